@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
 )
@@ -27,9 +28,21 @@ import (
 type Configuration struct {
 	metav1.TypeMeta `json:",inline"`
 
+	// Namespace is the namespace in which kueue is deployed. It's used as the
+	// namespace of the leader election lock when
+	// leaderElection.resourceNamespace is not set, which allows running kueue
+	// out of the cluster.
+	// +optional
+	Namespace *string `json:"namespace,omitempty"`
+
 	// ControllerManagerConfigurationSpec returns the configurations for controllers
 	cfg.ControllerManagerConfigurationSpec `json:",inline"`
 
+	// ClientConnection configures the connection of the manager to the
+	// Kubernetes API server.
+	// +optional
+	ClientConnection *ClientConnection `json:"clientConnection,omitempty"`
+
 	// ManageJobsWithoutQueueName controls whether or not Kueue reconciles
 	// batch/v1.Jobs that don't set the annotation kueue.x-k8s.io/queue-name.
 	// If set to true, then those jobs will be suspended and never started unless
@@ -38,6 +51,321 @@ type Configuration struct {
 	// Defaults to false; therefore, those jobs are not managed and if they are created
 	// unsuspended, they will start immediately.
 	ManageJobsWithoutQueueName bool `json:"manageJobsWithoutQueueName"`
+
+	// WorkloadAdmitters is the list of users allowed to set or clear the
+	// admission of a Workload, which should include the service account that
+	// kueue runs as, in the form system:serviceaccount:<namespace>:<name>.
+	// If empty, any user that can update Workloads can change their admission.
+	WorkloadAdmitters []string `json:"workloadAdmitters,omitempty"`
+
+	// WaitForPodsReady configures the eviction of admitted workloads whose
+	// pods don't become ready in time.
+	WaitForPodsReady *WaitForPodsReady `json:"waitForPodsReady,omitempty"`
+
+	// FairSharing configures the sharing of the unused quota among the
+	// ClusterQueues of a cohort.
+	FairSharing *FairSharing `json:"fairSharing,omitempty"`
+
+	// Resources configures how the resources requested by the workloads are
+	// accounted.
+	Resources *Resources `json:"resources,omitempty"`
+
+	// DryRun configures the scheduler to only report the admissions that it
+	// would do.
+	DryRun *DryRun `json:"dryRun,omitempty"`
+
+	// AdmissionWorkers is the maximum number of admitted workloads that the
+	// scheduler updates in the apiserver at the same time. The scheduler
+	// assumes the admissions in its cache and keeps admitting workloads while
+	// the updates are in flight. Defaults to 10. Zero or less means no limit.
+	// +optional
+	AdmissionWorkers *int32 `json:"admissionWorkers,omitempty"`
+
+	// QueueVisibility configures the list of pending workloads reported in
+	// the status of the ClusterQueues and Queues.
+	// +optional
+	QueueVisibility *QueueVisibility `json:"queueVisibility,omitempty"`
+
+	// InadmissibleWorkloads configures when the workloads found inadmissible
+	// are considered for admission again.
+	// +optional
+	InadmissibleWorkloads *InadmissibleWorkloads `json:"inadmissibleWorkloads,omitempty"`
+
+	// Debug configures the diagnostics of the scheduling latency.
+	// +optional
+	Debug *Debug `json:"debug,omitempty"`
+
+	// ProvisioningRequests configures the AdmissionCheck controller that
+	// provisions the nodes of the workloads through the cluster-autoscaler
+	// before they are admitted.
+	// +optional
+	ProvisioningRequests *ProvisioningRequests `json:"provisioningRequests,omitempty"`
+
+	// MultiKueue configures the AdmissionCheck controller that dispatches
+	// the workloads to other clusters.
+	// +optional
+	MultiKueue *MultiKueue `json:"multiKueue,omitempty"`
+
+	// ObjectRetentionPolicy configures the deletion of the finished
+	// workloads.
+	// +optional
+	ObjectRetentionPolicy *ObjectRetentionPolicy `json:"objectRetentionPolicy,omitempty"`
+}
+
+// ClientConnection defines the configuration of the client of the manager.
+type ClientConnection struct {
+	// QPS is the number of queries per second allowed to the API server.
+	// Defaults to the client-go default if not set.
+	// +optional
+	QPS *float32 `json:"qps,omitempty"`
+
+	// Burst is the number of queries allowed to the API server above QPS for
+	// short periods. Defaults to the client-go default if not set.
+	// +optional
+	Burst *int32 `json:"burst,omitempty"`
+}
+
+// WaitForPodsReady defines the configuration for the PodsReady timeout.
+type WaitForPodsReady struct {
+	// Enable indicates whether Kueue tracks the PodsReady condition of the
+	// admitted workloads and evicts the ones whose pods don't become ready
+	// within the timeout. Evicted workloads are requeued with an exponential
+	// backoff.
+	Enable bool `json:"enable,omitempty"`
+
+	// Timeout is the time that the pods of an admitted workload have to become
+	// ready or succeed. Defaults to 5 minutes.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// RequeuingStrategy configures the backoff before a workload evicted by
+	// the timeout is requeued.
+	// +optional
+	RequeuingStrategy *RequeuingStrategy `json:"requeuingStrategy,omitempty"`
+
+	// BlockAdmission indicates whether the scheduler waits for the pods of
+	// all the admitted workloads to be ready before admitting another
+	// workload, so that workloads that need all their pods running at the same
+	// time don't deadlock each other by holding part of the cluster each.
+	// Defaults to false.
+	// +optional
+	BlockAdmission *bool `json:"blockAdmission,omitempty"`
+}
+
+// RequeuingStrategy defines the backoff of the workloads evicted by the
+// PodsReady timeout.
+type RequeuingStrategy struct {
+	// BackoffLimitCount is the number of times that a workload is requeued
+	// after consecutive evictions. When it exceeds the timeout again, the
+	// workload is evicted with the RequeuingLimitExceeded reason and
+	// deactivated, by setting its .spec.active to false, instead of requeued.
+	// A ClusterQueue can override it with its .spec.requeuingLimitCount.
+	// If not set, the workload is always requeued.
+	// +optional
+	BackoffLimitCount *int32 `json:"backoffLimitCount,omitempty"`
+
+	// BackoffBaseSeconds is the delay before requeuing a workload after its
+	// first eviction. The delay doubles with every consecutive eviction.
+	// Defaults to 60.
+	// +optional
+	BackoffBaseSeconds *int32 `json:"backoffBaseSeconds,omitempty"`
+
+	// BackoffMaxSeconds is the maximum delay before requeuing a workload.
+	// Defaults to 3600.
+	// +optional
+	BackoffMaxSeconds *int32 `json:"backoffMaxSeconds,omitempty"`
+}
+
+// FairSharing defines the configuration for the fair sharing of the unused
+// quota of a cohort.
+type FairSharing struct {
+	// Enable indicates whether the ClusterQueues of a cohort get the unused
+	// quota according to their dominant resource share: the highest share of
+	// any resource that a ClusterQueue borrows, relative to the nominal quotas of
+	// the cohort. When workloads from several ClusterQueues need to borrow,
+	// the ClusterQueue with the lowest share goes first, and a ClusterQueue
+	// that preempts within its cohort can also reclaim quota borrowed by
+	// ClusterQueues with a higher share.
+	Enable bool `json:"enable,omitempty"`
+}
+
+// Resources defines the configuration for the accounting of the resources
+// requested by the workloads.
+type Resources struct {
+	// ExcludeResourcePrefixes is the list of prefixes of the resource names
+	// that kueue ignores. The requests of those resources don't need quota
+	// in the ClusterQueues.
+	ExcludeResourcePrefixes []string `json:"excludeResourcePrefixes,omitempty"`
+
+	// Transformations converts the requests of some resources into requests
+	// of other resources before they are accounted, for example to account
+	// a MIG partition as a fraction of a GPU. The transformations apply to
+	// the total requests of each podSet, rounded up, and before the excluded
+	// resources are dropped. Each input resource can have one transformation.
+	// +optional
+	Transformations []ResourceTransformation `json:"transformations,omitempty"`
+}
+
+// ResourceTransformation defines how the requests of a resource are
+// accounted.
+type ResourceTransformation struct {
+	// Input is the name of the requested resource.
+	Input corev1.ResourceName `json:"input"`
+
+	// Strategy is either Retain, to account the requests of the input
+	// resource in addition to the outputs, or Replace, to only account the
+	// outputs. Replace with no outputs excludes the input resource.
+	// Defaults to Retain.
+	// +optional
+	Strategy string `json:"strategy,omitempty"`
+
+	// Outputs are the quantities of each resource that one unit of the input
+	// resource amounts to. They can be fractions, like 142857u for 1/7.
+	// +optional
+	Outputs corev1.ResourceList `json:"outputs,omitempty"`
+}
+
+// DryRun defines the configuration for running the scheduler without
+// admitting workloads.
+type DryRun struct {
+	// Enable indicates whether the scheduler only records, in the Admitted
+	// condition and the events of the pending workloads, the ClusterQueue and
+	// flavors that would admit them or the number of workloads that it would
+	// preempt, without admitting or preempting any workload. Since nothing is
+	// admitted, each decision is made against the current usage of the
+	// ClusterQueues. Use it to validate the configuration of new
+	// ClusterQueues or for capacity planning.
+	Enable bool `json:"enable,omitempty"`
+}
+
+// QueueVisibility defines the configuration for the pending workloads
+// reported in the status of the ClusterQueues and Queues.
+type QueueVisibility struct {
+	// MaxCount is the maximum number of pending workloads listed in
+	// .status.pendingWorkloadsStatus of each ClusterQueue and Queue. Listing
+	// them requires sorting all the pending workloads of the ClusterQueue on
+	// every status update, so it defaults to 0, which disables the list.
+	// +optional
+	MaxCount int32 `json:"maxCount,omitempty"`
+
+	// UpdateIntervalSeconds is the minimum time between changes of the list
+	// of a ClusterQueue or Queue, so that its status isn't updated on every
+	// admission. Defaults to 5.
+	// +optional
+	UpdateIntervalSeconds *int32 `json:"updateIntervalSeconds,omitempty"`
+}
+
+// InadmissibleWorkloads defines the configuration for requeuing the
+// workloads found inadmissible in BestEffortFIFO ClusterQueues.
+type InadmissibleWorkloads struct {
+	// RequeuePolicy determines which events move the inadmissible
+	// workloads back to their ClusterQueues. Possible values are:
+	//
+	// - Immediate: any event in the cohort that might make a workload
+	// admissible, like a workload finishing, moves all the inadmissible
+	// workloads of the cohort back.
+	// - Eventual: only the events that free the quota of a resource move the
+	// inadmissible workloads of the cohort that request that resource back.
+	// - Timeout: each inadmissible workload is moved back after
+	// TimeoutSeconds.
+	//
+	// With any policy, changes to the ClusterQueues, Queues or
+	// ResourceFlavors, and to the workload itself, move the workload back.
+	// Defaults to Immediate.
+	// +optional
+	RequeuePolicy string `json:"requeuePolicy,omitempty"`
+
+	// TimeoutSeconds is the time after which an inadmissible workload is
+	// moved back with the Timeout policy. Defaults to 60.
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// Debug defines the configuration for diagnosing the performance of kueue.
+type Debug struct {
+	// Enable indicates whether kueue serves the pprof profiles under
+	// /debug/pprof/ in the metrics endpoint and measures the time that each
+	// scheduling cycle spends in its phases: building the snapshot,
+	// nominating the workloads, assigning flavors, planning preemptions and
+	// updating the workloads in the API server. The times are reported in the
+	// kueue_admission_cycle_phase_duration_seconds metric and in a log
+	// summary of each cycle.
+	Enable bool `json:"enable,omitempty"`
+}
+
+// ProvisioningRequests defines the configuration for the AdmissionCheck
+// controller that creates ProvisioningRequests of the cluster-autoscaler.
+type ProvisioningRequests struct {
+	// Enable indicates whether kueue evaluates the AdmissionChecks with the
+	// kueue.x-k8s.io/provisioning-request controllerName: for each workload
+	// with a quota reservation, it creates a ProvisioningRequest with the
+	// podSets of the workload and marks the check Ready once the capacity is
+	// provisioned. It requires the ProvisioningRequest API,
+	// autoscaling.x-k8s.io/v1beta1, in the cluster.
+	Enable bool `json:"enable,omitempty"`
+
+	// ProvisioningClassName is the class of the ProvisioningRequests.
+	// Defaults to best-effort-atomic-scale-up.autoscaling.x-k8s.io.
+	// +optional
+	ProvisioningClassName string `json:"provisioningClassName,omitempty"`
+
+	// RetryLimitCount is the number of times that a failed
+	// ProvisioningRequest is recreated. When the last one fails, the check
+	// requests a retry, which evicts and requeues the workload. Defaults to
+	// 3.
+	// +optional
+	RetryLimitCount *int32 `json:"retryLimitCount,omitempty"`
+
+	// RetryBaseSeconds is the delay before recreating a ProvisioningRequest
+	// after the first failure. The delay doubles with every consecutive
+	// failure. Defaults to 60.
+	// +optional
+	RetryBaseSeconds *int32 `json:"retryBaseSeconds,omitempty"`
+
+	// RetryMaxSeconds is the maximum delay before recreating a
+	// ProvisioningRequest. Defaults to 1800.
+	// +optional
+	RetryMaxSeconds *int32 `json:"retryMaxSeconds,omitempty"`
+}
+
+// MultiKueue defines the configuration for the AdmissionCheck controller
+// that dispatches the workloads to the clusters of a MultiKueueConfig.
+type MultiKueue struct {
+	// Enable indicates whether kueue evaluates the AdmissionChecks with the
+	// kueue.x-k8s.io/multikueue controllerName: for each workload with a
+	// quota reservation, it creates a copy of the workload in the clusters
+	// of the MultiKueueConfig of the check, and runs the job of the
+	// workload in the first cluster that admits it. The kubeconfigs of the
+	// clusters are read from Secrets in the namespace of kueue.
+	Enable bool `json:"enable,omitempty"`
+
+	// Origin is the value of the kueue.x-k8s.io/multikueue-origin label of
+	// the workloads and jobs created in the remote clusters. Clusters that
+	// dispatch workloads to the same remote clusters need different origins.
+	// Defaults to multikueue.
+	// +optional
+	Origin string `json:"origin,omitempty"`
+
+	// SyncPeriodSeconds is how often the remote workloads and jobs are
+	// polled, and how often unreachable clusters are retried. Defaults to
+	// 10.
+	// +optional
+	SyncPeriodSeconds *int32 `json:"syncPeriodSeconds,omitempty"`
+}
+
+// ObjectRetentionPolicy defines when the finished workloads are deleted.
+type ObjectRetentionPolicy struct {
+	// FinishedWorkloadTTLSeconds is the time that a workload is kept after
+	// its Finished condition is set. Once expired, the workload is deleted.
+	// If not set, the finished workloads are kept until their owners are
+	// deleted.
+	// +optional
+	FinishedWorkloadTTLSeconds *int32 `json:"finishedWorkloadTTLSeconds,omitempty"`
+
+	// DeleteFinishedOwners indicates whether the owner of an expired
+	// finished workload, like its Job, is deleted along with it. Defaults to
+	// false.
+	// +optional
+	DeleteFinishedOwners bool `json:"deleteFinishedOwners,omitempty"`
 }
 
 func init() {
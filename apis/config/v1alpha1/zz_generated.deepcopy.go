@@ -22,14 +22,111 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientConnection) DeepCopyInto(out *ClientConnection) {
+	*out = *in
+	if in.QPS != nil {
+		in, out := &in.QPS, &out.QPS
+		*out = new(float32)
+		**out = **in
+	}
+	if in.Burst != nil {
+		in, out := &in.Burst, &out.Burst
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientConnection.
+func (in *ClientConnection) DeepCopy() *ClientConnection {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientConnection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Configuration) DeepCopyInto(out *Configuration) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
+	if in.Namespace != nil {
+		in, out := &in.Namespace, &out.Namespace
+		*out = new(string)
+		**out = **in
+	}
 	in.ControllerManagerConfigurationSpec.DeepCopyInto(&out.ControllerManagerConfigurationSpec)
+	if in.ClientConnection != nil {
+		in, out := &in.ClientConnection, &out.ClientConnection
+		*out = new(ClientConnection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WorkloadAdmitters != nil {
+		in, out := &in.WorkloadAdmitters, &out.WorkloadAdmitters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WaitForPodsReady != nil {
+		in, out := &in.WaitForPodsReady, &out.WaitForPodsReady
+		*out = new(WaitForPodsReady)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FairSharing != nil {
+		in, out := &in.FairSharing, &out.FairSharing
+		*out = new(FairSharing)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(Resources)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRun)
+		**out = **in
+	}
+	if in.AdmissionWorkers != nil {
+		in, out := &in.AdmissionWorkers, &out.AdmissionWorkers
+		*out = new(int32)
+		**out = **in
+	}
+	if in.QueueVisibility != nil {
+		in, out := &in.QueueVisibility, &out.QueueVisibility
+		*out = new(QueueVisibility)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InadmissibleWorkloads != nil {
+		in, out := &in.InadmissibleWorkloads, &out.InadmissibleWorkloads
+		*out = new(InadmissibleWorkloads)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Debug != nil {
+		in, out := &in.Debug, &out.Debug
+		*out = new(Debug)
+		**out = **in
+	}
+	if in.ProvisioningRequests != nil {
+		in, out := &in.ProvisioningRequests, &out.ProvisioningRequests
+		*out = new(ProvisioningRequests)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MultiKueue != nil {
+		in, out := &in.MultiKueue, &out.MultiKueue
+		*out = new(MultiKueue)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObjectRetentionPolicy != nil {
+		in, out := &in.ObjectRetentionPolicy, &out.ObjectRetentionPolicy
+		*out = new(ObjectRetentionPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Configuration.
@@ -49,3 +146,267 @@ func (in *Configuration) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Debug) DeepCopyInto(out *Debug) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Debug.
+func (in *Debug) DeepCopy() *Debug {
+	if in == nil {
+		return nil
+	}
+	out := new(Debug)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRun) DeepCopyInto(out *DryRun) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRun.
+func (in *DryRun) DeepCopy() *DryRun {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FairSharing) DeepCopyInto(out *FairSharing) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FairSharing.
+func (in *FairSharing) DeepCopy() *FairSharing {
+	if in == nil {
+		return nil
+	}
+	out := new(FairSharing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InadmissibleWorkloads) DeepCopyInto(out *InadmissibleWorkloads) {
+	*out = *in
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InadmissibleWorkloads.
+func (in *InadmissibleWorkloads) DeepCopy() *InadmissibleWorkloads {
+	if in == nil {
+		return nil
+	}
+	out := new(InadmissibleWorkloads)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiKueue) DeepCopyInto(out *MultiKueue) {
+	*out = *in
+	if in.SyncPeriodSeconds != nil {
+		in, out := &in.SyncPeriodSeconds, &out.SyncPeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueue.
+func (in *MultiKueue) DeepCopy() *MultiKueue {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiKueue)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectRetentionPolicy) DeepCopyInto(out *ObjectRetentionPolicy) {
+	*out = *in
+	if in.FinishedWorkloadTTLSeconds != nil {
+		in, out := &in.FinishedWorkloadTTLSeconds, &out.FinishedWorkloadTTLSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectRetentionPolicy.
+func (in *ObjectRetentionPolicy) DeepCopy() *ObjectRetentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectRetentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningRequests) DeepCopyInto(out *ProvisioningRequests) {
+	*out = *in
+	if in.RetryLimitCount != nil {
+		in, out := &in.RetryLimitCount, &out.RetryLimitCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetryBaseSeconds != nil {
+		in, out := &in.RetryBaseSeconds, &out.RetryBaseSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetryMaxSeconds != nil {
+		in, out := &in.RetryMaxSeconds, &out.RetryMaxSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningRequests.
+func (in *ProvisioningRequests) DeepCopy() *ProvisioningRequests {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningRequests)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QueueVisibility) DeepCopyInto(out *QueueVisibility) {
+	*out = *in
+	if in.UpdateIntervalSeconds != nil {
+		in, out := &in.UpdateIntervalSeconds, &out.UpdateIntervalSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueueVisibility.
+func (in *QueueVisibility) DeepCopy() *QueueVisibility {
+	if in == nil {
+		return nil
+	}
+	out := new(QueueVisibility)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequeuingStrategy) DeepCopyInto(out *RequeuingStrategy) {
+	*out = *in
+	if in.BackoffLimitCount != nil {
+		in, out := &in.BackoffLimitCount, &out.BackoffLimitCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BackoffBaseSeconds != nil {
+		in, out := &in.BackoffBaseSeconds, &out.BackoffBaseSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BackoffMaxSeconds != nil {
+		in, out := &in.BackoffMaxSeconds, &out.BackoffMaxSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequeuingStrategy.
+func (in *RequeuingStrategy) DeepCopy() *RequeuingStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RequeuingStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceTransformation) DeepCopyInto(out *ResourceTransformation) {
+	*out = *in
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceTransformation.
+func (in *ResourceTransformation) DeepCopy() *ResourceTransformation {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceTransformation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Resources) DeepCopyInto(out *Resources) {
+	*out = *in
+	if in.ExcludeResourcePrefixes != nil {
+		in, out := &in.ExcludeResourcePrefixes, &out.ExcludeResourcePrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Transformations != nil {
+		in, out := &in.Transformations, &out.Transformations
+		*out = make([]ResourceTransformation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Resources.
+func (in *Resources) DeepCopy() *Resources {
+	if in == nil {
+		return nil
+	}
+	out := new(Resources)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WaitForPodsReady) DeepCopyInto(out *WaitForPodsReady) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RequeuingStrategy != nil {
+		in, out := &in.RequeuingStrategy, &out.RequeuingStrategy
+		*out = new(RequeuingStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlockAdmission != nil {
+		in, out := &in.BlockAdmission, &out.BlockAdmission
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WaitForPodsReady.
+func (in *WaitForPodsReady) DeepCopy() *WaitForPodsReady {
+	if in == nil {
+		return nil
+	}
+	out := new(WaitForPodsReady)
+	in.DeepCopyInto(out)
+	return out
+}
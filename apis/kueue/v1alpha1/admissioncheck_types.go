@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AdmissionCheckSpec defines the desired state of AdmissionCheck
+type AdmissionCheckSpec struct {
+	// controllerName is the name of the controller that evaluates the check
+	// and updates its state in the workloads. For example,
+	// example.com/budget-approval.
+	ControllerName string `json:"controllerName"`
+
+	// parameters references an object with the configuration of the check
+	// for its controller. For example, the MultiKueueConfig of a
+	// kueue.x-k8s.io/multikueue check.
+	// +optional
+	Parameters *AdmissionCheckParametersReference `json:"parameters,omitempty"`
+}
+
+// AdmissionCheckParametersReference references a cluster-scoped object.
+type AdmissionCheckParametersReference struct {
+	// apiGroup is the group of the object.
+	APIGroup string `json:"apiGroup"`
+
+	// kind is the kind of the object.
+	Kind string `json:"kind"`
+
+	// name is the name of the object.
+	Name string `json:"name"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Controller",JSONPath=".spec.controllerName",type=string,description="Controller that evaluates the check"
+
+// AdmissionCheck is the Schema for the admissionchecks API. An
+// AdmissionCheck gates the admission of the workloads of the ClusterQueues
+// that reference it, after their quota is reserved.
+type AdmissionCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AdmissionCheckSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AdmissionCheckList contains a list of AdmissionCheck
+type AdmissionCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AdmissionCheck `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AdmissionCheck{}, &AdmissionCheckList{})
+}
@@ -24,23 +24,29 @@ import (
 
 // ClusterQueueSpec defines the desired state of ClusterQueue
 type ClusterQueueSpec struct {
-	// resources represent the total pod requests of workloads dispatched
-	// via this clusterQueue. This doesn’t guarantee the actual availability of
-	// resources, although an integration with a resource provisioner like Cluster
-	// Autoscaler is possible to achieve that. Example:
+	// resourceGroups describes groups of resources that share the same
+	// flavors. Each resource group lists the resources it covers and the
+	// flavors that provide quota for them, in order of preference. The quotas
+	// represent the total pod requests of workloads dispatched via this
+	// clusterQueue. This doesn’t guarantee the actual availability of
+	// resources, although an integration with a resource provisioner like
+	// Cluster Autoscaler is possible to achieve that. Example:
 	//
-	// - name: cpu
+	// - coveredResources: ["cpu", "memory"]
 	//   flavors:
-	//   - quota:
-	//       min: 100
-	// - name: memory
-	//   flavors:
-	//   - quota:
-	//       min: 100Gi
+	//   - name: default
+	//     resources:
+	//     - name: cpu
+	//       nominalQuota: 100
+	//     - name: memory
+	//       nominalQuota: 100Gi
 	//
-	// +listType=map
-	// +listMapKey=name
-	Resources []Resource `json:"resources,omitempty"`
+	// A resource can only be covered by one group, and a flavor can only be
+	// part of one group.
+	//
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=16
+	ResourceGroups []ResourceGroup `json:"resourceGroups,omitempty"`
 
 	// cohort that this ClusterQueue belongs to. QCs that belong to the
 	// same cohort can borrow unused resources from each other.
@@ -53,13 +59,13 @@ type ClusterQueueSpec struct {
 	// 1. tenantB can run a workload consuming up to 20 k80 GPUs, meaning a resource
 	//    can be allocated from more than one clusterQueue in a cohort.
 	// 2. tenantB can not consume any p100 GPUs or spot because its QC has no quota
-	//    defined for them, and so the max is implicitly 0.
+	//    defined for them, and so the quota is implicitly 0.
 	// 3. If both tenantA and tenantB are running jobs such that current usage for
-	//    tenantA is lower than its min quota (e.g., 5 k80 GPUS) while
-	//    tenantB’s usage is higher than its min quota (e.g., 12 k80 GPUs),
+	//    tenantA is lower than its nominal quota (e.g., 5 k80 GPUS) while
+	//    tenantB’s usage is higher than its nominal quota (e.g., 12 k80 GPUs),
 	//    and both tenants have pending jobs requesting the remaining clusterQueue of
 	//    the cohort (the 3 k80 GPUs), then tenantA jobs will get this remaining
-	//    clusterQueue since tenantA is below its min limit.
+	//    clusterQueue since tenantA is below its nominal quota.
 	// 4. If a tenantA workload doesn’t tolerate spot, then the workload will only
 	//    be eligible to consume on-demand cores (the next in the list of cpu flavors).
 	// 5. Before considering on-demand, the workload will get assigned spot if
@@ -69,49 +75,54 @@ type ClusterQueueSpec struct {
 	//  name: tenantA
 	// spec:
 	//  cohort: borrowing-cohort
-	//  resources:
-	// - name: cpu
-	//   - name: spot
-	//     quota:
-	//       min: 1000
-	//   - name: on-demand
-	//     quota:
-	//       min: 100
-	// - name: nvidia.com/gpus
-	//   - name: k80
-	//     quota:
-	//       min: 10
-	//       max: 20
-	//     labels:
-	//     - cloud.provider.com/accelerator: nvidia-tesla-k80
-	//   - name: p100
-	//     quota:
-	//       min: 10
-	//       max: 20
-	//     labels:
-	//     - cloud.provider.com/accelerator: nvidia-tesla-p100
+	//  resourceGroups:
+	//  - coveredResources: ["cpu"]
+	//    flavors:
+	//    - name: spot
+	//      resources:
+	//      - name: cpu
+	//        nominalQuota: 1000
+	//    - name: on-demand
+	//      resources:
+	//      - name: cpu
+	//        nominalQuota: 100
+	//  - coveredResources: ["nvidia.com/gpus"]
+	//    flavors:
+	//    - name: k80
+	//      resources:
+	//      - name: nvidia.com/gpus
+	//        nominalQuota: 10
+	//        borrowingLimit: 10
+	//    - name: p100
+	//      resources:
+	//      - name: nvidia.com/gpus
+	//        nominalQuota: 10
+	//        borrowingLimit: 10
 	//
 	// metadata:
 	//  name: tenantB
 	// spec:
 	//  cohort: borrowing-cohort
-	//  resources:
-	// - name: cpu
-	//   - name: on-demand
-	//     quota:
-	//       min: 100
-	// - name: nvidia.com/gpus
-	//   - name: k80
-	//     quota:
-	//       min: 10
-	//       max: 20
-	//     labels:
-	//     - cloud.provider.com/accelerator: nvidia-tesla-k80
+	//  resourceGroups:
+	//  - coveredResources: ["cpu"]
+	//    flavors:
+	//    - name: on-demand
+	//      resources:
+	//      - name: cpu
+	//        nominalQuota: 100
+	//  - coveredResources: ["nvidia.com/gpus"]
+	//    flavors:
+	//    - name: k80
+	//      resources:
+	//      - name: nvidia.com/gpus
+	//        nominalQuota: 10
+	//        borrowingLimit: 10
 	//
 	// If empty, this ClusterQueue cannot borrow from any other ClusterQueue and vice versa.
 	//
 	// The name style is similar to label keys. These are just names to link QCs
-	// together, and they are meaningless otherwise.
+	// together, unless a Cohort object with the same name nests the cohort in
+	// a parent Cohort or sets limits on its usage.
 	Cohort string `json:"cohort,omitempty"`
 
 	// QueueingStrategy indicates the queueing strategy of the workloads
@@ -129,12 +140,148 @@ type ClusterQueueSpec struct {
 	// +kubebuilder:validation:Enum=StrictFIFO;BestEffortFIFO
 	QueueingStrategy QueueingStrategy `json:"queueingStrategy,omitempty"`
 
+	// fairQueueing indicates how admissions are interleaved across the
+	// queues of this ClusterQueue.
+	//
+	// - None: workloads from all the queues are ordered together, following
+	// the queueingStrategy.
+	// - QueueWeight: the queues take turns to provide the next workload to
+	// admit, proportionally to their .spec.weight, so that the backlog of a
+	// queue can't starve the others. Within a queue, workloads are ordered
+	// following the queueingStrategy.
+	// - NamespaceRoundRobin: the namespaces take turns to provide the next
+	// workload to admit, so that a burst of workloads from one namespace
+	// can't occupy the entire head of the ClusterQueue. Within a namespace,
+	// workloads are ordered following the queueingStrategy.
+	//
+	// +kubebuilder:default=None
+	// +kubebuilder:validation:Enum=None;QueueWeight;NamespaceRoundRobin
+	FairQueueing FairQueueingPolicy `json:"fairQueueing,omitempty"`
+
+	// priorityAging increases the priority with which the pending workloads
+	// are ordered in this ClusterQueue the longer they wait, so that
+	// low-priority workloads don't starve behind a steady stream of
+	// higher-priority ones. It only applies with the BestEffortFIFO
+	// queueingStrategy and it doesn't change the priority used for
+	// preemption.
+	// If null, the pending workloads are ordered by their priority.
+	// +optional
+	PriorityAging *PriorityAging `json:"priorityAging,omitempty"`
+
 	// namespaceSelector defines which namespaces are allowed to submit workloads to
 	// this clusterQueue. Beyond this basic support for policy, an policy agent like
 	// Gatekeeper should be used to enforce more advanced policies.
 	// Defaults to null which is a nothing selector (no namespaces eligible).
 	// If set to an empty selector `{}`, then all namespaces are eligible.
 	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// minBorrowingPriority is the minimum priority that a workload needs to
+	// borrow resources from the cohort. Workloads with a lower priority can
+	// only be admitted within the nominal quota of this ClusterQueue, keeping
+	// borrowed (and thus preemptible) capacity away from workloads that can't
+	// tolerate preemption.
+	// If null, any workload can borrow.
+	// +optional
+	MinBorrowingPriority *int32 `json:"minBorrowingPriority,omitempty"`
+
+	// preemption describes the policies to preempt admitted workloads in order
+	// to admit a pending workload that doesn't fit in the available quota.
+	// If null, workloads are never preempted.
+	// +optional
+	Preemption *ClusterQueuePreemption `json:"preemption,omitempty"`
+
+	// flavorFungibility describes whether a workload should try the next
+	// flavor of a resource before borrowing or preempting in the current one.
+	// If null, workloads borrow in the first flavor that fits and only try the
+	// next flavor instead of preempting.
+	// +optional
+	FlavorFungibility *FlavorFungibility `json:"flavorFungibility,omitempty"`
+
+	// fairSharing configures the share of the unused quota of the cohort
+	// that this ClusterQueue gets when fair sharing is enabled in the
+	// configuration of Kueue.
+	// If null, the ClusterQueue has a weight of 1.
+	// +optional
+	FairSharing *FairSharing `json:"fairSharing,omitempty"`
+
+	// admissionChecks are the names of the AdmissionChecks that the workloads
+	// of this ClusterQueue have to pass, after their quota is reserved, to be
+	// admitted.
+	// +listType=set
+	// +optional
+	AdmissionChecks []string `json:"admissionChecks,omitempty"`
+
+	// maxAdmittedWorkloads is the maximum number of workloads that can
+	// reserve quota in this ClusterQueue at the same time, even if it has
+	// quota for more. It limits the concurrency of the workloads regardless
+	// of their requests. The number of workloads counted against the limit is
+	// reported in .status.reservingWorkloads.
+	// If null, the number of admitted workloads is only limited by quota.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxAdmittedWorkloads *int32 `json:"maxAdmittedWorkloads,omitempty"`
+
+	// requeuingLimitCount is the number of times that a workload admitted by
+	// this ClusterQueue is requeued after consecutive evictions because its
+	// pods didn't become ready in time. When it exceeds the timeout again,
+	// the workload is evicted with the RequeuingLimitExceeded reason and
+	// deactivated, instead of requeued, freeing the quota for other
+	// workloads. It only applies when waitForPodsReady is enabled in the
+	// configuration of kueue, and overrides its backoffLimitCount.
+	// If null, the backoffLimitCount of the configuration applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	RequeuingLimitCount *int32 `json:"requeuingLimitCount,omitempty"`
+
+	// namespaceQuotas limits the quantity of each resource, summed across
+	// flavors, that the workloads of any single namespace can reserve in this
+	// ClusterQueue at the same time. It prevents one namespace from taking
+	// all the quota of a ClusterQueue shared by many namespaces.
+	// The resources without a namespace quota are only limited by the quotas
+	// of the ClusterQueue.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	NamespaceQuotas []NamespaceQuota `json:"namespaceQuotas,omitempty"`
+
+	// quotaSchedules override the nominalQuota of some [flavor, resource]
+	// combinations during recurring time windows, for example to offer more
+	// quota at night. When the windows of several schedules overlap, the
+	// first schedule in the list that overrides a quota wins.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	QuotaSchedules []QuotaSchedule `json:"quotaSchedules,omitempty"`
+
+	// quotaShrinkPolicy determines what happens to the admitted workloads
+	// when the quotas of the ClusterQueue shrink below its usage, for
+	// example when the window of a quota schedule ends.
+	//
+	// - None: the admitted workloads keep running, and new workloads wait
+	// until the usage is below the quotas.
+	// - Evict: the lowest priority, most recently created workloads are
+	// evicted and requeued until the usage fits the quotas again. The usage
+	// of a ClusterQueue in a cohort only has to fit its nominalQuota plus
+	// its borrowingLimit, if set.
+	//
+	// +kubebuilder:default=None
+	// +kubebuilder:validation:Enum=None;Evict
+	QuotaShrinkPolicy QuotaShrinkPolicy `json:"quotaShrinkPolicy,omitempty"`
+
+	// stopPolicy allows to stop the admission of workloads from this
+	// ClusterQueue.
+	//
+	// - None: workloads are admitted normally.
+	// - Hold: pending workloads are not admitted, while the admitted
+	// workloads keep running.
+	// - HoldAndDrain: pending workloads are not admitted and the admitted
+	// workloads are evicted and requeued.
+	//
+	// +kubebuilder:default=None
+	// +kubebuilder:validation:Enum=None;Hold;HoldAndDrain
+	StopPolicy StopPolicy `json:"stopPolicy,omitempty"`
 }
 
 type QueueingStrategy string
@@ -151,68 +298,374 @@ const (
 	BestEffortFIFO QueueingStrategy = "BestEffortFIFO"
 )
 
-type Resource struct {
-	// name of the resource. For example, cpu, memory or nvidia.com/gpu.
-	Name corev1.ResourceName `json:"name"`
+type FairQueueingPolicy string
+
+const (
+	// NoFairQueueing means that workloads from all the queues are ordered
+	// together.
+	NoFairQueueing FairQueueingPolicy = "None"
+
+	// QueueWeightFairQueueing means that the queues take turns to provide
+	// the next workload, proportionally to their weights.
+	QueueWeightFairQueueing FairQueueingPolicy = "QueueWeight"
+
+	// NamespaceRoundRobinFairQueueing means that the namespaces take turns
+	// to provide the next workload.
+	NamespaceRoundRobinFairQueueing FairQueueingPolicy = "NamespaceRoundRobin"
+)
+
+type StopPolicy string
+
+const (
+	// StopPolicyNone means that the ClusterQueue admits workloads normally.
+	StopPolicyNone StopPolicy = "None"
+
+	// StopPolicyHold means that the ClusterQueue doesn't admit workloads,
+	// but the admitted ones keep running.
+	StopPolicyHold StopPolicy = "Hold"
+
+	// StopPolicyHoldAndDrain means that the ClusterQueue doesn't admit
+	// workloads and evicts the admitted ones.
+	StopPolicyHoldAndDrain StopPolicy = "HoldAndDrain"
+)
+
+type QuotaShrinkPolicy string
+
+const (
+	// QuotaShrinkPolicyNone means that the admitted workloads keep running
+	// when the quotas shrink below the usage.
+	QuotaShrinkPolicyNone QuotaShrinkPolicy = "None"
+
+	// QuotaShrinkPolicyEvict means that admitted workloads are evicted until
+	// the usage fits the quotas again.
+	QuotaShrinkPolicyEvict QuotaShrinkPolicy = "Evict"
+)
+
+type PreemptionPolicy string
 
-	// flavors is the list of different flavors of this resource and their limits.
-	// Typically two different “flavors” of the same resource represent
+const (
+	// PreemptionPolicyNever means that no workloads are preempted.
+	PreemptionPolicyNever PreemptionPolicy = "Never"
+
+	// PreemptionPolicyLowerPriority means that only workloads with a lower
+	// priority than the pending workload are preempted.
+	PreemptionPolicyLowerPriority PreemptionPolicy = "LowerPriority"
+
+	// PreemptionPolicyAny means that any workload can be preempted, regardless
+	// of its priority.
+	PreemptionPolicyAny PreemptionPolicy = "Any"
+)
+
+// PriorityAging defines how the pending workloads of a ClusterQueue gain
+// priority while they wait.
+type PriorityAging struct {
+	// increase is the priority that a pending workload gains for every
+	// period that it waits, since its creation or its last eviction by the
+	// PodsReady timeout.
+	// +kubebuilder:validation:Minimum=1
+	Increase int32 `json:"increase"`
+
+	// periodSeconds is the waiting time for each increase of the priority.
+	// Defaults to 60.
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// maxIncrease is the maximum priority that a pending workload can gain.
+	// If null, the priority keeps growing while the workload waits.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxIncrease *int32 `json:"maxIncrease,omitempty"`
+}
+
+// FairSharing contains the properties of the ClusterQueue for fair sharing.
+type FairSharing struct {
+	// weight is the share of the unused quota of the cohort that this
+	// ClusterQueue gets, relative to the other ClusterQueues of the cohort.
+	// The dominant resource share of the ClusterQueue is divided by its
+	// weight, so a ClusterQueue with weight 2 can borrow twice as much as a
+	// ClusterQueue with weight 1 before their shares are equal.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	Weight int32 `json:"weight,omitempty"`
+}
+
+// ClusterQueuePreemption contains policies to preempt admitted workloads.
+type ClusterQueuePreemption struct {
+	// withinClusterQueue determines whether a pending workload that doesn't fit
+	// in the quota of its ClusterQueue can preempt workloads admitted in the
+	// same ClusterQueue. Possible values are:
+	//
+	// - Never: don't preempt workloads in the ClusterQueue.
+	// - LowerPriority: preempt workloads in the ClusterQueue that have a lower
+	// priority than the pending workload.
+	//
+	// +kubebuilder:default=Never
+	// +kubebuilder:validation:Enum=Never;LowerPriority
+	WithinClusterQueue PreemptionPolicy `json:"withinClusterQueue,omitempty"`
+
+	// withinCohort determines whether a pending workload that doesn't fit in
+	// the nominal quota of its ClusterQueue can preempt workloads admitted in
+	// other ClusterQueues of the cohort that are borrowing resources. The
+	// pending workload reclaims the nominal quota of its ClusterQueue and,
+	// thus, it can only preempt workloads in the cohort if it fits without
+	// borrowing.
+	// Possible values are:
+	//
+	// - Never: don't preempt workloads in the cohort.
+	// - LowerPriority: preempt workloads in the cohort that have a lower
+	// priority than the pending workload.
+	// - Any: preempt any workload in the cohort, regardless of its priority.
+	//
+	// +kubebuilder:default=Never
+	// +kubebuilder:validation:Enum=Never;LowerPriority;Any
+	WithinCohort PreemptionPolicy `json:"withinCohort,omitempty"`
+}
+
+type FlavorFungibilityPolicy string
+
+const (
+	// Borrow means that the workload borrows in the current flavor.
+	Borrow FlavorFungibilityPolicy = "Borrow"
+
+	// Preempt means that the workload preempts in the current flavor.
+	Preempt FlavorFungibilityPolicy = "Preempt"
+
+	// TryNextFlavor means that the workload tries the next flavor.
+	TryNextFlavor FlavorFungibilityPolicy = "TryNextFlavor"
+)
+
+// FlavorFungibility determines the order in which the flavors of a resource
+// are considered when the workload doesn't fit in the nominal quota of a
+// flavor.
+type FlavorFungibility struct {
+	// whenCanBorrow determines what a workload does when it fits in a flavor
+	// by borrowing from the cohort. Possible values are:
+	//
+	// - Borrow: take the flavor, borrowing from the cohort.
+	// - TryNextFlavor: look for a next flavor in which the workload fits
+	// without borrowing. If there is none, take the first flavor in which the
+	// workload fits by borrowing.
+	//
+	// +kubebuilder:default=Borrow
+	// +kubebuilder:validation:Enum=Borrow;TryNextFlavor
+	WhenCanBorrow FlavorFungibilityPolicy `json:"whenCanBorrow,omitempty"`
+
+	// whenCanPreempt determines what a workload does when it doesn't fit in a
+	// flavor, but it could fit in the nominal quota of the flavor by preempting
+	// other workloads, following the preemption policies of the
+	// ClusterQueue. Possible values are:
+	//
+	// - Preempt: stop looking for flavors and preempt workloads to fit in
+	// the flavor, unless a previous flavor fits by borrowing.
+	// - TryNextFlavor: look for a next flavor in which the workload fits.
+	// Workloads are only preempted if the workload doesn't fit in any flavor.
+	//
+	// +kubebuilder:default=TryNextFlavor
+	// +kubebuilder:validation:Enum=Preempt;TryNextFlavor
+	WhenCanPreempt FlavorFungibilityPolicy `json:"whenCanPreempt,omitempty"`
+}
+
+type ResourceGroup struct {
+	// coveredResources is the list of resources covered by the flavors in
+	// this group. For example, cpu, memory or nvidia.com/gpu.
+	//
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	CoveredResources []corev1.ResourceName `json:"coveredResources"`
+
+	// flavors is the list of flavors that provide the resources of this
+	// group. Typically two different “flavors” of the same resource represent
 	// different hardware models (e.g., gpu models, cpu architectures) or
 	// pricing (on-demand vs spot cpus). The flavors are distinguished via labels and
 	// taints.
 	//
-	// For example, if the resource is nvidia.com/gpu, and we want to define
+	// For example, if the group covers nvidia.com/gpu, and we want to define
 	// different limits for different gpu models, then each model is mapped to a
 	// flavor and must set different values of a shared key. For example:
 	//
 	// spec:
-	//  resources:
-	// - name: nvidia.com/gpus
-	//   - name: k80
-	//     quota:
-	//       min: 10
-	//   - name: p100
-	//     quota:
-	//       min: 10
+	//  resourceGroups:
+	//  - coveredResources: ["nvidia.com/gpus"]
+	//    flavors:
+	//    - name: k80
+	//      resources:
+	//      - name: nvidia.com/gpus
+	//        nominalQuota: 10
+	//    - name: p100
+	//      resources:
+	//      - name: nvidia.com/gpus
+	//        nominalQuota: 10
 	//
 	// The flavors are evaluated in order, selecting the first to satisfy a
 	// workload’s requirements. Also the quantities are additive, in the example
 	// above the GPU quota in total is 20 (10 k80 + 10 p100).
 	// A workload is limited to the selected type by converting the labels to a node
-	// selector that gets injected into the workload. This list can’t be empty, at
-	// least one flavor must exist.
+	// selector that gets injected into the workload. The pods of a podSet get
+	// the same flavor for all the resources of the group. This list can’t be
+	// empty, at least one flavor must exist.
 	//
 	// +listType=map
 	// +listMapKey=name
-	Flavors []Flavor `json:"flavors,omitempty"`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	Flavors []FlavorQuotas `json:"flavors"`
+
+	// flavorAssignment indicates whether the podSets of a workload can be
+	// assigned different flavors of the resources of this group.
+	//
+	// - AnyFlavor: each podSet gets the first flavor that fits its requests,
+	// so a workload can be split across flavors.
+	// - SameFlavor: all the podSets get the same flavor, which has to fit the
+	// requests of the whole workload.
+	//
+	// Workloads can also require the same flavor for a resource through
+	// .spec.sameFlavorResources.
+	//
+	// +kubebuilder:default=AnyFlavor
+	// +kubebuilder:validation:Enum=AnyFlavor;SameFlavor
+	FlavorAssignment FlavorAssignmentPolicy `json:"flavorAssignment,omitempty"`
 }
 
-type Flavor struct {
+type FlavorAssignmentPolicy string
+
+const (
+	// AnyFlavor means that each podSet of a workload gets the first flavor
+	// that fits its requests.
+	AnyFlavor FlavorAssignmentPolicy = "AnyFlavor"
+
+	// SameFlavor means that all the podSets of a workload get the same flavor.
+	SameFlavor FlavorAssignmentPolicy = "SameFlavor"
+)
+
+type FlavorQuotas struct {
 	// name is a reference to the resourceFlavor that defines this flavor.
 	// +kubebuilder:default=default
 	Name ResourceFlavorReference `json:"name"`
 
-	// quota is the limit of resource usage at a point in time.
-	Quota Quota `json:"quota"`
+	// resources is the list of quotas for this flavor per resource.
+	// It must list the coveredResources of the group, in the same order.
+	//
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	Resources []ResourceQuota `json:"resources"`
+}
+
+// NamespaceQuota is the limit of a resource for each namespace of a
+// ClusterQueue.
+type NamespaceQuota struct {
+	// name of the resource. For example, cpu, memory or nvidia.com/gpu.
+	Name corev1.ResourceName `json:"name"`
+
+	// limit is the maximum quantity of the resource that the workloads of a
+	// namespace can reserve. Like quotas, it can't be negative.
+	Limit resource.Quantity `json:"limit"`
+}
+
+// QuotaSchedule is a recurring time window during which some quotas of a
+// ClusterQueue are overridden.
+type QuotaSchedule struct {
+	// name identifies the schedule.
+	Name string `json:"name"`
+
+	// schedule is a cron expression, in UTC, for the start of the windows,
+	// with the fields minute, hour, day of month, month and day of week. For
+	// example, "0 22 * * 1-5" starts a window at 22:00 on weekdays.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// durationSeconds is the length of each window.
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=604800
+	DurationSeconds int32 `json:"durationSeconds"`
+
+	// quotas are the nominalQuotas that apply during the windows. The
+	// [flavor, resource] combinations must be in the resourceGroups of the
+	// ClusterQueue, and keep their borrowingLimit, lendingLimit and
+	// overcommitPercentage.
+	// +listType=map
+	// +listMapKey=flavor
+	// +listMapKey=resource
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	Quotas []ScheduledQuota `json:"quotas"`
+}
+
+// ScheduledQuota is the nominalQuota of a [flavor, resource] combination
+// during the windows of a QuotaSchedule.
+type ScheduledQuota struct {
+	// flavor is the name of the flavor.
+	Flavor ResourceFlavorReference `json:"flavor"`
+
+	// resource is the name of the resource.
+	Resource corev1.ResourceName `json:"resource"`
+
+	// nominalQuota replaces the nominalQuota of the [flavor, resource]
+	// combination. Like quotas, it can't be negative, and it can't be less
+	// than the lendingLimit of the combination.
+	NominalQuota resource.Quantity `json:"nominalQuota"`
 }
 
 // ResourceFlavorReference is the name of the ResourceFlavor.
 type ResourceFlavorReference string
 
-type Quota struct {
-	// min amount of resource requests that are available to be used by workloads
-	// admitted by this ClusterQueue at a point in time.
-	// The sum of min quotas for a flavor in a cohort defines the maximum amount
-	// of resources that can be allocated by a ClusterQueue in the cohort.
-	Min resource.Quantity `json:"min,omitempty"`
-
-	// max is the upper limit on the amount of resource requests that
-	// can be used by workloads admitted by this ClusterQueue at a point in time.
-	// Resources can be borrowed from unused min quota of other
-	// ClusterQueues in the same cohort.
-	// If not null, it must be greater than or equal to min.
-	// If null, there is no upper limit for borrowing.
-	Max *resource.Quantity `json:"max,omitempty"`
+type ResourceQuota struct {
+	// name of the resource. For example, cpu, memory or nvidia.com/gpu.
+	Name corev1.ResourceName `json:"name"`
+
+	// nominalQuota is the quantity of this resource that is available for
+	// workloads admitted by this ClusterQueue at a point in time.
+	// Quotas can't be negative. Quotas for cpu are rounded up to millicores
+	// and quotas for other resources must be whole numbers.
+	// nominalQuota should represent the resources in the cluster available for
+	// running jobs (after discounting resources consumed by system components
+	// and pods not managed by kueue). In an autoscaled cluster, nominalQuota
+	// should account for resources that can be provided by a component such as
+	// Kubernetes cluster-autoscaler.
+	//
+	// If the ClusterQueue belongs to a cohort, the sum of the quotas for each
+	// (flavor, resource) combination defines the maximum quantity that can be
+	// allocated by a ClusterQueue in the cohort.
+	NominalQuota resource.Quantity `json:"nominalQuota"`
+
+	// borrowingLimit is the maximum amount of quota for the [flavor, resource]
+	// combination that this ClusterQueue is allowed to borrow from the unused
+	// quota of other ClusterQueues in the same cohort.
+	// In total, at a given time, workloads in a ClusterQueue can consume a
+	// quantity of quota equal to nominalQuota+borrowingLimit, assuming the other
+	// ClusterQueues in the cohort have enough unused quota.
+	// If null, it means that there is no borrowing limit.
+	// If not null, it must be non-negative.
+	// +optional
+	BorrowingLimit *resource.Quantity `json:"borrowingLimit,omitempty"`
+
+	// lendingLimit is the maximum amount of unused quota for the [flavor,
+	// resource] combination that this ClusterQueue can lend to other
+	// ClusterQueues in the same cohort. The rest of the nominalQuota is
+	// guaranteed to the workloads of this ClusterQueue and doesn't count
+	// towards the quota or the usage of the cohort.
+	// If null, it means that there is no lending limit, meaning that
+	// all the nominalQuota can be borrowed by other clusterQueues in the cohort.
+	// If not null, it must be non-negative and less than or equal to the
+	// nominalQuota.
+	// +optional
+	LendingLimit *resource.Quantity `json:"lendingLimit,omitempty"`
+
+	// overcommitPercentage is the percentage of the quotas of this
+	// [flavor, resource] combination that workloads can request. For example,
+	// a value of 150 allows admitting workloads requesting up to 1.5x the
+	// quotas, which is useful for resources like cpu, which batch workloads
+	// rarely use in full. Resources like GPUs should keep the default of 100,
+	// meaning no overcommitment.
+	//
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=1000
+	OvercommitPercentage int32 `json:"overcommitPercentage,omitempty"`
 }
 
 // ClusterQueueStatus defines the observed state of ClusterQueue
@@ -227,12 +680,72 @@ type ClusterQueueStatus struct {
 	// +optional
 	PendingWorkloads int32 `json:"pendingWorkloads"`
 
+	// reservingWorkloads is the number of workloads currently reserving quota
+	// in this clusterQueue, including the admitted ones, and haven't finished
+	// yet.
+	// +optional
+	ReservingWorkloads int32 `json:"reservingWorkloads"`
+
 	// AdmittedWorkloads is the number of workloads currently admitted to this
-	// clusterQueue and haven't finished yet.
+	// clusterQueue, whose quota is reserved and whose AdmissionChecks are
+	// Ready, and haven't finished yet.
 	// +optional
 	AdmittedWorkloads int32 `json:"admittedWorkloads"`
+
+	// conditions hold the latest available observations of the ClusterQueue
+	// current state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// pendingWorkloadsStatus lists the first pending workloads of the
+	// clusterQueue. It's only reported when enabled in the configuration of
+	// kueue.
+	// +optional
+	PendingWorkloadsStatus *PendingWorkloadsStatus `json:"pendingWorkloadsStatus,omitempty"`
+}
+
+// PendingWorkloadsStatus is the head of the pending workloads of a
+// clusterQueue or queue.
+type PendingWorkloadsStatus struct {
+	// head lists the first pending workloads, in the order in which they are
+	// considered for admission.
+	// +listType=atomic
+	// +optional
+	Head []PendingWorkload `json:"head,omitempty"`
+
+	// lastChangeTime is the last time the head changed. The head changes at
+	// most once per the update interval set in the configuration of kueue,
+	// so it can lag behind the queue.
+	LastChangeTime metav1.Time `json:"lastChangeTime"`
 }
 
+// PendingWorkload is a workload waiting for admission.
+type PendingWorkload struct {
+	// name is the name of the workload.
+	Name string `json:"name"`
+
+	// namespace is the namespace of the workload.
+	Namespace string `json:"namespace"`
+
+	// position is the position of the workload in its clusterQueue,
+	// starting at 0 for the next workload to be considered for admission.
+	Position int32 `json:"position"`
+
+	// priority is the priority of the workload.
+	Priority int32 `json:"priority"`
+}
+
+const (
+	// ClusterQueueActive indicates that the ClusterQueue can admit new
+	// workloads. It's False, with reason FlavorNotFound, while any of the
+	// ResourceFlavors that the ClusterQueue references doesn't exist.
+	ClusterQueueActive = "Active"
+)
+
 type UsedResources map[corev1.ResourceName]map[string]Usage
 
 type Usage struct {
@@ -240,7 +753,8 @@ type Usage struct {
 	// borrowed from the cohort.
 	Total *resource.Quantity `json:"total,omitempty"`
 
-	// Borrowed is the used quantity past the min quota, borrowed from the cohort.
+	// Borrowed is the used quantity past the nominal quota, borrowed from the
+	// cohort.
 	Borrowed *resource.Quantity `json:"borrowing,omitempty"`
 }
 
@@ -250,6 +764,7 @@ type Usage struct {
 //+kubebuilder:printcolumn:name="Cohort",JSONPath=".spec.cohort",type=string,description="Cohort that this ClusterQueue belongs to"
 //+kubebuilder:printcolumn:name="Strategy",JSONPath=".spec.queueingStrategy",type=string,description="The queueing strategy used to prioritize workloads",priority=1
 //+kubebuilder:printcolumn:name="Pending Workloads",JSONPath=".status.pendingWorkloads",type=integer,description="Number of pending workloads"
+//+kubebuilder:printcolumn:name="Reserving Workloads",JSONPath=".status.reservingWorkloads",type=integer,description="Number of workloads reserving quota that haven't finished yet",priority=1
 //+kubebuilder:printcolumn:name="Admitted Workloads",JSONPath=".status.admittedWorkloads",type=integer,description="Number of admitted workloads that haven't finished yet",priority=1
 
 // ClusterQueue is the Schema for the clusterQueue API.
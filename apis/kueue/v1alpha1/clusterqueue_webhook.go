@@ -0,0 +1,261 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"math"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"sigs.k8s.io/kueue/pkg/util/cron"
+)
+
+const (
+	// MaxQuota is the maximum value of a quota, in the units in which the
+	// resource is tracked: millicores for cpu and absolute units for
+	// everything else. It leaves room to add up quotas and usage across a
+	// cohort, and to apply overcommitment, without overflowing int64.
+	MaxQuota = math.MaxInt64 / 1000
+
+	// MaxOvercommitPercentage is the maximum overcommitPercentage of a
+	// resource.
+	MaxOvercommitPercentage = 1000
+)
+
+// log is for logging in this package.
+var clusterQueueLog = ctrl.Log.WithName("clusterqueue-webhook")
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks for
+// ClusterQueues.
+func (r *ClusterQueue) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-kueue-x-k8s-io-v1alpha1-clusterqueue,mutating=true,failurePolicy=fail,sideEffects=None,groups=kueue.x-k8s.io,resources=clusterqueues,verbs=create;update,versions=v1alpha1,name=mclusterqueue.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &ClusterQueue{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type.
+// It normalizes the quotas to the precision in which they are tracked: cpu
+// quotas are rounded up to millicores.
+func (r *ClusterQueue) Default() {
+	clusterQueueLog.V(5).Info("defaulter", "clusterQueue", klog.KObj(r))
+
+	for i := range r.Spec.ResourceGroups {
+		rg := &r.Spec.ResourceGroups[i]
+		for j := range rg.Flavors {
+			for k := range rg.Flavors[j].Resources {
+				quota := &rg.Flavors[j].Resources[k]
+				if quota.Name != corev1.ResourceCPU {
+					continue
+				}
+				quota.NominalQuota = normalizeCPUQuota(quota.NominalQuota)
+				if quota.BorrowingLimit != nil {
+					limit := normalizeCPUQuota(*quota.BorrowingLimit)
+					quota.BorrowingLimit = &limit
+				}
+				if quota.LendingLimit != nil {
+					limit := normalizeCPUQuota(*quota.LendingLimit)
+					quota.LendingLimit = &limit
+				}
+			}
+		}
+	}
+	for i := range r.Spec.QuotaSchedules {
+		for j := range r.Spec.QuotaSchedules[i].Quotas {
+			if quota := &r.Spec.QuotaSchedules[i].Quotas[j]; quota.Resource == corev1.ResourceCPU {
+				quota.NominalQuota = normalizeCPUQuota(quota.NominalQuota)
+			}
+		}
+	}
+}
+
+func normalizeCPUQuota(q resource.Quantity) resource.Quantity {
+	if q.Sign() < 0 || q.CmpInt64(MaxQuota/1000) > 0 {
+		// Leave it for validation to reject.
+		return q
+	}
+	return *resource.NewMilliQuantity(q.MilliValue(), q.Format)
+}
+
+// +kubebuilder:webhook:path=/validate-kueue-x-k8s-io-v1alpha1-clusterqueue,mutating=false,failurePolicy=fail,sideEffects=None,groups=kueue.x-k8s.io,resources=clusterqueues,verbs=create;update,versions=v1alpha1,name=vclusterqueue.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ClusterQueue{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *ClusterQueue) ValidateCreate() error {
+	clusterQueueLog.V(5).Info("validate create", "clusterQueue", klog.KObj(r))
+	return ValidateClusterQueue(r).ToAggregate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *ClusterQueue) ValidateUpdate(old runtime.Object) error {
+	clusterQueueLog.V(5).Info("validate update", "clusterQueue", klog.KObj(r))
+	return ValidateClusterQueue(r).ToAggregate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *ClusterQueue) ValidateDelete() error {
+	return nil
+}
+
+// ValidateClusterQueue validates the resource groups of a ClusterQueue, so
+// that the flavors of a group provide quota for the same resources, and the
+// quotas, so that they can be tracked with integer arithmetic during fit
+// checks.
+func ValidateClusterQueue(cq *ClusterQueue) field.ErrorList {
+	var allErrs field.ErrorList
+	groupsPath := field.NewPath("spec", "resourceGroups")
+	seenResources := make(map[corev1.ResourceName]bool)
+	seenFlavors := make(map[ResourceFlavorReference]bool)
+	for i := range cq.Spec.ResourceGroups {
+		rg := &cq.Spec.ResourceGroups[i]
+		rgPath := groupsPath.Index(i)
+		for j, name := range rg.CoveredResources {
+			if seenResources[name] {
+				allErrs = append(allErrs, field.Duplicate(rgPath.Child("coveredResources").Index(j), name))
+			}
+			seenResources[name] = true
+		}
+		for j := range rg.Flavors {
+			flavor := &rg.Flavors[j]
+			flavorPath := rgPath.Child("flavors").Index(j)
+			if seenFlavors[flavor.Name] {
+				allErrs = append(allErrs, field.Duplicate(flavorPath.Child("name"), flavor.Name))
+			}
+			seenFlavors[flavor.Name] = true
+			allErrs = append(allErrs, validateFlavorQuotas(flavor, rg.CoveredResources, flavorPath)...)
+		}
+	}
+	nsQuotasPath := field.NewPath("spec", "namespaceQuotas")
+	for i, q := range cq.Spec.NamespaceQuotas {
+		allErrs = append(allErrs, validateQuotaQuantity(q.Name, q.Limit, nsQuotasPath.Index(i).Child("limit"))...)
+	}
+	schedulesPath := field.NewPath("spec", "quotaSchedules")
+	for i := range cq.Spec.QuotaSchedules {
+		allErrs = append(allErrs, validateQuotaSchedule(&cq.Spec.QuotaSchedules[i], cq.Spec.ResourceGroups, schedulesPath.Index(i))...)
+	}
+	return allErrs
+}
+
+// validateQuotaSchedule checks that the schedule is a valid cron expression
+// and that it overrides quotas of the resource groups, without going below
+// their lendingLimit.
+func validateQuotaSchedule(s *QuotaSchedule, groups []ResourceGroup, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if _, err := cron.Parse(s.Schedule); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("schedule"), s.Schedule, err.Error()))
+	}
+	for i, q := range s.Quotas {
+		quotaPath := path.Child("quotas").Index(i)
+		quota := findQuota(groups, q.Flavor, q.Resource)
+		if quota == nil {
+			allErrs = append(allErrs, field.Invalid(quotaPath, fmt.Sprintf("%s/%s", q.Flavor, q.Resource), "must be a flavor and resource of the resourceGroups"))
+		}
+		allErrs = append(allErrs, validateQuotaQuantity(q.Resource, q.NominalQuota, quotaPath.Child("nominalQuota"))...)
+		if quota != nil && quota.LendingLimit != nil && quota.LendingLimit.Cmp(q.NominalQuota) > 0 {
+			allErrs = append(allErrs, field.Invalid(quotaPath.Child("nominalQuota"), q.NominalQuota.String(),
+				fmt.Sprintf("must be greater than or equal to the lendingLimit: %s", quota.LendingLimit)))
+		}
+	}
+	return allErrs
+}
+
+// findQuota returns the quota of the resource for the flavor in the resource
+// groups, or nil if there isn't any.
+func findQuota(groups []ResourceGroup, flavor ResourceFlavorReference, name corev1.ResourceName) *ResourceQuota {
+	for i := range groups {
+		for j := range groups[i].Flavors {
+			if groups[i].Flavors[j].Name != flavor {
+				continue
+			}
+			for k := range groups[i].Flavors[j].Resources {
+				if q := &groups[i].Flavors[j].Resources[k]; q.Name == name {
+					return q
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateFlavorQuotas checks that the flavor has quotas for the covered
+// resources of its group, in the same order, and validates the quotas.
+func validateFlavorQuotas(flavor *FlavorQuotas, coveredResources []corev1.ResourceName, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	resourcesPath := path.Child("resources")
+	if len(flavor.Resources) != len(coveredResources) {
+		allErrs = append(allErrs, field.Invalid(resourcesPath, len(flavor.Resources),
+			fmt.Sprintf("must have the same number of resources as the coveredResources: %d", len(coveredResources))))
+	}
+	for i := range flavor.Resources {
+		quota := &flavor.Resources[i]
+		quotaPath := resourcesPath.Index(i)
+		if i < len(coveredResources) && quota.Name != coveredResources[i] {
+			allErrs = append(allErrs, field.Invalid(quotaPath.Child("name"), quota.Name,
+				fmt.Sprintf("must match the coveredResources, in the same order: %s", coveredResources[i])))
+		}
+		if quota.OvercommitPercentage > MaxOvercommitPercentage {
+			allErrs = append(allErrs, field.Invalid(quotaPath.Child("overcommitPercentage"), quota.OvercommitPercentage,
+				fmt.Sprintf("must be less than or equal to %d", MaxOvercommitPercentage)))
+		}
+		allErrs = append(allErrs, validateQuotaQuantity(quota.Name, quota.NominalQuota, quotaPath.Child("nominalQuota"))...)
+		if quota.BorrowingLimit != nil {
+			allErrs = append(allErrs, validateQuotaQuantity(quota.Name, *quota.BorrowingLimit, quotaPath.Child("borrowingLimit"))...)
+		}
+		if quota.LendingLimit != nil {
+			allErrs = append(allErrs, validateQuotaQuantity(quota.Name, *quota.LendingLimit, quotaPath.Child("lendingLimit"))...)
+			if quota.LendingLimit.Cmp(quota.NominalQuota) > 0 {
+				allErrs = append(allErrs, field.Invalid(quotaPath.Child("lendingLimit"), quota.LendingLimit.String(), "must be less than or equal to the nominalQuota"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateQuotaQuantity checks that the quantity is not negative, is not
+// too big and is a whole number in the units in which the resource is
+// tracked.
+func validateQuotaQuantity(name corev1.ResourceName, q resource.Quantity, path *field.Path) field.ErrorList {
+	if q.Sign() < 0 {
+		return field.ErrorList{field.Invalid(path, q.String(), "must be greater than or equal to 0")}
+	}
+	unitScale := resource.Scale(0)
+	if name == corev1.ResourceCPU {
+		unitScale = resource.Milli
+	}
+	maxQuota := resource.NewScaledQuantity(MaxQuota, unitScale)
+	if q.Cmp(*maxQuota) > 0 {
+		return field.ErrorList{field.Invalid(path, q.String(), fmt.Sprintf("must be less than or equal to %s", maxQuota))}
+	}
+	if rounded := resource.NewScaledQuantity(q.ScaledValue(unitScale), unitScale); q.Cmp(*rounded) != 0 {
+		if name == corev1.ResourceCPU {
+			return field.ErrorList{field.Invalid(path, q.String(), "must be a whole number of millicores")}
+		}
+		return field.ErrorList{field.Invalid(path, q.String(), "must be a whole number")}
+	}
+	return nil
+}
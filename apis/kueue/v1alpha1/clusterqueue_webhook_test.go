@@ -0,0 +1,449 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestClusterQueueDefault(t *testing.T) {
+	cq := ClusterQueue{
+		Spec: ClusterQueueSpec{
+			ResourceGroups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{
+						{
+							Name:           corev1.ResourceCPU,
+							NominalQuota:   resource.MustParse("1.0005"),
+							BorrowingLimit: quantityPtr(resource.MustParse("2e3")),
+							LendingLimit:   quantityPtr(resource.MustParse("0.5001")),
+						},
+						{
+							Name:         corev1.ResourceMemory,
+							NominalQuota: resource.MustParse("1Gi"),
+						},
+					},
+				}},
+			}},
+		},
+	}
+	cq.Default()
+	wantQuotas := []ResourceQuota{
+		{
+			Name:           corev1.ResourceCPU,
+			NominalQuota:   resource.MustParse("1001m"),
+			BorrowingLimit: quantityPtr(resource.MustParse("2000")),
+			LendingLimit:   quantityPtr(resource.MustParse("501m")),
+		},
+		{
+			Name:         corev1.ResourceMemory,
+			NominalQuota: resource.MustParse("1Gi"),
+		},
+	}
+	gotQuotas := cq.Spec.ResourceGroups[0].Flavors[0].Resources
+	if diff := cmp.Diff(wantQuotas, gotQuotas, cmp.Comparer(func(a, b resource.Quantity) bool {
+		return a.Cmp(b) == 0
+	})); diff != "" {
+		t.Errorf("Unexpected quotas after defaulting (-want,+got):\n%s", diff)
+	}
+}
+
+func TestValidateClusterQueue(t *testing.T) {
+	groupsPath := field.NewPath("spec", "resourceGroups")
+	quotaPath := groupsPath.Index(0).Child("flavors").Index(0).Child("resources").Index(0)
+	nsQuotasPath := field.NewPath("spec", "namespaceQuotas")
+	schedulesPath := field.NewPath("spec", "quotaSchedules")
+	cpuGroups := []ResourceGroup{{
+		CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+		Flavors: []FlavorQuotas{{
+			Name: "default",
+			Resources: []ResourceQuota{{
+				Name:         corev1.ResourceCPU,
+				NominalQuota: resource.MustParse("1"),
+			}},
+		}},
+	}}
+	cases := map[string]struct {
+		groups          []ResourceGroup
+		namespaceQuotas []NamespaceQuota
+		quotaSchedules  []QuotaSchedule
+		wantErrs        field.ErrorList
+	}{
+		"valid": {
+			groups: []ResourceGroup{
+				{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+					Flavors: []FlavorQuotas{
+						{
+							Name: "on-demand",
+							Resources: []ResourceQuota{
+								{
+									Name:                 corev1.ResourceCPU,
+									NominalQuota:         resource.MustParse("100m"),
+									BorrowingLimit:       quantityPtr(resource.MustParse("1")),
+									LendingLimit:         quantityPtr(resource.MustParse("50m")),
+									OvercommitPercentage: 150,
+								},
+								{
+									Name:         corev1.ResourceMemory,
+									NominalQuota: resource.MustParse("1Gi"),
+								},
+							},
+						},
+						{
+							Name: "spot",
+							Resources: []ResourceQuota{
+								{
+									Name:         corev1.ResourceCPU,
+									NominalQuota: resource.MustParse("1"),
+								},
+								{
+									Name:         corev1.ResourceMemory,
+									NominalQuota: resource.MustParse("2Gi"),
+								},
+							},
+						},
+					},
+				},
+				{
+					CoveredResources: []corev1.ResourceName{"example.com/gpu"},
+					Flavors: []FlavorQuotas{{
+						Name: "model-a",
+						Resources: []ResourceQuota{{
+							Name:         "example.com/gpu",
+							NominalQuota: resource.MustParse("2"),
+						}},
+					}},
+				},
+			},
+		},
+		"zero quotas": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceMemory},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:           corev1.ResourceMemory,
+						BorrowingLimit: quantityPtr(resource.MustParse("0")),
+						LendingLimit:   quantityPtr(resource.MustParse("0")),
+					}},
+				}},
+			}},
+		},
+		"negative nominal quota": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceMemory},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:         corev1.ResourceMemory,
+						NominalQuota: resource.MustParse("-1Gi"),
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("nominalQuota"), nil, ""),
+			},
+		},
+		"negative borrowing limit": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceMemory},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:           corev1.ResourceMemory,
+						NominalQuota:   resource.MustParse("1Gi"),
+						BorrowingLimit: quantityPtr(resource.MustParse("-1Gi")),
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("borrowingLimit"), nil, ""),
+			},
+		},
+		"lending limit above nominal quota": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceMemory},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:         corev1.ResourceMemory,
+						NominalQuota: resource.MustParse("1Gi"),
+						LendingLimit: quantityPtr(resource.MustParse("2Gi")),
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("lendingLimit"), nil, ""),
+			},
+		},
+		"fractional gpus": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{"example.com/gpu"},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:         "example.com/gpu",
+						NominalQuota: resource.MustParse("500m"),
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("nominalQuota"), nil, ""),
+			},
+		},
+		"sub-millicore cpu": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:         corev1.ResourceCPU,
+						NominalQuota: resource.MustParse("1.0005"),
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("nominalQuota"), nil, ""),
+			},
+		},
+		"too big": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:         corev1.ResourceCPU,
+						NominalQuota: resource.MustParse("1e17"),
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("nominalQuota"), nil, ""),
+			},
+		},
+		"valid namespace quotas": {
+			namespaceQuotas: []NamespaceQuota{
+				{Name: corev1.ResourceCPU, Limit: resource.MustParse("500m")},
+				{Name: "example.com/gpu", Limit: resource.MustParse("4")},
+			},
+		},
+		"invalid namespace quotas": {
+			namespaceQuotas: []NamespaceQuota{
+				{Name: corev1.ResourceCPU, Limit: resource.MustParse("-1")},
+				{Name: "example.com/gpu", Limit: resource.MustParse("1.5")},
+			},
+			wantErrs: field.ErrorList{
+				field.Invalid(nsQuotasPath.Index(0).Child("limit"), nil, ""),
+				field.Invalid(nsQuotasPath.Index(1).Child("limit"), nil, ""),
+			},
+		},
+		"valid quota schedules": {
+			groups: cpuGroups,
+			quotaSchedules: []QuotaSchedule{{
+				Name:            "night",
+				Schedule:        "0 22 * * 1-5",
+				DurationSeconds: 8 * 3600,
+				Quotas: []ScheduledQuota{
+					{Flavor: "default", Resource: corev1.ResourceCPU, NominalQuota: resource.MustParse("4")},
+				},
+			}},
+		},
+		"invalid quota schedules": {
+			groups: cpuGroups,
+			quotaSchedules: []QuotaSchedule{{
+				Name:            "night",
+				Schedule:        "0 22 * *",
+				DurationSeconds: 8 * 3600,
+				Quotas: []ScheduledQuota{
+					{Flavor: "default", Resource: corev1.ResourceMemory, NominalQuota: resource.MustParse("1Gi")},
+					{Flavor: "default", Resource: corev1.ResourceCPU, NominalQuota: resource.MustParse("-1")},
+				},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(schedulesPath.Index(0).Child("schedule"), nil, ""),
+				field.Invalid(schedulesPath.Index(0).Child("quotas").Index(0), nil, ""),
+				field.Invalid(schedulesPath.Index(0).Child("quotas").Index(1).Child("nominalQuota"), nil, ""),
+			},
+		},
+		"quota schedule below the lendingLimit": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:         corev1.ResourceCPU,
+						NominalQuota: resource.MustParse("4"),
+						LendingLimit: quantityPtr(resource.MustParse("2")),
+					}},
+				}},
+			}},
+			quotaSchedules: []QuotaSchedule{{
+				Name:            "night",
+				Schedule:        "0 22 * * 1-5",
+				DurationSeconds: 8 * 3600,
+				Quotas: []ScheduledQuota{
+					{Flavor: "default", Resource: corev1.ResourceCPU, NominalQuota: resource.MustParse("1")},
+				},
+			}, {
+				Name:            "weekend",
+				Schedule:        "0 0 * * 6",
+				DurationSeconds: 48 * 3600,
+				Quotas: []ScheduledQuota{
+					{Flavor: "default", Resource: corev1.ResourceCPU, NominalQuota: resource.MustParse("2")},
+				},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(schedulesPath.Index(0).Child("quotas").Index(0).Child("nominalQuota"), nil, ""),
+			},
+		},
+		"overcommit too big": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:                 corev1.ResourceCPU,
+						NominalQuota:         resource.MustParse("1"),
+						OvercommitPercentage: 2000,
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("overcommitPercentage"), nil, ""),
+			},
+		},
+		"flavor missing a covered resource": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{{
+						Name:         corev1.ResourceCPU,
+						NominalQuota: resource.MustParse("1"),
+					}},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(groupsPath.Index(0).Child("flavors").Index(0).Child("resources"), nil, ""),
+			},
+		},
+		"flavor resources in a different order": {
+			groups: []ResourceGroup{{
+				CoveredResources: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+				Flavors: []FlavorQuotas{{
+					Name: "default",
+					Resources: []ResourceQuota{
+						{
+							Name:         corev1.ResourceMemory,
+							NominalQuota: resource.MustParse("1Gi"),
+						},
+						{
+							Name:         corev1.ResourceCPU,
+							NominalQuota: resource.MustParse("1"),
+						},
+					},
+				}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Invalid(quotaPath.Child("name"), nil, ""),
+				field.Invalid(groupsPath.Index(0).Child("flavors").Index(0).Child("resources").Index(1).Child("name"), nil, ""),
+			},
+		},
+		"resource in two groups": {
+			groups: []ResourceGroup{
+				{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+					Flavors: []FlavorQuotas{{
+						Name: "on-demand",
+						Resources: []ResourceQuota{{
+							Name:         corev1.ResourceCPU,
+							NominalQuota: resource.MustParse("1"),
+						}},
+					}},
+				},
+				{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+					Flavors: []FlavorQuotas{{
+						Name: "spot",
+						Resources: []ResourceQuota{{
+							Name:         corev1.ResourceCPU,
+							NominalQuota: resource.MustParse("1"),
+						}},
+					}},
+				},
+			},
+			wantErrs: field.ErrorList{
+				field.Duplicate(groupsPath.Index(1).Child("coveredResources").Index(0), nil),
+			},
+		},
+		"flavor in two groups": {
+			groups: []ResourceGroup{
+				{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+					Flavors: []FlavorQuotas{{
+						Name: "default",
+						Resources: []ResourceQuota{{
+							Name:         corev1.ResourceCPU,
+							NominalQuota: resource.MustParse("1"),
+						}},
+					}},
+				},
+				{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceMemory},
+					Flavors: []FlavorQuotas{{
+						Name: "default",
+						Resources: []ResourceQuota{{
+							Name:         corev1.ResourceMemory,
+							NominalQuota: resource.MustParse("1Gi"),
+						}},
+					}},
+				},
+			},
+			wantErrs: field.ErrorList{
+				field.Duplicate(groupsPath.Index(1).Child("flavors").Index(0).Child("name"), nil),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cq := &ClusterQueue{
+				Spec: ClusterQueueSpec{
+					ResourceGroups:  tc.groups,
+					NamespaceQuotas: tc.namespaceQuotas,
+					QuotaSchedules:  tc.quotaSchedules,
+				},
+			}
+			gotErrs := ValidateClusterQueue(cq)
+			if diff := cmp.Diff(tc.wantErrs, gotErrs, cmpopts.IgnoreFields(field.Error{}, "Detail", "BadValue")); diff != "" {
+				t.Errorf("Unexpected errors (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func quantityPtr(q resource.Quantity) *resource.Quantity {
+	return &q
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CohortSpec defines the desired state of Cohort
+type CohortSpec struct {
+	// parent is the name of the Cohort that this Cohort belongs to. The
+	// ClusterQueues of all the Cohorts under the same root Cohort can borrow
+	// unused resources from each other, within the limits of every Cohort in
+	// between. Empty for a root Cohort.
+	//
+	// A Cohort doesn't need to exist as an object for ClusterQueues to
+	// reference it; in that case, it's a root Cohort without limits.
+	Parent string `json:"parent,omitempty"`
+
+	// resources are limits on the total usage of the ClusterQueues in this
+	// Cohort and in all its descendants. Resources and flavors that are not
+	// listed are not limited by this Cohort. Example:
+	//
+	// - name: cpu
+	//   flavors:
+	//   - name: on-demand
+	//     limit: 100
+	//
+	// The limits apply to both the usage within the nominal quotas of the
+	// ClusterQueues and the borrowed usage, except for the usage within the
+	// quota that the ClusterQueues don't lend, as set by their lendingLimit.
+	//
+	// +listType=map
+	// +listMapKey=name
+	Resources []CohortResource `json:"resources,omitempty"`
+}
+
+type CohortResource struct {
+	// name of the resource. For example, cpu, memory or nvidia.com/gpu.
+	Name corev1.ResourceName `json:"name"`
+
+	// flavors are the limits of the resource, by flavor.
+	// +listType=map
+	// +listMapKey=name
+	Flavors []CohortFlavor `json:"flavors,omitempty"`
+}
+
+type CohortFlavor struct {
+	// name is a reference to the resourceFlavor.
+	Name ResourceFlavorReference `json:"name"`
+
+	// limit is the maximum amount of the resource flavor that the
+	// ClusterQueues in the Cohort and its descendants can use together.
+	Limit resource.Quantity `json:"limit"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Parent",JSONPath=".spec.parent",type=string,description="Parent Cohort"
+
+// Cohort is the Schema for the cohorts API. A Cohort groups ClusterQueues
+// that borrow resources from each other and can be nested in other Cohorts.
+type Cohort struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CohortSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CohortList contains a list of Cohort
+type CohortList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Cohort `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Cohort{}, &CohortList{})
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var cohortLog = ctrl.Log.WithName("cohort-webhook")
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks for
+// Cohorts.
+func (r *Cohort) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-kueue-x-k8s-io-v1alpha1-cohort,mutating=true,failurePolicy=fail,sideEffects=None,groups=kueue.x-k8s.io,resources=cohorts,verbs=create;update,versions=v1alpha1,name=mcohort.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Cohort{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type.
+// It normalizes the cpu limits to millicores, like the ClusterQueue quotas.
+func (r *Cohort) Default() {
+	cohortLog.V(5).Info("defaulter", "cohort", klog.KObj(r))
+
+	for i := range r.Spec.Resources {
+		res := &r.Spec.Resources[i]
+		if res.Name != corev1.ResourceCPU {
+			continue
+		}
+		for j := range res.Flavors {
+			res.Flavors[j].Limit = normalizeCPUQuota(res.Flavors[j].Limit)
+		}
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-kueue-x-k8s-io-v1alpha1-cohort,mutating=false,failurePolicy=fail,sideEffects=None,groups=kueue.x-k8s.io,resources=cohorts,verbs=create;update,versions=v1alpha1,name=vcohort.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Cohort{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *Cohort) ValidateCreate() error {
+	cohortLog.V(5).Info("validate create", "cohort", klog.KObj(r))
+	return ValidateCohort(r).ToAggregate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *Cohort) ValidateUpdate(old runtime.Object) error {
+	cohortLog.V(5).Info("validate update", "cohort", klog.KObj(r))
+	return ValidateCohort(r).ToAggregate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *Cohort) ValidateDelete() error {
+	return nil
+}
+
+// ValidateCohort validates the parent and the limits of a Cohort. Cycles
+// across several Cohorts can't be detected here; the controllers treat a
+// Cohort that closes a cycle as a root.
+func ValidateCohort(c *Cohort) field.ErrorList {
+	var allErrs field.ErrorList
+	if c.Spec.Parent == c.Name {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "parent"), c.Spec.Parent, "must not be the Cohort itself"))
+	}
+	resourcesPath := field.NewPath("spec", "resources")
+	for i := range c.Spec.Resources {
+		res := &c.Spec.Resources[i]
+		for j := range res.Flavors {
+			limitPath := resourcesPath.Index(i).Child("flavors").Index(j).Child("limit")
+			allErrs = append(allErrs, validateQuotaQuantity(res.Name, res.Flavors[j].Limit, limitPath)...)
+		}
+	}
+	return allErrs
+}
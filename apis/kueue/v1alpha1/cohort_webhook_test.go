@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateCohort(t *testing.T) {
+	limitPath := field.NewPath("spec", "resources").Index(0).Child("flavors").Index(0).Child("limit")
+	cases := map[string]struct {
+		spec     CohortSpec
+		wantErrs field.ErrorList
+	}{
+		"valid": {
+			spec: CohortSpec{
+				Parent: "root",
+				Resources: []CohortResource{{
+					Name:    corev1.ResourceCPU,
+					Flavors: []CohortFlavor{{Name: "default", Limit: resource.MustParse("100m")}},
+				}},
+			},
+		},
+		"parent is itself": {
+			spec: CohortSpec{Parent: "cohort"},
+			wantErrs: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "parent"), nil, ""),
+			},
+		},
+		"negative limit": {
+			spec: CohortSpec{
+				Resources: []CohortResource{{
+					Name:    corev1.ResourceMemory,
+					Flavors: []CohortFlavor{{Name: "default", Limit: resource.MustParse("-1Gi")}},
+				}},
+			},
+			wantErrs: field.ErrorList{
+				field.Invalid(limitPath, nil, ""),
+			},
+		},
+		"fractional gpus": {
+			spec: CohortSpec{
+				Resources: []CohortResource{{
+					Name:    "example.com/gpu",
+					Flavors: []CohortFlavor{{Name: "default", Limit: resource.MustParse("500m")}},
+				}},
+			},
+			wantErrs: field.ErrorList{
+				field.Invalid(limitPath, nil, ""),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &Cohort{
+				ObjectMeta: metav1.ObjectMeta{Name: "cohort"},
+				Spec:       tc.spec,
+			}
+			gotErrs := ValidateCohort(c)
+			if diff := cmp.Diff(tc.wantErrs, gotErrs, cmpopts.IgnoreFields(field.Error{}, "Detail", "BadValue")); diff != "" {
+				t.Errorf("Unexpected errors (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fuzzerFuncs restricts the generated values to the ones that the API server
+// would accept, so that failures point to kueue code and not to impossible
+// inputs, like quantities with a random format.
+var fuzzerFuncs = []interface{}{
+	func(q *resource.Quantity, c fuzz.Continue) {
+		*q = *resource.NewMilliQuantity(c.Int63n(1_000_000_000), resource.DecimalSI)
+	},
+	func(t *metav1.Time, c fuzz.Continue) {
+		*t = metav1.Unix(c.Int63n(1<<32), 0)
+	},
+	func(m *metav1.ObjectMeta, c fuzz.Continue) {
+		c.Fuzz(&m.Name)
+		c.Fuzz(&m.Namespace)
+		c.Fuzz(&m.Labels)
+		c.Fuzz(&m.Annotations)
+		c.Fuzz(&m.CreationTimestamp)
+	},
+	func(s *corev1.PodSpec, c fuzz.Continue) {
+		s.Containers = make([]corev1.Container, c.Intn(3))
+		for i := range s.Containers {
+			c.Fuzz(&s.Containers[i].Name)
+			c.Fuzz(&s.Containers[i].Resources)
+		}
+		s.InitContainers = make([]corev1.Container, c.Intn(2))
+		for i := range s.InitContainers {
+			c.Fuzz(&s.InitContainers[i].Name)
+			c.Fuzz(&s.InitContainers[i].Resources)
+		}
+		c.Fuzz(&s.Overhead)
+		c.Fuzz(&s.NodeSelector)
+		c.Fuzz(&s.Tolerations)
+		c.Fuzz(&s.PriorityClassName)
+	},
+}
+
+func newFuzzer(data []byte) *fuzz.Fuzzer {
+	return fuzz.NewFromGoFuzz(data).NilChance(0.2).NumElements(0, 4).Funcs(fuzzerFuncs...)
+}
+
+func fuzzSeeds(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("kueue"))
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	f.Add([]byte{255, 254, 253, 252, 251, 250, 249, 248, 247, 246, 245, 244})
+}
+
+// roundTrip serializes obj to JSON and decodes it into out.
+func roundTrip(t *testing.T, obj, out interface{}) {
+	t.Helper()
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Marshaling %T: %v", obj, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatalf("Unmarshaling %T: %v", obj, err)
+	}
+}
+
+func FuzzWorkloadDefaultAndValidate(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzer := newFuzzer(data)
+		var wl, oldWl Workload
+		fuzzer.Fuzz(&wl)
+		fuzzer.Fuzz(&oldWl)
+
+		wl.Default()
+		defaulted := wl.DeepCopy()
+		wl.Default()
+		if !equality.Semantic.DeepEqual(defaulted, &wl) {
+			t.Errorf("Defaulting is not idempotent for %+v", defaulted)
+		}
+
+		// Validation can reject the object, but it must not panic.
+		_ = ValidateWorkloadUpdate(&wl, &oldWl)
+
+		var got Workload
+		roundTrip(t, &wl, &got)
+		if !equality.Semantic.DeepEqual(&wl, &got) {
+			t.Errorf("Workload changed after a round trip:\nwant: %+v\ngot: %+v", &wl, &got)
+		}
+		got.Default()
+		if !equality.Semantic.DeepEqual(&wl, &got) {
+			t.Errorf("Defaulting a decoded Workload changed it:\nwant: %+v\ngot: %+v", &wl, &got)
+		}
+	})
+}
+
+func FuzzClusterQueueRoundTrip(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var cq ClusterQueue
+		newFuzzer(data).Fuzz(&cq)
+
+		var got ClusterQueue
+		roundTrip(t, &cq, &got)
+		if !equality.Semantic.DeepEqual(&cq, &got) {
+			t.Errorf("ClusterQueue changed after a round trip:\nwant: %+v\ngot: %+v", &cq, &got)
+		}
+	})
+}
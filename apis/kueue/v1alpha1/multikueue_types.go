@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MultiKueueClusterActive is the condition of a MultiKueueCluster that
+// indicates whether kueue can reach the cluster.
+const MultiKueueClusterActive = "Active"
+
+// MultiKueueClusterSpec defines the desired state of MultiKueueCluster
+type MultiKueueClusterSpec struct {
+	// kubeConfig is the reference to the kubeconfig with the credentials
+	// to access the cluster.
+	KubeConfig KubeConfigReference `json:"kubeConfig"`
+}
+
+// KubeConfigReference references a kubeconfig stored in a Secret in the
+// namespace in which kueue is deployed.
+type KubeConfigReference struct {
+	// secretName is the name of the Secret.
+	SecretName string `json:"secretName"`
+
+	// key is the key of the kubeconfig in the data of the Secret. Defaults
+	// to kubeconfig.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// MultiKueueClusterStatus defines the observed state of MultiKueueCluster
+type MultiKueueClusterStatus struct {
+	// conditions hold the latest available observations of the
+	// MultiKueueCluster current state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Active",JSONPath=".status.conditions[?(@.type==\"Active\")].status",type=string,description="Whether the cluster can be reached"
+
+// MultiKueueCluster is the Schema for the multikueueclusters API. A
+// MultiKueueCluster is a cluster, running kueue, to which the workloads can
+// be dispatched.
+type MultiKueueCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MultiKueueClusterSpec   `json:"spec,omitempty"`
+	Status MultiKueueClusterStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MultiKueueClusterList contains a list of MultiKueueCluster
+type MultiKueueClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MultiKueueCluster `json:"items"`
+}
+
+// MultiKueueConfigSpec defines the desired state of MultiKueueConfig
+type MultiKueueConfigSpec struct {
+	// clusters are the names of the MultiKueueClusters to which the
+	// workloads are dispatched.
+	// +kubebuilder:validation:MinItems=1
+	Clusters []string `json:"clusters"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// MultiKueueConfig is the Schema for the multikueueconfigs API. It's the
+// parameters of the AdmissionChecks with the kueue.x-k8s.io/multikueue
+// controllerName.
+type MultiKueueConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MultiKueueConfigSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MultiKueueConfigList contains a list of MultiKueueConfig
+type MultiKueueConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MultiKueueConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MultiKueueCluster{}, &MultiKueueClusterList{}, &MultiKueueConfig{}, &MultiKueueConfigList{})
+}
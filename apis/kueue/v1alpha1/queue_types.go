@@ -24,6 +24,35 @@ import (
 type QueueSpec struct {
 	// clusterQueue is a reference to a clusterQueue that backs this queue.
 	ClusterQueue ClusterQueueReference `json:"clusterQueue,omitempty"`
+
+	// weight is the share of admissions that this queue gets, relative to
+	// the other queues of the ClusterQueue, when the ClusterQueue uses the
+	// QueueWeight fairQueueing policy.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	Weight int32 `json:"weight,omitempty"`
+
+	// maxAdmittedWorkloads is the maximum number of workloads from this
+	// queue that can be admitted at the same time, even if the clusterQueue
+	// has quota for more. It's useful when the workloads share downstream
+	// dependencies, such as databases or shared storage, that can't handle
+	// an unlimited number of parallel jobs.
+	// If null, the number of admitted workloads is only limited by quota.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxAdmittedWorkloads *int32 `json:"maxAdmittedWorkloads,omitempty"`
+
+	// reactivationCooldownSeconds is the time after which the workloads of
+	// this queue that were deactivated with the RequeuingLimitExceeded reason
+	// are activated again. The reactivation starts the requeuing backoff
+	// over, so the workload is requeued as many times as the limit before
+	// it's deactivated for another cooldown. The workloads deactivated
+	// manually aren't reactivated.
+	// If null, the workloads stay deactivated until they are activated
+	// manually.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	ReactivationCooldownSeconds *int32 `json:"reactivationCooldownSeconds,omitempty"`
 }
 
 // ClusterQueueReference is the name of the ClusterQueue.
@@ -35,12 +64,43 @@ type QueueStatus struct {
 	// queue not yet admitted to a ClusterQueue.
 	// +optional
 	PendingWorkloads int32 `json:"pendingWorkloads"`
+
+	// reservingWorkloads is the number of workloads from this queue currently
+	// reserving quota in its ClusterQueue, including the admitted ones, and
+	// haven't finished yet.
+	// +optional
+	ReservingWorkloads int32 `json:"reservingWorkloads"`
+
+	// admittedWorkloads is the number of workloads from this queue currently
+	// admitted to its ClusterQueue, whose quota is reserved and whose
+	// AdmissionChecks are Ready, and haven't finished yet.
+	// +optional
+	AdmittedWorkloads int32 `json:"admittedWorkloads"`
+
+	// usedResources are the resources (by flavor) reserved by the workloads
+	// from this queue. Borrowing is only tracked for the whole
+	// ClusterQueue, so only the total is reported.
+	// +optional
+	UsedResources UsedResources `json:"usedResources,omitempty"`
+
+	// flavors are the names of the existing ResourceFlavors that the
+	// workloads of this queue can be assigned through its ClusterQueue.
+	// +listType=set
+	// +optional
+	Flavors []string `json:"flavors,omitempty"`
+
+	// pendingWorkloadsStatus lists the first pending workloads of this
+	// queue, with their positions in the ClusterQueue. It's only reported
+	// when enabled in the configuration of kueue.
+	// +optional
+	PendingWorkloadsStatus *PendingWorkloadsStatus `json:"pendingWorkloadsStatus,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="ClusterQueue",JSONPath=".spec.clusterQueue",type=string,description="Backing ClusterQueue"
 //+kubebuilder:printcolumn:name="Pending Workloads",JSONPath=".status.pendingWorkloads",type=integer,description="Number of pending workloads"
+//+kubebuilder:printcolumn:name="Admitted Workloads",JSONPath=".status.admittedWorkloads",type=integer,description="Number of admitted workloads that haven't finished yet",priority=1
 
 // Queue is the Schema for the queues API
 type Queue struct {
@@ -28,11 +28,26 @@ type WorkloadSpec struct {
 	//
 	// +listType=map
 	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
 	PodSets []PodSet `json:"podSets,omitempty"`
 
+	// sameFlavorResources is the list of resources for which all the podSets
+	// must be assigned the same flavor. For example, pods that need to
+	// communicate efficiently can be required to get the same GPU model.
+	//
+	// +listType=set
+	SameFlavorResources []corev1.ResourceName `json:"sameFlavorResources,omitempty"`
+
 	// queueName is the name of the queue the Workload is associated with.
 	QueueName string `json:"queueName"`
 
+	// placementHints are additional required node selector terms for the pods
+	// of all the podSets. Like in a required node affinity, the terms are
+	// ORed. They are combined with the node affinity of each podSet, and only
+	// the flavors whose labels match them are assigned to the workload.
+	// +optional
+	PlacementHints []corev1.NodeSelectorTerm `json:"placementHints,omitempty"`
+
 	// admission holds the parameters of the admission of the workload by a ClusterQueue.
 	Admission *Admission `json:"admission,omitempty"`
 
@@ -40,17 +55,57 @@ type WorkloadSpec struct {
 	// "system-node-critical" and "system-cluster-critical" are two special
 	// keywords which indicate the highest priorities with the former being
 	// the highest priority. Any other name must be defined by creating a
-	// PriorityClass object with that name. If not specified, the workload
+	// PriorityClass or a WorkloadPriorityClass object with that name,
+	// depending on priorityClassSource. If not specified, the workload
 	// priority will be default or zero if there is no default.
 	PriorityClassName string `json:"priorityClassName,omitempty"`
 
+	// priorityClassSource indicates the kind of the object that
+	// priorityClassName refers to:
+	//
+	// - kueue.x-k8s.io/workloadpriorityclass: a WorkloadPriorityClass.
+	// - scheduling.k8s.io/priorityclass: a pod PriorityClass.
+	//
+	// +kubebuilder:default=""
+	// +kubebuilder:validation:Enum=kueue.x-k8s.io/workloadpriorityclass;scheduling.k8s.io/priorityclass;""
+	// +optional
+	PriorityClassSource string `json:"priorityClassSource,omitempty"`
+
 	// Priority determines the order of access to the resources managed by the
 	// ClusterQueue where the workload is queued.
 	// The priority value is populated from PriorityClassName.
 	// The higher the value, the higher the priority.
 	Priority *int32 `json:"priority,omitempty"`
+
+	// active determines whether the workload can be admitted. Setting it to
+	// false evicts the workload if it's admitted, which stops its job, and
+	// keeps it out of the queues until it's set to true again.
+	// Defaults to true.
+	// +kubebuilder:default=true
+	// +optional
+	Active *bool `json:"active,omitempty"`
+
+	// maximumExecutionTimeSeconds is the maximum time that the workload can
+	// stay admitted. Once exceeded, the workload is evicted with the
+	// DeadlineExceeded reason and deactivated, so that it's not requeued.
+	// The time accumulates across admissions, and starts over when the
+	// workload is activated again.
+	// If null, the workload can stay admitted until it finishes.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaximumExecutionTimeSeconds *int32 `json:"maximumExecutionTimeSeconds,omitempty"`
 }
 
+const (
+	// WorkloadPriorityClassSource is the priorityClassSource of the workloads
+	// whose priority comes from a WorkloadPriorityClass.
+	WorkloadPriorityClassSource = "kueue.x-k8s.io/workloadpriorityclass"
+
+	// PodPriorityClassSource is the priorityClassSource of the workloads
+	// whose priority comes from a pod PriorityClass.
+	PodPriorityClassSource = "scheduling.k8s.io/priorityclass"
+)
+
 type Admission struct {
 	// clusterQueue is the name of the ClusterQueue that admitted this workload.
 	ClusterQueue ClusterQueueReference `json:"clusterQueue"`
@@ -59,6 +114,13 @@ type Admission struct {
 	// +listType=map
 	// +listMapKey=name
 	PodSetFlavors []PodSetFlavors `json:"podSetFlavors"`
+
+	// admissionChecks are the names of the AdmissionChecks of the ClusterQueue
+	// at the time the quota was reserved. The workload is only admitted once
+	// all of them are Ready in .status.admissionChecks.
+	// +listType=set
+	// +optional
+	AdmissionChecks []string `json:"admissionChecks,omitempty"`
 }
 
 type PodSetFlavors struct {
@@ -68,6 +130,24 @@ type PodSetFlavors struct {
 
 	// Flavors are the flavors assigned to the workload for each resource.
 	Flavors map[corev1.ResourceName]string `json:"flavors,omitempty"`
+
+	// splits hold the flavors assigned to subsets of the pods, when the
+	// podSet was split across flavors. flavors is empty in that case.
+	// +optional
+	Splits []PodSetSplit `json:"splits,omitempty"`
+
+	// count is the number of pods admitted for the podSet, when it is lower
+	// than .spec.podSets[*].count because the workload was partially admitted.
+	// +optional
+	Count *int32 `json:"count,omitempty"`
+}
+
+type PodSetSplit struct {
+	// count is the number of pods in the subset.
+	Count int32 `json:"count"`
+
+	// flavors are the flavors assigned to the subset for each resource.
+	Flavors map[corev1.ResourceName]string `json:"flavors,omitempty"`
 }
 
 type PodSet struct {
@@ -80,6 +160,25 @@ type PodSet struct {
 
 	// count is the number of pods for the spec.
 	Count int32 `json:"count"`
+
+	// minCount is the minimum number of pods for the spec. When set, the
+	// workload can be admitted with a count between minCount and count if
+	// the quota doesn't allow admitting all the pods. The admitted count is
+	// recorded in .spec.admission.podSetFlavors[*].count.
+	// +optional
+	MinCount *int32 `json:"minCount,omitempty"`
+
+	// splitAcrossFlavors allows kueue to split the pods of this podSet into
+	// subsets that are assigned different flavors, when all the pods don't
+	// fit in a single flavor. It is meant for workloads that don't require
+	// locality among their pods.
+	// Each subset is recorded in .spec.admission.podSetFlavors[*].splits.
+	// The controller of the workload must create the pods of each subset
+	// with the flavors of that subset, so it can't be used for podSets
+	// whose pods share a single template, like the pods of a Job. It's
+	// rejected for the workloads owned by a batch/v1 Job.
+	// +optional
+	SplitAcrossFlavors bool `json:"splitAcrossFlavors,omitempty"`
 }
 
 // WorkloadStatus defines the observed state of Workload
@@ -90,6 +189,111 @@ type WorkloadStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []WorkloadCondition `json:"conditions,omitempty"`
+
+	// requeueState holds the state of the requeueing of a workload that was
+	// evicted because its pods didn't become ready in time. It's kept while
+	// the workload is deactivated, and cleared when the workload is activated
+	// again.
+	// +optional
+	RequeueState *RequeueState `json:"requeueState,omitempty"`
+
+	// admissionBackoff holds the backoff before the next attempt to admit a
+	// workload that was repeatedly found inadmissible. It's cleared when the
+	// workload is admitted.
+	// +optional
+	AdmissionBackoff *AdmissionBackoff `json:"admissionBackoff,omitempty"`
+
+	// admissionChecks hold the state of the AdmissionChecks listed in
+	// .spec.admission.admissionChecks. They are initialized as Pending when
+	// the quota is reserved and updated by the controllers of the checks.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	AdmissionChecks []AdmissionCheckState `json:"admissionChecks,omitempty"`
+
+	// reclaimablePods is the number of pods of each podSet that finished and
+	// no longer need their quota. The quota is released while the rest of the
+	// pods keep running. It's maintained by the controller of the job.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ReclaimablePods []ReclaimablePod `json:"reclaimablePods,omitempty"`
+
+	// accumulatedPastExecutionTimeSeconds is the time that the workload was
+	// admitted in its previous admissions. It counts towards
+	// .spec.maximumExecutionTimeSeconds, and it's cleared when the workload
+	// is activated again after being deactivated.
+	// +optional
+	AccumulatedPastExecutionTimeSeconds *int32 `json:"accumulatedPastExecutionTimeSeconds,omitempty"`
+}
+
+type ReclaimablePod struct {
+	// name is the name of the podSet.
+	Name string `json:"name"`
+
+	// count is the number of pods of the podSet that no longer need quota.
+	// +kubebuilder:validation:Minimum=0
+	Count int32 `json:"count"`
+}
+
+type CheckState string
+
+const (
+	// CheckStatePending means that the check didn't complete yet.
+	CheckStatePending CheckState = "Pending"
+
+	// CheckStateReady means that the check passed.
+	CheckStateReady CheckState = "Ready"
+
+	// CheckStateRetry means that the check can't pass with the current
+	// reservation. The workload is evicted and requeued.
+	CheckStateRetry CheckState = "Retry"
+
+	// CheckStateRejected means that the check can't pass for the workload.
+	// The workload is evicted and deactivated.
+	CheckStateRejected CheckState = "Rejected"
+)
+
+type AdmissionCheckState struct {
+	// name is the name of the AdmissionCheck.
+	Name string `json:"name"`
+
+	// state of the check.
+	// +kubebuilder:validation:Enum=Pending;Ready;Retry;Rejected
+	State CheckState `json:"state"`
+
+	// lastTransitionTime is the last time the state changed.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+
+	// message is a human readable message indicating details about the
+	// state.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type RequeueState struct {
+	// count is the number of consecutive times that the workload was evicted
+	// because its pods didn't become ready in time.
+	// +optional
+	Count int32 `json:"count,omitempty"`
+
+	// requeueAt is the time after which the workload can be admitted again.
+	// +optional
+	RequeueAt *metav1.Time `json:"requeueAt,omitempty"`
+}
+
+type AdmissionBackoff struct {
+	// attempts is the number of consecutive attempts in which the workload
+	// was found inadmissible.
+	Attempts int32 `json:"attempts"`
+
+	// reason is why the workload was found inadmissible in the last attempt.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// nextAttemptTime is the time after which the workload is tried again,
+	// unless an event frees up quota earlier.
+	NextAttemptTime metav1.Time `json:"nextAttemptTime"`
 }
 
 type WorkloadCondition struct {
@@ -131,6 +335,30 @@ const (
 	// WorkloadFinished means that the workload associated to the
 	// ResourceClaim finished running (failed or succeeded).
 	WorkloadFinished WorkloadConditionType = "Finished"
+
+	// WorkloadEvicted means that the Workload is being evicted: its admission
+	// is cleared, which stops the associated job, and the Workload is requeued.
+	// Once the admission is cleared, the status becomes False and the reason
+	// and message are kept to record the cause of the eviction.
+	WorkloadEvicted WorkloadConditionType = "Evicted"
+
+	// WorkloadPodsReady means that all the pods of the admitted Workload are
+	// ready or succeeded. It's only maintained when waitForPodsReady is
+	// enabled in the configuration.
+	WorkloadPodsReady WorkloadConditionType = "PodsReady"
+
+	// WorkloadQuotaReserved means that the Workload has a quota reservation
+	// in a ClusterQueue, recorded in .spec.admission. When the ClusterQueue
+	// has AdmissionChecks, the Workload is only Admitted once all of them are
+	// Ready.
+	WorkloadQuotaReserved WorkloadConditionType = "QuotaReserved"
+
+	// WorkloadAdmissionChecksReady summarizes the states of the
+	// AdmissionChecks of the quota reservation. It's True when all of them
+	// are Ready. Otherwise, the reason is the most severe of the states,
+	// Rejected, Retry or Pending, and the message lists the checks that
+	// aren't Ready.
+	WorkloadAdmissionChecksReady WorkloadConditionType = "AdmissionChecksReady"
 )
 
 // +kubebuilder:object:root=true
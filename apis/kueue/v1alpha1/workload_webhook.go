@@ -17,30 +17,81 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/apimachinery/pkg/runtime"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 const (
 	DefaultPodSetName = "main"
+
+	// MaxPodSets is the maximum number of podSets of a Workload.
+	MaxPodSets = 8
+
+	// JobUIDLabel is the label in the workload that holds the UID of the
+	// object that controls it, usually its job.
+	JobUIDLabel = "kueue.x-k8s.io/job-uid"
+
+	// DefaultQueueAnnotation is the annotation in a Namespace that holds the
+	// name of the Queue for the jobs and workloads created in the namespace
+	// without a queue name.
+	DefaultQueueAnnotation = "kueue.x-k8s.io/default-queue"
+
+	// PriorityBoostAnnotation is the annotation in a Workload that, when set
+	// to "true", moves it to the front of its ClusterQueue, ahead of the
+	// workloads with higher priorities. Only the users allowed to update the
+	// workloads/priorityboost subresource can set it.
+	PriorityBoostAnnotation = "kueue.x-k8s.io/priority-boost"
 )
 
 // log is for logging in this package.
 var workloadlog = ctrl.Log.WithName("workload-webhook")
 
-func (r *Workload) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(r).
-		Complete()
+// SetupWebhookWithManager registers the defaulting and validating webhooks for
+// Workloads. If admitters is not empty, only those users can set or clear the
+// admission of a Workload.
+func (r *Workload) SetupWebhookWithManager(mgr ctrl.Manager, admitters ...string) error {
+	mgr.GetWebhookServer().Register(mutateWorkloadPath, &webhook.Admission{
+		Handler: &workloadDefaulter{client: mgr.GetClient()},
+	})
+	mgr.GetWebhookServer().Register(validateWorkloadPath, &webhook.Admission{
+		Handler: &workloadValidator{
+			admitters: sets.NewString(admitters...),
+			client:    mgr.GetClient(),
+			reviewer:  mgr.GetClient(),
+		},
+	})
+	return nil
 }
 
 // +kubebuilder:webhook:path=/mutate-kueue-x-k8s-io-v1alpha1-workload,mutating=true,failurePolicy=fail,sideEffects=None,groups=kueue.x-k8s.io,resources=workloads,verbs=create;update,versions=v1alpha1,name=mworkload.kb.io,admissionReviewVersions=v1
 
+const mutateWorkloadPath = "/mutate-kueue-x-k8s-io-v1alpha1-workload"
+
 var _ webhook.Defaulter = &Workload{}
 
-// Default implements webhook.Defaulter so a webhook will be registered for the type
+// Default implements webhook.Defaulter. It sets the defaults that don't
+// depend on other objects.
 func (r *Workload) Default() {
 	workloadlog.V(5).Info("defaulter", "workload", klog.KObj(r))
 
@@ -50,23 +101,432 @@ func (r *Workload) Default() {
 			podSet.Name = DefaultPodSetName
 		}
 	}
+	if r.Spec.Active == nil {
+		r.Spec.Active = pointer.Bool(true)
+	}
+}
+
+// workloadDefaulter defaults Workloads. Unlike webhook.Defaulter, it can
+// look up the priority classes and knows whether the Workload is being
+// created.
+type workloadDefaulter struct {
+	client  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &workloadDefaulter{}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (d *workloadDefaulter) InjectDecoder(dec *admission.Decoder) error {
+	d.decoder = dec
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (d *workloadDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	wl := &Workload{}
+	if err := d.decoder.Decode(req, wl); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	workloadlog.V(5).Info("defaulting", "workload", klog.KObj(wl), "operation", req.Operation)
+
+	if err := d.defaultWorkload(ctx, wl, req.Operation == admissionv1.Create); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	marshalled, err := json.Marshal(wl)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshalled)
+}
+
+// defaultWorkload sets the defaults of the Workload and labels it with the
+// UID of its controller. On creation, it also sets the default queue of the
+// namespace if the Workload has no queue name, and resolves the priority from
+// the priority class, unless it's already set. The priority isn't resolved
+// on updates because it can't change once the workload is admitted.
+func (d *workloadDefaulter) defaultWorkload(ctx context.Context, wl *Workload, create bool) error {
+	wl.Default()
+	if owner := metav1.GetControllerOf(wl); owner != nil {
+		if wl.Labels == nil {
+			wl.Labels = make(map[string]string, 1)
+		}
+		wl.Labels[JobUIDLabel] = string(owner.UID)
+	}
+	if !create || d.client == nil {
+		return nil
+	}
+	if wl.Spec.QueueName == "" {
+		name, err := DefaultQueueName(ctx, d.client, wl.Namespace)
+		if err != nil {
+			return err
+		}
+		wl.Spec.QueueName = name
+	}
+	if wl.Spec.Priority != nil {
+		return nil
+	}
+	return d.resolvePriority(ctx, wl)
+}
+
+// DefaultQueueName returns the name of the default Queue of the namespace,
+// from its DefaultQueueAnnotation, or empty if it has none. The webhooks read
+// the namespaces from the informer cache of the manager, which follows the
+// changes to the annotation.
+func DefaultQueueName(ctx context.Context, c client.Reader, namespace string) (string, error) {
+	var ns corev1.Namespace
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	return ns.Annotations[DefaultQueueAnnotation], nil
+}
+
+// resolvePriority populates the priority of the workload from its
+// WorkloadPriorityClass or pod PriorityClass, or from the global default pod
+// PriorityClass if it has no priority class name. A missing priority class
+// leaves the priority unset, so that the workload isn't rejected for a class
+// that might be created later; the validating webhook warns about it.
+func (d *workloadDefaulter) resolvePriority(ctx context.Context, wl *Workload) error {
+	if wl.Spec.PriorityClassSource == WorkloadPriorityClassSource {
+		var wpc WorkloadPriorityClass
+		if err := d.client.Get(ctx, types.NamespacedName{Name: wl.Spec.PriorityClassName}, &wpc); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		wl.Spec.Priority = pointer.Int32(wpc.Value)
+		return nil
+	}
+	if wl.Spec.PriorityClassName != "" {
+		var pc schedulingv1.PriorityClass
+		if err := d.client.Get(ctx, types.NamespacedName{Name: wl.Spec.PriorityClassName}, &pc); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		wl.Spec.Priority = pointer.Int32(pc.Value)
+		wl.Spec.PriorityClassSource = PodPriorityClassSource
+		return nil
+	}
+	var pcs schedulingv1.PriorityClassList
+	if err := d.client.List(ctx, &pcs); err != nil {
+		return err
+	}
+	// Like for pods, the global default with the lowest value wins if there
+	// is more than one.
+	var defaultPC *schedulingv1.PriorityClass
+	for i := range pcs.Items {
+		if pc := &pcs.Items[i]; pc.GlobalDefault && (defaultPC == nil || pc.Value < defaultPC.Value) {
+			defaultPC = pc
+		}
+	}
+	if defaultPC != nil {
+		wl.Spec.Priority = pointer.Int32(defaultPC.Value)
+		wl.Spec.PriorityClassName = defaultPC.Name
+		wl.Spec.PriorityClassSource = PodPriorityClassSource
+	}
+	return nil
 }
 
 // +kubebuilder:webhook:path=/validate-kueue-x-k8s-io-v1alpha1-workload,mutating=false,failurePolicy=fail,sideEffects=None,groups=kueue.x-k8s.io,resources=workloads,verbs=create;update,versions=v1alpha1,name=vworkload.kb.io,admissionReviewVersions=v1
 
-var _ webhook.Validator = &Workload{}
+const validateWorkloadPath = "/validate-kueue-x-k8s-io-v1alpha1-workload"
+
+// workloadValidator validates Workloads. Unlike webhook.Validator, it has
+// access to the user making the request and can return warnings.
+type workloadValidator struct {
+	admitters sets.String
+	client    client.Reader
+	// reviewer creates the SubjectAccessReviews that check whether the user
+	// can boost a Workload.
+	reviewer client.Writer
+	decoder  *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &workloadValidator{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
-func (r *Workload) ValidateCreate() error {
+// InjectDecoder implements admission.DecoderInjector.
+func (v *workloadValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
 	return nil
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *Workload) ValidateUpdate(old runtime.Object) error {
+// Handle implements admission.Handler.
+func (v *workloadValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	wl := &Workload{}
+	if err := v.decoder.Decode(req, wl); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	workloadlog.V(5).Info("validating", "workload", klog.KObj(wl), "operation", req.Operation, "user", req.UserInfo.Username)
+
+	var allErrs field.ErrorList
+	var warnings []string
+	switch req.Operation {
+	case admissionv1.Create:
+		allErrs = append(ValidateWorkload(wl), v.validateAdmitter(req.UserInfo.Username, nil, wl)...)
+		allErrs = append(allErrs, v.validatePriorityBoost(ctx, req.UserInfo, nil, wl)...)
+		if v.client != nil && wl.Spec.QueueName != "" {
+			warnings = MissingQueueWarnings(ctx, v.client, req.Namespace, wl.Spec.QueueName)
+		}
+		if v.client != nil && wl.Spec.PriorityClassSource == WorkloadPriorityClassSource {
+			warnings = append(warnings, MissingWorkloadPriorityClassWarnings(ctx, v.client, wl.Spec.PriorityClassName)...)
+		}
+	case admissionv1.Update:
+		oldWl := &Workload{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldWl); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		allErrs = append(validateAdmission(wl), ValidateWorkloadUpdate(wl, oldWl)...)
+		allErrs = append(allErrs, v.validateAdmitter(req.UserInfo.Username, oldWl, wl)...)
+		allErrs = append(allErrs, v.validatePriorityBoost(ctx, req.UserInfo, oldWl, wl)...)
+	}
+	if len(allErrs) > 0 {
+		return admission.Denied(allErrs.ToAggregate().Error())
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// MissingQueueWarnings returns admission warnings if the Queue doesn't exist
+// in the namespace or if the ClusterQueue it points to doesn't exist. The
+// queues might be created later, so these are not errors. Failures other than
+// not found are ignored.
+func MissingQueueWarnings(ctx context.Context, c client.Reader, namespace, name string) []string {
+	var q Queue
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &q); err != nil {
+		if apierrors.IsNotFound(err) {
+			return []string{fmt.Sprintf("Queue %s/%s doesn't exist", namespace, name)}
+		}
+		return nil
+	}
+	if q.Spec.ClusterQueue == "" {
+		return nil
+	}
+	var cq ClusterQueue
+	if err := c.Get(ctx, types.NamespacedName{Name: string(q.Spec.ClusterQueue)}, &cq); err != nil {
+		if apierrors.IsNotFound(err) {
+			return []string{fmt.Sprintf("ClusterQueue %s, referenced by Queue %s/%s, doesn't exist", q.Spec.ClusterQueue, namespace, name)}
+		}
+	}
 	return nil
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (r *Workload) ValidateDelete() error {
+// MissingWorkloadPriorityClassWarnings returns admission warnings if the
+// WorkloadPriorityClass doesn't exist. Failures other than not found are
+// ignored.
+func MissingWorkloadPriorityClassWarnings(ctx context.Context, c client.Reader, name string) []string {
+	var wpc WorkloadPriorityClass
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, &wpc); err != nil && apierrors.IsNotFound(err) {
+		return []string{fmt.Sprintf("WorkloadPriorityClass %s doesn't exist", name)}
+	}
+	return nil
+}
+
+// validateAdmitter checks that only the admitters set or clear the admission
+// of a workload, so that users can't fabricate admissions and corrupt the
+// usage accounting of the ClusterQueues. oldObj is nil for creations.
+func (v *workloadValidator) validateAdmitter(user string, oldObj, newObj *Workload) field.ErrorList {
+	if v.admitters.Len() == 0 || v.admitters.Has(user) {
+		return nil
+	}
+	var oldAdmission *Admission
+	if oldObj != nil {
+		oldAdmission = oldObj.Spec.Admission
+	}
+	if equality.Semantic.DeepEqual(oldAdmission, newObj.Spec.Admission) {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(field.NewPath("spec", "admission"), fmt.Sprintf("user %q is not allowed to change the admission", user))}
+}
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// validatePriorityBoost checks the value of the PriorityBoostAnnotation and
+// that the user, unless it's an admitter, can update the
+// workloads/priorityboost subresource when the annotation is set. Removing
+// it is allowed to everyone. oldObj is nil for creations.
+func (v *workloadValidator) validatePriorityBoost(ctx context.Context, user authenticationv1.UserInfo, oldObj, newObj *Workload) field.ErrorList {
+	path := field.NewPath("metadata", "annotations").Key(PriorityBoostAnnotation)
+	value, ok := newObj.Annotations[PriorityBoostAnnotation]
+	if !ok {
+		return nil
+	}
+	if value != "true" {
+		return field.ErrorList{field.NotSupported(path, value, []string{"true"})}
+	}
+	if oldObj != nil && oldObj.Annotations[PriorityBoostAnnotation] == value {
+		return nil
+	}
+	if v.admitters.Has(user.Username) {
+		return nil
+	}
+	if v.reviewer == nil {
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("user %q is not allowed to boost workloads", user.Username))}
+	}
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   newObj.Namespace,
+				Verb:        "update",
+				Group:       GroupVersion.Group,
+				Resource:    "workloads",
+				Subresource: "priorityboost",
+				Name:        newObj.Name,
+			},
+		},
+	}
+	if err := v.reviewer.Create(ctx, review); err != nil {
+		return field.ErrorList{field.InternalError(path, fmt.Errorf("checking the permissions of user %q: %w", user.Username, err))}
+	}
+	if !review.Status.Allowed {
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("user %q is not allowed to boost workloads", user.Username))}
+	}
 	return nil
 }
+
+// ValidateWorkload validates a new Workload: the number of podSets, their
+// counts and requests and, if it's admitted, that the admission assigns
+// flavors to each podSet and the counts of its partial admission.
+func ValidateWorkload(obj *Workload) field.ErrorList {
+	return append(validatePodSets(obj), validateAdmission(obj)...)
+}
+
+// validatePodSets validates the number of podSets, their counts and
+// requests, and that the podSets of a Job aren't split across flavors. The
+// Workloads created before these rules existed can still be updated, as long
+// as their podSets don't change.
+func validatePodSets(obj *Workload) field.ErrorList {
+	var allErrs field.ErrorList
+	podSets := obj.Spec.PodSets
+	podSetsPath := field.NewPath("spec", "podSets")
+	ownedByJob := false
+	if owner := metav1.GetControllerOf(obj); owner != nil {
+		ownedByJob = owner.APIVersion == batchv1.SchemeGroupVersion.String() && owner.Kind == "Job"
+	}
+	if len(podSets) > MaxPodSets {
+		allErrs = append(allErrs, field.TooMany(podSetsPath, len(podSets), MaxPodSets))
+	}
+	for i := range podSets {
+		ps := &podSets[i]
+		if ps.Count < 1 {
+			allErrs = append(allErrs, field.Invalid(podSetsPath.Index(i).Child("count"), ps.Count, "must be greater than 0"))
+		}
+		// The quota is accounted by the requests of the pods, so a podSet
+		// without requests can't be admitted in a meaningful way.
+		if !HasRequests(&ps.Spec) {
+			allErrs = append(allErrs, field.Required(podSetsPath.Index(i).Child("spec", "containers"), "must specify the resource requests or limits of at least one container"))
+		}
+		// The pods of a Job share one template, so they can't be given the
+		// flavors of their split, and the Job could never start.
+		if ps.SplitAcrossFlavors && ownedByJob {
+			allErrs = append(allErrs, field.Forbidden(podSetsPath.Index(i).Child("splitAcrossFlavors"), "is not supported for the workloads of batch/v1 Jobs"))
+		}
+	}
+	return allErrs
+}
+
+// validateAdmission validates the minCounts of the podSets and, if the
+// Workload is admitted, that the admission assigns flavors to each podSet
+// and the counts of its partial admission.
+func validateAdmission(obj *Workload) field.ErrorList {
+	var allErrs field.ErrorList
+	podSetsPath := field.NewPath("spec", "podSets")
+	minCounts := make(map[string]int32, len(obj.Spec.PodSets))
+	counts := make(map[string]int32, len(obj.Spec.PodSets))
+	for i := range obj.Spec.PodSets {
+		ps := &obj.Spec.PodSets[i]
+		counts[ps.Name] = ps.Count
+		minCounts[ps.Name] = 1
+		if ps.MinCount != nil {
+			if *ps.MinCount < 1 || *ps.MinCount > ps.Count {
+				allErrs = append(allErrs, field.Invalid(podSetsPath.Index(i).Child("minCount"), *ps.MinCount, "must be between 1 and count"))
+			} else {
+				minCounts[ps.Name] = *ps.MinCount
+			}
+		}
+	}
+	if obj.Spec.Admission == nil {
+		return allErrs
+	}
+	// The cache accounts for the requests of each podSet with the flavors
+	// assigned to it by name, so every podSet needs exactly one assignment.
+	flavorsPath := field.NewPath("spec", "admission", "podSetFlavors")
+	assigned := sets.NewString()
+	for i := range obj.Spec.Admission.PodSetFlavors {
+		psFlavors := &obj.Spec.Admission.PodSetFlavors[i]
+		namePath := flavorsPath.Index(i).Child("name")
+		count, found := counts[psFlavors.Name]
+		if !found {
+			allErrs = append(allErrs, field.NotFound(namePath, psFlavors.Name))
+			continue
+		}
+		if assigned.Has(psFlavors.Name) {
+			allErrs = append(allErrs, field.Duplicate(namePath, psFlavors.Name))
+			continue
+		}
+		assigned.Insert(psFlavors.Name)
+		if psFlavors.Count == nil {
+			continue
+		}
+		if *psFlavors.Count < minCounts[psFlavors.Name] || *psFlavors.Count > count {
+			allErrs = append(allErrs, field.Invalid(flavorsPath.Index(i).Child("count"), *psFlavors.Count, "must be between the minCount and the count of the podSet"))
+		}
+	}
+	for i := range obj.Spec.PodSets {
+		if name := obj.Spec.PodSets[i].Name; !assigned.Has(name) {
+			allErrs = append(allErrs, field.Required(flavorsPath, fmt.Sprintf("missing the flavors of podSet %q", name)))
+		}
+	}
+	return allErrs
+}
+
+// HasRequests returns whether any of the containers or init containers of the
+// pod spec, or its overhead, specifies resource requests or limits. Like for
+// pods, limits are used as requests if the requests aren't specified.
+func HasRequests(spec *corev1.PodSpec) bool {
+	for i := range spec.Containers {
+		if r := &spec.Containers[i].Resources; len(r.Requests) > 0 || len(r.Limits) > 0 {
+			return true
+		}
+	}
+	for i := range spec.InitContainers {
+		if r := &spec.InitContainers[i].Resources; len(r.Requests) > 0 || len(r.Limits) > 0 {
+			return true
+		}
+	}
+	return len(spec.Overhead) > 0
+}
+
+// ValidateWorkloadUpdate validates the transition of a Workload from oldObj to
+// newObj. The number, counts and requests of the podSets are only validated
+// if the podSets change.
+func ValidateWorkloadUpdate(newObj, oldObj *Workload) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	// The cache accounts for an admitted workload in its ClusterQueue, while
+	// the queue manager follows the queueName. Moving an admitted workload
+	// would make them disagree, so the admission has to be cleared first.
+	if oldObj.Spec.Admission != nil && newObj.Spec.Admission != nil && newObj.Spec.QueueName != oldObj.Spec.QueueName {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("queueName"), "cannot be changed while the workload is admitted"))
+	}
+	// The flavors of an admitted workload were chosen for its placement
+	// hints.
+	if oldObj.Spec.Admission != nil && newObj.Spec.Admission != nil && !equality.Semantic.DeepEqual(newObj.Spec.PlacementHints, oldObj.Spec.PlacementHints) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("placementHints"), "cannot be changed while the workload is admitted"))
+	}
+	// The cache accounts for the requests of the podSets of an admitted
+	// workload, and preemption relies on the priority it was admitted with.
+	if oldObj.Spec.Admission != nil && newObj.Spec.Admission != nil && !equality.Semantic.DeepEqual(newObj.Spec.PodSets, oldObj.Spec.PodSets) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("podSets"), "cannot be changed while the workload is admitted"))
+	}
+	if oldObj.Spec.Admission != nil && newObj.Spec.Admission != nil && !equality.Semantic.DeepEqual(newObj.Spec.Priority, oldObj.Spec.Priority) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("priority"), "cannot be changed while the workload is admitted"))
+	}
+	if !equality.Semantic.DeepEqual(newObj.Spec.PodSets, oldObj.Spec.PodSets) {
+		allErrs = append(allErrs, validatePodSets(newObj)...)
+	}
+	return allErrs
+}
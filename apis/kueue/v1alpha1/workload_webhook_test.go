@@ -0,0 +1,807 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateWorkload(t *testing.T) {
+	cases := map[string]struct {
+		podSet    PodSet
+		podSets   []PodSet
+		owner     *metav1.OwnerReference
+		admission *Admission
+		wantErrs  field.ErrorList
+	}{
+		"split across flavors": {
+			podSet: PodSet{Name: "main", Count: 5, SplitAcrossFlavors: true, Spec: requestingPodSpec()},
+			owner:  &metav1.OwnerReference{APIVersion: "example.com/v1", Kind: "Job", Name: "job", Controller: pointer.Bool(true)},
+		},
+		"split across flavors for a Job": {
+			podSet: PodSet{Name: "main", Count: 5, SplitAcrossFlavors: true, Spec: requestingPodSpec()},
+			owner:  &metav1.OwnerReference{APIVersion: "batch/v1", Kind: "Job", Name: "job", Controller: pointer.Bool(true)},
+			wantErrs: field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "podSets").Index(0).Child("splitAcrossFlavors"), ""),
+			},
+		},
+		"zero count": {
+			podSet: PodSet{Name: "main", Count: 0, Spec: requestingPodSpec()},
+			wantErrs: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "podSets").Index(0).Child("count"), nil, ""),
+			},
+		},
+		"no requests": {
+			podSet: PodSet{Name: "main", Count: 5, Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "c"}},
+			}},
+			wantErrs: field.ErrorList{
+				field.Required(field.NewPath("spec", "podSets").Index(0).Child("spec", "containers"), ""),
+			},
+		},
+		"only limits": {
+			podSet: PodSet{Name: "main", Count: 5, Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name: "c",
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					},
+				}},
+			}},
+		},
+		"requests in an init container": {
+			podSet: PodSet{Name: "main", Count: 5, Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "c"}},
+				InitContainers: []corev1.Container{{
+					Name: "init",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					},
+				}},
+			}},
+		},
+		"too many podSets": {
+			podSets: func() []PodSet {
+				podSets := make([]PodSet, MaxPodSets+1)
+				for i := range podSets {
+					podSets[i] = PodSet{Name: fmt.Sprintf("ps%d", i), Count: 1, Spec: requestingPodSpec()}
+				}
+				return podSets
+			}(),
+			wantErrs: field.ErrorList{
+				field.TooMany(field.NewPath("spec", "podSets"), 0, 0),
+			},
+		},
+		"valid minCount": {
+			podSet: PodSet{Name: "main", Count: 5, MinCount: pointer.Int32(2), Spec: requestingPodSpec()},
+		},
+		"minCount above count": {
+			podSet: PodSet{Name: "main", Count: 5, MinCount: pointer.Int32(6), Spec: requestingPodSpec()},
+			wantErrs: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "podSets").Index(0).Child("minCount"), nil, ""),
+			},
+		},
+		"zero minCount": {
+			podSet: PodSet{Name: "main", Count: 5, MinCount: pointer.Int32(0), Spec: requestingPodSpec()},
+			wantErrs: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "podSets").Index(0).Child("minCount"), nil, ""),
+			},
+		},
+		"partial admission": {
+			podSet: PodSet{Name: "main", Count: 5, MinCount: pointer.Int32(2), Spec: requestingPodSpec()},
+			admission: &Admission{
+				ClusterQueue:  "cq",
+				PodSetFlavors: []PodSetFlavors{{Name: "main", Count: pointer.Int32(3)}},
+			},
+		},
+		"partial admission below minCount": {
+			podSet: PodSet{Name: "main", Count: 5, MinCount: pointer.Int32(2), Spec: requestingPodSpec()},
+			admission: &Admission{
+				ClusterQueue:  "cq",
+				PodSetFlavors: []PodSetFlavors{{Name: "main", Count: pointer.Int32(1)}},
+			},
+			wantErrs: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "admission", "podSetFlavors").Index(0).Child("count"), nil, ""),
+			},
+		},
+		"admission for an unknown podSet": {
+			podSet: PodSet{Name: "main", Count: 5, Spec: requestingPodSpec()},
+			admission: &Admission{
+				ClusterQueue:  "cq",
+				PodSetFlavors: []PodSetFlavors{{Name: "other"}},
+			},
+			wantErrs: field.ErrorList{
+				field.NotFound(field.NewPath("spec", "admission", "podSetFlavors").Index(0).Child("name"), nil),
+				field.Required(field.NewPath("spec", "admission", "podSetFlavors"), ""),
+			},
+		},
+		"duplicated podSet in the admission": {
+			podSet: PodSet{Name: "main", Count: 5, Spec: requestingPodSpec()},
+			admission: &Admission{
+				ClusterQueue:  "cq",
+				PodSetFlavors: []PodSetFlavors{{Name: "main"}, {Name: "main"}},
+			},
+			wantErrs: field.ErrorList{
+				field.Duplicate(field.NewPath("spec", "admission", "podSetFlavors").Index(1).Child("name"), nil),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			podSets := tc.podSets
+			if podSets == nil {
+				podSets = []PodSet{tc.podSet}
+			}
+			wl := &Workload{
+				Spec: WorkloadSpec{
+					PodSets:   podSets,
+					Admission: tc.admission,
+				},
+			}
+			if tc.owner != nil {
+				wl.OwnerReferences = []metav1.OwnerReference{*tc.owner}
+			}
+			gotErrs := ValidateWorkload(wl)
+			if diff := cmp.Diff(tc.wantErrs, gotErrs, cmpopts.IgnoreFields(field.Error{}, "Detail", "BadValue")); diff != "" {
+				t.Errorf("Unexpected errors (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateWorkloadUpdate(t *testing.T) {
+	admitted := Workload{
+		Spec: WorkloadSpec{
+			QueueName: "queue",
+			Admission: &Admission{ClusterQueue: "cq"},
+		},
+	}
+	cases := map[string]struct {
+		oldObj   Workload
+		update   func(*Workload)
+		wantErrs field.ErrorList
+	}{
+		"queue change while pending": {
+			oldObj: Workload{Spec: WorkloadSpec{QueueName: "queue"}},
+			update: func(w *Workload) {
+				w.Spec.QueueName = "other"
+			},
+		},
+		"queue change while admitted": {
+			oldObj: admitted,
+			update: func(w *Workload) {
+				w.Spec.QueueName = "other"
+			},
+			wantErrs: field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "queueName"), ""),
+			},
+		},
+		"queue change along with admission removal": {
+			oldObj: admitted,
+			update: func(w *Workload) {
+				w.Spec.QueueName = "other"
+				w.Spec.Admission = nil
+			},
+		},
+		"placement hints change while admitted": {
+			oldObj: admitted,
+			update: func(w *Workload) {
+				w.Spec.PlacementHints = []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      "zone",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{"a"},
+					}},
+				}}
+			},
+			wantErrs: field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "placementHints"), ""),
+			},
+		},
+		"podSets change while admitted": {
+			oldObj: admitted,
+			update: func(w *Workload) {
+				w.Spec.PodSets = []PodSet{{Name: "main", Count: 2, Spec: requestingPodSpec()}}
+			},
+			wantErrs: field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "podSets"), ""),
+			},
+		},
+		"priority change while admitted": {
+			oldObj: admitted,
+			update: func(w *Workload) {
+				w.Spec.Priority = pointer.Int32(100)
+			},
+			wantErrs: field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "priority"), ""),
+			},
+		},
+		"podSets change along with admission removal": {
+			oldObj: admitted,
+			update: func(w *Workload) {
+				w.Spec.PodSets = []PodSet{{Name: "main", Count: 2, Spec: requestingPodSpec()}}
+				w.Spec.Admission = nil
+			},
+		},
+		"admission change in the same queue": {
+			oldObj: admitted,
+			update: func(w *Workload) {
+				w.Spec.Admission = &Admission{ClusterQueue: "other-cq"}
+			},
+		},
+		"unchanged podSets without requests": {
+			oldObj: Workload{Spec: WorkloadSpec{
+				QueueName: "queue",
+				PodSets:   []PodSet{{Name: "main", Count: 1}},
+			}},
+			update: func(w *Workload) {
+				w.Spec.QueueName = "other"
+			},
+		},
+		"podSets changed to have no requests": {
+			oldObj: Workload{Spec: WorkloadSpec{
+				PodSets: []PodSet{{Name: "main", Count: 1, Spec: requestingPodSpec()}},
+			}},
+			update: func(w *Workload) {
+				w.Spec.PodSets = []PodSet{{Name: "main", Count: 0}}
+			},
+			wantErrs: field.ErrorList{
+				field.Invalid(field.NewPath("spec", "podSets").Index(0).Child("count"), nil, ""),
+				field.Required(field.NewPath("spec", "podSets").Index(0).Child("spec", "containers"), ""),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			newObj := tc.oldObj.DeepCopy()
+			tc.update(newObj)
+			gotErrs := ValidateWorkloadUpdate(newObj, &tc.oldObj)
+			if diff := cmp.Diff(tc.wantErrs, gotErrs, cmpopts.IgnoreFields(field.Error{}, "Detail", "BadValue")); diff != "" {
+				t.Errorf("Unexpected errors (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWorkloadValidatorAdmitters(t *testing.T) {
+	pending := &Workload{Spec: WorkloadSpec{QueueName: "queue"}}
+	admitted := pending.DeepCopy()
+	admitted.Spec.Admission = &Admission{ClusterQueue: "cq"}
+	updated := admitted.DeepCopy()
+	updated.Labels = map[string]string{"foo": "bar"}
+
+	cases := map[string]struct {
+		admitters []string
+		user      string
+		oldObj    *Workload
+		newObj    *Workload
+		want      bool
+	}{
+		"admitter admits": {
+			admitters: []string{"kueue"},
+			user:      "kueue",
+			oldObj:    pending,
+			newObj:    admitted,
+			want:      true,
+		},
+		"user admits": {
+			admitters: []string{"kueue"},
+			user:      "user",
+			oldObj:    pending,
+			newObj:    admitted,
+		},
+		"user clears admission": {
+			admitters: []string{"kueue"},
+			user:      "user",
+			oldObj:    admitted,
+			newObj:    pending,
+		},
+		"user creates admitted workload": {
+			admitters: []string{"kueue"},
+			user:      "user",
+			newObj:    admitted,
+		},
+		"user creates pending workload": {
+			admitters: []string{"kueue"},
+			user:      "user",
+			newObj:    pending,
+			want:      true,
+		},
+		"user updates admitted workload": {
+			admitters: []string{"kueue"},
+			user:      "user",
+			oldObj:    admitted,
+			newObj:    updated,
+			want:      true,
+		},
+		"no admitters configured": {
+			user:   "user",
+			oldObj: pending,
+			newObj: admitted,
+			want:   true,
+		},
+	}
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("Failed creating decoder: %v", err)
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := &workloadValidator{admitters: sets.NewString(tc.admitters...)}
+			if err := v.InjectDecoder(decoder); err != nil {
+				t.Fatalf("Failed injecting decoder: %v", err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				UserInfo:  authenticationv1.UserInfo{Username: tc.user},
+				Object:    rawWorkload(t, tc.newObj),
+			}}
+			if tc.oldObj != nil {
+				req.Operation = admissionv1.Update
+				req.OldObject = rawWorkload(t, tc.oldObj)
+			}
+			resp := v.Handle(context.Background(), req)
+			if resp.Allowed != tc.want {
+				t.Errorf("Handle() allowed = %t, want %t (result: %v)", resp.Allowed, tc.want, resp.Result)
+			}
+		})
+	}
+}
+
+// fakeReviewer answers the SubjectAccessReviews, allowing the given users.
+type fakeReviewer struct {
+	client.Writer
+	allowed sets.String
+	err     error
+}
+
+func (r *fakeReviewer) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	if r.err != nil {
+		return r.err
+	}
+	review := obj.(*authorizationv1.SubjectAccessReview)
+	attrs := review.Spec.ResourceAttributes
+	review.Status.Allowed = r.allowed.Has(review.Spec.User) && attrs.Verb == "update" &&
+		attrs.Resource == "workloads" && attrs.Subresource == "priorityboost"
+	return nil
+}
+
+func TestWorkloadValidatorPriorityBoost(t *testing.T) {
+	pending := &Workload{
+		ObjectMeta: metav1.ObjectMeta{Name: "wl", Namespace: "ns"},
+		Spec:       WorkloadSpec{QueueName: "queue"},
+	}
+	boosted := pending.DeepCopy()
+	boosted.Annotations = map[string]string{PriorityBoostAnnotation: "true"}
+	invalid := pending.DeepCopy()
+	invalid.Annotations = map[string]string{PriorityBoostAnnotation: "yes"}
+	updated := boosted.DeepCopy()
+	updated.Labels = map[string]string{"foo": "bar"}
+
+	cases := map[string]struct {
+		user      string
+		reviewErr error
+		oldObj    *Workload
+		newObj    *Workload
+		want      bool
+	}{
+		"operator boosts": {
+			user:   "operator",
+			oldObj: pending,
+			newObj: boosted,
+			want:   true,
+		},
+		"operator creates boosted workload": {
+			user:   "operator",
+			newObj: boosted,
+			want:   true,
+		},
+		"admitter boosts": {
+			user:   "kueue",
+			oldObj: pending,
+			newObj: boosted,
+			want:   true,
+		},
+		"user boosts": {
+			user:   "user",
+			oldObj: pending,
+			newObj: boosted,
+		},
+		"user creates boosted workload": {
+			user:   "user",
+			newObj: boosted,
+		},
+		"user updates boosted workload": {
+			user:   "user",
+			oldObj: boosted,
+			newObj: updated,
+			want:   true,
+		},
+		"user removes boost": {
+			user:   "user",
+			oldObj: boosted,
+			newObj: pending,
+			want:   true,
+		},
+		"operator sets invalid value": {
+			user:   "operator",
+			oldObj: pending,
+			newObj: invalid,
+		},
+		"review fails": {
+			user:      "operator",
+			reviewErr: errors.New("unavailable"),
+			oldObj:    pending,
+			newObj:    boosted,
+		},
+	}
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("Failed creating decoder: %v", err)
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := &workloadValidator{
+				admitters: sets.NewString("kueue"),
+				reviewer:  &fakeReviewer{allowed: sets.NewString("operator"), err: tc.reviewErr},
+			}
+			if err := v.InjectDecoder(decoder); err != nil {
+				t.Fatalf("Failed injecting decoder: %v", err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				UserInfo:  authenticationv1.UserInfo{Username: tc.user},
+				Object:    rawWorkload(t, tc.newObj),
+			}}
+			if tc.oldObj != nil {
+				req.Operation = admissionv1.Update
+				req.OldObject = rawWorkload(t, tc.oldObj)
+			}
+			resp := v.Handle(context.Background(), req)
+			if resp.Allowed != tc.want {
+				t.Errorf("Handle() allowed = %t, want %t (result: %v)", resp.Allowed, tc.want, resp.Result)
+			}
+		})
+	}
+}
+
+func TestWorkloadValidatorMissingQueueWarnings(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("Failed creating decoder: %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&Queue{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "main"},
+			Spec:       QueueSpec{ClusterQueue: "cq"},
+		},
+		&Queue{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "orphan"},
+			Spec:       QueueSpec{ClusterQueue: "missing"},
+		},
+		&ClusterQueue{ObjectMeta: metav1.ObjectMeta{Name: "cq"}},
+		&WorkloadPriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high"}, Value: 100},
+	).Build()
+
+	cases := map[string]struct {
+		queueName           string
+		priorityClassName   string
+		priorityClassSource string
+		operation           admissionv1.Operation
+		wantWarnings        []string
+	}{
+		"queue and cluster queue exist": {
+			queueName: "main",
+			operation: admissionv1.Create,
+		},
+		"queue doesn't exist": {
+			queueName:    "mian",
+			operation:    admissionv1.Create,
+			wantWarnings: []string{"Queue ns/mian doesn't exist"},
+		},
+		"cluster queue doesn't exist": {
+			queueName:    "orphan",
+			operation:    admissionv1.Create,
+			wantWarnings: []string{"ClusterQueue missing, referenced by Queue ns/orphan, doesn't exist"},
+		},
+		"no queue name": {
+			operation: admissionv1.Create,
+		},
+		"update": {
+			queueName: "mian",
+			operation: admissionv1.Update,
+		},
+		"workload priority class exists": {
+			queueName:           "main",
+			priorityClassName:   "high",
+			priorityClassSource: WorkloadPriorityClassSource,
+			operation:           admissionv1.Create,
+		},
+		"workload priority class doesn't exist": {
+			queueName:           "main",
+			priorityClassName:   "hihg",
+			priorityClassSource: WorkloadPriorityClassSource,
+			operation:           admissionv1.Create,
+			wantWarnings:        []string{"WorkloadPriorityClass hihg doesn't exist"},
+		},
+		"pod priority class isn't checked": {
+			queueName:           "main",
+			priorityClassName:   "hihg",
+			priorityClassSource: PodPriorityClassSource,
+			operation:           admissionv1.Create,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := &workloadValidator{client: c}
+			if err := v.InjectDecoder(decoder); err != nil {
+				t.Fatalf("Failed injecting decoder: %v", err)
+			}
+			wl := &Workload{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "wl"},
+				Spec: WorkloadSpec{
+					QueueName:           tc.queueName,
+					PriorityClassName:   tc.priorityClassName,
+					PriorityClassSource: tc.priorityClassSource,
+				},
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tc.operation,
+				Namespace: "ns",
+				Object:    rawWorkload(t, wl),
+				OldObject: rawWorkload(t, wl),
+			}}
+			resp := v.Handle(context.Background(), req)
+			if !resp.Allowed {
+				t.Errorf("Handle() denied the workload (result: %v)", resp.Result)
+			}
+			if diff := cmp.Diff(tc.wantWarnings, resp.Warnings); diff != "" {
+				t.Errorf("Unexpected warnings (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func rawWorkload(t *testing.T, wl *Workload) runtime.RawExtension {
+	t.Helper()
+	wl = wl.DeepCopy()
+	wl.APIVersion = GroupVersion.String()
+	wl.Kind = "Workload"
+	data, err := json.Marshal(wl)
+	if err != nil {
+		t.Fatalf("Marshaling workload: %v", err)
+	}
+	return runtime.RawExtension{Raw: data}
+}
+
+func TestWorkloadDefaulter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	if err := schedulingv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding scheduling scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding core scheme: %v", err)
+	}
+	objs := []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "team",
+			Annotations: map[string]string{DefaultQueueAnnotation: "team-queue"},
+		}},
+		&WorkloadPriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high"}, Value: 100},
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "pod-high"}, Value: 200},
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "default-high"}, Value: 20, GlobalDefault: true},
+		&schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "default-low"}, Value: 10, GlobalDefault: true},
+	}
+
+	cases := map[string]struct {
+		namespace string
+		spec      WorkloadSpec
+		owner     *metav1.OwnerReference
+		update    bool
+		noObjs    bool
+		wantSpec  WorkloadSpec
+		wantUID   string
+	}{
+		"podSet name and label of the controller": {
+			spec: WorkloadSpec{
+				PodSets:  []PodSet{{Count: 1}},
+				Priority: pointer.Int32(1),
+			},
+			owner: &metav1.OwnerReference{
+				APIVersion: "batch/v1",
+				Kind:       "Job",
+				Name:       "job",
+				UID:        "job-uid",
+				Controller: pointer.Bool(true),
+			},
+			wantSpec: WorkloadSpec{
+				PodSets:  []PodSet{{Name: DefaultPodSetName, Count: 1}},
+				Priority: pointer.Int32(1),
+			},
+			wantUID: "job-uid",
+		},
+		"priority from a WorkloadPriorityClass": {
+			spec: WorkloadSpec{
+				PriorityClassName:   "high",
+				PriorityClassSource: WorkloadPriorityClassSource,
+			},
+			wantSpec: WorkloadSpec{
+				PriorityClassName:   "high",
+				PriorityClassSource: WorkloadPriorityClassSource,
+				Priority:            pointer.Int32(100),
+			},
+		},
+		"priority from a pod PriorityClass": {
+			spec: WorkloadSpec{
+				PriorityClassName: "pod-high",
+			},
+			wantSpec: WorkloadSpec{
+				PriorityClassName:   "pod-high",
+				PriorityClassSource: PodPriorityClassSource,
+				Priority:            pointer.Int32(200),
+			},
+		},
+		"priority from the lowest global default": {
+			wantSpec: WorkloadSpec{
+				PriorityClassName:   "default-low",
+				PriorityClassSource: PodPriorityClassSource,
+				Priority:            pointer.Int32(10),
+			},
+		},
+		"no global default": {
+			noObjs:   true,
+			wantSpec: WorkloadSpec{},
+		},
+		"missing priority class": {
+			spec: WorkloadSpec{
+				PriorityClassName:   "hihg",
+				PriorityClassSource: WorkloadPriorityClassSource,
+			},
+			wantSpec: WorkloadSpec{
+				PriorityClassName:   "hihg",
+				PriorityClassSource: WorkloadPriorityClassSource,
+			},
+		},
+		"priority already set": {
+			spec: WorkloadSpec{
+				PriorityClassName: "pod-high",
+				Priority:          pointer.Int32(5),
+			},
+			wantSpec: WorkloadSpec{
+				PriorityClassName: "pod-high",
+				Priority:          pointer.Int32(5),
+			},
+		},
+		"queue name from the namespace default": {
+			namespace: "team",
+			spec: WorkloadSpec{
+				Priority: pointer.Int32(1),
+			},
+			wantSpec: WorkloadSpec{
+				QueueName: "team-queue",
+				Priority:  pointer.Int32(1),
+			},
+		},
+		"queue name takes precedence over the namespace default": {
+			namespace: "team",
+			spec: WorkloadSpec{
+				QueueName: "queue",
+				Priority:  pointer.Int32(1),
+			},
+			wantSpec: WorkloadSpec{
+				QueueName: "queue",
+				Priority:  pointer.Int32(1),
+			},
+		},
+		"namespace default not set on update": {
+			namespace: "team",
+			spec: WorkloadSpec{
+				Priority: pointer.Int32(1),
+			},
+			update: true,
+			wantSpec: WorkloadSpec{
+				Priority: pointer.Int32(1),
+			},
+		},
+		"priority not resolved on update": {
+			spec: WorkloadSpec{
+				PriorityClassName: "pod-high",
+			},
+			update: true,
+			wantSpec: WorkloadSpec{
+				PriorityClassName: "pod-high",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if !tc.noObjs {
+				builder = builder.WithObjects(objs...)
+			}
+			d := &workloadDefaulter{client: builder.Build()}
+			namespace := tc.namespace
+			if namespace == "" {
+				namespace = "ns"
+			}
+			wl := &Workload{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "wl"},
+				Spec:       tc.spec,
+			}
+			if tc.owner != nil {
+				wl.OwnerReferences = []metav1.OwnerReference{*tc.owner}
+			}
+			if err := d.defaultWorkload(context.Background(), wl, !tc.update); err != nil {
+				t.Fatalf("Defaulting workload: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantSpec, wl.Spec, cmpopts.IgnoreFields(WorkloadSpec{}, "Active")); diff != "" {
+				t.Errorf("Unexpected spec (-want,+got):\n%s", diff)
+			}
+			if got := wl.Labels[JobUIDLabel]; got != tc.wantUID {
+				t.Errorf("Got label %s=%q, want %q", JobUIDLabel, got, tc.wantUID)
+			}
+		})
+	}
+}
+
+func requestingPodSpec() corev1.PodSpec {
+	return corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name: "c",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			},
+		}},
+	}
+}
@@ -37,6 +37,11 @@ func (in *Admission) DeepCopyInto(out *Admission) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AdmissionChecks != nil {
+		in, out := &in.AdmissionChecks, &out.AdmissionChecks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Admission.
@@ -49,6 +54,131 @@ func (in *Admission) DeepCopy() *Admission {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionBackoff) DeepCopyInto(out *AdmissionBackoff) {
+	*out = *in
+	in.NextAttemptTime.DeepCopyInto(&out.NextAttemptTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionBackoff.
+func (in *AdmissionBackoff) DeepCopy() *AdmissionBackoff {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionBackoff)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionCheck) DeepCopyInto(out *AdmissionCheck) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionCheck.
+func (in *AdmissionCheck) DeepCopy() *AdmissionCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AdmissionCheck) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionCheckList) DeepCopyInto(out *AdmissionCheckList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AdmissionCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionCheckList.
+func (in *AdmissionCheckList) DeepCopy() *AdmissionCheckList {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionCheckList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AdmissionCheckList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionCheckParametersReference) DeepCopyInto(out *AdmissionCheckParametersReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionCheckParametersReference.
+func (in *AdmissionCheckParametersReference) DeepCopy() *AdmissionCheckParametersReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionCheckParametersReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionCheckSpec) DeepCopyInto(out *AdmissionCheckSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(AdmissionCheckParametersReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionCheckSpec.
+func (in *AdmissionCheckSpec) DeepCopy() *AdmissionCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdmissionCheckState) DeepCopyInto(out *AdmissionCheckState) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdmissionCheckState.
+func (in *AdmissionCheckState) DeepCopy() *AdmissionCheckState {
+	if in == nil {
+		return nil
+	}
+	out := new(AdmissionCheckState)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterQueue) DeepCopyInto(out *ClusterQueue) {
 	*out = *in
@@ -95,13 +225,493 @@ func (in *ClusterQueueList) DeepCopy() *ClusterQueueList {
 	if in == nil {
 		return nil
 	}
-	out := new(ClusterQueueList)
+	out := new(ClusterQueueList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterQueueList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterQueuePreemption) DeepCopyInto(out *ClusterQueuePreemption) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterQueuePreemption.
+func (in *ClusterQueuePreemption) DeepCopy() *ClusterQueuePreemption {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterQueuePreemption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterQueueSpec) DeepCopyInto(out *ClusterQueueSpec) {
+	*out = *in
+	if in.ResourceGroups != nil {
+		in, out := &in.ResourceGroups, &out.ResourceGroups
+		*out = make([]ResourceGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PriorityAging != nil {
+		in, out := &in.PriorityAging, &out.PriorityAging
+		*out = new(PriorityAging)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MinBorrowingPriority != nil {
+		in, out := &in.MinBorrowingPriority, &out.MinBorrowingPriority
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Preemption != nil {
+		in, out := &in.Preemption, &out.Preemption
+		*out = new(ClusterQueuePreemption)
+		**out = **in
+	}
+	if in.FlavorFungibility != nil {
+		in, out := &in.FlavorFungibility, &out.FlavorFungibility
+		*out = new(FlavorFungibility)
+		**out = **in
+	}
+	if in.FairSharing != nil {
+		in, out := &in.FairSharing, &out.FairSharing
+		*out = new(FairSharing)
+		**out = **in
+	}
+	if in.AdmissionChecks != nil {
+		in, out := &in.AdmissionChecks, &out.AdmissionChecks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxAdmittedWorkloads != nil {
+		in, out := &in.MaxAdmittedWorkloads, &out.MaxAdmittedWorkloads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RequeuingLimitCount != nil {
+		in, out := &in.RequeuingLimitCount, &out.RequeuingLimitCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NamespaceQuotas != nil {
+		in, out := &in.NamespaceQuotas, &out.NamespaceQuotas
+		*out = make([]NamespaceQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.QuotaSchedules != nil {
+		in, out := &in.QuotaSchedules, &out.QuotaSchedules
+		*out = make([]QuotaSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterQueueSpec.
+func (in *ClusterQueueSpec) DeepCopy() *ClusterQueueSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterQueueSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterQueueStatus) DeepCopyInto(out *ClusterQueueStatus) {
+	*out = *in
+	if in.UsedResources != nil {
+		in, out := &in.UsedResources, &out.UsedResources
+		*out = make(UsedResources, len(*in))
+		for key, val := range *in {
+			var outVal map[string]Usage
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]Usage, len(*in))
+				for key, val := range *in {
+					(*out)[key] = *val.DeepCopy()
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PendingWorkloadsStatus != nil {
+		in, out := &in.PendingWorkloadsStatus, &out.PendingWorkloadsStatus
+		*out = new(PendingWorkloadsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterQueueStatus.
+func (in *ClusterQueueStatus) DeepCopy() *ClusterQueueStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterQueueStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Cohort) DeepCopyInto(out *Cohort) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Cohort.
+func (in *Cohort) DeepCopy() *Cohort {
+	if in == nil {
+		return nil
+	}
+	out := new(Cohort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Cohort) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CohortFlavor) DeepCopyInto(out *CohortFlavor) {
+	*out = *in
+	out.Limit = in.Limit.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CohortFlavor.
+func (in *CohortFlavor) DeepCopy() *CohortFlavor {
+	if in == nil {
+		return nil
+	}
+	out := new(CohortFlavor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CohortList) DeepCopyInto(out *CohortList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Cohort, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CohortList.
+func (in *CohortList) DeepCopy() *CohortList {
+	if in == nil {
+		return nil
+	}
+	out := new(CohortList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CohortList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CohortResource) DeepCopyInto(out *CohortResource) {
+	*out = *in
+	if in.Flavors != nil {
+		in, out := &in.Flavors, &out.Flavors
+		*out = make([]CohortFlavor, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CohortResource.
+func (in *CohortResource) DeepCopy() *CohortResource {
+	if in == nil {
+		return nil
+	}
+	out := new(CohortResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CohortSpec) DeepCopyInto(out *CohortSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]CohortResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CohortSpec.
+func (in *CohortSpec) DeepCopy() *CohortSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CohortSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FairSharing) DeepCopyInto(out *FairSharing) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FairSharing.
+func (in *FairSharing) DeepCopy() *FairSharing {
+	if in == nil {
+		return nil
+	}
+	out := new(FairSharing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlavorFungibility) DeepCopyInto(out *FlavorFungibility) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlavorFungibility.
+func (in *FlavorFungibility) DeepCopy() *FlavorFungibility {
+	if in == nil {
+		return nil
+	}
+	out := new(FlavorFungibility)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlavorQuotas) DeepCopyInto(out *FlavorQuotas) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlavorQuotas.
+func (in *FlavorQuotas) DeepCopy() *FlavorQuotas {
+	if in == nil {
+		return nil
+	}
+	out := new(FlavorQuotas)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeConfigReference) DeepCopyInto(out *KubeConfigReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeConfigReference.
+func (in *KubeConfigReference) DeepCopy() *KubeConfigReference {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeConfigReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiKueueCluster) DeepCopyInto(out *MultiKueueCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueueCluster.
+func (in *MultiKueueCluster) DeepCopy() *MultiKueueCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiKueueCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MultiKueueCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiKueueClusterList) DeepCopyInto(out *MultiKueueClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MultiKueueCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueueClusterList.
+func (in *MultiKueueClusterList) DeepCopy() *MultiKueueClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiKueueClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MultiKueueClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiKueueClusterSpec) DeepCopyInto(out *MultiKueueClusterSpec) {
+	*out = *in
+	out.KubeConfig = in.KubeConfig
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueueClusterSpec.
+func (in *MultiKueueClusterSpec) DeepCopy() *MultiKueueClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiKueueClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiKueueClusterStatus) DeepCopyInto(out *MultiKueueClusterStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueueClusterStatus.
+func (in *MultiKueueClusterStatus) DeepCopy() *MultiKueueClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiKueueClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiKueueConfig) DeepCopyInto(out *MultiKueueConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueueConfig.
+func (in *MultiKueueConfig) DeepCopy() *MultiKueueConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiKueueConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MultiKueueConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiKueueConfigList) DeepCopyInto(out *MultiKueueConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MultiKueueConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueueConfigList.
+func (in *MultiKueueConfigList) DeepCopy() *MultiKueueConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiKueueConfigList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ClusterQueueList) DeepCopyObject() runtime.Object {
+func (in *MultiKueueConfigList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -109,76 +719,73 @@ func (in *ClusterQueueList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ClusterQueueSpec) DeepCopyInto(out *ClusterQueueSpec) {
+func (in *MultiKueueConfigSpec) DeepCopyInto(out *MultiKueueConfigSpec) {
 	*out = *in
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = make([]Resource, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.NamespaceSelector != nil {
-		in, out := &in.NamespaceSelector, &out.NamespaceSelector
-		*out = new(v1.LabelSelector)
-		(*in).DeepCopyInto(*out)
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterQueueSpec.
-func (in *ClusterQueueSpec) DeepCopy() *ClusterQueueSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiKueueConfigSpec.
+func (in *MultiKueueConfigSpec) DeepCopy() *MultiKueueConfigSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ClusterQueueSpec)
+	out := new(MultiKueueConfigSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ClusterQueueStatus) DeepCopyInto(out *ClusterQueueStatus) {
+func (in *NamespaceQuota) DeepCopyInto(out *NamespaceQuota) {
 	*out = *in
-	if in.UsedResources != nil {
-		in, out := &in.UsedResources, &out.UsedResources
-		*out = make(UsedResources, len(*in))
-		for key, val := range *in {
-			var outVal map[string]Usage
-			if val == nil {
-				(*out)[key] = nil
-			} else {
-				in, out := &val, &outVal
-				*out = make(map[string]Usage, len(*in))
-				for key, val := range *in {
-					(*out)[key] = *val.DeepCopy()
-				}
-			}
-			(*out)[key] = outVal
-		}
+	out.Limit = in.Limit.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceQuota.
+func (in *NamespaceQuota) DeepCopy() *NamespaceQuota {
+	if in == nil {
+		return nil
 	}
+	out := new(NamespaceQuota)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterQueueStatus.
-func (in *ClusterQueueStatus) DeepCopy() *ClusterQueueStatus {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingWorkload) DeepCopyInto(out *PendingWorkload) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingWorkload.
+func (in *PendingWorkload) DeepCopy() *PendingWorkload {
 	if in == nil {
 		return nil
 	}
-	out := new(ClusterQueueStatus)
+	out := new(PendingWorkload)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Flavor) DeepCopyInto(out *Flavor) {
+func (in *PendingWorkloadsStatus) DeepCopyInto(out *PendingWorkloadsStatus) {
 	*out = *in
-	in.Quota.DeepCopyInto(&out.Quota)
+	if in.Head != nil {
+		in, out := &in.Head, &out.Head
+		*out = make([]PendingWorkload, len(*in))
+		copy(*out, *in)
+	}
+	in.LastChangeTime.DeepCopyInto(&out.LastChangeTime)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Flavor.
-func (in *Flavor) DeepCopy() *Flavor {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingWorkloadsStatus.
+func (in *PendingWorkloadsStatus) DeepCopy() *PendingWorkloadsStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Flavor)
+	out := new(PendingWorkloadsStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -187,6 +794,11 @@ func (in *Flavor) DeepCopy() *Flavor {
 func (in *PodSet) DeepCopyInto(out *PodSet) {
 	*out = *in
 	in.Spec.DeepCopyInto(&out.Spec)
+	if in.MinCount != nil {
+		in, out := &in.MinCount, &out.MinCount
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSet.
@@ -209,6 +821,18 @@ func (in *PodSetFlavors) DeepCopyInto(out *PodSetFlavors) {
 			(*out)[key] = val
 		}
 	}
+	if in.Splits != nil {
+		in, out := &in.Splits, &out.Splits
+		*out = make([]PodSetSplit, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Count != nil {
+		in, out := &in.Count, &out.Count
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSetFlavors.
@@ -221,13 +845,55 @@ func (in *PodSetFlavors) DeepCopy() *PodSetFlavors {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodSetSplit) DeepCopyInto(out *PodSetSplit) {
+	*out = *in
+	if in.Flavors != nil {
+		in, out := &in.Flavors, &out.Flavors
+		*out = make(map[corev1.ResourceName]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSetSplit.
+func (in *PodSetSplit) DeepCopy() *PodSetSplit {
+	if in == nil {
+		return nil
+	}
+	out := new(PodSetSplit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriorityAging) DeepCopyInto(out *PriorityAging) {
+	*out = *in
+	if in.MaxIncrease != nil {
+		in, out := &in.MaxIncrease, &out.MaxIncrease
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriorityAging.
+func (in *PriorityAging) DeepCopy() *PriorityAging {
+	if in == nil {
+		return nil
+	}
+	out := new(PriorityAging)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Queue) DeepCopyInto(out *Queue) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Queue.
@@ -283,6 +949,16 @@ func (in *QueueList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *QueueSpec) DeepCopyInto(out *QueueSpec) {
 	*out = *in
+	if in.MaxAdmittedWorkloads != nil {
+		in, out := &in.MaxAdmittedWorkloads, &out.MaxAdmittedWorkloads
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ReactivationCooldownSeconds != nil {
+		in, out := &in.ReactivationCooldownSeconds, &out.ReactivationCooldownSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueueSpec.
@@ -298,6 +974,33 @@ func (in *QueueSpec) DeepCopy() *QueueSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *QueueStatus) DeepCopyInto(out *QueueStatus) {
 	*out = *in
+	if in.UsedResources != nil {
+		in, out := &in.UsedResources, &out.UsedResources
+		*out = make(UsedResources, len(*in))
+		for key, val := range *in {
+			var outVal map[string]Usage
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make(map[string]Usage, len(*in))
+				for key, val := range *in {
+					(*out)[key] = *val.DeepCopy()
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Flavors != nil {
+		in, out := &in.Flavors, &out.Flavors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingWorkloadsStatus != nil {
+		in, out := &in.PendingWorkloadsStatus, &out.PendingWorkloadsStatus
+		*out = new(PendingWorkloadsStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueueStatus.
@@ -311,44 +1014,57 @@ func (in *QueueStatus) DeepCopy() *QueueStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Quota) DeepCopyInto(out *Quota) {
+func (in *QuotaSchedule) DeepCopyInto(out *QuotaSchedule) {
 	*out = *in
-	out.Min = in.Min.DeepCopy()
-	if in.Max != nil {
-		in, out := &in.Max, &out.Max
-		x := (*in).DeepCopy()
-		*out = &x
+	if in.Quotas != nil {
+		in, out := &in.Quotas, &out.Quotas
+		*out = make([]ScheduledQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Quota.
-func (in *Quota) DeepCopy() *Quota {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaSchedule.
+func (in *QuotaSchedule) DeepCopy() *QuotaSchedule {
 	if in == nil {
 		return nil
 	}
-	out := new(Quota)
+	out := new(QuotaSchedule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Resource) DeepCopyInto(out *Resource) {
+func (in *ReclaimablePod) DeepCopyInto(out *ReclaimablePod) {
 	*out = *in
-	if in.Flavors != nil {
-		in, out := &in.Flavors, &out.Flavors
-		*out = make([]Flavor, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReclaimablePod.
+func (in *ReclaimablePod) DeepCopy() *ReclaimablePod {
+	if in == nil {
+		return nil
+	}
+	out := new(ReclaimablePod)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequeueState) DeepCopyInto(out *RequeueState) {
+	*out = *in
+	if in.RequeueAt != nil {
+		in, out := &in.RequeueAt, &out.RequeueAt
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Resource.
-func (in *Resource) DeepCopy() *Resource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequeueState.
+func (in *RequeueState) DeepCopy() *RequeueState {
 	if in == nil {
 		return nil
 	}
-	out := new(Resource)
+	out := new(RequeueState)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -424,6 +1140,75 @@ func (in *ResourceFlavorList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroup) DeepCopyInto(out *ResourceGroup) {
+	*out = *in
+	if in.CoveredResources != nil {
+		in, out := &in.CoveredResources, &out.CoveredResources
+		*out = make([]corev1.ResourceName, len(*in))
+		copy(*out, *in)
+	}
+	if in.Flavors != nil {
+		in, out := &in.Flavors, &out.Flavors
+		*out = make([]FlavorQuotas, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroup.
+func (in *ResourceGroup) DeepCopy() *ResourceGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceQuota) DeepCopyInto(out *ResourceQuota) {
+	*out = *in
+	out.NominalQuota = in.NominalQuota.DeepCopy()
+	if in.BorrowingLimit != nil {
+		in, out := &in.BorrowingLimit, &out.BorrowingLimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.LendingLimit != nil {
+		in, out := &in.LendingLimit, &out.LendingLimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceQuota.
+func (in *ResourceQuota) DeepCopy() *ResourceQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledQuota) DeepCopyInto(out *ScheduledQuota) {
+	*out = *in
+	out.NominalQuota = in.NominalQuota.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledQuota.
+func (in *ScheduledQuota) DeepCopy() *ScheduledQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Usage) DeepCopyInto(out *Usage) {
 	*out = *in
@@ -556,6 +1341,63 @@ func (in *WorkloadList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadPriorityClass) DeepCopyInto(out *WorkloadPriorityClass) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadPriorityClass.
+func (in *WorkloadPriorityClass) DeepCopy() *WorkloadPriorityClass {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadPriorityClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WorkloadPriorityClass) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadPriorityClassList) DeepCopyInto(out *WorkloadPriorityClassList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]WorkloadPriorityClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadPriorityClassList.
+func (in *WorkloadPriorityClassList) DeepCopy() *WorkloadPriorityClassList {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadPriorityClassList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WorkloadPriorityClassList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadSpec) DeepCopyInto(out *WorkloadSpec) {
 	*out = *in
@@ -566,6 +1408,18 @@ func (in *WorkloadSpec) DeepCopyInto(out *WorkloadSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SameFlavorResources != nil {
+		in, out := &in.SameFlavorResources, &out.SameFlavorResources
+		*out = make([]corev1.ResourceName, len(*in))
+		copy(*out, *in)
+	}
+	if in.PlacementHints != nil {
+		in, out := &in.PlacementHints, &out.PlacementHints
+		*out = make([]corev1.NodeSelectorTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Admission != nil {
 		in, out := &in.Admission, &out.Admission
 		*out = new(Admission)
@@ -576,6 +1430,16 @@ func (in *WorkloadSpec) DeepCopyInto(out *WorkloadSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Active != nil {
+		in, out := &in.Active, &out.Active
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaximumExecutionTimeSeconds != nil {
+		in, out := &in.MaximumExecutionTimeSeconds, &out.MaximumExecutionTimeSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadSpec.
@@ -598,6 +1462,33 @@ func (in *WorkloadStatus) DeepCopyInto(out *WorkloadStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RequeueState != nil {
+		in, out := &in.RequeueState, &out.RequeueState
+		*out = new(RequeueState)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdmissionBackoff != nil {
+		in, out := &in.AdmissionBackoff, &out.AdmissionBackoff
+		*out = new(AdmissionBackoff)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdmissionChecks != nil {
+		in, out := &in.AdmissionChecks, &out.AdmissionChecks
+		*out = make([]AdmissionCheckState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReclaimablePods != nil {
+		in, out := &in.ReclaimablePods, &out.ReclaimablePods
+		*out = make([]ReclaimablePod, len(*in))
+		copy(*out, *in)
+	}
+	if in.AccumulatedPastExecutionTimeSeconds != nil {
+		in, out := &in.AccumulatedPastExecutionTimeSeconds, &out.AccumulatedPastExecutionTimeSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadStatus.
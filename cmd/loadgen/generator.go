@@ -0,0 +1,210 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/util/pointer"
+)
+
+const (
+	namePrefix     = "loadgen-"
+	cleanupTimeout = time.Minute
+)
+
+type objectKind string
+
+const (
+	kindJob      objectKind = "Job"
+	kindWorkload objectKind = "Workload"
+)
+
+type config struct {
+	namespace       string
+	kind            objectKind
+	count           int
+	rate            float64
+	queues          []string
+	sizes           []resource.Quantity
+	maxParallelism  int
+	priorityClasses []string
+	image           string
+	runtime         time.Duration
+	timeout         time.Duration
+	seed            int64
+	cleanup         bool
+}
+
+// run creates cfg.count objects at cfg.rate per second and watches the
+// Workloads in the namespace until all the created ones are admitted or the
+// context is done.
+func run(ctx context.Context, c client.WithWatch, cfg *config) (*stats, error) {
+	s := newStats()
+	var created []client.Object
+	defer func() {
+		if cfg.cleanup {
+			cleanup(c, created)
+		}
+	}()
+
+	w, err := c.Watch(ctx, &kueue.WorkloadList{}, client.InNamespace(cfg.namespace))
+	if err != nil {
+		return s, fmt.Errorf("watching workloads: %w", err)
+	}
+	defer func() {
+		w.Stop()
+	}()
+
+	rnd := rand.New(rand.NewSource(cfg.seed))
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.rate))
+	defer ticker.Stop()
+	for len(created)+s.failedCount() < cfg.count || !s.allAdmitted() {
+		var tick <-chan time.Time
+		if len(created)+s.failedCount() < cfg.count {
+			tick = ticker.C
+		}
+		select {
+		case <-ctx.Done():
+			return s, fmt.Errorf("waiting for admissions: %w", ctx.Err())
+		case <-tick:
+			obj := newObject(rnd, cfg)
+			if err := c.Create(ctx, obj); err != nil {
+				log.Error(err, "Creating object", "kind", cfg.kind)
+				s.failed()
+				continue
+			}
+			created = append(created, obj)
+			s.created(obj.GetName(), time.Now())
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				// The API server closes watches periodically. Admissions that
+				// happened in the meantime are caught by listing.
+				if w, err = c.Watch(ctx, &kueue.WorkloadList{}, client.InNamespace(cfg.namespace)); err != nil {
+					return s, fmt.Errorf("watching workloads: %w", err)
+				}
+				if err := observeList(ctx, c, cfg.namespace, s); err != nil {
+					return s, err
+				}
+				continue
+			}
+			if ev.Type == watch.Added || ev.Type == watch.Modified {
+				if wl, ok := ev.Object.(*kueue.Workload); ok {
+					observe(wl, s)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+func observeList(ctx context.Context, c client.Client, ns string, s *stats) error {
+	var wls kueue.WorkloadList
+	if err := c.List(ctx, &wls, client.InNamespace(ns)); err != nil {
+		return fmt.Errorf("listing workloads: %w", err)
+	}
+	for i := range wls.Items {
+		observe(&wls.Items[i], s)
+	}
+	return nil
+}
+
+func observe(wl *kueue.Workload, s *stats) {
+	if wl.Spec.Admission != nil {
+		// Workloads created for Jobs have the same name as the Job.
+		s.admitted(wl.Name, time.Now())
+	}
+}
+
+func newObject(rnd *rand.Rand, cfg *config) client.Object {
+	queue := cfg.queues[rnd.Intn(len(cfg.queues))]
+	size := cfg.sizes[rnd.Intn(len(cfg.sizes))]
+	count := int32(rnd.Intn(cfg.maxParallelism) + 1)
+	var priorityClass string
+	if len(cfg.priorityClasses) > 0 {
+		priorityClass = cfg.priorityClasses[rnd.Intn(len(cfg.priorityClasses))]
+	}
+	podSpec := corev1.PodSpec{
+		RestartPolicy:     corev1.RestartPolicyNever,
+		PriorityClassName: priorityClass,
+		Containers: []corev1.Container{
+			{
+				Name:  "c",
+				Image: cfg.image,
+				Args:  []string{cfg.runtime.String()},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: size},
+				},
+			},
+		},
+	}
+	meta := metav1.ObjectMeta{
+		GenerateName: namePrefix,
+		Namespace:    cfg.namespace,
+	}
+
+	if cfg.kind == kindWorkload {
+		return &kueue.Workload{
+			ObjectMeta: meta,
+			Spec: kueue.WorkloadSpec{
+				QueueName:         queue,
+				PriorityClassName: priorityClass,
+				PodSets: []kueue.PodSet{
+					{
+						Name:  kueue.DefaultPodSetName,
+						Count: count,
+						Spec:  podSpec,
+					},
+				},
+			},
+		}
+	}
+	meta.Annotations = map[string]string{constants.QueueAnnotation: queue}
+	return &batchv1.Job{
+		ObjectMeta: meta,
+		Spec: batchv1.JobSpec{
+			Parallelism: pointer.Int32(count),
+			Completions: pointer.Int32(count),
+			Suspend:     pointer.Bool(true),
+			Template:    corev1.PodTemplateSpec{Spec: podSpec},
+		},
+	}
+}
+
+func cleanup(c client.Client, objs []client.Object) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	for _, obj := range objs {
+		err := c.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "Deleting object", "object", client.ObjectKeyFromObject(obj))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// loadgen creates a configurable mix of Jobs or Workloads in a cluster running
+// kueue and reports how fast they get admitted.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	log    = ctrl.Log.WithName("loadgen")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(kueue.AddToScheme(scheme))
+}
+
+func main() {
+	var (
+		cfg        config
+		kind       string
+		queues     string
+		sizes      string
+		priorities string
+	)
+	flag.StringVar(&cfg.namespace, "namespace", "default", "Namespace where the objects are created.")
+	flag.StringVar(&kind, "kind", string(kindJob), "Kind of the objects to create: Job or Workload.")
+	flag.IntVar(&cfg.count, "count", 100, "Number of objects to create.")
+	flag.Float64Var(&cfg.rate, "rate", 10, "Objects created per second.")
+	flag.StringVar(&queues, "queues", "main", "Comma separated list of queue names the objects are spread across.")
+	flag.StringVar(&sizes, "sizes", "1", "Comma separated list of CPU requests per pod, picked at random for each object.")
+	flag.IntVar(&cfg.maxParallelism, "max-parallelism", 1, "Objects get a random number of pods between 1 and this value.")
+	flag.StringVar(&priorities, "priority-classes", "", "Comma separated list of priority class names, picked at random for each object.")
+	flag.StringVar(&cfg.image, "image", "gcr.io/k8s-staging-perf-tests/sleep:v0.0.3", "Image of the Job pods.")
+	flag.DurationVar(&cfg.runtime, "job-runtime", 10*time.Second, "How long the Job pods sleep.")
+	flag.DurationVar(&cfg.timeout, "timeout", 10*time.Minute, "How long to wait for all the objects to be admitted.")
+	flag.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "Seed of the random mix.")
+	flag.BoolVar(&cfg.cleanup, "cleanup", true, "Delete the created objects before exiting.")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	cfg.kind = objectKind(kind)
+	if cfg.kind != kindJob && cfg.kind != kindWorkload {
+		exitOnError(fmt.Errorf("unsupported kind %q", kind))
+	}
+	if cfg.count <= 0 || cfg.rate <= 0 || cfg.maxParallelism <= 0 {
+		exitOnError(fmt.Errorf("count, rate and max-parallelism must be positive"))
+	}
+	cfg.queues = splitList(queues)
+	if len(cfg.queues) == 0 {
+		exitOnError(fmt.Errorf("at least one queue is required"))
+	}
+	for _, s := range splitList(sizes) {
+		q, err := resource.ParseQuantity(s)
+		if err != nil {
+			exitOnError(fmt.Errorf("parsing size %q: %w", s, err))
+		}
+		cfg.sizes = append(cfg.sizes, q)
+	}
+	if len(cfg.sizes) == 0 {
+		exitOnError(fmt.Errorf("at least one size is required"))
+	}
+	cfg.priorityClasses = splitList(priorities)
+
+	c, err := client.NewWithWatch(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	exitOnError(err)
+
+	ctx := ctrl.SetupSignalHandler()
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	s, err := run(ctx, c, &cfg)
+	fmt.Println(s.Report())
+	exitOnError(err)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		log.Error(err, "Running the load generator")
+		os.Exit(1)
+	}
+}
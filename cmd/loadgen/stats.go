@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stats records when each object was created and when its Workload was first
+// seen admitted.
+type stats struct {
+	sync.Mutex
+	start        time.Time
+	creations    map[string]time.Time
+	admissions   map[string]time.Time
+	failedCreate int
+}
+
+func newStats() *stats {
+	return &stats{
+		start:      time.Now(),
+		creations:  make(map[string]time.Time),
+		admissions: make(map[string]time.Time),
+	}
+}
+
+func (s *stats) created(name string, t time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	s.creations[name] = t
+}
+
+// admitted records the first time a Workload was seen admitted. Workloads
+// might be observed before their creation is recorded, so they are not
+// filtered here.
+func (s *stats) admitted(name string, t time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.admissions[name]; !ok {
+		s.admissions[name] = t
+	}
+}
+
+func (s *stats) failed() {
+	s.Lock()
+	defer s.Unlock()
+	s.failedCreate++
+}
+
+func (s *stats) failedCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.failedCreate
+}
+
+func (s *stats) allAdmitted() bool {
+	s.Lock()
+	defer s.Unlock()
+	for name := range s.creations {
+		if _, ok := s.admissions[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// latencies returns the sorted admission latencies of the created objects
+// and the time of the last admission.
+func (s *stats) latencies() ([]time.Duration, time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	var last time.Time
+	latencies := make([]time.Duration, 0, len(s.creations))
+	for name, created := range s.creations {
+		admitted, ok := s.admissions[name]
+		if !ok {
+			continue
+		}
+		// The watch event can arrive before Create returns.
+		latency := admitted.Sub(created)
+		if latency < 0 {
+			latency = 0
+		}
+		latencies = append(latencies, latency)
+		if admitted.After(last) {
+			last = admitted
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies, last
+}
+
+// Report returns a human readable summary of the admission throughput and
+// latency.
+func (s *stats) Report() string {
+	latencies, last := s.latencies()
+	s.Lock()
+	created, failed := len(s.creations), s.failedCreate
+	s.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Created: %d, failed to create: %d, admitted: %d\n", created, failed, len(latencies))
+	if len(latencies) == 0 {
+		return b.String()
+	}
+	elapsed := last.Sub(s.start)
+	if elapsed > 0 {
+		fmt.Fprintf(&b, "Throughput: %.2f admissions/s over %v\n", float64(len(latencies))/elapsed.Seconds(), elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintf(&b, "Admission latency: p50=%v p90=%v p99=%v max=%v\n",
+		percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99), latencies[len(latencies)-1])
+	return b.String()
+}
+
+// percentile returns the p-th percentile of the sorted durations, using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPercentile(t *testing.T) {
+	durations := make([]time.Duration, 10)
+	for i := range durations {
+		durations[i] = time.Duration(i+1) * time.Second
+	}
+	cases := map[string]struct {
+		durations []time.Duration
+		p         int
+		want      time.Duration
+	}{
+		"empty": {
+			p: 50,
+		},
+		"single": {
+			durations: []time.Duration{time.Second},
+			p:         99,
+			want:      time.Second,
+		},
+		"p0": {
+			durations: durations,
+			p:         0,
+			want:      time.Second,
+		},
+		"p50": {
+			durations: durations,
+			p:         50,
+			want:      5 * time.Second,
+		},
+		"p99": {
+			durations: durations,
+			p:         99,
+			want:      10 * time.Second,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := percentile(tc.durations, tc.p)
+			if got != tc.want {
+				t.Errorf("percentile(_, %d) = %v, want %v", tc.p, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatsLatencies(t *testing.T) {
+	now := time.Now()
+	s := newStats()
+	s.created("a", now)
+	s.created("b", now)
+	s.created("c", now)
+	s.admitted("b", now.Add(2*time.Second))
+	s.admitted("a", now.Add(time.Second))
+	s.admitted("a", now.Add(5*time.Second))
+	s.admitted("other", now.Add(3*time.Second))
+	s.admitted("c", now.Add(-time.Second))
+
+	if !s.allAdmitted() {
+		t.Error("allAdmitted() = false, want true")
+	}
+	latencies, last := s.latencies()
+	wantLatencies := []time.Duration{0, time.Second, 2 * time.Second}
+	if diff := cmp.Diff(wantLatencies, latencies); diff != "" {
+		t.Errorf("Unexpected latencies (-want,+got):\n%s", diff)
+	}
+	if want := now.Add(2 * time.Second); !last.Equal(want) {
+		t.Errorf("Last admission at %v, want %v", last, want)
+	}
+}
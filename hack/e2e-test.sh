@@ -0,0 +1,72 @@
+#!/usr/bin/env bash
+
+# Copyright 2022 The Kubernetes Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SOURCE_DIR="$(cd "$(dirname -- "${BASH_SOURCE[0]}")" && pwd -P)"
+ROOT_DIR="${SOURCE_DIR}/.."
+
+KIND=${KIND:-kind}
+KUSTOMIZE=${KUSTOMIZE:-${ROOT_DIR}/bin/kustomize}
+KUBECTL=${KUBECTL:-kubectl}
+GO_CMD=${GO_CMD:-go}
+E2E_KIND_CLUSTER_NAME=${E2E_KIND_CLUSTER_NAME:-kind}
+E2E_KIND_VERSION=${E2E_KIND_VERSION:-kindest/node:v1.23.4}
+CERT_MANAGER_VERSION=${CERT_MANAGER_VERSION:-v1.7.1}
+ARTIFACTS=${ARTIFACTS:-${ROOT_DIR}/bin/e2e-artifacts}
+IMAGE_TAG=${IMAGE_TAG:?IMAGE_TAG must be set to the kueue image under test}
+E2E_KEEP_CLUSTER=${E2E_KEEP_CLUSTER:-false}
+
+export KUBECONFIG="${ARTIFACTS}/kubeconfig"
+
+function cleanup {
+    mkdir -p "${ARTIFACTS}"
+    ${KIND} export logs "${ARTIFACTS}/logs" --name "${E2E_KIND_CLUSTER_NAME}" || true
+    if [ "${E2E_KEEP_CLUSTER}" != "true" ]; then
+        ${KIND} delete cluster --name "${E2E_KIND_CLUSTER_NAME}"
+    fi
+}
+
+function startup {
+    mkdir -p "${ARTIFACTS}"
+    ${KIND} create cluster --name "${E2E_KIND_CLUSTER_NAME}" --image "${E2E_KIND_VERSION}" --wait 1m
+    ${KUBECTL} get nodes
+}
+
+function kind_load {
+    (cd "${ROOT_DIR}" && make image-build IMAGE_TAG="${IMAGE_TAG}")
+    ${KIND} load docker-image "${IMAGE_TAG}" --name "${E2E_KIND_CLUSTER_NAME}"
+}
+
+function kueue_deploy {
+    ${KUBECTL} apply -f "https://github.com/cert-manager/cert-manager/releases/download/${CERT_MANAGER_VERSION}/cert-manager.yaml"
+    ${KUBECTL} -n cert-manager wait --for=condition=Available deployment --all --timeout=3m
+
+    # The image is loaded into the nodes, so it must not be pulled from the registry.
+    (cd "${ROOT_DIR}/config/manager" && ${KUSTOMIZE} edit set image controller="${IMAGE_TAG}")
+    ${KUSTOMIZE} build "${ROOT_DIR}/config/default" \
+        | sed -e 's/imagePullPolicy: Always/imagePullPolicy: IfNotPresent/' \
+        | ${KUBECTL} apply -f -
+    (cd "${ROOT_DIR}/config/manager" && ${KUSTOMIZE} edit set image controller=gcr.io/k8s-staging-kueue/kueue:main)
+}
+
+trap cleanup EXIT
+startup
+kind_load
+kueue_deploy
+cd "${ROOT_DIR}" && E2E_KIND_CLUSTER_NAME="${E2E_KIND_CLUSTER_NAME}" ${GO_CMD} test -v ./test/e2e/... -timeout 30m
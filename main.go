@@ -18,14 +18,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	schedulingv1 "k8s.io/api/scheduling/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -34,18 +39,43 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	configv1alpha1 "sigs.k8s.io/kueue/apis/config/v1alpha1"
 	kueuev1alpha1 "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/cache"
 	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/controller/admissionchecks/multikueue"
+	"sigs.k8s.io/kueue/pkg/controller/admissionchecks/provisioning"
 	"sigs.k8s.io/kueue/pkg/controller/core"
 	"sigs.k8s.io/kueue/pkg/controller/workload/job"
+	"sigs.k8s.io/kueue/pkg/controller/workload/jobframework"
+	"sigs.k8s.io/kueue/pkg/metrics"
 	"sigs.k8s.io/kueue/pkg/queue"
 	"sigs.k8s.io/kueue/pkg/scheduler"
+	"sigs.k8s.io/kueue/pkg/visibility"
+	"sigs.k8s.io/kueue/pkg/workload"
 	//+kubebuilder:scaffold:imports
 )
 
+// defaultPodsReadyTimeout is the PodsReady timeout used when
+// waitForPodsReady is enabled without a timeout.
+const defaultPodsReadyTimeout = 5 * time.Minute
+
+// defaultQueueVisibilityUpdateInterval is the minimum time between changes of
+// the pending workloads listed in the status of a queue when the list is
+// enabled without an interval.
+const defaultQueueVisibilityUpdateInterval = 5 * time.Second
+
+// defaultProvisioningRetryLimitCount is the number of times that a failed
+// ProvisioningRequest is recreated when the limit is not set.
+const defaultProvisioningRetryLimitCount = 3
+
+// defaultNamespace is the namespace in which kueue is deployed when the
+// configuration doesn't set one.
+const defaultNamespace = "kueue-system"
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -78,6 +108,10 @@ func main() {
 		MetricsBindAddress:     ":8080",
 		Port:                   9443,
 		LeaderElectionID:       "c1f6bfd2.kueue.x-k8s.io",
+		// The leadership is released once the shutdown sequence finished,
+		// so that the next leader doesn't have to wait for the lease to
+		// expire.
+		LeaderElectionReleaseOnCancel: true,
 	}
 	var err error
 	config := configv1alpha1.Configuration{}
@@ -95,7 +129,20 @@ func main() {
 		setupLog.Info("Successfully loaded config file", "config", cfgStr)
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
+	if options.LeaderElectionNamespace == "" && config.Namespace != nil {
+		options.LeaderElectionNamespace = *config.Namespace
+	}
+	kubeConfig := ctrl.GetConfigOrDie()
+	if cc := config.ClientConnection; cc != nil {
+		if cc.QPS != nil {
+			kubeConfig.QPS = *cc.QPS
+		}
+		if cc.Burst != nil {
+			kubeConfig.Burst = int(*cc.Burst)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(kubeConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -107,23 +154,117 @@ func main() {
 		setupLog.Error(err, "Unable to setup cache indexes")
 	}
 
-	queues := queue.NewManager(mgr.GetClient())
-	cCache := cache.New(mgr.GetClient())
-	if failedCtrl, err := core.SetupControllers(mgr, queues, cCache); err != nil {
+	metrics.Register(ctrlmetrics.Registry)
+
+	var workloadInfoOpts []workload.InfoOption
+	if config.Resources != nil && len(config.Resources.ExcludeResourcePrefixes) > 0 {
+		workloadInfoOpts = append(workloadInfoOpts, workload.WithExcludedResourcePrefixes(config.Resources.ExcludeResourcePrefixes))
+	}
+	if config.Resources != nil && len(config.Resources.Transformations) > 0 {
+		transformations, err := resourceTransformations(config.Resources.Transformations)
+		if err != nil {
+			setupLog.Error(err, "Invalid resource transformations")
+			os.Exit(1)
+		}
+		workloadInfoOpts = append(workloadInfoOpts, workload.WithResourceTransformations(transformations))
+	}
+	waitForPodsReady := config.WaitForPodsReady != nil && config.WaitForPodsReady.Enable
+	blockAdmission := waitForPodsReady && config.WaitForPodsReady.BlockAdmission != nil && *config.WaitForPodsReady.BlockAdmission
+	cCache := cache.New(mgr.GetClient(),
+		cache.WithWorkloadInfoOptions(workloadInfoOpts...),
+		cache.WithPodsReadyTracking(blockAdmission))
+	queueOpts := []queue.Option{
+		queue.WithWorkloadInfoOptions(workloadInfoOpts...),
+		queue.WithStatusChecker(cCache),
+	}
+	if iw := config.InadmissibleWorkloads; iw != nil {
+		policy := queue.RequeueImmediate
+		if iw.RequeuePolicy != "" {
+			policy = queue.InadmissibleRequeuing(iw.RequeuePolicy)
+		}
+		switch policy {
+		case queue.RequeueImmediate, queue.RequeueEventual, queue.RequeueTimeout:
+		default:
+			setupLog.Error(nil, "invalid requeue policy for inadmissible workloads", "requeuePolicy", iw.RequeuePolicy)
+			os.Exit(1)
+		}
+		timeout := queue.DefaultInadmissibleTimeout
+		if iw.TimeoutSeconds != nil {
+			timeout = time.Duration(*iw.TimeoutSeconds) * time.Second
+		}
+		queueOpts = append(queueOpts, queue.WithInadmissibleRequeuing(policy, timeout))
+	}
+	queues := queue.NewManager(mgr.GetClient(), queueOpts...)
+	var coreOpts []core.Option
+	if waitForPodsReady {
+		timeout := defaultPodsReadyTimeout
+		if config.WaitForPodsReady.Timeout != nil {
+			timeout = config.WaitForPodsReady.Timeout.Duration
+		}
+		coreOpts = append(coreOpts, core.WithPodsReadyTimeout(&timeout))
+		if rs := config.WaitForPodsReady.RequeuingStrategy; rs != nil {
+			backoff := core.RequeuingBackoff{LimitCount: rs.BackoffLimitCount}
+			if rs.BackoffBaseSeconds != nil {
+				backoff.BaseDelay = time.Duration(*rs.BackoffBaseSeconds) * time.Second
+			}
+			if rs.BackoffMaxSeconds != nil {
+				backoff.MaxDelay = time.Duration(*rs.BackoffMaxSeconds) * time.Second
+			}
+			coreOpts = append(coreOpts, core.WithRequeuingBackoff(backoff))
+		}
+	}
+	if qv := config.QueueVisibility; qv != nil && qv.MaxCount > 0 {
+		updateInterval := defaultQueueVisibilityUpdateInterval
+		if qv.UpdateIntervalSeconds != nil {
+			updateInterval = time.Duration(*qv.UpdateIntervalSeconds) * time.Second
+		}
+		coreOpts = append(coreOpts, core.WithQueueVisibility(qv.MaxCount, updateInterval))
+	}
+	if rp := config.ObjectRetentionPolicy; rp != nil && rp.FinishedWorkloadTTLSeconds != nil {
+		ttl := time.Duration(*rp.FinishedWorkloadTTLSeconds) * time.Second
+		coreOpts = append(coreOpts, core.WithFinishedRetention(core.FinishedRetention{TTL: &ttl, DeleteOwners: rp.DeleteFinishedOwners}))
+	}
+	if failedCtrl, err := core.SetupControllers(mgr, queues, cCache, coreOpts...); err != nil {
 		setupLog.Error(err, "Unable to create controller", "controller", failedCtrl)
 	}
 	if err = job.NewReconciler(mgr.GetScheme(),
 		mgr.GetClient(),
 		mgr.GetEventRecorderFor(constants.JobControllerName),
-		job.WithManageJobsWithoutQueueName(config.ManageJobsWithoutQueueName),
+		jobframework.WithManageJobsWithoutQueueName(config.ManageJobsWithoutQueueName),
+		jobframework.WithWaitForPodsReady(waitForPodsReady),
+		jobframework.WithCache(cCache),
 	).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Job")
 		os.Exit(1)
 	}
-	if err = (&kueuev1alpha1.Workload{}).SetupWebhookWithManager(mgr); err != nil {
+	if pr := config.ProvisioningRequests; pr != nil && pr.Enable {
+		if err = setupProvisioningController(mgr, pr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ProvisioningRequest")
+			os.Exit(1)
+		}
+	}
+	if mk := config.MultiKueue; mk != nil && mk.Enable {
+		if err = setupMultiKueueControllers(mgr, config.Namespace, mk); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "MultiKueue")
+			os.Exit(1)
+		}
+	}
+	if err = job.SetupWebhook(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Job")
+		os.Exit(1)
+	}
+	if err = (&kueuev1alpha1.Workload{}).SetupWebhookWithManager(mgr, config.WorkloadAdmitters...); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Workload")
 		os.Exit(1)
 	}
+	if err = (&kueuev1alpha1.ClusterQueue{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "ClusterQueue")
+		os.Exit(1)
+	}
+	if err = (&kueuev1alpha1.Cohort{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Cohort")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -134,16 +275,59 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddMetricsExtraHandler(visibility.TopPath, visibility.NewTopHandler(cCache)); err != nil {
+		setupLog.Error(err, "unable to set up the ClusterQueues usage endpoint")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler(visibility.CapacityPath, visibility.NewCapacityHandler(cCache)); err != nil {
+		setupLog.Error(err, "unable to set up the ClusterQueues capacity endpoint")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler(visibility.ConsistencyPath, visibility.NewConsistencyHandler(cCache)); err != nil {
+		setupLog.Error(err, "unable to set up the quota consistency endpoint")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler(visibility.PendingWorkloadsPath, visibility.NewPendingWorkloadsHandler(queues)); err != nil {
+		setupLog.Error(err, "unable to set up the pending workloads endpoint")
+		os.Exit(1)
+	}
+
+	debug := config.Debug != nil && config.Debug.Enable
+	if debug {
+		setupProfilingEndpoints(mgr)
+	}
 
 	ctx := ctrl.SetupSignalHandler()
-	go func() {
-		queues.CleanUpOnContext(ctx)
-	}()
+	admissionWorkers := scheduler.DefaultAdmissionWorkers
+	if config.AdmissionWorkers != nil {
+		admissionWorkers = int(*config.AdmissionWorkers)
+	}
 	sched := scheduler.New(queues, cCache, mgr.GetClient(),
-		mgr.GetEventRecorderFor(constants.ManagerName))
-	go func() {
-		sched.Start(ctx)
-	}()
+		mgr.GetEventRecorderFor(constants.ManagerName),
+		scheduler.WithFairSharing(config.FairSharing != nil && config.FairSharing.Enable),
+		scheduler.WithWorkloadInfoOptions(workloadInfoOpts...),
+		scheduler.WithWaitForPodsReady(blockAdmission),
+		scheduler.WithDryRun(config.DryRun != nil && config.DryRun.Enable),
+		scheduler.WithAdmissionWorkers(admissionWorkers),
+		scheduler.WithProfiling(debug))
+	// On shutdown, the scheduler stops starting cycles and waits for the
+	// admissions in flight, then the statuses held by the controllers are
+	// flushed. The manager waits for this before releasing the leadership.
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		go queues.CleanUpOnContext(ctx)
+		go cCache.CleanUpOnContext(ctx)
+		if err := sched.Start(ctx); err != nil {
+			return err
+		}
+		flushCtx := ctrl.LoggerInto(context.Background(), setupLog)
+		if err := core.FlushStatuses(flushCtx, mgr.GetClient(), queues, cCache, coreOpts...); err != nil {
+			setupLog.Error(err, "unable to flush the queue statuses")
+		}
+		return nil
+	})); err != nil {
+		setupLog.Error(err, "unable to set up the scheduler")
+		os.Exit(1)
+	}
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
@@ -151,6 +335,92 @@ func main() {
 	}
 }
 
+// setupProfilingEndpoints serves the pprof profiles under /debug/pprof/ in
+// the metrics endpoint.
+func setupProfilingEndpoints(mgr ctrl.Manager) {
+	handlers := map[string]http.Handler{
+		"/debug/pprof/":        http.HandlerFunc(pprof.Index),
+		"/debug/pprof/cmdline": http.HandlerFunc(pprof.Cmdline),
+		"/debug/pprof/profile": http.HandlerFunc(pprof.Profile),
+		"/debug/pprof/symbol":  http.HandlerFunc(pprof.Symbol),
+		"/debug/pprof/trace":   http.HandlerFunc(pprof.Trace),
+	}
+	for path, handler := range handlers {
+		if err := mgr.AddMetricsExtraHandler(path, handler); err != nil {
+			setupLog.Error(err, "unable to set up the profiling endpoint", "path", path)
+			os.Exit(1)
+		}
+	}
+}
+
+// setupProvisioningController sets up the AdmissionCheck controller that
+// creates ProvisioningRequests, with the backoff of the configuration.
+func setupProvisioningController(mgr ctrl.Manager, cfg *configv1alpha1.ProvisioningRequests) error {
+	backoff := provisioning.RetryBackoff{LimitCount: defaultProvisioningRetryLimitCount}
+	if cfg.RetryLimitCount != nil {
+		backoff.LimitCount = *cfg.RetryLimitCount
+	}
+	if cfg.RetryBaseSeconds != nil {
+		backoff.BaseDelay = time.Duration(*cfg.RetryBaseSeconds) * time.Second
+	}
+	if cfg.RetryMaxSeconds != nil {
+		backoff.MaxDelay = time.Duration(*cfg.RetryMaxSeconds) * time.Second
+	}
+	opts := []provisioning.Option{provisioning.WithRetryBackoff(backoff)}
+	if cfg.ProvisioningClassName != "" {
+		opts = append(opts, provisioning.WithProvisioningClassName(cfg.ProvisioningClassName))
+	}
+	return provisioning.NewReconciler(mgr.GetClient(),
+		mgr.GetEventRecorderFor(constants.ProvisioningRequestControllerName),
+		opts...,
+	).SetupWithManager(mgr)
+}
+
+// setupMultiKueueControllers sets up the AdmissionCheck controller that
+// dispatches the workloads to other clusters, reading their kubeconfigs from
+// the namespace of kueue.
+func setupMultiKueueControllers(mgr ctrl.Manager, namespace *string, cfg *configv1alpha1.MultiKueue) error {
+	ns := defaultNamespace
+	if namespace != nil {
+		ns = *namespace
+	}
+	var opts []multikueue.Option
+	if cfg.Origin != "" {
+		opts = append(opts, multikueue.WithOrigin(cfg.Origin))
+	}
+	if cfg.SyncPeriodSeconds != nil {
+		opts = append(opts, multikueue.WithSyncPeriod(time.Duration(*cfg.SyncPeriodSeconds)*time.Second))
+	}
+	return multikueue.SetupControllers(mgr, ns, opts...)
+}
+
+// resourceTransformations converts the configured transformations, checking
+// their strategies and that each input resource has one transformation.
+func resourceTransformations(cfg []configv1alpha1.ResourceTransformation) ([]workload.ResourceTransformation, error) {
+	inputs := make(map[corev1.ResourceName]bool, len(cfg))
+	res := make([]workload.ResourceTransformation, 0, len(cfg))
+	for _, t := range cfg {
+		if inputs[t.Input] {
+			return nil, fmt.Errorf("resource %s has more than one transformation", t.Input)
+		}
+		inputs[t.Input] = true
+		var retain bool
+		switch t.Strategy {
+		case "", "Retain":
+			retain = true
+		case "Replace":
+		default:
+			return nil, fmt.Errorf("resource %s has an invalid strategy %q", t.Input, t.Strategy)
+		}
+		res = append(res, workload.ResourceTransformation{
+			Input:   t.Input,
+			Retain:  retain,
+			Outputs: t.Outputs,
+		})
+	}
+	return res, nil
+}
+
 func encodeConfig(cfg *configv1alpha1.Configuration) (string, error) {
 	codecs := serializer.NewCodecFactory(scheme)
 	const mediaType = runtime.ContentTypeYAML
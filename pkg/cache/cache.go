@@ -20,24 +20,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/cron"
 	"sigs.k8s.io/kueue/pkg/util/pointer"
+	"sigs.k8s.io/kueue/pkg/util/priority"
 	"sigs.k8s.io/kueue/pkg/workload"
 )
 
-const workloadClusterQueueKey = "spec.admission.clusterQueue"
+// WorkloadClusterQueueKey is the index of the Workloads by the ClusterQueue
+// that admitted them.
+const WorkloadClusterQueueKey = "spec.admission.clusterQueue"
 
 var (
 	errCqNotFound          = errors.New("cluster queue not found")
 	errWorkloadNotAdmitted = errors.New("workload not admitted by a ClusterQueue")
+
+	// ErrClusterQueueAlreadyExists is returned when adding a ClusterQueue that
+	// the cache already has.
+	ErrClusterQueueAlreadyExists = errors.New("ClusterQueue already exists")
 )
 
 // Cache keeps track of the Workloads that got admitted through ClusterQueues.
@@ -49,30 +62,94 @@ type Cache struct {
 	cohorts          map[string]*Cohort
 	assumedWorkloads map[string]string
 	resourceFlavors  map[string]*kueue.ResourceFlavor
+	queues           map[string]*Queue
+	// pendingWorkloads are the workloads waiting for a quota reservation, by
+	// key. Their demand is accounted in the ClusterQueue of their Queue.
+	pendingWorkloads map[string]*workload.Info
+
+	workloadInfoOptions []workload.InfoOption
+
+	// podsReadyTracking indicates whether the PodsReady condition of the
+	// admitted workloads is tracked. podsReadyCond is broadcast when the
+	// admitted workloads change.
+	podsReadyTracking bool
+	podsReadyCond     sync.Cond
+}
+
+type options struct {
+	workloadInfoOptions []workload.InfoOption
+	podsReadyTracking   bool
+}
+
+// Option configures the cache.
+type Option func(*options)
+
+// WithWorkloadInfoOptions sets the options to calculate the usage of the
+// admitted workloads.
+func WithWorkloadInfoOptions(opts ...workload.InfoOption) Option {
+	return func(o *options) {
+		o.workloadInfoOptions = opts
+	}
 }
 
-func New(client client.Client) *Cache {
-	return &Cache{
-		client:           client,
-		clusterQueues:    make(map[string]*ClusterQueue),
-		cohorts:          make(map[string]*Cohort),
-		assumedWorkloads: make(map[string]string),
-		resourceFlavors:  make(map[string]*kueue.ResourceFlavor),
+// WithPodsReadyTracking sets whether the cache tracks the PodsReady condition
+// of the admitted workloads, so that the scheduler can wait for it.
+func WithPodsReadyTracking(f bool) Option {
+	return func(o *options) {
+		o.podsReadyTracking = f
 	}
 }
 
+func New(client client.Client, opts ...Option) *Cache {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	c := &Cache{
+		client:              client,
+		clusterQueues:       make(map[string]*ClusterQueue),
+		cohorts:             make(map[string]*Cohort),
+		assumedWorkloads:    make(map[string]string),
+		resourceFlavors:     make(map[string]*kueue.ResourceFlavor),
+		queues:              make(map[string]*Queue),
+		pendingWorkloads:    make(map[string]*workload.Info),
+		workloadInfoOptions: options.workloadInfoOptions,
+		podsReadyTracking:   options.podsReadyTracking,
+	}
+	c.podsReadyCond.L = &c.RWMutex
+	return c
+}
+
 type Resources map[corev1.ResourceName]map[string]int64
 
 // Cohort is a set of ClusterQueues that can borrow resources from each other.
+// Cohorts can be nested: the ClusterQueues under the same root Cohort borrow
+// from each other, within the limits of every Cohort in between.
 type Cohort struct {
 	Name    string
 	members map[*ClusterQueue]struct{}
-
-	// These fields are only populated for a snapshot.
+	// The name of the parent Cohort and the limits, as set by the Cohort
+	// object with the same name, if there is one.
+	hasObject  bool
+	parentName string
+	limits     Resources
+
+	// These fields are only populated for a snapshot. The resources include
+	// the ClusterQueues of the descendant Cohorts.
+	Parent               *Cohort
+	Limits               Resources
 	RequestableResources Resources
 	UsedResources        Resources
 }
 
+// Root returns the root of the hierarchy of the Cohort in a snapshot.
+func (c *Cohort) Root() *Cohort {
+	for c.Parent != nil {
+		c = c.Parent
+	}
+	return c
+}
+
 func newCohort(name string, size int) *Cohort {
 	return &Cohort{
 		Name:    name,
@@ -83,8 +160,14 @@ func newCohort(name string, size int) *Cohort {
 // ClusterQueue is the internal implementation of kueue.ClusterQueue that
 // holds admitted workloads.
 type ClusterQueue struct {
-	Name                 string
-	Cohort               *Cohort
+	Name   string
+	Cohort *Cohort
+	// ResourceGroups are the groups of resources that share flavors, in the
+	// order of the ClusterQueue spec.
+	ResourceGroups []ResourceGroup
+	// RequestableResources are the quotas of the flavors of each resource.
+	// For each resource of a group, the flavors are in the order of the
+	// flavors of the group.
 	RequestableResources map[corev1.ResourceName][]FlavorLimits
 	UsedResources        Resources
 	Workloads            map[string]*workload.Info
@@ -93,19 +176,146 @@ type ClusterQueue struct {
 	// Those keys define the affinity terms of a workload
 	// that can be matched against the flavors.
 	LabelKeys map[corev1.ResourceName]sets.String
+	// The set of resources for which all the podSets of a workload have to
+	// get the same flavor.
+	SameFlavorResources sets.String
+	// The minimum priority that a workload needs to borrow from the cohort.
+	// If nil, any workload can borrow.
+	MinBorrowingPriority *int32
+	Preemption           kueue.ClusterQueuePreemption
+	FlavorFungibility    kueue.FlavorFungibility
+	// The weight that divides the dominant resource share of the
+	// ClusterQueue for fair sharing. If zero, the weight is 1.
+	FairWeight int64
+	// The names of the AdmissionChecks that the workloads have to pass after
+	// their quota is reserved.
+	AdmissionChecks []string
+	// The maximum number of workloads that can reserve quota at the same
+	// time. If nil, there is no limit.
+	MaxAdmittedWorkloads *int32
+	// The maximum requests of the workloads of each namespace, summed across
+	// flavors.
+	NamespaceQuotas workload.Requests
+	// The requests of the workloads reserving quota, by namespace, summed
+	// across flavors.
+	NamespaceUsage map[string]workload.Requests
+	// The number of workloads with a quota reservation, admitted or not, by
+	// the key of their Queue.
+	ReservingWorkloadsPerQueue map[string]int
+	// The ResourceFlavors referenced by the ClusterQueue that don't exist,
+	// in the order of the ClusterQueue spec.
+	MissingFlavors []string
+	// The names of the quota schedules whose windows were active when the
+	// quotas were computed, in the order of the ClusterQueue spec.
+	ActiveQuotaSchedules []string
+
+	// generation identifies the state of the ClusterQueue. It changes along
+	// with the quotas, flavors and usage, in the cache and in the snapshots,
+	// so that UpdateSnapshot only copies again the ClusterQueues that changed.
+	generation int64
+}
+
+// lastGeneration is the last generation given to a ClusterQueue. The
+// generations are unique across ClusterQueues, so that a ClusterQueue that
+// is deleted and created again isn't mistaken for its old copies.
+var lastGeneration int64
+
+// changed gives the ClusterQueue a new generation.
+func (c *ClusterQueue) changed() {
+	c.generation = atomic.AddInt64(&lastGeneration, 1)
+}
+
+// Active returns whether the ClusterQueue can admit new workloads, which
+// requires all the ResourceFlavors that it references to exist.
+func (c *ClusterQueue) Active() bool {
+	return len(c.MissingFlavors) == 0
+}
+
+// AdmissionLimitReached returns whether the ClusterQueue already has as many
+// workloads reserving quota as it allows.
+func (c *ClusterQueue) AdmissionLimitReached() bool {
+	return c.MaxAdmittedWorkloads != nil && len(c.Workloads) >= int(*c.MaxAdmittedWorkloads)
+}
+
+// FitsInNamespaceQuotas returns an error if admitting the workload would
+// make its namespace exceed the namespace quotas of the ClusterQueue.
+func (c *ClusterQueue) FitsInNamespaceQuotas(wi *workload.Info) error {
+	if len(c.NamespaceQuotas) == 0 {
+		return nil
+	}
+	requests := workload.Requests{}
+	for _, ps := range wi.TotalRequests {
+		for name, v := range ps.Requests {
+			requests[name] += v
+		}
+	}
+	names := make([]string, 0, len(c.NamespaceQuotas))
+	for name := range c.NamespaceQuotas {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	used := c.NamespaceUsage[wi.Obj.Namespace]
+	for _, n := range names {
+		name := corev1.ResourceName(n)
+		limit := c.NamespaceQuotas[name]
+		if v, ok := requests[name]; ok && used[name]+v > limit {
+			q := workload.ResourceQuantity(name, used[name])
+			l := workload.ResourceQuantity(name, limit)
+			return fmt.Errorf("namespace %s uses %s of its quota of %s for %s", wi.Obj.Namespace, q.String(), l.String(), name)
+		}
+	}
+	return nil
+}
+
+// Queue holds the admission limits of a kueue.Queue and the ClusterQueue
+// that it points to.
+type Queue struct {
+	Key          string
+	ClusterQueue string
+	// The maximum number of workloads from this Queue that can be admitted
+	// at the same time. If nil, there is no limit.
+	MaxAdmittedWorkloads *int32
+}
+
+// CanBorrow returns whether a workload with the given priority can borrow
+// resources from the cohort.
+func (c *ClusterQueue) CanBorrow(priority int32) bool {
+	return c.MinBorrowingPriority == nil || priority >= *c.MinBorrowingPriority
+}
+
+// ResourceGroup holds the resources that get the same flavor for a podSet,
+// and the names of their flavors, in order of preference.
+type ResourceGroup struct {
+	CoveredResources []corev1.ResourceName
+	Flavors          []string
 }
 
 // FlavorLimits holds a processed ClusterQueue flavor quota.
 type FlavorLimits struct {
-	Name string
-	Min  int64
-	Max  *int64
+	Name    string
+	Nominal int64
+	// BorrowingLimit is the maximum usage past the nominal quota. If nil,
+	// there is no limit.
+	BorrowingLimit *int64
+	// LendingLimit is the maximum of the nominal quota that the other
+	// ClusterQueues in the cohort can use. If nil, they can use all of it.
+	LendingLimit *int64
+}
+
+// Guaranteed returns the part of the nominal quota that the ClusterQueue
+// doesn't lend to the cohort.
+func (f *FlavorLimits) Guaranteed() int64 {
+	if f.LendingLimit == nil || *f.LendingLimit >= f.Nominal {
+		return 0
+	}
+	return f.Nominal - *f.LendingLimit
 }
 
 func (c *Cache) newClusterQueue(cq *kueue.ClusterQueue) (*ClusterQueue, error) {
 	cqImpl := &ClusterQueue{
-		Name:      cq.Name,
-		Workloads: map[string]*workload.Info{},
+		Name:                       cq.Name,
+		Workloads:                  map[string]*workload.Info{},
+		ReservingWorkloadsPerQueue: map[string]int{},
 	}
 	if err := cqImpl.update(cq, c.resourceFlavors); err != nil {
 		return nil, err
@@ -115,34 +325,110 @@ func (c *Cache) newClusterQueue(cq *kueue.ClusterQueue) (*ClusterQueue, error) {
 }
 
 func (c *ClusterQueue) update(in *kueue.ClusterQueue, resourceFlavors map[string]*kueue.ResourceFlavor) error {
-	c.RequestableResources = resourceLimitsByName(in.Spec.Resources)
+	c.updateQuotas(in, time.Now())
+	c.SameFlavorResources = nil
+	for _, rg := range in.Spec.ResourceGroups {
+		if rg.FlavorAssignment == kueue.SameFlavor {
+			if c.SameFlavorResources == nil {
+				c.SameFlavorResources = sets.NewString()
+			}
+			for _, name := range rg.CoveredResources {
+				c.SameFlavorResources.Insert(string(name))
+			}
+		}
+	}
+	c.MinBorrowingPriority = in.Spec.MinBorrowingPriority
+	if in.Spec.Preemption != nil {
+		c.Preemption = *in.Spec.Preemption
+	} else {
+		c.Preemption = kueue.ClusterQueuePreemption{}
+	}
+	if in.Spec.FlavorFungibility != nil {
+		c.FlavorFungibility = *in.Spec.FlavorFungibility
+	} else {
+		c.FlavorFungibility = kueue.FlavorFungibility{}
+	}
+	c.FairWeight = 0
+	if in.Spec.FairSharing != nil {
+		c.FairWeight = int64(in.Spec.FairSharing.Weight)
+	}
+	c.AdmissionChecks = in.Spec.AdmissionChecks
+	c.MaxAdmittedWorkloads = in.Spec.MaxAdmittedWorkloads
+	c.NamespaceQuotas = nil
+	if len(in.Spec.NamespaceQuotas) > 0 {
+		c.NamespaceQuotas = make(workload.Requests, len(in.Spec.NamespaceQuotas))
+		for _, q := range in.Spec.NamespaceQuotas {
+			c.NamespaceQuotas[q.Name] = workload.ResourceValue(q.Name, q.Limit)
+		}
+	}
 	nsSelector, err := metav1.LabelSelectorAsSelector(in.Spec.NamespaceSelector)
 	if err != nil {
 		return err
 	}
 	c.NamespaceSelector = nsSelector
 
-	usedResources := make(Resources, len(in.Spec.Resources))
-	for _, r := range in.Spec.Resources {
-		if len(r.Flavors) == 0 {
+	usedResources := make(Resources, len(c.RequestableResources))
+	for name, flavors := range c.RequestableResources {
+		if len(flavors) == 0 {
 			continue
 		}
 
-		existingUsedFlavors := c.UsedResources[r.Name]
-		usedFlavors := make(map[string]int64, len(r.Flavors))
-		for _, f := range r.Flavors {
-			usedFlavors[string(f.Name)] = existingUsedFlavors[string(f.Name)]
+		existingUsedFlavors := c.UsedResources[name]
+		usedFlavors := make(map[string]int64, len(flavors))
+		for _, f := range flavors {
+			usedFlavors[f.Name] = existingUsedFlavors[f.Name]
 		}
-		usedResources[r.Name] = usedFlavors
+		usedResources[name] = usedFlavors
 	}
 	c.UsedResources = usedResources
 	c.UpdateLabelKeys(resourceFlavors)
+	c.updateMissingFlavors(resourceFlavors)
 	return nil
 }
 
+// updateQuotas sets the quotas of the ClusterQueue to the ones of its
+// resource groups, overridden by the quota schedules active at the given
+// time. It returns when the active quota schedules can change next.
+func (c *ClusterQueue) updateQuotas(in *kueue.ClusterQueue, now time.Time) time.Time {
+	c.changed()
+	groups, active, next := scheduledResourceGroups(in, now)
+	c.ResourceGroups, c.RequestableResources = resourceLimitsByName(groups)
+	c.ActiveQuotaSchedules = active
+	return next
+}
+
+// updateMissingFlavors updates the ResourceFlavors referenced by the
+// ClusterQueue that are not in the given set.
+func (c *ClusterQueue) updateMissingFlavors(flavors map[string]*kueue.ResourceFlavor) {
+	c.changed()
+	c.MissingFlavors = nil
+	seen := sets.NewString()
+	for _, rg := range c.ResourceGroups {
+		for _, name := range rg.Flavors {
+			if _, exist := flavors[name]; !exist && !seen.Has(name) {
+				c.MissingFlavors = append(c.MissingFlavors, name)
+			}
+			seen.Insert(name)
+		}
+	}
+}
+
+// usesFlavor returns whether the ClusterQueue references the ResourceFlavor.
+func (c *ClusterQueue) usesFlavor(name string) bool {
+	for _, rg := range c.ResourceGroups {
+		for _, f := range rg.Flavors {
+			if f == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // UpdateLabelKeys updates a ClusterQueue's LabelKeys based on the passed ResourceFlavors set.
 // Exported only for testing.
 func (c *ClusterQueue) UpdateLabelKeys(flavors map[string]*kueue.ResourceFlavor) {
+	c.changed()
 	labelKeys := map[corev1.ResourceName]sets.String{}
 	for rName, flvLimits := range c.RequestableResources {
 		if len(flvLimits) == 0 {
@@ -170,14 +456,15 @@ func (c *ClusterQueue) UpdateLabelKeys(flavors map[string]*kueue.ResourceFlavor)
 	}
 }
 
-func (c *ClusterQueue) addWorkload(w *kueue.Workload) error {
+func (c *ClusterQueue) addWorkload(w *kueue.Workload, opts ...workload.InfoOption) error {
 	k := workload.Key(w)
 	if _, exist := c.Workloads[k]; exist {
 		return fmt.Errorf("workload already exists in ClusterQueue")
 	}
-	wi := workload.NewInfo(w)
+	wi := workload.NewInfo(w, opts...)
 	c.Workloads[k] = wi
 	c.updateWorkloadUsage(wi, 1)
+	c.ReservingWorkloadsPerQueue[queueKeyForWorkload(w)]++
 	return nil
 }
 
@@ -189,17 +476,52 @@ func (c *ClusterQueue) deleteWorkload(w *kueue.Workload) {
 	}
 	c.updateWorkloadUsage(wi, -1)
 	delete(c.Workloads, k)
+	qKey := queueKeyForWorkload(w)
+	if c.ReservingWorkloadsPerQueue[qKey]--; c.ReservingWorkloadsPerQueue[qKey] <= 0 {
+		delete(c.ReservingWorkloadsPerQueue, qKey)
+	}
 }
 
 func (c *ClusterQueue) updateWorkloadUsage(wi *workload.Info, m int64) {
+	c.changed()
+	updateWorkloadUsage(c.UsedResources, wi, m)
+	if c.NamespaceUsage == nil {
+		c.NamespaceUsage = make(map[string]workload.Requests)
+	}
+	ns := wi.Obj.Namespace
+	nsUsage := c.NamespaceUsage[ns]
+	if nsUsage == nil {
+		nsUsage = workload.Requests{}
+		c.NamespaceUsage[ns] = nsUsage
+	}
 	for _, ps := range wi.TotalRequests {
-		for wlRes, wlResFlv := range ps.Flavors {
-			v, wlResExist := ps.Requests[wlRes]
-			cqResFlv, cqResExist := c.UsedResources[wlRes]
-			if cqResExist && wlResExist {
-				if _, cqFlvExist := cqResFlv[wlResFlv]; cqFlvExist {
-					cqResFlv[wlResFlv] += v * m
-				}
+		for name, v := range ps.Requests {
+			if nsUsage[name] += v * m; nsUsage[name] == 0 {
+				delete(nsUsage, name)
+			}
+		}
+	}
+	if len(nsUsage) == 0 {
+		delete(c.NamespaceUsage, ns)
+	}
+}
+
+func updateWorkloadUsage(used Resources, wi *workload.Info, m int64) {
+	for _, ps := range wi.TotalRequests {
+		updateUsage(used, ps.Requests, ps.Flavors, m)
+		for _, split := range ps.Splits {
+			updateUsage(used, split.Requests, split.Flavors, m)
+		}
+	}
+}
+
+func updateUsage(used Resources, requests workload.Requests, flavors map[corev1.ResourceName]string, m int64) {
+	for wlRes, wlResFlv := range flavors {
+		v, wlResExist := requests[wlRes]
+		cqResFlv, cqResExist := used[wlRes]
+		if cqResExist && wlResExist {
+			if _, cqFlvExist := cqResFlv[wlResFlv]; cqFlvExist {
+				cqResFlv[wlResFlv] += v * m
 			}
 		}
 	}
@@ -213,6 +535,7 @@ func (c *Cache) AddOrUpdateResourceFlavor(rf *kueue.ResourceFlavor) {
 		// because it is not expensive to do so, and is not worth tracking which ClusterQueues use
 		// which flavors.
 		cq.UpdateLabelKeys(c.resourceFlavors)
+		cq.updateMissingFlavors(c.resourceFlavors)
 	}
 	c.Unlock()
 }
@@ -220,29 +543,122 @@ func (c *Cache) AddOrUpdateResourceFlavor(rf *kueue.ResourceFlavor) {
 func (c *Cache) DeleteResourceFlavor(rf *kueue.ResourceFlavor) {
 	c.Lock()
 	delete(c.resourceFlavors, rf.Name)
+	for _, cq := range c.clusterQueues {
+		cq.updateMissingFlavors(c.resourceFlavors)
+	}
 	c.Unlock()
 }
 
+// ClusterQueuesUsingFlavor returns the names of the ClusterQueues that
+// reference the ResourceFlavor.
+func (c *Cache) ClusterQueuesUsingFlavor(name string) []string {
+	c.RLock()
+	defer c.RUnlock()
+	var cqs []string
+	for _, cq := range c.clusterQueues {
+		if cq.usesFlavor(name) {
+			cqs = append(cqs, cq.Name)
+		}
+	}
+	return cqs
+}
+
+// ClusterQueueActive returns whether the ClusterQueue can admit new
+// workloads. A ClusterQueue that is not tracked is reported as active, so
+// that the scheduler reports it as missing.
+func (c *Cache) ClusterQueueActive(name string) bool {
+	c.RLock()
+	defer c.RUnlock()
+	cq := c.clusterQueues[name]
+	return cq == nil || cq.Active()
+}
+
+// ClusterQueueMissingFlavors returns the ResourceFlavors referenced by the
+// ClusterQueue that don't exist.
+func (c *Cache) ClusterQueueMissingFlavors(name string) []string {
+	c.RLock()
+	defer c.RUnlock()
+	cq := c.clusterQueues[name]
+	if cq == nil {
+		return nil
+	}
+	return cq.MissingFlavors
+}
+
+// AddOrUpdateQueue starts tracking the admission limits of a Queue or
+// replaces the tracked ones.
+func (c *Cache) AddOrUpdateQueue(q *kueue.Queue) {
+	c.Lock()
+	defer c.Unlock()
+	key := queueKey(q)
+	c.queues[key] = &Queue{
+		Key:                  key,
+		ClusterQueue:         string(q.Spec.ClusterQueue),
+		MaxAdmittedWorkloads: q.Spec.MaxAdmittedWorkloads,
+	}
+}
+
+// DeleteQueue stops tracking the admission limits of a Queue.
+func (c *Cache) DeleteQueue(q *kueue.Queue) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.queues, queueKey(q))
+}
+
+// AddOrUpdateCohort starts tracking the parent and the limits of a Cohort or
+// replaces the tracked ones.
+func (c *Cache) AddOrUpdateCohort(co *kueue.Cohort) {
+	c.Lock()
+	defer c.Unlock()
+	cohort, ok := c.cohorts[co.Name]
+	if !ok {
+		cohort = newCohort(co.Name, 0)
+		c.cohorts[co.Name] = cohort
+	}
+	cohort.hasObject = true
+	cohort.parentName = co.Spec.Parent
+	cohort.limits = cohortLimits(co.Spec.Resources)
+}
+
+// DeleteCohort stops tracking the parent and the limits of a Cohort. The
+// Cohort remains, as a root without limits, while ClusterQueues reference it.
+func (c *Cache) DeleteCohort(co *kueue.Cohort) {
+	c.Lock()
+	defer c.Unlock()
+	cohort, ok := c.cohorts[co.Name]
+	if !ok {
+		return
+	}
+	cohort.hasObject = false
+	cohort.parentName = ""
+	cohort.limits = nil
+	if len(cohort.members) == 0 {
+		delete(c.cohorts, co.Name)
+	}
+}
+
 func (c *Cache) AddClusterQueue(ctx context.Context, cq *kueue.ClusterQueue) error {
 	c.Lock()
 	defer c.Unlock()
 
 	if _, ok := c.clusterQueues[cq.Name]; ok {
-		return fmt.Errorf("ClusterQueue already exists")
+		return ErrClusterQueueAlreadyExists
 	}
 	cqImpl, err := c.newClusterQueue(cq)
 	if err != nil {
 		return err
 	}
-	c.addClusterQueueToCohort(cqImpl, cq.Spec.Cohort)
-	c.clusterQueues[cq.Name] = cqImpl
 
 	// On controller restart, an add ClusterQueue event may come after
 	// add workload events, and so here we explicitly list and add existing workloads.
+	// They are listed before adding the ClusterQueue, so that a failure
+	// leaves the cache unchanged and the operation can be retried.
 	var workloads kueue.WorkloadList
-	if err := c.client.List(ctx, &workloads, client.MatchingFields{workloadClusterQueueKey: cq.Name}); err != nil {
+	if err := c.client.List(ctx, &workloads, client.MatchingFields{WorkloadClusterQueueKey: cq.Name}); err != nil {
 		return fmt.Errorf("listing workloads that match the queue: %w", err)
 	}
+	c.addClusterQueueToCohort(cqImpl, cq.Spec.Cohort)
+	c.clusterQueues[cq.Name] = cqImpl
 	for i, w := range workloads.Items {
 		// Checking ClusterQueue name again because the field index is not available in tests.
 		if w.Spec.Admission == nil || string(w.Spec.Admission.ClusterQueue) != cq.Name {
@@ -275,6 +691,116 @@ func (c *Cache) UpdateClusterQueue(cq *kueue.ClusterQueue) error {
 	return nil
 }
 
+// UpdateQuotaSchedules recomputes the quotas of a tracked ClusterQueue with
+// the quota schedules active at the given time. It returns whether the
+// active quota schedules changed, and when they can change next, which is
+// the zero time if they never do.
+func (c *Cache) UpdateQuotaSchedules(cq *kueue.ClusterQueue, now time.Time) (bool, time.Time, error) {
+	c.Lock()
+	defer c.Unlock()
+	cqImpl, ok := c.clusterQueues[cq.Name]
+	if !ok {
+		return false, time.Time{}, errCqNotFound
+	}
+	oldActive := cqImpl.ActiveQuotaSchedules
+	next := cqImpl.updateQuotas(cq, now)
+	return !equality.Semantic.DeepEqual(oldActive, cqImpl.ActiveQuotaSchedules), next, nil
+}
+
+// QuotaExcessWorkloads returns the workloads to evict from the ClusterQueue
+// so that its usage fits its quotas again, after they shrunk: the lowest
+// priority, most recently created workloads using a [flavor, resource]
+// combination above its quota. The quota is the nominal quota, plus the
+// borrowing limit if the ClusterQueue is in a cohort. A ClusterQueue in a
+// cohort without a borrowing limit is never above its quota. The usage of
+// the workloads that are already being evicted doesn't count.
+func (c *Cache) QuotaExcessWorkloads(name string) []*workload.Info {
+	c.RLock()
+	defer c.RUnlock()
+	cq, ok := c.clusterQueues[name]
+	if !ok {
+		return nil
+	}
+	used := make(Resources, len(cq.UsedResources))
+	for res, flavors := range cq.UsedResources {
+		used[res] = make(map[string]int64, len(flavors))
+		for flavor, v := range flavors {
+			used[res][flavor] = v
+		}
+	}
+	candidates := make([]*workload.Info, 0, len(cq.Workloads))
+	for _, wi := range cq.Workloads {
+		if workload.InCondition(wi.Obj, kueue.WorkloadEvicted) {
+			updateWorkloadUsage(used, wi, -1)
+			continue
+		}
+		candidates = append(candidates, wi)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if pa, pb := priority.Priority(a.Obj), priority.Priority(b.Obj); pa != pb {
+			return pa < pb
+		}
+		if !a.Obj.CreationTimestamp.Equal(&b.Obj.CreationTimestamp) {
+			return b.Obj.CreationTimestamp.Before(&a.Obj.CreationTimestamp)
+		}
+		return workload.Key(a.Obj) < workload.Key(b.Obj)
+	})
+	var targets []*workload.Info
+	for _, wi := range candidates {
+		excess := cq.excessUsage(used)
+		if len(excess) == 0 {
+			break
+		}
+		updateWorkloadUsage(excess, wi, 1)
+		if !hasUsage(excess) {
+			continue
+		}
+		updateWorkloadUsage(used, wi, -1)
+		targets = append(targets, wi)
+	}
+	return targets
+}
+
+// excessUsage returns the [flavor, resource] combinations whose usage is
+// above their quota, with a usage of zero.
+func (c *ClusterQueue) excessUsage(used Resources) Resources {
+	var excess Resources
+	for res, flavors := range c.RequestableResources {
+		for _, f := range flavors {
+			limit := f.Nominal
+			if c.Cohort != nil {
+				if f.BorrowingLimit == nil {
+					continue
+				}
+				limit += *f.BorrowingLimit
+			}
+			if used[res][f.Name] <= limit {
+				continue
+			}
+			if excess == nil {
+				excess = make(Resources)
+			}
+			if excess[res] == nil {
+				excess[res] = make(map[string]int64)
+			}
+			excess[res][f.Name] = 0
+		}
+	}
+	return excess
+}
+
+func hasUsage(used Resources) bool {
+	for _, flavors := range used {
+		for _, v := range flavors {
+			if v > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (c *Cache) DeleteClusterQueue(cq *kueue.ClusterQueue) {
 	c.Lock()
 	defer c.Unlock()
@@ -284,11 +810,13 @@ func (c *Cache) DeleteClusterQueue(cq *kueue.ClusterQueue) {
 	}
 	c.deleteClusterQueueFromCohort(cqImpl)
 	delete(c.clusterQueues, cq.Name)
+	c.podsReadyCond.Broadcast()
 }
 
 func (c *Cache) AddOrUpdateWorkload(w *kueue.Workload) bool {
 	c.Lock()
 	defer c.Unlock()
+	c.podsReadyCond.Broadcast()
 	return c.addOrUpdateWorkload(w)
 }
 
@@ -308,12 +836,13 @@ func (c *Cache) addOrUpdateWorkload(w *kueue.Workload) bool {
 		clusterQueue.deleteWorkload(w)
 	}
 
-	return clusterQueue.addWorkload(w) == nil
+	return clusterQueue.addWorkload(w, c.workloadInfoOptions...) == nil
 }
 
 func (c *Cache) UpdateWorkload(oldWl, newWl *kueue.Workload) error {
 	c.Lock()
 	defer c.Unlock()
+	c.podsReadyCond.Broadcast()
 	if oldWl.Spec.Admission != nil {
 		cq, ok := c.clusterQueues[string(oldWl.Spec.Admission.ClusterQueue)]
 		if !ok {
@@ -330,7 +859,7 @@ func (c *Cache) UpdateWorkload(oldWl, newWl *kueue.Workload) error {
 	if !ok {
 		return fmt.Errorf("new ClusterQueue doesn't exist")
 	}
-	return cq.addWorkload(newWl)
+	return cq.addWorkload(newWl, c.workloadInfoOptions...)
 }
 
 func (c *Cache) DeleteWorkload(w *kueue.Workload) error {
@@ -348,9 +877,31 @@ func (c *Cache) DeleteWorkload(w *kueue.Workload) error {
 	c.cleanupAssumedState(w)
 
 	qc.deleteWorkload(w)
+	c.podsReadyCond.Broadcast()
 	return nil
 }
 
+// AddOrUpdatePendingWorkload accounts for the demand of a workload waiting
+// for a quota reservation, or replaces the accounted one. Inactive workloads
+// have no demand.
+func (c *Cache) AddOrUpdatePendingWorkload(w *kueue.Workload) {
+	c.Lock()
+	defer c.Unlock()
+	key := workload.Key(w)
+	if !workload.IsActive(w) {
+		delete(c.pendingWorkloads, key)
+		return
+	}
+	c.pendingWorkloads[key] = workload.NewInfo(w, c.workloadInfoOptions...)
+}
+
+// DeletePendingWorkload releases the demand of a pending workload.
+func (c *Cache) DeletePendingWorkload(w *kueue.Workload) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.pendingWorkloads, workload.Key(w))
+}
+
 func (c *Cache) AssumeWorkload(w *kueue.Workload) error {
 	c.Lock()
 	defer c.Unlock()
@@ -370,7 +921,7 @@ func (c *Cache) AssumeWorkload(w *kueue.Workload) error {
 		return errCqNotFound
 	}
 
-	if err := cq.addWorkload(w); err != nil {
+	if err := cq.addWorkload(w, c.workloadInfoOptions...); err != nil {
 		return err
 	}
 	c.assumedWorkloads[k] = string(w.Spec.Admission.ClusterQueue)
@@ -395,17 +946,91 @@ func (c *Cache) ForgetWorkload(w *kueue.Workload) error {
 		return errCqNotFound
 	}
 	cq.deleteWorkload(w)
+	c.podsReadyCond.Broadcast()
 	return nil
 }
 
-// Usage reports the used resources and number of workloads admitted by the ClusterQueue.
-func (c *Cache) Usage(cqObj *kueue.ClusterQueue) (kueue.UsedResources, int, error) {
+// PodsReadyForAllAdmittedWorkloads returns whether all the admitted workloads,
+// including the assumed ones, have the PodsReady condition. The workloads
+// whose AdmissionChecks didn't complete are ignored, as their pods don't
+// start until they are admitted. It always returns true if the cache doesn't
+// track the PodsReady condition.
+func (c *Cache) PodsReadyForAllAdmittedWorkloads() bool {
+	if !c.podsReadyTracking {
+		return true
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.podsReadyForAllAdmittedWorkloads()
+}
+
+func (c *Cache) podsReadyForAllAdmittedWorkloads() bool {
+	for _, cq := range c.clusterQueues {
+		for _, wl := range cq.Workloads {
+			if workload.IsAdmitted(wl.Obj) && !workload.InCondition(wl.Obj, kueue.WorkloadPodsReady) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// WaitForPodsReady blocks until all the admitted workloads have the PodsReady
+// condition or the context is done.
+func (c *Cache) WaitForPodsReady(ctx context.Context) {
+	if !c.podsReadyTracking {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for !c.podsReadyForAllAdmittedWorkloads() {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			c.podsReadyCond.Wait()
+		}
+	}
+}
+
+// CleanUpOnContext tracks the context. When closed, it wakes the routines
+// waiting for the PodsReady condition of the admitted workloads.
+func (c *Cache) CleanUpOnContext(ctx context.Context) {
+	<-ctx.Done()
+	// Holding the lock guarantees that WaitForPodsReady is either waiting, or
+	// it will observe that the context is done.
+	c.Lock()
+	defer c.Unlock()
+	c.podsReadyCond.Broadcast()
+}
+
+// WorkloadCounts are the numbers of workloads that hold quota in a
+// ClusterQueue.
+type WorkloadCounts struct {
+	// Reserving is the number of workloads with a quota reservation,
+	// including the admitted ones.
+	Reserving int
+	// Admitted is the number of workloads with a quota reservation whose
+	// AdmissionChecks are all Ready.
+	Admitted int
+}
+
+func (wc *WorkloadCounts) add(wi *workload.Info) {
+	wc.Reserving++
+	if workload.IsAdmitted(wi.Obj) {
+		wc.Admitted++
+	}
+}
+
+// Usage reports the used resources and number of workloads reserving quota
+// in and admitted by the ClusterQueue.
+func (c *Cache) Usage(cqObj *kueue.ClusterQueue) (kueue.UsedResources, WorkloadCounts, error) {
 	c.RLock()
 	defer c.RUnlock()
 
 	cq := c.clusterQueues[cqObj.Name]
 	if cq == nil {
-		return nil, 0, errCqNotFound
+		return nil, WorkloadCounts{}, errCqNotFound
 	}
 	usage := make(kueue.UsedResources, len(cq.UsedResources))
 	for rName, usedRes := range cq.UsedResources {
@@ -416,7 +1041,7 @@ func (c *Cache) Usage(cqObj *kueue.ClusterQueue) (kueue.UsedResources, int, erro
 			fUsage := kueue.Usage{
 				Total: pointer.Quantity(workload.ResourceQuantity(rName, used)),
 			}
-			borrowing := used - flavor.Min
+			borrowing := used - flavor.Nominal
 			if borrowing > 0 {
 				fUsage.Borrowed = pointer.Quantity(workload.ResourceQuantity(rName, borrowing))
 			}
@@ -424,7 +1049,133 @@ func (c *Cache) Usage(cqObj *kueue.ClusterQueue) (kueue.UsedResources, int, erro
 		}
 		usage[rName] = rUsage
 	}
-	return usage, len(cq.Workloads), nil
+	var counts WorkloadCounts
+	for _, wi := range cq.Workloads {
+		counts.add(wi)
+	}
+	return usage, counts, nil
+}
+
+// QueueUsage reports the resources reserved by and the number of workloads
+// reserving quota and admitted from the Queue, and the names of the existing
+// flavors that the Queue can use through its ClusterQueue. If the
+// ClusterQueue is not tracked, the Queue has no usage.
+func (c *Cache) QueueUsage(q *kueue.Queue) (kueue.UsedResources, WorkloadCounts, []string) {
+	c.RLock()
+	defer c.RUnlock()
+
+	cq := c.clusterQueues[string(q.Spec.ClusterQueue)]
+	if cq == nil {
+		return nil, WorkloadCounts{}, nil
+	}
+	used := make(Resources, len(cq.RequestableResources))
+	flavors := sets.NewString()
+	for rName, requestable := range cq.RequestableResources {
+		used[rName] = make(map[string]int64, len(requestable))
+		for _, flavor := range requestable {
+			used[rName][flavor.Name] = 0
+			if _, exist := c.resourceFlavors[flavor.Name]; exist {
+				flavors.Insert(flavor.Name)
+			}
+		}
+	}
+	qKey := queueKey(q)
+	var counts WorkloadCounts
+	for _, wi := range cq.Workloads {
+		if queueKeyForWorkload(wi.Obj) == qKey {
+			updateWorkloadUsage(used, wi, 1)
+			counts.add(wi)
+		}
+	}
+	usage := make(kueue.UsedResources, len(used))
+	for rName, usedRes := range used {
+		rUsage := make(map[string]kueue.Usage, len(usedRes))
+		for flavor, v := range usedRes {
+			rUsage[flavor] = kueue.Usage{
+				Total: pointer.Quantity(workload.ResourceQuantity(rName, v)),
+			}
+		}
+		usage[rName] = rUsage
+	}
+	return usage, counts, flavors.List()
+}
+
+// InSync returns whether the cache reflects the given ClusterQueues,
+// ResourceFlavors and Workloads, as listed from the API server: it has the
+// same ClusterQueues and ResourceFlavors, and it holds exactly the admitted
+// Workloads at their current resourceVersion, with none of them assumed.
+// It allows tests to wait for the event handlers to catch up.
+func (c *Cache) InSync(cqs []kueue.ClusterQueue, flavors []kueue.ResourceFlavor, wls []kueue.Workload) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if len(c.assumedWorkloads) > 0 || len(cqs) != len(c.clusterQueues) || len(flavors) != len(c.resourceFlavors) {
+		return false
+	}
+	for i := range cqs {
+		if _, ok := c.clusterQueues[cqs[i].Name]; !ok {
+			return false
+		}
+	}
+	for i := range flavors {
+		if _, ok := c.resourceFlavors[flavors[i].Name]; !ok {
+			return false
+		}
+	}
+	admitted := 0
+	for i := range wls {
+		w := &wls[i]
+		if w.Spec.Admission == nil || workload.InCondition(w, kueue.WorkloadFinished) {
+			continue
+		}
+		cq, ok := c.clusterQueues[string(w.Spec.Admission.ClusterQueue)]
+		if !ok {
+			continue
+		}
+		info, ok := cq.Workloads[workload.Key(w)]
+		if !ok || info.Obj.ResourceVersion != w.ResourceVersion {
+			return false
+		}
+		admitted++
+	}
+	for _, cq := range c.clusterQueues {
+		admitted -= len(cq.Workloads)
+	}
+	return admitted == 0
+}
+
+// Dump returns the keys of the workloads admitted in each ClusterQueue, or
+// nil if there are none.
+func (c *Cache) Dump() map[string]sets.String {
+	c.RLock()
+	defer c.RUnlock()
+	dump := make(map[string]sets.String)
+	for name, cq := range c.clusterQueues {
+		if len(cq.Workloads) == 0 {
+			continue
+		}
+		elements := make(sets.String, len(cq.Workloads))
+		for key := range cq.Workloads {
+			elements.Insert(key)
+		}
+		dump[name] = elements
+	}
+	if len(dump) == 0 {
+		return nil
+	}
+	return dump
+}
+
+// DumpAssumed returns the ClusterQueue of each assumed workload, keyed by
+// the workload key.
+func (c *Cache) DumpAssumed() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+	dump := make(map[string]string, len(c.assumedWorkloads))
+	for k, v := range c.assumedWorkloads {
+		dump[k] = v
+	}
+	return dump
 }
 
 func (c *Cache) cleanupAssumedState(w *kueue.Workload) {
@@ -460,35 +1211,167 @@ func (c *Cache) deleteClusterQueueFromCohort(cq *ClusterQueue) {
 		return
 	}
 	delete(cq.Cohort.members, cq)
-	if len(cq.Cohort.members) == 0 {
+	if len(cq.Cohort.members) == 0 && !cq.Cohort.hasObject {
 		delete(c.cohorts, cq.Cohort.Name)
 	}
 	cq.Cohort = nil
 }
 
-func resourceLimitsByName(in []kueue.Resource) map[corev1.ResourceName][]FlavorLimits {
-	out := make(map[corev1.ResourceName][]FlavorLimits, len(in))
-	for _, r := range in {
-		flavors := make([]FlavorLimits, len(r.Flavors))
-		for i := range flavors {
-			f := &r.Flavors[i]
-			fLimits := FlavorLimits{
-				Name: string(f.Name),
-				Min:  workload.ResourceValue(r.Name, f.Quota.Min),
+func resourceLimitsByName(in []kueue.ResourceGroup) ([]ResourceGroup, map[corev1.ResourceName][]FlavorLimits) {
+	groups := make([]ResourceGroup, len(in))
+	out := make(map[corev1.ResourceName][]FlavorLimits)
+	for i := range in {
+		rg := &in[i]
+		groups[i].CoveredResources = append([]corev1.ResourceName(nil), rg.CoveredResources...)
+		groups[i].Flavors = make([]string, len(rg.Flavors))
+		for j := range rg.Flavors {
+			groups[i].Flavors[j] = string(rg.Flavors[j].Name)
+		}
+		for _, name := range rg.CoveredResources {
+			// Each flavor has quotas for every covered resource, so that the
+			// flavors of a resource follow the flavors of the group. A flavor
+			// missing a quota, which the webhook rejects, has no quota.
+			flavors := make([]FlavorLimits, len(rg.Flavors))
+			for j := range rg.Flavors {
+				flavors[j].Name = string(rg.Flavors[j].Name)
+				for k := range rg.Flavors[j].Resources {
+					if q := &rg.Flavors[j].Resources[k]; q.Name == name {
+						flavors[j] = flavorLimits(flavors[j].Name, q)
+						break
+					}
+				}
 			}
-			if f.Quota.Max != nil {
-				fLimits.Max = pointer.Int64(workload.ResourceValue(r.Name, *f.Quota.Max))
+			out[name] = flavors
+		}
+	}
+	return groups, out
+}
+
+// scheduledResourceGroups returns the resource groups of the ClusterQueue
+// with the nominal quotas overridden by the quota schedules active at the
+// given time, the names of those schedules, and when they can change next.
+// When several active schedules override the same quota, the first one
+// wins.
+func scheduledResourceGroups(in *kueue.ClusterQueue, now time.Time) ([]kueue.ResourceGroup, []string, time.Time) {
+	type quotaKey struct {
+		flavor   kueue.ResourceFlavorReference
+		resource corev1.ResourceName
+	}
+	groups := in.Spec.ResourceGroups
+	var active []string
+	var next time.Time
+	overridden := make(map[quotaKey]bool)
+	for i := range in.Spec.QuotaSchedules {
+		qs := &in.Spec.QuotaSchedules[i]
+		schedule, err := cron.Parse(qs.Schedule)
+		if err != nil {
+			// The webhook rejects invalid schedules.
+			continue
+		}
+		inWindow, change := schedule.Window(now, time.Duration(qs.DurationSeconds)*time.Second)
+		if !change.IsZero() && (next.IsZero() || change.Before(next)) {
+			next = change
+		}
+		if !inWindow {
+			continue
+		}
+		if active == nil {
+			groups = make([]kueue.ResourceGroup, len(in.Spec.ResourceGroups))
+			for j := range in.Spec.ResourceGroups {
+				in.Spec.ResourceGroups[j].DeepCopyInto(&groups[j])
 			}
-			flavors[i] = fLimits
+		}
+		active = append(active, qs.Name)
+		for _, sq := range qs.Quotas {
+			key := quotaKey{flavor: sq.Flavor, resource: sq.Resource}
+			if overridden[key] {
+				continue
+			}
+			overridden[key] = true
+			for j := range groups {
+				for k := range groups[j].Flavors {
+					if groups[j].Flavors[k].Name != sq.Flavor {
+						continue
+					}
+					for l := range groups[j].Flavors[k].Resources {
+						if q := &groups[j].Flavors[k].Resources[l]; q.Name == sq.Resource {
+							q.NominalQuota = sq.NominalQuota
+						}
+					}
+				}
+			}
+		}
+	}
+	return groups, active, next
+}
 
+func flavorLimits(flavor string, q *kueue.ResourceQuota) FlavorLimits {
+	fLimits := FlavorLimits{
+		Name:    flavor,
+		Nominal: overcommit(quotaValue(q.Name, q.NominalQuota), q.OvercommitPercentage),
+	}
+	if q.BorrowingLimit != nil {
+		fLimits.BorrowingLimit = pointer.Int64(overcommit(quotaValue(q.Name, *q.BorrowingLimit), q.OvercommitPercentage))
+	}
+	if q.LendingLimit != nil {
+		fLimits.LendingLimit = pointer.Int64(overcommit(quotaValue(q.Name, *q.LendingLimit), q.OvercommitPercentage))
+	}
+	return fLimits
+}
+
+func cohortLimits(in []kueue.CohortResource) Resources {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(Resources, len(in))
+	for _, r := range in {
+		flavors := make(map[string]int64, len(r.Flavors))
+		for _, f := range r.Flavors {
+			flavors[string(f.Name)] = quotaValue(r.Name, f.Limit)
 		}
 		out[r.Name] = flavors
 	}
 	return out
 }
 
+// quotaValue returns the integer value of a quota, rounded up to the units in
+// which the resource is tracked, like the webhook normalizes it. Negative and
+// oversized quotas, which the webhook rejects, are treated as 0 and
+// kueue.MaxQuota respectively.
+func quotaValue(name corev1.ResourceName, q resource.Quantity) int64 {
+	if q.Sign() < 0 {
+		return 0
+	}
+	unitScale := resource.Scale(0)
+	if name == corev1.ResourceCPU {
+		unitScale = resource.Milli
+	}
+	if q.Cmp(*resource.NewScaledQuantity(kueue.MaxQuota, unitScale)) > 0 {
+		return kueue.MaxQuota
+	}
+	return workload.ResourceValue(name, q)
+}
+
+// overcommit returns the quota v scaled by the given percentage. A
+// percentage of zero, as in objects that weren't defaulted, means no
+// overcommitment.
+func overcommit(v int64, percentage int32) int64 {
+	if percentage <= 100 {
+		return v
+	}
+	return v * int64(percentage) / 100
+}
+
+func queueKey(q *kueue.Queue) string {
+	return fmt.Sprintf("%s/%s", q.Namespace, q.Name)
+}
+
+func queueKeyForWorkload(w *kueue.Workload) string {
+	return fmt.Sprintf("%s/%s", w.Namespace, w.Spec.QueueName)
+}
+
 func SetupIndexes(indexer client.FieldIndexer) error {
-	return indexer.IndexField(context.Background(), &kueue.Workload{}, workloadClusterQueueKey, func(o client.Object) []string {
+	return indexer.IndexField(context.Background(), &kueue.Workload{}, WorkloadClusterQueueKey, func(o client.Object) []string {
 		wl := o.(*kueue.Workload)
 		if wl.Spec.Admission == nil {
 			return nil
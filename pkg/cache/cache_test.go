@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -29,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
@@ -42,15 +44,16 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 		{
 			ObjectMeta: metav1.ObjectMeta{Name: "a"},
 			Spec: kueue.ClusterQueueSpec{
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{{
 							Name: "default",
-							Quota: kueue.Quota{
-								Min: resource.MustParse("10"),
-								Max: pointer.Quantity(resource.MustParse("20")),
-							},
+							Resources: []kueue.ResourceQuota{{
+								Name:           corev1.ResourceCPU,
+								NominalQuota:   resource.MustParse("10"),
+								BorrowingLimit: pointer.Quantity(resource.MustParse("10")),
+							}},
 						}},
 					},
 				},
@@ -60,14 +63,15 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 		{
 			ObjectMeta: metav1.ObjectMeta{Name: "b"},
 			Spec: kueue.ClusterQueueSpec{
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{{
 							Name: "default",
-							Quota: kueue.Quota{
-								Min: resource.MustParse("15"),
-							},
+							Resources: []kueue.ResourceQuota{{
+								Name:         corev1.ResourceCPU,
+								NominalQuota: resource.MustParse("15"),
+							}},
 						}},
 					},
 				},
@@ -111,8 +115,12 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 			wantClusterQueues: map[string]*ClusterQueue{
 				"a": {
 					Name: "a",
+					ResourceGroups: []ResourceGroup{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors:          []string{"default"},
+					}},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{
-						corev1.ResourceCPU: {{Name: "default", Min: 10000, Max: pointer.Int64(20000)}},
+						corev1.ResourceCPU: {{Name: "default", Nominal: 10000, BorrowingLimit: pointer.Int64(10000)}},
 					},
 					NamespaceSelector: labels.Nothing(),
 					LabelKeys:         map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType")},
@@ -120,8 +128,12 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 				},
 				"b": {
 					Name: "b",
+					ResourceGroups: []ResourceGroup{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors:          []string{"default"},
+					}},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{
-						corev1.ResourceCPU: {{Name: "default", Min: 15000}},
+						corev1.ResourceCPU: {{Name: "default", Nominal: 15000}},
 					},
 					NamespaceSelector: labels.Nothing(),
 					UsedResources:     Resources{corev1.ResourceCPU: {"default": 0}},
@@ -129,12 +141,14 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 				},
 				"c": {
 					Name:                 "c",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Nothing(),
 					UsedResources:        Resources{},
 				},
 				"d": {
 					Name:                 "d",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Nothing(),
 					UsedResources:        Resources{},
@@ -161,8 +175,12 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 			wantClusterQueues: map[string]*ClusterQueue{
 				"a": {
 					Name: "a",
+					ResourceGroups: []ResourceGroup{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors:          []string{"default"},
+					}},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{
-						corev1.ResourceCPU: {{Name: "default", Min: 10000, Max: pointer.Int64(20000)}},
+						corev1.ResourceCPU: {{Name: "default", Nominal: 10000, BorrowingLimit: pointer.Int64(10000)}},
 					},
 					NamespaceSelector: labels.Nothing(),
 					LabelKeys:         map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType")},
@@ -170,8 +188,12 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 				},
 				"b": {
 					Name: "b",
+					ResourceGroups: []ResourceGroup{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors:          []string{"default"},
+					}},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{
-						corev1.ResourceCPU: {{Name: "default", Min: 15000}},
+						corev1.ResourceCPU: {{Name: "default", Nominal: 15000}},
 					},
 					NamespaceSelector: labels.Nothing(),
 					UsedResources:     Resources{corev1.ResourceCPU: {"default": 0}},
@@ -179,12 +201,14 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 				},
 				"c": {
 					Name:                 "c",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Nothing(),
 					UsedResources:        Resources{},
 				},
 				"d": {
 					Name:                 "d",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Nothing(),
 					UsedResources:        Resources{},
@@ -203,20 +227,25 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 					{
 						ObjectMeta: metav1.ObjectMeta{Name: "a"},
 						Spec: kueue.ClusterQueueSpec{
-							Resources: []kueue.Resource{
+							ResourceGroups: []kueue.ResourceGroup{
 								{
-									Name: corev1.ResourceCPU,
-									Flavors: []kueue.Flavor{
+									CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+									Flavors: []kueue.FlavorQuotas{
 										{
 											Name: "default",
-											Quota: kueue.Quota{
-												Min: resource.MustParse("5"),
-												Max: pointer.Quantity(resource.MustParse("10")),
-											},
+											Resources: []kueue.ResourceQuota{{
+												Name:                 corev1.ResourceCPU,
+												NominalQuota:         resource.MustParse("5"),
+												BorrowingLimit:       pointer.Quantity(resource.MustParse("5")),
+												LendingLimit:         pointer.Quantity(resource.MustParse("2")),
+												OvercommitPercentage: 150,
+											}},
 										},
 									},
+									FlavorAssignment: kueue.SameFlavor,
 								}},
-							Cohort: "two",
+							Cohort:               "two",
+							MinBorrowingPriority: pointer.Int32(100),
 						},
 					},
 					{
@@ -241,27 +270,36 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 			wantClusterQueues: map[string]*ClusterQueue{
 				"a": {
 					Name: "a",
+					ResourceGroups: []ResourceGroup{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors:          []string{"default"},
+					}},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{
-						corev1.ResourceCPU: {{Name: "default", Min: 5000, Max: pointer.Int64(10000)}},
+						corev1.ResourceCPU: {{Name: "default", Nominal: 7500, BorrowingLimit: pointer.Int64(7500), LendingLimit: pointer.Int64(3000)}},
 					},
-					NamespaceSelector: labels.Nothing(),
-					LabelKeys:         map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType", "region")},
-					UsedResources:     Resources{corev1.ResourceCPU: {"default": 0}},
+					NamespaceSelector:    labels.Nothing(),
+					LabelKeys:            map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType", "region")},
+					UsedResources:        Resources{corev1.ResourceCPU: {"default": 0}},
+					SameFlavorResources:  sets.NewString(string(corev1.ResourceCPU)),
+					MinBorrowingPriority: pointer.Int32(100),
 				},
 				"b": {
 					Name:                 "b",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Everything(),
 					UsedResources:        Resources{},
 				},
 				"c": {
 					Name:                 "c",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Nothing(),
 					UsedResources:        Resources{},
 				},
 				"d": {
 					Name:                 "d",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Nothing(),
 					UsedResources:        Resources{},
@@ -287,8 +325,12 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 			wantClusterQueues: map[string]*ClusterQueue{
 				"b": {
 					Name: "b",
+					ResourceGroups: []ResourceGroup{{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors:          []string{"default"},
+					}},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{
-						corev1.ResourceCPU: {{Name: "default", Min: 15000}},
+						corev1.ResourceCPU: {{Name: "default", Nominal: 15000}},
 					},
 					NamespaceSelector: labels.Nothing(),
 					UsedResources:     Resources{corev1.ResourceCPU: {"default": 0}},
@@ -296,6 +338,7 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 				},
 				"c": {
 					Name:                 "c",
+					ResourceGroups:       []ResourceGroup{},
 					RequestableResources: map[corev1.ResourceName][]FlavorLimits{},
 					NamespaceSelector:    labels.Nothing(),
 					UsedResources:        Resources{},
@@ -312,7 +355,7 @@ func TestCacheClusterQueueOperations(t *testing.T) {
 			cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
 			tc.operation(cache)
 			if diff := cmp.Diff(tc.wantClusterQueues, cache.clusterQueues,
-				cmpopts.IgnoreFields(ClusterQueue{}, "Cohort", "Workloads")); diff != "" {
+				cmpopts.IgnoreFields(ClusterQueue{}, "Cohort", "Workloads", "ReservingWorkloadsPerQueue"), cmpopts.IgnoreUnexported(ClusterQueue{})); diff != "" {
 				t.Errorf("Unexpected clusterQueues (-want,+got):\n%s", diff)
 			}
 
@@ -336,10 +379,10 @@ func TestCacheWorkloadOperations(t *testing.T) {
 		{
 			ObjectMeta: metav1.ObjectMeta{Name: "one"},
 			Spec: kueue.ClusterQueueSpec{
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: "cpu",
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{"cpu"},
+						Flavors: []kueue.FlavorQuotas{
 							{Name: "on-demand"},
 							{Name: "spot"},
 						},
@@ -350,10 +393,10 @@ func TestCacheWorkloadOperations(t *testing.T) {
 		{
 			ObjectMeta: metav1.ObjectMeta{Name: "two"},
 			Spec: kueue.ClusterQueueSpec{
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: "cpu",
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{"cpu"},
+						Flavors: []kueue.FlavorQuotas{
 							{Name: "on-demand"},
 							{Name: "spot"},
 						},
@@ -796,35 +839,38 @@ func TestClusterQueueUsage(t *testing.T) {
 	cq := kueue.ClusterQueue{
 		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
 		Spec: kueue.ClusterQueueSpec{
-			Resources: []kueue.Resource{
+			ResourceGroups: []kueue.ResourceGroup{
 				{
-					Name: corev1.ResourceCPU,
-					Flavors: []kueue.Flavor{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+					Flavors: []kueue.FlavorQuotas{
 						{
 							Name: "default",
-							Quota: kueue.Quota{
-								Min: resource.MustParse("10"),
-								Max: pointer.Quantity(resource.MustParse("20")),
-							},
+							Resources: []kueue.ResourceQuota{{
+								Name:           corev1.ResourceCPU,
+								NominalQuota:   resource.MustParse("10"),
+								BorrowingLimit: pointer.Quantity(resource.MustParse("10")),
+							}},
 						},
 					},
 				},
 				{
-					Name: "example.com/gpu",
-					Flavors: []kueue.Flavor{
+					CoveredResources: []corev1.ResourceName{"example.com/gpu"},
+					Flavors: []kueue.FlavorQuotas{
 						{
 							Name: "model_a",
-							Quota: kueue.Quota{
-								Min: resource.MustParse("5"),
-								Max: pointer.Quantity(resource.MustParse("10")),
-							},
+							Resources: []kueue.ResourceQuota{{
+								Name:           "example.com/gpu",
+								NominalQuota:   resource.MustParse("5"),
+								BorrowingLimit: pointer.Quantity(resource.MustParse("5")),
+							}},
 						},
 						{
 							Name: "model_b",
-							Quota: kueue.Quota{
-								Min: resource.MustParse("5"),
-								// No max.
-							},
+							Resources: []kueue.ResourceQuota{{
+								Name:         "example.com/gpu",
+								NominalQuota: resource.MustParse("5"),
+								// No borrowing limit.
+							}},
 						},
 					},
 				},
@@ -887,10 +933,50 @@ func TestClusterQueueUsage(t *testing.T) {
 			},
 		},
 	}
+	splitWorkload := kueue.Workload{
+		ObjectMeta: metav1.ObjectMeta{Name: "split"},
+		Spec: kueue.WorkloadSpec{
+			PodSets: []kueue.PodSet{
+				{
+					Name:  "main",
+					Count: 4,
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "1",
+						"example.com/gpu":  "2",
+					}),
+					SplitAcrossFlavors: true,
+				},
+			},
+			Admission: &kueue.Admission{
+				ClusterQueue: "foo",
+				PodSetFlavors: []kueue.PodSetFlavors{
+					{
+						Name: "main",
+						Splits: []kueue.PodSetSplit{
+							{
+								Count: 1,
+								Flavors: map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "default",
+									"example.com/gpu":  "model_a",
+								},
+							},
+							{
+								Count: 3,
+								Flavors: map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "default",
+									"example.com/gpu":  "model_b",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
 	cases := map[string]struct {
 		workloads         []kueue.Workload
 		wantUsedResources kueue.UsedResources
-		wantWorkloads     int
+		wantWorkloads     WorkloadCounts
 	}{
 		"single no borrowing": {
 			workloads: workloads[:1],
@@ -909,7 +995,7 @@ func TestClusterQueueUsage(t *testing.T) {
 					},
 				},
 			},
-			wantWorkloads: 1,
+			wantWorkloads: WorkloadCounts{Reserving: 1, Admitted: 1},
 		},
 		"multiple borrowing": {
 			workloads: workloads,
@@ -930,7 +1016,51 @@ func TestClusterQueueUsage(t *testing.T) {
 					},
 				},
 			},
-			wantWorkloads: 2,
+			wantWorkloads: WorkloadCounts{Reserving: 2, Admitted: 2},
+		},
+		"split across flavors": {
+			workloads: []kueue.Workload{splitWorkload},
+			wantUsedResources: kueue.UsedResources{
+				corev1.ResourceCPU: {
+					"default": kueue.Usage{
+						Total: pointer.Quantity(resource.MustParse("4")),
+					},
+				},
+				"example.com/gpu": {
+					"model_a": kueue.Usage{
+						Total: pointer.Quantity(resource.MustParse("2")),
+					},
+					"model_b": kueue.Usage{
+						Total:    pointer.Quantity(resource.MustParse("6")),
+						Borrowed: pointer.Quantity(resource.MustParse("1")),
+					},
+				},
+			},
+			wantWorkloads: WorkloadCounts{Reserving: 1, Admitted: 1},
+		},
+		"admission checks pending": {
+			workloads: []kueue.Workload{
+				*utiltesting.MakeWorkload("one", "").Request(corev1.ResourceCPU, "2").
+					Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, "default").AdmissionChecks("budget").Obj()).Obj(),
+				*utiltesting.MakeWorkload("two", "").Request(corev1.ResourceCPU, "3").
+					Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+			},
+			wantUsedResources: kueue.UsedResources{
+				corev1.ResourceCPU: {
+					"default": kueue.Usage{
+						Total: pointer.Quantity(resource.MustParse("5")),
+					},
+				},
+				"example.com/gpu": {
+					"model_a": kueue.Usage{
+						Total: pointer.Quantity(resource.MustParse("0")),
+					},
+					"model_b": kueue.Usage{
+						Total: pointer.Quantity(resource.MustParse("0")),
+					},
+				},
+			},
+			wantWorkloads: WorkloadCounts{Reserving: 2, Admitted: 1},
 		},
 	}
 	for name, tc := range cases {
@@ -945,9 +1075,10 @@ func TestClusterQueueUsage(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Adding ClusterQueue: %v", err)
 			}
-			for _, w := range tc.workloads {
-				if added := cache.AddOrUpdateWorkload(&w); !added {
-					t.Fatalf("Workload %s was not added", workload.Key(&w))
+			for i := range tc.workloads {
+				w := &tc.workloads[i]
+				if added := cache.AddOrUpdateWorkload(w); !added {
+					t.Fatalf("Workload %s was not added", workload.Key(w))
 				}
 			}
 			resources, workloads, err := cache.Usage(&cq)
@@ -958,7 +1089,386 @@ func TestClusterQueueUsage(t *testing.T) {
 				t.Errorf("Unexpected used resources (-want,+got):\n%s", diff)
 			}
 			if workloads != tc.wantWorkloads {
-				t.Errorf("Got %d workloads, want %d", workloads, tc.wantWorkloads)
+				t.Errorf("Got workloads %+v, want %+v", workloads, tc.wantWorkloads)
+			}
+		})
+	}
+}
+
+func TestQueueUsage(t *testing.T) {
+	cq := utiltesting.MakeClusterQueue("foo").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).
+			Flavor(utiltesting.MakeFlavor("missing", "10").Obj()).
+			Obj()).
+		Obj()
+	queue := utiltesting.MakeQueue("test", "ns").ClusterQueue("foo").Obj()
+	workloads := []kueue.Workload{
+		*utiltesting.MakeWorkload("one", "ns").Queue("test").Request(corev1.ResourceCPU, "3").
+			Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+		*utiltesting.MakeWorkload("two", "ns").Queue("test").Request(corev1.ResourceCPU, "2").
+			Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+		*utiltesting.MakeWorkload("other", "ns").Queue("other").Request(corev1.ResourceCPU, "4").
+			Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+	}
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	cache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("default").Obj())
+	ctx := context.Background()
+	if err := cache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Adding ClusterQueue: %v", err)
+	}
+	for i := range workloads {
+		if added := cache.AddOrUpdateWorkload(&workloads[i]); !added {
+			t.Fatalf("Workload %s was not added", workload.Key(&workloads[i]))
+		}
+	}
+	resources, counts, flavors := cache.QueueUsage(queue)
+	wantResources := kueue.UsedResources{
+		corev1.ResourceCPU: {
+			"default": kueue.Usage{
+				Total: pointer.Quantity(resource.MustParse("5")),
+			},
+			"missing": kueue.Usage{
+				Total: pointer.Quantity(resource.MustParse("0")),
+			},
+		},
+	}
+	if diff := cmp.Diff(wantResources, resources); diff != "" {
+		t.Errorf("Unexpected used resources (-want,+got):\n%s", diff)
+	}
+	if want := (WorkloadCounts{Reserving: 2, Admitted: 2}); counts != want {
+		t.Errorf("Got workloads %+v, want %+v", counts, want)
+	}
+	if diff := cmp.Diff([]string{"default"}, flavors); diff != "" {
+		t.Errorf("Unexpected flavors (-want,+got):\n%s", diff)
+	}
+
+	orphan := utiltesting.MakeQueue("orphan", "ns").ClusterQueue("bar").Obj()
+	if resources, counts, flavors := cache.QueueUsage(orphan); resources != nil || counts != (WorkloadCounts{}) || flavors != nil {
+		t.Errorf("Got usage %v, workloads %+v and flavors %v for a queue without ClusterQueue, want none", resources, counts, flavors)
+	}
+}
+
+func TestClusterQueueMissingFlavors(t *testing.T) {
+	cq := utiltesting.MakeClusterQueue("foo").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("on-demand", "10").Obj()).
+			Flavor(utiltesting.MakeFlavor("spot", "10").Obj()).
+			Obj()).
+		Obj()
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	if err := cache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Adding ClusterQueue: %v", err)
+	}
+	check := func(step string, wantMissing []string) {
+		t.Helper()
+		if diff := cmp.Diff(wantMissing, cache.ClusterQueueMissingFlavors("foo")); diff != "" {
+			t.Errorf("Unexpected missing flavors %s (-want,+got):\n%s", step, diff)
+		}
+		if got, want := cache.ClusterQueueActive("foo"), len(wantMissing) == 0; got != want {
+			t.Errorf("ClusterQueueActive() = %t %s, want %t", got, step, want)
+		}
+	}
+	check("without flavors", []string{"on-demand", "spot"})
+
+	cache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("spot").Obj())
+	check("after adding spot", []string{"on-demand"})
+
+	cache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("on-demand").Obj())
+	check("after adding on-demand", nil)
+
+	cache.DeleteResourceFlavor(utiltesting.MakeResourceFlavor("spot").Obj())
+	check("after deleting spot", []string{"spot"})
+
+	if diff := cmp.Diff([]string{"foo"}, cache.ClusterQueuesUsingFlavor("spot")); diff != "" {
+		t.Errorf("Unexpected ClusterQueues using spot (-want,+got):\n%s", diff)
+	}
+	if got := cache.ClusterQueuesUsingFlavor("other"); got != nil {
+		t.Errorf("Got ClusterQueues %v using an unreferenced flavor, want none", got)
+	}
+	if !cache.ClusterQueueActive("bar") {
+		t.Error("Got an untracked ClusterQueue reported as inactive")
+	}
+}
+
+func TestClusterQueueNamespaceQuotas(t *testing.T) {
+	cq := utiltesting.MakeClusterQueue("foo").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("on-demand", "10").Obj()).
+			Flavor(utiltesting.MakeFlavor("spot", "10").Obj()).
+			Obj()).
+		NamespaceQuota(corev1.ResourceCPU, "4").
+		Obj()
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	if err := cache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Adding ClusterQueue: %v", err)
+	}
+	admitted := []*kueue.Workload{
+		utiltesting.MakeWorkload("a", "ns1").Request(corev1.ResourceCPU, "2").
+			Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, "on-demand").Obj()).Obj(),
+		utiltesting.MakeWorkload("b", "ns1").Request(corev1.ResourceCPU, "1").
+			Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, "spot").Obj()).Obj(),
+	}
+	for _, w := range admitted {
+		cache.AddOrUpdateWorkload(w)
+	}
+	pending := utiltesting.MakeWorkload("c", "ns1").Request(corev1.ResourceCPU, "2").Obj()
+	otherNs := utiltesting.MakeWorkload("c", "ns2").Request(corev1.ResourceCPU, "2").Obj()
+
+	snapshot := cache.Snapshot()
+	if diff := cmp.Diff(map[string]workload.Requests{"ns1": {corev1.ResourceCPU: 3_000}}, snapshot.ClusterQueues["foo"].NamespaceUsage); diff != "" {
+		t.Errorf("Unexpected namespace usage (-want,+got):\n%s", diff)
+	}
+	wantErr := "namespace ns1 uses 3 of its quota of 4 for cpu"
+	if err := snapshot.ClusterQueues["foo"].FitsInNamespaceQuotas(workload.NewInfo(pending)); messageOrEmpty(err) != wantErr {
+		t.Errorf("FitsInNamespaceQuotas() = %v, want %q", err, wantErr)
+	}
+	if err := snapshot.ClusterQueues["foo"].FitsInNamespaceQuotas(workload.NewInfo(otherNs)); err != nil {
+		t.Errorf("FitsInNamespaceQuotas() for another namespace = %v, want nil", err)
+	}
+
+	if err := cache.DeleteWorkload(admitted[1]); err != nil {
+		t.Fatalf("Deleting workload: %v", err)
+	}
+	snapshot = cache.Snapshot()
+	if err := snapshot.ClusterQueues["foo"].FitsInNamespaceQuotas(workload.NewInfo(pending)); err != nil {
+		t.Errorf("FitsInNamespaceQuotas() after deleting a workload = %v, want nil", err)
+	}
+}
+
+func TestClusterQueueQuotaSchedules(t *testing.T) {
+	cq := utiltesting.MakeClusterQueue("foo").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).
+			Obj()).
+		QuotaSchedule("night", "0 22 * * *", 8*3600,
+			kueue.ScheduledQuota{Flavor: "default", Resource: corev1.ResourceCPU, NominalQuota: resource.MustParse("20")}).
+		QuotaSchedule("midnight", "0 0 * * *", 3600,
+			kueue.ScheduledQuota{Flavor: "default", Resource: corev1.ResourceCPU, NominalQuota: resource.MustParse("15")}).
+		Obj()
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	if err := cache.AddClusterQueue(context.Background(), cq); err != nil {
+		t.Fatalf("Adding ClusterQueue: %v", err)
+	}
+	steps := []struct {
+		at          string
+		wantChanged bool
+		wantActive  []string
+		wantNominal int64
+		wantNext    string
+	}{
+		{
+			at:          "2022-03-01T21:00:00Z",
+			wantNominal: 10_000,
+			wantNext:    "2022-03-01T22:00:00Z",
+		},
+		{
+			at:          "2022-03-01T23:00:00Z",
+			wantChanged: true,
+			wantActive:  []string{"night"},
+			wantNominal: 20_000,
+			wantNext:    "2022-03-02T00:00:00Z",
+		},
+		{
+			at:          "2022-03-02T00:30:00Z",
+			wantChanged: true,
+			wantActive:  []string{"night", "midnight"},
+			wantNominal: 20_000,
+			wantNext:    "2022-03-02T01:00:00Z",
+		},
+		{
+			at:          "2022-03-02T06:00:00Z",
+			wantChanged: true,
+			wantNominal: 10_000,
+			wantNext:    "2022-03-02T22:00:00Z",
+		},
+		{
+			at:          "2022-03-02T07:00:00Z",
+			wantNominal: 10_000,
+			wantNext:    "2022-03-02T22:00:00Z",
+		},
+	}
+	for i, step := range steps {
+		at, err := time.Parse(time.RFC3339, step.at)
+		if err != nil {
+			t.Fatalf("Failed parsing time: %v", err)
+		}
+		changed, next, err := cache.UpdateQuotaSchedules(cq, at)
+		if err != nil {
+			t.Fatalf("Updating quota schedules at %s: %v", step.at, err)
+		}
+		// The first step depends on the time at which the ClusterQueue was
+		// added.
+		if i > 0 && changed != step.wantChanged {
+			t.Errorf("At %s, got changed %t, want %t", step.at, changed, step.wantChanged)
+		}
+		if got := next.Format(time.RFC3339); got != step.wantNext {
+			t.Errorf("At %s, got next change %s, want %s", step.at, got, step.wantNext)
+		}
+		cqImpl := cache.clusterQueues["foo"]
+		if diff := cmp.Diff(step.wantActive, cqImpl.ActiveQuotaSchedules); diff != "" {
+			t.Errorf("At %s, unexpected active quota schedules (-want,+got):\n%s", step.at, diff)
+		}
+		if got := cqImpl.RequestableResources[corev1.ResourceCPU][0].Nominal; got != step.wantNominal {
+			t.Errorf("At %s, got nominal quota %d, want %d", step.at, got, step.wantNominal)
+		}
+	}
+}
+
+func TestQuotaExcessWorkloads(t *testing.T) {
+	now := time.Now()
+	admitted := func(name string, flavor string, priority int32, created time.Time) *utiltesting.WorkloadWrapper {
+		return utiltesting.MakeWorkload(name, "").Request(corev1.ResourceCPU, "2").
+			Priority(priority).
+			Creation(created).
+			Admit(utiltesting.MakeAdmission("foo").Flavor(corev1.ResourceCPU, flavor).Obj())
+	}
+	workloads := []*kueue.Workload{
+		admitted("old", "on-demand", 0, now.Add(-time.Hour)).Obj(),
+		admitted("new", "on-demand", 0, now).Obj(),
+		admitted("high", "on-demand", 10, now).Obj(),
+		admitted("spot", "spot", 0, now.Add(time.Minute)).Obj(),
+	}
+	cases := map[string]struct {
+		cohort         string
+		borrowingLimit string
+		evicted        string
+		want           []string
+	}{
+		"without cohort": {
+			want: []string{"new"},
+		},
+		"workloads being evicted are released": {
+			evicted: "old",
+		},
+		"in a cohort without borrowing limit": {
+			cohort: "one",
+		},
+		"in a cohort with a borrowing limit": {
+			cohort:         "one",
+			borrowingLimit: "1",
+			want:           []string{"new"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			onDemand := utiltesting.MakeFlavor("on-demand", "4")
+			if tc.borrowingLimit != "" {
+				onDemand.BorrowingLimit(tc.borrowingLimit)
+			}
+			cq := utiltesting.MakeClusterQueue("foo").Cohort(tc.cohort).
+				Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+					Flavor(onDemand.Obj()).
+					Flavor(utiltesting.MakeFlavor("spot", "2").Obj()).
+					Obj()).
+				Obj()
+			scheme := runtime.NewScheme()
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+			if err := cache.AddClusterQueue(context.Background(), cq); err != nil {
+				t.Fatalf("Adding ClusterQueue: %v", err)
+			}
+			for _, w := range workloads {
+				w = w.DeepCopy()
+				if w.Name == tc.evicted {
+					w.Status.Conditions = []kueue.WorkloadCondition{{
+						Type:   kueue.WorkloadEvicted,
+						Status: corev1.ConditionTrue,
+					}}
+				}
+				cache.AddOrUpdateWorkload(w)
+			}
+			var got []string
+			for _, wi := range cache.QuotaExcessWorkloads("foo") {
+				got = append(got, wi.Obj.Name)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Unexpected workloads to evict (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateAdmission(t *testing.T) {
+	admitted := func(name, cq, flavor, cpu string) *kueue.Workload {
+		return utiltesting.MakeWorkload(name, "").Request(corev1.ResourceCPU, cpu).
+			Admit(utiltesting.MakeAdmission(cq).Flavor(corev1.ResourceCPU, flavor).Obj()).
+			Obj()
+	}
+	cases := map[string]struct {
+		workload *kueue.Workload
+		wantErr  bool
+	}{
+		"fits": {
+			workload: admitted("new", "foo", "on-demand", "2"),
+		},
+		"ClusterQueue doesn't exist": {
+			workload: admitted("new", "baz", "on-demand", "2"),
+			wantErr:  true,
+		},
+		"inactive ClusterQueue": {
+			workload: admitted("new", "bar", "on-demand", "2"),
+			wantErr:  true,
+		},
+		"flavor not in the ClusterQueue": {
+			workload: admitted("new", "foo", "spot", "2"),
+			wantErr:  true,
+		},
+		"doesn't fit": {
+			workload: admitted("new", "foo", "on-demand", "3"),
+			wantErr:  true,
+		},
+		"replaces the workload with the same key": {
+			workload: admitted("running", "foo", "on-demand", "4"),
+		},
+	}
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	cache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("on-demand").Obj())
+	if err := cache.AddClusterQueue(ctx, utiltesting.MakeClusterQueue("foo").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("on-demand", "4").Obj()).
+			Obj()).
+		Obj()); err != nil {
+		t.Fatalf("Adding ClusterQueue: %v", err)
+	}
+	if err := cache.AddClusterQueue(ctx, utiltesting.MakeClusterQueue("bar").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("missing", "4").Obj()).
+			Obj()).
+		Obj()); err != nil {
+		t.Fatalf("Adding ClusterQueue: %v", err)
+	}
+	cache.AddOrUpdateWorkload(admitted("running", "foo", "on-demand", "2"))
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := cache.ValidateAdmission(tc.workload)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ValidateAdmission() = %v, want error %t", err, tc.wantErr)
 			}
 		})
 	}
@@ -970,3 +1480,256 @@ func messageOrEmpty(err error) string {
 	}
 	return err.Error()
 }
+
+func TestAddClusterQueueListFailure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	admitted := utiltesting.MakeWorkload("a", "").Request(corev1.ResourceCPU, "1").
+		Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()).Obj()
+	cl := utiltesting.NewFaultyClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(admitted).Build())
+	cl.Inject(utiltesting.Fault{
+		Op:    utiltesting.OpList,
+		Err:   utiltesting.TimeoutError(),
+		Times: 1,
+	})
+	cache := New(cl)
+	cq := utiltesting.MakeClusterQueue("cq").Cohort("one").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+		Obj()
+	ctx := context.Background()
+	if err := cache.AddClusterQueue(ctx, cq); err == nil {
+		t.Fatal("Adding clusterQueue succeeded, want the injected error")
+	}
+	if len(cache.clusterQueues) != 0 || len(cache.cohorts) != 0 {
+		t.Fatalf("Cache has %d clusterQueues and %d cohorts after the failure, want none", len(cache.clusterQueues), len(cache.cohorts))
+	}
+
+	if err := cache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Retrying to add clusterQueue: %v", err)
+	}
+	if got := len(cache.clusterQueues["cq"].Workloads); got != 1 {
+		t.Errorf("ClusterQueue has %d workloads, want 1", got)
+	}
+}
+
+func TestCacheInSync(t *testing.T) {
+	cq := *utiltesting.MakeClusterQueue("cq").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+		Obj()
+	flavor := *utiltesting.MakeResourceFlavor("default").Obj()
+	admitted := utiltesting.MakeWorkload("a", "").Request(corev1.ResourceCPU, "1").
+		Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()).Obj()
+	admitted.ResourceVersion = "1"
+	pending := utiltesting.MakeWorkload("b", "").Request(corev1.ResourceCPU, "1").Obj()
+	updated := admitted.DeepCopy()
+	updated.ResourceVersion = "2"
+	finished := admitted.DeepCopy()
+	finished.Status.Conditions = []kueue.WorkloadCondition{{
+		Type:   kueue.WorkloadFinished,
+		Status: corev1.ConditionTrue,
+	}}
+
+	cases := map[string]struct {
+		assume  bool
+		cqs     []kueue.ClusterQueue
+		flavors []kueue.ResourceFlavor
+		wls     []kueue.Workload
+		want    bool
+	}{
+		"in sync": {
+			cqs:     []kueue.ClusterQueue{cq},
+			flavors: []kueue.ResourceFlavor{flavor},
+			wls:     []kueue.Workload{*admitted, *pending},
+			want:    true,
+		},
+		"missing clusterQueue": {
+			cqs:     []kueue.ClusterQueue{cq, *utiltesting.MakeClusterQueue("other").Obj()},
+			flavors: []kueue.ResourceFlavor{flavor},
+			wls:     []kueue.Workload{*admitted},
+		},
+		"deleted flavor": {
+			cqs: []kueue.ClusterQueue{cq},
+			wls: []kueue.Workload{*admitted},
+		},
+		"stale workload": {
+			cqs:     []kueue.ClusterQueue{cq},
+			flavors: []kueue.ResourceFlavor{flavor},
+			wls:     []kueue.Workload{*updated},
+		},
+		"finished workload still in cache": {
+			cqs:     []kueue.ClusterQueue{cq},
+			flavors: []kueue.ResourceFlavor{flavor},
+			wls:     []kueue.Workload{*finished},
+		},
+		"assumed workload": {
+			assume:  true,
+			cqs:     []kueue.ClusterQueue{cq},
+			flavors: []kueue.ResourceFlavor{flavor},
+			wls:     []kueue.Workload{*admitted, *pending},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+			cache.AddOrUpdateResourceFlavor(flavor.DeepCopy())
+			if err := cache.AddClusterQueue(context.Background(), cq.DeepCopy()); err != nil {
+				t.Fatalf("Failed adding ClusterQueue: %v", err)
+			}
+			if !cache.AddOrUpdateWorkload(admitted.DeepCopy()) {
+				t.Fatalf("Failed adding workload")
+			}
+			if tc.assume {
+				assumed := pending.DeepCopy()
+				assumed.Spec.Admission = admitted.Spec.Admission.DeepCopy()
+				if err := cache.AssumeWorkload(assumed); err != nil {
+					t.Fatalf("Failed assuming workload: %v", err)
+				}
+			}
+			if got := cache.InSync(tc.cqs, tc.flavors, tc.wls); got != tc.want {
+				t.Errorf("InSync() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCacheDump(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	if dump := cache.Dump(); dump != nil {
+		t.Errorf("Dump() on an empty cache = %v, want nil", dump)
+	}
+
+	cq := utiltesting.MakeClusterQueue("cq").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+		Obj()
+	if err := cache.AddClusterQueue(context.Background(), cq); err != nil {
+		t.Fatalf("Failed adding ClusterQueue: %v", err)
+	}
+	admission := utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()
+	if !cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("a", "ns").Admit(admission).Obj()) {
+		t.Fatalf("Failed adding workload")
+	}
+	if err := cache.AssumeWorkload(utiltesting.MakeWorkload("b", "ns").Admit(admission).Obj()); err != nil {
+		t.Fatalf("Failed assuming workload: %v", err)
+	}
+
+	wantDump := map[string]sets.String{"cq": sets.NewString("ns/a", "ns/b")}
+	if diff := cmp.Diff(wantDump, cache.Dump()); diff != "" {
+		t.Errorf("Unexpected dump (-want,+got):\n%s", diff)
+	}
+	wantAssumed := map[string]string{"ns/b": "cq"}
+	if diff := cmp.Diff(wantAssumed, cache.DumpAssumed()); diff != "" {
+		t.Errorf("Unexpected assumed workloads (-want,+got):\n%s", diff)
+	}
+}
+
+func TestWaitForPodsReady(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build(), WithPodsReadyTracking(true))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.CleanUpOnContext(ctx)
+
+	cq := utiltesting.MakeClusterQueue("cq").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+		Obj()
+	if err := cache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding ClusterQueue: %v", err)
+	}
+	if !cache.PodsReadyForAllAdmittedWorkloads() {
+		t.Errorf("PodsReadyForAllAdmittedWorkloads() = false without workloads, want true")
+	}
+
+	admission := utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()
+	wl := utiltesting.MakeWorkload("a", "ns").Admit(admission).Obj()
+	if !cache.AddOrUpdateWorkload(wl) {
+		t.Fatalf("Failed adding workload")
+	}
+	if cache.PodsReadyForAllAdmittedWorkloads() {
+		t.Errorf("PodsReadyForAllAdmittedWorkloads() = true with a workload without PodsReady, want false")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cache.WaitForPodsReady(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("WaitForPodsReady returned before the pods were ready")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	readyWl := utiltesting.MakeWorkload("a", "ns").Admit(admission).
+		Condition(kueue.WorkloadPodsReady, corev1.ConditionTrue).Obj()
+	if err := cache.UpdateWorkload(wl, readyWl); err != nil {
+		t.Fatalf("Failed updating workload: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("WaitForPodsReady didn't return after the pods were ready")
+	}
+	if !cache.PodsReadyForAllAdmittedWorkloads() {
+		t.Errorf("PodsReadyForAllAdmittedWorkloads() = false with all the pods ready, want true")
+	}
+
+	reservingWl := utiltesting.MakeWorkload("b", "ns").
+		Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").AdmissionChecks("budget").Obj()).Obj()
+	if !cache.AddOrUpdateWorkload(reservingWl) {
+		t.Fatalf("Failed adding workload")
+	}
+	if !cache.PodsReadyForAllAdmittedWorkloads() {
+		t.Errorf("PodsReadyForAllAdmittedWorkloads() = false with a workload waiting for its AdmissionChecks, want true")
+	}
+}
+
+func TestWaitForPodsReadyCancelled(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build(), WithPodsReadyTracking(true))
+	ctx, cancel := context.WithCancel(context.Background())
+	go cache.CleanUpOnContext(ctx)
+
+	cq := utiltesting.MakeClusterQueue("cq").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+		Obj()
+	if err := cache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding ClusterQueue: %v", err)
+	}
+	admission := utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()
+	if !cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("a", "ns").Admit(admission).Obj()) {
+		t.Fatalf("Failed adding workload")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cache.WaitForPodsReady(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("WaitForPodsReady didn't return after the context was cancelled")
+	}
+}
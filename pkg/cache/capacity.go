@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// ClusterQueueCapacity summarizes the quota of a ClusterQueue, how much of it
+// is used, borrowed and lent, and the demand of its pending workloads, so
+// that components that scale the cluster can size it from the demand.
+type ClusterQueueCapacity struct {
+	Name             string             `json:"name"`
+	Cohort           string             `json:"cohort,omitempty"`
+	PendingWorkloads int                `json:"pendingWorkloads"`
+	Resources        []ResourceCapacity `json:"resources"`
+}
+
+// ResourceCapacity is the capacity of a resource in a ClusterQueue.
+type ResourceCapacity struct {
+	Name corev1.ResourceName `json:"name"`
+	// PendingDemand is the quantity requested by the pending workloads. It's
+	// not split by flavor, as the flavors are assigned on admission.
+	PendingDemand resource.Quantity `json:"pendingDemand"`
+	Flavors       []FlavorCapacity  `json:"flavors"`
+}
+
+// FlavorCapacity is the capacity of a flavor of a resource in a ClusterQueue.
+type FlavorCapacity struct {
+	Name         string            `json:"name"`
+	NominalQuota resource.Quantity `json:"nominalQuota"`
+	Used         resource.Quantity `json:"used"`
+	// Borrowed is the usage past the nominal quota.
+	Borrowed resource.Quantity `json:"borrowed"`
+	// Lent is the unused nominal quota that covers the usage borrowed by
+	// other ClusterQueues in the cohort. As the cohort doesn't record who
+	// lends to whom, the borrowed usage is attributed to the ClusterQueues
+	// with lendable quota in the order of their names.
+	Lent resource.Quantity `json:"lent"`
+}
+
+// ClusterQueuesCapacity returns the capacity of all the ClusterQueues, sorted
+// by name.
+func (c *Cache) ClusterQueuesCapacity() []ClusterQueueCapacity {
+	snap := c.Snapshot()
+	demand, pendingCount := c.pendingDemand()
+	lent := lentQuota(&snap)
+
+	names := make([]string, 0, len(snap.ClusterQueues))
+	for name := range snap.ClusterQueues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	capacities := make([]ClusterQueueCapacity, 0, len(names))
+	for _, name := range names {
+		cq := snap.ClusterQueues[name]
+		cqCapacity := ClusterQueueCapacity{
+			Name:             name,
+			PendingWorkloads: pendingCount[name],
+		}
+		if cq.Cohort != nil {
+			cqCapacity.Cohort = cq.Cohort.Name
+		}
+		resources := make([]string, 0, len(cq.RequestableResources))
+		for rName := range cq.RequestableResources {
+			resources = append(resources, string(rName))
+		}
+		sort.Strings(resources)
+		for _, r := range resources {
+			rName := corev1.ResourceName(r)
+			rCapacity := ResourceCapacity{
+				Name:          rName,
+				PendingDemand: workload.ResourceQuantity(rName, demand[name][rName]),
+			}
+			for _, flavor := range cq.RequestableResources[rName] {
+				used := cq.UsedResources[rName][flavor.Name]
+				var borrowed int64
+				if used > flavor.Nominal {
+					borrowed = used - flavor.Nominal
+				}
+				rCapacity.Flavors = append(rCapacity.Flavors, FlavorCapacity{
+					Name:         flavor.Name,
+					NominalQuota: workload.ResourceQuantity(rName, flavor.Nominal),
+					Used:         workload.ResourceQuantity(rName, used),
+					Borrowed:     workload.ResourceQuantity(rName, borrowed),
+					Lent:         workload.ResourceQuantity(rName, lent[name][rName][flavor.Name]),
+				})
+			}
+			cqCapacity.Resources = append(cqCapacity.Resources, rCapacity)
+		}
+		capacities = append(capacities, cqCapacity)
+	}
+	return capacities
+}
+
+// pendingDemand returns the requests of the pending workloads and their
+// number, by the ClusterQueue of their Queue. The workloads whose Queue is
+// not tracked are ignored.
+func (c *Cache) pendingDemand() (map[string]workload.Requests, map[string]int) {
+	c.RLock()
+	defer c.RUnlock()
+
+	demand := make(map[string]workload.Requests)
+	counts := make(map[string]int)
+	for _, wi := range c.pendingWorkloads {
+		q := c.queues[queueKeyForWorkload(wi.Obj)]
+		if q == nil {
+			continue
+		}
+		requests := demand[q.ClusterQueue]
+		if requests == nil {
+			requests = make(workload.Requests)
+			demand[q.ClusterQueue] = requests
+		}
+		for _, ps := range wi.TotalRequests {
+			for rName, v := range ps.Requests {
+				requests[rName] += v
+			}
+		}
+		counts[q.ClusterQueue]++
+	}
+	return demand, counts
+}
+
+// lentQuota returns the quota that each ClusterQueue of the snapshot lends,
+// by the name of the ClusterQueue. The usage borrowed in each root cohort is
+// covered by the unused lendable quota of its ClusterQueues, in the order of
+// their names.
+func lentQuota(snap *Snapshot) map[string]Resources {
+	membersByRoot := make(map[*Cohort][]*ClusterQueue)
+	for _, cq := range snap.ClusterQueues {
+		if cq.Cohort != nil {
+			root := cq.Cohort.Root()
+			membersByRoot[root] = append(membersByRoot[root], cq)
+		}
+	}
+	lent := make(map[string]Resources, len(snap.ClusterQueues))
+	for _, members := range membersByRoot {
+		sort.Slice(members, func(i, j int) bool {
+			return members[i].Name < members[j].Name
+		})
+		borrowed := make(Resources)
+		for _, cq := range members {
+			for rName, flavors := range cq.RequestableResources {
+				if borrowed[rName] == nil {
+					borrowed[rName] = make(map[string]int64, len(flavors))
+				}
+				for _, flavor := range flavors {
+					if used := cq.UsedResources[rName][flavor.Name]; used > flavor.Nominal {
+						borrowed[rName][flavor.Name] += used - flavor.Nominal
+					}
+				}
+			}
+		}
+		for _, cq := range members {
+			cqLent := make(Resources, len(cq.RequestableResources))
+			for rName, flavors := range cq.RequestableResources {
+				cqLent[rName] = make(map[string]int64, len(flavors))
+				for _, flavor := range flavors {
+					lendable := flavor.Nominal - cq.UsedResources[rName][flavor.Name]
+					if limit := flavor.Nominal - flavor.Guaranteed(); lendable > limit {
+						lendable = limit
+					}
+					v := borrowed[rName][flavor.Name]
+					if v > lendable {
+						v = lendable
+					}
+					if v <= 0 {
+						continue
+					}
+					cqLent[rName][flavor.Name] = v
+					borrowed[rName][flavor.Name] -= v
+				}
+			}
+			lent[cq.Name] = cqLent
+		}
+	}
+	return lent
+}
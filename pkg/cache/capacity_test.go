@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+)
+
+func TestClusterQueuesCapacity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("a").Cohort("cohort").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "10").LendingLimit("3").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("b").Cohort("cohort").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("c").Cohort("cohort").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "6").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("d").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).Obj(),
+	}
+	for _, cq := range clusterQueues {
+		if err := cache.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+	cache.AddOrUpdateQueue(utiltesting.MakeQueue("qa", "ns").ClusterQueue("a").Obj())
+	cache.AddOrUpdateQueue(utiltesting.MakeQueue("qd", "ns").ClusterQueue("d").Obj())
+	admitted := []*kueue.Workload{
+		utiltesting.MakeWorkload("a1", "ns").Request(corev1.ResourceCPU, "2").
+			Admit(utiltesting.MakeAdmission("a").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+		utiltesting.MakeWorkload("b1", "ns").Request(corev1.ResourceCPU, "14").
+			Admit(utiltesting.MakeAdmission("b").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+	}
+	for _, w := range admitted {
+		if !cache.AddOrUpdateWorkload(w) {
+			t.Fatalf("Failed adding workload %s", w.Name)
+		}
+	}
+	pending := []*kueue.Workload{
+		utiltesting.MakeWorkload("p1", "ns").Queue("qa").Request(corev1.ResourceCPU, "3").Obj(),
+		utiltesting.MakeWorkload("p2", "ns").Queue("qa").Request(corev1.ResourceCPU, "1").Obj(),
+		utiltesting.MakeWorkload("p3", "ns").Queue("qa").Request(corev1.ResourceCPU, "8").Obj(),
+		utiltesting.MakeWorkload("inactive", "ns").Queue("qa").Request(corev1.ResourceCPU, "5").Active(false).Obj(),
+		utiltesting.MakeWorkload("missing-queue", "ns").Queue("missing").Request(corev1.ResourceCPU, "5").Obj(),
+		utiltesting.MakeWorkload("d1", "ns").Queue("qd").Request(corev1.ResourceCPU, "2").Obj(),
+	}
+	for _, w := range pending {
+		cache.AddOrUpdatePendingWorkload(w)
+	}
+	cache.DeletePendingWorkload(pending[2])
+
+	want := []ClusterQueueCapacity{
+		{
+			Name:             "a",
+			Cohort:           "cohort",
+			PendingWorkloads: 2,
+			Resources: []ResourceCapacity{{
+				Name:          corev1.ResourceCPU,
+				PendingDemand: resource.MustParse("4"),
+				Flavors: []FlavorCapacity{{
+					Name:         "default",
+					NominalQuota: resource.MustParse("10"),
+					Used:         resource.MustParse("2"),
+					Borrowed:     resource.MustParse("0"),
+					Lent:         resource.MustParse("3"),
+				}},
+			}},
+		},
+		{
+			Name:   "b",
+			Cohort: "cohort",
+			Resources: []ResourceCapacity{{
+				Name:          corev1.ResourceCPU,
+				PendingDemand: resource.MustParse("0"),
+				Flavors: []FlavorCapacity{{
+					Name:         "default",
+					NominalQuota: resource.MustParse("10"),
+					Used:         resource.MustParse("14"),
+					Borrowed:     resource.MustParse("4"),
+					Lent:         resource.MustParse("0"),
+				}},
+			}},
+		},
+		{
+			Name:   "c",
+			Cohort: "cohort",
+			Resources: []ResourceCapacity{{
+				Name:          corev1.ResourceCPU,
+				PendingDemand: resource.MustParse("0"),
+				Flavors: []FlavorCapacity{{
+					Name:         "default",
+					NominalQuota: resource.MustParse("6"),
+					Used:         resource.MustParse("0"),
+					Borrowed:     resource.MustParse("0"),
+					Lent:         resource.MustParse("1"),
+				}},
+			}},
+		},
+		{
+			Name:             "d",
+			PendingWorkloads: 1,
+			Resources: []ResourceCapacity{{
+				Name:          corev1.ResourceCPU,
+				PendingDemand: resource.MustParse("2"),
+				Flavors: []FlavorCapacity{{
+					Name:         "default",
+					NominalQuota: resource.MustParse("4"),
+					Used:         resource.MustParse("0"),
+					Borrowed:     resource.MustParse("0"),
+					Lent:         resource.MustParse("0"),
+				}},
+			}},
+		},
+	}
+	if diff := cmp.Diff(want, cache.ClusterQueuesCapacity()); diff != "" {
+		t.Errorf("Unexpected capacity (-want,+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/pointer"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// ViolationType is the kind of inconsistency found by CheckConsistency.
+type ViolationType string
+
+const (
+	// UnknownClusterQueue means that a workload is admitted by a
+	// ClusterQueue that doesn't exist.
+	UnknownClusterQueue ViolationType = "UnknownClusterQueue"
+	// MissingWorkload means that an admitted workload is not accounted in
+	// the cache.
+	MissingWorkload ViolationType = "MissingWorkload"
+	// LeakedWorkload means that the cache accounts for a workload that is
+	// not admitted by the ClusterQueue anymore.
+	LeakedWorkload ViolationType = "LeakedWorkload"
+	// UsageMismatch means that the usage in the cache differs from the sum
+	// of the admissions recorded in the workloads.
+	UsageMismatch ViolationType = "UsageMismatch"
+	// QuotaExceeded means that the admitted workloads use more than the
+	// quota of the ClusterQueue.
+	QuotaExceeded ViolationType = "QuotaExceeded"
+	// CohortQuotaExceeded means that the admitted workloads of a root cohort
+	// use more than the sum of the nominal quotas that the ClusterQueues in
+	// its hierarchy lend.
+	CohortQuotaExceeded ViolationType = "CohortQuotaExceeded"
+	// CohortLimitExceeded means that the admitted workloads of a cohort and
+	// its descendants use more than the limit of the cohort.
+	CohortLimitExceeded ViolationType = "CohortLimitExceeded"
+)
+
+// Violation is an inconsistency between the admissions recorded in the
+// workloads, the quotas of the ClusterQueues and the cache.
+type Violation struct {
+	Type         ViolationType       `json:"type"`
+	ClusterQueue string              `json:"clusterQueue,omitempty"`
+	Cohort       string              `json:"cohort,omitempty"`
+	Workload     string              `json:"workload,omitempty"`
+	Resource     corev1.ResourceName `json:"resource,omitempty"`
+	Flavor       string              `json:"flavor,omitempty"`
+	Message      string              `json:"message"`
+}
+
+// CheckConsistency sums the admissions recorded in the Workloads of the
+// cluster and compares them against the quotas of the ClusterQueues and the
+// state of the cache. It's meant for operators recovering from incidents.
+// Workloads that are assumed in the cache are not reported as leaked, as
+// their admission might not be persisted yet.
+func (c *Cache) CheckConsistency(ctx context.Context) ([]Violation, error) {
+	var cqs kueue.ClusterQueueList
+	if err := c.client.List(ctx, &cqs); err != nil {
+		return nil, fmt.Errorf("listing ClusterQueues: %w", err)
+	}
+	var cohortObjs kueue.CohortList
+	if err := c.client.List(ctx, &cohortObjs); err != nil {
+		return nil, fmt.Errorf("listing Cohorts: %w", err)
+	}
+	var wls kueue.WorkloadList
+	if err := c.client.List(ctx, &wls); err != nil {
+		return nil, fmt.Errorf("listing Workloads: %w", err)
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	var violations []Violation
+	// Recompute the usage of the ClusterQueues from the API objects.
+	expected := make(map[string]*ClusterQueue, len(cqs.Items))
+	cohorts := make(map[string][]*ClusterQueue)
+	for i := range cqs.Items {
+		cq, err := c.newClusterQueue(&cqs.Items[i])
+		if err != nil {
+			return nil, fmt.Errorf("processing ClusterQueue %s: %w", cqs.Items[i].Name, err)
+		}
+		expected[cq.Name] = cq
+		if cohort := cqs.Items[i].Spec.Cohort; cohort != "" {
+			cohorts[cohort] = append(cohorts[cohort], cq)
+		}
+	}
+	for i := range wls.Items {
+		w := &wls.Items[i]
+		if w.Spec.Admission == nil || workload.InCondition(w, kueue.WorkloadFinished) {
+			continue
+		}
+		cqName := string(w.Spec.Admission.ClusterQueue)
+		cq := expected[cqName]
+		if cq == nil {
+			violations = append(violations, Violation{
+				Type:         UnknownClusterQueue,
+				ClusterQueue: cqName,
+				Workload:     workload.Key(w),
+				Message:      "Workload is admitted by a ClusterQueue that doesn't exist",
+			})
+			continue
+		}
+		// It can only fail for duplicates, which the API server prevents.
+		_ = cq.addWorkload(w, c.workloadInfoOptions...)
+		if cached := c.clusterQueues[cqName]; cached == nil || cached.Workloads[workload.Key(w)] == nil {
+			violations = append(violations, Violation{
+				Type:         MissingWorkload,
+				ClusterQueue: cqName,
+				Workload:     workload.Key(w),
+				Message:      "Admitted workload is not accounted in the cache",
+			})
+		}
+	}
+
+	for name, cached := range c.clusterQueues {
+		cq := expected[name]
+		for key := range cached.Workloads {
+			if cq != nil && cq.Workloads[key] != nil {
+				continue
+			}
+			if _, assumed := c.assumedWorkloads[key]; assumed {
+				continue
+			}
+			violations = append(violations, Violation{
+				Type:         LeakedWorkload,
+				ClusterQueue: name,
+				Workload:     key,
+				Message:      "Cache accounts for a workload that is not admitted by the ClusterQueue",
+			})
+		}
+	}
+
+	inCohort := make(map[*ClusterQueue]bool)
+	for _, members := range cohorts {
+		for _, cq := range members {
+			inCohort[cq] = true
+		}
+	}
+	for name, cq := range expected {
+		cached := c.clusterQueues[name]
+		for rName, flavors := range cq.RequestableResources {
+			for _, flavor := range flavors {
+				used := cq.UsedResources[rName][flavor.Name]
+				if cached != nil {
+					if cachedUsed := cached.UsedResources[rName][flavor.Name]; cachedUsed != used {
+						violations = append(violations, Violation{
+							Type:         UsageMismatch,
+							ClusterQueue: name,
+							Resource:     rName,
+							Flavor:       flavor.Name,
+							Message: fmt.Sprintf("Cache usage %s differs from the admitted usage %s",
+								quantityString(rName, cachedUsed), quantityString(rName, used)),
+						})
+					}
+				}
+				var limit *int64
+				if !inCohort[cq] {
+					// Without a cohort, there is nothing to borrow.
+					limit = &flavor.Nominal
+				} else if flavor.BorrowingLimit != nil {
+					limit = pointer.Int64(flavor.Nominal + *flavor.BorrowingLimit)
+				}
+				if limit != nil && used > *limit {
+					violations = append(violations, Violation{
+						Type:         QuotaExceeded,
+						ClusterQueue: name,
+						Resource:     rName,
+						Flavor:       flavor.Name,
+						Message: fmt.Sprintf("Admitted usage %s exceeds the quota %s",
+							quantityString(rName, used), quantityString(rName, *limit)),
+					})
+				}
+			}
+		}
+	}
+
+	// Recompute the usage of the cohort hierarchies.
+	hierarchy := make(map[string]*Cohort, len(cohorts)+len(cohortObjs.Items))
+	for i := range cohortObjs.Items {
+		co := &cohortObjs.Items[i]
+		cohort := newCohort(co.Name, 0)
+		cohort.parentName = co.Spec.Parent
+		cohort.Limits = cohortLimits(co.Spec.Resources)
+		hierarchy[co.Name] = cohort
+	}
+	for name := range cohorts {
+		if hierarchy[name] == nil {
+			hierarchy[name] = newCohort(name, 0)
+		}
+	}
+	linkCohorts(hierarchy)
+	for name, members := range cohorts {
+		for _, cq := range members {
+			for ancestor := hierarchy[name]; ancestor != nil; ancestor = ancestor.Parent {
+				cq.accumulateResources(ancestor)
+			}
+		}
+	}
+	for name, cohort := range hierarchy {
+		for rName, flavors := range cohort.UsedResources {
+			for flavor, used := range flavors {
+				if quota := cohort.RequestableResources[rName][flavor]; cohort.Parent == nil && used > quota {
+					violations = append(violations, Violation{
+						Type:     CohortQuotaExceeded,
+						Cohort:   name,
+						Resource: rName,
+						Flavor:   flavor,
+						Message: fmt.Sprintf("Admitted usage %s exceeds the quota %s of the cohort",
+							quantityString(rName, used), quantityString(rName, quota)),
+					})
+				}
+				if limit, ok := cohort.Limits[rName][flavor]; ok && used > limit {
+					violations = append(violations, Violation{
+						Type:     CohortLimitExceeded,
+						Cohort:   name,
+						Resource: rName,
+						Flavor:   flavor,
+						Message: fmt.Sprintf("Admitted usage %s exceeds the limit %s",
+							quantityString(rName, used), quantityString(rName, limit)),
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		a, b := &violations[i], &violations[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.ClusterQueue != b.ClusterQueue {
+			return a.ClusterQueue < b.ClusterQueue
+		}
+		if a.Cohort != b.Cohort {
+			return a.Cohort < b.Cohort
+		}
+		if a.Workload != b.Workload {
+			return a.Workload < b.Workload
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Flavor < b.Flavor
+	})
+	return violations, nil
+}
+
+func quantityString(name corev1.ResourceName, v int64) string {
+	q := workload.ResourceQuantity(name, v)
+	return q.String()
+}
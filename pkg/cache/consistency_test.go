@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("solo").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "5").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("a").Cohort("cohort").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "5").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("b").Cohort("cohort").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "5").Obj()).Obj()).Obj(),
+	}
+	admitted := func(name, cq, cpu string) *kueue.Workload {
+		return utiltesting.MakeWorkload(name, "ns").Request(corev1.ResourceCPU, cpu).
+			Admit(utiltesting.MakeAdmission(cq).Flavor(corev1.ResourceCPU, "default").Obj()).Obj()
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		clusterQueues[0], clusterQueues[1], clusterQueues[2],
+		admitted("solo1", "solo", "6"),
+		admitted("a1", "a", "12"),
+		admitted("ghost", "deleted", "1"),
+		utiltesting.MakeWorkload("pending", "ns").Request(corev1.ResourceCPU, "1").Obj(),
+	).Build()
+	cache := New(cl)
+	ctx := context.Background()
+	for _, cq := range clusterQueues {
+		if err := cache.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+
+	// A workload admitted while the cache wasn't watching.
+	if err := cl.Create(ctx, admitted("b1", "b", "1")); err != nil {
+		t.Fatalf("Failed creating workload: %v", err)
+	}
+	// A workload that was deleted while the cache wasn't watching.
+	cache.AddOrUpdateWorkload(admitted("leaked", "solo", "2"))
+
+	got, err := cache.CheckConsistency(ctx)
+	if err != nil {
+		t.Fatalf("CheckConsistency failed: %v", err)
+	}
+	want := []Violation{
+		{
+			Type:     CohortQuotaExceeded,
+			Cohort:   "cohort",
+			Resource: corev1.ResourceCPU,
+			Flavor:   "default",
+			Message:  "Admitted usage 13 exceeds the quota 10 of the cohort",
+		},
+		{
+			Type:         LeakedWorkload,
+			ClusterQueue: "solo",
+			Workload:     "ns/leaked",
+			Message:      "Cache accounts for a workload that is not admitted by the ClusterQueue",
+		},
+		{
+			Type:         MissingWorkload,
+			ClusterQueue: "b",
+			Workload:     "ns/b1",
+			Message:      "Admitted workload is not accounted in the cache",
+		},
+		{
+			Type:         QuotaExceeded,
+			ClusterQueue: "solo",
+			Resource:     corev1.ResourceCPU,
+			Flavor:       "default",
+			Message:      "Admitted usage 6 exceeds the quota 5",
+		},
+		{
+			Type:         UnknownClusterQueue,
+			ClusterQueue: "deleted",
+			Workload:     "ns/ghost",
+			Message:      "Workload is admitted by a ClusterQueue that doesn't exist",
+		},
+		{
+			Type:         UsageMismatch,
+			ClusterQueue: "b",
+			Resource:     corev1.ResourceCPU,
+			Flavor:       "default",
+			Message:      "Cache usage 0 differs from the admitted usage 1",
+		},
+		{
+			Type:         UsageMismatch,
+			ClusterQueue: "solo",
+			Resource:     corev1.ResourceCPU,
+			Flavor:       "default",
+			Message:      "Cache usage 8 differs from the admitted usage 6",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected violations (-want,+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"time"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// Interface is the set of operations of the cache that the controllers and
+// the scheduler depend on. Cache is the implementation used in production.
+type Interface interface {
+	// AddOrUpdateResourceFlavor starts tracking a ResourceFlavor or replaces
+	// the tracked one.
+	AddOrUpdateResourceFlavor(*kueue.ResourceFlavor)
+	// DeleteResourceFlavor stops tracking a ResourceFlavor.
+	DeleteResourceFlavor(*kueue.ResourceFlavor)
+	// ClusterQueuesUsingFlavor returns the names of the ClusterQueues that
+	// reference the ResourceFlavor.
+	ClusterQueuesUsingFlavor(string) []string
+
+	// AddOrUpdateQueue starts tracking the admission limits of a Queue or
+	// replaces the tracked ones.
+	AddOrUpdateQueue(*kueue.Queue)
+	// DeleteQueue stops tracking the admission limits of a Queue.
+	DeleteQueue(*kueue.Queue)
+
+	// AddOrUpdateCohort starts tracking the parent and the limits of a
+	// Cohort or replaces the tracked ones.
+	AddOrUpdateCohort(*kueue.Cohort)
+	// DeleteCohort stops tracking the parent and the limits of a Cohort.
+	DeleteCohort(*kueue.Cohort)
+
+	// AddClusterQueue starts tracking a ClusterQueue and the workloads
+	// admitted in it.
+	AddClusterQueue(context.Context, *kueue.ClusterQueue) error
+	// UpdateClusterQueue updates the quotas and cohort of a tracked
+	// ClusterQueue.
+	UpdateClusterQueue(*kueue.ClusterQueue) error
+	// UpdateQuotaSchedules recomputes the quotas of a tracked ClusterQueue
+	// with the quota schedules active at the given time. It returns whether
+	// the active quota schedules changed and when they can change next.
+	UpdateQuotaSchedules(*kueue.ClusterQueue, time.Time) (bool, time.Time, error)
+	// QuotaExcessWorkloads returns the workloads to evict from the
+	// ClusterQueue so that its usage fits its quotas.
+	QuotaExcessWorkloads(string) []*workload.Info
+	// DeleteClusterQueue stops tracking a ClusterQueue.
+	DeleteClusterQueue(*kueue.ClusterQueue)
+	// Usage reports the used resources and number of workloads reserving
+	// quota in and admitted by the ClusterQueue.
+	Usage(*kueue.ClusterQueue) (kueue.UsedResources, WorkloadCounts, error)
+	// ClusterQueueActive returns whether the ClusterQueue can admit new
+	// workloads.
+	ClusterQueueActive(string) bool
+	// ClusterQueueMissingFlavors returns the ResourceFlavors referenced by
+	// the ClusterQueue that don't exist.
+	ClusterQueueMissingFlavors(string) []string
+	// QueueUsage reports the resources reserved by and the number of
+	// workloads reserving quota and admitted from the Queue, and the names
+	// of the flavors that the Queue can use through its ClusterQueue.
+	QueueUsage(*kueue.Queue) (kueue.UsedResources, WorkloadCounts, []string)
+
+	// AddOrUpdateWorkload accounts for an admitted workload in its
+	// ClusterQueue. Returns false if the ClusterQueue is not tracked.
+	AddOrUpdateWorkload(*kueue.Workload) bool
+	// UpdateWorkload moves the usage of a workload from its old admission to
+	// the new one.
+	UpdateWorkload(oldWl, newWl *kueue.Workload) error
+	// DeleteWorkload releases the usage of a workload.
+	DeleteWorkload(*kueue.Workload) error
+	// AssumeWorkload accounts for a workload whose admission is not yet
+	// persisted in the API server.
+	AssumeWorkload(*kueue.Workload) error
+	// ForgetWorkload releases the usage of an assumed workload.
+	ForgetWorkload(*kueue.Workload) error
+	// AddOrUpdatePendingWorkload accounts for the demand of a workload
+	// waiting for a quota reservation.
+	AddOrUpdatePendingWorkload(*kueue.Workload)
+	// DeletePendingWorkload releases the demand of a pending workload.
+	DeletePendingWorkload(*kueue.Workload)
+
+	// PodsReadyForAllAdmittedWorkloads returns whether all the admitted
+	// workloads have the PodsReady condition, when it's tracked.
+	PodsReadyForAllAdmittedWorkloads() bool
+	// WaitForPodsReady blocks until all the admitted workloads have the
+	// PodsReady condition, when it's tracked, or the context is done.
+	WaitForPodsReady(context.Context)
+
+	// Snapshot returns a copy of the ClusterQueues, cohorts, ResourceFlavors
+	// and Queues that the scheduler, or a simulator, can modify without
+	// affecting the cache.
+	Snapshot() Snapshot
+	// UpdateSnapshot brings a snapshot up to date, only copying again the
+	// ClusterQueues that changed since they were copied.
+	UpdateSnapshot(*Snapshot)
+	// ValidateAdmission returns an error if the admission of the workload
+	// can't be restored in its ClusterQueue.
+	ValidateAdmission(*kueue.Workload) error
+}
+
+var _ Interface = &Cache{}
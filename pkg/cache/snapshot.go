@@ -17,52 +17,153 @@ limitations under the License.
 package cache
 
 import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/workload"
 )
 
+// Snapshot is a copy of the state of the cache at a point in time. Changes
+// to the cache don't affect a snapshot and changes to a snapshot, like adding
+// or removing workloads to simulate admissions and preemptions, don't affect
+// the cache. The ResourceFlavors, Queues and workload.Infos are shared with
+// the cache and must not be modified.
 type Snapshot struct {
 	ClusterQueues   map[string]*ClusterQueue
 	ResourceFlavors map[string]*kueue.ResourceFlavor
+	Queues          map[string]*Queue
+	// Cohorts are the cohorts of the ClusterQueues and their ancestors, by
+	// name.
+	Cohorts map[string]*Cohort
 }
 
+// Snapshot returns a copy of the ClusterQueues, with their usage and admitted
+// workloads, and of the cohorts, ResourceFlavors and Queues.
 func (c *Cache) Snapshot() Snapshot {
+	var snap Snapshot
+	c.UpdateSnapshot(&snap)
+	return snap
+}
+
+// UpdateSnapshot brings a snapshot up to date with the cache. Only the
+// ClusterQueues that changed since they were copied, in the cache or in the
+// snapshot, are copied again; the others are reused. The cohorts,
+// ResourceFlavors and Queues are always copied, as they are cheap to copy.
+func (c *Cache) UpdateSnapshot(snap *Snapshot) {
 	c.RLock()
 	defer c.RUnlock()
 
-	snap := Snapshot{
-		ClusterQueues:   make(map[string]*ClusterQueue, len(c.clusterQueues)),
-		ResourceFlavors: make(map[string]*kueue.ResourceFlavor, len(c.resourceFlavors)),
-	}
+	clusterQueues := make(map[string]*ClusterQueue, len(c.clusterQueues))
 	for _, cq := range c.clusterQueues {
-		snap.ClusterQueues[cq.Name] = cq.snapshot()
+		cqCopy := snap.ClusterQueues[cq.Name]
+		if cqCopy == nil || cqCopy.generation != cq.generation {
+			cqCopy = cq.snapshot()
+		}
+		cqCopy.Cohort = nil
+		clusterQueues[cq.Name] = cqCopy
 	}
+	snap.ClusterQueues = clusterQueues
+	snap.ResourceFlavors = make(map[string]*kueue.ResourceFlavor, len(c.resourceFlavors))
 	for _, rf := range c.resourceFlavors {
 		// Shallow copy is enough
 		snap.ResourceFlavors[rf.Name] = rf
 	}
+	snap.Queues = make(map[string]*Queue, len(c.queues))
+	for key, q := range c.queues {
+		// Shallow copy is enough, as the Queues are replaced on update.
+		snap.Queues[key] = q
+	}
+	cohorts := make(map[string]*Cohort, len(c.cohorts))
 	for _, cohort := range c.cohorts {
 		cohortCopy := newCohort(cohort.Name, len(cohort.members))
+		cohortCopy.parentName = cohort.parentName
+		cohortCopy.Limits = cohort.limits // Shallow copy is enough.
+		cohorts[cohort.Name] = cohortCopy
+	}
+	linkCohorts(cohorts)
+	snap.Cohorts = cohorts
+	for _, cohort := range c.cohorts {
+		cohortCopy := cohorts[cohort.Name]
 		for cq := range cohort.members {
 			cqCopy := snap.ClusterQueues[cq.Name]
-			cqCopy.accumulateResources(cohortCopy)
+			for ancestor := cohortCopy; ancestor != nil; ancestor = ancestor.Parent {
+				cqCopy.accumulateResources(ancestor)
+			}
 			cqCopy.Cohort = cohortCopy
 			cohortCopy.members[cqCopy] = struct{}{}
 		}
 	}
-	return snap
+}
+
+// linkCohorts sets the Parent of the cohorts from their parentName, adding
+// the parents that are only referenced by other cohorts.
+func linkCohorts(cohorts map[string]*Cohort) {
+	var children []*Cohort
+	for _, cohort := range cohorts {
+		if cohort.parentName != "" {
+			children = append(children, cohort)
+		}
+	}
+	for _, cohort := range children {
+		parent := cohorts[cohort.parentName]
+		if parent == nil {
+			parent = newCohort(cohort.parentName, 0)
+			cohorts[cohort.parentName] = parent
+		}
+		cohort.Parent = parent
+	}
+	breakCohortCycles(cohorts)
+}
+
+// breakCohortCycles turns the Cohorts that are part of a cycle of parents
+// into roots. The webhook can only reject a Cohort that is its own parent.
+func breakCohortCycles(cohorts map[string]*Cohort) {
+	for _, cohort := range cohorts {
+		visited := make(map[*Cohort]bool)
+		c := cohort
+		for c != nil && !visited[c] {
+			visited[c] = true
+			c = c.Parent
+		}
+		if c == nil {
+			continue
+		}
+		// c is part of a cycle.
+		for start := c; ; {
+			next := c.Parent
+			c.Parent = nil
+			if next == start {
+				break
+			}
+			c = next
+		}
+	}
 }
 
 // Snapshot creates a copy of ClusterQueue that includes references to immutable
 // objects and deep copies of changing ones. A reference to the cohort is not included.
 func (c *ClusterQueue) snapshot() *ClusterQueue {
 	cc := &ClusterQueue{
-		Name:                 c.Name,
-		RequestableResources: c.RequestableResources, // Shallow copy is enough.
-		UsedResources:        make(Resources, len(c.UsedResources)),
-		Workloads:            make(map[string]*workload.Info, len(c.Workloads)),
-		LabelKeys:            c.LabelKeys, // Shallow copy is enough.
-		NamespaceSelector:    c.NamespaceSelector,
+		Name:                       c.Name,
+		ResourceGroups:             c.ResourceGroups,       // Shallow copy is enough.
+		RequestableResources:       c.RequestableResources, // Shallow copy is enough.
+		UsedResources:              make(Resources, len(c.UsedResources)),
+		Workloads:                  make(map[string]*workload.Info, len(c.Workloads)),
+		LabelKeys:                  c.LabelKeys, // Shallow copy is enough.
+		NamespaceSelector:          c.NamespaceSelector,
+		SameFlavorResources:        c.SameFlavorResources, // Shallow copy is enough.
+		MinBorrowingPriority:       c.MinBorrowingPriority,
+		Preemption:                 c.Preemption,
+		FlavorFungibility:          c.FlavorFungibility,
+		FairWeight:                 c.FairWeight,
+		AdmissionChecks:            c.AdmissionChecks, // Shallow copy is enough.
+		MaxAdmittedWorkloads:       c.MaxAdmittedWorkloads,
+		NamespaceQuotas:            c.NamespaceQuotas, // Shallow copy is enough.
+		ReservingWorkloadsPerQueue: make(map[string]int, len(c.ReservingWorkloadsPerQueue)),
+		MissingFlavors:             c.MissingFlavors, // Shallow copy is enough.
+		generation:                 c.generation,
 	}
 	for res, flavors := range c.UsedResources {
 		flavorsCopy := make(map[string]int64, len(flavors))
@@ -75,9 +176,163 @@ func (c *ClusterQueue) snapshot() *ClusterQueue {
 		// Shallow copy is enough.
 		cc.Workloads[k] = v
 	}
+	for k, v := range c.ReservingWorkloadsPerQueue {
+		cc.ReservingWorkloadsPerQueue[k] = v
+	}
+	if len(c.NamespaceUsage) > 0 {
+		cc.NamespaceUsage = make(map[string]workload.Requests, len(c.NamespaceUsage))
+		for ns, usage := range c.NamespaceUsage {
+			cc.NamespaceUsage[ns] = usage.Scaled(1)
+		}
+	}
 	return cc
 }
 
+// QueueAdmissionLimitReached returns whether the Queue of the workload
+// already has as many admitted workloads as it allows, across all the
+// ClusterQueues. The workloads that reserve quota while their AdmissionChecks
+// complete count as admitted.
+func (s *Snapshot) QueueAdmissionLimitReached(w *kueue.Workload) bool {
+	key := queueKeyForWorkload(w)
+	q := s.Queues[key]
+	if q == nil || q.MaxAdmittedWorkloads == nil {
+		return false
+	}
+	reserving := 0
+	for _, cq := range s.ClusterQueues {
+		reserving += cq.ReservingWorkloadsPerQueue[key]
+	}
+	return reserving >= int(*q.MaxAdmittedWorkloads)
+}
+
+// RemoveWorkload removes an admitted workload from its ClusterQueue in the
+// snapshot, releasing its usage from the ClusterQueue and the cohorts.
+func (s *Snapshot) RemoveWorkload(wi *workload.Info) {
+	cq := s.ClusterQueues[string(wi.Obj.Spec.Admission.ClusterQueue)]
+	if cq == nil {
+		return
+	}
+	k := workload.Key(wi.Obj)
+	if _, exist := cq.Workloads[k]; !exist {
+		return
+	}
+	delete(cq.Workloads, k)
+	lentBefore := cq.lentUsage()
+	cq.updateWorkloadUsage(wi, -1)
+	qKey := queueKeyForWorkload(wi.Obj)
+	if cq.ReservingWorkloadsPerQueue[qKey]--; cq.ReservingWorkloadsPerQueue[qKey] <= 0 {
+		delete(cq.ReservingWorkloadsPerQueue, qKey)
+	}
+	cq.updateCohortUsage(lentBefore)
+}
+
+// AddWorkload adds an admitted workload to its ClusterQueue in the snapshot,
+// accounting for its usage in the ClusterQueue and the cohorts. It can add
+// back a workload that was removed with RemoveWorkload or simulate the
+// admission of a new one.
+func (s *Snapshot) AddWorkload(wi *workload.Info) {
+	cq := s.ClusterQueues[string(wi.Obj.Spec.Admission.ClusterQueue)]
+	if cq == nil {
+		return
+	}
+	k := workload.Key(wi.Obj)
+	if _, exist := cq.Workloads[k]; exist {
+		return
+	}
+	cq.Workloads[k] = wi
+	lentBefore := cq.lentUsage()
+	cq.updateWorkloadUsage(wi, 1)
+	cq.ReservingWorkloadsPerQueue[queueKeyForWorkload(wi.Obj)]++
+	cq.updateCohortUsage(lentBefore)
+}
+
+// lentUsage returns the usage of the ClusterQueue that counts towards the
+// usage of its cohorts: the usage past the guaranteed quota of each flavor.
+func (c *ClusterQueue) lentUsage() Resources {
+	lent := make(Resources, len(c.RequestableResources))
+	for name, flavors := range c.RequestableResources {
+		lent[name] = make(map[string]int64, len(flavors))
+		for _, flavor := range flavors {
+			used := c.UsedResources[name][flavor.Name] - flavor.Guaranteed()
+			if used < 0 {
+				used = 0
+			}
+			lent[name][flavor.Name] = used
+		}
+	}
+	return lent
+}
+
+// updateCohortUsage updates the usage of the cohorts of the ClusterQueue
+// with the change of its lent usage since lentBefore.
+func (c *ClusterQueue) updateCohortUsage(lentBefore Resources) {
+	if c.Cohort == nil {
+		return
+	}
+	lentAfter := c.lentUsage()
+	for cohort := c.Cohort; cohort != nil; cohort = cohort.Parent {
+		for name, flavors := range lentAfter {
+			for flavor, v := range flavors {
+				cohort.UsedResources[name][flavor] += v - lentBefore[name][flavor]
+			}
+		}
+	}
+}
+
+// DominantResourceShare returns the dominant resource share of the
+// ClusterQueue in a snapshot, in per mille: the highest share, among the
+// resources that the ClusterQueue borrows, of the quantity used above its
+// nominal quotas relative to the quotas of its root cohort, divided by the
+// fair sharing weight of the ClusterQueue.
+func (c *ClusterQueue) DominantResourceShare() int {
+	return c.dominantResourceShare(nil)
+}
+
+// DominantResourceShareWith returns the dominant resource share that the
+// ClusterQueue would have in a snapshot if the workload was admitted in it.
+func (c *ClusterQueue) DominantResourceShareWith(wi *workload.Info) int {
+	return c.dominantResourceShare(wi)
+}
+
+func (c *ClusterQueue) dominantResourceShare(wi *workload.Info) int {
+	if c.Cohort == nil {
+		return 0
+	}
+	var wlUsage Resources
+	if wi != nil {
+		wlUsage = make(Resources, len(c.RequestableResources))
+		for name, flavors := range c.RequestableResources {
+			wlUsage[name] = make(map[string]int64, len(flavors))
+			for _, flavor := range flavors {
+				wlUsage[name][flavor.Name] = 0
+			}
+		}
+		updateWorkloadUsage(wlUsage, wi, 1)
+	}
+	root := c.Cohort.Root()
+	drs := 0
+	for name, flavors := range c.RequestableResources {
+		var borrowed, lendable int64
+		for _, flavor := range flavors {
+			used := c.UsedResources[name][flavor.Name] + wlUsage[name][flavor.Name]
+			if used > flavor.Nominal {
+				borrowed += used - flavor.Nominal
+			}
+			lendable += root.RequestableResources[name][flavor.Name]
+		}
+		if borrowed == 0 || lendable == 0 {
+			continue
+		}
+		if share := int(borrowed * 1000 / lendable); share > drs {
+			drs = share
+		}
+	}
+	if c.FairWeight > 1 {
+		drs /= int(c.FairWeight)
+	}
+	return drs
+}
+
 func (c *ClusterQueue) accumulateResources(cohort *Cohort) {
 	if cohort.RequestableResources == nil {
 		cohort.RequestableResources = make(Resources, len(c.RequestableResources))
@@ -89,13 +344,14 @@ func (c *ClusterQueue) accumulateResources(cohort *Cohort) {
 			cohort.RequestableResources[name] = req
 		}
 		for _, flavor := range flavors {
-			req[flavor.Name] += flavor.Min
+			// The guaranteed quota isn't available to the cohort.
+			req[flavor.Name] += flavor.Nominal - flavor.Guaranteed()
 		}
 	}
 	if cohort.UsedResources == nil {
 		cohort.UsedResources = make(Resources, len(c.UsedResources))
 	}
-	for res, flavors := range c.UsedResources {
+	for res, flavors := range c.lentUsage() {
 		used := cohort.UsedResources[res]
 		if used == nil {
 			used = make(map[string]int64, len(flavors))
@@ -106,3 +362,111 @@ func (c *ClusterQueue) accumulateResources(cohort *Cohort) {
 		}
 	}
 }
+
+// FitsFlavorLimits returns whether a requested resource fits in a specific
+// flavor's quota limits in a snapshot. If it fits, also returns any borrowing
+// required.
+func (cq *ClusterQueue) FitsFlavorLimits(name corev1.ResourceName, val int64, flavor *FlavorLimits) (bool, int64) {
+	used := cq.UsedResources[name][flavor.Name]
+	if flavor.BorrowingLimit != nil && used+val > flavor.Nominal+*flavor.BorrowingLimit {
+		// Past borrowing limit.
+		return false, 0
+	}
+	borrow := used + val - flavor.Nominal
+	if borrow < 0 {
+		borrow = 0
+	}
+	if cq.Cohort == nil {
+		if borrow > 0 {
+			// There is nothing to borrow from.
+			return false, 0
+		}
+		return true, 0
+	}
+	// Only the usage past the guaranteed quota counts towards the cohort.
+	guaranteed := flavor.Guaranteed()
+	cohortVal := used + val - guaranteed
+	if cohortVal > val {
+		cohortVal = val
+	}
+	if cohortVal <= 0 {
+		return true, borrow
+	}
+	// The clusterQueue can borrow from any clusterQueue under the same
+	// root cohort.
+	root := cq.Cohort.Root()
+	if root.UsedResources[name][flavor.Name]+cohortVal > root.RequestableResources[name][flavor.Name] {
+		// Doesn't fit even with borrowing.
+		return false, 0
+	}
+	for cohort := cq.Cohort; cohort != nil; cohort = cohort.Parent {
+		if limit, ok := cohort.Limits[name][flavor.Name]; ok && cohort.UsedResources[name][flavor.Name]+cohortVal > limit {
+			// Past the limit of a cohort in the hierarchy.
+			return false, 0
+		}
+	}
+	return true, borrow
+}
+
+// ValidateAdmission returns why the admission of a workload can't be
+// restored: its ClusterQueue doesn't exist or is inactive, a flavor of the
+// admission isn't in the ClusterQueue, or its usage doesn't fit the quotas.
+// Any workload with the same key that the cache still has is ignored, as it
+// was deleted to be replaced by this one.
+func (c *Cache) ValidateAdmission(w *kueue.Workload) error {
+	snapshot := c.Snapshot()
+	cqName := string(w.Spec.Admission.ClusterQueue)
+	cq := snapshot.ClusterQueues[cqName]
+	if cq == nil {
+		return fmt.Errorf("ClusterQueue %s doesn't exist", cqName)
+	}
+	if !cq.Active() {
+		return fmt.Errorf("ClusterQueue %s is inactive", cqName)
+	}
+	if old := cq.Workloads[workload.Key(w)]; old != nil {
+		snapshot.RemoveWorkload(old)
+	}
+	wi := workload.NewInfo(w, c.workloadInfoOptions...)
+	usage := make(Resources)
+	for _, ps := range wi.TotalRequests {
+		if err := cq.addAdmissionUsage(usage, ps.Requests, ps.Flavors); err != nil {
+			return err
+		}
+		for _, split := range ps.Splits {
+			if err := cq.addAdmissionUsage(usage, split.Requests, split.Flavors); err != nil {
+				return err
+			}
+		}
+	}
+	for name, flavors := range usage {
+		for _, flavor := range cq.RequestableResources[name] {
+			val, ok := flavors[flavor.Name]
+			if !ok {
+				continue
+			}
+			if fits, _ := cq.FitsFlavorLimits(name, val, &flavor); !fits {
+				return fmt.Errorf("the usage of %s in flavor %s doesn't fit in ClusterQueue %s", name, flavor.Name, cqName)
+			}
+		}
+	}
+	return cq.FitsInNamespaceQuotas(wi)
+}
+
+// addAdmissionUsage adds the requests to the usage of the flavors assigned
+// to them, which must be flavors of the ClusterQueue.
+func (cq *ClusterQueue) addAdmissionUsage(usage Resources, requests workload.Requests, flavors map[corev1.ResourceName]string) error {
+	for name, fName := range flavors {
+		found := false
+		for _, flavor := range cq.RequestableResources[name] {
+			found = found || flavor.Name == fName
+		}
+		if !found {
+			return fmt.Errorf("flavor %s of %s is not in ClusterQueue %s", fName, name, cq.Name)
+		}
+		if usage[name] == nil {
+			usage[name] = make(map[string]int64)
+		}
+		usage[name][fName] += requests[name]
+	}
+	return nil
+}
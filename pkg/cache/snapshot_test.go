@@ -48,21 +48,23 @@ func TestSnapshot(t *testing.T) {
 			},
 			Spec: kueue.ClusterQueueSpec{
 				Cohort: "foo",
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "demand",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("100"),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:         corev1.ResourceCPU,
+									NominalQuota: resource.MustParse("100"),
+								}},
 							},
 							{
 								Name: "spot",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("200"),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:         corev1.ResourceCPU,
+									NominalQuota: resource.MustParse("200"),
+								}},
 							},
 						},
 					},
@@ -75,26 +77,28 @@ func TestSnapshot(t *testing.T) {
 			},
 			Spec: kueue.ClusterQueueSpec{
 				Cohort: "foo",
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "spot",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("100"),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:         corev1.ResourceCPU,
+									NominalQuota: resource.MustParse("100"),
+								}},
 							},
 						},
 					},
 					{
-						Name: "example.com/gpu",
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{"example.com/gpu"},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "default",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("50"),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:         "example.com/gpu",
+									NominalQuota: resource.MustParse("50"),
+								}},
 							},
 						},
 					},
@@ -106,15 +110,16 @@ func TestSnapshot(t *testing.T) {
 				Name: "bar",
 			},
 			Spec: kueue.ClusterQueueSpec{
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "default",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("100"),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:         corev1.ResourceCPU,
+									NominalQuota: resource.MustParse("100"),
+								}},
 							},
 						},
 					},
@@ -255,15 +260,19 @@ func TestSnapshot(t *testing.T) {
 			"foofoo": {
 				Name:   "foofoo",
 				Cohort: &wantCohorts[0],
+				ResourceGroups: []ResourceGroup{{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+					Flavors:          []string{"demand", "spot"},
+				}},
 				RequestableResources: map[corev1.ResourceName][]FlavorLimits{
 					corev1.ResourceCPU: {
 						{
-							Name: "demand",
-							Min:  100_000,
+							Name:    "demand",
+							Nominal: 100_000,
 						},
 						{
-							Name: "spot",
-							Min:  200_000,
+							Name:    "spot",
+							Nominal: 200_000,
 						},
 					},
 				},
@@ -276,23 +285,37 @@ func TestSnapshot(t *testing.T) {
 				Workloads: map[string]*workload.Info{
 					"/alpha": workload.NewInfo(&workloads[0]),
 				},
+				ReservingWorkloadsPerQueue: map[string]int{"/": 1},
+				NamespaceUsage: map[string]workload.Requests{
+					"": {corev1.ResourceCPU: 10_000},
+				},
 				LabelKeys:         map[corev1.ResourceName]sets.String{corev1.ResourceCPU: {"baz": {}, "foo": {}, "instance": {}}},
 				NamespaceSelector: labels.Nothing(),
 			},
 			"foobar": {
 				Name:   "foobar",
 				Cohort: &wantCohorts[0],
+				ResourceGroups: []ResourceGroup{
+					{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors:          []string{"spot"},
+					},
+					{
+						CoveredResources: []corev1.ResourceName{"example.com/gpu"},
+						Flavors:          []string{"default"},
+					},
+				},
 				RequestableResources: map[corev1.ResourceName][]FlavorLimits{
 					corev1.ResourceCPU: {
 						{
-							Name: "spot",
-							Min:  100_000,
+							Name:    "spot",
+							Nominal: 100_000,
 						},
 					},
 					"example.com/gpu": {
 						{
-							Name: "default",
-							Min:  50,
+							Name:    "default",
+							Nominal: 50,
 						},
 					},
 				},
@@ -308,24 +331,35 @@ func TestSnapshot(t *testing.T) {
 					"/beta":  workload.NewInfo(&workloads[1]),
 					"/gamma": workload.NewInfo(&workloads[2]),
 				},
+				ReservingWorkloadsPerQueue: map[string]int{"/": 2},
+				NamespaceUsage: map[string]workload.Requests{
+					"": {corev1.ResourceCPU: 10_000, "example.com/gpu": 15},
+				},
+				MissingFlavors:    []string{"default"},
 				NamespaceSelector: labels.Nothing(),
 				LabelKeys:         map[corev1.ResourceName]sets.String{corev1.ResourceCPU: {"baz": {}, "instance": {}}},
 			},
 			"bar": {
 				Name: "bar",
+				ResourceGroups: []ResourceGroup{{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+					Flavors:          []string{"default"},
+				}},
 				RequestableResources: map[corev1.ResourceName][]FlavorLimits{
 					corev1.ResourceCPU: {
 						{
-							Name: "default",
-							Min:  100_000,
+							Name:    "default",
+							Nominal: 100_000,
 						},
 					},
 				},
 				UsedResources: Resources{
 					corev1.ResourceCPU: map[string]int64{"default": 0},
 				},
-				Workloads:         map[string]*workload.Info{},
-				NamespaceSelector: labels.Nothing(),
+				Workloads:                  map[string]*workload.Info{},
+				ReservingWorkloadsPerQueue: map[string]int{},
+				MissingFlavors:             []string{"default"},
+				NamespaceSelector:          labels.Nothing(),
 			},
 		},
 		ResourceFlavors: map[string]*kueue.ResourceFlavor{
@@ -338,8 +372,382 @@ func TestSnapshot(t *testing.T) {
 				Labels:     map[string]string{"baz": "bar", "instance": "spot"},
 			},
 		},
+		Queues: map[string]*Queue{},
+		Cohorts: map[string]*Cohort{
+			"foo": &wantCohorts[0],
+		},
 	}
-	if diff := cmp.Diff(wantSnapshot, snapshot, cmpopts.IgnoreUnexported(Cohort{})); diff != "" {
+	if diff := cmp.Diff(wantSnapshot, snapshot, cmpopts.IgnoreUnexported(ClusterQueue{}, Cohort{})); diff != "" {
 		t.Errorf("Unexpected Snapshot (-want,+got):\n%s", diff)
 	}
 }
+
+func TestSnapshotCohortHierarchy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("a").Cohort("team-a").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "5").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("b").Cohort("team-b").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("c").Cohort("x").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "1").Obj()).Obj()).Obj(),
+	}
+	for _, cq := range clusterQueues {
+		if err := cache.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+	cache.AddOrUpdateCohort(utiltesting.MakeCohort("team-a").Parent("org").Limit(corev1.ResourceCPU, "default", "8").Obj())
+	cache.AddOrUpdateCohort(utiltesting.MakeCohort("team-b").Parent("org").Obj())
+	// A cycle of parents.
+	cache.AddOrUpdateCohort(utiltesting.MakeCohort("x").Parent("y").Obj())
+	cache.AddOrUpdateCohort(utiltesting.MakeCohort("y").Parent("x").Obj())
+	cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("wl", "").Request(corev1.ResourceCPU, "3").
+		Admit(utiltesting.MakeAdmission("a").Flavor(corev1.ResourceCPU, "default").Obj()).Obj())
+
+	snapshot := cache.Snapshot()
+	a := snapshot.ClusterQueues["a"]
+	if got := cohortPath(a.Cohort); !cmp.Equal(got, []string{"team-a", "org"}) {
+		t.Errorf("Cohorts of ClusterQueue a: %v, want [team-a org]", got)
+	}
+	if snapshot.ClusterQueues["b"].Cohort.Root() != a.Cohort.Root() {
+		t.Error("ClusterQueues a and b don't share the root cohort")
+	}
+	wantRoot := Cohort{
+		Name: "org",
+		RequestableResources: Resources{
+			corev1.ResourceCPU: {"default": 15_000},
+		},
+		UsedResources: Resources{
+			corev1.ResourceCPU: {"default": 3_000},
+		},
+	}
+	if diff := cmp.Diff(wantRoot, *a.Cohort.Root(), cmpopts.IgnoreUnexported(Cohort{})); diff != "" {
+		t.Errorf("Unexpected root cohort (-want,+got):\n%s", diff)
+	}
+	wantLimits := Resources{corev1.ResourceCPU: {"default": 8_000}}
+	if diff := cmp.Diff(wantLimits, a.Cohort.Limits); diff != "" {
+		t.Errorf("Unexpected limits of cohort team-a (-want,+got):\n%s", diff)
+	}
+	if got := cohortPath(snapshot.ClusterQueues["c"].Cohort); !cmp.Equal(got, []string{"x"}) {
+		t.Errorf("Cohorts of ClusterQueue c: %v, want [x]", got)
+	}
+
+	cache.DeleteCohort(utiltesting.MakeCohort("team-a").Obj())
+	snapshot = cache.Snapshot()
+	if got := cohortPath(snapshot.ClusterQueues["a"].Cohort); !cmp.Equal(got, []string{"team-a"}) {
+		t.Errorf("Cohorts of ClusterQueue a after deleting the Cohort: %v, want [team-a]", got)
+	}
+}
+
+func TestDominantResourceShare(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("a").Cohort("co").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).
+			Resource(utiltesting.MakeResource(corev1.ResourceMemory).Flavor(utiltesting.MakeFlavor("default", "4Gi").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("b").Cohort("co").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "6").Obj()).Obj()).
+			Resource(utiltesting.MakeResource(corev1.ResourceMemory).Flavor(utiltesting.MakeFlavor("default", "4Gi").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("c").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("d").Cohort("weighted").FairWeight(2).
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("e").Cohort("weighted").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).Obj(),
+	}
+	for _, cq := range clusterQueues {
+		if err := cache.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+	admission := func(cq string) *kueue.Admission {
+		return utiltesting.MakeAdmission(cq).Flavor(corev1.ResourceCPU, "default").Flavor(corev1.ResourceMemory, "default").Obj()
+	}
+	cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("a1", "").Request(corev1.ResourceCPU, "6").Request(corev1.ResourceMemory, "5Gi").
+		Admit(admission("a")).Obj())
+	cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("b1", "").Request(corev1.ResourceCPU, "2").
+		Admit(admission("b")).Obj())
+	cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("c1", "").Request(corev1.ResourceCPU, "6").
+		Admit(admission("c")).Obj())
+	cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("d1", "").Request(corev1.ResourceCPU, "6").
+		Admit(admission("d")).Obj())
+
+	snapshot := cache.Snapshot()
+	cases := map[string]struct {
+		cq       string
+		workload *kueue.Workload
+		want     int
+	}{
+		"borrowing in the dominant resource": {
+			cq:   "a",
+			want: 200,
+		},
+		"not borrowing": {
+			cq: "b",
+		},
+		"no cohort": {
+			cq: "c",
+		},
+		"divided by the weight": {
+			cq:   "d",
+			want: 125,
+		},
+		"with a workload that would borrow, divided by the weight": {
+			cq:       "d",
+			workload: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "2").Obj(),
+			want:     250,
+		},
+		"with a workload that would borrow": {
+			cq:       "b",
+			workload: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "1").Request(corev1.ResourceMemory, "6Gi").Obj(),
+			want:     250,
+		},
+		"with a workload that fits in the nominal quota": {
+			cq:       "b",
+			workload: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Obj(),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cq := snapshot.ClusterQueues[tc.cq]
+			var got int
+			if tc.workload != nil {
+				wi := workload.NewInfo(tc.workload)
+				wi.ClusterQueue = tc.cq
+				wi.TotalRequests[0].Flavors = map[corev1.ResourceName]string{
+					corev1.ResourceCPU:    "default",
+					corev1.ResourceMemory: "default",
+				}
+				got = cq.DominantResourceShareWith(wi)
+			} else {
+				got = cq.DominantResourceShare()
+			}
+			if got != tc.want {
+				t.Errorf("Got share %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotLendingLimit(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("lender").Cohort("co").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "10").LendingLimit("4").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("borrower").Cohort("co").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "5").Obj()).Obj()).Obj(),
+	}
+	for _, cq := range clusterQueues {
+		if err := cache.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+	cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("within-guaranteed", "").Request(corev1.ResourceCPU, "5").
+		Admit(utiltesting.MakeAdmission("lender").Flavor(corev1.ResourceCPU, "default").Obj()).Obj())
+	cache.AddOrUpdateWorkload(utiltesting.MakeWorkload("borrowing", "").Request(corev1.ResourceCPU, "7").
+		Admit(utiltesting.MakeAdmission("borrower").Flavor(corev1.ResourceCPU, "default").Obj()).Obj())
+
+	snapshot := cache.Snapshot()
+	wantCohort := Cohort{
+		Name: "co",
+		// The lender keeps 6 of its 10 cpus.
+		RequestableResources: Resources{
+			corev1.ResourceCPU: {"default": 9_000},
+		},
+		UsedResources: Resources{
+			corev1.ResourceCPU: {"default": 7_000},
+		},
+	}
+	if diff := cmp.Diff(wantCohort, *snapshot.Cohorts["co"], cmpopts.IgnoreUnexported(Cohort{})); diff != "" {
+		t.Errorf("Unexpected cohort (-want,+got):\n%s", diff)
+	}
+
+	wi := workload.NewInfo(utiltesting.MakeWorkload("past-guaranteed", "").Request(corev1.ResourceCPU, "3").
+		Admit(utiltesting.MakeAdmission("lender").Flavor(corev1.ResourceCPU, "default").Obj()).Obj())
+	snapshot.AddWorkload(wi)
+	// Only the cpu past the 6 guaranteed cpus comes from the cohort.
+	wantUsed := Resources{corev1.ResourceCPU: {"default": 9_000}}
+	if diff := cmp.Diff(wantUsed, snapshot.Cohorts["co"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the cohort after adding a workload (-want,+got):\n%s", diff)
+	}
+	snapshot.RemoveWorkload(wi)
+	wantUsed = Resources{corev1.ResourceCPU: {"default": 7_000}}
+	if diff := cmp.Diff(wantUsed, snapshot.Cohorts["co"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the cohort after removing the workload (-want,+got):\n%s", diff)
+	}
+}
+
+func cohortPath(c *Cohort) []string {
+	var names []string
+	for ; c != nil; c = c.Parent {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestQueueAdmissionLimitReached(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	for _, name := range []string{"one", "two"} {
+		if err := cache.AddClusterQueue(ctx, utiltesting.MakeClusterQueue(name).Obj()); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+	cache.AddOrUpdateQueue(utiltesting.MakeQueue("limited", "ns").MaxAdmittedWorkloads(2).Obj())
+	cache.AddOrUpdateQueue(utiltesting.MakeQueue("unlimited", "ns").Obj())
+	admitted := []*kueue.Workload{
+		utiltesting.MakeWorkload("a", "ns").Queue("limited").Admit(utiltesting.MakeAdmission("one").Obj()).Obj(),
+		utiltesting.MakeWorkload("b", "ns").Queue("limited").Admit(utiltesting.MakeAdmission("two").Obj()).Obj(),
+		utiltesting.MakeWorkload("c", "ns").Queue("unlimited").Admit(utiltesting.MakeAdmission("one").Obj()).Obj(),
+	}
+	for _, w := range admitted {
+		cache.AddOrUpdateWorkload(w)
+	}
+
+	cases := map[string]struct {
+		workload *kueue.Workload
+		want     bool
+	}{
+		"limit reached across ClusterQueues": {
+			workload: utiltesting.MakeWorkload("d", "ns").Queue("limited").Obj(),
+			want:     true,
+		},
+		"no limit": {
+			workload: utiltesting.MakeWorkload("d", "ns").Queue("unlimited").Obj(),
+		},
+		"queue not tracked": {
+			workload: utiltesting.MakeWorkload("d", "ns").Queue("other").Obj(),
+		},
+	}
+	snapshot := cache.Snapshot()
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := snapshot.QueueAdmissionLimitReached(tc.workload); got != tc.want {
+				t.Errorf("QueueAdmissionLimitReached() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+
+	if err := cache.DeleteWorkload(admitted[0]); err != nil {
+		t.Fatalf("Failed deleting workload: %v", err)
+	}
+	snapshot = cache.Snapshot()
+	if snapshot.QueueAdmissionLimitReached(utiltesting.MakeWorkload("d", "ns").Queue("limited").Obj()) {
+		t.Error("QueueAdmissionLimitReached() = true after a workload finished, want false")
+	}
+}
+
+func TestSnapshotAddRemoveWorkload(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	cq := utiltesting.MakeClusterQueue("a").Cohort("co").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).Obj()
+	if err := cache.AddClusterQueue(context.Background(), cq); err != nil {
+		t.Fatalf("Failed adding ClusterQueue: %v", err)
+	}
+	cache.AddOrUpdateQueue(utiltesting.MakeQueue("q", "ns").MaxAdmittedWorkloads(1).Obj())
+	wl := utiltesting.MakeWorkload("wl", "ns").Queue("q").Request(corev1.ResourceCPU, "3").
+		Admit(utiltesting.MakeAdmission("a").Flavor(corev1.ResourceCPU, "default").Obj()).Obj()
+	wi := workload.NewInfo(wl)
+	pending := utiltesting.MakeWorkload("pending", "ns").Queue("q").Obj()
+
+	snapshot := cache.Snapshot()
+	snapshot.AddWorkload(wi)
+	wantUsed := Resources{corev1.ResourceCPU: {"default": 3_000}}
+	if diff := cmp.Diff(wantUsed, snapshot.ClusterQueues["a"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the ClusterQueue after adding a workload (-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantUsed, snapshot.Cohorts["co"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the cohort after adding a workload (-want,+got):\n%s", diff)
+	}
+	if !snapshot.QueueAdmissionLimitReached(pending) {
+		t.Error("QueueAdmissionLimitReached() = false after adding a workload, want true")
+	}
+	if got := cache.Snapshot().ClusterQueues["a"].UsedResources[corev1.ResourceCPU]["default"]; got != 0 {
+		t.Errorf("Adding a workload to the snapshot changed the usage in the cache to %d", got)
+	}
+
+	snapshot.RemoveWorkload(wi)
+	wantUsed = Resources{corev1.ResourceCPU: {"default": 0}}
+	if diff := cmp.Diff(wantUsed, snapshot.ClusterQueues["a"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the ClusterQueue after removing the workload (-want,+got):\n%s", diff)
+	}
+	if snapshot.QueueAdmissionLimitReached(pending) {
+		t.Error("QueueAdmissionLimitReached() = true after removing the workload, want false")
+	}
+}
+
+func TestUpdateSnapshot(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	for _, name := range []string{"a", "b", "c"} {
+		cq := utiltesting.MakeClusterQueue(name).Cohort("co").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).Obj()
+		if err := cache.AddClusterQueue(context.Background(), cq); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+	admitted := func(name, cq string) *kueue.Workload {
+		return utiltesting.MakeWorkload(name, "ns").Request(corev1.ResourceCPU, "3").
+			Admit(utiltesting.MakeAdmission(cq).Flavor(corev1.ResourceCPU, "default").Obj()).Obj()
+	}
+
+	snapshot := cache.Snapshot()
+	prevA, prevB, prevC := snapshot.ClusterQueues["a"], snapshot.ClusterQueues["b"], snapshot.ClusterQueues["c"]
+	cache.AddOrUpdateWorkload(admitted("in-cache", "a"))
+	snapshot.AddWorkload(workload.NewInfo(admitted("in-snapshot", "b")))
+	cache.UpdateSnapshot(&snapshot)
+
+	if snapshot.ClusterQueues["a"] == prevA {
+		t.Error("The ClusterQueue that changed in the cache wasn't copied again")
+	}
+	if snapshot.ClusterQueues["b"] == prevB {
+		t.Error("The ClusterQueue that changed in the snapshot wasn't copied again")
+	}
+	if snapshot.ClusterQueues["c"] != prevC {
+		t.Error("The ClusterQueue that didn't change was copied again")
+	}
+	wantUsed := Resources{corev1.ResourceCPU: {"default": 3_000}}
+	if diff := cmp.Diff(wantUsed, snapshot.ClusterQueues["a"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the ClusterQueue changed in the cache (-want,+got):\n%s", diff)
+	}
+	wantUsed = Resources{corev1.ResourceCPU: {"default": 0}}
+	if diff := cmp.Diff(wantUsed, snapshot.ClusterQueues["b"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the ClusterQueue changed in the snapshot (-want,+got):\n%s", diff)
+	}
+	wantUsed = Resources{corev1.ResourceCPU: {"default": 3_000}}
+	if diff := cmp.Diff(wantUsed, snapshot.Cohorts["co"].UsedResources); diff != "" {
+		t.Errorf("Unexpected usage of the cohort (-want,+got):\n%s", diff)
+	}
+	if got := snapshot.ClusterQueues["c"].Cohort; got != snapshot.Cohorts["co"] {
+		t.Errorf("The reused ClusterQueue points to cohort %p, want %p", got, snapshot.Cohorts["co"])
+	}
+}
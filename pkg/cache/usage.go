@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"sigs.k8s.io/kueue/pkg/util/pointer"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// UsageSortKey is the criteria to sort the usage of the ClusterQueues.
+type UsageSortKey string
+
+const (
+	// SortByUtilization sorts the ClusterQueues by decreasing utilization.
+	SortByUtilization UsageSortKey = "utilization"
+	// SortByBorrowing lists the ClusterQueues that borrow first, and then
+	// sorts them by decreasing utilization.
+	SortByBorrowing UsageSortKey = "borrowing"
+)
+
+// ClusterQueueUsage summarizes how much of its quota a ClusterQueue uses.
+type ClusterQueueUsage struct {
+	Name               string `json:"name"`
+	Cohort             string `json:"cohort,omitempty"`
+	ReservingWorkloads int    `json:"reservingWorkloads"`
+	AdmittedWorkloads  int    `json:"admittedWorkloads"`
+	// UtilizationPercentage is the highest utilization among the flavors.
+	UtilizationPercentage int64 `json:"utilizationPercentage"`
+	// Borrowing indicates whether any flavor is used past its nominal quota.
+	Borrowing bool          `json:"borrowing"`
+	Flavors   []FlavorUsage `json:"flavors"`
+}
+
+// FlavorUsage is the usage of a flavor of a resource in a ClusterQueue.
+type FlavorUsage struct {
+	Resource       corev1.ResourceName `json:"resource"`
+	Flavor         string              `json:"flavor"`
+	NominalQuota   resource.Quantity   `json:"nominalQuota"`
+	BorrowingLimit *resource.Quantity  `json:"borrowingLimit,omitempty"`
+	Used           resource.Quantity   `json:"used"`
+	Borrowed       *resource.Quantity  `json:"borrowed,omitempty"`
+	// UtilizationPercentage is the used quantity over the nominal quota. It's
+	// 0 for flavors without nominal quota.
+	UtilizationPercentage int64 `json:"utilizationPercentage"`
+}
+
+// ClusterQueuesUsage returns the usage of all the ClusterQueues, sorted by
+// the given criteria. Ties are sorted by name.
+func (c *Cache) ClusterQueuesUsage(sortBy UsageSortKey) []ClusterQueueUsage {
+	c.RLock()
+	defer c.RUnlock()
+
+	usages := make([]ClusterQueueUsage, 0, len(c.clusterQueues))
+	for _, cq := range c.clusterQueues {
+		usages = append(usages, cq.usage())
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		a, b := &usages[i], &usages[j]
+		if sortBy == SortByBorrowing && a.Borrowing != b.Borrowing {
+			return a.Borrowing
+		}
+		if a.UtilizationPercentage != b.UtilizationPercentage {
+			return a.UtilizationPercentage > b.UtilizationPercentage
+		}
+		return a.Name < b.Name
+	})
+	return usages
+}
+
+func (c *ClusterQueue) usage() ClusterQueueUsage {
+	var counts WorkloadCounts
+	for _, wi := range c.Workloads {
+		counts.add(wi)
+	}
+	u := ClusterQueueUsage{
+		Name:               c.Name,
+		ReservingWorkloads: counts.Reserving,
+		AdmittedWorkloads:  counts.Admitted,
+	}
+	if c.Cohort != nil {
+		u.Cohort = c.Cohort.Name
+	}
+	resources := make([]string, 0, len(c.RequestableResources))
+	for rName := range c.RequestableResources {
+		resources = append(resources, string(rName))
+	}
+	sort.Strings(resources)
+	for _, r := range resources {
+		rName := corev1.ResourceName(r)
+		for _, flavor := range c.RequestableResources[rName] {
+			used := c.UsedResources[rName][flavor.Name]
+			fUsage := FlavorUsage{
+				Resource:     rName,
+				Flavor:       flavor.Name,
+				NominalQuota: workload.ResourceQuantity(rName, flavor.Nominal),
+				Used:         workload.ResourceQuantity(rName, used),
+			}
+			if flavor.BorrowingLimit != nil {
+				fUsage.BorrowingLimit = pointer.Quantity(workload.ResourceQuantity(rName, *flavor.BorrowingLimit))
+			}
+			if borrowed := used - flavor.Nominal; borrowed > 0 {
+				fUsage.Borrowed = pointer.Quantity(workload.ResourceQuantity(rName, borrowed))
+				u.Borrowing = true
+			}
+			if flavor.Nominal > 0 {
+				fUsage.UtilizationPercentage = used * 100 / flavor.Nominal
+			}
+			if fUsage.UtilizationPercentage > u.UtilizationPercentage {
+				u.UtilizationPercentage = fUsage.UtilizationPercentage
+			}
+			u.Flavors = append(u.Flavors, fUsage)
+		}
+	}
+	return u
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/pointer"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+)
+
+func TestClusterQueuesUsage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cache := New(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("a").Cohort("cohort").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "10").Obj()).
+				Flavor(utiltesting.MakeFlavor("spot", "0").BorrowingLimit("10").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("b").Cohort("cohort").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).Obj(),
+		utiltesting.MakeClusterQueue("c").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).
+			Resource(utiltesting.MakeResource(corev1.ResourceMemory).
+				Flavor(utiltesting.MakeFlavor("default", "10Gi").Obj()).Obj()).Obj(),
+	}
+	for _, cq := range clusterQueues {
+		if err := cache.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Failed adding ClusterQueue: %v", err)
+		}
+	}
+	workloads := []*kueue.Workload{
+		utiltesting.MakeWorkload("a1", "").Request(corev1.ResourceCPU, "2").
+			Admit(utiltesting.MakeAdmission("a").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+		utiltesting.MakeWorkload("a2", "").Request(corev1.ResourceCPU, "5").
+			Admit(utiltesting.MakeAdmission("a").Flavor(corev1.ResourceCPU, "spot").Obj()).Obj(),
+		utiltesting.MakeWorkload("b1", "").Request(corev1.ResourceCPU, "8").
+			Admit(utiltesting.MakeAdmission("b").Flavor(corev1.ResourceCPU, "default").Obj()).Obj(),
+		utiltesting.MakeWorkload("c1", "").Request(corev1.ResourceCPU, "4").Request(corev1.ResourceMemory, "2Gi").
+			Admit(utiltesting.MakeAdmission("c").Flavor(corev1.ResourceCPU, "default").Flavor(corev1.ResourceMemory, "default").Obj()).Obj(),
+	}
+	for _, w := range workloads {
+		if !cache.AddOrUpdateWorkload(w) {
+			t.Fatalf("Failed adding workload %s", w.Name)
+		}
+	}
+
+	wantUsages := []ClusterQueueUsage{
+		{
+			Name:                  "c",
+			ReservingWorkloads:    1,
+			AdmittedWorkloads:     1,
+			UtilizationPercentage: 100,
+			Flavors: []FlavorUsage{
+				{
+					Resource:              corev1.ResourceCPU,
+					Flavor:                "default",
+					NominalQuota:          resource.MustParse("4"),
+					Used:                  resource.MustParse("4"),
+					UtilizationPercentage: 100,
+				},
+				{
+					Resource:              corev1.ResourceMemory,
+					Flavor:                "default",
+					NominalQuota:          resource.MustParse("10Gi"),
+					Used:                  resource.MustParse("2Gi"),
+					UtilizationPercentage: 20,
+				},
+			},
+		},
+		{
+			Name:                  "b",
+			Cohort:                "cohort",
+			ReservingWorkloads:    1,
+			AdmittedWorkloads:     1,
+			UtilizationPercentage: 80,
+			Flavors: []FlavorUsage{
+				{
+					Resource:              corev1.ResourceCPU,
+					Flavor:                "default",
+					NominalQuota:          resource.MustParse("10"),
+					Used:                  resource.MustParse("8"),
+					UtilizationPercentage: 80,
+				},
+			},
+		},
+		{
+			Name:                  "a",
+			Cohort:                "cohort",
+			ReservingWorkloads:    2,
+			AdmittedWorkloads:     2,
+			UtilizationPercentage: 20,
+			Borrowing:             true,
+			Flavors: []FlavorUsage{
+				{
+					Resource:              corev1.ResourceCPU,
+					Flavor:                "default",
+					NominalQuota:          resource.MustParse("10"),
+					Used:                  resource.MustParse("2"),
+					UtilizationPercentage: 20,
+				},
+				{
+					Resource:       corev1.ResourceCPU,
+					Flavor:         "spot",
+					NominalQuota:   resource.MustParse("0"),
+					BorrowingLimit: pointer.Quantity(resource.MustParse("10")),
+					Used:           resource.MustParse("5"),
+					Borrowed:       pointer.Quantity(resource.MustParse("5")),
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantUsages, cache.ClusterQueuesUsage(SortByUtilization)); diff != "" {
+		t.Errorf("Unexpected usage sorted by utilization (-want,+got):\n%s", diff)
+	}
+
+	var gotNames []string
+	for _, u := range cache.ClusterQueuesUsage(SortByBorrowing) {
+		gotNames = append(gotNames, u.Name)
+	}
+	if diff := cmp.Diff([]string{"a", "c", "b"}, gotNames); diff != "" {
+		t.Errorf("Unexpected order sorted by borrowing (-want,+got):\n%s", diff)
+	}
+}
@@ -24,8 +24,56 @@ const (
 	// TODO(#23): Use the kubernetes.io domain when graduating APIs to beta.
 	QueueAnnotation = "kueue.x-k8s.io/queue-name"
 
-	ManagerName       = "kueue-manager"
-	JobControllerName = "kueue-job-controller"
+	// JobMinParallelismAnnotation is the annotation in the job that holds
+	// the minimum parallelism with which the job can run. It allows the
+	// workload to be admitted with fewer pods when the quota doesn't allow
+	// running all of them. It becomes the minCount of the podSet.
+	JobMinParallelismAnnotation = "kueue.x-k8s.io/job-min-parallelism"
+
+	// PlacementHintsAnnotation is the annotation in the job that holds
+	// additional requirements for the nodes of its pods, in label selector
+	// syntax, like "topology.kubernetes.io/zone in (us-east1-b,us-east1-c)".
+	// They become the placement hints of the workload.
+	PlacementHintsAnnotation = "kueue.x-k8s.io/placement-hints"
+
+	// PodSetsHashAnnotation is the annotation in the workload, and in the
+	// job while it runs, that holds the hash of the pod sets.
+	PodSetsHashAnnotation = "kueue.x-k8s.io/podsets-hash"
+
+	// AdmissionAnnotation is the annotation in a running job that holds the
+	// admission, in JSON, of the workload that the job was started with.
+	AdmissionAnnotation = "kueue.x-k8s.io/admission"
+
+	// RequeueAdmittedWorkloadsAnnotation is the annotation in a Queue or
+	// ClusterQueue that, when set to "true", makes kueue evict all the
+	// workloads admitted through it and put them back in their queues.
+	// The annotation is removed once all the workloads are requeued.
+	RequeueAdmittedWorkloadsAnnotation = "kueue.x-k8s.io/requeue-admitted-workloads"
+
+	// WorkloadPriorityClassLabel is the label in the job that holds the name
+	// of the WorkloadPriorityClass of its workload. It takes precedence over
+	// the priority class of the pods.
+	WorkloadPriorityClassLabel = "kueue.x-k8s.io/priority-class"
+
+	// ResourceInUseFinalizerName is the finalizer that kueue adds to the
+	// ResourceFlavors, so that they are only removed once no ClusterQueue
+	// references them.
+	ResourceInUseFinalizerName = "kueue.x-k8s.io/resource-in-use"
+
+	// MultiKueueClusterAnnotation is the annotation in the workload that
+	// holds the name of the MultiKueueCluster in which it runs. The job of
+	// the workload is left suspended in the local cluster.
+	MultiKueueClusterAnnotation = "kueue.x-k8s.io/multikueue-cluster"
+
+	// MultiKueueOriginLabel is the label in the workloads and jobs that
+	// kueue dispatched to a remote cluster, with the name of the cluster
+	// that they come from.
+	MultiKueueOriginLabel = "kueue.x-k8s.io/multikueue-origin"
+
+	ManagerName                       = "kueue-manager"
+	JobControllerName                 = "kueue-job-controller"
+	ProvisioningRequestControllerName = "kueue-provisioning-request-controller"
+	MultiKueueControllerName          = "kueue-multikueue-controller"
 
 	// UpdatesBatchPeriod is the batch period to hold workload updates
 	// before syncing a Queue and ClusterQueue objects.
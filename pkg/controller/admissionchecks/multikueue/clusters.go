@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multikueue
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+)
+
+// clustersReconciler builds the clients of the MultiKueueClusters from the
+// kubeconfigs in their Secrets, and reports in the Active condition whether
+// the clusters can be reached.
+type clustersReconciler struct {
+	client     client.Client
+	namespace  string
+	clients    *remoteClients
+	newClient  func(kubeConfig []byte) (client.Client, error)
+	retryAfter time.Duration
+}
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=multikueueclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=multikueueclusters/status,verbs=get;update;patch
+
+func (r *clustersReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var cluster kueue.MultiKueueCluster
+	if err := r.client.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.clients.remove(req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	log := ctrl.LoggerFrom(ctx).WithValues("multiKueueCluster", klog.KObj(&cluster))
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	condition, err := r.connect(ctx, &cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var result ctrl.Result
+	if condition.Status != metav1.ConditionTrue {
+		log.V(2).Info("Cluster is not active", "reason", condition.Reason, "message", condition.Message)
+		r.clients.remove(cluster.Name)
+		result.RequeueAfter = r.retryAfter
+	}
+	if old := meta.FindStatusCondition(cluster.Status.Conditions, kueue.MultiKueueClusterActive); old != nil &&
+		old.Status == condition.Status && old.Reason == condition.Reason && old.Message == condition.Message {
+		return result, nil
+	}
+	newCluster := cluster.DeepCopy()
+	meta.SetStatusCondition(&newCluster.Status.Conditions, condition)
+	return result, client.IgnoreNotFound(r.client.Status().Update(ctx, newCluster))
+}
+
+// connect builds the client of the cluster, unless its kubeconfig didn't
+// change, and checks that the cluster can be reached. It returns the Active
+// condition of the cluster.
+func (r *clustersReconciler) connect(ctx context.Context, cluster *kueue.MultiKueueCluster) (metav1.Condition, error) {
+	ref := cluster.Spec.KubeConfig
+	key := ref.Key
+	if key == "" {
+		key = defaultKubeConfigKey
+	}
+	var secret corev1.Secret
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: r.namespace, Name: ref.SecretName}, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return inactive("SecretNotFound", fmt.Sprintf("Secret %s not found", ref.SecretName)), nil
+		}
+		return metav1.Condition{}, err
+	}
+	kubeConfig, ok := secret.Data[key]
+	if !ok {
+		return inactive("BadKubeConfig", fmt.Sprintf("Secret %s has no key %s", ref.SecretName, key)), nil
+	}
+
+	r.clients.RLock()
+	current := r.clients.clients[cluster.Name]
+	r.clients.RUnlock()
+	var remote client.Client
+	if current != nil && bytes.Equal(current.kubeConfig, kubeConfig) {
+		remote = current.client
+	} else {
+		var err error
+		if remote, err = r.newClient(kubeConfig); err != nil {
+			return inactive("BadKubeConfig", err.Error()), nil
+		}
+	}
+	if err := remote.List(ctx, &kueue.WorkloadList{}, client.Limit(1)); err != nil {
+		return inactive("ClientConnectionFailed", err.Error()), nil
+	}
+	r.clients.set(cluster.Name, remote, kubeConfig)
+	return metav1.Condition{
+		Type:    kueue.MultiKueueClusterActive,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Active",
+		Message: "Connected",
+	}, nil
+}
+
+func inactive(reason, message string) metav1.Condition {
+	return metav1.Condition{
+		Type:    kueue.MultiKueueClusterActive,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// clustersForSecret returns the MultiKueueClusters whose kubeconfig is in
+// the Secret.
+func (r *clustersReconciler) clustersForSecret(obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != r.namespace {
+		return nil
+	}
+	var clusters kueue.MultiKueueClusterList
+	if err := r.client.List(context.Background(), &clusters); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, c := range clusters.Items {
+		if c.Spec.KubeConfig.SecretName == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: c.Name}})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *clustersReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("multikueuecluster").
+		For(&kueue.MultiKueueCluster{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.clustersForSecret)).
+		Complete(r)
+}
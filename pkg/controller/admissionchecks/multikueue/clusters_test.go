@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multikueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+)
+
+func TestClustersReconcile(t *testing.T) {
+	cases := map[string]struct {
+		secret        *corev1.Secret
+		wantCondition metav1.Condition
+		wantActive    bool
+	}{
+		"connects to the cluster": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "kueue-system"},
+				Data:       map[string][]byte{"kubeconfig": []byte("good")},
+			},
+			wantCondition: metav1.Condition{
+				Type:    kueue.MultiKueueClusterActive,
+				Status:  metav1.ConditionTrue,
+				Reason:  "Active",
+				Message: "Connected",
+			},
+			wantActive: true,
+		},
+		"reports a missing secret": {
+			wantCondition: metav1.Condition{
+				Type:    kueue.MultiKueueClusterActive,
+				Status:  metav1.ConditionFalse,
+				Reason:  "SecretNotFound",
+				Message: "Secret worker not found",
+			},
+		},
+		"reports a secret in another namespace as missing": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"},
+				Data:       map[string][]byte{"kubeconfig": []byte("good")},
+			},
+			wantCondition: metav1.Condition{
+				Type:    kueue.MultiKueueClusterActive,
+				Status:  metav1.ConditionFalse,
+				Reason:  "SecretNotFound",
+				Message: "Secret worker not found",
+			},
+		},
+		"reports a missing key": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "kueue-system"},
+				Data:       map[string][]byte{"config": []byte("good")},
+			},
+			wantCondition: metav1.Condition{
+				Type:    kueue.MultiKueueClusterActive,
+				Status:  metav1.ConditionFalse,
+				Reason:  "BadKubeConfig",
+				Message: "Secret worker has no key kubeconfig",
+			},
+		},
+		"reports an invalid kubeconfig": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "kueue-system"},
+				Data:       map[string][]byte{"kubeconfig": []byte("bad")},
+			},
+			wantCondition: metav1.Condition{
+				Type:    kueue.MultiKueueClusterActive,
+				Status:  metav1.ConditionFalse,
+				Reason:  "BadKubeConfig",
+				Message: "invalid kubeconfig",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding client-go scheme: %v", err)
+			}
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			objs := []client.Object{&kueue.MultiKueueCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker"},
+				Spec: kueue.MultiKueueClusterSpec{
+					KubeConfig: kueue.KubeConfigReference{SecretName: "worker"},
+				},
+			}}
+			if tc.secret != nil {
+				objs = append(objs, tc.secret)
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+			clients := newRemoteClients()
+			r := &clustersReconciler{
+				client:    cl,
+				namespace: "kueue-system",
+				clients:   clients,
+				newClient: func(kubeConfig []byte) (client.Client, error) {
+					if string(kubeConfig) != "good" {
+						return nil, errors.New("invalid kubeconfig")
+					}
+					return fake.NewClientBuilder().WithScheme(scheme).Build(), nil
+				},
+				retryAfter: time.Second,
+			}
+			ctx := ctrl.LoggerInto(context.Background(), ctrl.Log)
+			key := types.NamespacedName{Name: "worker"}
+
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile failed: %v", err)
+			}
+			if gotRequeue := result.RequeueAfter > 0; gotRequeue == tc.wantActive {
+				t.Errorf("Reconcile requeued after %v for an active cluster: %t", result.RequeueAfter, tc.wantActive)
+			}
+			var cluster kueue.MultiKueueCluster
+			if err := cl.Get(ctx, key, &cluster); err != nil {
+				t.Fatalf("Failed getting the MultiKueueCluster: %v", err)
+			}
+			if diff := cmp.Diff([]metav1.Condition{tc.wantCondition}, cluster.Status.Conditions, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("Unexpected conditions (-want,+got):\n%s", diff)
+			}
+			if gotActive := clients.get("worker") != nil; gotActive != tc.wantActive {
+				t.Errorf("Got active client %t, want %t", gotActive, tc.wantActive)
+			}
+		})
+	}
+}
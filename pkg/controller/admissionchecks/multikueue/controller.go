@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multikueue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ControllerName is the controllerName of the AdmissionChecks that this
+	// controller evaluates.
+	ControllerName = "kueue.x-k8s.io/multikueue"
+
+	// DefaultOrigin is the value of the origin label of the remote objects
+	// when none is configured.
+	DefaultOrigin = "multikueue"
+
+	defaultSyncPeriod = 10 * time.Second
+
+	defaultKubeConfigKey = "kubeconfig"
+)
+
+type options struct {
+	origin     string
+	syncPeriod time.Duration
+}
+
+// Option configures the reconcilers.
+type Option func(*options)
+
+// WithOrigin sets the value of the kueue.x-k8s.io/multikueue-origin label
+// of the remote objects, which identifies the cluster that created them.
+// Clusters sharing the same remote clusters need different origins.
+// Defaults to DefaultOrigin.
+func WithOrigin(origin string) Option {
+	return func(o *options) {
+		o.origin = origin
+	}
+}
+
+// WithSyncPeriod sets how often the remote workloads and jobs are polled,
+// and how often unreachable clusters are retried. Defaults to 10 seconds.
+func WithSyncPeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.syncPeriod = d
+	}
+}
+
+// SetupControllers sets up the controllers that connect to the
+// MultiKueueClusters, with the kubeconfigs in the Secrets of the given
+// namespace, and that dispatch the workloads to them.
+func SetupControllers(mgr ctrl.Manager, namespace string, opts ...Option) error {
+	options := options{origin: DefaultOrigin, syncPeriod: defaultSyncPeriod}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	clients := newRemoteClients()
+	cr := &clustersReconciler{
+		client:     mgr.GetClient(),
+		namespace:  namespace,
+		clients:    clients,
+		newClient:  newRemoteClient(mgr.GetScheme()),
+		retryAfter: options.syncPeriod,
+	}
+	if err := cr.SetupWithManager(mgr); err != nil {
+		return err
+	}
+	wr := &workloadReconciler{
+		client:     mgr.GetClient(),
+		clients:    clients,
+		origin:     options.origin,
+		syncPeriod: options.syncPeriod,
+	}
+	return wr.SetupWithManager(mgr)
+}
+
+// newRemoteClient returns the function that builds the client of a remote
+// cluster from its kubeconfig.
+func newRemoteClient(scheme *runtime.Scheme) func([]byte) (client.Client, error) {
+	return func(kubeConfig []byte) (client.Client, error) {
+		cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+		if err != nil {
+			return nil, err
+		}
+		return client.New(cfg, client.Options{Scheme: scheme})
+	}
+}
+
+type remoteClient struct {
+	client     client.Client
+	kubeConfig []byte
+}
+
+// remoteClients holds the clients of the active MultiKueueClusters.
+type remoteClients struct {
+	sync.RWMutex
+	clients map[string]*remoteClient
+}
+
+func newRemoteClients() *remoteClients {
+	return &remoteClients{clients: make(map[string]*remoteClient)}
+}
+
+// get returns the client of the cluster, or nil if it isn't active.
+func (r *remoteClients) get(name string) client.Client {
+	r.RLock()
+	defer r.RUnlock()
+	if rc := r.clients[name]; rc != nil {
+		return rc.client
+	}
+	return nil
+}
+
+func (r *remoteClients) set(name string, c client.Client, kubeConfig []byte) {
+	r.Lock()
+	defer r.Unlock()
+	r.clients[name] = &remoteClient{client: c, kubeConfig: kubeConfig}
+}
+
+func (r *remoteClients) remove(name string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.clients, name)
+}
+
+// names returns the names of the active clusters, sorted.
+func (r *remoteClients) names() []string {
+	r.RLock()
+	defer r.RUnlock()
+	names := make([]string, 0, len(r.clients))
+	for name := range r.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,445 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multikueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// workloadReconciler evaluates the AdmissionChecks with the ControllerName.
+// It creates a copy of each workload with a quota reservation in the
+// clusters of the MultiKueueConfig of the check, and marks the check Ready
+// once one of them admits its copy. The copies in the other clusters are
+// deleted. The job of the workload is then created in that cluster, with the
+// admitted copy as its workload, while the local job stays suspended. The
+// local job gets the Complete or Failed condition of the remote job when it
+// finishes. The check of a workload owned by another kind of object is
+// rejected, since only batch Jobs can run remotely.
+type workloadReconciler struct {
+	client     client.Client
+	clients    *remoteClients
+	origin     string
+	syncPeriod time.Duration
+}
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;watch;update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=admissionchecks,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=multikueueconfigs,verbs=get;list;watch
+
+func (r *workloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var wl kueue.Workload
+	if err := r.client.Get(ctx, req.NamespacedName, &wl); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.deleteRemoteObjects(ctx, req.NamespacedName, r.clients.names())
+		}
+		return ctrl.Result{}, err
+	}
+	log := ctrl.LoggerFrom(ctx).WithValues("workload", klog.KObj(&wl))
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	if !workload.HasQuotaReservation(&wl) || workload.InCondition(&wl, kueue.WorkloadFinished) {
+		// The workload only runs remotely while it holds the reservation.
+		if err := r.deleteRemoteObjects(ctx, req.NamespacedName, r.clients.names()); err != nil {
+			return ctrl.Result{}, err
+		}
+		if _, ok := wl.Annotations[constants.MultiKueueClusterAnnotation]; !ok {
+			return ctrl.Result{}, nil
+		}
+		newWl := wl.DeepCopy()
+		delete(newWl.Annotations, constants.MultiKueueClusterAnnotation)
+		return ctrl.Result{}, client.IgnoreNotFound(r.client.Update(ctx, newWl))
+	}
+	check, clusters, err := r.multiKueueCheck(ctx, &wl)
+	if err != nil || check == "" {
+		return ctrl.Result{}, err
+	}
+	state := workload.FindAdmissionCheck(wl.Status.AdmissionChecks, check)
+	if state == nil {
+		// The workload controller initializes the checks after recording
+		// the reservation.
+		return ctrl.Result{}, nil
+	}
+	switch state.State {
+	case kueue.CheckStatePending:
+		if owner := metav1.GetControllerOf(&wl); owner != nil && !isBatchJob(owner) {
+			log.V(2).Info("Rejecting the workload of an unsupported owner", "kind", owner.Kind, "apiVersion", owner.APIVersion)
+			newWl := wl.DeepCopy()
+			setCheckState(workload.FindAdmissionCheck(newWl.Status.AdmissionChecks, check), kueue.CheckStateRejected,
+				fmt.Sprintf("MultiKueue can't run the workloads owned by a %s of %s, only batch/v1 Jobs", owner.Kind, owner.APIVersion))
+			return ctrl.Result{}, client.IgnoreNotFound(r.client.Status().Update(ctx, newWl))
+		}
+		return r.dispatch(ctx, &wl, check, clusters)
+	case kueue.CheckStateReady:
+		return r.sync(ctx, &wl, check)
+	}
+	return ctrl.Result{}, nil
+}
+
+// dispatch creates the copies of the workload in the clusters, and marks the
+// check Ready once a cluster admits its copy.
+func (r *workloadReconciler) dispatch(ctx context.Context, wl *kueue.Workload, check string, clusters []string) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	key := types.NamespacedName{Namespace: wl.Namespace, Name: wl.Name}
+	var admittedIn string
+	active := 0
+	for _, name := range clusters {
+		remote := r.clients.get(name)
+		if remote == nil {
+			continue
+		}
+		active++
+		var remoteWl kueue.Workload
+		err := remote.Get(ctx, key, &remoteWl)
+		if apierrors.IsNotFound(err) {
+			if err := remote.Create(ctx, r.remoteWorkload(wl)); err != nil && !apierrors.IsAlreadyExists(err) {
+				log.Error(err, "Creating the remote workload", "multiKueueCluster", name)
+			}
+			continue
+		}
+		if err != nil {
+			log.Error(err, "Getting the remote workload", "multiKueueCluster", name)
+			continue
+		}
+		if admittedIn == "" && r.isCopy(&remoteWl) && workload.IsAdmitted(&remoteWl) {
+			admittedIn = name
+		}
+	}
+
+	newWl := wl.DeepCopy()
+	state := workload.FindAdmissionCheck(newWl.Status.AdmissionChecks, check)
+	if admittedIn == "" {
+		message := "Waiting for a MultiKueueCluster to admit the workload"
+		if active == 0 {
+			message = "No active MultiKueueClusters"
+		}
+		setCheckState(state, kueue.CheckStatePending, message)
+		if err := r.updateStatusIfChanged(ctx, wl, newWl); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.syncPeriod}, nil
+	}
+
+	log.V(2).Info("Workload admitted in a remote cluster", "multiKueueCluster", admittedIn)
+	var others []string
+	for _, name := range clusters {
+		if name != admittedIn {
+			others = append(others, name)
+		}
+	}
+	if err := r.deleteRemoteObjects(ctx, key, others); err != nil {
+		return ctrl.Result{}, err
+	}
+	if newWl.Annotations[constants.MultiKueueClusterAnnotation] != admittedIn {
+		if newWl.Annotations == nil {
+			newWl.Annotations = make(map[string]string, 1)
+		}
+		newWl.Annotations[constants.MultiKueueClusterAnnotation] = admittedIn
+		// The job must not start locally once the check is Ready.
+		if err := r.client.Update(ctx, newWl); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		state = workload.FindAdmissionCheck(newWl.Status.AdmissionChecks, check)
+	}
+	setCheckState(state, kueue.CheckStateReady, fmt.Sprintf("The workload was admitted in MultiKueueCluster %s", admittedIn))
+	if err := r.client.Status().Update(ctx, newWl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{RequeueAfter: r.syncPeriod}, nil
+}
+
+// sync runs the job of the workload in the cluster that admitted it, and
+// copies back the terminal condition of the remote job and the Finished
+// condition of the remote workload. The check requests a retry if the remote workload is no
+// longer admitted.
+func (r *workloadReconciler) sync(ctx context.Context, wl *kueue.Workload, check string) (ctrl.Result, error) {
+	cluster := wl.Annotations[constants.MultiKueueClusterAnnotation]
+	remote := r.clients.get(cluster)
+	if remote == nil {
+		// Wait for the cluster to be reachable again.
+		return ctrl.Result{RequeueAfter: r.syncPeriod}, nil
+	}
+	var remoteWl kueue.Workload
+	err := remote.Get(ctx, types.NamespacedName{Namespace: wl.Namespace, Name: wl.Name}, &remoteWl)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if apierrors.IsNotFound(err) || !r.isCopy(&remoteWl) ||
+		(!workload.IsAdmitted(&remoteWl) && !workload.InCondition(&remoteWl, kueue.WorkloadFinished)) {
+		ctrl.LoggerFrom(ctx).V(2).Info("Remote workload no longer admitted, requesting a retry", "multiKueueCluster", cluster)
+		newWl := wl.DeepCopy()
+		state := workload.FindAdmissionCheck(newWl.Status.AdmissionChecks, check)
+		setCheckState(state, kueue.CheckStateRetry, fmt.Sprintf("The workload is no longer admitted in MultiKueueCluster %s", cluster))
+		return ctrl.Result{}, client.IgnoreNotFound(r.client.Status().Update(ctx, newWl))
+	}
+	if err := r.syncJob(ctx, wl, remote, &remoteWl); err != nil {
+		return ctrl.Result{}, err
+	}
+	if i := workload.FindConditionIndex(&remoteWl.Status, kueue.WorkloadFinished); i != -1 && remoteWl.Status.Conditions[i].Status == corev1.ConditionTrue {
+		finished := remoteWl.Status.Conditions[i]
+		err := workload.UpdateStatus(ctx, r.client, wl, kueue.WorkloadFinished, corev1.ConditionTrue, finished.Reason, finished.Message)
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{RequeueAfter: r.syncPeriod}, nil
+}
+
+// syncJob creates the job that owns the workload, if it's a batch Job, in
+// the remote cluster, and copies the Complete or Failed condition of the
+// remote job to the local one, which finishes the workload. The rest of the
+// status is left alone, as the local job controller owns it. The remote job
+// is created suspended, and the remote kueue starts it with the admission of
+// the copy of the workload, which is made its workload.
+func (r *workloadReconciler) syncJob(ctx context.Context, wl *kueue.Workload, remote client.Client, remoteWl *kueue.Workload) error {
+	owner := metav1.GetControllerOf(wl)
+	if owner == nil || !isBatchJob(owner) {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: wl.Namespace, Name: owner.Name}
+	var job batchv1.Job
+	if err := r.client.Get(ctx, key, &job); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	var remoteJob batchv1.Job
+	err := remote.Get(ctx, key, &remoteJob)
+	if apierrors.IsNotFound(err) {
+		remoteJob = *r.remoteJob(&job)
+		if err := remote.Create(ctx, &remoteJob); err != nil {
+			return err
+		}
+		ctrl.LoggerFrom(ctx).V(2).Info("Created the remote job", "job", klog.KObj(&job))
+	} else if err != nil {
+		return err
+	}
+	if metav1.GetControllerOf(remoteWl) == nil {
+		remoteWl.OwnerReferences = append(remoteWl.OwnerReferences, *metav1.NewControllerRef(&remoteJob, batchv1.SchemeGroupVersion.WithKind("Job")))
+		if err := remote.Update(ctx, remoteWl); err != nil {
+			return err
+		}
+	}
+	terminal := terminalCondition(&remoteJob)
+	if terminal == nil || terminalCondition(&job) != nil {
+		return nil
+	}
+	job.Status.Conditions = append(job.Status.Conditions, *terminal.DeepCopy())
+	return client.IgnoreNotFound(r.client.Status().Update(ctx, &job))
+}
+
+// isBatchJob returns whether the owner reference points to a batch/v1 Job.
+func isBatchJob(owner *metav1.OwnerReference) bool {
+	return owner.Kind == "Job" && owner.APIVersion == batchv1.SchemeGroupVersion.String()
+}
+
+// terminalCondition returns the Complete or Failed condition of the job, if
+// it finished.
+func terminalCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		c := &job.Status.Conditions[i]
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return c
+		}
+	}
+	return nil
+}
+
+// multiKueueCheck returns the first AdmissionCheck of the workload with the
+// ControllerName, and the clusters of its MultiKueueConfig.
+func (r *workloadReconciler) multiKueueCheck(ctx context.Context, wl *kueue.Workload) (string, []string, error) {
+	for _, name := range wl.Spec.Admission.AdmissionChecks {
+		var ac kueue.AdmissionCheck
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name}, &ac); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", nil, err
+		}
+		if ac.Spec.ControllerName != ControllerName {
+			continue
+		}
+		params := ac.Spec.Parameters
+		if params == nil || params.APIGroup != kueue.GroupVersion.Group || params.Kind != "MultiKueueConfig" {
+			return name, nil, nil
+		}
+		var cfg kueue.MultiKueueConfig
+		if err := r.client.Get(ctx, types.NamespacedName{Name: params.Name}, &cfg); err != nil {
+			return name, nil, client.IgnoreNotFound(err)
+		}
+		return name, cfg.Spec.Clusters, nil
+	}
+	return "", nil, nil
+}
+
+// remoteWorkload returns the copy of the workload to create in a remote
+// cluster, without its reservation.
+func (r *workloadReconciler) remoteWorkload(wl *kueue.Workload) *kueue.Workload {
+	remote := &kueue.Workload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        wl.Name,
+			Namespace:   wl.Namespace,
+			Labels:      r.withOrigin(wl.Labels),
+			Annotations: make(map[string]string, len(wl.Annotations)),
+		},
+		Spec: *wl.Spec.DeepCopy(),
+	}
+	for k, v := range wl.Annotations {
+		if k != constants.MultiKueueClusterAnnotation {
+			remote.Annotations[k] = v
+		}
+	}
+	remote.Spec.Admission = nil
+	return remote
+}
+
+// remoteJob returns the copy of the job to create in a remote cluster,
+// suspended and without the fields generated for the local job.
+func (r *workloadReconciler) remoteJob(job *batchv1.Job) *batchv1.Job {
+	remote := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        job.Name,
+			Namespace:   job.Namespace,
+			Labels:      r.withOrigin(job.Labels),
+			Annotations: make(map[string]string, len(job.Annotations)),
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+	for k, v := range job.Annotations {
+		if k != constants.AdmissionAnnotation && k != constants.PodSetsHashAnnotation {
+			remote.Annotations[k] = v
+		}
+	}
+	remote.Spec.Selector = nil
+	remote.Spec.ManualSelector = nil
+	delete(remote.Spec.Template.Labels, "controller-uid")
+	delete(remote.Spec.Template.Labels, "job-name")
+	remote.Spec.Suspend = pointer.Bool(true)
+	return remote
+}
+
+// deleteRemoteObjects deletes the copies of the workload and its job in the
+// given clusters.
+func (r *workloadReconciler) deleteRemoteObjects(ctx context.Context, key types.NamespacedName, clusters []string) error {
+	for _, name := range clusters {
+		remote := r.clients.get(name)
+		if remote == nil {
+			continue
+		}
+		var remoteWl kueue.Workload
+		if err := r.deleteCopy(ctx, remote, key, &remoteWl); err != nil {
+			return err
+		}
+		var remoteJob batchv1.Job
+		if err := r.deleteCopy(ctx, remote, key, &remoteJob, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCopy deletes the remote object if it was created by this cluster.
+func (r *workloadReconciler) deleteCopy(ctx context.Context, remote client.Client, key types.NamespacedName, obj client.Object, opts ...client.DeleteOption) error {
+	if err := remote.Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !r.isCopy(obj) {
+		return nil
+	}
+	return client.IgnoreNotFound(remote.Delete(ctx, obj, opts...))
+}
+
+// isCopy returns whether the remote object was created by this cluster.
+func (r *workloadReconciler) isCopy(obj client.Object) bool {
+	return obj.GetLabels()[constants.MultiKueueOriginLabel] == r.origin
+}
+
+func (r *workloadReconciler) withOrigin(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[constants.MultiKueueOriginLabel] = r.origin
+	return result
+}
+
+func (r *workloadReconciler) updateStatusIfChanged(ctx context.Context, wl, newWl *kueue.Workload) error {
+	if equality.Semantic.DeepEqual(wl.Status, newWl.Status) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.client.Status().Update(ctx, newWl))
+}
+
+// setCheckState sets the state and message of the check, updating its
+// transition time if the state changed.
+func setCheckState(state *kueue.AdmissionCheckState, checkState kueue.CheckState, message string) {
+	if state.State != checkState {
+		state.State = checkState
+		state.LastTransitionTime = metav1.Now()
+	}
+	state.Message = message
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *workloadReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("multikueue").
+		For(&kueue.Workload{}, builder.WithPredicates(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return isCandidate(e.Object.(*kueue.Workload))
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return isCandidate(e.ObjectOld.(*kueue.Workload)) || isCandidate(e.ObjectNew.(*kueue.Workload))
+			},
+			// The copies of a deleted workload are deleted.
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return isCandidate(e.Object.(*kueue.Workload))
+			},
+			GenericFunc: func(event.GenericEvent) bool {
+				return false
+			},
+		})).
+		Complete(r)
+}
+
+// isCandidate returns whether the workload could have copies in remote
+// clusters.
+func isCandidate(wl *kueue.Workload) bool {
+	if _, ok := wl.Annotations[constants.MultiKueueClusterAnnotation]; ok {
+		return true
+	}
+	return wl.Spec.Admission != nil && len(wl.Spec.Admission.AdmissionChecks) > 0
+}
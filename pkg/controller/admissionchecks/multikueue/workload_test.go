@@ -0,0 +1,372 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multikueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+)
+
+func TestWorkloadReconcile(t *testing.T) {
+	makeWorkload := func(state kueue.CheckState, cluster string) *utiltesting.WorkloadWrapper {
+		w := utiltesting.MakeWorkload("wl", "ns").Queue("q").
+			Admit(utiltesting.MakeAdmission("cq").AdmissionChecks("dispatch").Obj()).
+			Condition(kueue.WorkloadQuotaReserved, corev1.ConditionTrue)
+		w.Status.AdmissionChecks = []kueue.AdmissionCheckState{{Name: "dispatch", State: state}}
+		if cluster != "" {
+			w.Annotations = map[string]string{constants.MultiKueueClusterAnnotation: cluster}
+		}
+		return w
+	}
+	withJobOwner := func(w *utiltesting.WorkloadWrapper) *utiltesting.WorkloadWrapper {
+		w.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+			Name:       "wl",
+			UID:        "job-uid",
+			Controller: pointer.Bool(true),
+		}}
+		return w
+	}
+	remoteCopy := func(origin string) *utiltesting.WorkloadWrapper {
+		w := utiltesting.MakeWorkload("wl", "ns").Queue("q")
+		w.Labels = map[string]string{constants.MultiKueueOriginLabel: origin}
+		return w
+	}
+	admittedCopy := func() *utiltesting.WorkloadWrapper {
+		return remoteCopy(DefaultOrigin).Admit(utiltesting.MakeAdmission("remote-cq").Obj())
+	}
+
+	cases := map[string]struct {
+		workload         *kueue.Workload
+		job              *batchv1.Job
+		noActiveClusters bool
+		remoteObjects    map[string][]client.Object
+		wantChecks       []kueue.AdmissionCheckState
+		wantCluster      string
+		wantCopies       map[string]bool
+		wantRemoteJobs   map[string]bool
+		wantJobStatus    *batchv1.JobStatus
+		wantFinished     bool
+		wantRequeue      bool
+		wantRemoteOwned  bool
+		wantSuspended    bool
+	}{
+		"creates the copies in the active clusters": {
+			workload: makeWorkload(kueue.CheckStatePending, "").Obj(),
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "dispatch", State: kueue.CheckStatePending, Message: "Waiting for a MultiKueueCluster to admit the workload"},
+			},
+			wantCopies:  map[string]bool{"w1": true, "w2": true},
+			wantRequeue: true,
+		},
+		"waits for active clusters": {
+			workload:         makeWorkload(kueue.CheckStatePending, "").Obj(),
+			noActiveClusters: true,
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "dispatch", State: kueue.CheckStatePending, Message: "No active MultiKueueClusters"},
+			},
+			wantRequeue: true,
+		},
+		"marks the check ready when a cluster admits the copy": {
+			workload: makeWorkload(kueue.CheckStatePending, "").Obj(),
+			remoteObjects: map[string][]client.Object{
+				"w1": {remoteCopy(DefaultOrigin).Obj()},
+				"w2": {admittedCopy().Obj()},
+			},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "dispatch", State: kueue.CheckStateReady, Message: "The workload was admitted in MultiKueueCluster w2"},
+			},
+			wantCluster: "w2",
+			wantCopies:  map[string]bool{"w2": true},
+			wantRequeue: true,
+		},
+		"ignores the workloads of other origins": {
+			workload: makeWorkload(kueue.CheckStatePending, "").Obj(),
+			remoteObjects: map[string][]client.Object{
+				"w1": {remoteCopy("other").Admit(utiltesting.MakeAdmission("remote-cq").Obj()).Obj()},
+				"w2": {remoteCopy(DefaultOrigin).Obj()},
+			},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "dispatch", State: kueue.CheckStatePending, Message: "Waiting for a MultiKueueCluster to admit the workload"},
+			},
+			wantCopies:  map[string]bool{"w1": true, "w2": true},
+			wantRequeue: true,
+		},
+		"creates the job in the cluster that admitted the workload": {
+			workload: withJobOwner(makeWorkload(kueue.CheckStateReady, "w2")).Obj(),
+			job:      utiltesting.MakeJob("wl", "ns").Obj(),
+			remoteObjects: map[string][]client.Object{
+				"w2": {admittedCopy().Obj()},
+			},
+			wantChecks:      []kueue.AdmissionCheckState{{Name: "dispatch", State: kueue.CheckStateReady}},
+			wantCluster:     "w2",
+			wantCopies:      map[string]bool{"w2": true},
+			wantRemoteJobs:  map[string]bool{"w2": true},
+			wantJobStatus:   &batchv1.JobStatus{},
+			wantSuspended:   true,
+			wantRequeue:     true,
+			wantRemoteOwned: true,
+		},
+		"doesn't copy the status of a running remote job": {
+			workload: withJobOwner(makeWorkload(kueue.CheckStateReady, "w2")).Obj(),
+			job:      utiltesting.MakeJob("wl", "ns").Obj(),
+			remoteObjects: map[string][]client.Object{
+				"w2": {
+					admittedCopy().Obj(),
+					func() *batchv1.Job {
+						j := utiltesting.MakeJob("wl", "ns").Suspend(false).Obj()
+						j.Labels = map[string]string{constants.MultiKueueOriginLabel: DefaultOrigin}
+						j.Status.Active = 1
+						return j
+					}(),
+				},
+			},
+			wantChecks:      []kueue.AdmissionCheckState{{Name: "dispatch", State: kueue.CheckStateReady}},
+			wantCluster:     "w2",
+			wantCopies:      map[string]bool{"w2": true},
+			wantRemoteJobs:  map[string]bool{"w2": true},
+			wantJobStatus:   &batchv1.JobStatus{},
+			wantRequeue:     true,
+			wantRemoteOwned: true,
+		},
+		"copies the terminal condition of the remote job": {
+			workload: withJobOwner(makeWorkload(kueue.CheckStateReady, "w2")).Obj(),
+			job:      utiltesting.MakeJob("wl", "ns").Obj(),
+			remoteObjects: map[string][]client.Object{
+				"w2": {
+					admittedCopy().Obj(),
+					func() *batchv1.Job {
+						j := utiltesting.MakeJob("wl", "ns").Suspend(false).Obj()
+						j.Labels = map[string]string{constants.MultiKueueOriginLabel: DefaultOrigin}
+						j.Status.Succeeded = 1
+						j.Status.Conditions = []batchv1.JobCondition{{
+							Type:   batchv1.JobComplete,
+							Status: corev1.ConditionTrue,
+							Reason: "Completed",
+						}}
+						return j
+					}(),
+				},
+			},
+			wantChecks:     []kueue.AdmissionCheckState{{Name: "dispatch", State: kueue.CheckStateReady}},
+			wantCluster:    "w2",
+			wantCopies:     map[string]bool{"w2": true},
+			wantRemoteJobs: map[string]bool{"w2": true},
+			wantJobStatus: &batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+				Type:   batchv1.JobComplete,
+				Status: corev1.ConditionTrue,
+				Reason: "Completed",
+			}}},
+			wantRequeue:     true,
+			wantRemoteOwned: true,
+		},
+		"rejects the workloads of unsupported owners": {
+			workload: func() *kueue.Workload {
+				w := makeWorkload(kueue.CheckStatePending, "").Obj()
+				w.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: "kubeflow.org/v1",
+					Kind:       "MPIJob",
+					Name:       "wl",
+					UID:        "mpijob-uid",
+					Controller: pointer.Bool(true),
+				}}
+				return w
+			}(),
+			wantChecks: []kueue.AdmissionCheckState{{
+				Name:    "dispatch",
+				State:   kueue.CheckStateRejected,
+				Message: "MultiKueue can't run the workloads owned by a MPIJob of kubeflow.org/v1, only batch/v1 Jobs",
+			}},
+		},
+		"copies the finished condition of the remote workload": {
+			workload: makeWorkload(kueue.CheckStateReady, "w1").Obj(),
+			remoteObjects: map[string][]client.Object{
+				"w1": {admittedCopy().Condition(kueue.WorkloadFinished, corev1.ConditionTrue).Obj()},
+			},
+			wantChecks:   []kueue.AdmissionCheckState{{Name: "dispatch", State: kueue.CheckStateReady}},
+			wantCluster:  "w1",
+			wantCopies:   map[string]bool{"w1": true},
+			wantFinished: true,
+		},
+		"requests a retry when the remote workload is no longer admitted": {
+			workload: makeWorkload(kueue.CheckStateReady, "w1").Obj(),
+			remoteObjects: map[string][]client.Object{
+				"w1": {remoteCopy(DefaultOrigin).Obj()},
+			},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "dispatch", State: kueue.CheckStateRetry, Message: "The workload is no longer admitted in MultiKueueCluster w1"},
+			},
+			wantCluster: "w1",
+			wantCopies:  map[string]bool{"w1": true},
+		},
+		"deletes the copies when the reservation is released": {
+			workload: func() *kueue.Workload {
+				w := makeWorkload(kueue.CheckStateReady, "w1").Obj()
+				w.Spec.Admission = nil
+				w.Status.AdmissionChecks = nil
+				return w
+			}(),
+			remoteObjects: map[string][]client.Object{
+				"w1": {
+					admittedCopy().Obj(),
+					func() *batchv1.Job {
+						j := utiltesting.MakeJob("wl", "ns").Obj()
+						j.Labels = map[string]string{constants.MultiKueueOriginLabel: DefaultOrigin}
+						return j
+					}(),
+				},
+				"w2": {remoteCopy("other").Obj()},
+			},
+			wantCopies: map[string]bool{"w2": true},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding client-go scheme: %v", err)
+			}
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			objs := []client.Object{
+				tc.workload,
+				&kueue.AdmissionCheck{
+					ObjectMeta: metav1.ObjectMeta{Name: "dispatch"},
+					Spec: kueue.AdmissionCheckSpec{
+						ControllerName: ControllerName,
+						Parameters: &kueue.AdmissionCheckParametersReference{
+							APIGroup: kueue.GroupVersion.Group,
+							Kind:     "MultiKueueConfig",
+							Name:     "workers",
+						},
+					},
+				},
+				&kueue.MultiKueueConfig{
+					ObjectMeta: metav1.ObjectMeta{Name: "workers"},
+					Spec:       kueue.MultiKueueConfigSpec{Clusters: []string{"w1", "w2", "w3"}},
+				},
+			}
+			if tc.job != nil {
+				objs = append(objs, tc.job)
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+			clients := newRemoteClients()
+			if !tc.noActiveClusters {
+				for _, name := range []string{"w1", "w2"} {
+					clients.set(name, fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.remoteObjects[name]...).Build(), nil)
+				}
+			}
+			r := &workloadReconciler{client: cl, clients: clients, origin: DefaultOrigin, syncPeriod: time.Second}
+			ctx := ctrl.LoggerInto(context.Background(), ctrl.Log)
+			key := types.NamespacedName{Namespace: "ns", Name: "wl"}
+
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile failed: %v", err)
+			}
+			if gotRequeue := result.RequeueAfter > 0; gotRequeue != tc.wantRequeue {
+				t.Errorf("Reconcile requeued after %v, want requeue: %t", result.RequeueAfter, tc.wantRequeue)
+			}
+
+			var wl kueue.Workload
+			if err := cl.Get(ctx, key, &wl); err != nil {
+				t.Fatalf("Failed getting workload: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantChecks, wl.Status.AdmissionChecks, cmpopts.IgnoreFields(kueue.AdmissionCheckState{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("Unexpected checks (-want,+got):\n%s", diff)
+			}
+			if got := wl.Annotations[constants.MultiKueueClusterAnnotation]; got != tc.wantCluster {
+				t.Errorf("Got cluster annotation %q, want %q", got, tc.wantCluster)
+			}
+			gotFinished := false
+			for _, c := range wl.Status.Conditions {
+				if c.Type == kueue.WorkloadFinished && c.Status == corev1.ConditionTrue {
+					gotFinished = true
+				}
+			}
+			if gotFinished != tc.wantFinished {
+				t.Errorf("Got finished %t, want %t", gotFinished, tc.wantFinished)
+			}
+
+			gotCopies := make(map[string]bool)
+			gotRemoteJobs := make(map[string]bool)
+			for _, name := range clients.names() {
+				remote := clients.get(name)
+				var remoteWl kueue.Workload
+				if err := remote.Get(ctx, key, &remoteWl); err == nil {
+					gotCopies[name] = true
+					if remoteWl.Spec.QueueName != "q" {
+						t.Errorf("Copy in cluster %s has queue %q, want q", name, remoteWl.Spec.QueueName)
+					}
+					if gotOwned := metav1.GetControllerOf(&remoteWl) != nil; tc.wantRemoteOwned && !gotOwned {
+						t.Errorf("Copy in cluster %s is not owned by the remote job", name)
+					}
+				} else if !apierrors.IsNotFound(err) {
+					t.Fatalf("Failed getting the copy in cluster %s: %v", name, err)
+				}
+				var remoteJob batchv1.Job
+				if err := remote.Get(ctx, key, &remoteJob); err == nil {
+					gotRemoteJobs[name] = true
+					if got := remoteJob.Labels[constants.MultiKueueOriginLabel]; got != DefaultOrigin {
+						t.Errorf("Remote job in cluster %s has origin %q, want %q", name, got, DefaultOrigin)
+					}
+					if tc.wantSuspended && (remoteJob.Spec.Suspend == nil || !*remoteJob.Spec.Suspend) {
+						t.Errorf("Remote job in cluster %s is not suspended", name)
+					}
+				} else if !apierrors.IsNotFound(err) {
+					t.Fatalf("Failed getting the job in cluster %s: %v", name, err)
+				}
+			}
+			if diff := cmp.Diff(tc.wantCopies, gotCopies, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Unexpected copies (-want,+got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantRemoteJobs, gotRemoteJobs, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Unexpected remote jobs (-want,+got):\n%s", diff)
+			}
+			if tc.wantJobStatus != nil {
+				var job batchv1.Job
+				if err := cl.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "wl"}, &job); err != nil {
+					t.Fatalf("Failed getting job: %v", err)
+				}
+				if diff := cmp.Diff(*tc.wantJobStatus, job.Status); diff != "" {
+					t.Errorf("Unexpected job status (-want,+got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+const (
+	// ControllerName is the controllerName of the AdmissionChecks that this
+	// controller evaluates.
+	ControllerName = "kueue.x-k8s.io/provisioning-request"
+
+	// DefaultProvisioningClassName is the class of the ProvisioningRequests
+	// when none is configured. The cluster-autoscaler scales up the nodes
+	// for all the pods of the request, or none of them.
+	DefaultProvisioningClassName = "best-effort-atomic-scale-up.autoscaling.x-k8s.io"
+
+	defaultRetryBaseDelay = time.Minute
+	defaultRetryMaxDelay  = 30 * time.Minute
+)
+
+// Reconciler evaluates the AdmissionChecks with the ControllerName: it
+// creates a ProvisioningRequest for the podSets of each workload with a
+// quota reservation, and marks the check Ready once the cluster-autoscaler
+// provisioned the capacity. Failed ProvisioningRequests are recreated with a
+// backoff, and the check requests a retry of the workload when the retries
+// are exhausted.
+type Reconciler struct {
+	client    client.Client
+	recorder  record.EventRecorder
+	className string
+	retry     RetryBackoff
+}
+
+// RetryBackoff configures the backoff before a failed ProvisioningRequest is
+// recreated.
+type RetryBackoff struct {
+	// LimitCount is the number of times that a failed ProvisioningRequest is
+	// recreated. When the last one fails, the check requests a retry.
+	LimitCount int32
+	// BaseDelay is the delay after the first failure, doubled with every
+	// consecutive failure. Zero means 1 minute.
+	BaseDelay time.Duration
+	// MaxDelay is the maximum delay. Zero means 30 minutes.
+	MaxDelay time.Duration
+}
+
+type options struct {
+	className string
+	retry     RetryBackoff
+}
+
+// Option configures the reconciler.
+type Option func(*options)
+
+// WithProvisioningClassName sets the class of the ProvisioningRequests.
+// Defaults to DefaultProvisioningClassName.
+func WithProvisioningClassName(name string) Option {
+	return func(o *options) {
+		o.className = name
+	}
+}
+
+// WithRetryBackoff sets the backoff before a failed ProvisioningRequest is
+// recreated.
+func WithRetryBackoff(backoff RetryBackoff) Option {
+	return func(o *options) {
+		o.retry = backoff
+	}
+}
+
+func NewReconciler(client client.Client, recorder record.EventRecorder, opts ...Option) *Reconciler {
+	options := options{className: DefaultProvisioningClassName}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	retry := options.retry
+	if retry.BaseDelay == 0 {
+		retry.BaseDelay = defaultRetryBaseDelay
+	}
+	if retry.MaxDelay == 0 {
+		retry.MaxDelay = defaultRetryMaxDelay
+	}
+	return &Reconciler{
+		client:    client,
+		recorder:  recorder,
+		className: options.className,
+		retry:     retry,
+	}
+}
+
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;watch;update
+//+kubebuilder:rbac:groups="",resources=podtemplates,verbs=get;list;watch;create;delete;deletecollection
+//+kubebuilder:rbac:groups=autoscaling.x-k8s.io,resources=provisioningrequests,verbs=get;list;watch;create;delete;deletecollection
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=admissionchecks,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=resourceflavors,verbs=get;list;watch
+
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var wl kueue.Workload
+	if err := r.client.Get(ctx, req.NamespacedName, &wl); err != nil {
+		// The ProvisioningRequests of a deleted workload are garbage collected.
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	log := ctrl.LoggerFrom(ctx).WithValues("workload", klog.KObj(&wl))
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	if !workload.HasQuotaReservation(&wl) || workload.InCondition(&wl, kueue.WorkloadFinished) {
+		// The capacity was only requested for the quota reservation.
+		return ctrl.Result{}, r.deleteProvisioningRequests(ctx, &wl)
+	}
+	reservedAt := reservationTime(&wl)
+	if reservedAt == nil {
+		// The workload controller initializes the checks after recording
+		// the reservation.
+		return ctrl.Result{}, nil
+	}
+	checks, err := r.provisioningChecks(ctx, &wl)
+	if err != nil || len(checks) == 0 {
+		return ctrl.Result{}, err
+	}
+	attempts, err := r.provisioningRequests(ctx, &wl, *reservedAt)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	newWl := wl.DeepCopy()
+	var result ctrl.Result
+	for _, check := range checks {
+		state := workload.FindAdmissionCheck(newWl.Status.AdmissionChecks, check)
+		if state == nil || state.State != kueue.CheckStatePending {
+			continue
+		}
+		requeueAfter, err := r.reconcileCheck(ctx, newWl, state, attempts[check])
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if requeueAfter > 0 && (result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = requeueAfter
+		}
+	}
+	if equality.Semantic.DeepEqual(wl.Status, newWl.Status) {
+		return result, nil
+	}
+	if err := r.client.Status().Update(ctx, newWl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return result, nil
+}
+
+// reconcileCheck updates the state of a pending check from the last
+// ProvisioningRequest created for it, or creates the next one. It returns the
+// time until a failed ProvisioningRequest can be recreated.
+func (r *Reconciler) reconcileCheck(ctx context.Context, wl *kueue.Workload, state *kueue.AdmissionCheckState, last *unstructured.Unstructured) (time.Duration, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("admissionCheck", state.Name)
+	if last == nil {
+		return 0, r.createProvisioningRequest(ctx, wl, state, 1)
+	}
+	attempt := requestAttempt(last)
+	failed := failure(last)
+	if failed == nil && isConditionTrue(last, conditionProvisioned) {
+		log.V(2).Info("Capacity provisioned", "provisioningRequest", last.GetName())
+		setCheckState(state, kueue.CheckStateReady, fmt.Sprintf("ProvisioningRequest %s provisioned the capacity", last.GetName()))
+		return 0, nil
+	}
+	if failed == nil {
+		setCheckState(state, kueue.CheckStatePending, fmt.Sprintf("Waiting for ProvisioningRequest %s", last.GetName()))
+		return 0, nil
+	}
+	if attempt > r.retry.LimitCount {
+		log.V(2).Info("Capacity provisioning failed, requesting a retry", "provisioningRequest", last.GetName(), "reason", failed.Reason)
+		message := fmt.Sprintf("ProvisioningRequest %s failed: %s", last.GetName(), failed.Message)
+		setCheckState(state, kueue.CheckStateRetry, message)
+		workload.RecordEvent(r.recorder, wl, corev1.EventTypeWarning, "ProvisioningFailed", message)
+		return 0, nil
+	}
+	delay := r.retry.delay(attempt)
+	if remaining := time.Until(failed.LastTransitionTime.Add(delay)); remaining > 0 {
+		setCheckState(state, kueue.CheckStatePending, fmt.Sprintf("ProvisioningRequest %s failed, retrying after %s: %s", last.GetName(), delay, failed.Message))
+		return remaining, nil
+	}
+	return 0, r.createProvisioningRequest(ctx, wl, state, attempt+1)
+}
+
+// delay returns the delay before the ProvisioningRequest is recreated after
+// the given number of failures.
+func (b *RetryBackoff) delay(count int32) time.Duration {
+	delay := b.BaseDelay
+	for i := int32(1); i < count && delay < b.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay
+}
+
+// createProvisioningRequest creates the ProvisioningRequest of the given
+// attempt for the check, with a PodTemplate for each podSet.
+func (r *Reconciler) createProvisioningRequest(ctx context.Context, wl *kueue.Workload, state *kueue.AdmissionCheckState, attempt int32) error {
+	podSets, err := r.podSetTemplates(ctx, wl)
+	if err != nil {
+		return err
+	}
+	name := provisioningRequestName(wl.Name, state.Name, attempt)
+	owner := metav1.NewControllerRef(wl, kueue.GroupVersion.WithKind("Workload"))
+	for i := range podSets {
+		tmpl := &corev1.PodTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            podTemplateName(name, i),
+				Namespace:       wl.Namespace,
+				Labels:          map[string]string{workloadUIDLabel: string(wl.UID)},
+				OwnerReferences: []metav1.OwnerReference{*owner},
+			},
+			Template: corev1.PodTemplateSpec{Spec: podSets[i].spec},
+		}
+		if err := r.client.Create(ctx, tmpl); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	pr := newProvisioningRequest(wl, name, state.Name, attempt, r.className, podSets)
+	if err := r.client.Create(ctx, pr); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	ctrl.LoggerFrom(ctx).V(2).Info("Created ProvisioningRequest", "admissionCheck", state.Name, "provisioningRequest", name)
+	setCheckState(state, kueue.CheckStatePending, fmt.Sprintf("Waiting for ProvisioningRequest %s", name))
+	return nil
+}
+
+// podSetTemplate is the pod spec and the number of pods of a podSet, or of a
+// subset of its pods, in a ProvisioningRequest.
+type podSetTemplate struct {
+	spec  corev1.PodSpec
+	count int32
+}
+
+// podSetTemplates returns the pods to provision for the admitted podSets,
+// with the node labels of their assigned flavors, so that the
+// cluster-autoscaler scales up the node groups of the flavors. The subsets
+// of a podSet split across flavors get a template each.
+func (r *Reconciler) podSetTemplates(ctx context.Context, wl *kueue.Workload) ([]podSetTemplate, error) {
+	podSets := make(map[string]*kueue.PodSet, len(wl.Spec.PodSets))
+	for i := range wl.Spec.PodSets {
+		podSets[wl.Spec.PodSets[i].Name] = &wl.Spec.PodSets[i]
+	}
+	var templates []podSetTemplate
+	for _, psFlavors := range wl.Spec.Admission.PodSetFlavors {
+		ps := podSets[psFlavors.Name]
+		if ps == nil {
+			continue
+		}
+		if len(psFlavors.Splits) == 0 {
+			count := ps.Count
+			if psFlavors.Count != nil {
+				count = *psFlavors.Count
+			}
+			tmpl, err := r.podSetTemplate(ctx, wl, ps, psFlavors.Flavors, count)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, tmpl)
+			continue
+		}
+		for _, split := range psFlavors.Splits {
+			tmpl, err := r.podSetTemplate(ctx, wl, ps, split.Flavors, split.Count)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, tmpl)
+		}
+	}
+	return templates, nil
+}
+
+func (r *Reconciler) podSetTemplate(ctx context.Context, wl *kueue.Workload, ps *kueue.PodSet, flavors map[corev1.ResourceName]string, count int32) (podSetTemplate, error) {
+	spec := *ps.Spec.DeepCopy()
+	for _, name := range flavors {
+		var flavor kueue.ResourceFlavor
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name}, &flavor); err != nil {
+			return podSetTemplate{}, err
+		}
+		if len(flavor.Labels) == 0 {
+			continue
+		}
+		if spec.NodeSelector == nil {
+			spec.NodeSelector = make(map[string]string, len(flavor.Labels))
+		}
+		for k, v := range flavor.Labels {
+			spec.NodeSelector[k] = v
+		}
+	}
+	if len(wl.Spec.PlacementHints) != 0 {
+		workload.InjectRequiredNodeSelectorTerms(&spec, wl.Spec.PlacementHints)
+	}
+	return podSetTemplate{spec: spec, count: count}, nil
+}
+
+// provisioningChecks returns the names of the AdmissionChecks of the
+// workload that this controller evaluates.
+func (r *Reconciler) provisioningChecks(ctx context.Context, wl *kueue.Workload) ([]string, error) {
+	var checks []string
+	for _, name := range wl.Spec.Admission.AdmissionChecks {
+		var ac kueue.AdmissionCheck
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name}, &ac); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if ac.Spec.ControllerName == ControllerName {
+			checks = append(checks, name)
+		}
+	}
+	return checks, nil
+}
+
+// provisioningRequests returns the last ProvisioningRequest of each check of
+// the workload. The ProvisioningRequests created before the quota
+// reservation belong to a previous reservation, so they are deleted.
+func (r *Reconciler) provisioningRequests(ctx context.Context, wl *kueue.Workload, reservedAt metav1.Time) (map[string]*unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(provisioningRequestGVK.GroupVersion().WithKind(provisioningRequestGVK.Kind + "List"))
+	if err := r.client.List(ctx, list, client.InNamespace(wl.Namespace), client.MatchingLabels{workloadUIDLabel: string(wl.UID)}); err != nil {
+		return nil, err
+	}
+	last := make(map[string]*unstructured.Unstructured)
+	for i := range list.Items {
+		pr := &list.Items[i]
+		if created := pr.GetCreationTimestamp(); created.Before(&reservedAt) {
+			if err := r.client.Delete(ctx, pr); client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			continue
+		}
+		check := pr.GetAnnotations()[admissionCheckAnnotation]
+		if prev := last[check]; prev == nil || requestAttempt(pr) > requestAttempt(prev) {
+			last[check] = pr
+		}
+	}
+	return last, nil
+}
+
+// deleteProvisioningRequests deletes the ProvisioningRequests of the
+// workload and their PodTemplates, so that the provisioned capacity is
+// released.
+func (r *Reconciler) deleteProvisioningRequests(ctx context.Context, wl *kueue.Workload) error {
+	pr := &unstructured.Unstructured{}
+	pr.SetGroupVersionKind(provisioningRequestGVK)
+	opts := []client.DeleteAllOfOption{client.InNamespace(wl.Namespace), client.MatchingLabels{workloadUIDLabel: string(wl.UID)}}
+	if err := r.client.DeleteAllOf(ctx, pr, opts...); err != nil {
+		return err
+	}
+	return r.client.DeleteAllOf(ctx, &corev1.PodTemplate{}, opts...)
+}
+
+// reservationTime returns when the quota of the workload was reserved, or
+// nil if the reservation isn't recorded yet.
+func reservationTime(wl *kueue.Workload) *metav1.Time {
+	i := workload.FindConditionIndex(&wl.Status, kueue.WorkloadQuotaReserved)
+	if i == -1 || wl.Status.Conditions[i].Status != corev1.ConditionTrue {
+		return nil
+	}
+	return &wl.Status.Conditions[i].LastTransitionTime
+}
+
+// setCheckState sets the state and message of the check, updating its
+// transition time if the state changed.
+func setCheckState(state *kueue.AdmissionCheckState, checkState kueue.CheckState, message string) {
+	if state.State != checkState {
+		state.State = checkState
+		state.LastTransitionTime = metav1.Now()
+	}
+	state.Message = message
+}
+
+// SetupWithManager sets up the controller with the Manager. It requires the
+// ProvisioningRequest API in the cluster.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	pr := &unstructured.Unstructured{}
+	pr.SetGroupVersionKind(provisioningRequestGVK)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("provisioningrequest").
+		For(&kueue.Workload{}, builder.WithPredicates(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return hasAdmissionChecks(e.Object.(*kueue.Workload))
+			},
+			// The update that clears the reservation releases the capacity.
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return hasAdmissionChecks(e.ObjectOld.(*kueue.Workload)) || hasAdmissionChecks(e.ObjectNew.(*kueue.Workload))
+			},
+			DeleteFunc: func(event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(event.GenericEvent) bool {
+				return false
+			},
+		})).
+		Owns(pr).
+		Complete(r)
+}
+
+func hasAdmissionChecks(wl *kueue.Workload) bool {
+	return wl.Spec.Admission != nil && len(wl.Spec.Admission.AdmissionChecks) > 0
+}
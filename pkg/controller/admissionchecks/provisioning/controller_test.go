@@ -0,0 +1,261 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+)
+
+func TestReconcile(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	reservedAt := now.Add(-time.Hour)
+	makeWorkload := func() *utiltesting.WorkloadWrapper {
+		w := utiltesting.MakeWorkload("wl", "ns").Request(corev1.ResourceCPU, "1").
+			Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "on-demand").AdmissionChecks("prov", "other").Obj())
+		w.UID = "wl-uid"
+		w.Status.Conditions = []kueue.WorkloadCondition{{
+			Type:               kueue.WorkloadQuotaReserved,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(reservedAt),
+		}}
+		w.Status.AdmissionChecks = []kueue.AdmissionCheckState{
+			{Name: "prov", State: kueue.CheckStatePending},
+			{Name: "other", State: kueue.CheckStatePending},
+		}
+		return w
+	}
+	condition := func(condType string, at time.Time) metav1.Condition {
+		return metav1.Condition{
+			Type:               condType,
+			Status:             metav1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(at),
+			Reason:             condType,
+			Message:            "no capacity",
+		}
+	}
+
+	cases := map[string]struct {
+		workload             *kueue.Workload
+		provisioningRequests []*unstructured.Unstructured
+		wantRequests         []string
+		wantChecks           []kueue.AdmissionCheckState
+		wantRequeue          bool
+		wantNodeSelectors    map[string]map[string]string
+	}{
+		"creates the first ProvisioningRequest": {
+			workload:     makeWorkload().Obj(),
+			wantRequests: []string{"wl-prov-1"},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "prov", State: kueue.CheckStatePending, Message: "Waiting for ProvisioningRequest wl-prov-1"},
+				{Name: "other", State: kueue.CheckStatePending},
+			},
+			wantNodeSelectors: map[string]map[string]string{
+				"wl-prov-1-0": {"instance-type": "on-demand"},
+			},
+		},
+		"marks the check ready once the capacity is provisioned": {
+			workload: makeWorkload().Obj(),
+			provisioningRequests: []*unstructured.Unstructured{
+				makeProvisioningRequest("wl-prov-1", "prov", 1, reservedAt.Add(time.Second), condition(conditionProvisioned, now)),
+			},
+			wantRequests: []string{"wl-prov-1"},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "prov", State: kueue.CheckStateReady, Message: "ProvisioningRequest wl-prov-1 provisioned the capacity"},
+				{Name: "other", State: kueue.CheckStatePending},
+			},
+		},
+		"waits for the backoff after a failure": {
+			workload: makeWorkload().Obj(),
+			provisioningRequests: []*unstructured.Unstructured{
+				makeProvisioningRequest("wl-prov-1", "prov", 1, reservedAt.Add(time.Second), condition(conditionFailed, now.Add(-10*time.Second))),
+			},
+			wantRequests: []string{"wl-prov-1"},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "prov", State: kueue.CheckStatePending, Message: "ProvisioningRequest wl-prov-1 failed, retrying after 1m0s: no capacity"},
+				{Name: "other", State: kueue.CheckStatePending},
+			},
+			wantRequeue: true,
+		},
+		"recreates the ProvisioningRequest after the backoff": {
+			workload: makeWorkload().Obj(),
+			provisioningRequests: []*unstructured.Unstructured{
+				makeProvisioningRequest("wl-prov-1", "prov", 1, reservedAt.Add(time.Second), condition(conditionFailed, now.Add(-2*time.Minute))),
+			},
+			wantRequests: []string{"wl-prov-1", "wl-prov-2"},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "prov", State: kueue.CheckStatePending, Message: "Waiting for ProvisioningRequest wl-prov-2"},
+				{Name: "other", State: kueue.CheckStatePending},
+			},
+		},
+		"requests a retry when the retries are exhausted": {
+			workload: makeWorkload().Obj(),
+			provisioningRequests: []*unstructured.Unstructured{
+				makeProvisioningRequest("wl-prov-1", "prov", 1, reservedAt.Add(time.Second), condition(conditionFailed, now.Add(-time.Hour))),
+				makeProvisioningRequest("wl-prov-2", "prov", 2, reservedAt.Add(time.Minute), condition(conditionProvisioned, now.Add(-time.Minute)), condition(conditionBookingExpired, now)),
+			},
+			wantRequests: []string{"wl-prov-1", "wl-prov-2"},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "prov", State: kueue.CheckStateRetry, Message: "ProvisioningRequest wl-prov-2 failed: no capacity"},
+				{Name: "other", State: kueue.CheckStatePending},
+			},
+		},
+		"replaces the ProvisioningRequests of a previous reservation": {
+			workload: makeWorkload().Obj(),
+			provisioningRequests: []*unstructured.Unstructured{
+				makeProvisioningRequest("wl-prov-2", "prov", 2, reservedAt.Add(-time.Minute), condition(conditionProvisioned, now)),
+			},
+			wantRequests: []string{"wl-prov-1"},
+			wantChecks: []kueue.AdmissionCheckState{
+				{Name: "prov", State: kueue.CheckStatePending, Message: "Waiting for ProvisioningRequest wl-prov-1"},
+				{Name: "other", State: kueue.CheckStatePending},
+			},
+		},
+		"deletes the ProvisioningRequests when the reservation is released": {
+			workload: func() *kueue.Workload {
+				w := makeWorkload().Obj()
+				w.Spec.Admission = nil
+				w.Status.AdmissionChecks = nil
+				return w
+			}(),
+			provisioningRequests: []*unstructured.Unstructured{
+				makeProvisioningRequest("wl-prov-1", "prov", 1, reservedAt.Add(time.Second), condition(conditionProvisioned, now)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding client-go scheme: %v", err)
+			}
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			scheme.AddKnownTypeWithName(provisioningRequestGVK, &unstructured.Unstructured{})
+			scheme.AddKnownTypeWithName(provisioningRequestGVK.GroupVersion().WithKind(provisioningRequestGVK.Kind+"List"), &unstructured.UnstructuredList{})
+			objs := []client.Object{
+				tc.workload,
+				&kueue.AdmissionCheck{
+					ObjectMeta: metav1.ObjectMeta{Name: "prov"},
+					Spec:       kueue.AdmissionCheckSpec{ControllerName: ControllerName},
+				},
+				&kueue.AdmissionCheck{
+					ObjectMeta: metav1.ObjectMeta{Name: "other"},
+					Spec:       kueue.AdmissionCheckSpec{ControllerName: "example.com/other"},
+				},
+				utiltesting.MakeResourceFlavor("on-demand").Label("instance-type", "on-demand").Obj(),
+			}
+			for _, pr := range tc.provisioningRequests {
+				objs = append(objs, pr)
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+			r := NewReconciler(cl, record.NewFakeRecorder(10), WithRetryBackoff(RetryBackoff{LimitCount: 1}))
+			ctx := ctrl.LoggerInto(context.Background(), ctrl.Log)
+			key := types.NamespacedName{Namespace: "ns", Name: "wl"}
+
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile failed: %v", err)
+			}
+			if gotRequeue := result.RequeueAfter > 0; gotRequeue != tc.wantRequeue {
+				t.Errorf("Reconcile requeued after %v, want requeue: %t", result.RequeueAfter, tc.wantRequeue)
+			}
+
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(provisioningRequestGVK.GroupVersion().WithKind(provisioningRequestGVK.Kind + "List"))
+			if err := cl.List(ctx, list, client.InNamespace("ns")); err != nil {
+				t.Fatalf("Failed listing ProvisioningRequests: %v", err)
+			}
+			var gotRequests []string
+			for _, pr := range list.Items {
+				gotRequests = append(gotRequests, pr.GetName())
+			}
+			sort.Strings(gotRequests)
+			if diff := cmp.Diff(tc.wantRequests, gotRequests); diff != "" {
+				t.Errorf("Unexpected ProvisioningRequests (-want,+got):\n%s", diff)
+			}
+
+			var wl kueue.Workload
+			if err := cl.Get(ctx, key, &wl); err != nil {
+				t.Fatalf("Failed getting workload: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantChecks, wl.Status.AdmissionChecks, cmpopts.IgnoreFields(kueue.AdmissionCheckState{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("Unexpected checks (-want,+got):\n%s", diff)
+			}
+
+			for name, wantSelector := range tc.wantNodeSelectors {
+				var tmpl corev1.PodTemplate
+				if err := cl.Get(ctx, types.NamespacedName{Namespace: "ns", Name: name}, &tmpl); err != nil {
+					t.Fatalf("Failed getting PodTemplate %s: %v", name, err)
+				}
+				if diff := cmp.Diff(wantSelector, tmpl.Template.Spec.NodeSelector); diff != "" {
+					t.Errorf("Unexpected node selector of PodTemplate %s (-want,+got):\n%s", name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestProvisioningRequestName(t *testing.T) {
+	if got := provisioningRequestName("wl", "prov", 1); got != "wl-prov-1" {
+		t.Errorf("Got name %q, want %q", got, "wl-prov-1")
+	}
+	long := strings.Repeat("a", 250)
+	name := provisioningRequestName(long, "prov", 1)
+	if len(podTemplateName(name, 99)) > 253 {
+		t.Errorf("Got name %q, too long for the names of the PodTemplates", name)
+	}
+	if name == provisioningRequestName(long, "prov", 2) {
+		t.Errorf("Got the same name %q for different attempts", name)
+	}
+}
+
+func makeProvisioningRequest(name, check string, attempt int32, created time.Time, conditions ...metav1.Condition) *unstructured.Unstructured {
+	wl := &kueue.Workload{ObjectMeta: metav1.ObjectMeta{Name: "wl", Namespace: "ns", UID: "wl-uid"}}
+	pr := newProvisioningRequest(wl, name, check, attempt, DefaultProvisioningClassName, nil)
+	pr.SetCreationTimestamp(metav1.NewTime(created))
+	conds := make([]interface{}, len(conditions))
+	for i := range conditions {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&conditions[i])
+		if err != nil {
+			panic(err)
+		}
+		conds[i] = u
+	}
+	pr.Object["status"] = map[string]interface{}{"conditions": conds}
+	return pr
+}
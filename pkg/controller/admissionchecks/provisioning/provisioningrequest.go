@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioning
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+)
+
+// The ProvisioningRequest API of the cluster-autoscaler is handled as
+// unstructured objects, so that kueue doesn't depend on the autoscaler
+// module.
+var provisioningRequestGVK = schema.GroupVersionKind{
+	Group:   "autoscaling.x-k8s.io",
+	Version: "v1beta1",
+	Kind:    "ProvisioningRequest",
+}
+
+const (
+	// The conditions that the cluster-autoscaler sets in the
+	// ProvisioningRequests.
+	conditionProvisioned     = "Provisioned"
+	conditionFailed          = "Failed"
+	conditionBookingExpired  = "BookingExpired"
+	conditionCapacityRevoked = "CapacityRevoked"
+
+	// workloadUIDLabel is the label in the ProvisioningRequests and
+	// PodTemplates that holds the UID of their workload.
+	workloadUIDLabel = "kueue.x-k8s.io/workload-uid"
+	// admissionCheckAnnotation is the annotation in the ProvisioningRequests
+	// that holds the name of their AdmissionCheck.
+	admissionCheckAnnotation = "kueue.x-k8s.io/admission-check"
+	// attemptAnnotation is the annotation in the ProvisioningRequests that
+	// holds the number of the attempt to provision the capacity for the
+	// check, starting at 1.
+	attemptAnnotation = "kueue.x-k8s.io/provisioning-attempt"
+
+	// maxPodTemplateSuffix is the length reserved in the names of the
+	// ProvisioningRequests for the suffix of the names of their PodTemplates.
+	maxPodTemplateSuffix = 6
+)
+
+// newProvisioningRequest returns the ProvisioningRequest of the given
+// attempt for the check, referencing the PodTemplates of the podSets.
+func newProvisioningRequest(wl *kueue.Workload, name, check string, attempt int32, className string, podSets []podSetTemplate) *unstructured.Unstructured {
+	refs := make([]interface{}, len(podSets))
+	for i, ps := range podSets {
+		refs[i] = map[string]interface{}{
+			"podTemplateRef": map[string]interface{}{"name": podTemplateName(name, i)},
+			"count":          int64(ps.count),
+		}
+	}
+	pr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"provisioningClassName": className,
+			"podSets":               refs,
+		},
+	}}
+	pr.SetGroupVersionKind(provisioningRequestGVK)
+	pr.SetName(name)
+	pr.SetNamespace(wl.Namespace)
+	pr.SetLabels(map[string]string{workloadUIDLabel: string(wl.UID)})
+	pr.SetAnnotations(map[string]string{
+		admissionCheckAnnotation: check,
+		attemptAnnotation:        strconv.Itoa(int(attempt)),
+	})
+	pr.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(wl, kueue.GroupVersion.WithKind("Workload"))})
+	return pr
+}
+
+// provisioningRequestName returns the name of the ProvisioningRequest of the
+// given attempt for the check of the workload. Names that don't leave room
+// for the suffixes of the PodTemplates are truncated, with a hash of the full
+// name, so that they remain unique.
+func provisioningRequestName(wlName, check string, attempt int32) string {
+	name := fmt.Sprintf("%s-%s-%d", wlName, check, attempt)
+	maxLength := validation.DNS1123SubdomainMaxLength - maxPodTemplateSuffix
+	if len(name) <= maxLength {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hash := fmt.Sprintf("%08x", h.Sum32())
+	return name[:maxLength-len(hash)-1] + "-" + hash
+}
+
+// podTemplateName returns the name of the PodTemplate of the i-th podSet of
+// the ProvisioningRequest.
+func podTemplateName(prName string, i int) string {
+	return fmt.Sprintf("%s-%d", prName, i)
+}
+
+// requestAttempt returns the number of the attempt of the
+// ProvisioningRequest, or 0 if it's not recorded.
+func requestAttempt(pr *unstructured.Unstructured) int32 {
+	attempt, err := strconv.Atoi(pr.GetAnnotations()[attemptAnnotation])
+	if err != nil {
+		return 0
+	}
+	return int32(attempt)
+}
+
+// findCondition returns the condition of the given type of the
+// ProvisioningRequest, or nil if it's not set or can't be decoded.
+func findCondition(pr *unstructured.Unstructured, condType string) *metav1.Condition {
+	conditions, _, _ := unstructured.NestedSlice(pr.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != condType {
+			continue
+		}
+		var cond metav1.Condition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &cond); err != nil {
+			return nil
+		}
+		return &cond
+	}
+	return nil
+}
+
+func isConditionTrue(pr *unstructured.Unstructured, condType string) bool {
+	cond := findCondition(pr, condType)
+	return cond != nil && cond.Status == metav1.ConditionTrue
+}
+
+// failure returns the condition that reports why the capacity of the
+// ProvisioningRequest can't be used, or nil if it can.
+func failure(pr *unstructured.Unstructured) *metav1.Condition {
+	for _, condType := range []string{conditionFailed, conditionCapacityRevoked, conditionBookingExpired} {
+		if cond := findCondition(pr, condType); cond != nil && cond.Status == metav1.ConditionTrue {
+			return cond
+		}
+	}
+	return nil
+}
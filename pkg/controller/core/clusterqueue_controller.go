@@ -18,11 +18,17 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/kueue/pkg/constants"
 
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
@@ -35,26 +41,42 @@ import (
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/metrics"
+	"sigs.k8s.io/kueue/pkg/util/retry"
+	"sigs.k8s.io/kueue/pkg/workload"
 )
 
-const wlUpdateChBuffer = 10
+const (
+	wlUpdateChBuffer = 10
+	rfUpdateChBuffer = 10
+)
 
 // ClusterQueueReconciler reconciles a ClusterQueue object
 type ClusterQueueReconciler struct {
 	client     client.Client
 	log        logr.Logger
-	qManager   *queue.Manager
-	cache      *cache.Cache
+	qManager   queue.Interface
+	cache      cache.Interface
+	retries    *retry.Queue
 	wlUpdateCh chan event.GenericEvent
+	rfUpdateCh chan event.GenericEvent
+	visibility queueVisibility
 }
 
-func NewClusterQueueReconciler(client client.Client, qMgr *queue.Manager, cache *cache.Cache) *ClusterQueueReconciler {
+func NewClusterQueueReconciler(client client.Client, qMgr queue.Interface, cache cache.Interface, retries *retry.Queue, opts ...Option) *ClusterQueueReconciler {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
 	return &ClusterQueueReconciler{
 		client:     client,
 		log:        ctrl.Log.WithName("cluster-queue-reconciler"),
 		qManager:   qMgr,
 		cache:      cache,
+		retries:    retries,
 		wlUpdateCh: make(chan event.GenericEvent, wlUpdateChBuffer),
+		rfUpdateCh: make(chan event.GenericEvent, rfUpdateChBuffer),
+		visibility: options.queueVisibility,
 	}
 }
 
@@ -74,25 +96,139 @@ func (r *ClusterQueueReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	ctx = ctrl.LoggerInto(ctx, log)
 	log.V(2).Info("Reconciling ClusterQueue")
 
-	status, err := r.Status(&cqObj)
+	if cqObj.Annotations[constants.RequeueAdmittedWorkloadsAnnotation] == "true" {
+		var workloads kueue.WorkloadList
+		if err := r.client.List(ctx, &workloads, client.MatchingFields{cache.WorkloadClusterQueueKey: cqObj.Name}); err != nil {
+			return ctrl.Result{}, err
+		}
+		err := requeueAdmittedWorkloads(ctx, r.client, workloads.Items, func(w *kueue.Workload) bool {
+			// Checking clusterQueue name again because the field index is not available in tests.
+			return w.Spec.Admission != nil && string(w.Spec.Admission.ClusterQueue) == cqObj.Name
+		})
+		if err != nil {
+			log.Error(err, "Failed to requeue admitted workloads")
+			return ctrl.Result{}, err
+		}
+		delete(cqObj.Annotations, constants.RequeueAdmittedWorkloadsAnnotation)
+		if err := r.client.Update(ctx, &cqObj); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	if cqObj.Spec.StopPolicy == kueue.StopPolicyHoldAndDrain {
+		if err := r.drain(ctx, &cqObj); err != nil {
+			log.Error(err, "Failed to drain admitted workloads")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// The quotas change at the start and end of the windows of the quota
+	// schedules, so the ClusterQueue is reconciled again at the next one.
+	var nextQuotaChange time.Duration
+	if len(cqObj.Spec.QuotaSchedules) > 0 {
+		now := time.Now()
+		changed, next, err := r.cache.UpdateQuotaSchedules(&cqObj, now)
+		if err != nil {
+			log.Error(err, "Failed to update the quota schedules in cache")
+			return ctrl.Result{}, err
+		}
+		if changed {
+			log.V(2).Info("Active quota schedules changed")
+			// Requeue the inadmissible workloads, which might fit the new
+			// quotas.
+			if err := r.qManager.UpdateClusterQueue(&cqObj); err != nil {
+				log.Error(err, "Failed to update clusterQueue in queue manager")
+			}
+		}
+		if !next.IsZero() {
+			nextQuotaChange = next.Sub(now)
+		}
+	}
+
+	if cqObj.Spec.QuotaShrinkPolicy == kueue.QuotaShrinkPolicyEvict {
+		if err := r.evictQuotaExcess(ctx, &cqObj); err != nil {
+			log.Error(err, "Failed to evict the workloads above the quotas")
+			return ctrl.Result{}, err
+		}
+	}
+
+	requeueAfter, err := r.updateStatus(ctx, &cqObj)
+	if nextQuotaChange > 0 && (requeueAfter == 0 || nextQuotaChange < requeueAfter) {
+		requeueAfter = nextQuotaChange
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, err
+}
+
+// evictQuotaExcess marks for eviction the workloads that no longer fit the
+// quotas of the ClusterQueue, after they shrunk.
+func (r *ClusterQueueReconciler) evictQuotaExcess(ctx context.Context, cqObj *kueue.ClusterQueue) error {
+	log := ctrl.LoggerFrom(ctx)
+	for _, wi := range r.cache.QuotaExcessWorkloads(cqObj.Name) {
+		err := workload.Evict(ctx, r.client, wi.Obj, workload.EvictedByQuotaShrink, "The quotas of the ClusterQueue shrunk below its usage")
+		if client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("evicting workload %s: %w", klog.KObj(wi.Obj), err)
+		}
+		log.V(2).Info("Evicting workload above the shrunk quotas", "workload", klog.KObj(wi.Obj))
+	}
+	return nil
+}
+
+// drain marks the workloads admitted by the ClusterQueue for eviction. The
+// WorkloadReconciler then clears their admission, which stops their jobs and
+// requeues them.
+func (r *ClusterQueueReconciler) drain(ctx context.Context, cqObj *kueue.ClusterQueue) error {
+	log := ctrl.LoggerFrom(ctx)
+	var workloads kueue.WorkloadList
+	if err := r.client.List(ctx, &workloads, client.MatchingFields{cache.WorkloadClusterQueueKey: cqObj.Name}); err != nil {
+		return err
+	}
+	for i := range workloads.Items {
+		w := &workloads.Items[i]
+		// Checking clusterQueue name again because the field index is not available in tests.
+		if !hasQuotaReservation(workloadStatus(w)) || string(w.Spec.Admission.ClusterQueue) != cqObj.Name || workload.InCondition(w, kueue.WorkloadEvicted) {
+			continue
+		}
+		err := workload.Evict(ctx, r.client, w, workload.EvictedByClusterQueueStopped, "The ClusterQueue is stopped")
+		if client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("evicting workload %s: %w", klog.KObj(w), err)
+		}
+		log.V(2).Info("Evicting workload from the drained ClusterQueue", "workload", klog.KObj(w))
+	}
+	return nil
+}
+
+// updateStatus updates the status of the ClusterQueue with the state of the
+// cache and the queue manager, if it changed. It returns the time after which
+// the list of pending workloads, held back to limit its changes, can be
+// updated.
+func (r *ClusterQueueReconciler) updateStatus(ctx context.Context, cqObj *kueue.ClusterQueue) (time.Duration, error) {
+	status, err := r.Status(cqObj)
 	if err != nil {
-		log.Error(err, "Failed getting status from cache")
-		return ctrl.Result{}, err
+		ctrl.LoggerFrom(ctx).Error(err, "Failed getting status from cache")
+		return 0, err
 	}
+	metrics.ReportClusterQueueWorkloads(cqObj.Name, int(status.PendingWorkloads), int(status.AdmittedWorkloads))
+	var requeueAfter time.Duration
+	status.PendingWorkloadsStatus, requeueAfter = r.visibility.pendingWorkloadsStatus(cqObj.Status.PendingWorkloadsStatus, func() []queue.PendingWorkload {
+		workloads, _ := r.qManager.ClusterQueuePendingWorkloads(cqObj.Name)
+		return workloads
+	})
 
 	if !equality.Semantic.DeepEqual(status, cqObj.Status) {
 		cqObj.Status = status
-		err := r.client.Status().Update(ctx, &cqObj)
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		return requeueAfter, client.IgnoreNotFound(r.client.Status().Update(ctx, cqObj))
 	}
-
-	return ctrl.Result{}, nil
+	return requeueAfter, nil
 }
 
 func (r *ClusterQueueReconciler) NotifyWorkloadUpdate(w *kueue.Workload) {
 	r.wlUpdateCh <- event.GenericEvent{Object: w}
 }
 
+func (r *ClusterQueueReconciler) NotifyResourceFlavorUpdate(rf *kueue.ResourceFlavor) {
+	r.rfUpdateCh <- event.GenericEvent{Object: rf}
+}
+
 // Event handlers return true to signal the controller to reconcile the
 // ClusterQueue associated with the event.
 
@@ -107,13 +243,35 @@ func (r *ClusterQueueReconciler) Create(e event.CreateEvent) bool {
 	ctx := ctrl.LoggerInto(context.Background(), log)
 	if err := r.cache.AddClusterQueue(ctx, cq); err != nil {
 		log.Error(err, "Failed to add clusterQueue to cache")
+		if !errors.Is(err, cache.ErrClusterQueueAlreadyExists) {
+			r.retries.Add("ClusterQueue/cache/"+cq.Name, r.retryAddClusterQueue(cq.Name, r.cache.AddClusterQueue, cache.ErrClusterQueueAlreadyExists))
+		}
 	}
 	if err := r.qManager.AddClusterQueue(ctx, cq); err != nil {
 		log.Error(err, "Failed to add clusterQueue to queue manager")
+		if !errors.Is(err, queue.ErrClusterQueueAlreadyExists) {
+			r.retries.Add("ClusterQueue/queues/"+cq.Name, r.retryAddClusterQueue(cq.Name, r.qManager.AddClusterQueue, queue.ErrClusterQueueAlreadyExists))
+		}
 	}
 	return true
 }
 
+// retryAddClusterQueue returns an operation that calls add with the latest
+// version of the ClusterQueue, unless it was deleted. alreadyExists is the
+// error that add returns if the ClusterQueue was added already.
+func (r *ClusterQueueReconciler) retryAddClusterQueue(name string, add func(context.Context, *kueue.ClusterQueue) error, alreadyExists error) retry.Func {
+	return func(ctx context.Context) error {
+		var cq kueue.ClusterQueue
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name}, &cq); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if err := add(ctx, &cq); err != nil && !errors.Is(err, alreadyExists) {
+			return err
+		}
+		return nil
+	}
+}
+
 func (r *ClusterQueueReconciler) Delete(e event.DeleteEvent) bool {
 	cq, match := e.Object.(*kueue.ClusterQueue)
 	if !match {
@@ -123,6 +281,7 @@ func (r *ClusterQueueReconciler) Delete(e event.DeleteEvent) bool {
 	r.log.V(2).Info("Queue delete event", "clusterQueue", klog.KObj(cq))
 	r.cache.DeleteClusterQueue(cq)
 	r.qManager.DeleteClusterQueue(cq)
+	metrics.ClearClusterQueueMetrics(cq.Name)
 	return true
 }
 
@@ -144,7 +303,7 @@ func (r *ClusterQueueReconciler) Update(e event.UpdateEvent) bool {
 }
 
 func (r *ClusterQueueReconciler) Generic(e event.GenericEvent) bool {
-	r.log.V(3).Info("Got Workload event", "workload", klog.KObj(e.Object))
+	r.log.V(3).Info("Got generic event", "obj", klog.KObj(e.Object), "kind", e.Object.GetObjectKind().GroupVersionKind())
 	return true
 }
 
@@ -153,7 +312,7 @@ func (r *ClusterQueueReconciler) Generic(e event.GenericEvent) bool {
 // Since the events come from a channel Source, only the Generic handler will
 // receive events.
 type cqWorkloadHandler struct {
-	qManager *queue.Manager
+	qManager queue.Interface
 }
 
 func (h *cqWorkloadHandler) Create(event.CreateEvent, workqueue.RateLimitingInterface) {
@@ -191,20 +350,47 @@ func (h *cqWorkloadHandler) requestForWorkloadClusterQueue(w *kueue.Workload) *r
 	}
 }
 
+// cqResourceFlavorHandler signals the controller to reconcile the
+// ClusterQueues that reference the ResourceFlavor in the event.
+// Since the events come from a channel Source, only the Generic handler will
+// receive events.
+type cqResourceFlavorHandler struct {
+	cache cache.Interface
+}
+
+func (h *cqResourceFlavorHandler) Create(event.CreateEvent, workqueue.RateLimitingInterface) {
+}
+
+func (h *cqResourceFlavorHandler) Update(event.UpdateEvent, workqueue.RateLimitingInterface) {
+}
+
+func (h *cqResourceFlavorHandler) Delete(event.DeleteEvent, workqueue.RateLimitingInterface) {
+}
+
+func (h *cqResourceFlavorHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	for _, name := range h.cache.ClusterQueuesUsingFlavor(e.Object.GetName()) {
+		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterQueueReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	wHandler := cqWorkloadHandler{
 		qManager: r.qManager,
 	}
+	rfHandler := cqResourceFlavorHandler{
+		cache: r.cache,
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kueue.ClusterQueue{}).
 		Watches(&source.Channel{Source: r.wlUpdateCh}, &wHandler).
+		Watches(&source.Channel{Source: r.rfUpdateCh}, &rfHandler).
 		WithEventFilter(r).
 		Complete(r)
 }
 
 func (r *ClusterQueueReconciler) Status(cq *kueue.ClusterQueue) (kueue.ClusterQueueStatus, error) {
-	usage, workloads, err := r.cache.Usage(cq)
+	usage, counts, err := r.cache.Usage(cq)
 	if err != nil {
 		r.log.Error(err, "Failed getting usage from cache")
 		// This is likely because the cluster queue was recently removed,
@@ -212,9 +398,36 @@ func (r *ClusterQueueReconciler) Status(cq *kueue.ClusterQueue) (kueue.ClusterQu
 		return kueue.ClusterQueueStatus{}, err
 	}
 
+	// The conditions are copied so that the ones of the object are only
+	// updated if the status changed.
+	conditions := append([]metav1.Condition(nil), cq.Status.Conditions...)
+	meta.SetStatusCondition(&conditions, activeCondition(r.cache.ClusterQueueMissingFlavors(cq.Name), cq.Generation))
 	return kueue.ClusterQueueStatus{
-		UsedResources:     usage,
-		AdmittedWorkloads: int32(workloads),
-		PendingWorkloads:  r.qManager.Pending(cq),
+		UsedResources:      usage,
+		ReservingWorkloads: int32(counts.Reserving),
+		AdmittedWorkloads:  int32(counts.Admitted),
+		PendingWorkloads:   r.qManager.Pending(cq),
+		Conditions:         conditions,
 	}, nil
 }
+
+// activeCondition returns the Active condition of a ClusterQueue that
+// references the given missing ResourceFlavors.
+func activeCondition(missingFlavors []string, generation int64) metav1.Condition {
+	if len(missingFlavors) > 0 {
+		return metav1.Condition{
+			Type:               kueue.ClusterQueueActive,
+			Status:             metav1.ConditionFalse,
+			Reason:             "FlavorNotFound",
+			Message:            fmt.Sprintf("Can't admit new workloads: ResourceFlavors %s not found", strings.Join(missingFlavors, ", ")),
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               kueue.ClusterQueueActive,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Ready",
+		Message:            "Can admit new workloads",
+		ObservedGeneration: generation,
+	}
+}
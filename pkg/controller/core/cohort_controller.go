@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/queue"
+)
+
+// CohortReconciler reconciles a Cohort object
+type CohortReconciler struct {
+	log      logr.Logger
+	qManager queue.Interface
+	cache    cache.Interface
+}
+
+func NewCohortReconciler(qMgr queue.Interface, cache cache.Interface) *CohortReconciler {
+	return &CohortReconciler{
+		log:      ctrl.Log.WithName("cohort-reconciler"),
+		qManager: qMgr,
+		cache:    cache,
+	}
+}
+
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=cohorts,verbs=get;list;watch
+
+func (r *CohortReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Nothing to do here.
+	return ctrl.Result{}, nil
+}
+
+func (r *CohortReconciler) Create(e event.CreateEvent) bool {
+	cohort, match := e.Object.(*kueue.Cohort)
+	if !match {
+		return false
+	}
+	log := r.log.WithValues("cohort", klog.KObj(cohort))
+	log.V(2).Info("Cohort create event")
+	r.cache.AddOrUpdateCohort(cohort)
+	r.qManager.AddOrUpdateCohort(cohort)
+	return false
+}
+
+func (r *CohortReconciler) Delete(e event.DeleteEvent) bool {
+	cohort, match := e.Object.(*kueue.Cohort)
+	if !match {
+		return false
+	}
+	log := r.log.WithValues("cohort", klog.KObj(cohort))
+	log.V(2).Info("Cohort delete event")
+	r.cache.DeleteCohort(cohort)
+	r.qManager.DeleteCohort(cohort)
+	return false
+}
+
+func (r *CohortReconciler) Update(e event.UpdateEvent) bool {
+	cohort, match := e.ObjectNew.(*kueue.Cohort)
+	if !match {
+		return false
+	}
+	log := r.log.WithValues("cohort", klog.KObj(cohort))
+	log.V(2).Info("Cohort update event")
+	r.cache.AddOrUpdateCohort(cohort)
+	r.qManager.AddOrUpdateCohort(cohort)
+	return false
+}
+
+func (r *CohortReconciler) Generic(e event.GenericEvent) bool {
+	r.log.V(3).Info("Ignore generic event", "obj", klog.KObj(e.Object), "kind", e.Object.GetObjectKind().GroupVersionKind())
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CohortReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kueue.Cohort{}).
+		WithEventFilter(r).
+		Complete(r)
+}
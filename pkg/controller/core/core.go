@@ -17,27 +17,145 @@ limitations under the License.
 package core
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/constants"
 	"sigs.k8s.io/kueue/pkg/queue"
+	"sigs.k8s.io/kueue/pkg/util/retry"
 )
 
 // SetupControllers sets up the core controllers. It returns the name of the
 // controller that failed to create and an error, if any.
-func SetupControllers(mgr ctrl.Manager, qManager *queue.Manager, cc *cache.Cache) (string, error) {
-	qRec := NewQueueReconciler(mgr.GetClient(), qManager)
+func SetupControllers(mgr ctrl.Manager, qManager queue.Interface, cc cache.Interface, opts ...Option) (string, error) {
+	// The event handlers retry the operations that fail, for example due to
+	// transient apiserver errors, so that the cache and the queue manager
+	// don't miss objects.
+	retries := retry.NewQueue("event-handler-retries")
+	if err := mgr.Add(retries); err != nil {
+		return "EventHandlerRetries", err
+	}
+	qRec := NewQueueReconciler(mgr.GetClient(), qManager, cc, retries, opts...)
 	if err := qRec.SetupWithManager(mgr); err != nil {
 		return "Queue", err
 	}
-	cqRec := NewClusterQueueReconciler(mgr.GetClient(), qManager, cc)
+	cqRec := NewClusterQueueReconciler(mgr.GetClient(), qManager, cc, retries, opts...)
 	if err := cqRec.SetupWithManager(mgr); err != nil {
 		return "ClusterQueue", err
 	}
-	if err := NewWorkloadReconciler(mgr.GetClient(), qManager, cc, qRec, cqRec).SetupWithManager(mgr); err != nil {
+	wlOpts := append([]Option{WithOwnerReader(mgr.GetAPIReader())}, opts...)
+	wlRec := NewWorkloadReconciler(mgr.GetClient(), qManager, cc, mgr.GetEventRecorderFor(constants.ManagerName),
+		append(wlOpts, WithWorkloadUpdateWatchers(qRec, cqRec))...)
+	if err := wlRec.SetupWithManager(mgr); err != nil {
 		return "Workload", err
 	}
-	if err := NewResourceFlavorReconciler(cc).SetupWithManager(mgr); err != nil {
+	if err := NewResourceFlavorReconciler(mgr.GetClient(), qManager, cc, cqRec).SetupWithManager(mgr); err != nil {
 		return "ResourceFlavor", err
 	}
+	if err := NewCohortReconciler(qManager, cc).SetupWithManager(mgr); err != nil {
+		return "Cohort", err
+	}
 	return "", nil
 }
+
+// FlushStatuses updates the status of all the Queues and ClusterQueues with
+// the state in memory. The controllers hold status updates for
+// constants.UpdatesBatchPeriod and drop them when they stop, so this is meant
+// to run on shutdown, after the scheduler stopped and before the leadership
+// is released.
+func FlushStatuses(ctx context.Context, c client.Client, qManager queue.Interface, cc cache.Interface, opts ...Option) error {
+	var errs []error
+	qRec := NewQueueReconciler(c, qManager, cc, nil, opts...)
+	var queues kueue.QueueList
+	if err := c.List(ctx, &queues); err != nil {
+		errs = append(errs, fmt.Errorf("listing queues: %w", err))
+	}
+	for i := range queues.Items {
+		if _, err := qRec.updateStatus(ctx, &queues.Items[i]); err != nil {
+			errs = append(errs, fmt.Errorf("updating status of queue %s: %w", klog.KObj(&queues.Items[i]), err))
+		}
+	}
+	cqRec := NewClusterQueueReconciler(c, qManager, cc, nil, opts...)
+	var cqs kueue.ClusterQueueList
+	if err := c.List(ctx, &cqs); err != nil {
+		errs = append(errs, fmt.Errorf("listing clusterQueues: %w", err))
+	}
+	for i := range cqs.Items {
+		if _, err := cqRec.updateStatus(ctx, &cqs.Items[i]); err != nil {
+			errs = append(errs, fmt.Errorf("updating status of clusterQueue %s: %w", klog.KObj(&cqs.Items[i]), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// queueVisibility configures the pending workloads listed in the status of
+// the ClusterQueues and Queues.
+type queueVisibility struct {
+	maxCount       int32
+	updateInterval time.Duration
+}
+
+// pendingWorkloadsStatus returns the status listing the first workloads
+// returned by list, which is only called if the list is enabled. If the
+// head changed, but less than the update interval after its last change, the
+// old status is kept and the time left until the head can change is
+// returned.
+func (v queueVisibility) pendingWorkloadsStatus(old *kueue.PendingWorkloadsStatus, list func() []queue.PendingWorkload) (*kueue.PendingWorkloadsStatus, time.Duration) {
+	if v.maxCount <= 0 {
+		return nil, 0
+	}
+	pending := list()
+	if len(pending) > int(v.maxCount) {
+		pending = pending[:v.maxCount]
+	}
+	var head []kueue.PendingWorkload
+	for _, pw := range pending {
+		head = append(head, kueue.PendingWorkload{
+			Name:      pw.Name,
+			Namespace: pw.Namespace,
+			Position:  pw.PositionInClusterQueue,
+			Priority:  pw.Priority,
+		})
+	}
+	if old != nil {
+		if equality.Semantic.DeepEqual(old.Head, head) {
+			return old, 0
+		}
+		if wait := time.Until(old.LastChangeTime.Add(v.updateInterval)); wait > 0 {
+			return old, wait
+		}
+	}
+	return &kueue.PendingWorkloadsStatus{
+		Head:           head,
+		LastChangeTime: metav1.Now(),
+	}, 0
+}
+
+// requeueAdmittedWorkloads clears the admission of the workloads that match
+// and are not finished. Their jobs get suspended and the workloads go back
+// to their queues.
+func requeueAdmittedWorkloads(ctx context.Context, c client.Client, workloads []kueue.Workload, match func(*kueue.Workload) bool) error {
+	log := ctrl.LoggerFrom(ctx)
+	for i := range workloads {
+		w := &workloads[i]
+		if !match(w) || !hasQuotaReservation(workloadStatus(w)) {
+			continue
+		}
+		w.Spec.Admission = nil
+		if err := c.Update(ctx, w); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("requeueing workload %s: %w", klog.KObj(w), err)
+		}
+		log.V(2).Info("Requeued admitted workload", "workload", klog.KObj(w))
+	}
+	return nil
+}
@@ -18,6 +18,8 @@ package core
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -32,23 +34,35 @@ import (
 	"sigs.k8s.io/kueue/pkg/constants"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
 	"sigs.k8s.io/kueue/pkg/queue"
+	"sigs.k8s.io/kueue/pkg/util/retry"
 )
 
 // QueueReconciler reconciles a Queue object
 type QueueReconciler struct {
 	client     client.Client
 	log        logr.Logger
-	queues     *queue.Manager
+	queues     queue.Interface
+	cache      cache.Interface
+	retries    *retry.Queue
 	wlUpdateCh chan event.GenericEvent
+	visibility queueVisibility
 }
 
-func NewQueueReconciler(client client.Client, queues *queue.Manager) *QueueReconciler {
+func NewQueueReconciler(client client.Client, queues queue.Interface, cache cache.Interface, retries *retry.Queue, opts ...Option) *QueueReconciler {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
 	return &QueueReconciler{
 		log:        ctrl.Log.WithName("queue-reconciler"),
 		queues:     queues,
+		cache:      cache,
 		client:     client,
+		retries:    retries,
 		wlUpdateCh: make(chan event.GenericEvent, wlUpdateChBuffer),
+		visibility: options.queueVisibility,
 	}
 }
 
@@ -71,21 +85,56 @@ func (r *QueueReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	ctx = ctrl.LoggerInto(ctx, log)
 	log.V(2).Info("Reconciling Queue")
 
-	// Shallow copy enough for now.
-	oldStatus := queueObj.Status
+	if queueObj.Annotations[constants.RequeueAdmittedWorkloadsAnnotation] == "true" {
+		var workloads kueue.WorkloadList
+		if err := r.client.List(ctx, &workloads, client.MatchingFields{queue.WorkloadQueueKey: queueObj.Name}, client.InNamespace(queueObj.Namespace)); err != nil {
+			return ctrl.Result{}, err
+		}
+		err := requeueAdmittedWorkloads(ctx, r.client, workloads.Items, func(w *kueue.Workload) bool {
+			// Checking queue name again because the field index is not available in tests.
+			return w.Spec.QueueName == queueObj.Name
+		})
+		if err != nil {
+			log.Error(err, "Failed to requeue admitted workloads")
+			return ctrl.Result{}, err
+		}
+		delete(queueObj.Annotations, constants.RequeueAdmittedWorkloadsAnnotation)
+		if err := r.client.Update(ctx, &queueObj); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	requeueAfter, err := r.updateStatus(ctx, &queueObj)
+	return ctrl.Result{RequeueAfter: requeueAfter}, err
+}
+
+// updateStatus updates the status of the Queue with the state of the queue
+// manager and the cache, if it changed. It returns the time after which the
+// list of pending workloads, held back to limit its changes, can be updated.
+func (r *QueueReconciler) updateStatus(ctx context.Context, queueObj *kueue.Queue) (time.Duration, error) {
+	oldStatus := queueObj.Status.DeepCopy()
 
-	pending, err := r.queues.PendingWorkloads(&queueObj)
+	pending, err := r.queues.PendingWorkloads(queueObj)
 	if err != nil {
 		r.log.Error(err, "Failed to retrieve queue status")
-		return ctrl.Result{}, err
+		return 0, err
 	}
+	usage, counts, flavors := r.cache.QueueUsage(queueObj)
 
 	queueObj.Status.PendingWorkloads = pending
-	if !equality.Semantic.DeepEqual(oldStatus, queueObj.Status) {
-		err := r.client.Status().Update(ctx, &queueObj)
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	queueObj.Status.ReservingWorkloads = int32(counts.Reserving)
+	queueObj.Status.AdmittedWorkloads = int32(counts.Admitted)
+	queueObj.Status.UsedResources = usage
+	queueObj.Status.Flavors = flavors
+	var requeueAfter time.Duration
+	queueObj.Status.PendingWorkloadsStatus, requeueAfter = r.visibility.pendingWorkloadsStatus(oldStatus.PendingWorkloadsStatus, func() []queue.PendingWorkload {
+		workloads, _ := r.queues.QueuePendingWorkloads(queueObj.Namespace, queueObj.Name)
+		return workloads
+	})
+	if !equality.Semantic.DeepEqual(*oldStatus, queueObj.Status) {
+		return requeueAfter, client.IgnoreNotFound(r.client.Status().Update(ctx, queueObj))
 	}
-	return ctrl.Result{}, nil
+	return requeueAfter, nil
 }
 
 func (r *QueueReconciler) Create(e event.CreateEvent) bool {
@@ -99,10 +148,29 @@ func (r *QueueReconciler) Create(e event.CreateEvent) bool {
 	ctx := logr.NewContext(context.Background(), log)
 	if err := r.queues.AddQueue(ctx, q); err != nil {
 		log.Error(err, "Failed to add queue to system")
+		if !errors.Is(err, queue.ErrQueueAlreadyExists) {
+			r.retries.Add("Queue/"+queue.Key(q), r.retryAddQueue(client.ObjectKeyFromObject(q)))
+		}
 	}
+	r.cache.AddOrUpdateQueue(q)
 	return true
 }
 
+// retryAddQueue returns an operation that adds the latest version of the
+// Queue to the queue manager, unless it was deleted or added already.
+func (r *QueueReconciler) retryAddQueue(key types.NamespacedName) retry.Func {
+	return func(ctx context.Context) error {
+		var q kueue.Queue
+		if err := r.client.Get(ctx, key, &q); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if err := r.queues.AddQueue(ctx, &q); err != nil && !errors.Is(err, queue.ErrQueueAlreadyExists) {
+			return err
+		}
+		return nil
+	}
+}
+
 func (r *QueueReconciler) Delete(e event.DeleteEvent) bool {
 	q, match := e.Object.(*kueue.Queue)
 	if !match {
@@ -111,6 +179,7 @@ func (r *QueueReconciler) Delete(e event.DeleteEvent) bool {
 	}
 	r.log.V(2).Info("Queue delete event", "queue", klog.KObj(q))
 	r.queues.DeleteQueue(q)
+	r.cache.DeleteQueue(q)
 	return true
 }
 
@@ -125,6 +194,7 @@ func (r *QueueReconciler) Update(e event.UpdateEvent) bool {
 	if err := r.queues.UpdateQueue(q); err != nil {
 		log.Error(err, "Failed to update queue in system")
 	}
+	r.cache.AddOrUpdateQueue(q)
 	return true
 }
 
@@ -162,11 +232,47 @@ func (h *qWorkloadHandler) Generic(e event.GenericEvent, q workqueue.RateLimitin
 	q.AddAfter(req, constants.UpdatesBatchPeriod)
 }
 
+// qClusterQueueHandler signals the controller to reconcile the Queues of the
+// ClusterQueue in the event, whose quotas or flavors might have changed.
+type qClusterQueueHandler struct {
+	client client.Client
+	log    logr.Logger
+}
+
+func (h *qClusterQueueHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.addQueues(e.Object, q)
+}
+
+func (h *qClusterQueueHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.addQueues(e.ObjectNew, q)
+}
+
+func (h *qClusterQueueHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.addQueues(e.Object, q)
+}
+
+func (h *qClusterQueueHandler) Generic(event.GenericEvent, workqueue.RateLimitingInterface) {
+}
+
+func (h *qClusterQueueHandler) addQueues(cq client.Object, q workqueue.RateLimitingInterface) {
+	var queues kueue.QueueList
+	if err := h.client.List(context.Background(), &queues, client.MatchingFields{queue.QueueClusterQueueKey: cq.GetName()}); err != nil {
+		h.log.Error(err, "Failed to list the queues of the clusterQueue", "clusterQueue", klog.KObj(cq))
+		return
+	}
+	for _, queueObj := range queues.Items {
+		req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&queueObj)}
+		// Give time to the ClusterQueueReconciler to update the cache.
+		q.AddAfter(req, constants.UpdatesBatchPeriod)
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *QueueReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kueue.Queue{}).
 		Watches(&source.Channel{Source: r.wlUpdateCh}, &qWorkloadHandler{}).
+		Watches(&source.Kind{Type: &kueue.ClusterQueue{}}, &qClusterQueueHandler{client: r.client, log: r.log}).
 		WithEventFilter(r).
 		Complete(r)
 }
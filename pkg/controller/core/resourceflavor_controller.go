@@ -20,65 +20,157 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/queue"
 )
 
+// ResourceFlavorUpdateWatcher is notified of the ResourceFlavor events, for
+// example to update the status of the ClusterQueues that reference them.
+type ResourceFlavorUpdateWatcher interface {
+	NotifyResourceFlavorUpdate(*kueue.ResourceFlavor)
+}
+
 // ResourceFlavorReconciler reconciles a ResourceFlavor object
 type ResourceFlavorReconciler struct {
-	log   logr.Logger
-	cache *cache.Cache
+	client   client.Client
+	log      logr.Logger
+	qManager queue.Interface
+	cache    cache.Interface
+	watchers []ResourceFlavorUpdateWatcher
 }
 
-func NewResourceFlavorReconciler(cache *cache.Cache) *ResourceFlavorReconciler {
+func NewResourceFlavorReconciler(client client.Client, qMgr queue.Interface, cache cache.Interface, watchers ...ResourceFlavorUpdateWatcher) *ResourceFlavorReconciler {
 	return &ResourceFlavorReconciler{
-		log:   ctrl.Log.WithName("resourceflavor-reconciler"),
-		cache: cache,
+		client:   client,
+		log:      ctrl.Log.WithName("resourceflavor-reconciler"),
+		qManager: qMgr,
+		cache:    cache,
+		watchers: watchers,
 	}
 }
 
-//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=resourceflavors,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=resourceflavors,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=resourceflavors/finalizers,verbs=update
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=clusterqueues,verbs=get;list;watch
 
+// Reconcile adds the ResourceInUse finalizer to the ResourceFlavor and, once
+// the ResourceFlavor is being deleted, removes it when no ClusterQueue
+// references the ResourceFlavor anymore.
 func (r *ResourceFlavorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	// Nothing to do here.
-	return ctrl.Result{}, nil
+	var flv kueue.ResourceFlavor
+	if err := r.client.Get(ctx, req.NamespacedName, &flv); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	log := ctrl.LoggerFrom(ctx).WithValues("resourceFlavor", klog.KObj(&flv))
+	log.V(2).Info("Reconciling ResourceFlavor")
+
+	if flv.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&flv, constants.ResourceInUseFinalizerName) {
+			controllerutil.AddFinalizer(&flv, constants.ResourceInUseFinalizerName)
+			if err := r.client.Update(ctx, &flv); err != nil {
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&flv, constants.ResourceInUseFinalizerName) {
+		return ctrl.Result{}, nil
+	}
+	cqs, err := r.clusterQueuesUsingFlavor(ctx, flv.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(cqs) > 0 {
+		log.V(2).Info("Waiting for the ClusterQueues to stop referencing the ResourceFlavor before deleting it", "clusterQueues", cqs)
+		return ctrl.Result{}, nil
+	}
+	controllerutil.RemoveFinalizer(&flv, constants.ResourceInUseFinalizerName)
+	return ctrl.Result{}, client.IgnoreNotFound(r.client.Update(ctx, &flv))
+}
+
+// clusterQueuesUsingFlavor returns the names of the ClusterQueues that
+// reference the ResourceFlavor. They are listed from the informer, instead of
+// the cache, so that they are consistent with the events of the ClusterQueues
+// that trigger the reconciliation.
+func (r *ResourceFlavorReconciler) clusterQueuesUsingFlavor(ctx context.Context, name string) ([]string, error) {
+	var cqs kueue.ClusterQueueList
+	if err := r.client.List(ctx, &cqs); err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := range cqs.Items {
+		if flavorsOfClusterQueue(&cqs.Items[i]).Has(name) {
+			names = append(names, cqs.Items[i].Name)
+		}
+	}
+	return names, nil
+}
+
+// flavorsOfClusterQueue returns the names of the ResourceFlavors that the
+// ClusterQueue references.
+func flavorsOfClusterQueue(cq *kueue.ClusterQueue) sets.String {
+	flavors := sets.NewString()
+	for _, rg := range cq.Spec.ResourceGroups {
+		for _, f := range rg.Flavors {
+			flavors.Insert(string(f.Name))
+		}
+	}
+	return flavors
 }
 
 func (r *ResourceFlavorReconciler) Create(e event.CreateEvent) bool {
 	flv, match := e.Object.(*kueue.ResourceFlavor)
 	if !match {
-		return false
+		// The ClusterQueue events are filtered by the handler.
+		return true
 	}
 	log := r.log.WithValues("resourceFlavor", klog.KObj(flv))
 	log.V(2).Info("ResourceFlavor create event")
 	r.cache.AddOrUpdateResourceFlavor(flv.DeepCopy())
-	return false
+	// The ClusterQueues waiting for the flavor might be active now.
+	r.qManager.QueueInadmissibleWorkloads(r.cache.ClusterQueuesUsingFlavor(flv.Name))
+	r.notifyWatchers(flv)
+	// Add the finalizer.
+	return true
 }
 
 func (r *ResourceFlavorReconciler) Delete(e event.DeleteEvent) bool {
 	flv, match := e.Object.(*kueue.ResourceFlavor)
 	if !match {
-		return false
+		return true
 	}
 	log := r.log.WithValues("resourceFlavor", klog.KObj(flv))
 	log.V(2).Info("ResourceFlavor delete event")
 	r.cache.DeleteResourceFlavor(flv)
+	r.notifyWatchers(flv)
 	return false
 }
 
 func (r *ResourceFlavorReconciler) Update(e event.UpdateEvent) bool {
 	flv, match := e.ObjectNew.(*kueue.ResourceFlavor)
 	if !match {
-		return false
+		return true
 	}
 	log := r.log.WithValues("resourceFlavor", klog.KObj(flv))
 	log.V(2).Info("ResourceFlavor update event")
 	r.cache.AddOrUpdateResourceFlavor(flv.DeepCopy())
-	return false
+	// Add the finalizer if it was removed, or remove it if the
+	// ResourceFlavor is being deleted.
+	return true
 }
 
 func (r *ResourceFlavorReconciler) Generic(e event.GenericEvent) bool {
@@ -86,10 +178,52 @@ func (r *ResourceFlavorReconciler) Generic(e event.GenericEvent) bool {
 	return false
 }
 
+func (r *ResourceFlavorReconciler) notifyWatchers(flv *kueue.ResourceFlavor) {
+	for _, w := range r.watchers {
+		w.NotifyResourceFlavorUpdate(flv)
+	}
+}
+
+// rfClusterQueueHandler signals the controller to reconcile the
+// ResourceFlavors that a ClusterQueue stopped referencing, so that their
+// deletion can finish.
+type rfClusterQueueHandler struct{}
+
+func (h *rfClusterQueueHandler) Create(event.CreateEvent, workqueue.RateLimitingInterface) {
+}
+
+func (h *rfClusterQueueHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	oldCq, ok := e.ObjectOld.(*kueue.ClusterQueue)
+	if !ok {
+		return
+	}
+	newCq, ok := e.ObjectNew.(*kueue.ClusterQueue)
+	if !ok {
+		return
+	}
+	addFlavors(flavorsOfClusterQueue(oldCq).Difference(flavorsOfClusterQueue(newCq)), q)
+}
+
+func (h *rfClusterQueueHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	if cq, ok := e.Object.(*kueue.ClusterQueue); ok {
+		addFlavors(flavorsOfClusterQueue(cq), q)
+	}
+}
+
+func (h *rfClusterQueueHandler) Generic(event.GenericEvent, workqueue.RateLimitingInterface) {
+}
+
+func addFlavors(flavors sets.String, q workqueue.RateLimitingInterface) {
+	for name := range flavors {
+		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResourceFlavorReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kueue.ResourceFlavor{}).
+		Watches(&source.Kind{Type: &kueue.ClusterQueue{}}, &rfClusterQueueHandler{}).
 		WithEventFilter(r).
 		Complete(r)
 }
@@ -19,10 +19,20 @@ package core
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -35,9 +45,22 @@ import (
 
 const (
 	// statuses for logging purposes
-	pending  = "pending"
-	admitted = "admitted"
-	finished = "finished"
+	pending       = "pending"
+	quotaReserved = "quotaReserved"
+	admitted      = "admitted"
+	finished      = "finished"
+
+	// defaultRequeueBaseDelay and defaultRequeueMaxDelay bound the
+	// exponential backoff before a workload evicted by the PodsReady timeout
+	// is requeued.
+	defaultRequeueBaseDelay = time.Minute
+	defaultRequeueMaxDelay  = time.Hour
+
+	// ownerCheckInterval is how often the owner of an admitted workload is
+	// checked, so that a workload whose job was deleted without deleting the
+	// workload doesn't keep its quota. The owner is read from the API server
+	// at most once per interval, however often the workload is reconciled.
+	ownerCheckInterval = 5 * time.Minute
 )
 
 type WorkloadUpdateWatcher interface {
@@ -47,19 +70,137 @@ type WorkloadUpdateWatcher interface {
 // WorkloadReconciler reconciles a Workload object
 type WorkloadReconciler struct {
 	log      logr.Logger
-	queues   *queue.Manager
-	cache    *cache.Cache
+	queues   queue.Interface
+	cache    cache.Interface
 	client   client.Client
+	recorder record.EventRecorder
 	watchers []WorkloadUpdateWatcher
+	// ownerReader reads the owners of the workloads. It shouldn't be backed
+	// by a cache, as the owners can be of any kind.
+	ownerReader client.Reader
+	// ownerChecks holds when the owners of the admitted workloads were last
+	// checked, by the UID of the workloads.
+	ownerChecksMu sync.Mutex
+	ownerChecks   map[types.UID]time.Time
+
+	podsReadyTimeout *time.Duration
+	requeuing        RequeuingBackoff
+	retention        FinishedRetention
 }
 
-func NewWorkloadReconciler(client client.Client, queues *queue.Manager, cache *cache.Cache, watchers ...WorkloadUpdateWatcher) *WorkloadReconciler {
+type options struct {
+	watchers         []WorkloadUpdateWatcher
+	podsReadyTimeout *time.Duration
+	requeuing        RequeuingBackoff
+	ownerReader      client.Reader
+	queueVisibility  queueVisibility
+	retention        FinishedRetention
+}
+
+// RequeuingBackoff configures the backoff before a workload evicted by the
+// PodsReady timeout is requeued.
+type RequeuingBackoff struct {
+	// LimitCount is the number of times that the workload is requeued after
+	// consecutive evictions, before it's deactivated instead. Nil means no
+	// limit.
+	LimitCount *int32
+	// BaseDelay is the delay after the first eviction, doubled with every
+	// consecutive eviction. Zero means 1 minute.
+	BaseDelay time.Duration
+	// MaxDelay is the maximum delay. Zero means 1 hour.
+	MaxDelay time.Duration
+}
+
+// FinishedRetention configures the deletion of the finished workloads.
+type FinishedRetention struct {
+	// TTL is the time that a workload is kept after it finishes. Nil keeps
+	// the finished workloads.
+	TTL *time.Duration
+	// DeleteOwners indicates whether the owner of the workload, like its
+	// job, is deleted along with it.
+	DeleteOwners bool
+}
+
+// Option configures the controllers.
+type Option func(*options)
+
+// WithWorkloadUpdateWatchers sets the watchers notified of the workload
+// events.
+func WithWorkloadUpdateWatchers(watchers ...WorkloadUpdateWatcher) Option {
+	return func(o *options) {
+		o.watchers = append(o.watchers, watchers...)
+	}
+}
+
+// WithPodsReadyTimeout sets the time that an admitted workload has for its
+// pods to be ready. When exceeded, the workload is evicted and requeued.
+// Nil disables the timeout.
+func WithPodsReadyTimeout(timeout *time.Duration) Option {
+	return func(o *options) {
+		o.podsReadyTimeout = timeout
+	}
+}
+
+// WithRequeuingBackoff sets the backoff before a workload evicted by the
+// PodsReady timeout is requeued.
+func WithRequeuingBackoff(backoff RequeuingBackoff) Option {
+	return func(o *options) {
+		o.requeuing = backoff
+	}
+}
+
+// WithOwnerReader sets the reader used to check whether the owners of the
+// admitted workloads still exist. Defaults to the client of the reconciler.
+func WithOwnerReader(reader client.Reader) Option {
+	return func(o *options) {
+		o.ownerReader = reader
+	}
+}
+
+// WithQueueVisibility sets the maximum number of pending workloads listed in
+// the status of each ClusterQueue and Queue, and the minimum time between
+// changes of the list. Zero or less disables the list.
+func WithQueueVisibility(maxCount int32, updateInterval time.Duration) Option {
+	return func(o *options) {
+		o.queueVisibility = queueVisibility{maxCount: maxCount, updateInterval: updateInterval}
+	}
+}
+
+// WithFinishedRetention sets when the finished workloads are deleted.
+func WithFinishedRetention(retention FinishedRetention) Option {
+	return func(o *options) {
+		o.retention = retention
+	}
+}
+
+func NewWorkloadReconciler(client client.Client, queues queue.Interface, cache cache.Interface, recorder record.EventRecorder, opts ...Option) *WorkloadReconciler {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	ownerReader := options.ownerReader
+	if ownerReader == nil {
+		ownerReader = client
+	}
+	requeuing := options.requeuing
+	if requeuing.BaseDelay == 0 {
+		requeuing.BaseDelay = defaultRequeueBaseDelay
+	}
+	if requeuing.MaxDelay == 0 {
+		requeuing.MaxDelay = defaultRequeueMaxDelay
+	}
 	return &WorkloadReconciler{
-		log:      ctrl.Log.WithName("workload-reconciler"),
-		client:   client,
-		queues:   queues,
-		cache:    cache,
-		watchers: watchers,
+		log:              ctrl.Log.WithName("workload-reconciler"),
+		client:           client,
+		queues:           queues,
+		cache:            cache,
+		recorder:         recorder,
+		watchers:         options.watchers,
+		ownerReader:      ownerReader,
+		ownerChecks:      make(map[types.UID]time.Time),
+		podsReadyTimeout: options.podsReadyTimeout,
+		requeuing:        requeuing,
+		retention:        options.retention,
 	}
 }
 
@@ -67,6 +208,8 @@ func NewWorkloadReconciler(client client.Client, queues *queue.Manager, cache *c
 //+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/finalizers,verbs=update
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=admissionchecks,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=delete
 
 func (r *WorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var wl kueue.Workload
@@ -79,26 +222,527 @@ func (r *WorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	log.V(2).Info("Reconciling Workload")
 
 	status := workloadStatus(&wl)
-	if status == pending && !r.queues.QueueForWorkloadExists(&wl) {
+	if status == finished {
+		return r.reconcileFinished(ctx, &wl)
+	}
+	if workload.InCondition(&wl, kueue.WorkloadEvicted) {
+		return ctrl.Result{}, client.IgnoreNotFound(r.evict(ctx, &wl))
+	}
+	if hasQuotaReservation(status) && !workload.IsActive(&wl) {
+		log.V(2).Info("Evicting deactivated workload")
+		err := workload.Evict(ctx, r.client, &wl, workload.EvictedByDeactivation, "The workload is deactivated")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if status == pending && (workload.InCondition(&wl, kueue.WorkloadQuotaReserved) || len(wl.Status.AdmissionChecks) > 0) {
+		err := workload.UnsetQuotaReservation(ctx, r.client, &wl, "Pending", "The workload has no quota reservation")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if status == pending && !workload.IsActive(&wl) {
+		if deactivatedByRequeuingLimit(&wl) {
+			cooldown, err := r.reactivationCooldown(ctx, &wl)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if cooldown != nil {
+				return r.reconcileReactivation(ctx, &wl, *cooldown)
+			}
+			err = workload.UpdateStatusIfChanged(ctx, r.client, &wl, kueue.WorkloadAdmitted, corev1.ConditionFalse,
+				workload.EvictedByRequeuingLimitExceeded, "The workload is deactivated for exceeding the requeuing limit")
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
 		err := workload.UpdateStatusIfChanged(ctx, r.client, &wl, kueue.WorkloadAdmitted, corev1.ConditionFalse,
-			"Inadmissible", fmt.Sprintf("Queue %s doesn't exist", wl.Spec.QueueName))
+			"Inactive", "The workload is deactivated")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if status == pending && wasDeactivated(&wl) {
+		// The backoff and the execution time start over when the workload is
+		// activated. The end of the reactivation cooldown is kept, so that a
+		// workload activated manually during the cooldown waits for it.
+		newWl := wl.DeepCopy()
+		newWl.Status.RequeueState = nil
+		if rs := wl.Status.RequeueState; rs != nil && rs.RequeueAt != nil && time.Now().Before(rs.RequeueAt.Time) &&
+			deactivatedByRequeuingLimit(&wl) {
+			newWl.Status.RequeueState = &kueue.RequeueState{RequeueAt: rs.RequeueAt}
+		}
+		newWl.Status.AccumulatedPastExecutionTimeSeconds = nil
+		err := workload.UpdatePendingStatus(ctx, r.client, newWl, "The workload was activated", newWl.Status.AdmissionBackoff)
+		if err == nil {
+			log.V(2).Info("Workload activated")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if status == pending && !r.queues.QueueForWorkloadExists(&wl) {
+		err := r.reportInadmissible(ctx, &wl, fmt.Sprintf("Queue %s doesn't exist", wl.Spec.QueueName))
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 	cqName, cqOk := r.queues.ClusterQueueForWorkload(&wl)
 	if status == pending && !cqOk {
-		err := workload.UpdateStatusIfChanged(ctx, r.client, &wl, kueue.WorkloadAdmitted, corev1.ConditionFalse,
-			"Inadmissible", fmt.Sprintf("ClusterQueue %s doesn't exist", cqName))
+		err := r.reportInadmissible(ctx, &wl, fmt.Sprintf("ClusterQueue %s doesn't exist", cqName))
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if status == admitted {
+	if hasQuotaReservation(status) {
+		if wl.Status.AdmissionBackoff != nil {
+			// The backoff of the admission attempts is over.
+			newWl := wl.DeepCopy()
+			newWl.Status.AdmissionBackoff = nil
+			return ctrl.Result{}, client.IgnoreNotFound(r.client.Status().Update(ctx, newWl))
+		}
+		if orphaned, err := r.ownerMissing(ctx, &wl); err != nil || orphaned {
+			if err == nil {
+				log.V(2).Info("Deleting workload whose owner no longer exists")
+				err = r.client.Delete(ctx, &wl, client.Preconditions{UID: &wl.UID})
+			}
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		if done, err := r.reconcileAdmissionChecks(ctx, &wl); done || err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
 		err := workload.UpdateStatusIfChanged(ctx, r.client, &wl, kueue.WorkloadAdmitted, corev1.ConditionTrue, "", "")
+		if err != nil || !workload.InCondition(&wl, kueue.WorkloadAdmitted) {
+			return withOwnerCheck(&wl, ctrl.Result{}), client.IgnoreNotFound(err)
+		}
+		remaining, exceeded := executionTimeRemaining(&wl)
+		if exceeded {
+			log.V(2).Info("Evicting workload exceeding its maximum execution time")
+			err := workload.Evict(ctx, r.client, &wl, workload.EvictedByDeadlineExceeded,
+				fmt.Sprintf("Exceeded the maximum execution time of %ds", *wl.Spec.MaximumExecutionTimeSeconds))
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		var result ctrl.Result
+		if r.podsReadyTimeout != nil {
+			result, err = r.reconcilePodsReadyTimeout(ctx, &wl)
+		}
+		if remaining > 0 && (result.RequeueAfter == 0 || result.RequeueAfter > remaining) {
+			result.RequeueAfter = remaining
+		}
+		return withOwnerCheck(&wl, result), err
+	}
+
+	if status == pending && wl.Status.RequeueState != nil && wl.Status.RequeueState.RequeueAt != nil {
+		if remaining := time.Until(wl.Status.RequeueState.RequeueAt.Time); remaining > 0 {
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+		// Clearing the requeue time makes the workload admissible again.
+		newWl := wl.DeepCopy()
+		newWl.Status.RequeueState.RequeueAt = nil
+		err := r.client.Status().Update(ctx, newWl)
+		if err == nil {
+			log.V(2).Info("Requeue backoff expired")
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// reconcileFinished deletes the finished workload once it's been finished
+// for the TTL, along with its owner if configured. Otherwise, it returns the
+// time until the workload expires.
+func (r *WorkloadReconciler) reconcileFinished(ctx context.Context, wl *kueue.Workload) (ctrl.Result, error) {
+	if r.retention.TTL == nil {
+		return ctrl.Result{}, nil
+	}
+	finishedAt := wl.Status.Conditions[workload.FindConditionIndex(&wl.Status, kueue.WorkloadFinished)].LastTransitionTime
+	if remaining := *r.retention.TTL - time.Since(finishedAt.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+	log := ctrl.LoggerFrom(ctx)
+	if ref := metav1.GetControllerOf(wl); ref != nil && r.retention.DeleteOwners {
+		owner := &metav1.PartialObjectMetadata{}
+		owner.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+		owner.SetNamespace(wl.Namespace)
+		owner.SetName(ref.Name)
+		log.V(2).Info("Deleting the owner of the expired finished workload", "owner", klog.KRef(wl.Namespace, ref.Name), "kind", ref.Kind)
+		// kueue is only allowed to delete the owners of the kinds that it
+		// integrates with, so an owner that it can't delete is left alone
+		// rather than keeping the workload forever.
+		err := r.client.Delete(ctx, owner, client.Preconditions{UID: &ref.UID}, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if apierrors.IsForbidden(err) {
+			log.V(2).Info("Not allowed to delete the owner of the expired finished workload", "owner", klog.KRef(wl.Namespace, ref.Name), "kind", ref.Kind, "error", err)
+		} else if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	log.V(2).Info("Deleting expired finished workload")
+	return ctrl.Result{}, client.IgnoreNotFound(r.client.Delete(ctx, wl, client.Preconditions{UID: &wl.UID}))
+}
+
+// reportInadmissible records in the Admitted condition, and in an event, why
+// the pending workload can't be admitted. The event is only recorded when the
+// reason changes.
+func (r *WorkloadReconciler) reportInadmissible(ctx context.Context, wl *kueue.Workload, message string) error {
+	if i := workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted); i != -1 {
+		if c := wl.Status.Conditions[i]; c.Status == corev1.ConditionFalse && c.Reason == "Inadmissible" && c.Message == message {
+			return nil
+		}
+	}
+	if err := workload.UpdateStatus(ctx, r.client, wl, kueue.WorkloadAdmitted, corev1.ConditionFalse, "Inadmissible", message); err != nil {
+		return err
+	}
+	workload.RecordEvent(r.recorder, wl, corev1.EventTypeWarning, "Inadmissible", message)
+	return nil
+}
+
+// ownerMissing returns whether the workload has a controller owner that no
+// longer exists, or that was replaced by an object with the same name.
+// Errors other than not finding the owner, like a missing permission to read
+// it, leave the workload alone. The owner is only read from the API server on
+// the first reconcile of the workload and once per ownerCheckInterval.
+func (r *WorkloadReconciler) ownerMissing(ctx context.Context, wl *kueue.Workload) (bool, error) {
+	ref := metav1.GetControllerOf(wl)
+	if ref == nil || !r.ownerCheckDue(wl.UID) {
+		return false, nil
+	}
+	owner := &metav1.PartialObjectMetadata{}
+	owner.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	err := r.ownerReader.Get(ctx, types.NamespacedName{Namespace: wl.Namespace, Name: ref.Name}, owner)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		ctrl.LoggerFrom(ctx).V(2).Info("Could not check the owner of the workload", "owner", klog.KRef(wl.Namespace, ref.Name), "kind", ref.Kind, "error", err.Error())
+		return false, nil
+	}
+	return owner.UID != ref.UID, nil
+}
+
+// ownerCheckDue returns whether the owner of the workload wasn't checked in
+// the last ownerCheckInterval, recording the check if it's due.
+func (r *WorkloadReconciler) ownerCheckDue(uid types.UID) bool {
+	r.ownerChecksMu.Lock()
+	defer r.ownerChecksMu.Unlock()
+	now := time.Now()
+	if last, ok := r.ownerChecks[uid]; ok && now.Sub(last) < ownerCheckInterval {
+		return false
+	}
+	r.ownerChecks[uid] = now
+	return true
+}
+
+// executionTimeRemaining returns the time that the admitted workload can
+// stay admitted, considering the time that it was admitted before, or whether
+// it already exceeded its maximum execution time. It returns zero if the
+// workload has no maximum execution time.
+func executionTimeRemaining(wl *kueue.Workload) (time.Duration, bool) {
+	if wl.Spec.MaximumExecutionTimeSeconds == nil {
+		return 0, false
+	}
+	admitted := wl.Status.Conditions[workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted)]
+	maxTime := time.Duration(*wl.Spec.MaximumExecutionTimeSeconds) * time.Second
+	pastTime := time.Duration(pointer.Int32Deref(wl.Status.AccumulatedPastExecutionTimeSeconds, 0)) * time.Second
+	remaining := maxTime - pastTime - time.Since(admitted.LastTransitionTime.Time)
+	return remaining, remaining <= 0
+}
+
+// withOwnerCheck makes the result requeue the workload in time for the next
+// check of its owner.
+func withOwnerCheck(wl *kueue.Workload, result ctrl.Result) ctrl.Result {
+	if metav1.GetControllerOf(wl) == nil {
+		return result
+	}
+	if result.RequeueAfter == 0 || result.RequeueAfter > ownerCheckInterval {
+		result.RequeueAfter = ownerCheckInterval
+	}
+	return result
+}
+
+// reconcileAdmissionChecks records the quota reservation of the workload and
+// tracks the state of its AdmissionChecks. It returns whether it updated the
+// workload or the workload is waiting for the checks, in which case it isn't
+// admitted yet.
+func (r *WorkloadReconciler) reconcileAdmissionChecks(ctx context.Context, wl *kueue.Workload) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if !workload.InCondition(wl, kueue.WorkloadQuotaReserved) {
+		return true, workload.UpdateStatus(ctx, r.client, wl, kueue.WorkloadQuotaReserved, corev1.ConditionTrue,
+			"QuotaReserved", fmt.Sprintf("Quota reserved in ClusterQueue %s", wl.Spec.Admission.ClusterQueue))
+	}
+
+	checks := wl.Spec.Admission.AdmissionChecks
+	if len(wl.Status.AdmissionChecks) != len(checks) {
+		newWl := wl.DeepCopy()
+		newWl.Status.AdmissionChecks = make([]kueue.AdmissionCheckState, len(checks))
+		for i, name := range checks {
+			if state := workload.FindAdmissionCheck(wl.Status.AdmissionChecks, name); state != nil {
+				newWl.Status.AdmissionChecks[i] = *state
+				continue
+			}
+			state := kueue.AdmissionCheckState{
+				Name:               name,
+				State:              kueue.CheckStatePending,
+				LastTransitionTime: metav1.Now(),
+			}
+			var ac kueue.AdmissionCheck
+			if err := r.client.Get(ctx, types.NamespacedName{Name: name}, &ac); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return true, err
+				}
+				state.Message = "AdmissionCheck not found"
+			}
+			newWl.Status.AdmissionChecks[i] = state
+		}
+		return true, r.client.Status().Update(ctx, newWl)
+	}
+
+	if len(checks) > 0 {
+		status, reason, message := admissionChecksCondition(checks, wl.Status.AdmissionChecks)
+		if !workload.ConditionMatches(wl, kueue.WorkloadAdmissionChecksReady, status, reason, message) {
+			return true, workload.UpdateStatus(ctx, r.client, wl, kueue.WorkloadAdmissionChecksReady, status, reason, message)
+		}
+	}
+
+	var pending []string
+	var retry *kueue.AdmissionCheckState
+	for _, name := range checks {
+		state := workload.FindAdmissionCheck(wl.Status.AdmissionChecks, name)
+		if state == nil {
+			// Unreachable, the states match the checks after the update above.
+			pending = append(pending, name)
+			continue
+		}
+		switch state.State {
+		case kueue.CheckStateRejected:
+			log.V(2).Info("AdmissionCheck rejected the workload, evicting and deactivating it", "admissionCheck", name)
+			return true, workload.Evict(ctx, r.client, wl, workload.EvictedByAdmissionCheckRejected,
+				fmt.Sprintf("AdmissionCheck %s rejected the workload: %s", name, state.Message))
+		case kueue.CheckStateRetry:
+			if retry == nil {
+				retry = state
+			}
+		case kueue.CheckStatePending:
+			pending = append(pending, name)
+		}
+	}
+	if retry != nil {
+		log.V(2).Info("AdmissionCheck requested a retry, evicting workload", "admissionCheck", retry.Name)
+		return true, workload.Evict(ctx, r.client, wl, workload.EvictedByAdmissionCheck,
+			fmt.Sprintf("AdmissionCheck %s requested a retry: %s", retry.Name, retry.Message))
+	}
+	if len(pending) > 0 {
+		return true, workload.UpdateStatusIfChanged(ctx, r.client, wl, kueue.WorkloadAdmitted, corev1.ConditionFalse,
+			"AdmissionChecksPending", fmt.Sprintf("Waiting for the AdmissionChecks %s", strings.Join(pending, ", ")))
+	}
+	return false, nil
+}
+
+// admissionChecksCondition returns the status, reason and message of the
+// condition that summarizes the states of the checks. The reason is the most
+// severe of the states and the message lists the checks that aren't Ready.
+func admissionChecksCondition(checks []string, states []kueue.AdmissionCheckState) (corev1.ConditionStatus, string, string) {
+	severity := map[kueue.CheckState]int{
+		kueue.CheckStateReady:    0,
+		kueue.CheckStatePending:  1,
+		kueue.CheckStateRetry:    2,
+		kueue.CheckStateRejected: 3,
+	}
+	worst := kueue.CheckStateReady
+	var details []string
+	for _, name := range checks {
+		state := kueue.AdmissionCheckState{Name: name, State: kueue.CheckStatePending}
+		if s := workload.FindAdmissionCheck(states, name); s != nil {
+			state = *s
+		}
+		if state.State == kueue.CheckStateReady {
+			continue
+		}
+		if severity[state.State] > severity[worst] {
+			worst = state.State
+		}
+		detail := fmt.Sprintf("%s is %s", name, state.State)
+		if state.Message != "" {
+			detail += ": " + state.Message
+		}
+		details = append(details, detail)
+	}
+	if worst == kueue.CheckStateReady {
+		return corev1.ConditionTrue, string(kueue.CheckStateReady), "All the AdmissionChecks are Ready"
+	}
+	return corev1.ConditionFalse, string(worst), fmt.Sprintf("AdmissionChecks not Ready: %s", strings.Join(details, "; "))
+}
+
+// reconcilePodsReadyTimeout evicts the admitted workload if its pods weren't
+// ready within the timeout since the admission. The workload is requeued
+// after an exponential backoff on the number of evictions.
+func (r *WorkloadReconciler) reconcilePodsReadyTimeout(ctx context.Context, wl *kueue.Workload) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if workload.InCondition(wl, kueue.WorkloadPodsReady) {
+		if wl.Status.RequeueState == nil {
+			return ctrl.Result{}, nil
+		}
+		newWl := wl.DeepCopy()
+		newWl.Status.RequeueState = nil
+		return ctrl.Result{}, client.IgnoreNotFound(r.client.Status().Update(ctx, newWl))
+	}
+	admitted := wl.Status.Conditions[workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted)]
+	if remaining := *r.podsReadyTimeout - time.Since(admitted.LastTransitionTime.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	limit, err := r.requeuingLimit(ctx, wl)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	newWl := wl.DeepCopy()
+	if newWl.Status.RequeueState == nil {
+		newWl.Status.RequeueState = &kueue.RequeueState{}
+	}
+	if count := newWl.Status.RequeueState.Count; limit != nil && count >= *limit {
+		// The workload is deactivated along with the eviction, so it's not
+		// requeued.
+		newWl.Status.RequeueState.RequeueAt = nil
+		err := workload.Evict(ctx, r.client, newWl, workload.EvictedByRequeuingLimitExceeded,
+			fmt.Sprintf("Exceeded the PodsReady timeout of %s after being requeued %d times", *r.podsReadyTimeout, count))
+		if err == nil {
+			log.V(2).Info("Evicting and deactivating workload that exceeded the requeuing limit", "evictions", count)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	newWl.Status.RequeueState.Count++
+	delay := r.requeuing.delay(newWl.Status.RequeueState.Count)
+	requeueAt := metav1.NewTime(time.Now().Add(delay))
+	newWl.Status.RequeueState.RequeueAt = &requeueAt
+	err = workload.Evict(ctx, r.client, newWl, workload.EvictedByPodsReadyTimeout,
+		fmt.Sprintf("Exceeded the PodsReady timeout of %s", *r.podsReadyTimeout))
+	if err == nil {
+		log.V(2).Info("Evicting workload exceeding the PodsReady timeout", "requeueAfter", delay)
+	}
+	return ctrl.Result{}, client.IgnoreNotFound(err)
+}
+
+// requeuingLimit returns the number of times that the admitted workload is
+// requeued after consecutive evictions by the PodsReady timeout: the limit of
+// its ClusterQueue, or the limit of the backoff if the ClusterQueue doesn't
+// set one. Nil means no limit.
+func (r *WorkloadReconciler) requeuingLimit(ctx context.Context, wl *kueue.Workload) (*int32, error) {
+	var cq kueue.ClusterQueue
+	if err := r.client.Get(ctx, types.NamespacedName{Name: string(wl.Spec.Admission.ClusterQueue)}, &cq); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	if cq.Spec.RequeuingLimitCount != nil {
+		return cq.Spec.RequeuingLimitCount, nil
+	}
+	return r.requeuing.LimitCount, nil
+}
+
+// deactivatedByRequeuingLimit returns whether the inactive workload was
+// deactivated for exceeding the requeuing limit. The reason is recorded in
+// its Admitted condition, or in its Evicted condition right after the
+// eviction.
+func deactivatedByRequeuingLimit(wl *kueue.Workload) bool {
+	i := workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted)
+	if i == -1 {
+		return false
+	}
+	switch wl.Status.Conditions[i].Reason {
+	case workload.EvictedByRequeuingLimitExceeded:
+		return true
+	case "Evicted":
+		j := workload.FindConditionIndex(&wl.Status, kueue.WorkloadEvicted)
+		return j != -1 && wl.Status.Conditions[j].Reason == workload.EvictedByRequeuingLimitExceeded
+	}
+	return false
+}
+
+// wasDeactivated returns whether the Admitted condition of the workload
+// still records that it's deactivated, which means that the active workload
+// was just activated again.
+func wasDeactivated(wl *kueue.Workload) bool {
+	i := workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted)
+	if i == -1 {
+		return false
+	}
+	reason := wl.Status.Conditions[i].Reason
+	return reason == "Inactive" || reason == workload.EvictedByRequeuingLimitExceeded
+}
+
+// reactivationCooldown returns the cooldown after which the queue of the
+// workload reactivates it, or nil if the queue doesn't reactivate workloads.
+func (r *WorkloadReconciler) reactivationCooldown(ctx context.Context, wl *kueue.Workload) (*time.Duration, error) {
+	var q kueue.Queue
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: wl.Namespace, Name: wl.Spec.QueueName}, &q); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if q.Spec.ReactivationCooldownSeconds == nil {
+		return nil, nil
+	}
+	cooldown := time.Duration(*q.Spec.ReactivationCooldownSeconds) * time.Second
+	return &cooldown, nil
+}
+
+// reconcileReactivation activates the workload deactivated by the requeuing
+// limit once the cooldown expires. The end of the cooldown is recorded
+// as the requeue time, which keeps the workload pending until then even if
+// it's activated earlier.
+func (r *WorkloadReconciler) reconcileReactivation(ctx context.Context, wl *kueue.Workload, cooldown time.Duration) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if wl.Status.RequeueState == nil || wl.Status.RequeueState.RequeueAt == nil {
+		newWl := wl.DeepCopy()
+		requeueAt := metav1.NewTime(time.Now().Add(cooldown))
+		if newWl.Status.RequeueState == nil {
+			newWl.Status.RequeueState = &kueue.RequeueState{}
+		}
+		newWl.Status.RequeueState.RequeueAt = &requeueAt
+		return ctrl.Result{}, client.IgnoreNotFound(r.client.Status().Update(ctx, newWl))
+	}
+	requeueAt := wl.Status.RequeueState.RequeueAt.Time
+	if remaining := time.Until(requeueAt); remaining > 0 {
+		err := workload.UpdateStatusIfChanged(ctx, r.client, wl, kueue.WorkloadAdmitted, corev1.ConditionFalse,
+			workload.EvictedByRequeuingLimitExceeded, fmt.Sprintf("The workload is deactivated until %s", requeueAt.UTC().Format(time.RFC3339)))
+		return ctrl.Result{RequeueAfter: remaining}, client.IgnoreNotFound(err)
+	}
+	newWl := wl.DeepCopy()
+	newWl.Spec.Active = pointer.Bool(true)
+	err := r.client.Update(ctx, newWl)
+	if err == nil {
+		log.V(2).Info("Reactivating workload after the cooldown", "cooldown", cooldown)
+	}
+	return ctrl.Result{}, client.IgnoreNotFound(err)
+}
+
+// delay returns the delay before the workload is requeued after the given
+// number of evictions.
+func (b *RequeuingBackoff) delay(count int32) time.Duration {
+	delay := b.BaseDelay
+	for i := int32(1); i < count && delay < b.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay
+}
+
+// evict clears the admission of a workload marked for eviction. Without an
+// admission, the job of the workload is stopped and the workload is requeued.
+// The update of the admission triggers another reconcile, in which the
+// eviction is recorded as finished.
+func (r *WorkloadReconciler) evict(ctx context.Context, wl *kueue.Workload) error {
+	log := ctrl.LoggerFrom(ctx)
+	if wl.Spec.Admission != nil {
+		newWl := wl.DeepCopy()
+		newWl.Spec.Admission = nil
+		evicted := wl.Status.Conditions[workload.FindConditionIndex(&wl.Status, kueue.WorkloadEvicted)]
+		switch evicted.Reason {
+		case workload.EvictedByDeadlineExceeded, workload.EvictedByAdmissionCheckRejected, workload.EvictedByRequeuingLimitExceeded:
+			// The workload exhausted its execution time or its requeuing
+			// limit, or can't pass an AdmissionCheck, so it's not requeued.
+			newWl.Spec.Active = pointer.Bool(false)
+		}
+		if err := r.client.Update(ctx, newWl); err != nil {
+			return err
+		}
+		log.V(2).Info("Cleared the admission of the evicted workload", "clusterQueue", wl.Spec.Admission.ClusterQueue)
+		workload.RecordEvent(r.recorder, wl, corev1.EventTypeNormal, "Evicted",
+			fmt.Sprintf("Evicted from ClusterQueue %s: %s", wl.Spec.Admission.ClusterQueue, evicted.Message))
+		return nil
+	}
+	if err := workload.FinishEviction(ctx, r.client, wl); err != nil {
+		return err
+	}
+	log.V(2).Info("Workload evicted")
+	return nil
+}
+
 func (r *WorkloadReconciler) Create(e event.CreateEvent) bool {
 	wl := e.Object.(*kueue.Workload)
 	defer r.notifyWatchers(wl)
@@ -111,6 +755,7 @@ func (r *WorkloadReconciler) Create(e event.CreateEvent) bool {
 	}
 
 	if wl.Spec.Admission == nil {
+		r.cache.AddOrUpdatePendingWorkload(wl.DeepCopy())
 		if !r.queues.AddOrUpdateWorkload(wl.DeepCopy()) {
 			log.V(2).Info("Queue for workload didn't exist; ignored for now")
 		}
@@ -132,6 +777,9 @@ func (r *WorkloadReconciler) Delete(e event.DeleteEvent) bool {
 	}
 	log := r.log.WithValues("workload", klog.KObj(wl), "queue", wl.Spec.QueueName, "status", status)
 	log.V(2).Info("Workload delete event")
+	r.ownerChecksMu.Lock()
+	delete(r.ownerChecks, wl.UID)
+	r.ownerChecksMu.Unlock()
 	// When assigning a clusterQueue to a workload, we assume it in the cache. If
 	// the state is unknown, the workload could have been assumed and we need
 	// to clear it from the cache.
@@ -149,6 +797,7 @@ func (r *WorkloadReconciler) Delete(e event.DeleteEvent) bool {
 	// Even if the state is unknown, the last cached state tells us whether the
 	// workload was in the queues and should be cleared from them.
 	if wl.Spec.Admission == nil {
+		r.cache.DeletePendingWorkload(wl)
 		r.queues.DeleteWorkload(wl)
 	}
 	return true
@@ -180,32 +829,43 @@ func (r *WorkloadReconciler) Update(e event.UpdateEvent) bool {
 
 	switch {
 	case status == finished:
-		if err := r.cache.DeleteWorkload(oldWl); err != nil && prevStatus == admitted {
+		if err := r.cache.DeleteWorkload(oldWl); err != nil && hasQuotaReservation(prevStatus) {
 			log.Error(err, "Failed to delete workload from cache")
 		}
+		r.cache.DeletePendingWorkload(oldWl)
 		r.queues.DeleteWorkload(oldWl)
 
 		// trigger the move of associated inadmissibleWorkloads if required.
 		r.queues.QueueAssociatedInadmissibleWorkloads(wl)
 
 	case prevStatus == pending && status == pending:
+		r.cache.AddOrUpdatePendingWorkload(wl.DeepCopy())
 		if !r.queues.UpdateWorkload(oldWl, wl.DeepCopy()) {
 			log.V(2).Info("Queue for updated workload didn't exist; ignoring for now")
 		}
+		if workload.InCondition(oldWl, kueue.WorkloadEvicted) && !workload.InCondition(wl, kueue.WorkloadEvicted) ||
+			requeueAt(oldWl) != nil && requeueAt(wl) == nil {
+			// The workload can be admitted again now that the eviction
+			// finished or the requeue backoff expired.
+			r.queues.QueueAssociatedInadmissibleWorkloads(wl)
+		}
 
-	case prevStatus == pending && status == admitted:
+	case prevStatus == pending && hasQuotaReservation(status):
+		r.cache.DeletePendingWorkload(oldWl)
 		r.queues.DeleteWorkload(oldWl)
 		if !r.cache.AddOrUpdateWorkload(wl.DeepCopy()) {
 			log.V(2).Info("ClusterQueue for workload didn't exist; ignored for now")
 		}
 
-	case prevStatus == admitted && status == pending:
+	case hasQuotaReservation(prevStatus) && status == pending:
 		if err := r.cache.DeleteWorkload(oldWl); err != nil {
 			log.Error(err, "Failed to delete workload from cache")
 		}
 		// trigger the move of associated inadmissibleWorkloads if required.
-		r.queues.QueueAssociatedInadmissibleWorkloads(wl)
+		// The old workload holds the admission whose quota is freed.
+		r.queues.QueueAssociatedInadmissibleWorkloads(oldWl)
 
+		r.cache.AddOrUpdatePendingWorkload(wl.DeepCopy())
 		if !r.queues.AddOrUpdateWorkload(wl.DeepCopy()) {
 			log.V(2).Info("Queue for workload didn't exist; ignored for now")
 		}
@@ -216,6 +876,11 @@ func (r *WorkloadReconciler) Update(e event.UpdateEvent) bool {
 		if err := r.cache.UpdateWorkload(oldWl, wl.DeepCopy()); err != nil {
 			log.Error(err, "Updating workload in cache")
 		}
+		if status == admitted && !equality.Semantic.DeepEqual(oldWl.Status.ReclaimablePods, wl.Status.ReclaimablePods) {
+			// The quota released by the finished pods might fit other
+			// workloads.
+			r.queues.QueueAssociatedInadmissibleWorkloads(wl)
+		}
 	}
 
 	return true
@@ -240,12 +905,31 @@ func (r *WorkloadReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
+func requeueAt(w *kueue.Workload) *metav1.Time {
+	if w.Status.RequeueState == nil {
+		return nil
+	}
+	return w.Status.RequeueState.RequeueAt
+}
+
+// workloadStatus returns the state of the workload: pending, while it waits
+// for a quota reservation; quotaReserved, while its AdmissionChecks complete;
+// admitted, once all of them are Ready; and finished.
 func workloadStatus(w *kueue.Workload) string {
 	if workload.InCondition(w, kueue.WorkloadFinished) {
 		return finished
 	}
-	if w.Spec.Admission != nil {
+	if workload.IsAdmitted(w) {
 		return admitted
 	}
+	if workload.HasQuotaReservation(w) {
+		return quotaReserved
+	}
 	return pending
 }
+
+// hasQuotaReservation returns whether a workload in the given state holds
+// quota in its ClusterQueue.
+func hasQuotaReservation(status string) bool {
+	return status == quotaReserved || status == admitted
+}
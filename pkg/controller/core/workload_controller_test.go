@@ -0,0 +1,639 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/queue"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+func newTestReconciler(t *testing.T, objs []client.Object, opts ...Option) (*WorkloadReconciler, client.Client) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	r := NewWorkloadReconciler(cl, queue.NewManager(cl), cache.New(cl), record.NewFakeRecorder(10), opts...)
+	return r, cl
+}
+
+func reconcileWorkload(t *testing.T, r *WorkloadReconciler, wl *kueue.Workload) ctrl.Result {
+	t.Helper()
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(wl)})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	return result
+}
+
+func TestAdmissionChecksCondition(t *testing.T) {
+	cases := map[string]struct {
+		states      []kueue.AdmissionCheckState
+		wantStatus  corev1.ConditionStatus
+		wantReason  string
+		wantMessage string
+	}{
+		"all ready": {
+			states: []kueue.AdmissionCheckState{
+				{Name: "budget", State: kueue.CheckStateReady},
+				{Name: "nodes", State: kueue.CheckStateReady},
+			},
+			wantStatus:  corev1.ConditionTrue,
+			wantReason:  "Ready",
+			wantMessage: "All the AdmissionChecks are Ready",
+		},
+		"pending": {
+			states: []kueue.AdmissionCheckState{
+				{Name: "budget", State: kueue.CheckStateReady},
+				{Name: "nodes", State: kueue.CheckStatePending, Message: "Provisioning"},
+			},
+			wantStatus:  corev1.ConditionFalse,
+			wantReason:  "Pending",
+			wantMessage: "AdmissionChecks not Ready: nodes is Pending: Provisioning",
+		},
+		"missing state is pending": {
+			states: []kueue.AdmissionCheckState{
+				{Name: "budget", State: kueue.CheckStateReady},
+			},
+			wantStatus:  corev1.ConditionFalse,
+			wantReason:  "Pending",
+			wantMessage: "AdmissionChecks not Ready: nodes is Pending",
+		},
+		"rejected is the most severe": {
+			states: []kueue.AdmissionCheckState{
+				{Name: "budget", State: kueue.CheckStateRejected, Message: "Over budget"},
+				{Name: "nodes", State: kueue.CheckStateRetry, Message: "Capacity not found"},
+			},
+			wantStatus:  corev1.ConditionFalse,
+			wantReason:  "Rejected",
+			wantMessage: "AdmissionChecks not Ready: budget is Rejected: Over budget; nodes is Retry: Capacity not found",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			status, reason, message := admissionChecksCondition([]string{"budget", "nodes"}, tc.states)
+			if status != tc.wantStatus || reason != tc.wantReason || message != tc.wantMessage {
+				t.Errorf("admissionChecksCondition() = (%s, %q, %q), want (%s, %q, %q)",
+					status, reason, message, tc.wantStatus, tc.wantReason, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func TestReconcileRejectedAdmissionCheck(t *testing.T) {
+	wl := utiltesting.MakeWorkload("wl", "ns").
+		Request(corev1.ResourceCPU, "1").
+		Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").AdmissionChecks("budget").Obj()).
+		Condition(kueue.WorkloadQuotaReserved, corev1.ConditionTrue).
+		Obj()
+	wl.Status.AdmissionChecks = []kueue.AdmissionCheckState{
+		{Name: "budget", State: kueue.CheckStateRejected, Message: "Over budget"},
+	}
+	r, cl := newTestReconciler(t, []client.Object{wl})
+
+	// The summary of the checks is recorded first.
+	reconcileWorkload(t, r, wl)
+	var got kueue.Workload
+	if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &got); err != nil {
+		t.Fatalf("Failed getting the workload: %v", err)
+	}
+	if !workload.ConditionMatches(&got, kueue.WorkloadAdmissionChecksReady, corev1.ConditionFalse,
+		"Rejected", "AdmissionChecks not Ready: budget is Rejected: Over budget") {
+		t.Errorf("Unexpected conditions: %v", got.Status.Conditions)
+	}
+
+	// Then the workload is evicted.
+	reconcileWorkload(t, r, wl)
+	if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &got); err != nil {
+		t.Fatalf("Failed getting the workload: %v", err)
+	}
+	if !workload.ConditionMatches(&got, kueue.WorkloadEvicted, corev1.ConditionTrue,
+		workload.EvictedByAdmissionCheckRejected, "AdmissionCheck budget rejected the workload: Over budget") {
+		t.Errorf("Unexpected conditions: %v", got.Status.Conditions)
+	}
+
+	// Clearing the admission deactivates the workload.
+	reconcileWorkload(t, r, wl)
+	if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &got); err != nil {
+		t.Fatalf("Failed getting the workload: %v", err)
+	}
+	if workload.IsActive(&got) {
+		t.Error("The workload wasn't deactivated")
+	}
+	if got.Spec.Admission != nil {
+		t.Errorf("The admission wasn't cleared: %v", got.Spec.Admission)
+	}
+}
+
+func TestReconcileReactivation(t *testing.T) {
+	cases := map[string]struct {
+		cooldownSeconds *int32
+		wantReactivated bool
+	}{
+		"no cooldown": {},
+		"cooldown": {
+			cooldownSeconds: pointer.Int32(60),
+			wantReactivated: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			q := utiltesting.MakeQueue("q", "ns").ClusterQueue("cq").Obj()
+			q.Spec.ReactivationCooldownSeconds = tc.cooldownSeconds
+			wl := utiltesting.MakeWorkload("wl", "ns").Queue("q").Active(false).Obj()
+			wl.Status.RequeueState = &kueue.RequeueState{Count: 3}
+			wl.Status.Conditions = []kueue.WorkloadCondition{
+				{
+					Type:   kueue.WorkloadAdmitted,
+					Status: corev1.ConditionFalse,
+					Reason: "Evicted",
+				},
+				{
+					Type:   kueue.WorkloadEvicted,
+					Status: corev1.ConditionFalse,
+					Reason: workload.EvictedByRequeuingLimitExceeded,
+				},
+			}
+			r, cl := newTestReconciler(t, []client.Object{q, wl})
+			ctx := context.Background()
+
+			reconcileWorkload(t, r, wl)
+			var got kueue.Workload
+			if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &got); err != nil {
+				t.Fatalf("Failed getting the workload: %v", err)
+			}
+			if !tc.wantReactivated {
+				if got.Status.RequeueState == nil || got.Status.RequeueState.Count != 3 {
+					t.Errorf("The requeue state wasn't kept: %v", got.Status.RequeueState)
+				}
+				if !workload.ConditionMatches(&got, kueue.WorkloadAdmitted, corev1.ConditionFalse,
+					workload.EvictedByRequeuingLimitExceeded, "The workload is deactivated for exceeding the requeuing limit") {
+					t.Errorf("Unexpected conditions: %v", got.Status.Conditions)
+				}
+				return
+			}
+			if got.Status.RequeueState == nil || got.Status.RequeueState.RequeueAt == nil {
+				t.Fatalf("The end of the cooldown wasn't recorded: %v", got.Status.RequeueState)
+			}
+			if remaining := time.Until(got.Status.RequeueState.RequeueAt.Time); remaining <= 0 || remaining > time.Minute {
+				t.Errorf("The cooldown ends in %v, want up to 1m", remaining)
+			}
+
+			// The workload stays inactive during the cooldown.
+			if result := reconcileWorkload(t, r, wl); result.RequeueAfter <= 0 {
+				t.Errorf("Reconcile requeued after %v during the cooldown, want a positive delay", result.RequeueAfter)
+			}
+			if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &got); err != nil {
+				t.Fatalf("Failed getting the workload: %v", err)
+			}
+			if workload.IsActive(&got) {
+				t.Error("The workload was activated during the cooldown")
+			}
+
+			// Once the cooldown expires, the workload is activated.
+			got.Status.RequeueState.RequeueAt = &metav1.Time{Time: time.Now().Add(-time.Second)}
+			if err := cl.Status().Update(ctx, &got); err != nil {
+				t.Fatalf("Failed updating the workload: %v", err)
+			}
+			reconcileWorkload(t, r, wl)
+			if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &got); err != nil {
+				t.Fatalf("Failed getting the workload: %v", err)
+			}
+			if !workload.IsActive(&got) {
+				t.Error("The workload wasn't activated after the cooldown")
+			}
+		})
+	}
+}
+
+func TestReconcileActivation(t *testing.T) {
+	cooldownEnd := metav1.NewTime(time.Now().Add(time.Minute).Truncate(time.Second))
+	cases := map[string]struct {
+		reason           string
+		requeueAt        *metav1.Time
+		wantRequeueState *kueue.RequeueState
+	}{
+		"deactivated": {
+			reason: "Inactive",
+		},
+		"deactivated by the requeuing limit": {
+			reason: workload.EvictedByRequeuingLimitExceeded,
+		},
+		"during the reactivation cooldown": {
+			reason:           workload.EvictedByRequeuingLimitExceeded,
+			requeueAt:        &cooldownEnd,
+			wantRequeueState: &kueue.RequeueState{RequeueAt: &cooldownEnd},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			q := utiltesting.MakeQueue("q", "ns").ClusterQueue("cq").Obj()
+			wl := utiltesting.MakeWorkload("wl", "ns").Queue("q").Obj()
+			wl.Status.RequeueState = &kueue.RequeueState{Count: 3, RequeueAt: tc.requeueAt}
+			wl.Status.AccumulatedPastExecutionTimeSeconds = pointer.Int32(60)
+			wl.Status.Conditions = []kueue.WorkloadCondition{{
+				Type:   kueue.WorkloadAdmitted,
+				Status: corev1.ConditionFalse,
+				Reason: tc.reason,
+			}}
+			r, cl := newTestReconciler(t, []client.Object{q, wl})
+
+			reconcileWorkload(t, r, wl)
+			var got kueue.Workload
+			if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &got); err != nil {
+				t.Fatalf("Failed getting the workload: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantRequeueState, got.Status.RequeueState); diff != "" {
+				t.Errorf("Unexpected requeue state (-want,+got):\n%s", diff)
+			}
+			if got.Status.AccumulatedPastExecutionTimeSeconds != nil {
+				t.Errorf("The execution time wasn't cleared, got %d seconds", *got.Status.AccumulatedPastExecutionTimeSeconds)
+			}
+			if !workload.ConditionMatches(&got, kueue.WorkloadAdmitted, corev1.ConditionFalse, "Pending", "The workload was activated") {
+				t.Errorf("Unexpected conditions: %v", got.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestReconcileAfterReactivation(t *testing.T) {
+	limit := int32(1)
+	timeout := time.Minute
+	backoff := RequeuingBackoff{LimitCount: &limit, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+	cases := map[string]struct {
+		deactivate     bool
+		wantActive     bool
+		wantEvictedBy  string
+		wantEvictCount int32
+	}{
+		"deactivated manually": {
+			deactivate: true,
+		},
+		"PodsReady timeout": {
+			wantActive:     true,
+			wantEvictedBy:  workload.EvictedByPodsReadyTimeout,
+			wantEvictCount: 1,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			q := utiltesting.MakeQueue("q", "ns").ClusterQueue("cq").Obj()
+			q.Spec.ReactivationCooldownSeconds = pointer.Int32(60)
+			cq := utiltesting.MakeClusterQueue("cq").Obj()
+			wl := utiltesting.MakeWorkload("wl", "ns").Queue("q").Request(corev1.ResourceCPU, "1").Active(false).Obj()
+			wl.Status.RequeueState = &kueue.RequeueState{
+				Count:     limit,
+				RequeueAt: &metav1.Time{Time: time.Now().Add(-time.Second)},
+			}
+			wl.Status.Conditions = []kueue.WorkloadCondition{{
+				Type:   kueue.WorkloadAdmitted,
+				Status: corev1.ConditionFalse,
+				Reason: workload.EvictedByRequeuingLimitExceeded,
+			}}
+			r, cl := newTestReconciler(t, []client.Object{q, cq, wl}, WithPodsReadyTimeout(&timeout), WithRequeuingBackoff(backoff))
+			ctx := context.Background()
+			var got kueue.Workload
+			get := func() {
+				t.Helper()
+				if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &got); err != nil {
+					t.Fatalf("Failed getting the workload: %v", err)
+				}
+			}
+
+			// The cooldown is over, so the workload is reactivated and its
+			// backoff starts over.
+			reconcileWorkload(t, r, wl)
+			reconcileWorkload(t, r, wl)
+			get()
+			if !workload.IsActive(&got) || got.Status.RequeueState != nil {
+				t.Fatalf("The workload wasn't reactivated, active=%t, requeueState=%v", workload.IsActive(&got), got.Status.RequeueState)
+			}
+
+			if tc.deactivate {
+				got.Spec.Active = pointer.Bool(false)
+				if err := cl.Update(ctx, &got); err != nil {
+					t.Fatalf("Failed deactivating the workload: %v", err)
+				}
+			} else {
+				got.Spec.Admission = utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()
+				if err := cl.Update(ctx, &got); err != nil {
+					t.Fatalf("Failed admitting the workload: %v", err)
+				}
+				past := metav1.NewTime(time.Now().Add(-2 * timeout))
+				got.Status.Conditions = []kueue.WorkloadCondition{
+					{Type: kueue.WorkloadQuotaReserved, Status: corev1.ConditionTrue, LastTransitionTime: past},
+					{Type: kueue.WorkloadAdmitted, Status: corev1.ConditionTrue, LastTransitionTime: past},
+				}
+				if err := cl.Status().Update(ctx, &got); err != nil {
+					t.Fatalf("Failed admitting the workload: %v", err)
+				}
+			}
+			reconcileWorkload(t, r, wl)
+			reconcileWorkload(t, r, wl)
+			get()
+			if active := workload.IsActive(&got); active != tc.wantActive {
+				t.Errorf("Active = %t, want %t", active, tc.wantActive)
+			}
+			if rs := got.Status.RequeueState; tc.wantEvictedBy == "" && rs != nil && rs.RequeueAt != nil {
+				t.Errorf("The manually deactivated workload is reactivated at %v", rs.RequeueAt)
+			}
+			if tc.wantEvictedBy != "" {
+				i := workload.FindConditionIndex(&got.Status, kueue.WorkloadEvicted)
+				if i == -1 || got.Status.Conditions[i].Reason != tc.wantEvictedBy {
+					t.Errorf("Unexpected conditions: %v", got.Status.Conditions)
+				}
+				if rs := got.Status.RequeueState; rs == nil || rs.Count != tc.wantEvictCount || rs.RequeueAt == nil {
+					t.Errorf("Unexpected requeue state %v, want count %d and a requeue time", rs, tc.wantEvictCount)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileOwnerCheck(t *testing.T) {
+	owner := utiltesting.MakeQueue("owner", "ns").Obj()
+	owner.UID = "owner-uid"
+	wl := utiltesting.MakeWorkload("wl", "ns").
+		Request(corev1.ResourceCPU, "1").
+		Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()).
+		Condition(kueue.WorkloadQuotaReserved, corev1.ConditionTrue).
+		Condition(kueue.WorkloadAdmitted, corev1.ConditionTrue).
+		Obj()
+	wl.UID = "wl-uid"
+	wl.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: kueue.GroupVersion.String(),
+		Kind:       "Queue",
+		Name:       owner.Name,
+		UID:        owner.UID,
+		Controller: pointer.Bool(true),
+	}}
+	r, cl := newTestReconciler(t, []client.Object{owner, wl})
+	ctx := context.Background()
+
+	reconcileWorkload(t, r, wl)
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &kueue.Workload{}); err != nil {
+		t.Fatalf("The workload with an owner wasn't kept: %v", err)
+	}
+
+	// The owner isn't checked again until the interval passes.
+	if err := cl.Delete(ctx, owner); err != nil {
+		t.Fatalf("Failed deleting the owner: %v", err)
+	}
+	reconcileWorkload(t, r, wl)
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &kueue.Workload{}); err != nil {
+		t.Fatalf("The owner was checked before the interval passed: %v", err)
+	}
+
+	r.ownerChecks[wl.UID] = time.Now().Add(-ownerCheckInterval)
+	reconcileWorkload(t, r, wl)
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &kueue.Workload{}); !apierrors.IsNotFound(err) {
+		t.Errorf("The workload without an owner wasn't deleted, got error %v", err)
+	}
+}
+
+// forbiddenOwnerClient fails deleting any object that isn't a workload, like
+// when kueue isn't allowed to delete the owners of a kind.
+type forbiddenOwnerClient struct {
+	client.Client
+}
+
+func (c *forbiddenOwnerClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if _, ok := obj.(*kueue.Workload); !ok {
+		return apierrors.NewForbidden(schema.GroupResource{Resource: "queues"}, obj.GetName(), errors.New("not allowed"))
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestReconcileFinishedOwnerForbidden(t *testing.T) {
+	wl := utiltesting.MakeWorkload("wl", "ns").
+		Condition(kueue.WorkloadFinished, corev1.ConditionTrue).
+		Obj()
+	wl.UID = "wl-uid"
+	wl.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: kueue.GroupVersion.String(),
+		Kind:       "Queue",
+		Name:       "owner",
+		UID:        "owner-uid",
+		Controller: pointer.Bool(true),
+	}}
+	_, cl := newTestReconciler(t, []client.Object{wl})
+	r := NewWorkloadReconciler(&forbiddenOwnerClient{Client: cl}, queue.NewManager(cl), cache.New(cl), record.NewFakeRecorder(10),
+		WithFinishedRetention(FinishedRetention{TTL: pointer.Duration(0), DeleteOwners: true}))
+
+	reconcileWorkload(t, r, wl)
+	if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &kueue.Workload{}); !apierrors.IsNotFound(err) {
+		t.Errorf("The expired workload wasn't deleted, got error %v", err)
+	}
+}
+
+func TestReconcileMaximumExecutionTime(t *testing.T) {
+	cases := map[string]struct {
+		pastSeconds   *int32
+		wantEvicted   bool
+		wantRemaining time.Duration
+	}{
+		"first admission": {
+			wantRemaining: 50 * time.Second,
+		},
+		"time of the previous admissions": {
+			pastSeconds:   pointer.Int32(30),
+			wantRemaining: 20 * time.Second,
+		},
+		"exceeded across admissions": {
+			pastSeconds: pointer.Int32(50),
+			wantEvicted: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			wl := utiltesting.MakeWorkload("wl", "ns").
+				Request(corev1.ResourceCPU, "1").
+				MaximumExecutionTimeSeconds(60).
+				Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()).
+				Condition(kueue.WorkloadQuotaReserved, corev1.ConditionTrue).
+				Condition(kueue.WorkloadAdmitted, corev1.ConditionTrue).
+				Obj()
+			admitted := &wl.Status.Conditions[workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted)]
+			admitted.LastTransitionTime = metav1.NewTime(time.Now().Add(-10 * time.Second))
+			wl.Status.AccumulatedPastExecutionTimeSeconds = tc.pastSeconds
+			r, cl := newTestReconciler(t, []client.Object{wl})
+
+			result := reconcileWorkload(t, r, wl)
+			var updatedWl kueue.Workload
+			if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &updatedWl); err != nil {
+				t.Fatalf("Failed getting the workload: %v", err)
+			}
+			i := workload.FindConditionIndex(&updatedWl.Status, kueue.WorkloadEvicted)
+			if evicted := i != -1 && updatedWl.Status.Conditions[i].Reason == workload.EvictedByDeadlineExceeded; evicted != tc.wantEvicted {
+				t.Errorf("Workload evicted by the deadline: %t, want %t", evicted, tc.wantEvicted)
+			}
+			// Allow for the time that the test takes.
+			if result.RequeueAfter > tc.wantRemaining || result.RequeueAfter < tc.wantRemaining-5*time.Second {
+				t.Errorf("Reconcile requeued after %v, want %v", result.RequeueAfter, tc.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestRequeuingBackoffDelay(t *testing.T) {
+	backoff := RequeuingBackoff{BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+	cases := map[int32]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		4:  time.Minute,
+		20: time.Minute,
+	}
+	for count, want := range cases {
+		if got := backoff.delay(count); got != want {
+			t.Errorf("delay(%d) = %v, want %v", count, got, want)
+		}
+	}
+}
+
+func TestReconcilePodsReadyTimeout(t *testing.T) {
+	limit := int32(2)
+	timeout := time.Minute
+	backoff := RequeuingBackoff{LimitCount: &limit, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+	cases := map[string]struct {
+		podsReady        bool
+		cqLimit          *int32
+		requeueState     *kueue.RequeueState
+		wantReason       string
+		wantMessage      string
+		wantRequeueState *kueue.RequeueState
+	}{
+		"first eviction": {
+			wantReason:       workload.EvictedByPodsReadyTimeout,
+			wantMessage:      "Exceeded the PodsReady timeout of 1m0s",
+			wantRequeueState: &kueue.RequeueState{Count: 1},
+		},
+		"consecutive eviction": {
+			requeueState:     &kueue.RequeueState{Count: 1},
+			wantReason:       workload.EvictedByPodsReadyTimeout,
+			wantMessage:      "Exceeded the PodsReady timeout of 1m0s",
+			wantRequeueState: &kueue.RequeueState{Count: 2},
+		},
+		"limit reached": {
+			requeueState:     &kueue.RequeueState{Count: 2},
+			wantReason:       workload.EvictedByRequeuingLimitExceeded,
+			wantMessage:      "Exceeded the PodsReady timeout of 1m0s after being requeued 2 times",
+			wantRequeueState: &kueue.RequeueState{Count: 2},
+		},
+		"limit of the ClusterQueue reached": {
+			cqLimit:          pointer.Int32(1),
+			requeueState:     &kueue.RequeueState{Count: 1},
+			wantReason:       workload.EvictedByRequeuingLimitExceeded,
+			wantMessage:      "Exceeded the PodsReady timeout of 1m0s after being requeued 1 times",
+			wantRequeueState: &kueue.RequeueState{Count: 1},
+		},
+		"limit of the ClusterQueue not reached": {
+			cqLimit:          pointer.Int32(5),
+			requeueState:     &kueue.RequeueState{Count: 2},
+			wantReason:       workload.EvictedByPodsReadyTimeout,
+			wantMessage:      "Exceeded the PodsReady timeout of 1m0s",
+			wantRequeueState: &kueue.RequeueState{Count: 3},
+		},
+		"pods ready": {
+			podsReady:    true,
+			requeueState: &kueue.RequeueState{Count: 1},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cq := utiltesting.MakeClusterQueue("cq").Obj()
+			cq.Spec.RequeuingLimitCount = tc.cqLimit
+			wlWrapper := utiltesting.MakeWorkload("wl", "ns").
+				Request(corev1.ResourceCPU, "1").
+				Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()).
+				Condition(kueue.WorkloadQuotaReserved, corev1.ConditionTrue).
+				Condition(kueue.WorkloadAdmitted, corev1.ConditionTrue)
+			if tc.podsReady {
+				wlWrapper.Condition(kueue.WorkloadPodsReady, corev1.ConditionTrue)
+			}
+			wl := wlWrapper.Obj()
+			wl.Status.RequeueState = tc.requeueState
+			r, cl := newTestReconciler(t, []client.Object{cq, wl}, WithPodsReadyTimeout(&timeout), WithRequeuingBackoff(backoff))
+
+			reconcileWorkload(t, r, wl)
+			var got kueue.Workload
+			if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &got); err != nil {
+				t.Fatalf("Failed getting the workload: %v", err)
+			}
+			evicted := workload.InCondition(&got, kueue.WorkloadEvicted)
+			if wantEvicted := tc.wantReason != ""; evicted != wantEvicted {
+				t.Errorf("Evicted = %t, want %t", evicted, wantEvicted)
+			}
+			if evicted && !workload.ConditionMatches(&got, kueue.WorkloadEvicted, corev1.ConditionTrue, tc.wantReason, tc.wantMessage) {
+				t.Errorf("Unexpected conditions: %v", got.Status.Conditions)
+			}
+			gotState := got.Status.RequeueState
+			if (gotState == nil) != (tc.wantRequeueState == nil) {
+				t.Fatalf("RequeueState = %v, want %v", gotState, tc.wantRequeueState)
+			}
+			if gotState == nil {
+				return
+			}
+			if gotState.Count != tc.wantRequeueState.Count {
+				t.Errorf("RequeueState count = %d, want %d", gotState.Count, tc.wantRequeueState.Count)
+			}
+			if tc.wantReason == workload.EvictedByRequeuingLimitExceeded {
+				if gotState.RequeueAt != nil {
+					t.Errorf("The workload is requeued at %v, want it not requeued", gotState.RequeueAt)
+				}
+				// The eviction deactivates the workload.
+				reconcileWorkload(t, r, wl)
+				if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &got); err != nil {
+					t.Fatalf("Failed getting the workload: %v", err)
+				}
+				if workload.IsActive(&got) {
+					t.Error("The workload wasn't deactivated")
+				}
+				return
+			}
+			want := backoff.delay(gotState.Count)
+			if gotState.RequeueAt == nil {
+				t.Fatal("The requeue time wasn't set")
+			}
+			if remaining := time.Until(gotState.RequeueAt.Time); remaining <= 0 || remaining > want {
+				t.Errorf("The workload is requeued in %v, want up to %v", remaining, want)
+			}
+		})
+	}
+}
@@ -18,94 +18,46 @@ package job
 
 import (
 	"context"
-	"fmt"
+	"strconv"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/constants"
-	utilpriority "sigs.k8s.io/kueue/pkg/util/priority"
-	"sigs.k8s.io/kueue/pkg/workload"
+	"sigs.k8s.io/kueue/pkg/controller/workload/jobframework"
 )
 
-var (
-	ownerKey = ".metadata.controller"
-)
+var gvk = batchv1.SchemeGroupVersion.WithKind("Job")
 
 // JobReconciler reconciles a Job object
 type JobReconciler struct {
-	client                     client.Client
-	scheme                     *runtime.Scheme
-	record                     record.EventRecorder
-	manageJobsWithoutQueueName bool
-}
-
-type options struct {
-	manageJobsWithoutQueueName bool
+	client     client.Client
+	reconciler *jobframework.JobReconciler
 }
 
-// Option configures the reconciler.
-type Option func(*options)
-
-// WithManageJobsWithoutQueueName indicates if the controller should reconcile
-// jobs that don't set the queue name annotation.
-func WithManageJobsWithoutQueueName(f bool) Option {
-	return func(o *options) {
-		o.manageJobsWithoutQueueName = f
-	}
-}
-
-var defaultOptions = options{}
-
 func NewReconciler(
 	scheme *runtime.Scheme,
 	client client.Client,
 	record record.EventRecorder,
-	opts ...Option) *JobReconciler {
-
-	options := defaultOptions
-	for _, opt := range opts {
-		opt(&options)
-	}
-
+	opts ...jobframework.Option) *JobReconciler {
 	return &JobReconciler{
-		scheme:                     scheme,
-		client:                     client,
-		record:                     record,
-		manageJobsWithoutQueueName: options.manageJobsWithoutQueueName,
+		client:     client,
+		reconciler: jobframework.NewReconciler(scheme, client, record, opts...),
 	}
 }
 
 // SetupWithManager sets up the controller with the Manager. It indexes workloads
 // based on the owning jobs.
 func (r *JobReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &kueue.Workload{}, ownerKey, func(rawObj client.Object) []string {
-		// grab the Workload object, extract the owner...
-		wl := rawObj.(*kueue.Workload)
-		owner := metav1.GetControllerOf(wl)
-		if owner == nil {
-			return nil
-		}
-		// ...make sure it's a Job...
-		if owner.APIVersion != "batch/v1" || owner.Kind != "Job" {
-			return nil
-		}
-
-		// ...and if so, return it
-		return []string{owner.Name}
-	}); err != nil {
+	if err := jobframework.SetupWorkloadOwnerIndex(context.Background(), mgr.GetFieldIndexer(), gvk); err != nil {
 		return err
 	}
 
@@ -115,14 +67,8 @@ func (r *JobReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-//+kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=list;get;watch
-//+kubebuilder:rbac:groups="",resources=events,verbs=create;watch;update
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=batch,resources=jobs/status,verbs=get
-//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/status,verbs=get;update;patch
-//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/finalizers,verbs=update
-//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=resourceflavors,verbs=get;list;watch
 
 func (r *JobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	var job batchv1.Job
@@ -130,368 +76,178 @@ func (r *JobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		// we'll ignore not-found errors, since there is nothing to do.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	return r.reconciler.ReconcileGenericJob(ctx, req, (*Job)(&job))
+}
 
-	log := ctrl.LoggerFrom(ctx).WithValues("job", klog.KObj(&job))
-	ctx = ctrl.LoggerInto(ctx, log)
-	if queueName(&job) == "" && !r.manageJobsWithoutQueueName {
-		log.V(3).Info(fmt.Sprintf("%s annotation is not set, ignoring the job", constants.QueueAnnotation))
-		return ctrl.Result{}, nil
-	}
-
-	log.V(2).Info("Reconciling Job")
-
-	var childWorkloads kueue.WorkloadList
-	if err := r.client.List(ctx, &childWorkloads, client.InNamespace(req.Namespace),
-		client.MatchingFields{ownerKey: req.Name}); err != nil {
-		log.Error(err, "Unable to list child workloads")
-		return ctrl.Result{}, err
-	}
-
-	// 1. make sure there is only a single existing instance of the workload
-	wl, err := r.ensureAtMostOneWorkload(ctx, &job, childWorkloads)
-	if err != nil {
-		log.Error(err, "Getting existing workloads")
-		return ctrl.Result{}, err
-	}
-
-	jobFinishedCond, jobFinished := jobFinishedCondition(&job)
-	// 2. create new workload if none exists
-	if wl == nil {
-		// Nothing to do if the job is finished
-		if jobFinished {
-			return ctrl.Result{}, nil
-		}
-		err := r.handleJobWithNoWorkload(ctx, &job)
-		if err != nil {
-			log.Error(err, "Handling job with no workload")
-		}
-		return ctrl.Result{}, err
-	}
-
-	// 3. handle a finished job
-	if jobFinished {
-		added := false
-		wl.Status.Conditions, added = appendFinishedConditionIfNotExists(wl.Status.Conditions, jobFinishedCond)
-		if !added {
-			return ctrl.Result{}, nil
-		}
-		err := r.client.Status().Update(ctx, wl)
-		if err != nil {
-			log.Error(err, "Updating workload status")
-		}
-		return ctrl.Result{}, err
-	}
-
-	// 4. Handle a not finished job
-	if jobSuspended(&job) {
-		// 4.1 start the job if the workload has been admitted, and the job is still suspended
-		if wl.Spec.Admission != nil {
-			log.V(2).Info("Job admitted, unsuspending")
-			err := r.startJob(ctx, wl, &job)
-			if err != nil {
-				log.Error(err, "Unsuspending job")
-			}
-			return ctrl.Result{}, err
-		}
+// Job implements jobframework.GenericJob for batch/v1 Jobs.
+type Job batchv1.Job
 
-		// 4.2 update queue name if changed.
-		q := queueName(&job)
-		if wl.Spec.QueueName != q {
-			log.V(2).Info("Job changed queues, updating workload")
-			wl.Spec.QueueName = q
-			err := r.client.Update(ctx, wl)
-			if err != nil {
-				log.Error(err, "Updating workload queue")
-			}
-			return ctrl.Result{}, err
-		}
-		log.V(3).Info("Job is suspended and workload not yet admitted by a clusterQueue, nothing to do")
-		return ctrl.Result{}, nil
-	}
+var _ jobframework.GenericJob = &Job{}
 
-	if wl.Spec.Admission == nil {
-		// 4.3 the job must be suspended if the workload is not yet admitted.
-		log.V(2).Info("Running job is not admitted by a cluster queue, suspending")
-		err := r.stopJob(ctx, wl, &job, "Not admitted by cluster queue")
-		if err != nil {
-			log.Error(err, "Suspending job with non admitted workload")
-		}
-		return ctrl.Result{}, err
-	}
+func (j *Job) Object() client.Object {
+	return (*batchv1.Job)(j)
+}
 
-	// 4.4 workload is admitted and job is running, nothing to do.
-	log.V(3).Info("Job running with admitted workload, nothing to do")
-	return ctrl.Result{}, nil
+func (j *Job) IsSuspended() bool {
+	return j.Spec.Suspend != nil && *j.Spec.Suspend
+}
 
+func (j *Job) Suspend() {
+	j.Spec.Suspend = pointer.BoolPtr(true)
 }
 
-// stopJob sends updates to suspend the job, reset the startTime so we can update the scheduling directives
-// later when unsuspending and resets the nodeSelector to its previous state based on what is available in
-// the workload (which should include the original affinities that the job had).
-func (r *JobReconciler) stopJob(ctx context.Context, w *kueue.Workload,
-	job *batchv1.Job, eventMsg string) error {
-	job.Spec.Suspend = pointer.BoolPtr(true)
-	if err := r.client.Update(ctx, job); err != nil {
-		return err
+func (j *Job) RunWithPodSetsInfo(podSetsInfo []jobframework.PodSetInfo) {
+	podSetsInfo[0].InjectInto(&j.Spec.Template.Spec)
+	if podSetsInfo[0].Count != nil {
+		j.Spec.Parallelism = pointer.Int32(*podSetsInfo[0].Count)
 	}
-	r.record.Eventf(job, corev1.EventTypeNormal, "Stopped", eventMsg)
+	j.Spec.Suspend = pointer.BoolPtr(false)
+}
 
-	// Reset start time so we can update the scheduling directives later when unsuspending.
-	if job.Status.StartTime != nil {
-		job.Status.StartTime = nil
-		if err := r.client.Status().Update(ctx, job); err != nil {
-			return err
-		}
+func (j *Job) RestorePodSetsInfo(podSets []kueue.PodSet) bool {
+	changed := jobframework.RestorePodSpec(&j.Spec.Template.Spec, &podSets[0])
+	if *j.Spec.Parallelism != podSets[0].Count {
+		j.Spec.Parallelism = pointer.Int32(podSets[0].Count)
+		changed = true
 	}
+	return changed
+}
 
-	if w != nil && !equality.Semantic.DeepEqual(job.Spec.Template.Spec.NodeSelector,
-		w.Spec.PodSets[0].Spec.NodeSelector) {
-		job.Spec.Template.Spec.NodeSelector = map[string]string{}
-		for k, v := range w.Spec.PodSets[0].Spec.NodeSelector {
-			job.Spec.Template.Spec.NodeSelector[k] = v
-		}
-		return r.client.Update(ctx, job)
+// ResetStatus resets the startTime, which prevents updating the scheduling
+// directives of the pod template.
+func (j *Job) ResetStatus() bool {
+	if j.Status.StartTime == nil {
+		return false
 	}
-
-	return nil
+	j.Status.StartTime = nil
+	return true
 }
 
-func (r *JobReconciler) startJob(ctx context.Context, w *kueue.Workload, job *batchv1.Job) error {
-	log := ctrl.LoggerFrom(ctx)
-
-	if len(w.Spec.PodSets) != 1 {
-		return fmt.Errorf("one podset must exist, found %d", len(w.Spec.PodSets))
-	}
-	nodeSelector, err := r.getNodeSelectors(ctx, w)
-	if err != nil {
-		return err
-	}
-	if len(nodeSelector) != 0 {
-		if job.Spec.Template.Spec.NodeSelector == nil {
-			job.Spec.Template.Spec.NodeSelector = nodeSelector
-		} else {
-			for k, v := range nodeSelector {
-				job.Spec.Template.Spec.NodeSelector[k] = v
+// From https://github.com/kubernetes/kubernetes/blob/master/pkg/controller/job/utils.go
+func (j *Job) Finished() (string, bool) {
+	for _, c := range j.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			if c.Type == batchv1.JobFailed {
+				return "Job failed", true
 			}
+			return "Job finished successfully", true
 		}
-
-	} else {
-		log.V(3).Info("no nodeSelectors to inject")
-	}
-
-	job.Spec.Suspend = pointer.BoolPtr(false)
-	if err := r.client.Update(ctx, job); err != nil {
-		return err
 	}
-
-	r.record.Eventf(job, corev1.EventTypeNormal, "Started",
-		"Admitted by clusterQueue %v", w.Spec.Admission.ClusterQueue)
-	return nil
+	return "", false
 }
 
-func (r *JobReconciler) getNodeSelectors(ctx context.Context, w *kueue.Workload) (map[string]string, error) {
-	if len(w.Spec.Admission.PodSetFlavors[0].Flavors) == 0 {
-		return nil, nil
-	}
-
-	processedFlvs := sets.NewString()
-	nodeSelector := map[string]string{}
-	for _, flvName := range w.Spec.Admission.PodSetFlavors[0].Flavors {
-		if processedFlvs.Has(flvName) {
-			continue
-		}
-		// Lookup the ResourceFlavors to fetch the node affinity labels to apply on the job.
-		flv := kueue.ResourceFlavor{}
-		if err := r.client.Get(ctx, types.NamespacedName{Name: flvName}, &flv); err != nil {
-			return nil, err
-		}
-		for k, v := range flv.Labels {
-			nodeSelector[k] = v
-		}
-		processedFlvs.Insert(flvName)
+func (j *Job) PodSets() []kueue.PodSet {
+	return []kueue.PodSet{
+		{
+			Spec:     *j.Spec.Template.Spec.DeepCopy(),
+			Count:    *j.Spec.Parallelism,
+			MinCount: j.minParallelism(),
+		},
 	}
-	return nodeSelector, nil
 }
 
-func (r *JobReconciler) handleJobWithNoWorkload(ctx context.Context, job *batchv1.Job) error {
-	log := ctrl.LoggerFrom(ctx)
-
-	// Wait until there are no active pods.
-	if job.Status.Active != 0 {
-		log.V(2).Info("Job is suspended but still has active pods, waiting")
+// minParallelism returns the parallelism in the min-parallelism annotation,
+// if it's a number between 1 and the parallelism of the job.
+func (j *Job) minParallelism() *int32 {
+	v, ok := j.Annotations[constants.JobMinParallelismAnnotation]
+	if !ok || j.Spec.Parallelism == nil {
 		return nil
 	}
-
-	// Create the corresponding workload.
-	wl, err := ConstructWorkloadFor(ctx, r.client, job, r.scheme)
-	if err != nil {
-		return err
-	}
-	if err = r.client.Create(ctx, wl); err != nil {
-		return err
+	minParallelism, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || minParallelism < 1 || int32(minParallelism) > *j.Spec.Parallelism {
+		return nil
 	}
-
-	r.record.Eventf(job, corev1.EventTypeNormal, "CreatedWorkload",
-		"Created Workload: %v", workload.Key(wl))
-	return nil
+	return pointer.Int32(int32(minParallelism))
 }
 
-// ensureAtmostoneworkload finds a matching workload and deletes redundant ones.
-func (r *JobReconciler) ensureAtMostOneWorkload(ctx context.Context, job *batchv1.Job, workloads kueue.WorkloadList) (*kueue.Workload, error) {
-	log := ctrl.LoggerFrom(ctx)
-
-	// Find a matching workload first if there is one.
-	var toDelete []*kueue.Workload
-	var match *kueue.Workload
-	for i := range workloads.Items {
-		w := &workloads.Items[i]
-		owner := metav1.GetControllerOf(w)
-		// Indexes don't work in unit tests, so we explicitly check for the
-		// owner here.
-		if owner.Name != job.Name {
-			continue
-		}
-		if match == nil && jobAndWorkloadEqual(job, w) {
-			match = w
-		} else {
-			toDelete = append(toDelete, w)
-		}
+func (j *Job) EquivalentToWorkload(wl *kueue.Workload) bool {
+	if len(wl.Spec.PodSets) != 1 {
+		return false
 	}
-
-	// If there is no matching workload and the job is running, suspend it.
-	if match == nil && !jobSuspended(job) {
-		log.V(2).Info("job with no matching workload, suspending")
-		var w *kueue.Workload
-		if len(workloads.Items) == 1 {
-			// The job may have been modified and hence the existing workload
-			// doesn't match the job anymore. All bets are off if there are more
-			// than one workload...
-			w = &workloads.Items[0]
-		}
-		if err := r.stopJob(ctx, w, job, "No matching Workload"); err != nil {
-			log.Error(err, "stopping job")
-		}
+	// A running job has a lower parallelism, down to the minCount, when its
+	// workload was partially admitted.
+	ps := &wl.Spec.PodSets[0]
+	// The pods of a job share one template, so they can't be split across
+	// flavors.
+	if ps.SplitAcrossFlavors {
+		return false
 	}
-
-	// Delete duplicate workload instances.
-	existedWls := 0
-	for i := range toDelete {
-		err := r.client.Delete(ctx, toDelete[i])
-		if err == nil || !apierrors.IsNotFound(err) {
-			existedWls++
-		}
-		if err != nil && !apierrors.IsNotFound(err) {
-			log.Error(err, "Failed to delete workload")
-		}
-		if err == nil {
-			r.record.Eventf(job, corev1.EventTypeNormal, "DeletedWorkload",
-				"Deleted not matching Workload: %v", workload.Key(toDelete[i]))
-		}
+	if p := *j.Spec.Parallelism; p != ps.Count && (j.IsSuspended() || ps.MinCount == nil || p < *ps.MinCount || p > ps.Count) {
+		return false
 	}
 
-	if existedWls != 0 {
-		if match == nil {
-			return nil, fmt.Errorf("no matching workload was found, tried deleting %d existing workload(s)", existedWls)
-		}
-		return nil, fmt.Errorf("only one workload should exist, found %d", len(workloads.Items))
+	// nodeSelector may change, hence we are not checking checking for
+	// equality of the whole job.Spec.Template.Spec.
+	if !equality.Semantic.DeepEqual(j.Spec.Template.Spec.InitContainers,
+		wl.Spec.PodSets[0].Spec.InitContainers) {
+		return false
 	}
-
-	return match, nil
+	return equality.Semantic.DeepEqual(j.Spec.Template.Spec.Containers,
+		wl.Spec.PodSets[0].Spec.Containers)
 }
 
-func ConstructWorkloadFor(ctx context.Context, client client.Client,
-	job *batchv1.Job, scheme *runtime.Scheme) (*kueue.Workload, error) {
-	w := &kueue.Workload{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      job.Name,
-			Namespace: job.Namespace,
-		},
-		Spec: kueue.WorkloadSpec{
-			PodSets: []kueue.PodSet{
-				{
-					Spec:  *job.Spec.Template.Spec.DeepCopy(),
-					Count: *job.Spec.Parallelism,
-				},
-			},
-			QueueName: queueName(job),
-		},
-	}
+func (j *Job) PriorityClass() string {
+	return j.Spec.Template.Spec.PriorityClassName
+}
 
-	// Populate priority from priority class.
-	priorityClassName, p, err := utilpriority.GetPriorityFromPriorityClass(
-		ctx, client, job.Spec.Template.Spec.PriorityClassName)
-	if err != nil {
-		return nil, err
-	}
-	w.Spec.Priority = &p
-	w.Spec.PriorityClassName = priorityClassName
+func (j *Job) IsActive() bool {
+	return j.Status.Active != 0
+}
 
-	if err := ctrl.SetControllerReference(job, w, scheme); err != nil {
-		return nil, err
+// PodsReady returns whether the ready and succeeded pods account for the
+// pods that the job runs at once.
+func (j *Job) PodsReady() bool {
+	ready := int32(0)
+	if j.Status.Ready != nil {
+		ready = *j.Status.Ready
 	}
-
-	return w, nil
+	return j.Status.Succeeded+ready >= j.podsCount()
 }
 
-func appendFinishedConditionIfNotExists(conds []kueue.WorkloadCondition, jobStatus batchv1.JobConditionType) ([]kueue.WorkloadCondition, bool) {
-	for i, c := range conds {
-		if c.Type == kueue.WorkloadFinished {
-			if c.Status == corev1.ConditionTrue {
-				return conds, false
-			}
-			conds = append(conds[:i], conds[i+1:]...)
-			break
-		}
+// ReclaimablePods returns the pods that are no longer needed once the pods
+// left to complete the job are fewer than its parallelism.
+func (j *Job) ReclaimablePods() []kueue.ReclaimablePod {
+	parallelism := int32(1)
+	if j.Spec.Parallelism != nil {
+		parallelism = *j.Spec.Parallelism
 	}
-	message := "Job finished successfully"
-	if jobStatus == batchv1.JobFailed {
-		message = "Job failed"
+	if parallelism == 1 || j.Status.Succeeded == 0 {
+		return nil
 	}
-	now := metav1.Now()
-	conds = append(conds, kueue.WorkloadCondition{
-		Type:               kueue.WorkloadFinished,
-		Status:             corev1.ConditionTrue,
-		LastProbeTime:      now,
-		LastTransitionTime: now,
-		Reason:             "JobFinished",
-		Message:            message,
-	})
-	return conds, true
-}
-
-// From https://github.com/kubernetes/kubernetes/blob/master/pkg/controller/job/utils.go
-func jobFinishedCondition(j *batchv1.Job) (batchv1.JobConditionType, bool) {
-	for _, c := range j.Status.Conditions {
-		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
-			return c.Type, true
-		}
+	completions := parallelism
+	if j.Spec.Completions != nil {
+		completions = *j.Spec.Completions
 	}
-	return "", false
-}
-
-func jobSuspended(j *batchv1.Job) bool {
-	return j.Spec.Suspend != nil && *j.Spec.Suspend
-
+	remaining := completions - j.Status.Succeeded
+	if remaining >= parallelism {
+		return nil
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return []kueue.ReclaimablePod{{
+		Name:  kueue.DefaultPodSetName,
+		Count: parallelism - remaining,
+	}}
 }
 
-func jobAndWorkloadEqual(job *batchv1.Job, wl *kueue.Workload) bool {
-	if len(wl.Spec.PodSets) != 1 {
-		return false
+func (j *Job) podsCount() int32 {
+	count := int32(1)
+	if j.Spec.Parallelism != nil {
+		count = *j.Spec.Parallelism
 	}
-	if *job.Spec.Parallelism != wl.Spec.PodSets[0].Count {
-		return false
+	if j.Spec.Completions != nil && *j.Spec.Completions < count {
+		count = *j.Spec.Completions
 	}
+	return count
+}
 
-	// nodeSelector may change, hence we are not checking checking for
-	// equality of the whole job.Spec.Template.Spec.
-	if !equality.Semantic.DeepEqual(job.Spec.Template.Spec.InitContainers,
-		wl.Spec.PodSets[0].Spec.InitContainers) {
-		return false
-	}
-	return equality.Semantic.DeepEqual(job.Spec.Template.Spec.Containers,
-		wl.Spec.PodSets[0].Spec.Containers)
+func (j *Job) GVK() schema.GroupVersionKind {
+	return gvk
 }
 
-func queueName(job *batchv1.Job) string {
-	return job.Annotations[constants.QueueAnnotation]
+// ConstructWorkloadFor returns the workload corresponding to the Job.
+func ConstructWorkloadFor(ctx context.Context, client client.Client,
+	job *batchv1.Job, scheme *runtime.Scheme) (*kueue.Workload, error) {
+	return jobframework.ConstructWorkloadFor(ctx, client, (*Job)(job), scheme)
 }
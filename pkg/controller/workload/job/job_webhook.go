@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/controller/workload/jobframework"
+)
+
+// +kubebuilder:webhook:path=/mutate-batch-v1-job,mutating=true,failurePolicy=ignore,sideEffects=None,groups=batch,resources=jobs,verbs=create,versions=v1,name=mjob.kb.io,admissionReviewVersions=v1
+
+const mutateJobPath = "/mutate-batch-v1-job"
+
+// +kubebuilder:webhook:path=/validate-batch-v1-job,mutating=false,failurePolicy=ignore,sideEffects=None,groups=batch,resources=jobs,verbs=create;update,versions=v1,name=vjob.kb.io,admissionReviewVersions=v1
+
+const validateJobPath = "/validate-batch-v1-job"
+
+// SetupWebhook registers a webhook that sets the default queue of the
+// namespace to the Jobs created without a queue name and suspends the Jobs
+// created with a queue name, so that they don't start before they are
+// admitted, and a
+// webhook that warns when a Job is created pointing to a Queue or a
+// WorkloadPriorityClass that doesn't exist, without resource requests or with
+// invalid placement hints or min parallelism. The Jobs rejected are the running ones whose queue name
+// changes and the ones whose users set the annotations that record the
+// admission of a running Job.
+func SetupWebhook(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(mutateJobPath, &webhook.Admission{
+		Handler: &jobDefaulter{client: mgr.GetClient()},
+	})
+	mgr.GetWebhookServer().Register(validateJobPath, &webhook.Admission{
+		Handler: &jobWebhook{client: mgr.GetClient()},
+	})
+	return nil
+}
+
+type jobDefaulter struct {
+	client  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &jobDefaulter{}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (d *jobDefaulter) InjectDecoder(dec *admission.Decoder) error {
+	d.decoder = dec
+	return nil
+}
+
+// Handle implements admission.Handler. It sets the default queue of the
+// namespace to the Jobs created without a queue name, and suspends the Jobs
+// created with a queue name. Otherwise, the Job controller could start their
+// pods before kueue creates and suspends the Workload.
+func (d *jobDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+	var job batchv1.Job
+	if err := d.decoder.Decode(req, &job); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	queueDefaulted := false
+	if jobframework.QueueName((*Job)(&job)) == "" && d.client != nil {
+		name, err := kueue.DefaultQueueName(ctx, d.client, req.Namespace)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if name != "" {
+			if job.Annotations == nil {
+				job.Annotations = make(map[string]string, 1)
+			}
+			job.Annotations[constants.QueueAnnotation] = name
+			queueDefaulted = true
+		}
+	}
+	if jobframework.QueueName((*Job)(&job)) == "" || (!queueDefaulted && pointer.BoolDeref(job.Spec.Suspend, false)) {
+		return admission.Allowed("")
+	}
+	job.Spec.Suspend = pointer.Bool(true)
+	marshalled, err := json.Marshal(&job)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshalled)
+}
+
+type jobWebhook struct {
+	client  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &jobWebhook{}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (w *jobWebhook) InjectDecoder(d *admission.Decoder) error {
+	w.decoder = d
+	return nil
+}
+
+// Handle implements admission.Handler.
+func (w *jobWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var job batchv1.Job
+	if err := w.decoder.Decode(req, &job); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	switch req.Operation {
+	case admissionv1.Create:
+		if err := validateAdmissionAnnotations(nil, &job); err != nil {
+			return admission.Denied(err.Error())
+		}
+		return w.handleCreate(ctx, req, &job)
+	case admissionv1.Update:
+		var oldJob batchv1.Job
+		if err := w.decoder.DecodeRaw(req.OldObject, &oldJob); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := validateAdmissionAnnotations(&oldJob, &job); err != nil {
+			return admission.Denied(err.Error())
+		}
+		return validateUpdate(&oldJob, &job)
+	}
+	return admission.Allowed("")
+}
+
+// validateAdmissionAnnotations forbids setting or changing the annotations
+// that record the admission of a running Job, since its workload is
+// recreated with that admission if it's deleted. They can only be set in the
+// update that starts the Job, like kueue does, and removed.
+func validateAdmissionAnnotations(oldJob, newJob *batchv1.Job) *field.Error {
+	starting := oldJob != nil && pointer.BoolDeref(oldJob.Spec.Suspend, false) && !pointer.BoolDeref(newJob.Spec.Suspend, false)
+	if starting {
+		return nil
+	}
+	for _, key := range []string{constants.AdmissionAnnotation, constants.PodSetsHashAnnotation} {
+		v, ok := newJob.Annotations[key]
+		if !ok {
+			continue
+		}
+		if oldJob != nil {
+			if oldV, oldOk := oldJob.Annotations[key]; oldOk && oldV == v {
+				continue
+			}
+		}
+		return field.Forbidden(field.NewPath("metadata", "annotations").Key(key), "is managed by kueue")
+	}
+	return nil
+}
+
+// validateUpdate forbids changing the queue name of a running Job, since
+// its workload is accounted in the ClusterQueue of the old queue. The Job
+// has to be suspended first, which evicts its workload.
+func validateUpdate(oldJob, newJob *batchv1.Job) admission.Response {
+	oldName := jobframework.QueueName((*Job)(oldJob))
+	if oldName == jobframework.QueueName((*Job)(newJob)) || pointer.BoolDeref(oldJob.Spec.Suspend, false) {
+		return admission.Allowed("")
+	}
+	path := field.NewPath("metadata", "annotations").Key(constants.QueueAnnotation)
+	return admission.Denied(field.Forbidden(path, "cannot be changed while the job is running").Error())
+}
+
+// handleCreate warns about the missing objects and invalid annotations of a
+// new Job.
+func (w *jobWebhook) handleCreate(ctx context.Context, req admission.Request, job *batchv1.Job) admission.Response {
+	name := jobframework.QueueName((*Job)(job))
+	if name == "" {
+		return admission.Allowed("")
+	}
+	warnings := kueue.MissingQueueWarnings(ctx, w.client, req.Namespace, name)
+	if _, err := jobframework.PlacementHintsFor((*Job)(job)); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	if v, ok := job.Annotations[constants.JobMinParallelismAnnotation]; ok && (*Job)(job).minParallelism() == nil {
+		warnings = append(warnings, fmt.Sprintf("annotation %s=%q is ignored, it must be a number between 1 and the parallelism", constants.JobMinParallelismAnnotation, v))
+	}
+	if !kueue.HasRequests(&job.Spec.Template.Spec) {
+		warnings = append(warnings, "the job doesn't specify the resource requests or limits of any container, so kueue can't create its workload and the job stays suspended")
+	}
+	if wpc := jobframework.WorkloadPriorityClassName((*Job)(job)); wpc != "" {
+		warnings = append(warnings, kueue.MissingWorkloadPriorityClassWarnings(ctx, w.client, wpc)...)
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
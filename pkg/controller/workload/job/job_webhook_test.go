@@ -0,0 +1,265 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+)
+
+func TestJobDefaulter(t *testing.T) {
+	suspendPatch := []jsonpatch.JsonPatchOperation{{Operation: "replace", Path: "/spec/suspend", Value: true}}
+	cases := map[string]struct {
+		job         *batchv1.Job
+		operation   admissionv1.Operation
+		wantPatches []jsonpatch.JsonPatchOperation
+	}{
+		"suspends a job with a queue name": {
+			job:         utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Obj(),
+			operation:   admissionv1.Create,
+			wantPatches: suspendPatch,
+		},
+		"suspended job": {
+			job:       utiltesting.MakeJob("job", "ns").Queue("queue").Obj(),
+			operation: admissionv1.Create,
+		},
+		"job without queue name": {
+			job:       utiltesting.MakeJob("job", "ns").Suspend(false).Obj(),
+			operation: admissionv1.Create,
+		},
+		"update": {
+			job:       utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Obj(),
+			operation: admissionv1.Update,
+		},
+		"queue name from the namespace default": {
+			job:       utiltesting.MakeJob("job", "team").Suspend(false).Obj(),
+			operation: admissionv1.Create,
+			wantPatches: []jsonpatch.JsonPatchOperation{
+				{Operation: "add", Path: "/metadata/annotations", Value: map[string]interface{}{constants.QueueAnnotation: "team-queue"}},
+				{Operation: "replace", Path: "/spec/suspend", Value: true},
+			},
+		},
+		"queue name takes precedence over the namespace default": {
+			job:         utiltesting.MakeJob("job", "team").Queue("queue").Suspend(false).Obj(),
+			operation:   admissionv1.Create,
+			wantPatches: suspendPatch,
+		},
+	}
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding core scheme: %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "team",
+			Annotations: map[string]string{kueue.DefaultQueueAnnotation: "team-queue"},
+		}},
+	).Build()
+	decoder := newDecoder(t)
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := &jobDefaulter{client: c}
+			if err := d.InjectDecoder(decoder); err != nil {
+				t.Fatalf("Failed injecting decoder: %v", err)
+			}
+			resp := d.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tc.operation,
+				Namespace: tc.job.Namespace,
+				Object:    rawJob(t, tc.job),
+			}})
+			if !resp.Allowed {
+				t.Errorf("Handle() denied the job (result: %v)", resp.Result)
+			}
+			sortPatches := cmpopts.SortSlices(func(a, b jsonpatch.JsonPatchOperation) bool { return a.Path < b.Path })
+			if diff := cmp.Diff(tc.wantPatches, resp.Patches, sortPatches); diff != "" {
+				t.Errorf("Unexpected patches (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJobWebhookUpdate(t *testing.T) {
+	cases := map[string]struct {
+		oldJob *batchv1.Job
+		newJob *batchv1.Job
+		want   bool
+	}{
+		"queue change while suspended": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("other").Obj(),
+			want:   true,
+		},
+		"queue change while running": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("other").Suspend(false).Obj(),
+		},
+		"queue change along with suspension": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("other").Obj(),
+		},
+		"queue set on a running job": {
+			oldJob: utiltesting.MakeJob("job", "ns").Suspend(false).Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Obj(),
+		},
+		"running job suspended": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("queue").Obj(),
+			want:   true,
+		},
+		"admission recorded when starting": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).
+				Annotation(constants.AdmissionAnnotation, `{"clusterQueue":"cq"}`).
+				Annotation(constants.PodSetsHashAnnotation, "hash").Obj(),
+			want: true,
+		},
+		"admission set on a running job": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).
+				Annotation(constants.AdmissionAnnotation, `{"clusterQueue":"cq"}`).Obj(),
+		},
+		"pod sets hash changed on a running job": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).
+				Annotation(constants.PodSetsHashAnnotation, "hash").Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).
+				Annotation(constants.PodSetsHashAnnotation, "other").Obj(),
+		},
+		"admission unchanged on a running job": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).
+				Annotation(constants.AdmissionAnnotation, `{"clusterQueue":"cq"}`).Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).Parallelism(3).
+				Annotation(constants.AdmissionAnnotation, `{"clusterQueue":"cq"}`).Obj(),
+			want: true,
+		},
+		"admission removed when suspending": {
+			oldJob: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).
+				Annotation(constants.AdmissionAnnotation, `{"clusterQueue":"cq"}`).
+				Annotation(constants.PodSetsHashAnnotation, "hash").Obj(),
+			newJob: utiltesting.MakeJob("job", "ns").Queue("queue").Obj(),
+			want:   true,
+		},
+	}
+	decoder := newDecoder(t)
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := &jobWebhook{}
+			if err := w.InjectDecoder(decoder); err != nil {
+				t.Fatalf("Failed injecting decoder: %v", err)
+			}
+			resp := w.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				Object:    rawJob(t, tc.newJob),
+				OldObject: rawJob(t, tc.oldJob),
+			}})
+			if resp.Allowed != tc.want {
+				t.Errorf("Handle() allowed = %t, want %t (result: %v)", resp.Allowed, tc.want, resp.Result)
+			}
+		})
+	}
+}
+
+func TestJobWebhookCreate(t *testing.T) {
+	noRequestsWarning := "the job doesn't specify the resource requests or limits of any container, so kueue can't create its workload and the job stays suspended"
+	cases := map[string]struct {
+		job          *batchv1.Job
+		want         bool
+		wantWarnings []string
+	}{
+		"job with a queue name": {
+			job:  utiltesting.MakeJob("job", "ns").Queue("queue").Request(corev1.ResourceCPU, "1").Obj(),
+			want: true,
+		},
+		"job without requests": {
+			job:          utiltesting.MakeJob("job", "ns").Queue("queue").Obj(),
+			want:         true,
+			wantWarnings: []string{noRequestsWarning},
+		},
+		"job without requests nor queue name": {
+			job:  utiltesting.MakeJob("job", "ns").Obj(),
+			want: true,
+		},
+		"job with an admission": {
+			job: utiltesting.MakeJob("job", "ns").Queue("queue").Suspend(false).
+				Annotation(constants.AdmissionAnnotation, `{"clusterQueue":"cq"}`).Obj(),
+		},
+		"job with a pod sets hash": {
+			job: utiltesting.MakeJob("job", "ns").Annotation(constants.PodSetsHashAnnotation, "hash").Obj(),
+		},
+	}
+	decoder := newDecoder(t)
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := &jobWebhook{client: fake.NewClientBuilder().Build()}
+			if err := w.InjectDecoder(decoder); err != nil {
+				t.Fatalf("Failed injecting decoder: %v", err)
+			}
+			resp := w.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Namespace: tc.job.Namespace,
+				Object:    rawJob(t, tc.job),
+			}})
+			if resp.Allowed != tc.want {
+				t.Errorf("Handle() allowed = %t, want %t (result: %v)", resp.Allowed, tc.want, resp.Result)
+			}
+			if diff := cmp.Diff(tc.wantWarnings, resp.Warnings); diff != "" {
+				t.Errorf("Unexpected warnings (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func newDecoder(t *testing.T) *admission.Decoder {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := batchv1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding batch scheme: %v", err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("Failed creating decoder: %v", err)
+	}
+	return decoder
+}
+
+func rawJob(t *testing.T, job *batchv1.Job) runtime.RawExtension {
+	t.Helper()
+	job = job.DeepCopy()
+	job.APIVersion = batchv1.SchemeGroupVersion.String()
+	job.Kind = "Job"
+	data, err := json.Marshal(job)
+	if err != nil {
+		t.Fatalf("Marshaling job: %v", err)
+	}
+	return runtime.RawExtension{Raw: data}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobframework
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// GenericJob is the interface that a job API implements to have its
+// workloads managed by the JobReconciler.
+type GenericJob interface {
+	// Object returns the job instance.
+	Object() client.Object
+	// IsSuspended returns whether the job is suspended.
+	IsSuspended() bool
+	// Suspend suspends the job.
+	Suspend()
+	// RunWithPodSetsInfo injects the scheduling directives of the admission
+	// into the pod templates, applies the admitted counts and unsuspends the
+	// job. The podSetsInfo are in
+	// the same order as the podSets returned by PodSets.
+	RunWithPodSetsInfo(podSetsInfo []PodSetInfo)
+	// RestorePodSetsInfo restores the scheduling directives of the pod
+	// templates and the counts to the ones of the podSets of the workload. It returns whether
+	// the job changed.
+	RestorePodSetsInfo(podSets []kueue.PodSet) bool
+	// ResetStatus resets the status fields that prevent the scheduling
+	// directives from being updated, like the startTime of a Job. It returns
+	// whether the status changed.
+	ResetStatus() bool
+	// Finished returns whether the job finished, with a message describing
+	// the outcome.
+	Finished() (message string, finished bool)
+	// PodSets returns the podSets of the workload corresponding to the job.
+	PodSets() []kueue.PodSet
+	// EquivalentToWorkload returns whether the podSets of the workload match
+	// the job. The node selectors and affinities are not compared, as they
+	// change when the job is started.
+	EquivalentToWorkload(wl *kueue.Workload) bool
+	// PriorityClass returns the name of the priority class of the pods.
+	PriorityClass() string
+	// IsActive returns whether the job has active pods.
+	IsActive() bool
+	// PodsReady returns whether all the pods of the job are ready or
+	// succeeded.
+	PodsReady() bool
+	// ReclaimablePods returns the number of pods of each podSet that
+	// finished and no longer need their quota, while the job keeps running.
+	ReclaimablePods() []kueue.ReclaimablePod
+	// GVK returns the GroupVersionKind of the job.
+	GVK() schema.GroupVersionKind
+}
+
+// PodSetInfo holds the scheduling directives for the pods of a podSet, as
+// decided by the admission of the workload.
+type PodSetInfo struct {
+	// NodeSelector holds the labels of the assigned flavors.
+	NodeSelector map[string]string
+	// RequiredNodeSelectorTerms holds groups of terms to add to the required
+	// node affinity: the placement hints of the workload and, if the podSet
+	// was split across flavors, a term per flavor. Each group is ANDed with
+	// the existing affinity.
+	RequiredNodeSelectorTerms [][]corev1.NodeSelectorTerm
+	// Count is the number of pods admitted for the podSet, when the workload
+	// was partially admitted.
+	Count *int32
+}
+
+// InjectInto adds the scheduling directives to the pod spec.
+func (i *PodSetInfo) InjectInto(spec *corev1.PodSpec) {
+	if len(i.NodeSelector) != 0 {
+		if spec.NodeSelector == nil {
+			spec.NodeSelector = make(map[string]string, len(i.NodeSelector))
+		}
+		for k, v := range i.NodeSelector {
+			spec.NodeSelector[k] = v
+		}
+	}
+	for _, terms := range i.RequiredNodeSelectorTerms {
+		workload.InjectRequiredNodeSelectorTerms(spec, terms)
+	}
+}
+
+// RestorePodSpec restores the node selector and the affinity of the pod spec
+// to the ones of the podSet. It returns whether the pod spec changed.
+func RestorePodSpec(spec *corev1.PodSpec, podSet *kueue.PodSet) bool {
+	changed := false
+	if !equality.Semantic.DeepEqual(spec.NodeSelector, podSet.Spec.NodeSelector) {
+		spec.NodeSelector = make(map[string]string, len(podSet.Spec.NodeSelector))
+		for k, v := range podSet.Spec.NodeSelector {
+			spec.NodeSelector[k] = v
+		}
+		changed = true
+	}
+	if !equality.Semantic.DeepEqual(spec.Affinity, podSet.Spec.Affinity) {
+		spec.Affinity = podSet.Spec.Affinity.DeepCopy()
+		changed = true
+	}
+	return changed
+}
+
+// QueueName returns the name of the queue in the annotation of the job.
+func QueueName(job GenericJob) string {
+	return job.Object().GetAnnotations()[constants.QueueAnnotation]
+}
+
+// WorkloadPriorityClassName returns the name of the WorkloadPriorityClass in
+// the label of the job.
+func WorkloadPriorityClassName(job GenericJob) string {
+	return job.Object().GetLabels()[constants.WorkloadPriorityClassLabel]
+}
+
+var placementOperators = map[selection.Operator]corev1.NodeSelectorOperator{
+	selection.In:           corev1.NodeSelectorOpIn,
+	selection.Equals:       corev1.NodeSelectorOpIn,
+	selection.DoubleEquals: corev1.NodeSelectorOpIn,
+	selection.NotIn:        corev1.NodeSelectorOpNotIn,
+	selection.NotEquals:    corev1.NodeSelectorOpNotIn,
+	selection.Exists:       corev1.NodeSelectorOpExists,
+	selection.DoesNotExist: corev1.NodeSelectorOpDoesNotExist,
+	selection.GreaterThan:  corev1.NodeSelectorOpGt,
+	selection.LessThan:     corev1.NodeSelectorOpLt,
+}
+
+// PlacementHintsFor returns the placement hints in the annotation of the job
+// as a single node selector term, or nil if the job doesn't have hints.
+func PlacementHintsFor(job GenericJob) ([]corev1.NodeSelectorTerm, error) {
+	value := job.Object().GetAnnotations()[constants.PlacementHintsAnnotation]
+	if value == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s annotation: %w", constants.PlacementHintsAnnotation, err)
+	}
+	reqs, _ := selector.Requirements()
+	term := corev1.NodeSelectorTerm{}
+	for _, r := range reqs {
+		term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+			Key:      r.Key(),
+			Operator: placementOperators[r.Operator()],
+			Values:   r.Values().List(),
+		})
+	}
+	return []corev1.NodeSelectorTerm{term}, nil
+}
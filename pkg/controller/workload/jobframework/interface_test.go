@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobframework
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+)
+
+func TestPodSetInfoInjectInto(t *testing.T) {
+	info := PodSetInfo{
+		NodeSelector: map[string]string{"instance": "spot"},
+		RequiredNodeSelectorTerms: [][]corev1.NodeSelectorTerm{
+			{
+				{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}},
+					},
+				},
+			},
+		},
+	}
+	spec := corev1.PodSpec{
+		NodeSelector: map[string]string{"arch": "amd64"},
+	}
+	info.InjectInto(&spec)
+	want := corev1.PodSpec{
+		NodeSelector: map[string]string{"arch": "amd64", "instance": "spot"},
+		Affinity: &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{
+							MatchExpressions: []corev1.NodeSelectorRequirement{
+								{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, spec); diff != "" {
+		t.Errorf("Unexpected pod spec (-want,+got):\n%s", diff)
+	}
+}
+
+func TestRestorePodSpec(t *testing.T) {
+	podSet := kueue.PodSet{
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{"arch": "amd64"},
+		},
+	}
+	cases := map[string]struct {
+		spec        corev1.PodSpec
+		wantChanged bool
+	}{
+		"unchanged": {
+			spec: corev1.PodSpec{
+				NodeSelector: map[string]string{"arch": "amd64"},
+			},
+		},
+		"injected directives": {
+			spec: corev1.PodSpec{
+				NodeSelector: map[string]string{"arch": "amd64", "instance": "spot"},
+				Affinity:     &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}},
+			},
+			wantChanged: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			changed := RestorePodSpec(&tc.spec, &podSet)
+			if changed != tc.wantChanged {
+				t.Errorf("RestorePodSpec returned %t, want %t", changed, tc.wantChanged)
+			}
+			if diff := cmp.Diff(podSet.Spec, tc.spec); diff != "" {
+				t.Errorf("Unexpected pod spec (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,625 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobframework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/constants"
+	utilpriority "sigs.k8s.io/kueue/pkg/util/priority"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// JobReconciler reconciles the workloads of the jobs that implement
+// GenericJob.
+type JobReconciler struct {
+	client                     client.Client
+	scheme                     *runtime.Scheme
+	record                     record.EventRecorder
+	manageJobsWithoutQueueName bool
+	waitForPodsReady           bool
+	cache                      cache.Interface
+}
+
+type options struct {
+	manageJobsWithoutQueueName bool
+	waitForPodsReady           bool
+	cache                      cache.Interface
+}
+
+// Option configures the reconciler.
+type Option func(*options)
+
+// WithManageJobsWithoutQueueName indicates if the controller should reconcile
+// jobs that don't set the queue name annotation.
+func WithManageJobsWithoutQueueName(f bool) Option {
+	return func(o *options) {
+		o.manageJobsWithoutQueueName = f
+	}
+}
+
+// WithWaitForPodsReady indicates if the controller should maintain the
+// PodsReady condition of the workloads.
+func WithWaitForPodsReady(f bool) Option {
+	return func(o *options) {
+		o.waitForPodsReady = f
+	}
+}
+
+// WithCache sets the cache that the admissions recorded in the jobs are
+// validated against before their workloads are recreated with them. Without
+// a cache, the workloads are recreated pending.
+func WithCache(c cache.Interface) Option {
+	return func(o *options) {
+		o.cache = c
+	}
+}
+
+var defaultOptions = options{}
+
+func NewReconciler(
+	scheme *runtime.Scheme,
+	client client.Client,
+	record record.EventRecorder,
+	opts ...Option) *JobReconciler {
+
+	options := defaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &JobReconciler{
+		scheme:                     scheme,
+		client:                     client,
+		record:                     record,
+		manageJobsWithoutQueueName: options.manageJobsWithoutQueueName,
+		waitForPodsReady:           options.waitForPodsReady,
+		cache:                      options.cache,
+	}
+}
+
+// OwnerReferenceIndexKey returns the name of the index of the workloads by
+// the name of their owning jobs of the given kind.
+func OwnerReferenceIndexKey(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf(".metadata.ownerReferences[%s.%s]", gvk.Group, gvk.Kind)
+}
+
+// SetupWorkloadOwnerIndex indexes the workloads by the name of their owning
+// jobs of the given kind.
+func SetupWorkloadOwnerIndex(ctx context.Context, indexer client.FieldIndexer, gvk schema.GroupVersionKind) error {
+	return indexer.IndexField(ctx, &kueue.Workload{}, OwnerReferenceIndexKey(gvk), func(rawObj client.Object) []string {
+		// grab the Workload object, extract the owner...
+		wl := rawObj.(*kueue.Workload)
+		owner := metav1.GetControllerOf(wl)
+		if owner == nil {
+			return nil
+		}
+		// ...make sure it's a job of the given kind...
+		if owner.APIVersion != gvk.GroupVersion().String() || owner.Kind != gvk.Kind {
+			return nil
+		}
+
+		// ...and if so, return it
+		return []string{owner.Name}
+	})
+}
+
+//+kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=list;get;watch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloadpriorityclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;watch;update
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads/finalizers,verbs=update
+//+kubebuilder:rbac:groups=kueue.x-k8s.io,resources=resourceflavors,verbs=get;list;watch
+
+// ReconcileGenericJob reconciles the workload of the job: it creates the
+// workload, starts the job when the workload is admitted, stops it when the
+// workload isn't admitted anymore and marks the workload as finished when the
+// job finishes.
+func (r *JobReconciler) ReconcileGenericJob(ctx context.Context, req ctrl.Request, job GenericJob) (ctrl.Result, error) {
+	object := job.Object()
+	log := ctrl.LoggerFrom(ctx).WithValues("job", klog.KObj(object), "gvk", job.GVK())
+	ctx = ctrl.LoggerInto(ctx, log)
+	if QueueName(job) == "" && !r.manageJobsWithoutQueueName {
+		log.V(3).Info(fmt.Sprintf("%s annotation is not set, ignoring the job", constants.QueueAnnotation))
+		return ctrl.Result{}, nil
+	}
+
+	log.V(2).Info("Reconciling Job")
+
+	var childWorkloads kueue.WorkloadList
+	if err := r.client.List(ctx, &childWorkloads, client.InNamespace(req.Namespace),
+		client.MatchingFields{OwnerReferenceIndexKey(job.GVK()): req.Name}); err != nil {
+		log.Error(err, "Unable to list child workloads")
+		return ctrl.Result{}, err
+	}
+
+	// 1. make sure there is only a single existing instance of the workload
+	wl, err := r.ensureAtMostOneWorkload(ctx, job, childWorkloads)
+	if err != nil {
+		log.Error(err, "Getting existing workloads")
+		return ctrl.Result{}, err
+	}
+
+	finishedMessage, jobFinished := job.Finished()
+	// 2. create new workload if none exists
+	if wl == nil {
+		// Nothing to do if the job is finished
+		if jobFinished {
+			return ctrl.Result{}, nil
+		}
+		err := r.handleJobWithNoWorkload(ctx, job)
+		if err != nil {
+			log.Error(err, "Handling job with no workload")
+		}
+		return ctrl.Result{}, err
+	}
+
+	// 3. handle a finished job
+	if jobFinished {
+		added := false
+		wl.Status.Conditions, added = appendFinishedConditionIfNotExists(wl.Status.Conditions, finishedMessage)
+		if !added {
+			return ctrl.Result{}, nil
+		}
+		err := r.client.Status().Update(ctx, wl)
+		if err != nil {
+			log.Error(err, "Updating workload status")
+			return ctrl.Result{}, err
+		}
+		workload.RecordEvent(r.record, wl, corev1.EventTypeNormal, "Finished", finishedMessage)
+		return ctrl.Result{}, nil
+	}
+
+	// 4. Handle a not finished job
+	if job.IsSuspended() {
+		// 4.1 start the job if the workload has been admitted, and the job is still suspended
+		if workload.IsAdmitted(wl) {
+			if cluster := wl.Annotations[constants.MultiKueueClusterAnnotation]; cluster != "" {
+				log.V(3).Info("Job admitted to run in a remote cluster, nothing to do", "multiKueueCluster", cluster)
+				return ctrl.Result{}, nil
+			}
+			log.V(2).Info("Job admitted, unsuspending")
+			err := r.startJob(ctx, wl, job)
+			if err != nil {
+				log.Error(err, "Unsuspending job")
+			}
+			return ctrl.Result{}, err
+		}
+
+		// 4.2 update queue name if changed.
+		q := QueueName(job)
+		if wl.Spec.QueueName != q {
+			log.V(2).Info("Job changed queues, updating workload")
+			wl.Spec.QueueName = q
+			err := r.client.Update(ctx, wl)
+			if err != nil {
+				log.Error(err, "Updating workload queue")
+			}
+			return ctrl.Result{}, err
+		}
+		log.V(3).Info("Job is suspended and workload not yet admitted by a clusterQueue, nothing to do")
+		return ctrl.Result{}, nil
+	}
+
+	if !workload.IsAdmitted(wl) {
+		// 4.3 the job must be suspended if the workload is not yet admitted.
+		log.V(2).Info("Running job is not admitted by a cluster queue, suspending")
+		err := r.stopJob(ctx, wl, job, "Not admitted by cluster queue")
+		if err != nil {
+			log.Error(err, "Suspending job with non admitted workload")
+		}
+		return ctrl.Result{}, err
+	}
+
+	// 4.4 workload is admitted and job is running, record when the pods are ready.
+	if r.waitForPodsReady && job.PodsReady() && !workload.InCondition(wl, kueue.WorkloadPodsReady) {
+		log.V(2).Info("Job pods are ready")
+		err := workload.UpdateStatus(ctx, r.client, wl, kueue.WorkloadPodsReady, corev1.ConditionTrue,
+			"PodsReady", "All the pods are ready or succeeded")
+		if err != nil {
+			log.Error(err, "Updating workload status")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// 4.5 release the quota of the pods that finished.
+	if rp := job.ReclaimablePods(); !equality.Semantic.DeepEqual(wl.Status.ReclaimablePods, rp) {
+		log.V(2).Info("Updating the reclaimable pods of the workload", "reclaimablePods", rp)
+		newWl := wl.DeepCopy()
+		newWl.Status.ReclaimablePods = rp
+		err := r.client.Status().Update(ctx, newWl)
+		if err != nil {
+			log.Error(err, "Updating workload status")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	log.V(3).Info("Job running with admitted workload, nothing to do")
+	return ctrl.Result{}, nil
+}
+
+// stopJob sends updates to suspend the job, reset the status so we can update the scheduling directives
+// later when unsuspending and resets the scheduling directives to their previous state based on what is
+// available in the workload (which should include the original affinities that the job had).
+func (r *JobReconciler) stopJob(ctx context.Context, w *kueue.Workload,
+	job GenericJob, eventMsg string) error {
+	object := job.Object()
+	job.Suspend()
+	annotations := object.GetAnnotations()
+	delete(annotations, constants.PodSetsHashAnnotation)
+	delete(annotations, constants.AdmissionAnnotation)
+	object.SetAnnotations(annotations)
+	if err := r.client.Update(ctx, object); err != nil {
+		return err
+	}
+	r.record.Eventf(object, corev1.EventTypeNormal, "Stopped", eventMsg)
+
+	// Reset the status so we can update the scheduling directives later when unsuspending.
+	if job.ResetStatus() {
+		if err := r.client.Status().Update(ctx, object); err != nil {
+			return err
+		}
+	}
+
+	if w == nil {
+		return nil
+	}
+	if job.RestorePodSetsInfo(w.Spec.PodSets) {
+		return r.client.Update(ctx, object)
+	}
+
+	return nil
+}
+
+func (r *JobReconciler) startJob(ctx context.Context, w *kueue.Workload, job GenericJob) error {
+	object := job.Object()
+	if len(w.Spec.PodSets) != len(job.PodSets()) {
+		return fmt.Errorf("%d podsets must exist, found %d", len(job.PodSets()), len(w.Spec.PodSets))
+	}
+	info, err := r.getPodSetsInfo(ctx, w)
+	if err != nil {
+		return err
+	}
+	job.RunWithPodSetsInfo(info)
+
+	// Record the admission, so that the workload can be recreated without
+	// stopping the job if it's deleted.
+	admission, err := json.Marshal(w.Spec.Admission)
+	if err != nil {
+		return err
+	}
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 2)
+	}
+	// The hash is taken from the job, as the counts of a partially admitted
+	// workload were applied to it.
+	annotations[constants.PodSetsHashAnnotation] = workload.PodSetsHash(job.PodSets())
+	annotations[constants.AdmissionAnnotation] = string(admission)
+	object.SetAnnotations(annotations)
+
+	if err := r.client.Update(ctx, object); err != nil {
+		return err
+	}
+
+	r.record.Eventf(object, corev1.EventTypeNormal, "Started",
+		"Admitted by clusterQueue %v", w.Spec.Admission.ClusterQueue)
+
+	if r.waitForPodsReady {
+		// The pods of a previous admission could have been ready.
+		return workload.UpdateStatusIfChanged(ctx, r.client, w, kueue.WorkloadPodsReady, corev1.ConditionFalse,
+			"PodsNotReady", "Waiting for the pods to be ready")
+	}
+	return nil
+}
+
+// getPodSetsInfo returns the scheduling directives for each podSet of the
+// admitted workload.
+func (r *JobReconciler) getPodSetsInfo(ctx context.Context, w *kueue.Workload) ([]PodSetInfo, error) {
+	log := ctrl.LoggerFrom(ctx)
+	infos := make([]PodSetInfo, len(w.Spec.Admission.PodSetFlavors))
+	for i, psFlavors := range w.Spec.Admission.PodSetFlavors {
+		info := &infos[i]
+		if len(psFlavors.Flavors) != 0 {
+			nodeSelector, err := r.getFlavorsLabels(ctx, psFlavors.Flavors)
+			if err != nil {
+				return nil, err
+			}
+			info.NodeSelector = nodeSelector
+		}
+		if len(info.NodeSelector) == 0 {
+			log.V(3).Info("no nodeSelectors to inject", "podSet", psFlavors.Name)
+		}
+		info.Count = psFlavors.Count
+		if len(w.Spec.PlacementHints) != 0 {
+			info.RequiredNodeSelectorTerms = append(info.RequiredNodeSelectorTerms, w.Spec.PlacementHints)
+		}
+		if len(psFlavors.Splits) != 0 {
+			// The pods of a podSet share one template, so they can't be
+			// pinned to the flavor of their split. Nothing would stop more
+			// pods than a split admits from landing in its flavor.
+			return nil, fmt.Errorf("podSet %q was split across flavors, which is not supported for jobs", psFlavors.Name)
+		}
+	}
+	return infos, nil
+}
+
+func (r *JobReconciler) getFlavorsLabels(ctx context.Context, flavors map[corev1.ResourceName]string) (map[string]string, error) {
+	processedFlvs := sets.NewString()
+	nodeSelector := map[string]string{}
+	for _, flvName := range flavors {
+		if processedFlvs.Has(flvName) {
+			continue
+		}
+		// Lookup the ResourceFlavors to fetch the node affinity labels to apply on the job.
+		flv := kueue.ResourceFlavor{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: flvName}, &flv); err != nil {
+			return nil, err
+		}
+		for k, v := range flv.Labels {
+			nodeSelector[k] = v
+		}
+		processedFlvs.Insert(flvName)
+	}
+	return nodeSelector, nil
+}
+
+func (r *JobReconciler) handleJobWithNoWorkload(ctx context.Context, job GenericJob) error {
+	log := ctrl.LoggerFrom(ctx)
+	object := job.Object()
+
+	// Recreate the workload of a running job with the admission that the job
+	// was started with, as long as the pod sets didn't change and the
+	// admission still fits in its ClusterQueue.
+	if !job.IsSuspended() {
+		wl, err := r.workloadToRestore(ctx, job)
+		if err != nil {
+			return err
+		}
+		if wl != nil {
+			if err = r.client.Create(ctx, wl); err != nil {
+				return err
+			}
+			r.record.Eventf(object, corev1.EventTypeNormal, "RestoredWorkload",
+				"Recreated admitted Workload: %v", workload.Key(wl))
+			return nil
+		}
+	}
+
+	// Wait until there are no active pods.
+	if job.IsActive() {
+		log.V(2).Info("Job is suspended but still has active pods, waiting")
+		return nil
+	}
+
+	// Create the corresponding workload.
+	wl, err := ConstructWorkloadFor(ctx, r.client, job, r.scheme)
+	if err != nil {
+		return err
+	}
+	if err = r.client.Create(ctx, wl); err != nil {
+		return err
+	}
+
+	r.record.Eventf(object, corev1.EventTypeNormal, "CreatedWorkload",
+		"Created Workload: %v", workload.Key(wl))
+	return nil
+}
+
+// ensureAtMostOneWorkload finds a matching workload and deletes redundant ones.
+func (r *JobReconciler) ensureAtMostOneWorkload(ctx context.Context, job GenericJob, workloads kueue.WorkloadList) (*kueue.Workload, error) {
+	log := ctrl.LoggerFrom(ctx)
+	object := job.Object()
+
+	// Find a matching workload first if there is one.
+	var toDelete []*kueue.Workload
+	var match *kueue.Workload
+	for i := range workloads.Items {
+		w := &workloads.Items[i]
+		owner := metav1.GetControllerOf(w)
+		// Indexes don't work in unit tests, so we explicitly check for the
+		// owner here.
+		if owner.Name != object.GetName() {
+			continue
+		}
+		if match == nil && equivalentToWorkload(job, w) {
+			match = w
+		} else {
+			toDelete = append(toDelete, w)
+		}
+	}
+
+	// If there is no matching workload and the job is running, suspend it,
+	// unless the workload can be recreated with the admission that the job
+	// was started with.
+	restorable := false
+	if match == nil && !job.IsSuspended() && len(workloads.Items) == 0 {
+		wl, err := r.workloadToRestore(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		restorable = wl != nil
+	}
+	if match == nil && !job.IsSuspended() && !restorable {
+		log.V(2).Info("job with no matching workload, suspending")
+		var w *kueue.Workload
+		if len(workloads.Items) == 1 {
+			// The job may have been modified and hence the existing workload
+			// doesn't match the job anymore. All bets are off if there are more
+			// than one workload...
+			w = &workloads.Items[0]
+		}
+		if err := r.stopJob(ctx, w, job, "No matching Workload"); err != nil {
+			log.Error(err, "stopping job")
+		}
+	}
+
+	// Delete duplicate workload instances.
+	existedWls := 0
+	for i := range toDelete {
+		err := r.client.Delete(ctx, toDelete[i])
+		if err == nil || !apierrors.IsNotFound(err) {
+			existedWls++
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to delete workload")
+		}
+		if err == nil {
+			r.record.Eventf(object, corev1.EventTypeNormal, "DeletedWorkload",
+				"Deleted not matching Workload: %v", workload.Key(toDelete[i]))
+		}
+	}
+
+	if existedWls != 0 {
+		if match == nil {
+			return nil, fmt.Errorf("no matching workload was found, tried deleting %d existing workload(s)", existedWls)
+		}
+		return nil, fmt.Errorf("only one workload should exist, found %d", len(workloads.Items))
+	}
+
+	return match, nil
+}
+
+// ConstructWorkloadFor returns the workload corresponding to the job, owned
+// by the job.
+func ConstructWorkloadFor(ctx context.Context, client client.Client,
+	job GenericJob, scheme *runtime.Scheme) (*kueue.Workload, error) {
+	object := job.Object()
+	hints, err := PlacementHintsFor(job)
+	if err != nil {
+		return nil, err
+	}
+	w := &kueue.Workload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      object.GetName(),
+			Namespace: object.GetNamespace(),
+		},
+		Spec: kueue.WorkloadSpec{
+			PodSets:        job.PodSets(),
+			QueueName:      QueueName(job),
+			PlacementHints: hints,
+		},
+	}
+	w.Annotations = map[string]string{
+		constants.PodSetsHashAnnotation: workload.PodSetsHash(w.Spec.PodSets),
+	}
+
+	// Populate priority from the workload priority class, if set, or from
+	// the priority class of the pods.
+	if wpc := WorkloadPriorityClassName(job); wpc != "" {
+		priorityClassName, p, err := utilpriority.GetPriorityFromWorkloadPriorityClass(ctx, client, wpc)
+		if err != nil {
+			return nil, err
+		}
+		w.Spec.Priority = &p
+		w.Spec.PriorityClassName = priorityClassName
+		w.Spec.PriorityClassSource = kueue.WorkloadPriorityClassSource
+	} else {
+		priorityClassName, p, err := utilpriority.GetPriorityFromPriorityClass(
+			ctx, client, job.PriorityClass())
+		if err != nil {
+			return nil, err
+		}
+		w.Spec.Priority = &p
+		w.Spec.PriorityClassName = priorityClassName
+		if priorityClassName != "" {
+			w.Spec.PriorityClassSource = kueue.PodPriorityClassSource
+		}
+	}
+
+	if err := ctrl.SetControllerReference(object, w, scheme); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func appendFinishedConditionIfNotExists(conds []kueue.WorkloadCondition, message string) ([]kueue.WorkloadCondition, bool) {
+	for i, c := range conds {
+		if c.Type == kueue.WorkloadFinished {
+			if c.Status == corev1.ConditionTrue {
+				return conds, false
+			}
+			conds = append(conds[:i], conds[i+1:]...)
+			break
+		}
+	}
+	now := metav1.Now()
+	conds = append(conds, kueue.WorkloadCondition{
+		Type:               kueue.WorkloadFinished,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             "JobFinished",
+		Message:            message,
+	})
+	return conds, true
+}
+
+// workloadToRestore returns a workload for the job with the admission
+// recorded in the job when it was started, if the pod sets of the job still
+// have the same hash and the cache validates the admission: its
+// ClusterQueue exists and is active, its flavors exist and its usage fits.
+// Otherwise, it returns nil and the workload is recreated pending.
+func (r *JobReconciler) workloadToRestore(ctx context.Context, job GenericJob) (*kueue.Workload, error) {
+	if r.cache == nil {
+		return nil, nil
+	}
+	annotations := job.Object().GetAnnotations()
+	hash, ok := annotations[constants.PodSetsHashAnnotation]
+	if !ok || hash != workload.PodSetsHash(job.PodSets()) {
+		return nil, nil
+	}
+	var admission kueue.Admission
+	if err := json.Unmarshal([]byte(annotations[constants.AdmissionAnnotation]), &admission); err != nil {
+		return nil, nil
+	}
+	wl, err := ConstructWorkloadFor(ctx, r.client, job, r.scheme)
+	if err != nil {
+		return nil, err
+	}
+	wl.Spec.Admission = &admission
+	if err := r.cache.ValidateAdmission(wl); err != nil {
+		ctrl.LoggerFrom(ctx).V(2).Info("Not restoring the admission of the job", "reason", err.Error())
+		return nil, nil
+	}
+	return wl, nil
+}
+
+func equivalentToWorkload(job GenericJob, wl *kueue.Workload) bool {
+	if hints, err := PlacementHintsFor(job); err != nil || !equality.Semantic.DeepEqual(hints, wl.Spec.PlacementHints) {
+		return false
+	}
+	return job.EquivalentToWorkload(wl)
+}
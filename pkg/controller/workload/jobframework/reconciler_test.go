@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobframework
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+)
+
+func TestGetPodSetsInfo(t *testing.T) {
+	cases := map[string]struct {
+		admission *kueue.Admission
+		wantInfos []PodSetInfo
+		wantErr   bool
+	}{
+		"single flavor": {
+			admission: utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "spot").Obj(),
+			wantInfos: []PodSetInfo{
+				{NodeSelector: map[string]string{"instance": "spot"}},
+			},
+		},
+		// The pods of a job share one template, so the pods of a split
+		// could run in the flavor of another split, and use more than the
+		// quota that the split admitted there.
+		"split across flavors": {
+			admission: &kueue.Admission{
+				ClusterQueue: "cq",
+				PodSetFlavors: []kueue.PodSetFlavors{
+					{
+						Name: "main",
+						Splits: []kueue.PodSetSplit{
+							{
+								Count:   3,
+								Flavors: map[corev1.ResourceName]string{corev1.ResourceCPU: "on-demand"},
+							},
+							{
+								Count:   2,
+								Flavors: map[corev1.ResourceName]string{corev1.ResourceCPU: "spot"},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				utiltesting.MakeResourceFlavor("on-demand").Label("instance", "on-demand").Obj(),
+				utiltesting.MakeResourceFlavor("spot").Label("instance", "spot").Obj(),
+			).Build()
+			r := NewReconciler(scheme, cl, nil)
+			wl := utiltesting.MakeWorkload("wl", "ns").Admit(tc.admission).Obj()
+			infos, err := r.getPodSetsInfo(context.Background(), wl)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("getPodSetsInfo() error = %v, want error %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantInfos, infos); diff != "" {
+				t.Errorf("Unexpected podSets info (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
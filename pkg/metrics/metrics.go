@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subsystemName = "kueue"
+
+	// AdmissionResultSuccess is the result of an admission attempt in which
+	// the workload was admitted.
+	AdmissionResultSuccess = "success"
+	// AdmissionResultInadmissible is the result of an admission attempt in
+	// which the workload couldn't be admitted.
+	AdmissionResultInadmissible = "inadmissible"
+)
+
+var (
+	admissionAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystemName,
+			Name:      "admission_attempts_total",
+			Help: "Total number of attempts to admit workloads. The label 'result' can have the following values:\n" +
+				"- 'success' means that the workload was admitted.\n" +
+				"- 'inadmissible' means that the workload couldn't be admitted.",
+		}, []string{"result"},
+	)
+
+	admissionWaitTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystemName,
+			Name:      "admission_wait_time_seconds",
+			Help:      "The time between a workload was created until it was admitted, per 'cluster_queue'",
+			Buckets:   prometheus.ExponentialBuckets(1, 2.5, 14),
+		}, []string{"cluster_queue"},
+	)
+
+	admissionCyclePhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystemName,
+			Name:      "admission_cycle_phase_duration_seconds",
+			Help: "The time that a scheduling cycle spent in each 'phase', when debugging is enabled. The label 'phase' can have the following values:\n" +
+				"- 'snapshot' is building the snapshot of the cache.\n" +
+				"- 'nomination' is finding a ClusterQueue and flavors for each head workload, including the flavor assignment and the preemption planning.\n" +
+				"- 'flavor_assignment' is assigning flavors to the head workloads.\n" +
+				"- 'preemption_planning' is finding the workloads to preempt.\n" +
+				"- 'api_updates' is updating the statuses of the pending workloads and evicting the preempted ones in the API server. The updates of the admitted workloads run in the background, after the cycle, so each of them is observed on its own.\n" +
+				"- 'total' is the whole cycle.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"phase"},
+	)
+
+	pendingWorkloads = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystemName,
+			Name:      "pending_workloads",
+			Help:      "Number of pending workloads, per 'cluster_queue'",
+		}, []string{"cluster_queue"},
+	)
+
+	admittedActiveWorkloads = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystemName,
+			Name:      "admitted_active_workloads",
+			Help:      "Number of admitted workloads that haven't finished yet, per 'cluster_queue'",
+		}, []string{"cluster_queue"},
+	)
+)
+
+// AdmissionAttempt records an attempt to admit a workload, with the given
+// result.
+func AdmissionAttempt(result string) {
+	admissionAttemptsTotal.WithLabelValues(result).Inc()
+}
+
+// AdmittedWorkload records the time that a workload admitted in the given
+// ClusterQueue waited since it was created.
+func AdmittedWorkload(cqName string, waitTime time.Duration) {
+	admissionWaitTime.WithLabelValues(cqName).Observe(waitTime.Seconds())
+}
+
+// AdmissionCyclePhase records the time that a scheduling cycle spent in the
+// given phase.
+func AdmissionCyclePhase(phase string, d time.Duration) {
+	admissionCyclePhaseDuration.WithLabelValues(phase).Observe(d.Seconds())
+}
+
+// ReportClusterQueueWorkloads records the number of pending and admitted
+// workloads of a ClusterQueue.
+func ReportClusterQueueWorkloads(cqName string, pending, admitted int) {
+	pendingWorkloads.WithLabelValues(cqName).Set(float64(pending))
+	admittedActiveWorkloads.WithLabelValues(cqName).Set(float64(admitted))
+}
+
+// ClearClusterQueueMetrics removes the metrics of a deleted ClusterQueue.
+func ClearClusterQueueMetrics(cqName string) {
+	admissionWaitTime.DeleteLabelValues(cqName)
+	pendingWorkloads.DeleteLabelValues(cqName)
+	admittedActiveWorkloads.DeleteLabelValues(cqName)
+}
+
+// Register registers the metrics in the given registerer.
+func Register(r prometheus.Registerer) {
+	r.MustRegister(
+		admissionAttemptsTotal,
+		admissionWaitTime,
+		admissionCyclePhaseDuration,
+		pendingWorkloads,
+		admittedActiveWorkloads,
+	)
+}
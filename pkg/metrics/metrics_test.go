@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReportClusterQueueWorkloads(t *testing.T) {
+	ReportClusterQueueWorkloads("cq", 3, 2)
+	if got := testutil.ToFloat64(pendingWorkloads.WithLabelValues("cq")); got != 3 {
+		t.Errorf("pending_workloads = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(admittedActiveWorkloads.WithLabelValues("cq")); got != 2 {
+		t.Errorf("admitted_active_workloads = %v, want 2", got)
+	}
+
+	ClearClusterQueueMetrics("cq")
+	if got := testutil.CollectAndCount(pendingWorkloads); got != 0 {
+		t.Errorf("Got %d pending_workloads series after clearing, want 0", got)
+	}
+	if got := testutil.CollectAndCount(admittedActiveWorkloads); got != 0 {
+		t.Errorf("Got %d admitted_active_workloads series after clearing, want 0", got)
+	}
+}
+
+func TestAdmissionAttempt(t *testing.T) {
+	AdmissionAttempt(AdmissionResultSuccess)
+	AdmissionAttempt(AdmissionResultInadmissible)
+	AdmissionAttempt(AdmissionResultInadmissible)
+	if got := testutil.ToFloat64(admissionAttemptsTotal.WithLabelValues(AdmissionResultSuccess)); got != 1 {
+		t.Errorf("Got %v successful attempts, want 1", got)
+	}
+	if got := testutil.ToFloat64(admissionAttemptsTotal.WithLabelValues(AdmissionResultInadmissible)); got != 2 {
+		t.Errorf("Got %v inadmissible attempts, want 2", got)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"math"
+	"time"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	utilpriority "sigs.k8s.io/kueue/pkg/util/priority"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// defaultPriorityAgingPeriod is the waiting time for each increase of the
+// priority when the ClusterQueue doesn't set one.
+const defaultPriorityAgingPeriod = time.Minute
+
+// agingClusterQueue is a ClusterQueue whose pending workloads gain priority
+// while they wait. The aged priorities are computed at a fixed time, so that
+// the order of the heap doesn't change between refreshes.
+type agingClusterQueue interface {
+	// agingPeriod returns the time between refreshes of the aged
+	// priorities, or zero if the workloads don't age.
+	agingPeriod() time.Duration
+	// refreshAging recomputes the aged priorities at the given time and
+	// reorders the pending workloads.
+	refreshAging(now time.Time)
+}
+
+// agingTimer refreshes the aged priorities of a ClusterQueue periodically.
+type agingTimer struct {
+	period time.Duration
+	timer  *time.Timer
+}
+
+// agingPeriod returns the waiting time for each increase of the priority.
+func agingPeriod(aging *kueue.PriorityAging) time.Duration {
+	if aging.PeriodSeconds <= 0 {
+		return defaultPriorityAgingPeriod
+	}
+	return time.Duration(aging.PeriodSeconds) * time.Second
+}
+
+// agedPriority returns the priority of the workload after waiting until now,
+// since its creation or its last eviction by the PodsReady timeout.
+func agedPriority(w *kueue.Workload, aging *kueue.PriorityAging, now time.Time) int32 {
+	p := utilpriority.Priority(w)
+	waited := now.Sub(workload.QueueOrderTimestamp(w).Time)
+	if waited <= 0 {
+		return p
+	}
+	increase := int64(waited/agingPeriod(aging)) * int64(aging.Increase)
+	if aging.MaxIncrease != nil && increase > int64(*aging.MaxIncrease) {
+		increase = int64(*aging.MaxIncrease)
+	}
+	aged := int64(p) + increase
+	if aged > math.MaxInt32 {
+		aged = math.MaxInt32
+	}
+	return int32(aged)
+}
+
+// updateAgingTimer starts, replaces or stops the timer that refreshes the
+// aged priorities of the ClusterQueue, following its aging period.
+func (m *Manager) updateAgingTimer(name string, cq ClusterQueue) {
+	var period time.Duration
+	if aging, ok := cq.(agingClusterQueue); ok {
+		period = aging.agingPeriod()
+	}
+	if t := m.agingTimers[name]; t != nil {
+		if t.period == period {
+			return
+		}
+		t.timer.Stop()
+		delete(m.agingTimers, name)
+	}
+	if period == 0 {
+		return
+	}
+	t := &agingTimer{period: period}
+	t.timer = time.AfterFunc(period, func() {
+		m.Lock()
+		defer m.Unlock()
+		// The timer could have been stopped or replaced meanwhile.
+		if m.agingTimers[name] != t {
+			return
+		}
+		if aging, ok := m.clusterQueues[name].(agingClusterQueue); ok {
+			aging.refreshAging(time.Now())
+		}
+		t.timer.Reset(period)
+	})
+	m.agingTimers[name] = t
+}
+
+// stopAgingTimer stops refreshing the aged priorities of the ClusterQueue.
+func (m *Manager) stopAgingTimer(name string) {
+	if t := m.agingTimers[name]; t != nil {
+		t.timer.Stop()
+		delete(m.agingTimers, name)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+const (
+	// inadmissibleBaseDelay is the delay before retrying a workload that was
+	// found inadmissible twice in a row. It doubles with each further
+	// attempt, up to inadmissibleMaxDelay. A workload found inadmissible for
+	// the first time is retried without delay.
+	inadmissibleBaseDelay = time.Second
+	inadmissibleMaxDelay  = 10 * time.Second
+)
+
+// backoff holds the consecutive attempts in which a workload was found
+// inadmissible and, while the workload waits before the next attempt, the
+// workload itself, which is kept out of its ClusterQueue.
+type backoff struct {
+	attempts    int32
+	info        *workload.Info
+	nextAttempt time.Time
+	timer       *time.Timer
+}
+
+func (b *backoff) waiting() bool {
+	return b.timer != nil
+}
+
+// inadmissibleDelay returns the delay before retrying a workload after the
+// given number of consecutive inadmissible attempts.
+func inadmissibleDelay(attempts int32) time.Duration {
+	if attempts < 2 {
+		return 0
+	}
+	delay := inadmissibleBaseDelay
+	for i := int32(2); i < attempts && delay < inadmissibleMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > inadmissibleMaxDelay {
+		delay = inadmissibleMaxDelay
+	}
+	return delay
+}
+
+// backOff records an inadmissible attempt of the workload. It returns
+// whether the workload has to wait before the next attempt, in which case
+// it's released to its ClusterQueue when the delay expires. A workload that
+// is back in the ClusterQueue, because it was updated, doesn't wait.
+func (m *Manager) backOff(info *workload.Info, cq ClusterQueue) bool {
+	key := workload.Key(info.Obj)
+	b := m.backoffs[key]
+	if b == nil {
+		b = &backoff{}
+		m.backoffs[key] = b
+	}
+	b.attempts++
+	delay := inadmissibleDelay(b.attempts)
+	if delay == 0 || cq.Info(key) != nil {
+		return false
+	}
+	b.info = info
+	b.nextAttempt = time.Now().Add(delay)
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		m.Lock()
+		defer m.Unlock()
+		// The backoff could have been released or reset meanwhile.
+		if m.backoffs[key] != b || b.timer != timer {
+			return
+		}
+		if m.releaseBackoff(b) {
+			m.cond.Broadcast()
+		}
+	})
+	b.timer = timer
+	return true
+}
+
+// releaseBackoff moves a workload waiting in backoff back to the
+// ClusterQueue of its queue, keeping the number of attempts. Returns whether
+// the workload is moved.
+func (m *Manager) releaseBackoff(b *backoff) bool {
+	if !b.waiting() {
+		return false
+	}
+	b.timer.Stop()
+	b.timer = nil
+	info := b.info
+	b.info = nil
+	q := m.queues[queueKeyForWorkload(info.Obj)]
+	if q == nil {
+		return false
+	}
+	cq := m.clusterQueues[q.ClusterQueue]
+	if cq == nil {
+		return false
+	}
+	return cq.RequeueIfNotPresent(info, true)
+}
+
+// releaseBackoffs moves the workloads waiting in backoff for the given
+// ClusterQueues that match, or all of them if match is nil, back to the
+// ClusterQueues, as an event might have made them admissible.
+// Returns whether at least one workload is moved.
+func (m *Manager) releaseBackoffs(cqNames sets.String, match func(*workload.Info) bool) bool {
+	released := false
+	for _, b := range m.backoffs {
+		if !b.waiting() || (match != nil && !match(b.info)) {
+			continue
+		}
+		if q := m.queues[queueKeyForWorkload(b.info.Obj)]; q != nil && cqNames.Has(q.ClusterQueue) {
+			released = m.releaseBackoff(b) || released
+		}
+	}
+	return released
+}
+
+// resetBackoff forgets the inadmissible attempts of a workload. If the
+// workload is waiting, it's not moved back to its ClusterQueue.
+func (m *Manager) resetBackoff(key string) {
+	if b := m.backoffs[key]; b != nil && b.waiting() {
+		b.timer.Stop()
+	}
+	delete(m.backoffs, key)
+}
+
+// waitingInBackoff returns the number of workloads of the ClusterQueue that
+// wait in backoff.
+func (m *Manager) waitingInBackoff(cqName string) int32 {
+	count := int32(0)
+	for key, b := range m.backoffs {
+		if !b.waiting() {
+			continue
+		}
+		q := m.queues[queueKeyForWorkload(b.info.Obj)]
+		if q == nil || q.ClusterQueue != cqName {
+			continue
+		}
+		// The workload could have been added back with its queue.
+		if cq := m.clusterQueues[cqName]; cq != nil && cq.Info(key) == nil {
+			count++
+		}
+	}
+	return count
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package queue
 
 import (
+	"sort"
+	"time"
+
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/workload"
@@ -30,38 +34,86 @@ type ClusterQueueBestEffortFIFO struct {
 
 	// inadmissibleWorkloads are workloads that have been tried at least once and couldn't be admitted.
 	inadmissibleWorkloads map[string]*workload.Info
+
+	// aging makes the pending workloads gain priority while they wait. If
+	// nil, they are ordered by their priority.
+	aging *kueue.PriorityAging
+	// agingTime is the time at which the aged priorities are computed.
+	agingTime time.Time
 }
 
 var _ ClusterQueue = &ClusterQueueBestEffortFIFO{}
+var _ agingClusterQueue = &ClusterQueueBestEffortFIFO{}
 
 const BestEffortFIFO = kueue.BestEffortFIFO
 
 func newClusterQueueBestEffortFIFO(cq *kueue.ClusterQueue) (ClusterQueue, error) {
-	cqImpl := newClusterQueueImpl(keyFunc, byCreationTime)
 	cqBE := &ClusterQueueBestEffortFIFO{
-		ClusterQueueImpl:      cqImpl,
 		inadmissibleWorkloads: make(map[string]*workload.Info),
 	}
+	cqBE.ClusterQueueImpl = newClusterQueueImpl(keyFunc, cqBE.agedQueueOrdering)
 
 	cqBE.Update(cq)
 	return cqBE, nil
 }
 
-func (cq *ClusterQueueBestEffortFIFO) PushOrUpdate(w *kueue.Workload) {
-	key := workload.Key(w)
+// Update updates the properties of the ClusterQueue, reordering the pending
+// workloads if their aging changes.
+func (cq *ClusterQueueBestEffortFIFO) Update(apiCQ *kueue.ClusterQueue) {
+	cq.ClusterQueueImpl.Update(apiCQ)
+	if !equality.Semantic.DeepEqual(cq.aging, apiCQ.Spec.PriorityAging) {
+		cq.aging = apiCQ.Spec.PriorityAging.DeepCopy()
+		cq.refreshAging(time.Now())
+	}
+}
+
+func (cq *ClusterQueueBestEffortFIFO) agingPeriod() time.Duration {
+	if cq.aging == nil {
+		return 0
+	}
+	return agingPeriod(cq.aging)
+}
+
+func (cq *ClusterQueueBestEffortFIFO) refreshAging(now time.Time) {
+	cq.agingTime = now
+	cq.rebuildHeap()
+}
+
+// agedQueueOrdering is queueOrdering with the priorities that the workloads
+// have after waiting until the agingTime.
+func (cq *ClusterQueueBestEffortFIFO) agedQueueOrdering(a, b interface{}) bool {
+	if cq.aging == nil {
+		return queueOrdering(a, b)
+	}
+	objA := a.(*workload.Info)
+	objB := b.(*workload.Info)
+	if b1, b2 := isBoosted(objA.Obj), isBoosted(objB.Obj); b1 != b2 {
+		return b1
+	}
+	p1 := agedPriority(objA.Obj, cq.aging, cq.agingTime)
+	p2 := agedPriority(objB.Obj, cq.aging, cq.agingTime)
+
+	if p1 != p2 {
+		return p1 > p2
+	}
+	return workload.QueueOrderTimestamp(objA.Obj).Before(workload.QueueOrderTimestamp(objB.Obj))
+}
+
+func (cq *ClusterQueueBestEffortFIFO) PushOrUpdate(wInfo *workload.Info) {
+	key := workload.Key(wInfo.Obj)
 	oldInfo := cq.inadmissibleWorkloads[key]
 	if oldInfo != nil {
 		// update in place if the workload was inadmissible and didn't change
-		// to potentially become admissible.
-		if equality.Semantic.DeepEqual(oldInfo.Obj.Spec, w.Spec) {
-			cq.inadmissibleWorkloads[key] = workload.NewInfo(w)
+		// to potentially become admissible, or to be boosted.
+		if equality.Semantic.DeepEqual(oldInfo.Obj.Spec, wInfo.Obj.Spec) && isBoosted(oldInfo.Obj) == isBoosted(wInfo.Obj) {
+			cq.inadmissibleWorkloads[key] = wInfo
 			return
 		}
 		// otherwise move or update in place in the queue.
 		delete(cq.inadmissibleWorkloads, key)
 	}
 
-	cq.ClusterQueueImpl.PushOrUpdate(w)
+	cq.ClusterQueueImpl.PushOrUpdate(wInfo)
 }
 
 func (cq *ClusterQueueBestEffortFIFO) Delete(w *kueue.Workload) {
@@ -69,6 +121,15 @@ func (cq *ClusterQueueBestEffortFIFO) Delete(w *kueue.Workload) {
 	cq.ClusterQueueImpl.Delete(w)
 }
 
+// DeleteFromQueue removes all workloads belonging to this queue from the
+// ClusterQueue, including the inadmissible ones.
+func (cq *ClusterQueueBestEffortFIFO) DeleteFromQueue(q *Queue) {
+	for _, w := range q.items {
+		delete(cq.inadmissibleWorkloads, workload.Key(w.Obj))
+	}
+	cq.ClusterQueueImpl.DeleteFromQueue(q)
+}
+
 // RequeueIfNotPresent inserts a workload that cannot be admitted into
 // ClusterQueue, unless it is already in the queue. If immediate is true,
 // the workload will be pushed back to heap directly. If not,
@@ -92,17 +153,53 @@ func (cq *ClusterQueueBestEffortFIFO) RequeueIfNotPresent(wInfo *workload.Info,
 	return true
 }
 
-// QueueInadmissibleWorkloads moves all workloads from inadmissibleWorkloads to heap.
+// QueueInadmissibleWorkloads moves the workloads from inadmissibleWorkloads
+// that match, or all of them if match is nil, to heap.
 // If at least one workload is moved, returns true. Otherwise returns false.
-func (cq *ClusterQueueBestEffortFIFO) QueueInadmissibleWorkloads() bool {
+func (cq *ClusterQueueBestEffortFIFO) QueueInadmissibleWorkloads(match func(*workload.Info) bool) bool {
 	if len(cq.inadmissibleWorkloads) == 0 {
 		return false
 	}
 
-	for _, wInfo := range cq.inadmissibleWorkloads {
+	if match == nil {
+		for _, wInfo := range cq.inadmissibleWorkloads {
+			cq.ClusterQueueImpl.pushIfNotPresent(wInfo)
+		}
+		cq.inadmissibleWorkloads = make(map[string]*workload.Info)
+		return true
+	}
+
+	moved := false
+	for key, wInfo := range cq.inadmissibleWorkloads {
+		if !match(wInfo) {
+			continue
+		}
 		cq.ClusterQueueImpl.pushIfNotPresent(wInfo)
+		delete(cq.inadmissibleWorkloads, key)
+		moved = true
 	}
+	return moved
+}
 
-	cq.inadmissibleWorkloads = make(map[string]*workload.Info)
-	return true
+func (cq *ClusterQueueBestEffortFIFO) Snapshot() []*workload.Info {
+	infos := cq.ClusterQueueImpl.Snapshot()
+	inadmissible := make([]*workload.Info, 0, len(cq.inadmissibleWorkloads))
+	for _, info := range cq.inadmissibleWorkloads {
+		inadmissible = append(inadmissible, info)
+	}
+	sort.Slice(inadmissible, func(i, j int) bool {
+		return cq.lessFunc(inadmissible[i], inadmissible[j])
+	})
+	return append(infos, inadmissible...)
+}
+
+func (cq *ClusterQueueBestEffortFIFO) DumpInadmissible() (sets.String, bool) {
+	if len(cq.inadmissibleWorkloads) == 0 {
+		return sets.NewString(), false
+	}
+	elements := make(sets.String, len(cq.inadmissibleWorkloads))
+	for _, info := range cq.inadmissibleWorkloads {
+		elements.Insert(info.Obj.Name)
+	}
+	return elements, true
 }
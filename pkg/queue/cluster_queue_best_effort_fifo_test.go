@@ -18,11 +18,13 @@ package queue
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/pointer"
 	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
 	"sigs.k8s.io/kueue/pkg/workload"
 )
@@ -49,6 +51,7 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 		workloadsToDelete          []*kueue.Workload
 		queueInadmissibleWorkloads bool
 		wantWorkloads              sets.String
+		wantInadmissibleWorkloads  sets.String
 	}{
 		{
 			name:                       "add, update, delete workload",
@@ -58,6 +61,7 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 			workloadsToDelete:          []*kueue.Workload{workloads[0]},
 			queueInadmissibleWorkloads: false,
 			wantWorkloads:              sets.NewString(workloads[1].Name),
+			wantInadmissibleWorkloads:  sets.NewString(),
 		},
 		{
 			name:                       "update inadmissible workload",
@@ -67,6 +71,7 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 			workloadsToDelete:          []*kueue.Workload{},
 			queueInadmissibleWorkloads: false,
 			wantWorkloads:              sets.NewString(workloads[0].Name, workloads[1].Name),
+			wantInadmissibleWorkloads:  sets.NewString(),
 		},
 		{
 			name:                       "re-queue inadmissible workload",
@@ -76,6 +81,7 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 			workloadsToDelete:          []*kueue.Workload{},
 			queueInadmissibleWorkloads: true,
 			wantWorkloads:              sets.NewString(workloads[0].Name, workloads[1].Name),
+			wantInadmissibleWorkloads:  sets.NewString(),
 		},
 		{
 			name:                       "delete inadmissible workload",
@@ -85,6 +91,17 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 			workloadsToDelete:          []*kueue.Workload{workloads[1]},
 			queueInadmissibleWorkloads: true,
 			wantWorkloads:              sets.NewString(workloads[0].Name),
+			wantInadmissibleWorkloads:  sets.NewString(),
+		},
+		{
+			name:                       "add inadmissible workload",
+			workloadsToAdd:             []*kueue.Workload{workloads[0]},
+			inadmissibleWorkloadsToAdd: []*workload.Info{workload.NewInfo(workloads[1])},
+			workloadsToUpdate:          []*kueue.Workload{},
+			workloadsToDelete:          []*kueue.Workload{},
+			queueInadmissibleWorkloads: false,
+			wantWorkloads:              sets.NewString(workloads[0].Name),
+			wantInadmissibleWorkloads:  sets.NewString(workloads[1].Name),
 		},
 	}
 
@@ -96,7 +113,7 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 			}
 
 			for _, w := range test.workloadsToAdd {
-				cq.PushOrUpdate(w)
+				cq.PushOrUpdate(workload.NewInfo(w))
 			}
 
 			for _, w := range test.inadmissibleWorkloadsToAdd {
@@ -104,7 +121,7 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 			}
 
 			for _, w := range test.workloadsToUpdate {
-				cq.PushOrUpdate(w)
+				cq.PushOrUpdate(workload.NewInfo(w))
 			}
 
 			for _, w := range test.workloadsToDelete {
@@ -112,13 +129,94 @@ func TestClusterQueueBestEffortFIFO(t *testing.T) {
 			}
 
 			if test.queueInadmissibleWorkloads {
-				cq.QueueInadmissibleWorkloads()
+				cq.QueueInadmissibleWorkloads(nil)
 			}
 
 			gotWorkloads, _ := cq.Dump()
 			if diff := cmp.Diff(test.wantWorkloads, gotWorkloads); diff != "" {
 				t.Errorf("Unexpected items in cluster foo (-want,+got):\n%s", diff)
 			}
+
+			gotInadmissible, _ := cq.DumpInadmissible()
+			if diff := cmp.Diff(test.wantInadmissibleWorkloads, gotInadmissible); diff != "" {
+				t.Errorf("Unexpected inadmissible items in cluster foo (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClusterQueueBestEffortFIFOPriorityAging(t *testing.T) {
+	now := time.Now()
+	cases := map[string]struct {
+		aging       kueue.PriorityAging
+		workloads   []*kueue.Workload
+		refreshedAt time.Time
+		wantOrder   []string
+	}{
+		"waiting workload overtakes a higher priority": {
+			aging: kueue.PriorityAging{Increase: 1, PeriodSeconds: 60},
+			workloads: []*kueue.Workload{
+				utiltesting.MakeWorkload("old", "").Priority(0).Creation(now.Add(-10 * time.Minute)).Obj(),
+				utiltesting.MakeWorkload("new", "").Priority(3).Creation(now).Obj(),
+			},
+			refreshedAt: now,
+			wantOrder:   []string{"old", "new"},
+		},
+		"increase is limited": {
+			aging: kueue.PriorityAging{Increase: 1, PeriodSeconds: 60, MaxIncrease: pointer.Int32(2)},
+			workloads: []*kueue.Workload{
+				utiltesting.MakeWorkload("old", "").Priority(0).Creation(now.Add(-10 * time.Minute)).Obj(),
+				utiltesting.MakeWorkload("new", "").Priority(3).Creation(now).Obj(),
+			},
+			refreshedAt: now,
+			wantOrder:   []string{"new", "old"},
+		},
+		"refresh reorders the workloads": {
+			aging: kueue.PriorityAging{Increase: 1, PeriodSeconds: 60},
+			workloads: []*kueue.Workload{
+				utiltesting.MakeWorkload("old", "").Priority(0).Creation(now.Add(-30 * time.Second)).Obj(),
+				utiltesting.MakeWorkload("new", "").Priority(1).Creation(now).Obj(),
+			},
+			refreshedAt: now.Add(30 * time.Second),
+			wantOrder:   []string{"old", "new"},
+		},
+		"not refreshed": {
+			aging: kueue.PriorityAging{Increase: 1, PeriodSeconds: 60},
+			workloads: []*kueue.Workload{
+				utiltesting.MakeWorkload("old", "").Priority(0).Creation(now.Add(-30 * time.Second)).Obj(),
+				utiltesting.MakeWorkload("new", "").Priority(1).Creation(now).Obj(),
+			},
+			wantOrder: []string{"new", "old"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cq, err := newClusterQueueBestEffortFIFO(utiltesting.MakeClusterQueue("cq").
+				QueueingStrategy(kueue.BestEffortFIFO).
+				PriorityAging(tc.aging).
+				Obj())
+			if err != nil {
+				t.Fatalf("Failed creating ClusterQueue %v", err)
+			}
+			aging := cq.(*ClusterQueueBestEffortFIFO)
+			// Compute the aged priorities at a fixed time.
+			aging.refreshAging(now)
+			if got := aging.agingPeriod(); got != time.Duration(tc.aging.PeriodSeconds)*time.Second {
+				t.Errorf("Got aging period %v, want %ds", got, tc.aging.PeriodSeconds)
+			}
+			for _, w := range tc.workloads {
+				cq.PushOrUpdate(workload.NewInfo(w))
+			}
+			if !tc.refreshedAt.IsZero() {
+				aging.refreshAging(tc.refreshedAt)
+			}
+			var gotOrder []string
+			for info := cq.Pop(); info != nil; info = cq.Pop() {
+				gotOrder = append(gotOrder, info.Obj.Name)
+			}
+			if diff := cmp.Diff(tc.wantOrder, gotOrder); diff != "" {
+				t.Errorf("Unexpected order (-want,+got):\n%s", diff)
+			}
 		})
 	}
 }
@@ -24,20 +24,46 @@ import (
 	"sigs.k8s.io/kueue/pkg/workload"
 )
 
+// workloadHeap holds the pending workloads of a ClusterQueue in order.
+type workloadHeap interface {
+	PushOrUpdate(obj interface{})
+	PushIfNotPresent(obj interface{}) bool
+	Delete(key string)
+	Pop() interface{}
+	GetByKey(key string) interface{}
+	Len() int
+	List() []interface{}
+	Sorted() []interface{}
+}
+
 // ClusterQueueImpl is the base implementation of ClusterQueue interface.
 // It can be inherited and overwritten by other class.
 type ClusterQueueImpl struct {
 	// QueueingStrategy indicates the queueing strategy of the workloads
 	// across the queues in this ClusterQueue.
 	QueueingStrategy kueue.QueueingStrategy
-
-	heap   heap.Heap
-	cohort string
+	// FairQueueing indicates how the workloads of the different queues are
+	// interleaved.
+	FairQueueing kueue.FairQueueingPolicy
+	// StopPolicy indicates whether the admission of workloads from this
+	// ClusterQueue is stopped.
+	StopPolicy kueue.StopPolicy
+
+	heap     workloadHeap
+	keyFunc  func(obj interface{}) string
+	lessFunc func(a, b interface{}) bool
+	cohort   string
+	// queues are the queues feeding this ClusterQueue, by key.
+	queues map[string]*Queue
 }
 
 func newClusterQueueImpl(keyFunc func(obj interface{}) string, lessFunc func(a, b interface{}) bool) *ClusterQueueImpl {
+	h := heap.New(keyFunc, lessFunc)
 	return &ClusterQueueImpl{
-		heap: heap.New(keyFunc, lessFunc),
+		heap:     &h,
+		keyFunc:  keyFunc,
+		lessFunc: lessFunc,
+		queues:   make(map[string]*Queue),
 	}
 }
 
@@ -46,13 +72,62 @@ var _ ClusterQueue = &ClusterQueueImpl{}
 func (c *ClusterQueueImpl) Update(apiCQ *kueue.ClusterQueue) {
 	c.QueueingStrategy = apiCQ.Spec.QueueingStrategy
 	c.cohort = apiCQ.Spec.Cohort
+	c.StopPolicy = apiCQ.Spec.StopPolicy
+	if fairQueueing := apiCQ.Spec.FairQueueing; fairQueueing != c.FairQueueing {
+		c.FairQueueing = fairQueueing
+		c.rebuildHeap()
+	}
+}
+
+// rebuildHeap moves the workloads to a new heap that follows the
+// FairQueueing policy.
+func (c *ClusterQueueImpl) rebuildHeap() {
+	var h workloadHeap
+	switch c.FairQueueing {
+	case kueue.QueueWeightFairQueueing:
+		h = newFairHeap(c.keyFunc, c.lessFunc, queueOfWorkload, c.queueWeight)
+	case kueue.NamespaceRoundRobinFairQueueing:
+		h = newFairHeap(c.keyFunc, c.lessFunc, namespaceOfWorkload, equalWeight)
+	default:
+		defaultHeap := heap.New(c.keyFunc, c.lessFunc)
+		h = &defaultHeap
+	}
+	for _, obj := range c.heap.List() {
+		h.PushIfNotPresent(obj)
+	}
+	c.heap = h
+}
+
+func queueOfWorkload(info *workload.Info) string {
+	return queueKeyForWorkload(info.Obj)
+}
+
+func namespaceOfWorkload(info *workload.Info) string {
+	return info.Obj.Namespace
+}
+
+func equalWeight(string) int32 {
+	return 1
+}
+
+// queueWeight returns the weight of the queue with the given key.
+func (c *ClusterQueueImpl) queueWeight(key string) int32 {
+	if q := c.queues[key]; q != nil {
+		return q.Weight
+	}
+	return 1
 }
 
 func (c *ClusterQueueImpl) Cohort() string {
 	return c.cohort
 }
 
+func (c *ClusterQueueImpl) Stopped() bool {
+	return c.StopPolicy == kueue.StopPolicyHold || c.StopPolicy == kueue.StopPolicyHoldAndDrain
+}
+
 func (c *ClusterQueueImpl) AddFromQueue(q *Queue) bool {
+	c.queues[q.Key] = q
 	added := false
 	for _, info := range q.items {
 		if c.pushIfNotPresent(info) {
@@ -63,6 +138,7 @@ func (c *ClusterQueueImpl) AddFromQueue(q *Queue) bool {
 }
 
 func (c *ClusterQueueImpl) DeleteFromQueue(q *Queue) {
+	delete(c.queues, q.Key)
 	for _, w := range q.items {
 		c.Delete(w.Obj)
 	}
@@ -74,9 +150,8 @@ func (c *ClusterQueueImpl) pushIfNotPresent(info *workload.Info) bool {
 	return c.heap.PushIfNotPresent(info)
 }
 
-func (c *ClusterQueueImpl) PushOrUpdate(w *kueue.Workload) {
-	info := workload.NewInfo(w)
-	c.heap.PushOrUpdate(info)
+func (c *ClusterQueueImpl) PushOrUpdate(wInfo *workload.Info) {
+	c.heap.PushOrUpdate(wInfo)
 }
 
 func (c *ClusterQueueImpl) Delete(w *kueue.Workload) {
@@ -87,7 +162,7 @@ func (c *ClusterQueueImpl) RequeueIfNotPresent(wInfo *workload.Info, _ bool) boo
 	return c.pushIfNotPresent(wInfo)
 }
 
-func (c *ClusterQueueImpl) QueueInadmissibleWorkloads() bool {
+func (c *ClusterQueueImpl) QueueInadmissibleWorkloads(func(*workload.Info) bool) bool {
 	return false
 }
 
@@ -107,6 +182,15 @@ func (c *ClusterQueueImpl) Pending() int32 {
 	return int32(c.heap.Len())
 }
 
+func (c *ClusterQueueImpl) Snapshot() []*workload.Info {
+	sorted := c.heap.Sorted()
+	infos := make([]*workload.Info, len(sorted))
+	for i, obj := range sorted {
+		infos[i] = obj.(*workload.Info)
+	}
+	return infos
+}
+
 func (c *ClusterQueueImpl) Dump() (sets.String, bool) {
 	if c.heap.Len() == 0 {
 		return sets.NewString(), false
@@ -119,6 +203,10 @@ func (c *ClusterQueueImpl) Dump() (sets.String, bool) {
 	return elements, true
 }
 
+func (c *ClusterQueueImpl) DumpInadmissible() (sets.String, bool) {
+	return sets.NewString(), false
+}
+
 func (c *ClusterQueueImpl) Info(key string) *workload.Info {
 	info := c.heap.GetByKey(key)
 	if info == nil {
@@ -20,6 +20,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
 	"sigs.k8s.io/kueue/pkg/workload"
@@ -30,19 +32,19 @@ const (
 )
 
 func Test_PushOrUpdate(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	wl := utiltesting.MakeWorkload("workload-1", defaultNamespace).Obj()
 	if cq.Pending() != 0 {
 		t.Error("ClusterQueue should be empty")
 	}
-	cq.PushOrUpdate(wl)
+	cq.PushOrUpdate(workload.NewInfo(wl))
 	if cq.Pending() != 1 {
 		t.Error("ClusterQueue should have one workload")
 	}
 
 	// Just used to validate the update operation.
 	wl.ResourceVersion = "1"
-	cq.PushOrUpdate(wl)
+	cq.PushOrUpdate(workload.NewInfo(wl))
 	newWl := cq.Pop()
 	if cq.Pending() != 0 || newWl.Obj.ResourceVersion != "1" {
 		t.Error("failed to update a workload in ClusterQueue")
@@ -50,15 +52,15 @@ func Test_PushOrUpdate(t *testing.T) {
 }
 
 func Test_Pop(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	now := time.Now()
 	wl1 := utiltesting.MakeWorkload("workload-1", defaultNamespace).Creation(now).Obj()
 	wl2 := utiltesting.MakeWorkload("workload-2", defaultNamespace).Creation(now.Add(time.Second)).Obj()
 	if cq.Pop() != nil {
 		t.Error("ClusterQueue should be empty")
 	}
-	cq.PushOrUpdate(wl1)
-	cq.PushOrUpdate(wl2)
+	cq.PushOrUpdate(workload.NewInfo(wl1))
+	cq.PushOrUpdate(workload.NewInfo(wl2))
 	newWl := cq.Pop()
 	if newWl == nil || newWl.Obj.Name != "workload-1" {
 		t.Error("failed to Pop workload")
@@ -73,11 +75,11 @@ func Test_Pop(t *testing.T) {
 }
 
 func Test_Delete(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	wl1 := utiltesting.MakeWorkload("workload-1", defaultNamespace).Obj()
 	wl2 := utiltesting.MakeWorkload("workload-2", defaultNamespace).Obj()
-	cq.PushOrUpdate(wl1)
-	cq.PushOrUpdate(wl2)
+	cq.PushOrUpdate(workload.NewInfo(wl1))
+	cq.PushOrUpdate(workload.NewInfo(wl2))
 	if cq.Pending() != 2 {
 		t.Error("ClusterQueue should have two workload")
 	}
@@ -94,40 +96,40 @@ func Test_Delete(t *testing.T) {
 }
 
 func Test_Dump(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	wl1 := utiltesting.MakeWorkload("workload-1", defaultNamespace).Obj()
 	wl2 := utiltesting.MakeWorkload("workload-2", defaultNamespace).Obj()
 	if _, ok := cq.Dump(); ok {
 		t.Error("ClusterQueue should be empty")
 	}
-	cq.PushOrUpdate(wl1)
-	cq.PushOrUpdate(wl2)
+	cq.PushOrUpdate(workload.NewInfo(wl1))
+	cq.PushOrUpdate(workload.NewInfo(wl2))
 	if data, ok := cq.Dump(); !(ok && data.HasAll("workload-1", "workload-2")) {
 		t.Error("dump data is not right")
 	}
 }
 
 func Test_Info(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	wl := utiltesting.MakeWorkload("workload-1", defaultNamespace).Obj()
 	if info := cq.Info(keyFunc(workload.NewInfo(wl))); info != nil {
 		t.Error("workload doesn't exist")
 	}
-	cq.PushOrUpdate(wl)
+	cq.PushOrUpdate(workload.NewInfo(wl))
 	if info := cq.Info(keyFunc(workload.NewInfo(wl))); info == nil {
 		t.Error("expected workload to exist")
 	}
 }
 
 func Test_AddFromQueue(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	wl := utiltesting.MakeWorkload("workload-1", defaultNamespace).Obj()
 	queue := &Queue{
 		items: map[string]*workload.Info{
 			wl.Name: workload.NewInfo(wl),
 		},
 	}
-	cq.PushOrUpdate(wl)
+	cq.PushOrUpdate(workload.NewInfo(wl))
 	if added := cq.AddFromQueue(queue); added {
 		t.Error("expected workload not to be added")
 	}
@@ -138,7 +140,7 @@ func Test_AddFromQueue(t *testing.T) {
 }
 
 func Test_DeleteFromQueue(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	wl1 := utiltesting.MakeWorkload("workload-1", defaultNamespace).Obj()
 	wl2 := utiltesting.MakeWorkload("workload-2", defaultNamespace).Obj()
 	queue := &Queue{
@@ -158,7 +160,7 @@ func Test_DeleteFromQueue(t *testing.T) {
 }
 
 func Test_RequeueIfNotPresent(t *testing.T) {
-	cq := newClusterQueueImpl(keyFunc, byCreationTime)
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
 	wl := utiltesting.MakeWorkload("workload-1", defaultNamespace).Obj()
 	if ok := cq.RequeueIfNotPresent(workload.NewInfo(wl), true); !ok {
 		t.Error("failed to requeue nonexistent workload")
@@ -167,3 +169,128 @@ func Test_RequeueIfNotPresent(t *testing.T) {
 		t.Error("existent workload shouldn't be added again")
 	}
 }
+
+func Test_FairQueueing(t *testing.T) {
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
+	now := time.Now()
+	queueA := &Queue{Key: defaultNamespace + "/a", Weight: 2, items: map[string]*workload.Info{}}
+	queueB := &Queue{Key: defaultNamespace + "/b", Weight: 1, items: map[string]*workload.Info{}}
+	for i, name := range []string{"a1", "a2", "a3", "a4", "b1", "b2", "b3", "b4"} {
+		wl := utiltesting.MakeWorkload(name, defaultNamespace).Queue(name[:1]).Creation(now.Add(time.Duration(i) * time.Second)).Obj()
+		q := queueA
+		if name[0] == 'b' {
+			q = queueB
+		}
+		q.items[workload.Key(wl)] = workload.NewInfo(wl)
+	}
+	cq.AddFromQueue(queueA)
+	cq.AddFromQueue(queueB)
+
+	// Switching the policy keeps the pending workloads.
+	cq.Update(utiltesting.MakeClusterQueue("cq").FairQueueing(kueue.QueueWeightFairQueueing).Obj())
+	if cq.Pending() != 8 {
+		t.Fatalf("ClusterQueue has %d pending workloads, want 8", cq.Pending())
+	}
+
+	wantOrder := []string{"a1", "b1", "a2", "a3", "b2", "a4", "b3", "b4"}
+	var snapshotOrder []string
+	for _, info := range cq.Snapshot() {
+		snapshotOrder = append(snapshotOrder, info.Obj.Name)
+	}
+	if diff := cmp.Diff(wantOrder, snapshotOrder); diff != "" {
+		t.Errorf("Unexpected order in the snapshot (-want,+got):\n%s", diff)
+	}
+
+	var gotOrder []string
+	for info := cq.Pop(); info != nil; info = cq.Pop() {
+		gotOrder = append(gotOrder, info.Obj.Name)
+	}
+	if diff := cmp.Diff(wantOrder, gotOrder); diff != "" {
+		t.Errorf("Unexpected order (-want,+got):\n%s", diff)
+	}
+}
+
+func Test_FairQueueingBoost(t *testing.T) {
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
+	cq.Update(utiltesting.MakeClusterQueue("cq").FairQueueing(kueue.NamespaceRoundRobinFairQueueing).Obj())
+	now := time.Now()
+	workloads := []*kueue.Workload{
+		utiltesting.MakeWorkload("a", "ns1").Creation(now).Obj(),
+		utiltesting.MakeWorkload("b", "ns1").Creation(now.Add(time.Second)).Obj(),
+		utiltesting.MakeWorkload("c", "ns1").Creation(now.Add(2*time.Second)).Annotation(kueue.PriorityBoostAnnotation, "true").Obj(),
+		utiltesting.MakeWorkload("d", "ns2").Creation(now.Add(3 * time.Second)).Obj(),
+	}
+	for _, w := range workloads {
+		cq.PushOrUpdate(workload.NewInfo(w))
+	}
+
+	// The boosted workload goes first, regardless of the turn of ns1.
+	wantOrder := []string{"c", "d", "a", "b"}
+	var snapshotOrder []string
+	for _, info := range cq.Snapshot() {
+		snapshotOrder = append(snapshotOrder, info.Obj.Name)
+	}
+	if diff := cmp.Diff(wantOrder, snapshotOrder); diff != "" {
+		t.Errorf("Unexpected order in the snapshot (-want,+got):\n%s", diff)
+	}
+	var gotOrder []string
+	for info := cq.Pop(); info != nil; info = cq.Pop() {
+		gotOrder = append(gotOrder, info.Obj.Name)
+	}
+	if diff := cmp.Diff(wantOrder, gotOrder); diff != "" {
+		t.Errorf("Unexpected order (-want,+got):\n%s", diff)
+	}
+}
+
+func Test_NamespaceRoundRobin(t *testing.T) {
+	cq := newClusterQueueImpl(keyFunc, queueOrdering)
+	cq.Update(utiltesting.MakeClusterQueue("cq").FairQueueing(kueue.NamespaceRoundRobinFairQueueing).Obj())
+	now := time.Now()
+	// A burst of workloads in ns1 followed by a few in ns2 and ns3.
+	workloads := []*kueue.Workload{
+		utiltesting.MakeWorkload("a", "ns1").Creation(now).Obj(),
+		utiltesting.MakeWorkload("b", "ns1").Creation(now.Add(time.Second)).Obj(),
+		utiltesting.MakeWorkload("c", "ns1").Creation(now.Add(2 * time.Second)).Obj(),
+		utiltesting.MakeWorkload("d", "ns2").Creation(now.Add(3 * time.Second)).Obj(),
+		utiltesting.MakeWorkload("e", "ns2").Creation(now.Add(4 * time.Second)).Obj(),
+		utiltesting.MakeWorkload("f", "ns3").Creation(now.Add(5 * time.Second)).Obj(),
+	}
+	for _, w := range workloads {
+		cq.PushOrUpdate(workload.NewInfo(w))
+	}
+
+	var gotOrder []string
+	for info := cq.Pop(); info != nil; info = cq.Pop() {
+		gotOrder = append(gotOrder, info.Obj.Name)
+	}
+	wantOrder := []string{"a", "d", "f", "b", "e", "c"}
+	if diff := cmp.Diff(wantOrder, gotOrder); diff != "" {
+		t.Errorf("Unexpected order (-want,+got):\n%s", diff)
+	}
+}
+
+func Test_FairHeapRemovesEmptyGroups(t *testing.T) {
+	h := newFairHeap(keyFunc, queueOrdering, namespaceOfWorkload, equalWeight)
+	now := time.Now()
+	a := workload.NewInfo(utiltesting.MakeWorkload("a", "ns1").Creation(now).Obj())
+	b := workload.NewInfo(utiltesting.MakeWorkload("b", "ns2").Creation(now.Add(time.Second)).Obj())
+	h.PushOrUpdate(a)
+	h.PushOrUpdate(b)
+	if len(h.groups) != 2 {
+		t.Fatalf("Heap has %d groups, want 2", len(h.groups))
+	}
+
+	if got := h.Pop(); got != a {
+		t.Fatalf("Pop() = %v, want workload a", got)
+	}
+	if _, ok := h.groups["ns1"]; ok {
+		t.Error("The group of the popped workload wasn't removed")
+	}
+	h.Delete(workload.Key(b.Obj))
+	if len(h.groups) != 0 {
+		t.Errorf("Heap has %d groups after deleting all the workloads, want 0", len(h.groups))
+	}
+	if got := h.Pop(); got != nil {
+		t.Errorf("Pop() = %v on an empty heap, want nil", got)
+	}
+}
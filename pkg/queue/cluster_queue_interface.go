@@ -31,6 +31,9 @@ type ClusterQueue interface {
 	Update(*kueue.ClusterQueue)
 	// Cohort returns the Cohort of this ClusterQueue.
 	Cohort() string
+	// Stopped returns whether the admission of workloads from this
+	// ClusterQueue is stopped.
+	Stopped() bool
 
 	// AddFromQueue pushes all workloads belonging to this queue to
 	// the ClusterQueue. If at least one workload is added, returns true.
@@ -42,7 +45,7 @@ type ClusterQueue interface {
 
 	// PushOrUpdate pushes the workload to ClusterQueue.
 	// If the workload is already present, updates with the new one.
-	PushOrUpdate(*kueue.Workload)
+	PushOrUpdate(*workload.Info)
 	// Delete removes the workload from ClusterQueue.
 	Delete(*kueue.Workload)
 	// Pop removes the head of the queue and returns it. It returns nil if the
@@ -59,17 +62,26 @@ type ClusterQueue interface {
 	// The workload should not be reinserted if it's already in the ClusterQueue.
 	// Returns true if the workload was inserted.
 	RequeueIfNotPresent(*workload.Info, bool) bool
-	// QueueInadmissibleWorkloads moves all workloads put in temporary placeholder stage
-	// to the ClusterQueue. If at least one workload is moved,
+	// QueueInadmissibleWorkloads moves the workloads put in temporary
+	// placeholder stage that match the given function, or all of them if it's
+	// nil, to the ClusterQueue. If at least one workload is moved,
 	// returns true. Otherwise returns false.
-	QueueInadmissibleWorkloads() bool
+	QueueInadmissibleWorkloads(match func(*workload.Info) bool) bool
 
 	// Pending returns the number of pending workloads.
 	Pending() int32
+	// Snapshot returns the pending workloads in the order in which they
+	// would be popped, followed by the workloads in the temporary
+	// placeholder stage, in the order of the queueing strategy.
+	Snapshot() []*workload.Info
 	// Dump produces a dump of the current workloads in the heap of
 	// this ClusterQueue. It returns false if the queue is empty.
 	// Otherwise returns true.
 	Dump() (sets.String, bool)
+	// DumpInadmissible produces a dump of the current workloads in the
+	// temporary placeholder stage of this ClusterQueue. It returns false if
+	// there are none. Otherwise returns true.
+	DumpInadmissible() (sets.String, bool)
 	// Info returns workload.Info for the workload key.
 	// Users of this method should not modify the returned object.
 	Info(string) *workload.Info
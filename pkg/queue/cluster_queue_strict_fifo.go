@@ -33,22 +33,40 @@ var _ ClusterQueue = &ClusterQueueStrictFIFO{}
 const StrictFIFO = kueue.StrictFIFO
 
 func newClusterQueueStrictFIFO(cq *kueue.ClusterQueue) (ClusterQueue, error) {
-	cqImpl := newClusterQueueImpl(keyFunc, byCreationTime)
+	cqImpl := newClusterQueueImpl(keyFunc, queueOrdering)
 	cqImpl.Update(cq)
 	return cqImpl, nil
 }
 
-// byCreationTime is the function used by the clusterQueue heap algorithm to sort
-// workloads. It sorts workloads based on their priority.
-// When priorities are equal, it uses workloads.creationTimestamp.
-func byCreationTime(a, b interface{}) bool {
+// isStrictFIFO returns whether the ClusterQueue follows StrictFIFO.
+func isStrictFIFO(cq ClusterQueue) bool {
+	impl, ok := cq.(*ClusterQueueImpl)
+	return ok && impl.QueueingStrategy == StrictFIFO
+}
+
+// queueOrdering is the function used by the clusterQueue heap algorithm to
+// sort workloads. The workloads boosted by an administrator go first.
+// Otherwise, it sorts workloads based on their priority.
+// When priorities are equal, it uses the timestamp returned by
+// workload.QueueOrderTimestamp: the creation time or, for workloads evicted
+// by the PodsReady timeout, the time of the eviction.
+func queueOrdering(a, b interface{}) bool {
 	objA := a.(*workload.Info)
 	objB := b.(*workload.Info)
+	if b1, b2 := isBoosted(objA.Obj), isBoosted(objB.Obj); b1 != b2 {
+		return b1
+	}
 	p1 := utilpriority.Priority(objA.Obj)
 	p2 := utilpriority.Priority(objB.Obj)
 
 	if p1 != p2 {
 		return p1 > p2
 	}
-	return objA.Obj.CreationTimestamp.Before(&objB.Obj.CreationTimestamp)
+	return workload.QueueOrderTimestamp(objA.Obj).Before(workload.QueueOrderTimestamp(objB.Obj))
+}
+
+// isBoosted returns whether the workload was moved to the front of its
+// ClusterQueue with the kueue.PriorityBoostAnnotation.
+func isBoosted(w *kueue.Workload) bool {
+	return w.Annotations[kueue.PriorityBoostAnnotation] == "true"
 }
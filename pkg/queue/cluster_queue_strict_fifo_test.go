@@ -20,10 +20,12 @@ import (
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/workload"
 )
 
 const (
@@ -62,7 +64,7 @@ func TestFIFOClusterQueue(t *testing.T) {
 		},
 	}
 	for _, w := range ws {
-		q.PushOrUpdate(w)
+		q.PushOrUpdate(workload.NewInfo(w))
 	}
 	got := q.Pop()
 	if got == nil {
@@ -71,12 +73,12 @@ func TestFIFOClusterQueue(t *testing.T) {
 	if got.Obj.Name != "before" {
 		t.Errorf("Popped workload %q want %q", got.Obj.Name, "before")
 	}
-	q.PushOrUpdate(&kueue.Workload{
+	q.PushOrUpdate(workload.NewInfo(&kueue.Workload{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:              "after",
 			CreationTimestamp: metav1.NewTime(now.Add(-time.Minute)),
 		},
-	})
+	}))
 	got = q.Pop()
 	if got == nil {
 		t.Fatal("Queue is empty")
@@ -167,6 +169,99 @@ func TestStrictFIFO(t *testing.T) {
 			},
 			expected: "w2",
 		},
+		{
+			name: "w1.priority equals w2.priority and w1.create time equals w2.create time",
+			w1: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w1",
+					CreationTimestamp: metav1.NewTime(t1),
+				},
+			},
+			w2: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w2",
+					CreationTimestamp: metav1.NewTime(t1),
+				},
+			},
+			expected: "w1",
+		},
+		{
+			name: "w1.priority equals w2.priority and w1 was evicted by the PodsReady timeout after w2.create time",
+			w1: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w1",
+					CreationTimestamp: metav1.NewTime(t1),
+				},
+				Status: kueue.WorkloadStatus{
+					Conditions: []kueue.WorkloadCondition{
+						{
+							Type:               kueue.WorkloadEvicted,
+							Status:             corev1.ConditionTrue,
+							LastTransitionTime: metav1.NewTime(t2.Add(time.Second)),
+							Reason:             workload.EvictedByPodsReadyTimeout,
+						},
+					},
+				},
+			},
+			w2: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w2",
+					CreationTimestamp: metav1.NewTime(t2),
+				},
+			},
+			expected: "w2",
+		},
+		{
+			name: "w1.priority equals w2.priority and w1 was preempted after w2.create time",
+			w1: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w1",
+					CreationTimestamp: metav1.NewTime(t1),
+				},
+				Status: kueue.WorkloadStatus{
+					Conditions: []kueue.WorkloadCondition{
+						{
+							Type:               kueue.WorkloadEvicted,
+							Status:             corev1.ConditionTrue,
+							LastTransitionTime: metav1.NewTime(t2.Add(time.Second)),
+							Reason:             workload.EvictedByPreemption,
+						},
+					},
+				},
+			},
+			w2: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w2",
+					CreationTimestamp: metav1.NewTime(t2),
+				},
+			},
+			expected: "w1",
+		},
+		{
+			name: "w2 is boosted and w1.priority is higher than w2.priority",
+			w1: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w1",
+					CreationTimestamp: metav1.NewTime(t1),
+				},
+				Spec: kueue.WorkloadSpec{
+					PriorityClassName: "highPriority",
+					Priority:          pointer.Int32(highPriority),
+				},
+			},
+			w2: &kueue.Workload{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "w2",
+					CreationTimestamp: metav1.NewTime(t2),
+					Annotations:       map[string]string{kueue.PriorityBoostAnnotation: "true"},
+				},
+				Spec: kueue.WorkloadSpec{
+					PriorityClassName: "lowPriority",
+					Priority:          pointer.Int32(lowPriority),
+				},
+			},
+			expected: "w2",
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			q, err := newClusterQueue(&kueue.ClusterQueue{
@@ -178,8 +273,8 @@ func TestStrictFIFO(t *testing.T) {
 				t.Fatalf("Failed creating ClusterQueue %v", err)
 			}
 
-			q.PushOrUpdate(tt.w1)
-			q.PushOrUpdate(tt.w2)
+			q.PushOrUpdate(workload.NewInfo(tt.w1))
+			q.PushOrUpdate(workload.NewInfo(tt.w2))
 
 			got := q.Pop()
 			if got == nil {
@@ -191,3 +286,51 @@ func TestStrictFIFO(t *testing.T) {
 		})
 	}
 }
+
+func TestStrictFIFOPriorityUpdate(t *testing.T) {
+	q, err := newClusterQueue(&kueue.ClusterQueue{
+		Spec: kueue.ClusterQueueSpec{
+			QueueingStrategy: kueue.StrictFIFO,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed creating ClusterQueue %v", err)
+	}
+	now := metav1.Now()
+	ws := []*kueue.Workload{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "before",
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Second)),
+			},
+			Spec: kueue.WorkloadSpec{
+				Priority: pointer.Int32(lowPriority),
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "after",
+				CreationTimestamp: now,
+			},
+			Spec: kueue.WorkloadSpec{
+				Priority: pointer.Int32(lowPriority),
+			},
+		},
+	}
+	for _, w := range ws {
+		q.PushOrUpdate(workload.NewInfo(w))
+	}
+	updated := ws[1].DeepCopy()
+	updated.Spec.Priority = pointer.Int32(highPriority)
+	q.PushOrUpdate(workload.NewInfo(updated))
+
+	for _, want := range []string{"after", "before"} {
+		got := q.Pop()
+		if got == nil {
+			t.Fatal("Queue is empty")
+		}
+		if got.Obj.Name != want {
+			t.Errorf("Popped workload %q want %q", got.Obj.Name, want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sigs.k8s.io/kueue/pkg/util/heap"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// fairQueueingStride is the pass that a group of workloads advances, divided
+// by its weight, every time one of its workloads is popped.
+const fairQueueingStride = 1 << 20
+
+// fairHeap is a workloadHeap that holds a heap per group of workloads and
+// pops from the groups in turns, proportionally to their weights, following
+// stride scheduling.
+type fairHeap struct {
+	keyFunc    func(obj interface{}) string
+	lessFunc   func(a, b interface{}) bool
+	groupFunc  func(*workload.Info) string
+	weightFunc func(group string) int32
+
+	// groups holds the groups that have workloads. A group is removed when
+	// its last workload is popped or deleted.
+	groups map[string]*fairGroup
+	// groupOf maps the key of a workload to its group.
+	groupOf map[string]string
+	// pass is the pass of the last group that was popped. Groups that
+	// become non-empty start from it, so that they don't accumulate turns
+	// while they are empty.
+	pass int64
+}
+
+type fairGroup struct {
+	heap heap.Heap
+	pass int64
+}
+
+var _ workloadHeap = &fairHeap{}
+
+func newFairHeap(keyFunc func(obj interface{}) string, lessFunc func(a, b interface{}) bool, groupFunc func(*workload.Info) string, weightFunc func(string) int32) *fairHeap {
+	return &fairHeap{
+		keyFunc:    keyFunc,
+		lessFunc:   lessFunc,
+		groupFunc:  groupFunc,
+		weightFunc: weightFunc,
+		groups:     make(map[string]*fairGroup),
+		groupOf:    make(map[string]string),
+	}
+}
+
+func (h *fairHeap) PushOrUpdate(obj interface{}) {
+	key := h.keyFunc(obj)
+	group := h.groupFunc(obj.(*workload.Info))
+	if oldGroup, ok := h.groupOf[key]; ok && oldGroup != group {
+		h.Delete(key)
+	}
+	h.groupOf[key] = group
+	h.group(group).heap.PushOrUpdate(obj)
+}
+
+func (h *fairHeap) PushIfNotPresent(obj interface{}) bool {
+	key := h.keyFunc(obj)
+	if _, ok := h.groupOf[key]; ok {
+		return false
+	}
+	group := h.groupFunc(obj.(*workload.Info))
+	h.groupOf[key] = group
+	return h.group(group).heap.PushIfNotPresent(obj)
+}
+
+func (h *fairHeap) group(name string) *fairGroup {
+	g := h.groups[name]
+	if g == nil {
+		g = &fairGroup{
+			heap: heap.New(h.keyFunc, h.lessFunc),
+			pass: h.pass,
+		}
+		h.groups[name] = g
+	}
+	return g
+}
+
+// removeIfEmpty removes the group if it has no workloads left.
+func (h *fairHeap) removeIfEmpty(name string) {
+	if g := h.groups[name]; g != nil && g.heap.Len() == 0 {
+		delete(h.groups, name)
+	}
+}
+
+func (h *fairHeap) Delete(key string) {
+	group, ok := h.groupOf[key]
+	if !ok {
+		return
+	}
+	delete(h.groupOf, key)
+	h.groups[group].heap.Delete(key)
+	h.removeIfEmpty(group)
+}
+
+// Pop returns the head of the group with the lowest pass. Ties are broken
+// by comparing the heads of the groups.
+func (h *fairHeap) Pop() interface{} {
+	var bestName string
+	var best *fairGroup
+	for name, g := range h.groups {
+		if best == nil || h.before(g.heap.Peek(), g.pass, best.heap.Peek(), best.pass) {
+			bestName, best = name, g
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	obj := best.heap.Pop()
+	delete(h.groupOf, h.keyFunc(obj))
+	h.pass = best.pass
+	best.pass += h.stride(bestName)
+	h.removeIfEmpty(bestName)
+	return obj
+}
+
+// before returns whether the head a of a group with passA goes before the
+// head b of a group with passB. Boosted workloads go first regardless of
+// the turns of their groups.
+func (h *fairHeap) before(a interface{}, passA int64, b interface{}, passB int64) bool {
+	if boostedA, boostedB := isBoosted(a.(*workload.Info).Obj), isBoosted(b.(*workload.Info).Obj); boostedA != boostedB {
+		return boostedA
+	}
+	return passA < passB || (passA == passB && h.lessFunc(a, b))
+}
+
+// stride returns the pass that a group advances when one of its workloads
+// is popped.
+func (h *fairHeap) stride(group string) int64 {
+	weight := int64(h.weightFunc(group))
+	if weight < 1 {
+		weight = 1
+	}
+	return fairQueueingStride / weight
+}
+
+func (h *fairHeap) GetByKey(key string) interface{} {
+	group, ok := h.groupOf[key]
+	if !ok {
+		return nil
+	}
+	return h.groups[group].heap.GetByKey(key)
+}
+
+func (h *fairHeap) Len() int {
+	return len(h.groupOf)
+}
+
+func (h *fairHeap) List() []interface{} {
+	list := make([]interface{}, 0, h.Len())
+	for _, g := range h.groups {
+		list = append(list, g.heap.List()...)
+	}
+	return list
+}
+
+// Sorted returns the workloads in the order in which they would be popped,
+// simulating the turns of the groups without modifying them.
+func (h *fairHeap) Sorted() []interface{} {
+	type turn struct {
+		items []interface{}
+		pass  int64
+	}
+	turns := make(map[string]*turn, len(h.groups))
+	for name, g := range h.groups {
+		turns[name] = &turn{items: g.heap.Sorted(), pass: g.pass}
+	}
+	list := make([]interface{}, 0, h.Len())
+	for len(turns) > 0 {
+		var bestName string
+		var best *turn
+		for name, t := range turns {
+			if best == nil || h.before(t.items[0], t.pass, best.items[0], best.pass) {
+				bestName, best = name, t
+			}
+		}
+		list = append(list, best.items[0])
+		best.items = best.items[1:]
+		best.pass += h.stride(bestName)
+		if len(best.items) == 0 {
+			delete(turns, bestName)
+		}
+	}
+	return list
+}
@@ -21,7 +21,9 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,14 +34,23 @@ import (
 )
 
 const (
-	workloadQueueKey     = "spec.queueName"
-	queueClusterQueueKey = "spec.clusterQueue"
+	// WorkloadQueueKey is the index of the Workloads by their Queue name.
+	WorkloadQueueKey = "spec.queueName"
+	// QueueClusterQueueKey is the index of the Queues by their ClusterQueue
+	// name.
+	QueueClusterQueueKey = "spec.clusterQueue"
 )
 
 var (
-	errQueueDoesNotExist         = errors.New("queue doesn't exist")
-	errClusterQueueDoesNotExist  = errors.New("clusterQueue doesn't exist")
-	errClusterQueueAlreadyExists = errors.New("clusterQueue already exists")
+	errQueueDoesNotExist        = errors.New("queue doesn't exist")
+	errClusterQueueDoesNotExist = errors.New("clusterQueue doesn't exist")
+
+	// ErrQueueAlreadyExists is returned when adding a Queue that the manager
+	// already has.
+	ErrQueueAlreadyExists = errors.New("queue already exists")
+	// ErrClusterQueueAlreadyExists is returned when adding a ClusterQueue that
+	// the manager already has.
+	ErrClusterQueueAlreadyExists = errors.New("clusterQueue already exists")
 )
 
 type Manager struct {
@@ -52,14 +63,98 @@ type Manager struct {
 
 	// Key is cohort's name. Value is a set of associated ClusterQueue names.
 	cohorts map[string]sets.String
+	// Key is cohort's name. Value is the name of its parent, as set by the
+	// Cohort object.
+	cohortParents map[string]string
+
+	// Key is the workload key. Value is the backoff of a pending workload
+	// that was found inadmissible.
+	backoffs map[string]*backoff
+
+	// Key is the ClusterQueue name. Value is the timer that refreshes the
+	// aged priorities of its pending workloads.
+	agingTimers map[string]*agingTimer
+
+	// inadmissibleRequeuing determines which events move the inadmissible
+	// workloads back to their ClusterQueues.
+	inadmissibleRequeuing InadmissibleRequeuing
+	// inadmissibleTimeout is the time after which the inadmissible workloads
+	// are moved back to their ClusterQueues with the Timeout strategy.
+	inadmissibleTimeout time.Duration
+	// Key is the workload key. Value is the timer that moves the
+	// inadmissible workload back to its ClusterQueue with the Timeout
+	// strategy.
+	inadmissibleTimers map[string]*time.Timer
+
+	workloadInfoOptions []workload.InfoOption
+	statusChecker       StatusChecker
 }
 
-func NewManager(client client.Client) *Manager {
+// StatusChecker reports whether the ClusterQueues can admit new workloads.
+type StatusChecker interface {
+	// ClusterQueueActive returns whether the ClusterQueue can admit new
+	// workloads.
+	ClusterQueueActive(name string) bool
+}
+
+type options struct {
+	workloadInfoOptions   []workload.InfoOption
+	statusChecker         StatusChecker
+	inadmissibleRequeuing InadmissibleRequeuing
+	inadmissibleTimeout   time.Duration
+}
+
+// Option configures the manager.
+type Option func(*options)
+
+// WithWorkloadInfoOptions sets the options to calculate the requests of the
+// pending workloads.
+func WithWorkloadInfoOptions(opts ...workload.InfoOption) Option {
+	return func(o *options) {
+		o.workloadInfoOptions = opts
+	}
+}
+
+// WithStatusChecker sets the checker of the ClusterQueues that can admit new
+// workloads. The workloads of the inactive ClusterQueues are not returned by
+// Heads.
+func WithStatusChecker(c StatusChecker) Option {
+	return func(o *options) {
+		o.statusChecker = c
+	}
+}
+
+// WithInadmissibleRequeuing sets the strategy to move the inadmissible
+// workloads back to their ClusterQueues and, for the Timeout strategy, the
+// time after which they are moved.
+func WithInadmissibleRequeuing(strategy InadmissibleRequeuing, timeout time.Duration) Option {
+	return func(o *options) {
+		o.inadmissibleRequeuing = strategy
+		o.inadmissibleTimeout = timeout
+	}
+}
+
+func NewManager(client client.Client, opts ...Option) *Manager {
+	options := options{
+		inadmissibleRequeuing: RequeueImmediate,
+		inadmissibleTimeout:   DefaultInadmissibleTimeout,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
 	m := &Manager{
-		client:        client,
-		queues:        make(map[string]*Queue),
-		clusterQueues: make(map[string]ClusterQueue),
-		cohorts:       make(map[string]sets.String),
+		client:                client,
+		queues:                make(map[string]*Queue),
+		clusterQueues:         make(map[string]ClusterQueue),
+		cohorts:               make(map[string]sets.String),
+		cohortParents:         make(map[string]string),
+		backoffs:              make(map[string]*backoff),
+		agingTimers:           make(map[string]*agingTimer),
+		inadmissibleRequeuing: options.inadmissibleRequeuing,
+		inadmissibleTimeout:   options.inadmissibleTimeout,
+		inadmissibleTimers:    make(map[string]*time.Timer),
+		workloadInfoOptions:   options.workloadInfoOptions,
+		statusChecker:         options.statusChecker,
 	}
 	m.cond.L = &m.RWMutex
 	return m
@@ -70,7 +165,7 @@ func (m *Manager) AddClusterQueue(ctx context.Context, cq *kueue.ClusterQueue) e
 	defer m.Unlock()
 
 	if _, ok := m.clusterQueues[cq.Name]; ok {
-		return errClusterQueueAlreadyExists
+		return ErrClusterQueueAlreadyExists
 	}
 
 	cqImpl, err := newClusterQueue(cq)
@@ -78,19 +173,23 @@ func (m *Manager) AddClusterQueue(ctx context.Context, cq *kueue.ClusterQueue) e
 		return err
 	}
 
+	// Iterate through existing queues, as queues corresponding to this cluster
+	// queue might have been added earlier. They are listed before adding the
+	// cluster queue, so that a failure leaves the manager unchanged and the
+	// operation can be retried.
+	var queues kueue.QueueList
+	if err := m.client.List(ctx, &queues, client.MatchingFields{QueueClusterQueueKey: cq.Name}); err != nil {
+		return fmt.Errorf("listing queues pointing to the cluster queue: %w", err)
+	}
+
 	m.clusterQueues[cq.Name] = cqImpl
+	m.updateAgingTimer(cq.Name, cqImpl)
 
 	cohort := cq.Spec.Cohort
 	if cohort != "" {
 		m.addCohort(cohort, cq.Name)
 	}
 
-	// Iterate through existing queues, as queues corresponding to this cluster
-	// queue might have been added earlier.
-	var queues kueue.QueueList
-	if err := m.client.List(ctx, &queues, client.MatchingFields{queueClusterQueueKey: cq.Name}); err != nil {
-		return fmt.Errorf("listing queues pointing to the cluster queue: %w", err)
-	}
 	addedWorkloads := false
 	for _, q := range queues.Items {
 		// Checking clusterQueue name again because the field index is not available in tests.
@@ -104,7 +203,7 @@ func (m *Manager) AddClusterQueue(ctx context.Context, cq *kueue.ClusterQueue) e
 		}
 	}
 
-	queued := m.queueAllInadmissibleWorkloadsInCohort(cqImpl)
+	queued := m.queueAllInadmissibleWorkloadsInCohort(cq.Name)
 	if queued || addedWorkloads {
 		m.cond.Broadcast()
 	}
@@ -119,16 +218,18 @@ func (m *Manager) UpdateClusterQueue(cq *kueue.ClusterQueue) error {
 		return errClusterQueueDoesNotExist
 	}
 	oldCohort := cqImpl.Cohort()
+	wasStopped := cqImpl.Stopped()
 
 	// TODO(#8): recreate heap based on a change of queueing policy.
 	cqImpl.Update(cq)
+	m.updateAgingTimer(cq.Name, cqImpl)
 	newCohort := cqImpl.Cohort()
 	if oldCohort != newCohort {
 		m.updateCohort(oldCohort, newCohort, cq.Name)
 	}
 
 	// TODO(#8): Selectively move workloads based on the exact event.
-	if m.queueAllInadmissibleWorkloadsInCohort(cqImpl) {
+	if m.queueAllInadmissibleWorkloadsInCohort(cq.Name) || (wasStopped && !cqImpl.Stopped()) {
 		m.cond.Broadcast()
 	}
 
@@ -143,6 +244,7 @@ func (m *Manager) DeleteClusterQueue(cq *kueue.ClusterQueue) {
 		return
 	}
 	delete(m.clusterQueues, cq.Name)
+	m.stopAgingTimer(cq.Name)
 
 	cohort := cq.Spec.Cohort
 	if cohort != "" {
@@ -150,29 +252,62 @@ func (m *Manager) DeleteClusterQueue(cq *kueue.ClusterQueue) {
 	}
 }
 
+// AddOrUpdateCohort starts tracking the parent of a Cohort or replaces the
+// tracked one. The workloads in the hierarchies that the Cohort leaves and
+// joins might fit under its new parent and limits.
+func (m *Manager) AddOrUpdateCohort(co *kueue.Cohort) {
+	m.Lock()
+	defer m.Unlock()
+	oldRoot := m.rootCohort(co.Name)
+	if co.Spec.Parent == "" {
+		delete(m.cohortParents, co.Name)
+	} else {
+		m.cohortParents[co.Name] = co.Spec.Parent
+	}
+	queued := m.queueInadmissibleWorkloadsUnderRoot(oldRoot, nil)
+	if newRoot := m.rootCohort(co.Name); newRoot != oldRoot {
+		queued = m.queueInadmissibleWorkloadsUnderRoot(newRoot, nil) || queued
+	}
+	if queued {
+		m.cond.Broadcast()
+	}
+}
+
+// DeleteCohort stops tracking the parent of a Cohort.
+func (m *Manager) DeleteCohort(co *kueue.Cohort) {
+	m.Lock()
+	defer m.Unlock()
+	oldRoot := m.rootCohort(co.Name)
+	delete(m.cohortParents, co.Name)
+	if m.queueInadmissibleWorkloadsUnderRoot(oldRoot, nil) {
+		m.cond.Broadcast()
+	}
+}
+
 func (m *Manager) AddQueue(ctx context.Context, q *kueue.Queue) error {
 	m.Lock()
 	defer m.Unlock()
 
 	key := Key(q)
 	if _, ok := m.queues[key]; ok {
-		return fmt.Errorf("queue %q already exists", q.Name)
+		return ErrQueueAlreadyExists
 	}
-	qImpl := newQueue(q)
-	m.queues[key] = qImpl
 	// Iterate through existing workloads, as workloads corresponding to this
-	// queue might have been added earlier.
+	// queue might have been added earlier. They are listed before adding the
+	// queue, so that a failure leaves the manager unchanged.
 	var workloads kueue.WorkloadList
-	if err := m.client.List(ctx, &workloads, client.MatchingFields{workloadQueueKey: q.Name}, client.InNamespace(q.Namespace)); err != nil {
+	if err := m.client.List(ctx, &workloads, client.MatchingFields{WorkloadQueueKey: q.Name}, client.InNamespace(q.Namespace)); err != nil {
 		return fmt.Errorf("listing workloads that match the queue: %w", err)
 	}
+	qImpl := newQueue(q)
+	m.queues[key] = qImpl
 	for _, w := range workloads.Items {
 		w := w
 		// Checking queue name again because the field index is not available in tests.
-		if w.Spec.QueueName != q.Name || w.Spec.Admission != nil {
+		if w.Spec.QueueName != q.Name || workload.HasQuotaReservation(&w) || !workload.IsActive(&w) {
 			continue
 		}
-		qImpl.AddOrUpdate(&w)
+		qImpl.AddOrUpdate(workload.NewInfo(&w, m.workloadInfoOptions...))
 	}
 	cq := m.clusterQueues[qImpl.ClusterQueue]
 	if cq != nil && cq.AddFromQueue(qImpl) {
@@ -193,12 +328,25 @@ func (m *Manager) UpdateQueue(q *kueue.Queue) error {
 		if oldCQ != nil {
 			oldCQ.DeleteFromQueue(qImpl)
 		}
+		// The pending workloads are evaluated in the new ClusterQueue right
+		// away, even the ones that were waiting in backoff.
+		for key := range qImpl.items {
+			m.resetBackoff(key)
+		}
 		newCQ := m.clusterQueues[string(q.Spec.ClusterQueue)]
 		if newCQ != nil && newCQ.AddFromQueue(qImpl) {
 			m.cond.Broadcast()
 		}
 	}
+	oldMax := qImpl.MaxAdmittedWorkloads
 	qImpl.update(q)
+	if !equality.Semantic.DeepEqual(oldMax, qImpl.MaxAdmittedWorkloads) {
+		// Workloads of this queue might have been inadmissible because of the
+		// old limit.
+		if m.queueAllInadmissibleWorkloadsInCohort(qImpl.ClusterQueue) {
+			m.cond.Broadcast()
+		}
+	}
 	return nil
 }
 
@@ -232,7 +380,7 @@ func (m *Manager) PendingWorkloads(q *kueue.Queue) (int32, error) {
 func (m *Manager) Pending(cq *kueue.ClusterQueue) int32 {
 	m.RLock()
 	defer m.RUnlock()
-	return m.clusterQueues[cq.Name].Pending()
+	return m.clusterQueues[cq.Name].Pending() + m.waitingInBackoff(cq.Name)
 }
 
 func (m *Manager) QueueForWorkloadExists(wl *kueue.Workload) bool {
@@ -271,12 +419,24 @@ func (m *Manager) addOrUpdateWorkload(w *kueue.Workload) bool {
 	if q == nil {
 		return false
 	}
-	q.AddOrUpdate(w)
+	// A deactivated workload stays out of the queues until it's activated.
+	if !workload.IsActive(w) {
+		m.deleteWorkloadFromQueueAndClusterQueue(w, qKey)
+		m.resetBackoff(workload.Key(w))
+		return true
+	}
+	wInfo := workload.NewInfo(w, m.workloadInfoOptions...)
+	q.AddOrUpdate(wInfo)
 	cq := m.clusterQueues[q.ClusterQueue]
 	if cq == nil {
 		return false
 	}
-	cq.PushOrUpdate(w)
+	// A workload waiting in backoff stays out of the ClusterQueue.
+	if b := m.backoffs[workload.Key(w)]; b != nil && b.waiting() {
+		b.info = wInfo
+		return true
+	}
+	cq.PushOrUpdate(wInfo)
 	m.cond.Broadcast()
 	return true
 }
@@ -284,6 +444,9 @@ func (m *Manager) addOrUpdateWorkload(w *kueue.Workload) bool {
 // RequeueWorkload requeues the workload ensuring that the queue and the
 // workload still exist in the client cache and it's not admitted. It won't
 // requeue if the workload is already in the queue (possible if the workload was updated).
+// If the workload was found inadmissible (immediate is false) more than once
+// in a row, it waits an exponential backoff before going back to its
+// ClusterQueue.
 func (m *Manager) RequeueWorkload(ctx context.Context, info *workload.Info, immediate bool) bool {
 	m.Lock()
 	defer m.Unlock()
@@ -296,7 +459,7 @@ func (m *Manager) RequeueWorkload(ctx context.Context, info *workload.Info, imme
 	var w kueue.Workload
 	err := m.client.Get(ctx, client.ObjectKeyFromObject(info.Obj), &w)
 	// Since the client is cached, the only possible error is NotFound
-	if apierrors.IsNotFound(err) || w.Spec.Admission != nil {
+	if apierrors.IsNotFound(err) || workload.HasQuotaReservation(&w) || !workload.IsActive(&w) {
 		return false
 	}
 
@@ -306,8 +469,14 @@ func (m *Manager) RequeueWorkload(ctx context.Context, info *workload.Info, imme
 		return false
 	}
 
+	if !immediate && m.backOff(info, cq) {
+		return true
+	}
 	added := cq.RequeueIfNotPresent(info, immediate)
 	if added {
+		if !immediate {
+			m.startInadmissibleTimer(info)
+		}
 		m.cond.Broadcast()
 	}
 	return added
@@ -316,9 +485,28 @@ func (m *Manager) RequeueWorkload(ctx context.Context, info *workload.Info, imme
 func (m *Manager) DeleteWorkload(w *kueue.Workload) {
 	m.Lock()
 	m.deleteWorkloadFromQueueAndClusterQueue(w, queueKeyForWorkload(w))
+	m.resetBackoff(workload.Key(w))
+	m.stopInadmissibleTimer(workload.Key(w))
 	m.Unlock()
 }
 
+// Backoff returns the number of consecutive attempts in which the workload
+// was found inadmissible and, if the workload waits before the next attempt,
+// the time of the next attempt.
+func (m *Manager) Backoff(w *kueue.Workload) (int32, *time.Time) {
+	m.RLock()
+	defer m.RUnlock()
+	b := m.backoffs[workload.Key(w)]
+	if b == nil {
+		return 0, nil
+	}
+	if !b.waiting() {
+		return b.attempts, nil
+	}
+	nextAttempt := b.nextAttempt
+	return b.attempts, &nextAttempt
+}
+
 func (m *Manager) deleteWorkloadFromQueueAndClusterQueue(w *kueue.Workload, qKey string) {
 	q := m.queues[qKey]
 	if q == nil {
@@ -331,9 +519,11 @@ func (m *Manager) deleteWorkloadFromQueueAndClusterQueue(w *kueue.Workload, qKey
 	}
 }
 
-// QueueAssociatedInadmissibleWorkloads moves all associated workloads from
-// inadmissibleWorkloads to heap. If at least one workload is moved,
-// returns true. Otherwise returns false.
+// QueueAssociatedInadmissibleWorkloads moves the workloads from
+// inadmissibleWorkloads to heap that might be admissible after an event of
+// the given workload, following the InadmissibleRequeuing strategy. If the
+// workload has an admission, the event freed its quota. Otherwise, the event
+// made the workload itself admissible.
 func (m *Manager) QueueAssociatedInadmissibleWorkloads(w *kueue.Workload) {
 	m.Lock()
 	defer m.Unlock()
@@ -343,39 +533,106 @@ func (m *Manager) QueueAssociatedInadmissibleWorkloads(w *kueue.Workload) {
 		return
 	}
 
-	cq := m.clusterQueues[q.ClusterQueue]
-	if cq == nil {
+	match, ok := m.associatedInadmissibleWorkloads(w)
+	if !ok {
 		return
 	}
-
-	if m.queueAllInadmissibleWorkloadsInCohort(cq) {
+	if m.queueInadmissibleWorkloadsInCohort(q.ClusterQueue, match) {
 		m.cond.Broadcast()
 	}
 }
 
+// QueueInadmissibleWorkloads moves the inadmissible workloads of the cohorts
+// of the given ClusterQueues back to the queues, for example when the
+// ClusterQueues become active. It wakes up Heads, which skips the workloads
+// of the inactive ClusterQueues.
+func (m *Manager) QueueInadmissibleWorkloads(cqNames []string) {
+	m.Lock()
+	defer m.Unlock()
+	if len(cqNames) == 0 {
+		return
+	}
+	for _, name := range cqNames {
+		m.queueAllInadmissibleWorkloadsInCohort(name)
+	}
+	m.cond.Broadcast()
+}
+
 // queueAllInadmissibleWorkloadsInCohort moves all workloads in the same
-// cohort with this ClusterQueue from inadmissibleWorkloads to heap. If the
-// cohort of this ClusterQueue is empty, it just moves all workloads in this
-// ClusterQueue. If at least one workload is moved, returns true. Otherwise
-// returns false.
+// cohort hierarchy with this ClusterQueue from inadmissibleWorkloads, or from
+// their backoff, to heap.
+// If the cohort of this ClusterQueue is empty, it just moves all workloads in
+// this ClusterQueue. If at least one workload is moved, returns true.
+// Otherwise returns false.
 // The events listed below could make workloads in the same cohort admissible.
 // Then queueAllInadmissibleWorkloadsInCohort need to be invoked.
 // 1. delete events for any admitted workload in the cohort.
 // 2. add events of any cluster queue in the cohort.
 // 3. update events of any cluster queue in the cohort.
-func (m *Manager) queueAllInadmissibleWorkloadsInCohort(cq ClusterQueue) bool {
+func (m *Manager) queueAllInadmissibleWorkloadsInCohort(cqName string) bool {
+	return m.queueInadmissibleWorkloadsInCohort(cqName, nil)
+}
+
+// queueInadmissibleWorkloadsInCohort is queueAllInadmissibleWorkloadsInCohort
+// for the workloads that match, or all of them if match is nil.
+func (m *Manager) queueInadmissibleWorkloadsInCohort(cqName string, match func(*workload.Info) bool) bool {
+	cq := m.clusterQueues[cqName]
+	if cq == nil {
+		return false
+	}
 	cohort := cq.Cohort()
 	if cohort == "" {
-		return cq.QueueInadmissibleWorkloads()
+		queued := cq.QueueInadmissibleWorkloads(match)
+		return m.releaseBackoffs(sets.NewString(cqName), match) || queued
 	}
 
+	return m.queueInadmissibleWorkloadsUnderRoot(m.rootCohort(cohort), match)
+}
+
+// queueInadmissibleWorkloadsUnderRoot moves the workloads of the
+// ClusterQueues in the cohorts under the given root that match, or all of
+// them if match is nil, from inadmissibleWorkloads to heap. Returns whether
+// at least one workload is moved.
+func (m *Manager) queueInadmissibleWorkloadsUnderRoot(root string, match func(*workload.Info) bool) bool {
 	queued := false
-	for cqName := range m.cohorts[cohort] {
-		if clusterQueue, ok := m.clusterQueues[cqName]; ok {
-			queued = clusterQueue.QueueInadmissibleWorkloads() || queued
+	underRoot := sets.NewString()
+	for cohort, cqNames := range m.cohorts {
+		if m.rootCohort(cohort) != root {
+			continue
+		}
+		for cqName := range cqNames {
+			if clusterQueue, ok := m.clusterQueues[cqName]; ok {
+				queued = clusterQueue.QueueInadmissibleWorkloads(match) || queued
+				underRoot.Insert(cqName)
+			}
 		}
 	}
-	return queued
+	return m.releaseBackoffs(underRoot, match) || queued
+}
+
+// rootCohort returns the root of the hierarchy of the cohort. If the parents
+// form a cycle, it returns the smallest name in the cycle, so that all the
+// cohorts that lead to the cycle share it.
+func (m *Manager) rootCohort(cohort string) string {
+	visited := sets.NewString()
+	for {
+		parent, ok := m.cohortParents[cohort]
+		if !ok {
+			return cohort
+		}
+		if visited.Has(cohort) {
+			break
+		}
+		visited.Insert(cohort)
+		cohort = parent
+	}
+	root := cohort
+	for c := m.cohortParents[cohort]; c != cohort; c = m.cohortParents[c] {
+		if c < root {
+			root = c
+		}
+	}
+	return root
 }
 
 // UpdateWorkload updates the workload to the corresponding queue or adds it if
@@ -386,6 +643,11 @@ func (m *Manager) UpdateWorkload(oldW, w *kueue.Workload) bool {
 	if oldW.Spec.QueueName != w.Spec.QueueName {
 		m.deleteWorkloadFromQueueAndClusterQueue(w, queueKeyForWorkload(oldW))
 	}
+	// A workload with a different spec might be admissible, and a boosted
+	// workload shouldn't wait for its backoff.
+	if !equality.Semantic.DeepEqual(oldW.Spec, w.Spec) || isBoosted(oldW) != isBoosted(w) {
+		m.resetBackoff(workload.Key(w))
+	}
 	return m.addOrUpdateWorkload(w)
 }
 
@@ -394,6 +656,10 @@ func (m *Manager) UpdateWorkload(oldW, w *kueue.Workload) bool {
 // Heads.
 func (m *Manager) CleanUpOnContext(ctx context.Context) {
 	<-ctx.Done()
+	// Holding the lock guarantees that Heads is either waiting, or it will
+	// observe that the context is done.
+	m.Lock()
+	defer m.Unlock()
 	m.cond.Broadcast()
 }
 
@@ -438,9 +704,81 @@ func (m *Manager) Dump() map[string]sets.String {
 	return dump
 }
 
+// InSync returns whether the manager reflects the given Queues, ClusterQueues
+// and Workloads, as listed from the API server: it has the same Queues and
+// ClusterQueues, and every workload it holds is pending in the same Queue.
+// Pending workloads might be missing from the manager while the scheduler
+// evaluates them, so they are not required to be present.
+// It allows tests to wait for the event handlers to catch up.
+func (m *Manager) InSync(queues []kueue.Queue, cqs []kueue.ClusterQueue, wls []kueue.Workload) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	if len(queues) != len(m.queues) || len(cqs) != len(m.clusterQueues) {
+		return false
+	}
+	for i := range queues {
+		if _, ok := m.queues[Key(&queues[i])]; !ok {
+			return false
+		}
+	}
+	for i := range cqs {
+		if _, ok := m.clusterQueues[cqs[i].Name]; !ok {
+			return false
+		}
+	}
+	pending := make(map[string]string)
+	for i := range wls {
+		w := &wls[i]
+		if !workload.HasQuotaReservation(w) && !workload.InCondition(w, kueue.WorkloadFinished) {
+			pending[workload.Key(w)] = queueKeyForWorkload(w)
+		}
+	}
+	for qKey, q := range m.queues {
+		for key := range q.items {
+			if pending[key] != qKey {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// DumpInadmissible returns the inadmissible workloads of each ClusterQueue,
+// or nil if there are none.
+func (m *Manager) DumpInadmissible() map[string]sets.String {
+	m.Lock()
+	defer m.Unlock()
+	dump := make(map[string]sets.String)
+	for key, cq := range m.clusterQueues {
+		if elements, ok := cq.DumpInadmissible(); ok {
+			dump[key] = elements
+		}
+	}
+	if len(dump) == 0 {
+		return nil
+	}
+	return dump
+}
+
 func (m *Manager) heads() []workload.Info {
 	var workloads []workload.Info
 	for cqName, cq := range m.clusterQueues {
+		// The workloads of a stopped ClusterQueue wait in its heap until the
+		// ClusterQueue is resumed.
+		if cq.Stopped() {
+			continue
+		}
+		// Likewise, the workloads of an inactive ClusterQueue wait until the
+		// ResourceFlavors that it references are created.
+		if m.statusChecker != nil && !m.statusChecker.ClusterQueueActive(cqName) {
+			continue
+		}
+		// In StrictFIFO, a workload waiting in backoff still blocks the
+		// workloads behind it.
+		if isStrictFIFO(cq) && m.waitingInBackoff(cqName) > 0 {
+			continue
+		}
 		wl := cq.Pop()
 		if wl == nil {
 			continue
@@ -476,14 +814,14 @@ func (m *Manager) updateCohort(oldCohort string, newCohort string, cqName string
 }
 
 func SetupIndexes(indexer client.FieldIndexer) error {
-	err := indexer.IndexField(context.Background(), &kueue.Workload{}, workloadQueueKey, func(o client.Object) []string {
+	err := indexer.IndexField(context.Background(), &kueue.Workload{}, WorkloadQueueKey, func(o client.Object) []string {
 		wl := o.(*kueue.Workload)
 		return []string{wl.Spec.QueueName}
 	})
 	if err != nil {
 		return fmt.Errorf("setting index on queue for Workload: %w", err)
 	}
-	err = indexer.IndexField(context.Background(), &kueue.Queue{}, queueClusterQueueKey, func(o client.Object) []string {
+	err = indexer.IndexField(context.Background(), &kueue.Queue{}, QueueClusterQueueKey, func(o client.Object) []string {
 		q := o.(*kueue.Queue)
 		return []string{string(q.Spec.ClusterQueue)}
 	})
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// Interface is the set of operations of the queue manager that the
+// controllers and the scheduler depend on. Manager is the implementation
+// used in production.
+type Interface interface {
+	// AddClusterQueue starts tracking a ClusterQueue and the pending workloads
+	// of the Queues pointing to it.
+	AddClusterQueue(context.Context, *kueue.ClusterQueue) error
+	// UpdateClusterQueue updates the properties of a tracked ClusterQueue.
+	UpdateClusterQueue(*kueue.ClusterQueue) error
+	// DeleteClusterQueue stops tracking a ClusterQueue.
+	DeleteClusterQueue(*kueue.ClusterQueue)
+	// Pending returns the number of pending workloads in a ClusterQueue.
+	Pending(*kueue.ClusterQueue) int32
+
+	// AddOrUpdateCohort starts tracking the parent of a Cohort or replaces
+	// the tracked one.
+	AddOrUpdateCohort(*kueue.Cohort)
+	// DeleteCohort stops tracking the parent of a Cohort.
+	DeleteCohort(*kueue.Cohort)
+
+	// AddQueue starts tracking a Queue and its pending workloads.
+	AddQueue(context.Context, *kueue.Queue) error
+	// UpdateQueue updates the properties of a tracked Queue.
+	UpdateQueue(*kueue.Queue) error
+	// DeleteQueue stops tracking a Queue.
+	DeleteQueue(*kueue.Queue)
+	// PendingWorkloads returns the number of pending workloads in a Queue.
+	PendingWorkloads(*kueue.Queue) (int32, error)
+	// ClusterQueuePendingWorkloads returns the pending workloads of a
+	// ClusterQueue, in order. Returns false if the ClusterQueue is not
+	// tracked.
+	ClusterQueuePendingWorkloads(name string) ([]PendingWorkload, bool)
+	// QueuePendingWorkloads returns the pending workloads of a Queue, in
+	// order. Returns false if the Queue is not tracked.
+	QueuePendingWorkloads(namespace, name string) ([]PendingWorkload, bool)
+
+	// QueueForWorkloadExists returns whether the Queue of the workload is
+	// tracked.
+	QueueForWorkloadExists(*kueue.Workload) bool
+	// ClusterQueueForWorkload returns the name of the ClusterQueue where the
+	// workload should be queued and whether it exists.
+	ClusterQueueForWorkload(*kueue.Workload) (string, bool)
+
+	// AddOrUpdateWorkload adds or updates workload to the corresponding queue.
+	// Returns whether the queue existed.
+	AddOrUpdateWorkload(*kueue.Workload) bool
+	// UpdateWorkload updates the workload to the corresponding queue or adds
+	// it if it didn't exist. Returns whether the queue existed.
+	UpdateWorkload(oldW, w *kueue.Workload) bool
+	// DeleteWorkload removes the workload from its queue.
+	DeleteWorkload(*kueue.Workload)
+	// RequeueWorkload puts back a workload that couldn't be admitted, unless
+	// it's already in the queue. Returns whether the workload was requeued.
+	RequeueWorkload(ctx context.Context, info *workload.Info, immediate bool) bool
+	// Backoff returns the number of consecutive attempts in which the
+	// workload was found inadmissible and, if the workload waits before the
+	// next attempt, the time of the next attempt.
+	Backoff(*kueue.Workload) (int32, *time.Time)
+	// QueueAssociatedInadmissibleWorkloads moves the inadmissible workloads
+	// that might fit after the given workload frees up quota back to the
+	// queues.
+	QueueAssociatedInadmissibleWorkloads(*kueue.Workload)
+	// QueueInadmissibleWorkloads moves the inadmissible workloads of the
+	// cohorts of the given ClusterQueues back to the queues.
+	QueueInadmissibleWorkloads(cqNames []string)
+
+	// Heads returns the heads of the queues, along with their associated
+	// ClusterQueue. It blocks until the queues have elements or the context
+	// terminates.
+	Heads(context.Context) []workload.Info
+}
+
+var _ Interface = &Manager{}
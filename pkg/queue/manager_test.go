@@ -23,9 +23,11 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
@@ -48,6 +50,7 @@ func TestAddQueueOrphans(t *testing.T) {
 		utiltesting.MakeWorkload("c", "earth").Queue("foo").Obj(),
 		utiltesting.MakeWorkload("d", "earth").Queue("foo").
 			Admit(utiltesting.MakeAdmission("cq").Obj()).Obj(),
+		utiltesting.MakeWorkload("e", "earth").Queue("foo").Active(false).Obj(),
 		utiltesting.MakeWorkload("a", "moon").Queue("foo").Obj(),
 	).Build()
 	manager := NewManager(kClient)
@@ -101,6 +104,48 @@ func TestAddClusterQueueOrphans(t *testing.T) {
 	}
 }
 
+// TestAddQueuesListFailure verifies that a failure listing objects leaves the
+// manager unchanged, so that adding the queues can be retried.
+func TestAddQueuesListFailure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	q := utiltesting.MakeQueue("foo", "earth").ClusterQueue("cq").Obj()
+	kClient := utiltesting.NewFaultyClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		utiltesting.MakeWorkload("a", "earth").Queue("foo").Obj(),
+		q,
+	).Build())
+	kClient.Inject(utiltesting.Fault{
+		Op:    utiltesting.OpList,
+		Err:   utiltesting.TimeoutError(),
+		Times: 2,
+	})
+	ctx := context.Background()
+	manager := NewManager(kClient)
+	cq := utiltesting.MakeClusterQueue("cq").Obj()
+	if err := manager.AddQueue(ctx, q); err == nil {
+		t.Fatal("Adding queue succeeded, want the injected error")
+	}
+	if err := manager.AddClusterQueue(ctx, cq); err == nil {
+		t.Fatal("Adding clusterQueue succeeded, want the injected error")
+	}
+	if len(manager.queues) != 0 || len(manager.clusterQueues) != 0 {
+		t.Fatalf("Manager has %d queues and %d clusterQueues after the failures, want none", len(manager.queues), len(manager.clusterQueues))
+	}
+
+	if err := manager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Retrying to add queue: %v", err)
+	}
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Retrying to add clusterQueue: %v", err)
+	}
+	workloads := popNamesFromCQ(manager.clusterQueues[cq.Name])
+	if diff := cmp.Diff([]string{"earth/a"}, workloads); diff != "" {
+		t.Errorf("Unexpected workloads in clusterQueue (-want,+got):\n%s", diff)
+	}
+}
+
 // TestUpdateQueue tests that workloads are transferred between clusterQueues
 // when the queue points to a different clusterQueue.
 func TestUpdateQueue(t *testing.T) {
@@ -158,6 +203,179 @@ func TestUpdateQueue(t *testing.T) {
 	}
 }
 
+// TestUpdateQueueInadmissible tests that the inadmissible workloads and the
+// workloads waiting in backoff move to the new clusterQueue of their queue.
+func TestUpdateQueueInadmissible(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("cq1").QueueingStrategy(kueue.BestEffortFIFO).Obj(),
+		utiltesting.MakeClusterQueue("cq2").QueueingStrategy(kueue.BestEffortFIFO).Obj(),
+	}
+	q := utiltesting.MakeQueue("foo", "").ClusterQueue("cq1").Obj()
+	now := time.Now()
+	workloads := []*kueue.Workload{
+		utiltesting.MakeWorkload("a", "").Queue("foo").Creation(now).Obj(),
+		utiltesting.MakeWorkload("b", "").Queue("foo").Creation(now.Add(-time.Second)).Obj(),
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(workloads[0], workloads[1]).Build()
+	manager := NewManager(cl)
+	ctx, cancel := context.WithTimeout(context.Background(), headsTimeout)
+	defer cancel()
+	for _, cq := range clusterQueues {
+		if err := manager.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Failed adding clusterQueue %s: %v", cq.Name, err)
+		}
+	}
+	if err := manager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Failed adding queue %s: %v", q.Name, err)
+	}
+	inadmissibleAttempt := func(want string) {
+		t.Helper()
+		heads := manager.Heads(ctx)
+		if len(heads) != 1 || workload.Key(heads[0].Obj) != want {
+			t.Fatalf("Got heads %v, want %s", heads, want)
+		}
+		manager.RequeueWorkload(ctx, &heads[0], false)
+	}
+	// "b" is found inadmissible twice, which makes it wait in backoff, and
+	// "a" once, which leaves it inadmissible in the clusterQueue.
+	inadmissibleAttempt("/b")
+	if err := manager.UpdateClusterQueue(clusterQueues[0]); err != nil {
+		t.Fatalf("Failed updating clusterQueue: %v", err)
+	}
+	inadmissibleAttempt("/b")
+	inadmissibleAttempt("/a")
+	if attempts, nextAttempt := manager.Backoff(workloads[1]); attempts != 2 || nextAttempt == nil {
+		t.Fatalf("Backoff() = %d, %v for workload b, want 2 attempts waiting", attempts, nextAttempt)
+	}
+
+	q.Spec.ClusterQueue = "cq2"
+	if err := manager.UpdateQueue(q); err != nil {
+		t.Fatalf("Failed updating queue: %v", err)
+	}
+	wantQueued := map[string]sets.String{"cq2": sets.NewString("a", "b")}
+	if diff := cmp.Diff(wantQueued, manager.Dump()); diff != "" {
+		t.Errorf("Unexpected workloads in the ClusterQueues (-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]sets.String(nil), manager.DumpInadmissible()); diff != "" {
+		t.Errorf("Unexpected inadmissible workloads in the ClusterQueues (-want,+got):\n%s", diff)
+	}
+	if attempts, nextAttempt := manager.Backoff(workloads[1]); attempts != 0 || nextAttempt != nil {
+		t.Errorf("Backoff() = %d, %v for workload b after the update, want 0, nil", attempts, nextAttempt)
+	}
+}
+
+func TestQueueAssociatedInadmissibleWorkloads(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	finished := utiltesting.MakeWorkload("finished", "").Queue("foo").
+		Request(corev1.ResourceCPU, "1").
+		Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()).
+		Obj()
+	cases := map[string]struct {
+		strategy         InadmissibleRequeuing
+		event            *kueue.Workload
+		wantQueued       map[string]sets.String
+		wantInadmissible map[string]sets.String
+	}{
+		"immediate": {
+			strategy:   RequeueImmediate,
+			event:      finished,
+			wantQueued: map[string]sets.String{"cq": sets.NewString("cpu", "gpu")},
+		},
+		"eventual": {
+			strategy:         RequeueEventual,
+			event:            finished,
+			wantQueued:       map[string]sets.String{"cq": sets.NewString("cpu")},
+			wantInadmissible: map[string]sets.String{"cq": sets.NewString("gpu")},
+		},
+		"eventual, the workload itself becomes admissible": {
+			strategy:         RequeueEventual,
+			event:            utiltesting.MakeWorkload("gpu", "").Queue("foo").Obj(),
+			wantQueued:       map[string]sets.String{"cq": sets.NewString("gpu")},
+			wantInadmissible: map[string]sets.String{"cq": sets.NewString("cpu")},
+		},
+		"timeout": {
+			strategy:         RequeueTimeout,
+			event:            finished,
+			wantInadmissible: map[string]sets.String{"cq": sets.NewString("cpu", "gpu")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			workloads := []*kueue.Workload{
+				utiltesting.MakeWorkload("cpu", "").Queue("foo").Request(corev1.ResourceCPU, "1").Obj(),
+				utiltesting.MakeWorkload("gpu", "").Queue("foo").Request("example.com/gpu", "1").Obj(),
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+			manager := NewManager(cl, WithInadmissibleRequeuing(tc.strategy, time.Hour))
+			ctx := context.Background()
+			if err := manager.AddClusterQueue(ctx, utiltesting.MakeClusterQueue("cq").QueueingStrategy(kueue.BestEffortFIFO).Obj()); err != nil {
+				t.Fatalf("Failed adding clusterQueue: %v", err)
+			}
+			if err := manager.AddQueue(ctx, utiltesting.MakeQueue("foo", "").ClusterQueue("cq").Obj()); err != nil {
+				t.Fatalf("Failed adding queue: %v", err)
+			}
+			// The workloads are created after the queue, so that they are
+			// only in the queue as inadmissible.
+			for _, w := range workloads {
+				if err := cl.Create(ctx, w); err != nil {
+					t.Fatalf("Failed creating workload %s: %v", w.Name, err)
+				}
+				if !manager.RequeueWorkload(ctx, workload.NewInfo(w), false) {
+					t.Fatalf("Failed requeuing workload %s", w.Name)
+				}
+			}
+
+			manager.QueueAssociatedInadmissibleWorkloads(tc.event)
+			if diff := cmp.Diff(tc.wantQueued, manager.Dump()); diff != "" {
+				t.Errorf("Unexpected workloads in the ClusterQueues (-want,+got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantInadmissible, manager.DumpInadmissible()); diff != "" {
+				t.Errorf("Unexpected inadmissible workloads in the ClusterQueues (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInadmissibleTimeout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	wl := utiltesting.MakeWorkload("a", "").Queue("foo").Obj()
+	cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := NewManager(cl, WithInadmissibleRequeuing(RequeueTimeout, 100*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), headsTimeout)
+	defer cancel()
+	go manager.CleanUpOnContext(ctx)
+	if err := manager.AddClusterQueue(ctx, utiltesting.MakeClusterQueue("cq").QueueingStrategy(kueue.BestEffortFIFO).Obj()); err != nil {
+		t.Fatalf("Failed adding clusterQueue: %v", err)
+	}
+	if err := manager.AddQueue(ctx, utiltesting.MakeQueue("foo", "").ClusterQueue("cq").Obj()); err != nil {
+		t.Fatalf("Failed adding queue: %v", err)
+	}
+	if err := cl.Create(ctx, wl); err != nil {
+		t.Fatalf("Failed creating workload: %v", err)
+	}
+	if !manager.RequeueWorkload(ctx, workload.NewInfo(wl), false) {
+		t.Fatalf("Failed requeuing the workload")
+	}
+	if diff := cmp.Diff(map[string]sets.String{"cq": sets.NewString("a")}, manager.DumpInadmissible()); diff != "" {
+		t.Errorf("Unexpected inadmissible workloads in the ClusterQueues (-want,+got):\n%s", diff)
+	}
+
+	heads := manager.Heads(ctx)
+	if len(heads) != 1 || heads[0].Obj.Name != "a" {
+		t.Errorf("Got heads %v after the timeout, want the workload", heads)
+	}
+}
+
 func TestAddWorkload(t *testing.T) {
 	scheme := runtime.NewScheme()
 	if err := kueue.AddToScheme(scheme); err != nil {
@@ -421,6 +639,387 @@ func TestRequeueWorkloadStrictFIFO(t *testing.T) {
 	}
 }
 
+func TestRequeueWorkloadBackoff(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cq := utiltesting.MakeClusterQueue("cq").Obj()
+	q := utiltesting.MakeQueue("foo", "").ClusterQueue("cq").Obj()
+	wl := utiltesting.MakeWorkload("a", "").Queue("foo").Obj()
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(wl).Build()
+	manager := NewManager(cl)
+	ctx, cancel := context.WithTimeout(context.Background(), headsTimeout)
+	defer cancel()
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding cluster queue %s: %v", cq.Name, err)
+	}
+	if err := manager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Failed adding queue %s: %v", q.Name, err)
+	}
+	inadmissibleAttempt := func() {
+		t.Helper()
+		heads := manager.Heads(ctx)
+		if len(heads) != 1 {
+			t.Fatalf("Got %d heads, want 1", len(heads))
+		}
+		if !manager.RequeueWorkload(ctx, &heads[0], false) {
+			t.Fatalf("Workload wasn't requeued")
+		}
+	}
+	wantQueued := map[string]sets.String{"cq": sets.NewString("a")}
+
+	// The first inadmissible attempt is retried without delay.
+	inadmissibleAttempt()
+	if diff := cmp.Diff(wantQueued, manager.Dump()); diff != "" {
+		t.Errorf("Unexpected workloads in the ClusterQueues after the first attempt (-want,+got):\n%s", diff)
+	}
+	if attempts, nextAttempt := manager.Backoff(wl); attempts != 1 || nextAttempt != nil {
+		t.Errorf("Backoff() = %d, %v after the first attempt, want 1, nil", attempts, nextAttempt)
+	}
+
+	// The second one waits, out of the ClusterQueue.
+	before := time.Now()
+	inadmissibleAttempt()
+	if dump := manager.Dump(); dump != nil {
+		t.Errorf("Unexpected workloads in the ClusterQueues while in backoff: %v", dump)
+	}
+	if got := manager.Pending(cq); got != 1 {
+		t.Errorf("Pending() = %d while in backoff, want 1", got)
+	}
+	attempts, nextAttempt := manager.Backoff(wl)
+	if attempts != 2 || nextAttempt == nil || nextAttempt.Before(before.Add(inadmissibleBaseDelay)) {
+		t.Errorf("Backoff() = %d, %v after the second attempt, want 2 and a time after %v", attempts, nextAttempt, before.Add(inadmissibleBaseDelay))
+	}
+
+	// An update of the ClusterQueue releases the workload, keeping the
+	// attempts.
+	if err := manager.UpdateClusterQueue(cq); err != nil {
+		t.Fatalf("Failed updating cluster queue: %v", err)
+	}
+	if diff := cmp.Diff(wantQueued, manager.Dump()); diff != "" {
+		t.Errorf("Unexpected workloads in the ClusterQueues after the update (-want,+got):\n%s", diff)
+	}
+	if attempts, nextAttempt := manager.Backoff(wl); attempts != 2 || nextAttempt != nil {
+		t.Errorf("Backoff() = %d, %v after the update, want 2, nil", attempts, nextAttempt)
+	}
+
+	// Deleting the workload forgets the attempts.
+	inadmissibleAttempt()
+	manager.DeleteWorkload(wl)
+	if attempts, nextAttempt := manager.Backoff(wl); attempts != 0 || nextAttempt != nil {
+		t.Errorf("Backoff() = %d, %v after deleting the workload, want 0, nil", attempts, nextAttempt)
+	}
+	if got := manager.Pending(cq); got != 0 {
+		t.Errorf("Pending() = %d after deleting the workload, want 0", got)
+	}
+}
+
+func TestHeadsStrictFIFOBackoff(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	now := time.Now()
+	cq := utiltesting.MakeClusterQueue("cq").QueueingStrategy(kueue.StrictFIFO).Obj()
+	q := utiltesting.MakeQueue("foo", "").ClusterQueue("cq").Obj()
+	older := utiltesting.MakeWorkload("older", "").Queue("foo").Creation(now.Add(-time.Second)).Obj()
+	newer := utiltesting.MakeWorkload("newer", "").Queue("foo").Creation(now).Obj()
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(older).Build()
+	manager := NewManager(cl)
+	ctx := context.Background()
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding cluster queue %s: %v", cq.Name, err)
+	}
+	if err := manager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Failed adding queue %s: %v", q.Name, err)
+	}
+	manager.AddOrUpdateWorkload(newer)
+	// Two inadmissible attempts put the older workload in backoff.
+	for i := 0; i < 2; i++ {
+		manager.Lock()
+		heads := manager.heads()
+		manager.Unlock()
+		if len(heads) != 1 || heads[0].Obj.Name != older.Name {
+			t.Fatalf("Got heads %v, want the older workload", heads)
+		}
+		manager.RequeueWorkload(ctx, &heads[0], false)
+	}
+
+	manager.Lock()
+	defer manager.Unlock()
+	if heads := manager.heads(); len(heads) != 0 {
+		t.Errorf("Got %d heads while the head of the StrictFIFO ClusterQueue waits in backoff, want 0", len(heads))
+	}
+}
+
+func TestHeadsStoppedClusterQueue(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cq := utiltesting.MakeClusterQueue("cq").StopPolicy(kueue.StopPolicyHold).Obj()
+	q := utiltesting.MakeQueue("foo", "").ClusterQueue("cq").Obj()
+	wl := utiltesting.MakeWorkload("a", "").Queue("foo").Obj()
+	manager := NewManager(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding cluster queue %s: %v", cq.Name, err)
+	}
+	if err := manager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Failed adding queue %s: %v", q.Name, err)
+	}
+	manager.AddOrUpdateWorkload(wl)
+
+	manager.Lock()
+	heads := manager.heads()
+	manager.Unlock()
+	if len(heads) != 0 {
+		t.Fatalf("Got %d heads from a held ClusterQueue, want 0", len(heads))
+	}
+
+	cq.Spec.StopPolicy = kueue.StopPolicyNone
+	if err := manager.UpdateClusterQueue(cq); err != nil {
+		t.Fatalf("Failed updating cluster queue %s: %v", cq.Name, err)
+	}
+	manager.Lock()
+	defer manager.Unlock()
+	if heads := manager.heads(); len(heads) != 1 || heads[0].Obj.Name != wl.Name {
+		t.Errorf("Got heads %v after resuming the ClusterQueue, want the workload", heads)
+	}
+}
+
+func TestPriorityAgingTimer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cq := utiltesting.MakeClusterQueue("cq").
+		QueueingStrategy(kueue.BestEffortFIFO).
+		PriorityAging(kueue.PriorityAging{Increase: 1, PeriodSeconds: 60}).
+		Obj()
+	manager := NewManager(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding cluster queue %s: %v", cq.Name, err)
+	}
+	checkTimer := func(wantPeriod time.Duration) {
+		t.Helper()
+		manager.Lock()
+		defer manager.Unlock()
+		var gotPeriod time.Duration
+		if timer := manager.agingTimers[cq.Name]; timer != nil {
+			gotPeriod = timer.period
+		}
+		if gotPeriod != wantPeriod {
+			t.Errorf("Got aging timer with period %v, want %v", gotPeriod, wantPeriod)
+		}
+	}
+	checkTimer(time.Minute)
+
+	cq.Spec.PriorityAging.PeriodSeconds = 30
+	if err := manager.UpdateClusterQueue(cq); err != nil {
+		t.Fatalf("Failed updating cluster queue %s: %v", cq.Name, err)
+	}
+	checkTimer(30 * time.Second)
+
+	cq.Spec.PriorityAging = nil
+	if err := manager.UpdateClusterQueue(cq); err != nil {
+		t.Fatalf("Failed updating cluster queue %s: %v", cq.Name, err)
+	}
+	checkTimer(0)
+
+	cq.Spec.PriorityAging = &kueue.PriorityAging{Increase: 1}
+	if err := manager.UpdateClusterQueue(cq); err != nil {
+		t.Fatalf("Failed updating cluster queue %s: %v", cq.Name, err)
+	}
+	checkTimer(defaultPriorityAgingPeriod)
+
+	manager.DeleteClusterQueue(cq)
+	checkTimer(0)
+}
+
+func TestHeadsInactiveClusterQueue(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cq := utiltesting.MakeClusterQueue("cq").Obj()
+	q := utiltesting.MakeQueue("foo", "").ClusterQueue("cq").Obj()
+	wl := utiltesting.MakeWorkload("a", "").Queue("foo").Obj()
+	checker := fakeStatusChecker{"cq": false}
+	manager := NewManager(fake.NewClientBuilder().WithScheme(scheme).Build(), WithStatusChecker(checker))
+	ctx := context.Background()
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding cluster queue %s: %v", cq.Name, err)
+	}
+	if err := manager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Failed adding queue %s: %v", q.Name, err)
+	}
+	manager.AddOrUpdateWorkload(wl)
+
+	manager.Lock()
+	heads := manager.heads()
+	manager.Unlock()
+	if len(heads) != 0 {
+		t.Fatalf("Got %d heads from an inactive ClusterQueue, want 0", len(heads))
+	}
+	wantDump := map[string]sets.String{"cq": sets.NewString("a")}
+	if diff := cmp.Diff(wantDump, manager.Dump()); diff != "" {
+		t.Errorf("Unexpected queued workloads in the inactive ClusterQueue (-want,+got):\n%s", diff)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, headsTimeout)
+	defer cancel()
+	go manager.CleanUpOnContext(ctx)
+	gotHeads := make(chan []workload.Info)
+	go func() {
+		gotHeads <- manager.Heads(ctx)
+	}()
+	// The checker is only read while holding the lock of the manager.
+	manager.Lock()
+	checker["cq"] = true
+	manager.Unlock()
+	manager.QueueInadmissibleWorkloads([]string{"cq"})
+	if heads := <-gotHeads; len(heads) != 1 || heads[0].Obj.Name != wl.Name {
+		t.Errorf("Got heads %v after activating the ClusterQueue, want the workload", heads)
+	}
+}
+
+type fakeStatusChecker map[string]bool
+
+func (c fakeStatusChecker) ClusterQueueActive(name string) bool {
+	return c[name]
+}
+
+func TestDeactivatedWorkload(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	cq := utiltesting.MakeClusterQueue("cq").Obj()
+	q := utiltesting.MakeQueue("foo", "").ClusterQueue("cq").Obj()
+	wl := utiltesting.MakeWorkload("a", "").Queue("foo").Obj()
+	manager := NewManager(fake.NewClientBuilder().WithScheme(scheme).Build())
+	ctx := context.Background()
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding cluster queue %s: %v", cq.Name, err)
+	}
+	if err := manager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Failed adding queue %s: %v", q.Name, err)
+	}
+	manager.AddOrUpdateWorkload(wl)
+
+	inactiveWl := wl.DeepCopy()
+	inactiveWl.Spec.Active = pointer.Bool(false)
+	if !manager.UpdateWorkload(wl, inactiveWl) {
+		t.Fatal("Queue for the deactivated workload wasn't found")
+	}
+	if dump := manager.Dump(); dump != nil {
+		t.Errorf("Got queued workloads %v after deactivating the workload, want none", dump)
+	}
+
+	activeWl := inactiveWl.DeepCopy()
+	activeWl.Spec.Active = pointer.Bool(true)
+	if !manager.UpdateWorkload(inactiveWl, activeWl) {
+		t.Fatal("Queue for the activated workload wasn't found")
+	}
+	wantDump := map[string]sets.String{"cq": sets.NewString("a")}
+	if diff := cmp.Diff(wantDump, manager.Dump()); diff != "" {
+		t.Errorf("Unexpected queued workloads after activating the workload (-want,+got):\n%s", diff)
+	}
+}
+
+func TestPendingWorkloads(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %s", err)
+	}
+	now := time.Now()
+	cq := utiltesting.MakeClusterQueue("cq").QueueingStrategy(kueue.BestEffortFIFO).Obj()
+	queues := []*kueue.Queue{
+		utiltesting.MakeQueue("foo", "ns").ClusterQueue("cq").Obj(),
+		utiltesting.MakeQueue("bar", "ns").ClusterQueue("cq").Obj(),
+	}
+	workloads := []*kueue.Workload{
+		utiltesting.MakeWorkload("a", "ns").Queue("foo").Creation(now).Obj(),
+		utiltesting.MakeWorkload("b", "ns").Queue("bar").Priority(10).Creation(now.Add(time.Second)).Obj(),
+		utiltesting.MakeWorkload("c", "ns").Queue("foo").Creation(now.Add(2 * time.Second)).Obj(),
+		utiltesting.MakeWorkload("d", "ns").Queue("bar").Creation(now.Add(3 * time.Second)).Obj(),
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(workloads[1]).Build()
+	manager := NewManager(cl)
+	ctx := context.Background()
+	if err := manager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Failed adding cluster queue %s: %v", cq.Name, err)
+	}
+	for _, q := range queues {
+		if err := manager.AddQueue(ctx, q); err != nil {
+			t.Fatalf("Failed adding queue %s: %v", q.Name, err)
+		}
+	}
+	for _, w := range workloads {
+		manager.AddOrUpdateWorkload(w)
+	}
+	// The workload with the highest priority is found inadmissible.
+	manager.Lock()
+	heads := manager.heads()
+	manager.Unlock()
+	if len(heads) != 1 || heads[0].Obj.Name != "b" {
+		t.Fatalf("Got heads %v, want workload b", heads)
+	}
+	manager.RequeueWorkload(ctx, &heads[0], false)
+
+	gotCQ, ok := manager.ClusterQueuePendingWorkloads("cq")
+	if !ok {
+		t.Fatal("ClusterQueue not found")
+	}
+	wantCQ := []PendingWorkload{
+		{Namespace: "ns", Name: "a", Queue: "foo", PositionInClusterQueue: 0, PositionInQueue: 0},
+		{Namespace: "ns", Name: "c", Queue: "foo", PositionInClusterQueue: 1, PositionInQueue: 1},
+		{Namespace: "ns", Name: "d", Queue: "bar", PositionInClusterQueue: 2, PositionInQueue: 0},
+		{Namespace: "ns", Name: "b", Queue: "bar", Priority: 10, PositionInClusterQueue: 3, PositionInQueue: 1},
+	}
+	if diff := cmp.Diff(wantCQ, gotCQ); diff != "" {
+		t.Errorf("Unexpected pending workloads in the ClusterQueue (-want,+got):\n%s", diff)
+	}
+
+	gotQ, ok := manager.QueuePendingWorkloads("ns", "bar")
+	if !ok {
+		t.Fatal("Queue not found")
+	}
+	wantQ := []PendingWorkload{
+		{Namespace: "ns", Name: "d", Queue: "bar", PositionInClusterQueue: 2, PositionInQueue: 0},
+		{Namespace: "ns", Name: "b", Queue: "bar", Priority: 10, PositionInClusterQueue: 3, PositionInQueue: 1},
+	}
+	if diff := cmp.Diff(wantQ, gotQ); diff != "" {
+		t.Errorf("Unexpected pending workloads in the Queue (-want,+got):\n%s", diff)
+	}
+
+	if _, ok := manager.ClusterQueuePendingWorkloads("other"); ok {
+		t.Error("Found pending workloads for a ClusterQueue that doesn't exist")
+	}
+	if _, ok := manager.QueuePendingWorkloads("ns", "other"); ok {
+		t.Error("Found pending workloads for a Queue that doesn't exist")
+	}
+}
+
+func TestInadmissibleDelay(t *testing.T) {
+	cases := map[int32]time.Duration{
+		1:  0,
+		2:  time.Second,
+		3:  2 * time.Second,
+		5:  8 * time.Second,
+		6:  10 * time.Second,
+		30: 10 * time.Second,
+	}
+	for attempts, want := range cases {
+		if got := inadmissibleDelay(attempts); got != want {
+			t.Errorf("inadmissibleDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
 func TestUpdateWorkload(t *testing.T) {
 	scheme := runtime.NewScheme()
 	if err := kueue.AddToScheme(scheme); err != nil {
@@ -851,3 +1450,107 @@ func workloadNamesFromQ(q *Queue) sets.String {
 	}
 	return names
 }
+
+func TestInSync(t *testing.T) {
+	cq := *utiltesting.MakeClusterQueue("cq").Obj()
+	q := *utiltesting.MakeQueue("foo", "earth").ClusterQueue("cq").Obj()
+	pending := *utiltesting.MakeWorkload("a", "earth").Queue("foo").Obj()
+	movedQueue := *pending.DeepCopy()
+	movedQueue.Spec.QueueName = "bar"
+	admitted := *pending.DeepCopy()
+	admitted.Spec.Admission = utiltesting.MakeAdmission("cq").Obj()
+
+	cases := map[string]struct {
+		queues []kueue.Queue
+		cqs    []kueue.ClusterQueue
+		wls    []kueue.Workload
+		want   bool
+	}{
+		"in sync": {
+			queues: []kueue.Queue{q},
+			cqs:    []kueue.ClusterQueue{cq},
+			wls:    []kueue.Workload{pending},
+			want:   true,
+		},
+		"workload being scheduled": {
+			queues: []kueue.Queue{q},
+			cqs:    []kueue.ClusterQueue{cq},
+			wls:    []kueue.Workload{pending, *utiltesting.MakeWorkload("b", "earth").Queue("foo").Obj()},
+			want:   true,
+		},
+		"missing queue": {
+			queues: []kueue.Queue{q, *utiltesting.MakeQueue("bar", "earth").ClusterQueue("cq").Obj()},
+			cqs:    []kueue.ClusterQueue{cq},
+			wls:    []kueue.Workload{pending},
+		},
+		"deleted clusterQueue": {
+			queues: []kueue.Queue{q},
+			wls:    []kueue.Workload{pending},
+		},
+		"deleted workload": {
+			queues: []kueue.Queue{q},
+			cqs:    []kueue.ClusterQueue{cq},
+		},
+		"admitted workload": {
+			queues: []kueue.Queue{q},
+			cqs:    []kueue.ClusterQueue{cq},
+			wls:    []kueue.Workload{admitted},
+		},
+		"workload moved to another queue": {
+			queues: []kueue.Queue{q},
+			cqs:    []kueue.ClusterQueue{cq},
+			wls:    []kueue.Workload{movedQueue},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			ctx := context.Background()
+			manager := NewManager(fake.NewClientBuilder().WithScheme(scheme).Build())
+			if err := manager.AddClusterQueue(ctx, cq.DeepCopy()); err != nil {
+				t.Fatalf("Failed adding clusterQueue: %v", err)
+			}
+			if err := manager.AddQueue(ctx, q.DeepCopy()); err != nil {
+				t.Fatalf("Failed adding queue: %v", err)
+			}
+			if !manager.AddOrUpdateWorkload(pending.DeepCopy()) {
+				t.Fatalf("Failed adding workload")
+			}
+			if got := manager.InSync(tc.queues, tc.cqs, tc.wls); got != tc.want {
+				t.Errorf("InSync() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRootCohort(t *testing.T) {
+	manager := NewManager(fake.NewClientBuilder().Build())
+	manager.AddOrUpdateCohort(utiltesting.MakeCohort("team-a").Parent("org").Obj())
+	manager.AddOrUpdateCohort(utiltesting.MakeCohort("org").Obj())
+	// A cycle of parents, with a cohort leading to it.
+	manager.AddOrUpdateCohort(utiltesting.MakeCohort("z").Parent("y").Obj())
+	manager.AddOrUpdateCohort(utiltesting.MakeCohort("y").Parent("x").Obj())
+	manager.AddOrUpdateCohort(utiltesting.MakeCohort("x").Parent("y").Obj())
+
+	cases := map[string]string{
+		"team-a": "org",
+		"org":    "org",
+		"other":  "other",
+		"x":      "x",
+		"y":      "x",
+		"z":      "x",
+	}
+	for cohort, want := range cases {
+		if got := manager.rootCohort(cohort); got != want {
+			t.Errorf("rootCohort(%q) = %q, want %q", cohort, got, want)
+		}
+	}
+
+	manager.DeleteCohort(utiltesting.MakeCohort("team-a").Obj())
+	if got := manager.rootCohort("team-a"); got != "team-a" {
+		t.Errorf("rootCohort(%q) after deleting the Cohort = %q, want %q", "team-a", got, "team-a")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sort"
+
+	"sigs.k8s.io/kueue/pkg/util/priority"
+)
+
+// PendingWorkload is a workload waiting for admission and its position in
+// its ClusterQueue and Queue. The positions start at 0 for the next workload
+// to be considered for admission.
+type PendingWorkload struct {
+	Namespace              string `json:"namespace"`
+	Name                   string `json:"name"`
+	Queue                  string `json:"queue"`
+	Priority               int32  `json:"priority"`
+	PositionInClusterQueue int32  `json:"positionInClusterQueue"`
+	PositionInQueue        int32  `json:"positionInQueue"`
+}
+
+// ClusterQueuePendingWorkloads returns the pending workloads of a
+// ClusterQueue, in the order in which they are considered for admission.
+// The workloads that were found inadmissible follow, and then the ones
+// waiting in backoff, by the time of their next attempt. Returns false if the
+// ClusterQueue is not tracked.
+func (m *Manager) ClusterQueuePendingWorkloads(name string) ([]PendingWorkload, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	if _, ok := m.clusterQueues[name]; !ok {
+		return nil, false
+	}
+	return m.pendingWorkloads(name), true
+}
+
+// QueuePendingWorkloads returns the pending workloads of a Queue, in the
+// order in which they are considered for admission, with their positions in
+// the ClusterQueue. Returns false if the Queue is not tracked.
+func (m *Manager) QueuePendingWorkloads(namespace, name string) ([]PendingWorkload, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	key := namespace + "/" + name
+	q := m.queues[key]
+	if q == nil {
+		return nil, false
+	}
+	pending := make([]PendingWorkload, 0, len(q.items))
+	if _, ok := m.clusterQueues[q.ClusterQueue]; !ok {
+		return pending, true
+	}
+	for _, pw := range m.pendingWorkloads(q.ClusterQueue) {
+		if pw.Namespace == namespace && pw.Queue == name {
+			pending = append(pending, pw)
+		}
+	}
+	return pending, true
+}
+
+// pendingWorkloads lists the pending workloads of a ClusterQueue, with their
+// positions. It must be called with the lock held.
+func (m *Manager) pendingWorkloads(cqName string) []PendingWorkload {
+	cq := m.clusterQueues[cqName]
+	infos := cq.Snapshot()
+	var waiting []*backoff
+	for key, b := range m.backoffs {
+		// The workload could have been added back with its queue.
+		if !b.waiting() || cq.Info(key) != nil {
+			continue
+		}
+		if q := m.queues[queueKeyForWorkload(b.info.Obj)]; q != nil && q.ClusterQueue == cqName {
+			waiting = append(waiting, b)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].nextAttempt.Before(waiting[j].nextAttempt)
+	})
+	for _, b := range waiting {
+		infos = append(infos, b.info)
+	}
+
+	pending := make([]PendingWorkload, len(infos))
+	positionsInQueues := make(map[string]int32)
+	for i, info := range infos {
+		qKey := queueKeyForWorkload(info.Obj)
+		pending[i] = PendingWorkload{
+			Namespace:              info.Obj.Namespace,
+			Name:                   info.Obj.Name,
+			Queue:                  info.Obj.Spec.QueueName,
+			Priority:               priority.Priority(info.Obj),
+			PositionInClusterQueue: int32(i),
+			PositionInQueue:        positionsInQueues[qKey],
+		}
+		positionsInQueues[qKey]++
+	}
+	return pending
+}
@@ -40,13 +40,19 @@ func queueKeyForWorkload(w *kueue.Workload) string {
 
 // Queue is the internal implementation of kueue.Queue.
 type Queue struct {
+	Key          string
 	ClusterQueue string
+	Weight       int32
+	// MaxAdmittedWorkloads is the maximum number of workloads from this
+	// queue that can be admitted at the same time.
+	MaxAdmittedWorkloads *int32
 
 	items map[string]*workload.Info
 }
 
 func newQueue(q *kueue.Queue) *Queue {
 	qImpl := &Queue{
+		Key:   Key(q),
 		items: make(map[string]*workload.Info),
 	}
 	qImpl.update(q)
@@ -55,11 +61,13 @@ func newQueue(q *kueue.Queue) *Queue {
 
 func (q *Queue) update(apiQueue *kueue.Queue) {
 	q.ClusterQueue = string(apiQueue.Spec.ClusterQueue)
+	q.Weight = apiQueue.Spec.Weight
+	q.MaxAdmittedWorkloads = apiQueue.Spec.MaxAdmittedWorkloads
 }
 
-func (q *Queue) AddOrUpdate(w *kueue.Workload) {
-	key := workload.Key(w)
-	q.items[key] = workload.NewInfo(w)
+func (q *Queue) AddOrUpdate(info *workload.Info) {
+	key := workload.Key(info.Obj)
+	q.items[key] = info
 }
 
 func (q *Queue) AddIfNotPresent(w *workload.Info) bool {
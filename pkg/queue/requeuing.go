@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// InadmissibleRequeuing is the strategy to move the workloads found
+// inadmissible back to their ClusterQueues.
+type InadmissibleRequeuing string
+
+const (
+	// RequeueImmediate moves all the inadmissible workloads of a cohort back
+	// on any event that might make one of them admissible.
+	RequeueImmediate InadmissibleRequeuing = "Immediate"
+
+	// RequeueEventual moves the inadmissible workloads of a cohort back only
+	// when the quota of a resource that they request is freed, or when the
+	// workload itself becomes admissible.
+	RequeueEventual InadmissibleRequeuing = "Eventual"
+
+	// RequeueTimeout moves each inadmissible workload back after a timeout,
+	// or when the workload itself becomes admissible.
+	RequeueTimeout InadmissibleRequeuing = "Timeout"
+)
+
+// DefaultInadmissibleTimeout is the default time after which the
+// inadmissible workloads are moved back with the Timeout strategy.
+const DefaultInadmissibleTimeout = time.Minute
+
+// associatedInadmissibleWorkloads returns the function that matches the
+// inadmissible workloads to move back after an event of the given workload,
+// which is nil for all of them. It returns false if none has to be moved.
+func (m *Manager) associatedInadmissibleWorkloads(w *kueue.Workload) (func(*workload.Info) bool, bool) {
+	if m.inadmissibleRequeuing != RequeueEventual && m.inadmissibleRequeuing != RequeueTimeout {
+		return nil, true
+	}
+	if w.Spec.Admission == nil {
+		// The event made the workload itself admissible.
+		key := workload.Key(w)
+		return func(info *workload.Info) bool {
+			return workload.Key(info.Obj) == key
+		}, true
+	}
+	if m.inadmissibleRequeuing == RequeueTimeout {
+		return nil, false
+	}
+	freed := freedResources(w.Spec.Admission)
+	return func(info *workload.Info) bool {
+		for name := range requestedResources(info) {
+			if _, ok := freed[name]; ok {
+				return true
+			}
+		}
+		return false
+	}, true
+}
+
+// freedResources returns the names of the resources that have quota in the
+// admission, which the workload frees.
+func freedResources(admission *kueue.Admission) map[corev1.ResourceName]struct{} {
+	names := make(map[corev1.ResourceName]struct{})
+	for _, psf := range admission.PodSetFlavors {
+		for name := range psf.Flavors {
+			names[name] = struct{}{}
+		}
+		for _, split := range psf.Splits {
+			for name := range split.Flavors {
+				names[name] = struct{}{}
+			}
+		}
+	}
+	return names
+}
+
+// requestedResources returns the names of the resources that the workload
+// requests.
+func requestedResources(info *workload.Info) map[corev1.ResourceName]struct{} {
+	names := make(map[corev1.ResourceName]struct{})
+	for _, ps := range info.TotalRequests {
+		for name := range ps.Requests {
+			names[name] = struct{}{}
+		}
+		for _, split := range ps.Splits {
+			for name := range split.Requests {
+				names[name] = struct{}{}
+			}
+		}
+	}
+	return names
+}
+
+// startInadmissibleTimer moves the inadmissible workload back to its
+// ClusterQueue after the timeout, with the Timeout strategy.
+func (m *Manager) startInadmissibleTimer(info *workload.Info) {
+	if m.inadmissibleRequeuing != RequeueTimeout {
+		return
+	}
+	key := workload.Key(info.Obj)
+	m.stopInadmissibleTimer(key)
+	var timer *time.Timer
+	timer = time.AfterFunc(m.inadmissibleTimeout, func() {
+		m.Lock()
+		defer m.Unlock()
+		// The timer could have been stopped or replaced meanwhile.
+		if m.inadmissibleTimers[key] != timer {
+			return
+		}
+		delete(m.inadmissibleTimers, key)
+		q := m.queues[queueKeyForWorkload(info.Obj)]
+		if q == nil {
+			return
+		}
+		cq := m.clusterQueues[q.ClusterQueue]
+		if cq != nil && cq.QueueInadmissibleWorkloads(func(i *workload.Info) bool {
+			return workload.Key(i.Obj) == key
+		}) {
+			m.cond.Broadcast()
+		}
+	})
+	m.inadmissibleTimers[key] = timer
+}
+
+// stopInadmissibleTimer stops the timer of the inadmissible workload, if any.
+func (m *Manager) stopInadmissibleTimer(key string) {
+	if timer := m.inadmissibleTimers[key]; timer != nil {
+		timer.Stop()
+		delete(m.inadmissibleTimers, key)
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	logrtesting "github.com/go-logr/logr/testing"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/queue"
+	"sigs.k8s.io/kueue/pkg/util/routine"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+const (
+	goldenDir         = "testdata/golden"
+	goldenInputSuffix = ".input.yaml"
+	goldenSuffix      = ".golden.yaml"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Rewrite the golden files of TestScheduleGolden with the current decisions.")
+
+// goldenDecisions is the outcome of a scheduling cycle, as stored in the
+// golden files.
+type goldenDecisions struct {
+	// Admitted are the workloads admitted in the cycle, keyed by namespace/name.
+	Admitted map[string]kueue.Admission `json:"admitted,omitempty"`
+	// Pending are the workloads that are not admitted after the cycle, keyed
+	// by namespace/name, with the message of their Admitted condition.
+	Pending map[string]string `json:"pending,omitempty"`
+	// Evicted are the admitted workloads marked for eviction in the cycle,
+	// keyed by namespace/name. The preemption targets have the reason
+	// Preempted and a message naming the workload that preempted them.
+	Evicted map[string]goldenEviction `json:"evicted,omitempty"`
+	// Queued are the workloads left in each ClusterQueue.
+	Queued map[string][]string `json:"queued,omitempty"`
+}
+
+// goldenEviction is the Evicted condition of a workload evicted in the cycle.
+type goldenEviction struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// TestScheduleGolden runs a scheduling cycle for each fixture in
+// testdata/golden and compares the decisions against the golden files.
+// A fixture <name>.input.yaml contains the Namespaces, ResourceFlavors,
+// ClusterQueues, Queues and Workloads of the cluster. Workloads with
+// .spec.admission are part of the cache snapshot, the rest are pending.
+// Run with -update-golden to regenerate <name>.golden.yaml.
+func TestScheduleGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join(goldenDir, "*"+goldenInputSuffix))
+	if err != nil {
+		t.Fatalf("Listing fixtures: %v", err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("No fixtures found in %s", goldenDir)
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), goldenInputSuffix)
+		t.Run(name, func(t *testing.T) {
+			objs, err := readFixture(input)
+			if err != nil {
+				t.Fatalf("Reading fixture: %v", err)
+			}
+			got, err := runGoldenCycle(t, objs)
+			if err != nil {
+				t.Fatalf("Running scheduling cycle: %v", err)
+			}
+			gotYAML, err := yaml.Marshal(got)
+			if err != nil {
+				t.Fatalf("Serializing decisions: %v", err)
+			}
+			goldenPath := filepath.Join(goldenDir, name+goldenSuffix)
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, gotYAML, 0644); err != nil {
+					t.Fatalf("Writing golden file: %v", err)
+				}
+				return
+			}
+			wantYAML, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Reading golden file: %v", err)
+			}
+			if diff := cmp.Diff(string(wantYAML), string(gotYAML)); diff != "" {
+				t.Errorf("Unexpected decisions (-want,+got):\n%s\nRun with -update-golden if the change is intended.", diff)
+			}
+		})
+	}
+}
+
+func goldenScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("adding kueue scheme: %w", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("adding core scheme: %w", err)
+	}
+	return scheme, nil
+}
+
+// readFixture decodes all the objects in a multi-document YAML file.
+func readFixture(path string) ([]client.Object, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scheme, err := goldenScheme()
+	if err != nil {
+		return nil, err
+	}
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	var objs []client.Object
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decoding object %d: %w", len(objs), err)
+		}
+		cObj, ok := obj.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("object %d of type %T is not supported", len(objs), obj)
+		}
+		objs = append(objs, cObj)
+	}
+	return objs, nil
+}
+
+func runGoldenCycle(t *testing.T, objs []client.Object) (*goldenDecisions, error) {
+	log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+		Verbosity: 2,
+	})
+	ctx := ctrl.LoggerInto(context.Background(), log)
+	scheme, err := goldenScheme()
+	if err != nil {
+		return nil, err
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	recorder := record.NewBroadcaster().NewRecorder(scheme,
+		corev1.EventSource{Component: constants.ManagerName})
+	qManager := queue.NewManager(cl)
+	cqCache := cache.New(cl)
+
+	// Load the objects in the same order as the tests in scheduler_test.go,
+	// so that workloads are picked up when their queues are added.
+	for _, obj := range objs {
+		if rf, ok := obj.(*kueue.ResourceFlavor); ok {
+			cqCache.AddOrUpdateResourceFlavor(rf)
+		}
+	}
+	for _, obj := range objs {
+		if q, ok := obj.(*kueue.Queue); ok {
+			if err := qManager.AddQueue(ctx, q); err != nil {
+				return nil, fmt.Errorf("inserting queue %s/%s in manager: %w", q.Namespace, q.Name, err)
+			}
+		}
+	}
+	for _, obj := range objs {
+		if cq, ok := obj.(*kueue.ClusterQueue); ok {
+			if err := cqCache.AddClusterQueue(ctx, cq); err != nil {
+				return nil, fmt.Errorf("inserting clusterQueue %s in cache: %w", cq.Name, err)
+			}
+			if err := qManager.AddClusterQueue(ctx, cq); err != nil {
+				return nil, fmt.Errorf("inserting clusterQueue %s in manager: %w", cq.Name, err)
+			}
+		}
+	}
+
+	scheduler := New(qManager, cqCache, cl, recorder)
+	wg := sync.WaitGroup{}
+	scheduler.setAdmissionRoutineWrapper(routine.NewWrapper(
+		func() { wg.Add(1) },
+		func() { wg.Done() },
+	))
+	ctx, cancel := context.WithTimeout(ctx, queueingTimeout)
+	defer cancel()
+	go qManager.CleanUpOnContext(ctx)
+	scheduler.schedule(ctx)
+	wg.Wait()
+
+	preAdmitted := make(map[string]bool)
+	preEvicted := make(map[string]bool)
+	for _, obj := range objs {
+		if wl, ok := obj.(*kueue.Workload); ok && wl.Spec.Admission != nil {
+			preAdmitted[workload.Key(wl)] = true
+			preEvicted[workload.Key(wl)] = isEvicted(wl)
+		}
+	}
+	var wls kueue.WorkloadList
+	if err := cl.List(ctx, &wls); err != nil {
+		return nil, fmt.Errorf("listing workloads: %w", err)
+	}
+	decisions := goldenDecisions{}
+	for i := range wls.Items {
+		wl := &wls.Items[i]
+		key := workload.Key(wl)
+		if wl.Spec.Admission != nil {
+			if isEvicted(wl) && !preEvicted[key] {
+				if decisions.Evicted == nil {
+					decisions.Evicted = make(map[string]goldenEviction)
+				}
+				c := wl.Status.Conditions[workload.FindConditionIndex(&wl.Status, kueue.WorkloadEvicted)]
+				decisions.Evicted[key] = goldenEviction{Reason: c.Reason, Message: c.Message}
+			}
+			if !preAdmitted[key] {
+				if decisions.Admitted == nil {
+					decisions.Admitted = make(map[string]kueue.Admission)
+				}
+				decisions.Admitted[key] = *wl.Spec.Admission
+			}
+			continue
+		}
+		if decisions.Pending == nil {
+			decisions.Pending = make(map[string]string)
+		}
+		var msg string
+		if i := workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted); i != -1 {
+			msg = wl.Status.Conditions[i].Message
+		}
+		decisions.Pending[key] = msg
+	}
+	for cq, names := range qManager.Dump() {
+		if decisions.Queued == nil {
+			decisions.Queued = make(map[string][]string)
+		}
+		decisions.Queued[cq] = names.List()
+	}
+	return &decisions, nil
+}
+
+func isEvicted(wl *kueue.Workload) bool {
+	i := workload.FindConditionIndex(&wl.Status, kueue.WorkloadEvicted)
+	return i != -1 && wl.Status.Conditions[i].Status == corev1.ConditionTrue
+}
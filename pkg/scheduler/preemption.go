@@ -0,0 +1,279 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/util/priority"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// findPreemptionTargets returns the admitted workloads that need to be
+// preempted for the entry to fit in the clusterQueue, following the
+// preemption policies of the clusterQueue. The entry first reclaims the
+// nominal quota of its clusterQueue from the clusterQueues of the cohort that
+// borrow the flavors it needs, so that the quota that others borrow is
+// recovered before the clusterQueue preempts its own workloads. With fair
+// sharing, the entry can also preempt workloads in the cohort to borrow up to
+// a fair share. It returns nil if preempting workloads can't make the entry
+// fit.
+// The snapshot is restored before returning.
+func (e *entry) findPreemptionTargets(log logr.Logger, snap *cache.Snapshot, cq *cache.ClusterQueue, fairSharing bool) []*workload.Info {
+	wlPriority := priority.Priority(e.Obj)
+	reclaimable := reclaimableFlavors(e, cq)
+	var sameQueue, cohort []*workload.Info
+	if cq.Preemption.WithinClusterQueue == kueue.PreemptionPolicyLowerPriority {
+		for _, wi := range cq.Workloads {
+			if priority.Priority(wi.Obj) < wlPriority {
+				sameQueue = append(sameQueue, wi)
+			}
+		}
+	}
+	withinCohort := cq.Preemption.WithinCohort
+	if cq.Cohort != nil && (withinCohort == kueue.PreemptionPolicyLowerPriority || withinCohort == kueue.PreemptionPolicyAny) {
+		for _, other := range snap.ClusterQueues {
+			if other == cq || other.Cohort == nil || other.Cohort.Root() != cq.Cohort.Root() || !isBorrowingIn(other, reclaimable) {
+				continue
+			}
+			for _, wi := range other.Workloads {
+				if withinCohort == kueue.PreemptionPolicyAny || priority.Priority(wi.Obj) < wlPriority {
+					cohort = append(cohort, wi)
+				}
+			}
+		}
+	}
+	if len(cohort) > 0 {
+		// The workload reclaims the nominal quota of its clusterQueue, so it
+		// has to fit without borrowing. The workloads of the clusterQueue are
+		// only preempted if the reclaimed quota isn't enough.
+		candidates := make([]*workload.Info, 0, len(cohort)+len(sameQueue))
+		candidates = append(append(candidates, cohort...), sameQueue...)
+		if targets := minimalPreemptions(log, e, snap, withoutBorrowing(cq), reclaimable, candidates); targets != nil {
+			return targets
+		}
+	}
+	if len(sameQueue) > 0 {
+		if targets := minimalPreemptions(log, e, snap, cq, reclaimable, sameQueue); targets != nil {
+			return targets
+		}
+	}
+	if len(cohort) > 0 && fairSharing {
+		return fairPreemptions(log, e, snap, cq, cohort)
+	}
+	return nil
+}
+
+// minimalPreemptions removes candidates from the snapshot, in order, until
+// the entry fits. The candidates of other clusterQueues are skipped once
+// their clusterQueue, after the removals, no longer borrows any of the
+// reclaimable flavors. Then it adds back the candidates that don't need to be
+// preempted for the entry to keep fitting.
+func minimalPreemptions(log logr.Logger, e *entry, snap *cache.Snapshot, cq *cache.ClusterQueue, reclaimable map[corev1.ResourceName]sets.String, candidates []*workload.Info) []*workload.Info {
+	sort.Slice(candidates, candidatesOrdering(candidates, cq.Name))
+	var targets []*workload.Info
+	fits := false
+	for _, candidate := range candidates {
+		candidateCQ := snap.ClusterQueues[string(candidate.Obj.Spec.Admission.ClusterQueue)]
+		if candidateCQ.Name != cq.Name && !isBorrowingIn(candidateCQ, reclaimable) {
+			// The clusterQueue of the candidate doesn't have quota to reclaim anymore.
+			continue
+		}
+		snap.RemoveWorkload(candidate)
+		targets = append(targets, candidate)
+		if fitsInSnapshot(log, e, snap, cq) {
+			fits = true
+			break
+		}
+	}
+	if !fits {
+		for _, target := range targets {
+			snap.AddWorkload(target)
+		}
+		return nil
+	}
+	targets = trimTargets(log, e, snap, cq, targets)
+	for _, target := range targets {
+		snap.AddWorkload(target)
+	}
+	return targets
+}
+
+// fairPreemptions removes candidates from the snapshot, starting with the
+// clusterQueues with the highest dominant resource share, until the entry
+// fits, possibly borrowing. Only the clusterQueues with a higher share than
+// the clusterQueue of the entry lose workloads. The preemptions are discarded
+// unless the share of the clusterQueue of each target was higher than the
+// share that the clusterQueue of the entry gets after admitting it, so that
+// the targets can't preempt the entry back.
+func fairPreemptions(log logr.Logger, e *entry, snap *cache.Snapshot, cq *cache.ClusterQueue, candidates []*workload.Info) []*workload.Info {
+	shares := make(map[string]int)
+	for _, candidate := range candidates {
+		name := string(candidate.Obj.Spec.Admission.ClusterQueue)
+		if _, found := shares[name]; !found {
+			shares[name] = snap.ClusterQueues[name].DominantResourceShare()
+		}
+	}
+	byPriority := candidatesOrdering(candidates, cq.Name)
+	sort.Slice(candidates, func(i, j int) bool {
+		si := shares[string(candidates[i].Obj.Spec.Admission.ClusterQueue)]
+		sj := shares[string(candidates[j].Obj.Spec.Admission.ClusterQueue)]
+		if si != sj {
+			return si > sj
+		}
+		return byPriority(i, j)
+	})
+	ownShare := cq.DominantResourceShare()
+	var targets []*workload.Info
+	targetShares := make(map[string]int)
+	fits := false
+	for _, candidate := range candidates {
+		share := snap.ClusterQueues[string(candidate.Obj.Spec.Admission.ClusterQueue)].DominantResourceShare()
+		if share <= ownShare {
+			continue
+		}
+		snap.RemoveWorkload(candidate)
+		targets = append(targets, candidate)
+		targetShares[workload.Key(candidate.Obj)] = share
+		if fitsInSnapshot(log, e, snap, cq) {
+			fits = true
+			break
+		}
+	}
+	if fits {
+		targets = trimTargets(log, e, snap, cq, targets)
+		// The entry fits after trimming the targets.
+		probe := entry{Info: e.Info}
+		_ = probe.assignFlavors(log, snap.ResourceFlavors, cq)
+		newShare := cq.DominantResourceShareWith(&probe.Info)
+		for _, target := range targets {
+			if targetShares[workload.Key(target.Obj)] <= newShare {
+				fits = false
+				break
+			}
+		}
+	}
+	for _, target := range targets {
+		snap.AddWorkload(target)
+	}
+	if !fits {
+		return nil
+	}
+	return targets
+}
+
+// trimTargets adds back to the snapshot the targets that don't need to be
+// preempted for the entry to keep fitting. The last target is required for the
+// entry to fit. The remaining targets are left out of the snapshot.
+func trimTargets(log logr.Logger, e *entry, snap *cache.Snapshot, cq *cache.ClusterQueue, targets []*workload.Info) []*workload.Info {
+	for i := len(targets) - 2; i >= 0; i-- {
+		snap.AddWorkload(targets[i])
+		if fitsInSnapshot(log, e, snap, cq) {
+			targets = append(targets[:i], targets[i+1:]...)
+		} else {
+			snap.RemoveWorkload(targets[i])
+		}
+	}
+	return targets
+}
+
+func fitsInSnapshot(log logr.Logger, e *entry, snap *cache.Snapshot, cq *cache.ClusterQueue) bool {
+	probe := entry{Info: e.Info}
+	return probe.assignFlavors(log, snap.ResourceFlavors, cq) == nil
+}
+
+// reclaimableFlavors returns the flavors of the clusterQueue, by resource,
+// for the resources that the entry requests. Preempting the workloads of a
+// clusterQueue that only borrows other resources or flavors wouldn't give
+// quota back to the entry, but would take it from the nominal quota of that
+// clusterQueue.
+func reclaimableFlavors(e *entry, cq *cache.ClusterQueue) map[corev1.ResourceName]sets.String {
+	reclaimable := make(map[corev1.ResourceName]sets.String)
+	for _, ps := range e.TotalRequests {
+		for res := range ps.Requests {
+			if _, found := reclaimable[res]; found {
+				continue
+			}
+			flavors := sets.NewString()
+			for _, flv := range cq.RequestableResources[res] {
+				flavors.Insert(flv.Name)
+			}
+			reclaimable[res] = flavors
+		}
+	}
+	return reclaimable
+}
+
+// isBorrowingIn returns whether the clusterQueue uses more than its nominal
+// quota for any of the given flavors.
+func isBorrowingIn(cq *cache.ClusterQueue, flavors map[corev1.ResourceName]sets.String) bool {
+	for res, names := range flavors {
+		for _, flv := range cq.RequestableResources[res] {
+			if names.Has(flv.Name) && cq.UsedResources[res][flv.Name] > flv.Nominal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// candidatesOrdering criteria:
+// 1. Workloads from other clusterQueues in the cohort.
+// 2. Lower priority first.
+// 3. Most recently created first, to lose as little progress as possible.
+func candidatesOrdering(candidates []*workload.Info, cq string) func(int, int) bool {
+	return func(i, j int) bool {
+		a := candidates[i]
+		b := candidates[j]
+		aInCQ := string(a.Obj.Spec.Admission.ClusterQueue) == cq
+		bInCQ := string(b.Obj.Spec.Admission.ClusterQueue) == cq
+		if aInCQ != bInCQ {
+			return !aInCQ
+		}
+		pa := priority.Priority(a.Obj)
+		pb := priority.Priority(b.Obj)
+		if pa != pb {
+			return pa < pb
+		}
+		return b.Obj.CreationTimestamp.Before(&a.Obj.CreationTimestamp)
+	}
+}
+
+// preempt marks the preemption targets of the entry for eviction. The
+// workload controller clears their admissions and requeues them.
+func (s *Scheduler) preempt(ctx context.Context, e *entry) {
+	log := ctrl.LoggerFrom(ctx)
+	message := fmt.Sprintf("Preempted to admit workload %s in ClusterQueue %s", workload.Key(e.Obj), e.ClusterQueue)
+	for _, target := range e.preemptionTargets {
+		if err := workload.Evict(ctx, s.client, target.Obj, workload.EvictedByPreemption, message); err != nil {
+			log.Error(err, "Failed to preempt workload", "preemptedWorkload", klog.KObj(target.Obj))
+			continue
+		}
+		workload.RecordEvent(s.recorder, target.Obj, corev1.EventTypeNormal, "Preempted", message)
+		log.V(2).Info("Workload preempted", "preemptedWorkload", klog.KObj(target.Obj))
+	}
+}
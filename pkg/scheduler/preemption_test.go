@@ -0,0 +1,430 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	logrtesting "github.com/go-logr/logr/testing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/queue"
+	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+func TestFindPreemptionTargets(t *testing.T) {
+	now := time.Now()
+	cpuResource := func(nominal string) *kueue.ResourceGroup {
+		return utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", nominal).Obj()).Obj()
+	}
+	gpuResource := func(nominal string) *kueue.ResourceGroup {
+		return utiltesting.MakeResource("example.com/gpu").
+			Flavor(utiltesting.MakeFlavor("gpu", nominal).Obj()).Obj()
+	}
+	clusterQueues := []*kueue.ClusterQueue{
+		utiltesting.MakeClusterQueue("standalone").
+			Resource(cpuResource("6")).
+			Preemption(kueue.ClusterQueuePreemption{
+				WithinClusterQueue: kueue.PreemptionPolicyLowerPriority,
+			}).
+			Obj(),
+		utiltesting.MakeClusterQueue("no-preemption").
+			Resource(cpuResource("6")).
+			Obj(),
+		utiltesting.MakeClusterQueue("c1").
+			Cohort("cohort").
+			Resource(cpuResource("6")).
+			Preemption(kueue.ClusterQueuePreemption{
+				WithinClusterQueue: kueue.PreemptionPolicyLowerPriority,
+				WithinCohort:       kueue.PreemptionPolicyLowerPriority,
+			}).
+			Obj(),
+		utiltesting.MakeClusterQueue("c2").
+			Cohort("cohort").
+			Resource(cpuResource("6")).
+			Obj(),
+		utiltesting.MakeClusterQueue("d1").
+			Cohort("other").
+			Resource(cpuResource("6")).
+			Preemption(kueue.ClusterQueuePreemption{
+				WithinCohort: kueue.PreemptionPolicyAny,
+			}).
+			Obj(),
+		utiltesting.MakeClusterQueue("d2").
+			Cohort("other").
+			Resource(cpuResource("6")).
+			Obj(),
+		utiltesting.MakeClusterQueue("f1").
+			Cohort("fair").
+			Resource(cpuResource("4")).
+			Preemption(kueue.ClusterQueuePreemption{
+				WithinCohort: kueue.PreemptionPolicyAny,
+			}).
+			Obj(),
+		utiltesting.MakeClusterQueue("f2").
+			Cohort("fair").
+			Resource(cpuResource("4")).
+			Obj(),
+		utiltesting.MakeClusterQueue("f3").
+			Cohort("fair").
+			Resource(cpuResource("4")).
+			Obj(),
+		utiltesting.MakeClusterQueue("m1").
+			Cohort("multi").
+			Resource(cpuResource("6")).
+			Resource(gpuResource("4")).
+			Preemption(kueue.ClusterQueuePreemption{
+				WithinClusterQueue: kueue.PreemptionPolicyLowerPriority,
+				WithinCohort:       kueue.PreemptionPolicyLowerPriority,
+			}).
+			Obj(),
+		utiltesting.MakeClusterQueue("m2").
+			Cohort("multi").
+			Resource(cpuResource("6")).
+			Resource(gpuResource("4")).
+			Obj(),
+		utiltesting.MakeClusterQueue("m3").
+			Cohort("multi").
+			Resource(cpuResource("6")).
+			Resource(gpuResource("4")).
+			Obj(),
+	}
+	admitted := func(name, cq, cpu string, priority int32, creation time.Time) *kueue.Workload {
+		return utiltesting.MakeWorkload(name, "").
+			Request(corev1.ResourceCPU, cpu).
+			Priority(priority).
+			Creation(creation).
+			Admit(utiltesting.MakeAdmission(cq).Flavor(corev1.ResourceCPU, "default").Obj()).
+			Obj()
+	}
+	cases := map[string]struct {
+		admitted    []*kueue.Workload
+		incoming    *kueue.Workload
+		cq          string
+		fairSharing bool
+		wantTargets []string
+	}{
+		"preempt lowest priority in the clusterQueue": {
+			admitted: []*kueue.Workload{
+				admitted("low", "standalone", "2", -1, now),
+				admitted("mid", "standalone", "2", 0, now),
+				admitted("high", "standalone", "2", 1, now),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "2").Priority(1).Obj(),
+			cq:          "standalone",
+			wantTargets: []string{"/low"},
+		},
+		"preempt multiple workloads in the clusterQueue": {
+			admitted: []*kueue.Workload{
+				admitted("low", "standalone", "2", -1, now),
+				admitted("mid", "standalone", "2", 0, now),
+				admitted("high", "standalone", "2", 1, now),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Priority(2).Obj(),
+			cq:          "standalone",
+			wantTargets: []string{"/low", "/mid"},
+		},
+		"preempt the newest of the workloads with the same priority": {
+			admitted: []*kueue.Workload{
+				admitted("old", "standalone", "2", 0, now),
+				admitted("new", "standalone", "2", 0, now.Add(time.Second)),
+				admitted("high", "standalone", "2", 1, now),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "2").Priority(1).Obj(),
+			cq:          "standalone",
+			wantTargets: []string{"/new"},
+		},
+		"not enough workloads with lower priority": {
+			admitted: []*kueue.Workload{
+				admitted("low", "standalone", "2", -1, now),
+				admitted("mid", "standalone", "2", 0, now),
+				admitted("high", "standalone", "2", 1, now),
+			},
+			incoming: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Priority(0).Obj(),
+			cq:       "standalone",
+		},
+		"preemption disabled": {
+			admitted: []*kueue.Workload{
+				admitted("low", "no-preemption", "6", -1, now),
+			},
+			incoming: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "2").Priority(1).Obj(),
+			cq:       "no-preemption",
+		},
+		"reclaim quota from a borrowing clusterQueue": {
+			admitted: []*kueue.Workload{
+				admitted("a", "c2", "4", -1, now),
+				admitted("b", "c2", "4", -1, now.Add(time.Second)),
+				admitted("c", "c2", "4", 0, now),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Obj(),
+			cq:          "c1",
+			wantTargets: []string{"/b"},
+		},
+		"can't reclaim more than the nominal quota": {
+			admitted: []*kueue.Workload{
+				admitted("a", "c2", "4", -1, now),
+				admitted("b", "c2", "4", -1, now),
+				admitted("c", "c2", "4", -1, now),
+			},
+			incoming: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "8").Obj(),
+			cq:       "c1",
+		},
+		"don't preempt in clusterQueues that aren't borrowing": {
+			admitted: []*kueue.Workload{
+				admitted("a", "c2", "4", -1, now),
+				admitted("b", "c1", "6", 0, now),
+			},
+			incoming: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Obj(),
+			cq:       "c1",
+		},
+		"reclaim quota regardless of priority": {
+			admitted: []*kueue.Workload{
+				admitted("a", "d2", "6", 10, now),
+				admitted("b", "d2", "6", 10, now.Add(time.Second)),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "6").Obj(),
+			cq:          "d1",
+			wantTargets: []string{"/b"},
+		},
+		"borrowing needs fair sharing to preempt": {
+			admitted: []*kueue.Workload{
+				admitted("a", "f1", "4", 0, now),
+				admitted("b", "f2", "4", 0, now),
+				admitted("c", "f2", "2", -1, now),
+				admitted("d", "f2", "2", 0, now),
+			},
+			incoming: utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "2").Obj(),
+			cq:       "f1",
+		},
+		"preempt to borrow up to a fair share": {
+			admitted: []*kueue.Workload{
+				admitted("a", "f1", "4", 0, now),
+				admitted("b", "f2", "4", 0, now),
+				admitted("c", "f2", "2", -1, now),
+				admitted("d", "f2", "2", 0, now),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "2").Obj(),
+			cq:          "f1",
+			fairSharing: true,
+			wantTargets: []string{"/c"},
+		},
+		"don't preempt to borrow over a fair share": {
+			admitted: []*kueue.Workload{
+				admitted("a", "f1", "4", 0, now),
+				admitted("b", "f2", "4", 0, now),
+				admitted("c", "f2", "2", -1, now),
+				admitted("d", "f3", "2", 0, now),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Obj(),
+			cq:          "f1",
+			fairSharing: true,
+		},
+		"reclaim borrowed quota before preempting in the clusterQueue": {
+			admitted: []*kueue.Workload{
+				admitted("own", "m1", "2", -1, now),
+				admitted("m2-a", "m2", "4", -1, now),
+				admitted("m2-b", "m2", "4", -1, now.Add(time.Second)),
+				admitted("m3-a", "m3", "4", -1, now),
+				admitted("m3-b", "m3", "4", -1, now),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "2").Obj(),
+			cq:          "m1",
+			wantTargets: []string{"/m2-b"},
+		},
+		"reclaim quota from several borrowing clusterQueues": {
+			admitted: []*kueue.Workload{
+				admitted("own", "m1", "2", -1, now),
+				admitted("m2-a", "m2", "4", -1, now),
+				admitted("m2-b", "m2", "2", -1, now),
+				admitted("m2-c", "m2", "2", -1, now.Add(2*time.Second)),
+				admitted("m3-a", "m3", "4", -1, now),
+				admitted("m3-b", "m3", "2", -1, now),
+				admitted("m3-c", "m3", "2", -1, now.Add(time.Second)),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Obj(),
+			cq:          "m1",
+			wantTargets: []string{"/m2-c", "/m3-c"},
+		},
+		"don't reclaim from clusterQueues borrowing other resources": {
+			admitted: []*kueue.Workload{
+				admitted("m2-cpu", "m2", "6", -2, now),
+				utiltesting.MakeWorkload("m2-gpu", "").
+					Request("example.com/gpu", "6").
+					Creation(now).
+					Admit(utiltesting.MakeAdmission("m2").Flavor("example.com/gpu", "gpu").Obj()).
+					Obj(),
+				admitted("m3-a", "m3", "6", -1, now),
+				admitted("m3-b", "m3", "6", -1, now.Add(time.Second)),
+			},
+			incoming:    utiltesting.MakeWorkload("in", "").Request(corev1.ResourceCPU, "4").Obj(),
+			cq:          "m1",
+			wantTargets: []string{"/m3-b"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+				Verbosity: 2,
+			})
+			ctx := ctrl.LoggerInto(context.Background(), log)
+			scheme := runtime.NewScheme()
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme).Build()
+			cqCache := cache.New(cl)
+			cqCache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("default").Obj())
+			cqCache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("gpu").Obj())
+			for _, cq := range clusterQueues {
+				if err := cqCache.AddClusterQueue(ctx, cq); err != nil {
+					t.Fatalf("Inserting clusterQueue %s in cache: %v", cq.Name, err)
+				}
+			}
+			for _, w := range tc.admitted {
+				if !cqCache.AddOrUpdateWorkload(w) {
+					t.Fatalf("Couldn't add workload %s to the cache", w.Name)
+				}
+			}
+			snapshot := cqCache.Snapshot()
+			wlInfo := workload.NewInfo(tc.incoming)
+			wlInfo.ClusterQueue = tc.cq
+			e := entry{Info: *wlInfo}
+			cq := snapshot.ClusterQueues[tc.cq]
+			if e.assignFlavors(log, snapshot.ResourceFlavors, cq) == nil {
+				t.Fatalf("Workload fits without preemptions")
+			}
+			targets := e.findPreemptionTargets(log, &snapshot, cq, tc.fairSharing)
+			gotTargets := sets.NewString()
+			for _, target := range targets {
+				gotTargets.Insert(workload.Key(target.Obj))
+			}
+			if diff := cmp.Diff(sets.NewString(tc.wantTargets...), gotTargets); diff != "" {
+				t.Errorf("Unexpected preemption targets (-want,+got):\n%s", diff)
+			}
+			if diff := cmp.Diff(cqCache.Snapshot(), snapshot, cmpopts.IgnoreUnexported(cache.ClusterQueue{}, cache.Cohort{})); diff != "" {
+				t.Errorf("Snapshot wasn't restored (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSchedulePreemption(t *testing.T) {
+	log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+		Verbosity: 2,
+	})
+	ctx := ctrl.LoggerInto(context.Background(), log)
+	cq := utiltesting.MakeClusterQueue("cq").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "4").Obj()).Obj()).
+		Preemption(kueue.ClusterQueuePreemption{
+			WithinClusterQueue: kueue.PreemptionPolicyLowerPriority,
+		}).
+		Obj()
+	q := utiltesting.MakeQueue("main", "ns").ClusterQueue("cq").Obj()
+	low := utiltesting.MakeWorkload("low", "ns").
+		Queue("main").
+		Request(corev1.ResourceCPU, "4").
+		Priority(0).
+		Admit(utiltesting.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()).
+		Obj()
+	high := utiltesting.MakeWorkload("high", "ns").
+		Queue("main").
+		Request(corev1.ResourceCPU, "4").
+		Priority(1).
+		Obj()
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(low.DeepCopy(), high.DeepCopy(), q, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}).
+		Build()
+	broadcaster := record.NewBroadcaster()
+	recorder := broadcaster.NewRecorder(scheme, corev1.EventSource{Component: constants.ManagerName})
+	qManager := queue.NewManager(cl)
+	cqCache := cache.New(cl)
+	if err := qManager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Inserting queue %s/%s in manager: %v", q.Namespace, q.Name, err)
+	}
+	if err := qManager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Inserting clusterQueue %s in manager: %v", cq.Name, err)
+	}
+	if err := cqCache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Inserting clusterQueue %s in cache: %v", cq.Name, err)
+	}
+	cqCache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("default").Obj())
+	var lowInClient kueue.Workload
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(low), &lowInClient); err != nil {
+		t.Fatalf("Failed obtaining workload: %v", err)
+	}
+	if !cqCache.AddOrUpdateWorkload(&lowInClient) {
+		t.Fatal("Couldn't add the admitted workload to the cache")
+	}
+	var highInClient kueue.Workload
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(high), &highInClient); err != nil {
+		t.Fatalf("Failed obtaining workload: %v", err)
+	}
+	if !qManager.AddOrUpdateWorkload(&highInClient) {
+		t.Fatal("Couldn't queue the pending workload")
+	}
+	scheduler := New(qManager, cqCache, cl, recorder)
+
+	scheduler.schedule(ctx)
+
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(low), &lowInClient); err != nil {
+		t.Fatalf("Failed obtaining workload: %v", err)
+	}
+	if !workload.InCondition(&lowInClient, kueue.WorkloadEvicted) {
+		t.Error("The lower priority workload wasn't marked for eviction")
+	}
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(high), &highInClient); err != nil {
+		t.Fatalf("Failed obtaining workload: %v", err)
+	}
+	if highInClient.Spec.Admission != nil {
+		t.Error("The higher priority workload was admitted before the preemption finished")
+	}
+	wantConditions := []kueue.WorkloadCondition{
+		{
+			Type:    kueue.WorkloadAdmitted,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Pending",
+			Message: "Preempting 1 workload(s) to fit in the quota",
+		},
+	}
+	if diff := cmp.Diff(wantConditions, highInClient.Status.Conditions, cmpopts.IgnoreFields(kueue.WorkloadCondition{}, "LastTransitionTime", "LastProbeTime")); diff != "" {
+		t.Errorf("Unexpected conditions (-want,+got):\n%s", diff)
+	}
+}
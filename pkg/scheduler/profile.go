@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"sigs.k8s.io/kueue/pkg/metrics"
+)
+
+type cyclePhase int
+
+const (
+	phaseSnapshot cyclePhase = iota
+	phaseNomination
+	phaseFlavorAssignment
+	phasePreemptionPlanning
+	phaseAPIUpdates
+	numCyclePhases
+)
+
+var cyclePhaseNames = [numCyclePhases]string{
+	phaseSnapshot:           "snapshot",
+	phaseNomination:         "nomination",
+	phaseFlavorAssignment:   "flavor_assignment",
+	phasePreemptionPlanning: "preemption_planning",
+	phaseAPIUpdates:         "api_updates",
+}
+
+// cycleProfile accumulates the time that a scheduling cycle spends in each
+// phase. The methods of a nil cycleProfile do nothing, so that the cycles
+// only pay for the measurements when debugging is enabled.
+type cycleProfile struct {
+	start     time.Time
+	durations [numCyclePhases]time.Duration
+}
+
+func newCycleProfile(enabled bool) *cycleProfile {
+	if !enabled {
+		return nil
+	}
+	return &cycleProfile{start: time.Now()}
+}
+
+// track starts measuring the given phase and returns the function that stops
+// it. The time of a phase is accumulated across its calls.
+func (p *cycleProfile) track(phase cyclePhase) func() {
+	if p == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		p.durations[phase] += time.Since(start)
+	}
+}
+
+// trackAsync starts measuring the given phase in a goroutine that can outlive
+// the cycle, like the update of an admitted workload, and returns the
+// function that stops it. Since the cycle may have reported already, each
+// measurement is recorded in the metrics on its own.
+func (p *cycleProfile) trackAsync(phase cyclePhase) func() {
+	if p == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		metrics.AdmissionCyclePhase(cyclePhaseNames[phase], time.Since(start))
+	}
+}
+
+// report records the durations of the phases in the metrics and logs a
+// summary of the cycle, with the number of entries by status.
+func (p *cycleProfile) report(log logr.Logger, entries []entry) {
+	if p == nil {
+		return
+	}
+	total := time.Since(p.start)
+	keysAndValues := make([]interface{}, 0, 2*(int(numCyclePhases)+4))
+	keysAndValues = append(keysAndValues, "total", total)
+	metrics.AdmissionCyclePhase("total", total)
+	for phase, d := range p.durations {
+		keysAndValues = append(keysAndValues, cyclePhaseNames[phase], d)
+		metrics.AdmissionCyclePhase(cyclePhaseNames[phase], d)
+	}
+	var admittedCount, preemptingCount int
+	for _, e := range entries {
+		switch e.status {
+		case assumed:
+			admittedCount++
+		case preempting:
+			preemptingCount++
+		}
+	}
+	keysAndValues = append(keysAndValues, "heads", len(entries), "admitted", admittedCount, "preempting", preemptingCount)
+	log.Info("Scheduling cycle finished", keysAndValues...)
+}
@@ -20,6 +20,9 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -38,37 +41,145 @@ import (
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/metrics"
 	"sigs.k8s.io/kueue/pkg/queue"
+	"sigs.k8s.io/kueue/pkg/util/pointer"
+	"sigs.k8s.io/kueue/pkg/util/priority"
 	"sigs.k8s.io/kueue/pkg/util/routine"
 	"sigs.k8s.io/kueue/pkg/workload"
 )
 
 const (
 	errCouldNotAdmitWL = "Could not admit workload and assigning flavors in apiserver"
+
+	// DefaultAdmissionWorkers is the default maximum number of admitted
+	// workloads being updated in the apiserver at the same time.
+	DefaultAdmissionWorkers = 10
 )
 
 type Scheduler struct {
-	queues                  *queue.Manager
-	cache                   *cache.Cache
+	queues                  queue.Interface
+	cache                   cache.Interface
 	client                  client.Client
 	recorder                record.EventRecorder
 	admissionRoutineWrapper routine.Wrapper
+	fairSharing             bool
+	workloadInfoOptions     []workload.InfoOption
+	waitForPodsReady        bool
+	dryRun                  bool
+	profiling               bool
+
+	// admissions tracks the admissions in flight, so that shutting down
+	// doesn't leave workloads assumed in the cache but not admitted in the
+	// apiserver.
+	admissions sync.WaitGroup
+	// admissionSlots bounds the number of updates of admitted workloads in
+	// flight. The scheduling cycles don't wait for the slots: the workloads are
+	// assumed in the cache, so the next cycles already account for their usage.
+	admissionSlots chan struct{}
+	// snapshot is the snapshot of the cache of the last cycle, which the
+	// next cycle updates, only copying the ClusterQueues that changed.
+	snapshot cache.Snapshot
+}
+
+type options struct {
+	fairSharing         bool
+	workloadInfoOptions []workload.InfoOption
+	waitForPodsReady    bool
+	dryRun              bool
+	admissionWorkers    int
+	profiling           bool
+}
+
+// Option configures the scheduler.
+type Option func(*options)
+
+// WithFairSharing sets whether the ClusterQueues of a cohort share the unused
+// quota according to their dominant resource share.
+func WithFairSharing(enable bool) Option {
+	return func(o *options) {
+		o.fairSharing = enable
+	}
 }
 
-func New(queues *queue.Manager, cache *cache.Cache, cl client.Client, recorder record.EventRecorder) *Scheduler {
+// WithWorkloadInfoOptions sets the options to calculate the requests of the
+// workloads that are partially admitted.
+func WithWorkloadInfoOptions(opts ...workload.InfoOption) Option {
+	return func(o *options) {
+		o.workloadInfoOptions = opts
+	}
+}
+
+// WithWaitForPodsReady sets whether the scheduler admits one workload at a
+// time, waiting for the pods of the admitted workloads to be ready before
+// admitting the next one.
+func WithWaitForPodsReady(f bool) Option {
+	return func(o *options) {
+		o.waitForPodsReady = f
+	}
+}
+
+// WithDryRun sets whether the scheduler only records the admissions and
+// preemptions that it would do, in the status and the events of the
+// workloads, instead of admitting and preempting workloads.
+func WithDryRun(f bool) Option {
+	return func(o *options) {
+		o.dryRun = f
+	}
+}
+
+// WithAdmissionWorkers sets the maximum number of admitted workloads that are
+// updated in the apiserver at the same time. Zero or less means no limit.
+func WithAdmissionWorkers(n int) Option {
+	return func(o *options) {
+		o.admissionWorkers = n
+	}
+}
+
+// WithProfiling sets whether the scheduler measures the time that each cycle
+// spends in its phases, reporting it in the metrics and in a log summary of
+// the cycle.
+func WithProfiling(f bool) Option {
+	return func(o *options) {
+		o.profiling = f
+	}
+}
+
+func New(queues queue.Interface, cache cache.Interface, cl client.Client, recorder record.EventRecorder, opts ...Option) *Scheduler {
+	options := options{
+		admissionWorkers: DefaultAdmissionWorkers,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var admissionSlots chan struct{}
+	if options.admissionWorkers > 0 {
+		admissionSlots = make(chan struct{}, options.admissionWorkers)
+	}
 	return &Scheduler{
 		queues:                  queues,
 		cache:                   cache,
 		client:                  cl,
 		recorder:                recorder,
 		admissionRoutineWrapper: routine.DefaultWrapper,
+		fairSharing:             options.fairSharing,
+		workloadInfoOptions:     options.workloadInfoOptions,
+		waitForPodsReady:        options.waitForPodsReady,
+		dryRun:                  options.dryRun,
+		profiling:               options.profiling,
+		admissionSlots:          admissionSlots,
 	}
 }
 
-func (s *Scheduler) Start(ctx context.Context) {
+// Start runs scheduling cycles until the context is done. Then it waits for
+// the admissions in flight to finish before returning.
+func (s *Scheduler) Start(ctx context.Context) error {
 	log := ctrl.LoggerFrom(ctx).WithName("scheduler")
 	ctx = ctrl.LoggerInto(ctx, log)
 	wait.UntilWithContext(ctx, s.schedule, 0)
+	log.V(2).Info("Waiting for the admissions in flight to finish")
+	s.admissions.Wait()
+	return nil
 }
 
 func (s *Scheduler) setAdmissionRoutineWrapper(wrapper routine.Wrapper) {
@@ -78,51 +189,96 @@ func (s *Scheduler) setAdmissionRoutineWrapper(wrapper routine.Wrapper) {
 func (s *Scheduler) schedule(ctx context.Context) {
 	log := ctrl.LoggerFrom(ctx)
 
+	// Wait for the pods of the admitted workloads to be ready, if required,
+	// so that gang workloads don't deadlock each other by holding part of the
+	// cluster each.
+	if s.waitForPodsReady && !s.cache.PodsReadyForAllAdmittedWorkloads() {
+		log.V(3).Info("Waiting for the pods of the admitted workloads to be ready")
+		s.cache.WaitForPodsReady(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.V(3).Info("The pods of the admitted workloads are ready")
+	}
+
 	// 1. Get the heads from the queues, including their desired clusterQueue.
 	// This operation blocks while the queues are empty.
 	headWorkloads := s.queues.Heads(ctx)
-	// No elements means the program is finishing.
-	if len(headWorkloads) == 0 {
+	// No elements means the program is finishing. Don't start a cycle if the
+	// program started finishing while the heads were obtained: the workloads
+	// remain pending in the apiserver.
+	if len(headWorkloads) == 0 || ctx.Err() != nil {
 		return
 	}
 
+	prof := newCycleProfile(s.profiling)
+
 	// 2. Take a snapshot of the cache.
-	snapshot := s.cache.Snapshot()
+	done := prof.track(phaseSnapshot)
+	s.cache.UpdateSnapshot(&s.snapshot)
+	snapshot := s.snapshot
+	done()
 
 	// 3. Calculate requirements for admitting workloads (resource flavors, borrowing).
 	// (resource flavors, borrowing).
-	entries := s.nominate(ctx, headWorkloads, snapshot)
+	done = prof.track(phaseNomination)
+	entries := s.nominate(ctx, headWorkloads, snapshot, prof)
+	done()
 
 	// 4. Sort entries based on borrowing and timestamps.
 	sort.Sort(entryOrdering(entries))
 
-	// 5. Admit entries, ensuring that no more than one workload gets
-	// admitted by a cohort (if borrowing).
-	// This is because there can be other workloads deeper in a clusterQueue whose
-	// head got admitted that should be scheduled in the cohort before the heads
-	// of other clusterQueues.
+	// 5. Admit entries. The admitted workloads are added to the snapshot, so
+	// that the entries in a cohort that already got admissions in this cycle
+	// are only admitted if they still fit. Since the entries are sorted, the
+	// conflicts for the capacity of a cohort are resolved in the same order
+	// every time. When waiting for the pods to be ready, only one workload is
+	// admitted per cycle.
 	usedCohorts := sets.NewString()
+	admitted := false
 	for i := range entries {
 		e := &entries[i]
 		if e.status != nominated {
+			if len(e.preemptionTargets) > 0 && s.dryRun {
+				e.inadmissibleReason = fmt.Sprintf("Dry run: would preempt %d workload(s) to fit in the quota", len(e.preemptionTargets))
+			} else if len(e.preemptionTargets) > 0 {
+				done := prof.track(phaseAPIUpdates)
+				s.preemptForEntry(ctx, e, snapshot.ClusterQueues[e.ClusterQueue], usedCohorts)
+				done()
+			}
 			continue
 		}
-		c := snapshot.ClusterQueues[e.ClusterQueue]
-		if len(e.borrows) > 0 && c.Cohort != nil && usedCohorts.Has(c.Cohort.Name) {
+		if s.waitForPodsReady && admitted {
 			e.status = skipped
-			e.inadmissibleReason = "cohort used in this cycle"
+			e.inadmissibleReason = "Waiting for the pods of the admitted workloads to be ready"
 			continue
 		}
+		c := snapshot.ClusterQueues[e.ClusterQueue]
 		log := log.WithValues("workload", klog.KObj(e.Obj), "clusterQueue", klog.KRef("", e.ClusterQueue))
-		if err := s.admit(ctrl.LoggerInto(ctx, log), e); err == nil {
-			e.status = assumed
-		} else {
+		if c.Cohort != nil && usedCohorts.Has(c.Cohort.Root().Name) {
+			if err := e.assignFlavors(log, snapshot.ResourceFlavors, c); err != nil {
+				e.status = skipped
+				e.inadmissibleReason = fmt.Sprintf("Workload didn't fit after the admissions in the cohort in this cycle: %v", err)
+				continue
+			}
+		}
+		admitted = true
+		newWorkload := admittedWorkload(e, c)
+		if s.dryRun {
+			// The workload stays pending, as if it didn't fit, with the
+			// admission it would get in its status.
+			e.status = ""
+			e.inadmissibleReason = fmt.Sprintf("Dry run: would be admitted by ClusterQueue %s, using %s", e.ClusterQueue, quotaUsage(e.TotalRequests))
+			log.V(2).Info("Workload would be admitted", "usage", quotaUsage(e.TotalRequests))
+		} else if err := s.admit(ctrl.LoggerInto(ctx, log), e, newWorkload, prof); err != nil {
 			e.inadmissibleReason = fmt.Sprintf("Failed to admit workload: %v", err)
 		}
-		// Even if there was a failure, we shouldn't admit other workloads to this
-		// cohort.
+		// Even if there was a failure, the workload is accounted for, so that
+		// the other workloads of the cohort don't take its quota in this
+		// cycle.
+		snapshot.AddWorkload(workload.NewInfo(newWorkload, s.workloadInfoOptions...))
 		if c.Cohort != nil {
-			usedCohorts.Insert(c.Cohort.Name)
+			usedCohorts.Insert(c.Cohort.Root().Name)
 		}
 	}
 
@@ -133,10 +289,38 @@ func (s *Scheduler) schedule(ctx context.Context) {
 			"clusterQueue", klog.KRef("", e.ClusterQueue),
 			"status", e.status,
 			"reason", e.inadmissibleReason)
-		if e.status != assumed {
+		if e.status == assumed {
+			metrics.AdmissionAttempt(metrics.AdmissionResultSuccess)
+		} else {
+			metrics.AdmissionAttempt(metrics.AdmissionResultInadmissible)
+		}
+		if e.status != assumed && e.status != preempting {
+			done := prof.track(phaseAPIUpdates)
 			s.requeueAndUpdate(log, ctx, e)
+			done()
+		}
+	}
+	prof.report(log, entries)
+}
+
+// preemptForEntry preempts the workloads that prevent the entry from fitting,
+// unless there were admissions or preemptions in the cohort during this cycle,
+// as the preemption targets were calculated without them.
+func (s *Scheduler) preemptForEntry(ctx context.Context, e *entry, cq *cache.ClusterQueue, usedCohorts sets.String) {
+	if cq.Cohort != nil {
+		root := cq.Cohort.Root()
+		if usedCohorts.Has(root.Name) {
+			return
 		}
+		usedCohorts.Insert(root.Name)
 	}
+	log := ctrl.LoggerFrom(ctx).WithValues("workload", klog.KObj(e.Obj), "clusterQueue", klog.KRef("", e.ClusterQueue))
+	// The workload is requeued before preempting, so that the events of the
+	// preempted workloads move it back to the active queue.
+	e.inadmissibleReason = fmt.Sprintf("Preempting %d workload(s) to fit in the quota", len(e.preemptionTargets))
+	s.requeueAndUpdate(log, ctx, *e)
+	e.status = preempting
+	s.preempt(ctrl.LoggerInto(ctx, log), e)
 }
 
 type entryStatus string
@@ -148,6 +332,8 @@ const (
 	skipped entryStatus = "skipped"
 	// indicates if the workload was assumed to have been admitted.
 	assumed entryStatus = "assumed"
+	// indicates if the workload is waiting for other workloads to be preempted.
+	preempting entryStatus = "preempting"
 )
 
 // entry holds requirements for a workload to be admitted by a clusterQueue.
@@ -160,11 +346,18 @@ type entry struct {
 	borrows            cache.Resources
 	status             entryStatus
 	inadmissibleReason string
+	// preemptionTargets is the admitted workloads that need to be preempted
+	// for the workload to fit in the clusterQueue.
+	preemptionTargets []*workload.Info
+	// dominantResourceShare is the share of the cohort that the clusterQueue
+	// would borrow after admitting the workload, when fair sharing is
+	// enabled.
+	dominantResourceShare int
 }
 
 // nominate returns the workloads with their requirements (resource flavors, borrowing) if
 // they were admitted by the clusterQueues in the snapshot.
-func (s *Scheduler) nominate(ctx context.Context, workloads []workload.Info, snap cache.Snapshot) []entry {
+func (s *Scheduler) nominate(ctx context.Context, workloads []workload.Info, snap cache.Snapshot, prof *cycleProfile) []entry {
 	log := ctrl.LoggerFrom(ctx)
 	entries := make([]entry, 0, len(workloads))
 	for _, w := range workloads {
@@ -174,90 +367,491 @@ func (s *Scheduler) nominate(ctx context.Context, workloads []workload.Info, sna
 		e := entry{Info: w}
 		if cq == nil {
 			e.inadmissibleReason = "ClusterQueue not found"
+		} else if !cq.Active() {
+			e.inadmissibleReason = fmt.Sprintf("ClusterQueue is inactive: ResourceFlavors %s not found", strings.Join(cq.MissingFlavors, ", "))
+		} else if workload.InCondition(w.Obj, kueue.WorkloadEvicted) {
+			e.inadmissibleReason = "Waiting for the eviction to finish"
+		} else if rs := w.Obj.Status.RequeueState; rs != nil && rs.RequeueAt != nil {
+			e.inadmissibleReason = fmt.Sprintf("Waiting for the requeue backoff until %s", rs.RequeueAt.Format(time.RFC3339))
 		} else if err := s.client.Get(ctx, types.NamespacedName{Name: w.Obj.Namespace}, &ns); err != nil {
 			e.inadmissibleReason = fmt.Sprintf("Could not obtain workload namespace: %v", err)
 		} else if !cq.NamespaceSelector.Matches(labels.Set(ns.Labels)) {
 			e.inadmissibleReason = "Workload namespace doesn't match ClusterQueue selector"
-		} else if !e.assignFlavors(log, snap.ResourceFlavors, cq) {
-			e.inadmissibleReason = "Workload didn't fit in the remaining quota"
+		} else if cq.AdmissionLimitReached() {
+			e.inadmissibleReason = fmt.Sprintf("ClusterQueue reached its maximum of %d admitted workloads", *cq.MaxAdmittedWorkloads)
+		} else if snap.QueueAdmissionLimitReached(w.Obj) {
+			e.inadmissibleReason = "Queue reached its maximum number of admitted workloads"
+		} else if err := e.trackedAssignFlavors(log, snap.ResourceFlavors, cq, prof); err != nil {
+			e.inadmissibleReason = fmt.Sprintf("Workload didn't fit in the remaining quota: %v", err)
+			// Preempting doesn't help a workload that doesn't fit in the
+			// namespace quota with all its pods.
+			nsErr := cq.FitsInNamespaceQuotas(&w)
+			if nsErr == nil {
+				done := prof.track(phasePreemptionPlanning)
+				e.preemptionTargets = e.findPreemptionTargets(log, &snap, cq, s.fairSharing)
+				done()
+			}
+			if len(e.preemptionTargets) == 0 {
+				// There is nothing to preempt, so the workload takes a next
+				// flavor or, if possible, is admitted with fewer pods.
+				if nsErr == nil && cq.FlavorFungibility.WhenCanPreempt == kueue.Preempt {
+					cq = tryingNextFlavor(cq)
+					if e.trackedAssignFlavors(log, snap.ResourceFlavors, cq, prof) == nil {
+						e.status = nominated
+						e.inadmissibleReason = ""
+					}
+				}
+				done := prof.track(phaseFlavorAssignment)
+				if e.status != nominated && e.assignFlavorsPartially(log, snap.ResourceFlavors, cq, s.workloadInfoOptions) {
+					e.status = nominated
+					e.inadmissibleReason = ""
+				}
+				done()
+			}
+		} else if err := cq.FitsInNamespaceQuotas(&e.Info); err != nil {
+			e.inadmissibleReason = fmt.Sprintf("Workload didn't fit in the namespace quota: %v", err)
+			// The workload might fit in the namespace quota with fewer pods.
+			done := prof.track(phaseFlavorAssignment)
+			if e.assignFlavorsPartially(log, snap.ResourceFlavors, cq, s.workloadInfoOptions) {
+				e.status = nominated
+				e.inadmissibleReason = ""
+			}
+			done()
 		} else {
 			e.status = nominated
 		}
+		if s.fairSharing && e.status == nominated && len(e.borrows) > 0 {
+			e.dominantResourceShare = cq.DominantResourceShareWith(&e.Info)
+		}
 		entries = append(entries, e)
 	}
 	return entries
 }
 
+// trackedAssignFlavors is assignFlavors, measured as part of the flavor
+// assignment phase of the cycle profile.
+func (e *entry) trackedAssignFlavors(log logr.Logger, resourceFlavors map[string]*kueue.ResourceFlavor, cq *cache.ClusterQueue, prof *cycleProfile) error {
+	defer prof.track(phaseFlavorAssignment)()
+	return e.assignFlavors(log, resourceFlavors, cq)
+}
+
 // assignFlavors calculates the flavors that should be assigned to this entry
 // if admitted by this clusterQueue, including details of how much it needs to
 // borrow from the cohort.
-// It returns whether the entry would fit. If it doesn't fit, the object is
-// unmodified.
-func (e *entry) assignFlavors(log logr.Logger, resourceFlavors map[string]*kueue.ResourceFlavor, cq *cache.ClusterQueue) bool {
-	flavoredRequests := make([]workload.PodSetResources, 0, len(e.TotalRequests))
+// The entry only fits if all its podSets fit. Otherwise, it returns an
+// unfitError with the reason for each podSet that doesn't fit, and the object
+// is unmodified.
+func (e *entry) assignFlavors(log logr.Logger, resourceFlavors map[string]*kueue.ResourceFlavor, cq *cache.ClusterQueue) error {
+	if !cq.CanBorrow(priority.Priority(e.Obj)) {
+		cq = withoutBorrowing(cq)
+	}
+	totalRequests := e.TotalRequests
+	if _, ok := cq.RequestableResources[corev1.ResourcePods]; ok {
+		// Each pod counts towards the quota for pods.
+		totalRequests = workload.WithPodsRequests(totalRequests)
+	}
+	flavoredRequests := make([]workload.PodSetResources, 0, len(totalRequests))
 	wUsed := make(cache.Resources)
 	wBorrows := make(cache.Resources)
-	for i, podSet := range e.TotalRequests {
-		flavors := make(map[corev1.ResourceName]string, len(podSet.Requests))
-		for resName, reqVal := range podSet.Requests {
-			rFlavor, borrow := findFlavorForResource(log, resName, reqVal, wUsed[resName], resourceFlavors, cq, &e.Obj.Spec.PodSets[i].Spec)
-			if rFlavor == "" {
-				return false
-			}
-			if borrow > 0 {
-				if wBorrows[resName] == nil {
-					wBorrows[resName] = make(map[string]int64)
+	// Resource groups that need the same flavor for all the podSets, as
+	// required by the workload or the clusterQueue for any of their
+	// resources, are assigned upfront, considering the requests of all the
+	// podSets together.
+	sameFlavorResources := sets.NewString()
+	for _, resName := range e.Obj.Spec.SameFlavorResources {
+		sameFlavorResources.Insert(string(resName))
+	}
+	sameFlavorResources = sameFlavorResources.Union(cq.SameFlavorResources)
+	// The placement hints restrict the flavors that each podSet can get.
+	podSets := workload.PodSetsWithPlacementHints(e.Obj)
+	sameFlavors := make(map[corev1.ResourceName]string, len(sameFlavorResources))
+	for i := range cq.ResourceGroups {
+		rg := &cq.ResourceGroups[i]
+		if !coversAny(rg, sameFlavorResources) {
+			continue
+		}
+		rFlavor, borrows, requested, flavorReasons := findFlavorForPodSets(log, rg, totalRequests, podSets, resourceFlavors, cq)
+		if len(requested) == 0 {
+			continue
+		}
+		if rFlavor == "" {
+			reason := withFlavorReasons(fmt.Sprintf("no flavor fits the requests of all the pod sets for %s", resourceNames(requested)), flavorReasons)
+			var unfit unfitError
+			for _, podSet := range totalRequests {
+				if len(requestsForGroup(rg, podSet.Requests)) > 0 {
+					unfit = append(unfit, podSetReason{podSet: podSet.Name, reason: reason})
 				}
-				// Don't accumulate borrowing. The returned `borrow` already considers
-				// usage from previous pod sets.
-				wBorrows[resName][rFlavor] = borrow
 			}
-			if wUsed[resName] == nil {
-				wUsed[resName] = make(map[string]int64)
+			return unfit
+		}
+		for resName := range requested {
+			if borrow := borrows[resName]; borrow > 0 {
+				wBorrows[resName] = map[string]int64{rFlavor: borrow}
 			}
-			wUsed[resName][rFlavor] += reqVal
-			flavors[resName] = rFlavor
+			sameFlavors[resName] = rFlavor
 		}
-		flavoredRequests = append(flavoredRequests, workload.PodSetResources{
+	}
+	// All the podSets are evaluated, even after one doesn't fit, to report
+	// the reasons for all of them.
+	var unfit unfitError
+	for i, podSet := range totalRequests {
+		podSetSpec := &podSets[i]
+		psResources := workload.PodSetResources{
 			Name:     podSet.Name,
+			Count:    podSet.Count,
 			Requests: podSet.Requests,
-			Flavors:  flavors,
-		})
+		}
+		flavors, borrows, err := assignPodSetFlavors(log, podSet.Requests, &podSetSpec.Spec, sameFlavors, wUsed, resourceFlavors, cq)
+		if err == nil {
+			psResources.Flavors = flavors
+			addUsage(wUsed, wBorrows, podSet.Requests, flavors, borrows)
+		} else if podSetSpec.SplitAcrossFlavors && podSetSpec.Count > 1 {
+			psResources.Splits, err = splitPodSet(log, podSetSpec, podSet.Requests, sameFlavors, wUsed, wBorrows, resourceFlavors, cq)
+		}
+		if err != nil {
+			unfit = append(unfit, podSetReason{podSet: podSet.Name, reason: err.Error()})
+			continue
+		}
+		flavoredRequests = append(flavoredRequests, psResources)
+	}
+	if len(unfit) > 0 {
+		return unfit
 	}
 	e.TotalRequests = flavoredRequests
 	if len(wBorrows) > 0 {
 		e.borrows = wBorrows
 	}
+	return nil
+}
+
+// podSetReason is the reason why a podSet doesn't fit in a clusterQueue.
+type podSetReason struct {
+	podSet string
+	reason string
+}
+
+// unfitError lists the podSets of a workload that don't fit in a
+// clusterQueue, in the order of the workload.
+type unfitError []podSetReason
+
+func (e unfitError) Error() string {
+	msgs := make([]string, len(e))
+	for i, r := range e {
+		msgs[i] = fmt.Sprintf("couldn't assign flavors to pod set %s: %s", r.podSet, r.reason)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// withFlavorReasons appends to the reason why a request doesn't fit the
+// reasons why each flavor that was considered doesn't fit.
+func withFlavorReasons(reason string, flavorReasons []string) string {
+	if len(flavorReasons) == 0 {
+		return reason
+	}
+	return fmt.Sprintf("%s: %s", reason, strings.Join(flavorReasons, ", "))
+}
+
+// resourceNames returns the sorted names of the requested resources, joined
+// by commas.
+func resourceNames(requests workload.Requests) string {
+	names := make([]string, 0, len(requests))
+	for resName := range requests {
+		names = append(names, string(resName))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// assignFlavorsPartially looks for the smallest reduction of the counts of
+// the podSets, down to their minCount, with which the entry fits in the
+// clusterQueue, including its namespace quotas. The pods are removed from
+// each podSet in proportion to how far its count is from its minCount.
+// It returns whether the entry would fit. If it doesn't fit, the object is
+// unmodified.
+func (e *entry) assignFlavorsPartially(log logr.Logger, resourceFlavors map[string]*kueue.ResourceFlavor, cq *cache.ClusterQueue, infoOpts []workload.InfoOption) bool {
+	deltas := make([]int32, len(e.Obj.Spec.PodSets))
+	total := int32(0)
+	for i, ps := range e.Obj.Spec.PodSets {
+		if ps.MinCount != nil && *ps.MinCount < ps.Count {
+			deltas[i] = ps.Count - *ps.MinCount
+			total += deltas[i]
+		}
+	}
+	if total == 0 {
+		return false
+	}
+	reduced := func(removed int32) *entry {
+		wl := e.Obj.DeepCopy()
+		for i := range wl.Spec.PodSets {
+			// Round up, so that all the pods above minCount are removed when
+			// removing the total.
+			wl.Spec.PodSets[i].Count -= (deltas[i]*removed + total - 1) / total
+		}
+		return &entry{Info: *workload.NewInfo(wl, infoOpts...)}
+	}
+	fits := func(r *entry) bool {
+		return r.assignFlavors(log, resourceFlavors, cq) == nil && cq.FitsInNamespaceQuotas(&r.Info) == nil
+	}
+	removed := int32(sort.Search(int(total), func(i int) bool {
+		return fits(reduced(int32(i + 1)))
+	})) + 1
+	if removed > total {
+		return false
+	}
+	fit := reduced(removed)
+	if !fits(fit) {
+		return false
+	}
+	e.TotalRequests = fit.TotalRequests
+	e.borrows = fit.borrows
 	return true
 }
 
-// admit sets the admitting clusterQueue and flavors into the workload of
-// the entry, and asynchronously updates the object in the apiserver after
-// assuming it in the cache.
-func (s *Scheduler) admit(ctx context.Context, e *entry) error {
-	log := ctrl.LoggerFrom(ctx)
+// withoutBorrowing returns a copy of the clusterQueue in which the usage of
+// each flavor is limited to its nominal quota.
+func withoutBorrowing(cq *cache.ClusterQueue) *cache.ClusterQueue {
+	cqCopy := *cq
+	cqCopy.RequestableResources = make(map[corev1.ResourceName][]cache.FlavorLimits, len(cq.RequestableResources))
+	for name, flavors := range cq.RequestableResources {
+		limits := make([]cache.FlavorLimits, len(flavors))
+		for i, flv := range flavors {
+			limits[i] = flv
+			limits[i].BorrowingLimit = pointer.Int64(0)
+		}
+		cqCopy.RequestableResources[name] = limits
+	}
+	return &cqCopy
+}
+
+// tryingNextFlavor returns a copy of the clusterQueue in which workloads try
+// the next flavor instead of preempting.
+func tryingNextFlavor(cq *cache.ClusterQueue) *cache.ClusterQueue {
+	cqCopy := *cq
+	cqCopy.FlavorFungibility.WhenCanPreempt = kueue.TryNextFlavor
+	return &cqCopy
+}
+
+// assignPodSetFlavors returns the flavors that satisfy the requests of a podSet,
+// given that wUsed is the usage of flavors by previous podSets, along with
+// the borrowing required for each resource. All the resources of a group get
+// the same flavor.
+// Resources in sameFlavors keep the flavor that was already assigned to them.
+// If the podSet doesn't fit, it returns an error with the reason.
+func assignPodSetFlavors(
+	log logr.Logger,
+	requests workload.Requests,
+	spec *corev1.PodSpec,
+	sameFlavors map[corev1.ResourceName]string,
+	wUsed cache.Resources,
+	resourceFlavors map[string]*kueue.ResourceFlavor,
+	cq *cache.ClusterQueue) (map[corev1.ResourceName]string, map[corev1.ResourceName]int64, error) {
+	flavors := make(map[corev1.ResourceName]string, len(requests))
+	borrows := make(map[corev1.ResourceName]int64)
+	for i := range cq.ResourceGroups {
+		rg := &cq.ResourceGroups[i]
+		groupRequests := requestsForGroup(rg, requests)
+		if len(groupRequests) == 0 {
+			continue
+		}
+		rFlavor, ok := flavorForGroup(rg, sameFlavors)
+		if !ok {
+			var rBorrows map[corev1.ResourceName]int64
+			var flavorReasons []string
+			rFlavor, rBorrows, flavorReasons = findFlavorForGroup(log, rg, groupRequests, wUsed, resourceFlavors, cq, spec)
+			if rFlavor == "" {
+				reason := fmt.Sprintf("no flavor fits the requests for %s", resourceNames(groupRequests))
+				return nil, nil, fmt.Errorf("%s", withFlavorReasons(reason, flavorReasons))
+			}
+			for resName, borrow := range rBorrows {
+				borrows[resName] = borrow
+			}
+		}
+		for resName := range groupRequests {
+			flavors[resName] = rFlavor
+		}
+	}
+	for resName := range requests {
+		if _, ok := flavors[resName]; !ok {
+			// The clusterQueue doesn't have quota for the resource.
+			return nil, nil, fmt.Errorf("resource %s unavailable in ClusterQueue", resName)
+		}
+	}
+	return flavors, borrows, nil
+}
+
+// requestsForGroup returns the requests for the resources of the group.
+func requestsForGroup(rg *cache.ResourceGroup, requests workload.Requests) workload.Requests {
+	var groupRequests workload.Requests
+	for _, resName := range rg.CoveredResources {
+		if val, ok := requests[resName]; ok {
+			if groupRequests == nil {
+				groupRequests = make(workload.Requests, len(rg.CoveredResources))
+			}
+			groupRequests[resName] = val
+		}
+	}
+	return groupRequests
+}
+
+// flavorForGroup returns the flavor in sameFlavors of any of the resources of
+// the group.
+func flavorForGroup(rg *cache.ResourceGroup, sameFlavors map[corev1.ResourceName]string) (string, bool) {
+	for _, resName := range rg.CoveredResources {
+		if rFlavor, ok := sameFlavors[resName]; ok {
+			return rFlavor, true
+		}
+	}
+	return "", false
+}
+
+// coversAny returns whether the group covers any of the resources.
+func coversAny(rg *cache.ResourceGroup, resources sets.String) bool {
+	for _, resName := range rg.CoveredResources {
+		if resources.Has(string(resName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// addUsage adds the requests in the given flavors to wUsed and records the
+// borrowing in wBorrows.
+func addUsage(wUsed, wBorrows cache.Resources, requests workload.Requests, flavors map[corev1.ResourceName]string, borrows map[corev1.ResourceName]int64) {
+	for resName, rFlavor := range flavors {
+		if borrow, ok := borrows[resName]; ok {
+			if wBorrows[resName] == nil {
+				wBorrows[resName] = make(map[string]int64)
+			}
+			// Don't accumulate borrowing. The borrowing already considers
+			// usage from previous pod sets.
+			wBorrows[resName][rFlavor] = borrow
+		}
+		if wUsed[resName] == nil {
+			wUsed[resName] = make(map[string]int64)
+		}
+		wUsed[resName][rFlavor] += requests[resName]
+	}
+}
+
+// splitPodSet splits the pods of the podSet into subsets that fit in
+// different flavors. Each subset has as many pods as fit in the first
+// available flavors, considering the usage of previous subsets.
+// It returns an error if some of the pods don't fit in any flavor.
+func splitPodSet(
+	log logr.Logger,
+	podSet *kueue.PodSet,
+	requests workload.Requests,
+	sameFlavors map[corev1.ResourceName]string,
+	wUsed, wBorrows cache.Resources,
+	resourceFlavors map[string]*kueue.ResourceFlavor,
+	cq *cache.ClusterQueue) ([]workload.PodSetSplit, error) {
+	var splits []workload.PodSetSplit
+	for first := 0; first < int(podSet.Count); {
+		remaining := int(podSet.Count) - first
+		count := sort.Search(remaining, func(i int) bool {
+			_, _, err := assignPodSetFlavors(log, podsRequests(requests, podSet.Count, first, i+1), &podSet.Spec, sameFlavors, wUsed, resourceFlavors, cq)
+			return err != nil
+		})
+		if count == 0 {
+			_, _, err := assignPodSetFlavors(log, podsRequests(requests, podSet.Count, first, 1), &podSet.Spec, sameFlavors, wUsed, resourceFlavors, cq)
+			return nil, fmt.Errorf("couldn't fit the last %d of %d pods in any flavor: %v", remaining, podSet.Count, err)
+		}
+		splitRequests := podsRequests(requests, podSet.Count, first, count)
+		flavors, borrows, _ := assignPodSetFlavors(log, splitRequests, &podSet.Spec, sameFlavors, wUsed, resourceFlavors, cq)
+		addUsage(wUsed, wBorrows, splitRequests, flavors, borrows)
+		splits = append(splits, workload.PodSetSplit{
+			Count:    int32(count),
+			Requests: splitRequests,
+			Flavors:  flavors,
+		})
+		first += count
+	}
+	return splits, nil
+}
+
+// podsRequests returns the share of the total requests of a podSet of total
+// pods that belongs to the count pods starting at first. The transformed
+// requests of a podSet might not be a multiple of its count, so the remainder
+// is spread one unit per pod over the first pods, and the shares of all the
+// pods add up to the total requests.
+func podsRequests(requests workload.Requests, total int32, first, count int) workload.Requests {
+	res := make(workload.Requests, len(requests))
+	for resName, v := range requests {
+		perPod, remainder := v/int64(total), int(v%int64(total))
+		res[resName] = perPod * int64(count)
+		if first < remainder {
+			last := first + count
+			if last > remainder {
+				last = remainder
+			}
+			res[resName] += int64(last - first)
+		}
+	}
+	return res
+}
+
+// admittedWorkload returns a copy of the workload of the entry with the
+// admitting clusterQueue, flavors and admission checks.
+func admittedWorkload(e *entry, cq *cache.ClusterQueue) *kueue.Workload {
 	newWorkload := e.Obj.DeepCopy()
 	admission := &kueue.Admission{
-		ClusterQueue:  kueue.ClusterQueueReference(e.ClusterQueue),
-		PodSetFlavors: make([]kueue.PodSetFlavors, len(e.TotalRequests)),
+		ClusterQueue:    kueue.ClusterQueueReference(e.ClusterQueue),
+		PodSetFlavors:   make([]kueue.PodSetFlavors, len(e.TotalRequests)),
+		AdmissionChecks: cq.AdmissionChecks,
 	}
 	for i := range e.TotalRequests {
 		admission.PodSetFlavors[i] = kueue.PodSetFlavors{
 			Name:    e.Obj.Spec.PodSets[i].Name,
 			Flavors: e.TotalRequests[i].Flavors,
 		}
+		if count := e.TotalRequests[i].Count; count < e.Obj.Spec.PodSets[i].Count {
+			admission.PodSetFlavors[i].Count = pointer.Int32(count)
+		}
+		for _, split := range e.TotalRequests[i].Splits {
+			admission.PodSetFlavors[i].Splits = append(admission.PodSetFlavors[i].Splits, kueue.PodSetSplit{
+				Count:   split.Count,
+				Flavors: split.Flavors,
+			})
+		}
 	}
 	newWorkload.Spec.Admission = admission
+	return newWorkload
+}
+
+// admit asynchronously updates the admitted workload of the entry in the
+// apiserver after assuming it in the cache. The updates beyond the limit of admission
+// workers wait for a slot, without blocking the scheduling cycles. The time of
+// the update, without the wait, is recorded in the API updates phase of the
+// profile.
+func (s *Scheduler) admit(ctx context.Context, e *entry, newWorkload *kueue.Workload, prof *cycleProfile) error {
+	log := ctrl.LoggerFrom(ctx)
+	admission := newWorkload.Spec.Admission
 	if err := s.cache.AssumeWorkload(newWorkload); err != nil {
 		return err
 	}
+	// The status is set before starting the goroutine, which reads the entry
+	// if the update fails.
+	e.status = assumed
 	log.V(2).Info("Workload assumed in the cache")
 
+	// The update has to finish even if the program is shutting down,
+	// otherwise the workload would stay assumed.
+	ctx = ctrl.LoggerInto(context.Background(), log)
+	s.admissions.Add(1)
 	s.admissionRoutineWrapper.Run(func() {
+		defer s.admissions.Done()
+		if s.admissionSlots != nil {
+			s.admissionSlots <- struct{}{}
+			defer func() { <-s.admissionSlots }()
+		}
+		done := prof.trackAsync(phaseAPIUpdates)
 		err := s.client.Update(ctx, newWorkload)
+		done()
 		if err == nil {
-			s.recorder.Eventf(newWorkload, corev1.EventTypeNormal, "Admitted", "Admitted by ClusterQueue %v", admission.ClusterQueue)
+			workload.RecordEvent(s.recorder, newWorkload, corev1.EventTypeNormal, "Admitted",
+				fmt.Sprintf("Admitted by ClusterQueue %v, using %s", admission.ClusterQueue, quotaUsage(e.TotalRequests)))
 			log.V(2).Info("Workload successfully admitted and assigned flavors")
+			metrics.AdmittedWorkload(e.ClusterQueue, time.Since(e.Obj.CreationTimestamp.Time))
 			return
 		}
 		// Ignore errors because the workload or clusterQueue could have been deleted
@@ -275,46 +869,226 @@ func (s *Scheduler) admit(ctx context.Context, e *entry) error {
 	return nil
 }
 
-// findFlavorForResources returns a flavor which can satisfy the resource request,
-// given that wUsed is the usage of flavors by previous podsets.
-// If it finds a flavor, also returns any borrowing required.
-func findFlavorForResource(
+// quotaUsage describes the quota that the podSets use from each flavor, like
+// "cpu: 2 in default, memory: 1Gi in default".
+func quotaUsage(podSets []workload.PodSetResources) string {
+	usage := make(cache.Resources)
+	for _, ps := range podSets {
+		addUsage(usage, nil, ps.Requests, ps.Flavors, nil)
+		for _, split := range ps.Splits {
+			addUsage(usage, nil, split.Requests, split.Flavors, nil)
+		}
+	}
+	var parts []string
+	for resName, flavors := range usage {
+		for flvName, val := range flavors {
+			q := workload.ResourceQuantity(resName, val)
+			parts = append(parts, fmt.Sprintf("%s: %s in %s", resName, q.String(), flvName))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// findFlavorForGroup returns a flavor of the resource group which can
+// satisfy the requests of its resources, given that wUsed is the usage of
+// flavors by previous podsets.
+// If it finds a flavor, also returns any borrowing required. Otherwise, it
+// returns the reasons why the flavors that were considered don't fit.
+func findFlavorForGroup(
 	log logr.Logger,
-	name corev1.ResourceName,
-	val int64,
-	wUsed map[string]int64,
+	rg *cache.ResourceGroup,
+	requests workload.Requests,
+	wUsed cache.Resources,
 	resourceFlavors map[string]*kueue.ResourceFlavor,
 	cq *cache.ClusterQueue,
-	spec *corev1.PodSpec) (string, int64) {
+	spec *corev1.PodSpec) (string, map[corev1.ResourceName]int64, []string) {
 	// We will only check against the flavors' labels for the resource.
-	selector := flavorSelector(spec, cq.LabelKeys[name])
-	for _, flvLimit := range cq.RequestableResources[name] {
-		flavor, exist := resourceFlavors[flvLimit.Name]
+	selector := flavorSelector(spec, groupLabelKeys(rg, cq))
+	var choice flavorChoice
+	for i, flvName := range rg.Flavors {
+		flavor, exist := resourceFlavors[flvName]
 		if !exist {
-			log.Error(nil, "Flavor not found", "Flavor", flvLimit.Name)
+			log.Error(nil, "Flavor not found", "Flavor", flvName)
+			choice.reasons = append(choice.reasons, fmt.Sprintf("flavor %s not found", flvName))
 			continue
 		}
-		_, untolerated := corev1helpers.FindMatchingUntoleratedTaint(flavor.Taints, spec.Tolerations, func(t *corev1.Taint) bool {
-			return t.Effect == corev1.TaintEffectNoSchedule || t.Effect == corev1.TaintEffectNoExecute
-		})
-		if untolerated {
+		mismatch, err := flavorMismatch(flavor, spec, selector)
+		if err != nil {
+			log.Error(err, "Matching workload affinity against flavor; no flavor assigned")
+			return "", nil, []string{fmt.Sprintf("invalid node affinity: %v", err)}
+		}
+		if mismatch != "" {
+			choice.reasons = append(choice.reasons, mismatch)
+			continue
+		}
+
+		// Check considering the flavor usage by previous pod sets.
+		if choice.consider(rg, i, requests, wUsed, cq) {
+			break
+		}
+	}
+	return choice.flavor, choice.borrow, choice.reasons
+}
+
+// findFlavorForPodSets returns a flavor of the resource group which can
+// satisfy the requests of all the podSets for its resources. If it finds a
+// flavor, also returns any borrowing required. The third return value is the
+// total requests for the resources of the group, which are empty if no podSet
+// requests them. The last one is the reasons why the flavors that were
+// considered don't fit.
+func findFlavorForPodSets(
+	log logr.Logger,
+	rg *cache.ResourceGroup,
+	requests []workload.PodSetResources,
+	podSets []kueue.PodSet,
+	resourceFlavors map[string]*kueue.ResourceFlavor,
+	cq *cache.ClusterQueue) (string, map[corev1.ResourceName]int64, workload.Requests, []string) {
+	total := make(workload.Requests)
+	var requesting []int
+	for i, ps := range requests {
+		psRequests := requestsForGroup(rg, ps.Requests)
+		if len(psRequests) == 0 {
+			continue
+		}
+		for resName, val := range psRequests {
+			total[resName] += val
+		}
+		requesting = append(requesting, i)
+	}
+	if len(requesting) == 0 {
+		return "", nil, nil, nil
+	}
+	selectors := make([]nodeaffinity.RequiredNodeAffinity, len(requesting))
+	for j, i := range requesting {
+		selectors[j] = flavorSelector(&podSets[i].Spec, groupLabelKeys(rg, cq))
+	}
+	var choice flavorChoice
+	for i, flvName := range rg.Flavors {
+		flavor, exist := resourceFlavors[flvName]
+		if !exist {
+			log.Error(nil, "Flavor not found", "Flavor", flvName)
+			choice.reasons = append(choice.reasons, fmt.Sprintf("flavor %s not found", flvName))
 			continue
 		}
-		if match, err := selector.Match(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: flavor.Labels}}); !match || err != nil {
+		mismatch := ""
+		for j, k := range requesting {
+			var err error
+			mismatch, err = flavorMismatch(flavor, &podSets[k].Spec, selectors[j])
 			if err != nil {
 				log.Error(err, "Matching workload affinity against flavor; no flavor assigned")
-				return "", 0
+				return "", nil, total, []string{fmt.Sprintf("invalid node affinity: %v", err)}
+			}
+			if mismatch != "" {
+				break
 			}
+		}
+		if mismatch != "" {
+			choice.reasons = append(choice.reasons, mismatch)
 			continue
 		}
+		if choice.consider(rg, i, total, nil, cq) {
+			break
+		}
+	}
+	return choice.flavor, choice.borrow, total, choice.reasons
+}
 
-		// Check considering the flavor usage by previous pod sets.
-		ok, borrow := fitsFlavorLimits(name, val+wUsed[flavor.Name], cq, &flvLimit)
-		if ok {
-			return flavor.Name, borrow
+// groupLabelKeys returns the label keys of the flavors of the resource group.
+// All the resources of a group have the same flavors and, thus, the same
+// label keys.
+func groupLabelKeys(rg *cache.ResourceGroup, cq *cache.ClusterQueue) sets.String {
+	if len(rg.CoveredResources) == 0 {
+		return nil
+	}
+	return cq.LabelKeys[rg.CoveredResources[0]]
+}
+
+// flavorChoice holds the flavor chosen for a resource group while the flavors
+// are considered in order, following the flavorFungibility of the
+// clusterQueue, and the reasons why the flavors considered don't fit.
+type flavorChoice struct {
+	flavor  string
+	borrow  map[corev1.ResourceName]int64
+	reasons []string
+}
+
+// consider evaluates the i-th flavor of the resource group, which matches the
+// workload, for the requests of the resources of the group, given that wUsed
+// is the usage of flavors by previous podSets. It returns whether the search
+// should stop.
+func (c *flavorChoice) consider(rg *cache.ResourceGroup, i int, requests workload.Requests, wUsed cache.Resources, cq *cache.ClusterQueue) bool {
+	fits, canPreempt := true, true
+	var borrow map[corev1.ResourceName]int64
+	var insufficient []string
+	for resName, val := range requests {
+		flavor := &cq.RequestableResources[resName][i]
+		prevUsage := wUsed[resName][flavor.Name]
+		if ok, b := cq.FitsFlavorLimits(resName, val+prevUsage, flavor); ok {
+			if b > 0 {
+				if borrow == nil {
+					borrow = make(map[corev1.ResourceName]int64)
+				}
+				borrow[resName] = b
+			}
+			continue
 		}
+		fits = false
+		canPreempt = canPreempt && canPreemptFor(val+prevUsage, cq, flavor)
+		availableVal := availableInFlavor(resName, cq, flavor) - prevUsage
+		if availableVal < 0 {
+			availableVal = 0
+		}
+		need := workload.ResourceQuantity(resName, val)
+		available := workload.ResourceQuantity(resName, availableVal)
+		insufficient = append(insufficient, fmt.Sprintf("insufficient %s in flavor %s (need %s, available %s)",
+			resName, flavor.Name, need.String(), available.String()))
+	}
+	sort.Strings(insufficient)
+	c.reasons = append(c.reasons, insufficient...)
+	if fits {
+		if len(borrow) == 0 || cq.FlavorFungibility.WhenCanBorrow != kueue.TryNextFlavor {
+			c.flavor, c.borrow = rg.Flavors[i], borrow
+			return true
+		}
+		// Keep the first flavor that fits by borrowing, in case no next
+		// flavor fits without borrowing.
+		if c.flavor == "" {
+			c.flavor, c.borrow = rg.Flavors[i], borrow
+		}
+		return false
+	}
+	// Stop, so that the workload preempts in this flavor, unless a previous
+	// flavor fits by borrowing.
+	return cq.FlavorFungibility.WhenCanPreempt == kueue.Preempt && canPreempt
+}
+
+// canPreemptFor returns whether a request of val could fit in the flavor by
+// preempting workloads, following the preemption policies of the
+// clusterQueue.
+func canPreemptFor(val int64, cq *cache.ClusterQueue, flavor *cache.FlavorLimits) bool {
+	withinCohort := cq.Preemption.WithinCohort
+	canPreempt := cq.Preemption.WithinClusterQueue == kueue.PreemptionPolicyLowerPriority ||
+		cq.Cohort != nil && (withinCohort == kueue.PreemptionPolicyLowerPriority || withinCohort == kueue.PreemptionPolicyAny)
+	return canPreempt && val <= flavor.Nominal
+}
+
+// flavorMismatch returns why the flavor doesn't match the pods described by
+// spec: because they don't tolerate a taint of the flavor or because the
+// selector doesn't match the flavor labels. It returns an empty string if the
+// flavor matches.
+func flavorMismatch(flavor *kueue.ResourceFlavor, spec *corev1.PodSpec, selector nodeaffinity.RequiredNodeAffinity) (string, error) {
+	taint, untolerated := corev1helpers.FindMatchingUntoleratedTaint(flavor.Taints, spec.Tolerations, func(t *corev1.Taint) bool {
+		return t.Effect == corev1.TaintEffectNoSchedule || t.Effect == corev1.TaintEffectNoExecute
+	})
+	if untolerated {
+		return fmt.Sprintf("untolerated taint %s in flavor %s", taint.ToString(), flavor.Name), nil
+	}
+	match, err := selector.Match(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: flavor.Labels}})
+	if err != nil || match {
+		return "", err
 	}
-	return "", 0
+	return fmt.Sprintf("flavor %s doesn't match the node affinity", flavor.Name), nil
 }
 
 func flavorSelector(spec *corev1.PodSpec, allowedKeys sets.String) nodeaffinity.RequiredNodeAffinity {
@@ -363,30 +1137,36 @@ func flavorSelector(spec *corev1.PodSpec, allowedKeys sets.String) nodeaffinity.
 	return nodeaffinity.GetRequiredNodeAffinity(&corev1.Pod{Spec: specCopy})
 }
 
-// fitsFlavorLimits returns whether a requested resource fits in a specific flavor's quota limits.
-// If it fits, also returns any borrowing required.
-func fitsFlavorLimits(name corev1.ResourceName, val int64, cq *cache.ClusterQueue, flavor *cache.FlavorLimits) (bool, int64) {
+// availableInFlavor returns the largest request of the resource that fits in
+// the flavor, following the same limits as cache.ClusterQueue.FitsFlavorLimits.
+func availableInFlavor(name corev1.ResourceName, cq *cache.ClusterQueue, flavor *cache.FlavorLimits) int64 {
 	used := cq.UsedResources[name][flavor.Name]
-	if flavor.Max != nil && used+val > *flavor.Max {
-		// Past borrowing limit.
-		return false, 0
-	}
-	cohortUsed := used
-	cohortTotal := flavor.Min
+	available := flavor.Nominal - used
 	if cq.Cohort != nil {
-		cohortUsed = cq.Cohort.UsedResources[name][flavor.Name]
-		cohortTotal = cq.Cohort.RequestableResources[name][flavor.Name]
-	}
-	borrow := used + val - flavor.Min
-	if borrow < 0 {
-		borrow = 0
+		// The usage past the guaranteed quota comes from the cohort.
+		guaranteed := flavor.Guaranteed() - used
+		if guaranteed < 0 {
+			guaranteed = 0
+		}
+		root := cq.Cohort.Root()
+		fromCohort := root.RequestableResources[name][flavor.Name] - root.UsedResources[name][flavor.Name]
+		for cohort := cq.Cohort; cohort != nil; cohort = cohort.Parent {
+			if limit, ok := cohort.Limits[name][flavor.Name]; ok && limit-cohort.UsedResources[name][flavor.Name] < fromCohort {
+				fromCohort = limit - cohort.UsedResources[name][flavor.Name]
+			}
+		}
+		if fromCohort < 0 {
+			fromCohort = 0
+		}
+		available = guaranteed + fromCohort
+		if flavor.BorrowingLimit != nil && flavor.Nominal+*flavor.BorrowingLimit-used < available {
+			available = flavor.Nominal + *flavor.BorrowingLimit - used
+		}
 	}
-	if cohortUsed+val > cohortTotal {
-		// Doesn't fit even with borrowing.
-		// TODO(PostMVP): preemption could help if borrow == 0
-		return false, 0
+	if available < 0 {
+		return 0
 	}
-	return true, borrow
+	return available
 }
 
 type entryOrdering []entry
@@ -400,19 +1180,24 @@ func (e entryOrdering) Swap(i, j int) {
 }
 
 // Less is the ordering criteria:
-// 1. request under min quota before borrowing.
-// 2. FIFO on creation timestamp.
+// 1. request under nominal quota before borrowing.
+// 2. lower weighted dominant resource share of the cohort, with fair sharing.
+// 3. FIFO on the queue order timestamp: creation or PodsReady timeout eviction.
 func (e entryOrdering) Less(i, j int) bool {
 	a := e[i]
 	b := e[j]
-	// 1. Request under min quota.
+	// 1. Request under nominal quota.
 	aMin := len(a.borrows) == 0
 	bMin := len(b.borrows) == 0
 	if aMin != bMin {
 		return aMin
 	}
-	// 2. FIFO.
-	return a.Obj.CreationTimestamp.Before(&b.Obj.CreationTimestamp)
+	// 2. Lower dominant resource share.
+	if a.dominantResourceShare != b.dominantResourceShare {
+		return a.dominantResourceShare < b.dominantResourceShare
+	}
+	// 3. FIFO.
+	return workload.QueueOrderTimestamp(a.Obj).Before(workload.QueueOrderTimestamp(b.Obj))
 }
 
 func (s *Scheduler) requeueAndUpdate(log logr.Logger, ctx context.Context, e entry) {
@@ -420,7 +1205,15 @@ func (s *Scheduler) requeueAndUpdate(log logr.Logger, ctx context.Context, e ent
 	log.V(2).Info("Workload re-queued", "workload", klog.KObj(e.Obj), "queue", klog.KRef(e.Obj.Namespace, e.Obj.Spec.QueueName), "added", added, "status", e.status)
 
 	if e.status == "" {
-		err := workload.UpdateStatus(ctx, s.client, e.Obj, kueue.WorkloadAdmitted, corev1.ConditionFalse, "Pending", e.inadmissibleReason)
+		var backoff *kueue.AdmissionBackoff
+		if attempts, nextAttempt := s.queues.Backoff(e.Obj); nextAttempt != nil {
+			backoff = &kueue.AdmissionBackoff{
+				Attempts:        attempts,
+				Reason:          e.inadmissibleReason,
+				NextAttemptTime: metav1.NewTime(*nextAttempt),
+			}
+		}
+		err := workload.UpdatePendingStatus(ctx, s.client, e.Obj, e.inadmissibleReason, backoff)
 		if err != nil {
 			log.Error(err, "Could not update Workload status")
 		}
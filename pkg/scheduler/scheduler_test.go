@@ -19,6 +19,7 @@ package scheduler
 import (
 	"context"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -72,16 +73,17 @@ func TestSchedule(t *testing.T) {
 					},
 				},
 				QueueingStrategy: kueue.StrictFIFO,
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "default",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("50"),
-									Max: pointer.Quantity(resource.MustParse("50")),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:           corev1.ResourceCPU,
+									NominalQuota:   resource.MustParse("50"),
+									BorrowingLimit: pointer.Quantity(resource.MustParse("0")),
+								}},
 							},
 						},
 					},
@@ -102,23 +104,25 @@ func TestSchedule(t *testing.T) {
 					},
 				},
 				QueueingStrategy: kueue.StrictFIFO,
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "on-demand",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("50"),
-									Max: pointer.Quantity(resource.MustParse("100")),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:           corev1.ResourceCPU,
+									NominalQuota:   resource.MustParse("50"),
+									BorrowingLimit: pointer.Quantity(resource.MustParse("50")),
+								}},
 							},
 							{
 								Name: "spot",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("100"),
-									Max: pointer.Quantity(resource.MustParse("100")),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:           corev1.ResourceCPU,
+									NominalQuota:   resource.MustParse("100"),
+									BorrowingLimit: pointer.Quantity(resource.MustParse("0")),
+								}},
 							},
 						},
 					},
@@ -139,41 +143,94 @@ func TestSchedule(t *testing.T) {
 					},
 				},
 				QueueingStrategy: kueue.StrictFIFO,
-				Resources: []kueue.Resource{
+				ResourceGroups: []kueue.ResourceGroup{
 					{
-						Name: corev1.ResourceCPU,
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "on-demand",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("50"),
-									Max: pointer.Quantity(resource.MustParse("60")),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:           corev1.ResourceCPU,
+									NominalQuota:   resource.MustParse("50"),
+									BorrowingLimit: pointer.Quantity(resource.MustParse("10")),
+								}},
 							},
 							{
 								Name: "spot",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("0"),
-									Max: pointer.Quantity(resource.MustParse("100")),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:           corev1.ResourceCPU,
+									NominalQuota:   resource.MustParse("0"),
+									BorrowingLimit: pointer.Quantity(resource.MustParse("100")),
+								}},
 							},
 						},
 					},
 					{
-						Name: "example.com/gpu",
-						Flavors: []kueue.Flavor{
+						CoveredResources: []corev1.ResourceName{"example.com/gpu"},
+						Flavors: []kueue.FlavorQuotas{
 							{
 								Name: "model-a",
-								Quota: kueue.Quota{
-									Min: resource.MustParse("20"),
-									Max: pointer.Quantity(resource.MustParse("20")),
-								},
+								Resources: []kueue.ResourceQuota{{
+									Name:           "example.com/gpu",
+									NominalQuota:   resource.MustParse("20"),
+									BorrowingLimit: pointer.Quantity(resource.MustParse("0")),
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "checked"},
+			Spec: kueue.ClusterQueueSpec{
+				NamespaceSelector: &metav1.LabelSelector{},
+				QueueingStrategy:  kueue.BestEffortFIFO,
+				ResourceGroups: []kueue.ResourceGroup{
+					{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
+							{
+								Name: "default",
+								Resources: []kueue.ResourceQuota{{
+									Name:         corev1.ResourceCPU,
+									NominalQuota: resource.MustParse("10"),
+								}},
+							},
+						},
+					},
+				},
+				AdmissionChecks: []string{"budget", "provisioning"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "limited"},
+			Spec: kueue.ClusterQueueSpec{
+				NamespaceSelector: &metav1.LabelSelector{},
+				QueueingStrategy:  kueue.StrictFIFO,
+				ResourceGroups: []kueue.ResourceGroup{
+					{
+						CoveredResources: []corev1.ResourceName{corev1.ResourceCPU},
+						Flavors: []kueue.FlavorQuotas{
+							{
+								Name: "default",
+								Resources: []kueue.ResourceQuota{{
+									Name:         corev1.ResourceCPU,
+									NominalQuota: resource.MustParse("10"),
+								}},
 							},
 						},
 					},
 				},
+				MaxAdmittedWorkloads: pointer.Int32(1),
 			},
 		},
+		*utiltesting.MakeClusterQueue("shared").
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "10").Obj()).
+				Obj()).
+			NamespaceQuota(corev1.ResourceCPU, "4").
+			Obj(),
 	}
 	queues := []kueue.Queue{
 		{
@@ -185,6 +242,16 @@ func TestSchedule(t *testing.T) {
 				ClusterQueue: "sales",
 			},
 		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "sales",
+				Name:      "limited",
+			},
+			Spec: kueue.QueueSpec{
+				ClusterQueue:         "sales",
+				MaxAdmittedWorkloads: pointer.Int32(1),
+			},
+		},
 		{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace: "sales",
@@ -212,16 +279,71 @@ func TestSchedule(t *testing.T) {
 				ClusterQueue: "eng-beta",
 			},
 		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "sales",
+				Name:      "checked",
+			},
+			Spec: kueue.QueueSpec{
+				ClusterQueue: "checked",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "sales",
+				Name:      "limited-cq",
+			},
+			Spec: kueue.QueueSpec{
+				ClusterQueue: "limited",
+			},
+		},
+		*utiltesting.MakeQueue("shared", "sales").ClusterQueue("shared").Obj(),
 	}
 	cases := map[string]struct {
 		workloads []kueue.Workload
+		// waitForPodsReady blocks the admission of more than one workload per cycle.
+		waitForPodsReady bool
+		// dryRun only records the admissions in the status of the workloads.
+		dryRun bool
 		// wantAssignments is a summary of all the admissions in the cache after this cycle.
 		wantAssignments map[string]kueue.Admission
 		// wantScheduled is the subset of workloads that got scheduled/admitted in this cycle.
 		wantScheduled []string
 		// wantLeft is the workload keys that are left in the queues after this cycle.
 		wantLeft map[string]sets.String
+		// wantPendingMessages are the messages of the Admitted condition of
+		// some of the pending workloads after this cycle.
+		wantPendingMessages map[string]string
 	}{
+		"dry run records the admission without admitting": {
+			dryRun: true,
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "sales",
+						Name:      "foo",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 10,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+			},
+			wantLeft: map[string]sets.String{
+				"sales": sets.NewString("foo"),
+			},
+			wantPendingMessages: map[string]string{
+				"sales/foo": "Dry run: would be admitted by ClusterQueue sales, using cpu: 10 in default",
+			},
+		},
 		"workload fits in single clusterQueue": {
 			workloads: []kueue.Workload{
 				{
@@ -324,7 +446,7 @@ func TestSchedule(t *testing.T) {
 				"sales": sets.NewString("new"),
 			},
 		},
-		"failed to match clusterQueue selector": {
+		"workload partially admitted down to the remaining quota": {
 			workloads: []kueue.Workload{
 				{
 					ObjectMeta: metav1.ObjectMeta{
@@ -332,11 +454,12 @@ func TestSchedule(t *testing.T) {
 						Name:      "new",
 					},
 					Spec: kueue.WorkloadSpec{
-						QueueName: "blocked",
+						QueueName: "main",
 						PodSets: []kueue.PodSet{
 							{
-								Name:  "one",
-								Count: 1,
+								Name:     "one",
+								Count:    15,
+								MinCount: pointer.Int32(5),
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
@@ -344,52 +467,37 @@ func TestSchedule(t *testing.T) {
 						},
 					},
 				},
-			},
-			wantLeft: map[string]sets.String{
-				"eng-alpha": sets.NewString("new"),
-			},
-		},
-		"assign to different cohorts": {
-			workloads: []kueue.Workload{
 				{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: "sales",
-						Name:      "new",
+						Name:      "assigned",
 					},
 					Spec: kueue.WorkloadSpec{
-						QueueName: "main",
 						PodSets: []kueue.PodSet{
 							{
 								Name:  "one",
-								Count: 1,
+								Count: 40,
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
 							},
 						},
-					},
-				},
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Namespace: "eng-alpha",
-						Name:      "new",
-					},
-					Spec: kueue.WorkloadSpec{
-						QueueName: "main",
-						PodSets: []kueue.PodSet{
-							{
-								Name:  "one",
-								Count: 51, // will borrow.
-								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-									corev1.ResourceCPU: "1",
-								}),
+						Admission: &kueue.Admission{
+							ClusterQueue: "sales",
+							PodSetFlavors: []kueue.PodSetFlavors{
+								{
+									Name: "one",
+									Flavors: map[corev1.ResourceName]string{
+										corev1.ResourceCPU: "default",
+									},
+								},
 							},
 						},
 					},
 				},
 			},
 			wantAssignments: map[string]kueue.Admission{
-				"sales/new": {
+				"sales/assigned": {
 					ClusterQueue: "sales",
 					PodSetFlavors: []kueue.PodSetFlavors{
 						{
@@ -400,33 +508,35 @@ func TestSchedule(t *testing.T) {
 						},
 					},
 				},
-				"eng-alpha/new": {
-					ClusterQueue: "eng-alpha",
+				"sales/new": {
+					ClusterQueue: "sales",
 					PodSetFlavors: []kueue.PodSetFlavors{
 						{
 							Name: "one",
 							Flavors: map[corev1.ResourceName]string{
-								corev1.ResourceCPU: "on-demand",
+								corev1.ResourceCPU: "default",
 							},
+							Count: pointer.Int32(10),
 						},
 					},
 				},
 			},
-			wantScheduled: []string{"sales/new", "eng-alpha/new"},
+			wantScheduled: []string{"sales/new"},
 		},
-		"assign to same cohort no borrowing": {
+		"workload doesn't fit with its minCount": {
 			workloads: []kueue.Workload{
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: "eng-alpha",
+						Namespace: "sales",
 						Name:      "new",
 					},
 					Spec: kueue.WorkloadSpec{
 						QueueName: "main",
 						PodSets: []kueue.PodSet{
 							{
-								Name:  "one",
-								Count: 40,
+								Name:     "one",
+								Count:    15,
+								MinCount: pointer.Int32(11),
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
@@ -436,11 +546,10 @@ func TestSchedule(t *testing.T) {
 				},
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: "eng-beta",
-						Name:      "new",
+						Namespace: "sales",
+						Name:      "assigned",
 					},
 					Spec: kueue.WorkloadSpec{
-						QueueName: "main",
 						PodSets: []kueue.PodSet{
 							{
 								Name:  "one",
@@ -450,99 +559,156 @@ func TestSchedule(t *testing.T) {
 								}),
 							},
 						},
-					},
-				},
-			},
-			wantAssignments: map[string]kueue.Admission{
-				"eng-alpha/new": {
-					ClusterQueue: "eng-alpha",
-					PodSetFlavors: []kueue.PodSetFlavors{
-						{
-							Name: "one",
-							Flavors: map[corev1.ResourceName]string{
-								corev1.ResourceCPU: "on-demand",
+						Admission: &kueue.Admission{
+							ClusterQueue: "sales",
+							PodSetFlavors: []kueue.PodSetFlavors{
+								{
+									Name: "one",
+									Flavors: map[corev1.ResourceName]string{
+										corev1.ResourceCPU: "default",
+									},
+								},
 							},
 						},
 					},
 				},
-				"eng-beta/new": {
-					ClusterQueue: "eng-beta",
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"sales/assigned": {
+					ClusterQueue: "sales",
 					PodSetFlavors: []kueue.PodSetFlavors{
 						{
 							Name: "one",
 							Flavors: map[corev1.ResourceName]string{
-								corev1.ResourceCPU: "on-demand",
+								corev1.ResourceCPU: "default",
 							},
 						},
 					},
 				},
 			},
-			wantScheduled: []string{"eng-alpha/new", "eng-beta/new"},
+			wantLeft: map[string]sets.String{
+				"sales": sets.NewString("new"),
+			},
 		},
-		"assign multiple resources and flavors": {
+		"queue reached its maximum number of admitted workloads": {
 			workloads: []kueue.Workload{
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: "eng-beta",
+						Namespace: "sales",
 						Name:      "new",
 					},
 					Spec: kueue.WorkloadSpec{
-						QueueName: "main",
+						QueueName: "limited",
 						PodSets: []kueue.PodSet{
 							{
 								Name:  "one",
-								Count: 10,
+								Count: 1,
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-									corev1.ResourceCPU: "6", // Needs to borrow.
-									"example.com/gpu":  "1",
+									corev1.ResourceCPU: "1",
 								}),
 							},
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "sales",
+						Name:      "assigned",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "limited",
+						PodSets: []kueue.PodSet{
 							{
-								Name:  "two",
-								Count: 40,
+								Name:  "one",
+								Count: 1,
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
 							},
 						},
+						Admission: &kueue.Admission{
+							ClusterQueue: "sales",
+							PodSetFlavors: []kueue.PodSetFlavors{
+								{
+									Name: "one",
+									Flavors: map[corev1.ResourceName]string{
+										corev1.ResourceCPU: "default",
+									},
+								},
+							},
+						},
 					},
 				},
 			},
 			wantAssignments: map[string]kueue.Admission{
-				"eng-beta/new": {
-					ClusterQueue: "eng-beta",
+				"sales/assigned": {
+					ClusterQueue: "sales",
 					PodSetFlavors: []kueue.PodSetFlavors{
 						{
 							Name: "one",
 							Flavors: map[corev1.ResourceName]string{
-								corev1.ResourceCPU: "on-demand",
-								"example.com/gpu":  "model-a",
-							},
-						},
-						{
-							Name: "two",
-							Flavors: map[corev1.ResourceName]string{
-								corev1.ResourceCPU: "spot",
+								corev1.ResourceCPU: "default",
 							},
 						},
 					},
 				},
 			},
-			wantScheduled: []string{"eng-beta/new"},
+			wantLeft: map[string]sets.String{
+				"sales": sets.NewString("new"),
+			},
+		},
+		"clusterQueue reached its maximum number of admitted workloads": {
+			workloads: []kueue.Workload{
+				*utiltesting.MakeWorkload("new", "sales").
+					Queue("limited-cq").
+					Request(corev1.ResourceCPU, "1").
+					Obj(),
+				*utiltesting.MakeWorkload("assigned", "sales").
+					Queue("limited-cq").
+					Request(corev1.ResourceCPU, "1").
+					Admit(utiltesting.MakeAdmission("limited").Flavor(corev1.ResourceCPU, "default").Obj()).
+					Obj(),
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"sales/assigned": *utiltesting.MakeAdmission("limited").Flavor(corev1.ResourceCPU, "default").Obj(),
+			},
+			wantLeft: map[string]sets.String{
+				"limited": sets.NewString("new"),
+			},
 		},
-		"cannot borrow if cohort was assigned": {
+		"namespace reached its quota in the clusterQueue": {
+			workloads: []kueue.Workload{
+				*utiltesting.MakeWorkload("new", "sales").
+					Queue("shared").
+					Request(corev1.ResourceCPU, "2").
+					Obj(),
+				*utiltesting.MakeWorkload("assigned", "sales").
+					Queue("shared").
+					Request(corev1.ResourceCPU, "3").
+					Admit(utiltesting.MakeAdmission("shared").Flavor(corev1.ResourceCPU, "default").Obj()).
+					Obj(),
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"sales/assigned": *utiltesting.MakeAdmission("shared").Flavor(corev1.ResourceCPU, "default").Obj(),
+			},
+			wantLeft: map[string]sets.String{
+				"shared": sets.NewString("new"),
+			},
+		},
+		"workload partially admitted down to the namespace quota": {
 			workloads: []kueue.Workload{
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: "eng-alpha",
+						Namespace: "sales",
 						Name:      "new",
 					},
 					Spec: kueue.WorkloadSpec{
-						QueueName: "main",
+						QueueName: "shared",
 						PodSets: []kueue.PodSet{
 							{
-								Name:  "one",
-								Count: 40,
+								Name:     "main",
+								Count:    5,
+								MinCount: pointer.Int32(2),
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
@@ -550,17 +716,42 @@ func TestSchedule(t *testing.T) {
 						},
 					},
 				},
+				*utiltesting.MakeWorkload("assigned", "sales").
+					Queue("shared").
+					Request(corev1.ResourceCPU, "1").
+					Admit(utiltesting.MakeAdmission("shared").Flavor(corev1.ResourceCPU, "default").Obj()).
+					Obj(),
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"sales/assigned": *utiltesting.MakeAdmission("shared").Flavor(corev1.ResourceCPU, "default").Obj(),
+				"sales/new": {
+					ClusterQueue: "shared",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "main",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "default",
+							},
+							Count: pointer.Int32(3),
+						},
+					},
+				},
+			},
+			wantScheduled: []string{"sales/new"},
+		},
+		"admission includes the checks of the clusterQueue": {
+			workloads: []kueue.Workload{
 				{
 					ObjectMeta: metav1.ObjectMeta{
-						Namespace: "eng-beta",
+						Namespace: "sales",
 						Name:      "new",
 					},
 					Spec: kueue.WorkloadSpec{
-						QueueName: "main",
+						QueueName: "checked",
 						PodSets: []kueue.PodSet{
 							{
 								Name:  "one",
-								Count: 51,
+								Count: 1,
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
@@ -570,30 +761,151 @@ func TestSchedule(t *testing.T) {
 				},
 			},
 			wantAssignments: map[string]kueue.Admission{
-				"eng-alpha/new": {
-					ClusterQueue: "eng-alpha",
+				"sales/new": {
+					ClusterQueue: "checked",
 					PodSetFlavors: []kueue.PodSetFlavors{
 						{
 							Name: "one",
 							Flavors: map[corev1.ResourceName]string{
-								corev1.ResourceCPU: "on-demand",
+								corev1.ResourceCPU: "default",
 							},
 						},
 					},
+					AdmissionChecks: []string{"budget", "provisioning"},
+				},
+			},
+			wantScheduled: []string{"sales/new"},
+		},
+		"waiting for the requeue backoff": {
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "sales",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 1,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+					Status: kueue.WorkloadStatus{
+						RequeueState: &kueue.RequeueState{
+							Count:     1,
+							RequeueAt: &metav1.Time{Time: time.Now().Add(time.Minute)},
+						},
+					},
 				},
 			},
-			wantScheduled: []string{"eng-alpha/new"},
 			wantLeft: map[string]sets.String{
-				"eng-beta": sets.NewString("new"),
+				"sales": sets.NewString("new"),
 			},
 		},
-		"cannot borrow resource not listed in clusterQueue": {
+		"failed to match clusterQueue selector": {
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "sales",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "blocked",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 1,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+			},
+			wantLeft: map[string]sets.String{
+				"eng-alpha": sets.NewString("new"),
+			},
+		},
+		"assign to different cohorts": {
 			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "sales",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 1,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
 				{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: "eng-alpha",
 						Name:      "new",
 					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 51, // will borrow.
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"sales/new": {
+					ClusterQueue: "sales",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "default",
+							},
+						},
+					},
+				},
+				"eng-alpha/new": {
+					ClusterQueue: "eng-alpha",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "on-demand",
+							},
+						},
+					},
+				},
+			},
+			wantScheduled: []string{"sales/new", "eng-alpha/new"},
+		},
+		"admits a single workload when waiting for the pods to be ready": {
+			waitForPodsReady: true,
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace:         "sales",
+						Name:              "new",
+						CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Second)),
+					},
 					Spec: kueue.WorkloadSpec{
 						QueueName: "main",
 						PodSets: []kueue.PodSet{
@@ -601,18 +913,51 @@ func TestSchedule(t *testing.T) {
 								Name:  "one",
 								Count: 1,
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-									"example.com/gpu": "1",
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace:         "eng-alpha",
+						Name:              "new",
+						CreationTimestamp: metav1.NewTime(time.Now()),
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 1,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
 								}),
 							},
 						},
 					},
 				},
 			},
+			wantAssignments: map[string]kueue.Admission{
+				"sales/new": {
+					ClusterQueue: "sales",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "default",
+							},
+						},
+					},
+				},
+			},
+			wantScheduled: []string{"sales/new"},
 			wantLeft: map[string]sets.String{
 				"eng-alpha": sets.NewString("new"),
 			},
 		},
-		"not enough resources to borrow, fallback to next flavor": {
+		"assign to same cohort no borrowing": {
 			workloads: []kueue.Workload{
 				{
 					ObjectMeta: metav1.ObjectMeta{
@@ -624,7 +969,7 @@ func TestSchedule(t *testing.T) {
 						PodSets: []kueue.PodSet{
 							{
 								Name:  "one",
-								Count: 60,
+								Count: 40,
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
@@ -635,29 +980,19 @@ func TestSchedule(t *testing.T) {
 				{
 					ObjectMeta: metav1.ObjectMeta{
 						Namespace: "eng-beta",
-						Name:      "existing",
+						Name:      "new",
 					},
 					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
 						PodSets: []kueue.PodSet{
 							{
 								Name:  "one",
-								Count: 45,
+								Count: 40,
 								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 									corev1.ResourceCPU: "1",
 								}),
 							},
 						},
-						Admission: &kueue.Admission{
-							ClusterQueue: "eng-beta",
-							PodSetFlavors: []kueue.PodSetFlavors{
-								{
-									Name: "one",
-									Flavors: map[corev1.ResourceName]string{
-										corev1.ResourceCPU: "on-demand",
-									},
-								},
-							},
-						},
 					},
 				},
 			},
@@ -668,12 +1003,12 @@ func TestSchedule(t *testing.T) {
 						{
 							Name: "one",
 							Flavors: map[corev1.ResourceName]string{
-								corev1.ResourceCPU: "spot",
+								corev1.ResourceCPU: "on-demand",
 							},
 						},
 					},
 				},
-				"eng-beta/existing": {
+				"eng-beta/new": {
 					ClusterQueue: "eng-beta",
 					PodSetFlavors: []kueue.PodSetFlavors{
 						{
@@ -685,89 +1020,371 @@ func TestSchedule(t *testing.T) {
 					},
 				},
 			},
-			wantScheduled: []string{"eng-alpha/new"},
+			wantScheduled: []string{"eng-alpha/new", "eng-beta/new"},
 		},
-	}
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
-				Verbosity: 2,
-			})
-			ctx := ctrl.LoggerInto(context.Background(), log)
-			scheme := runtime.NewScheme()
-			if err := kueue.AddToScheme(scheme); err != nil {
-				t.Fatalf("Failed adding kueue scheme: %v", err)
-			}
-			if err := corev1.AddToScheme(scheme); err != nil {
-				t.Fatalf("Failed adding kueue scheme: %v", err)
-			}
-			clientBuilder := fake.NewClientBuilder().WithScheme(scheme).
-				WithLists(&kueue.WorkloadList{Items: tc.workloads}, &kueue.QueueList{Items: queues}).
-				WithObjects(
-					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "eng-alpha", Labels: map[string]string{"dep": "eng"}}},
-					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "eng-beta", Labels: map[string]string{"dep": "eng"}}},
-					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sales", Labels: map[string]string{"dep": "sales"}}},
-				)
-			cl := clientBuilder.Build()
-			broadcaster := record.NewBroadcaster()
-			recorder := broadcaster.NewRecorder(scheme,
-				corev1.EventSource{Component: constants.ManagerName})
-			qManager := queue.NewManager(cl)
-			cqCache := cache.New(cl)
-			// Workloads are loaded into queues or clusterQueues as we add them.
-			for _, q := range queues {
-				if err := qManager.AddQueue(ctx, &q); err != nil {
-					t.Fatalf("Inserting queue %s/%s in manager: %v", q.Namespace, q.Name, err)
-				}
-			}
-			for _, cq := range clusterQueues {
-				if err := cqCache.AddClusterQueue(ctx, &cq); err != nil {
-					t.Fatalf("Inserting clusterQueue %s in cache: %v", cq.Name, err)
-				}
-				if err := qManager.AddClusterQueue(ctx, &cq); err != nil {
-					t.Fatalf("Inserting clusterQueue %s in manager: %v", cq.Name, err)
-				}
-			}
-			for i := range resourceFlavors {
-				cqCache.AddOrUpdateResourceFlavor(resourceFlavors[i])
-			}
-			workloadWatch, err := cl.Watch(ctx, &kueue.WorkloadList{})
-			if err != nil {
-				t.Fatalf("Failed setting up watch: %v", err)
-			}
-			scheduler := New(qManager, cqCache, cl, recorder)
-			wg := sync.WaitGroup{}
-			scheduler.setAdmissionRoutineWrapper(routine.NewWrapper(
-				func() { wg.Add(1) },
-				func() { wg.Done() },
-			))
-
-			ctx, cancel := context.WithTimeout(ctx, queueingTimeout)
-			go qManager.CleanUpOnContext(ctx)
-			defer cancel()
-			scheduler.schedule(ctx)
-
-			// Verify assignments in API.
-			gotScheduled := make(map[string]kueue.Admission)
-			timedOut := false
-			for !timedOut && len(gotScheduled) < len(tc.wantScheduled) {
-				select {
-				case evt := <-workloadWatch.ResultChan():
-					w, ok := evt.Object.(*kueue.Workload)
-					if !ok {
-						t.Fatalf("Received update for %T, want Workload", evt.Object)
-					}
-					if w.Spec.Admission != nil {
-						gotScheduled[workload.Key(w)] = *w.Spec.Admission
-					}
-				case <-time.After(watchTimeout):
-					t.Errorf("Timed out waiting for Workload updates")
-					timedOut = true
-				}
-			}
-			wg.Wait()
-			wantScheduled := make(map[string]kueue.Admission)
-			for _, key := range tc.wantScheduled {
+		"assign multiple resources and flavors": {
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-beta",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 10,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "6", // Needs to borrow.
+									"example.com/gpu":  "1",
+								}),
+							},
+							{
+								Name:  "two",
+								Count: 40,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"eng-beta/new": {
+					ClusterQueue: "eng-beta",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "on-demand",
+								"example.com/gpu":  "model-a",
+							},
+						},
+						{
+							Name: "two",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "spot",
+							},
+						},
+					},
+				},
+			},
+			wantScheduled: []string{"eng-beta/new"},
+		},
+		"borrows the remaining quota of a cohort assigned in the same cycle": {
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-alpha",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 40,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-beta",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 51,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"eng-alpha/new": {
+					ClusterQueue: "eng-alpha",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "on-demand",
+							},
+						},
+					},
+				},
+				"eng-beta/new": {
+					ClusterQueue: "eng-beta",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "on-demand",
+							},
+						},
+					},
+				},
+			},
+			wantScheduled: []string{"eng-alpha/new", "eng-beta/new"},
+		},
+		"takes the next flavor when the quota of the cohort is taken in the same cycle": {
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-alpha",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 45,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-beta",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 60,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"eng-alpha/new": {
+					ClusterQueue: "eng-alpha",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "on-demand",
+							},
+						},
+					},
+				},
+				"eng-beta/new": {
+					ClusterQueue: "eng-beta",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "spot",
+							},
+						},
+					},
+				},
+			},
+			wantScheduled: []string{"eng-alpha/new", "eng-beta/new"},
+		},
+		"cannot borrow resource not listed in clusterQueue": {
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-alpha",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 1,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									"example.com/gpu": "1",
+								}),
+							},
+						},
+					},
+				},
+			},
+			wantLeft: map[string]sets.String{
+				"eng-alpha": sets.NewString("new"),
+			},
+		},
+		"not enough resources to borrow, fallback to next flavor": {
+			workloads: []kueue.Workload{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-alpha",
+						Name:      "new",
+					},
+					Spec: kueue.WorkloadSpec{
+						QueueName: "main",
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 60,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "eng-beta",
+						Name:      "existing",
+					},
+					Spec: kueue.WorkloadSpec{
+						PodSets: []kueue.PodSet{
+							{
+								Name:  "one",
+								Count: 45,
+								Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "1",
+								}),
+							},
+						},
+						Admission: &kueue.Admission{
+							ClusterQueue: "eng-beta",
+							PodSetFlavors: []kueue.PodSetFlavors{
+								{
+									Name: "one",
+									Flavors: map[corev1.ResourceName]string{
+										corev1.ResourceCPU: "on-demand",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantAssignments: map[string]kueue.Admission{
+				"eng-alpha/new": {
+					ClusterQueue: "eng-alpha",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "spot",
+							},
+						},
+					},
+				},
+				"eng-beta/existing": {
+					ClusterQueue: "eng-beta",
+					PodSetFlavors: []kueue.PodSetFlavors{
+						{
+							Name: "one",
+							Flavors: map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "on-demand",
+							},
+						},
+					},
+				},
+			},
+			wantScheduled: []string{"eng-alpha/new"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+				Verbosity: 2,
+			})
+			ctx := ctrl.LoggerInto(context.Background(), log)
+			scheme := runtime.NewScheme()
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			if err := corev1.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			clientBuilder := fake.NewClientBuilder().WithScheme(scheme).
+				WithLists(&kueue.WorkloadList{Items: tc.workloads}, &kueue.QueueList{Items: queues}).
+				WithObjects(
+					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "eng-alpha", Labels: map[string]string{"dep": "eng"}}},
+					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "eng-beta", Labels: map[string]string{"dep": "eng"}}},
+					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "sales", Labels: map[string]string{"dep": "sales"}}},
+				)
+			cl := clientBuilder.Build()
+			broadcaster := record.NewBroadcaster()
+			recorder := broadcaster.NewRecorder(scheme,
+				corev1.EventSource{Component: constants.ManagerName})
+			qManager := queue.NewManager(cl)
+			cqCache := cache.New(cl)
+			// Workloads are loaded into queues or clusterQueues as we add them.
+			for _, q := range queues {
+				if err := qManager.AddQueue(ctx, &q); err != nil {
+					t.Fatalf("Inserting queue %s/%s in manager: %v", q.Namespace, q.Name, err)
+				}
+				cqCache.AddOrUpdateQueue(&q)
+			}
+			for _, cq := range clusterQueues {
+				if err := cqCache.AddClusterQueue(ctx, &cq); err != nil {
+					t.Fatalf("Inserting clusterQueue %s in cache: %v", cq.Name, err)
+				}
+				if err := qManager.AddClusterQueue(ctx, &cq); err != nil {
+					t.Fatalf("Inserting clusterQueue %s in manager: %v", cq.Name, err)
+				}
+			}
+			for i := range resourceFlavors {
+				cqCache.AddOrUpdateResourceFlavor(resourceFlavors[i])
+			}
+			workloadWatch, err := cl.Watch(ctx, &kueue.WorkloadList{})
+			if err != nil {
+				t.Fatalf("Failed setting up watch: %v", err)
+			}
+			scheduler := New(qManager, cqCache, cl, recorder, WithWaitForPodsReady(tc.waitForPodsReady), WithDryRun(tc.dryRun))
+			wg := sync.WaitGroup{}
+			scheduler.setAdmissionRoutineWrapper(routine.NewWrapper(
+				func() { wg.Add(1) },
+				func() { wg.Done() },
+			))
+
+			ctx, cancel := context.WithTimeout(ctx, queueingTimeout)
+			go qManager.CleanUpOnContext(ctx)
+			defer cancel()
+			scheduler.schedule(ctx)
+
+			// Verify assignments in API.
+			gotScheduled := make(map[string]kueue.Admission)
+			timedOut := false
+			for !timedOut && len(gotScheduled) < len(tc.wantScheduled) {
+				select {
+				case evt := <-workloadWatch.ResultChan():
+					w, ok := evt.Object.(*kueue.Workload)
+					if !ok {
+						t.Fatalf("Received update for %T, want Workload", evt.Object)
+					}
+					if w.Spec.Admission != nil {
+						gotScheduled[workload.Key(w)] = *w.Spec.Admission
+					}
+				case <-time.After(watchTimeout):
+					t.Errorf("Timed out waiting for Workload updates")
+					timedOut = true
+				}
+			}
+			wg.Wait()
+			wantScheduled := make(map[string]kueue.Admission)
+			for _, key := range tc.wantScheduled {
 				wantScheduled[key] = tc.wantAssignments[key]
 			}
 			if diff := cmp.Diff(wantScheduled, gotScheduled); diff != "" {
@@ -798,6 +1415,20 @@ func TestSchedule(t *testing.T) {
 			if diff := cmp.Diff(tc.wantLeft, qDump); diff != "" {
 				t.Errorf("Unexpected elements left in the queue (-want,+got):\n%s", diff)
 			}
+
+			for key, wantMsg := range tc.wantPendingMessages {
+				ns, name, _ := strings.Cut(key, "/")
+				var wl kueue.Workload
+				if err := cl.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &wl); err != nil {
+					t.Fatalf("Getting workload %s: %v", key, err)
+				}
+				idx := workload.FindConditionIndex(&wl.Status, kueue.WorkloadAdmitted)
+				if idx < 0 {
+					t.Errorf("Workload %s has no Admitted condition", key)
+				} else if gotMsg := wl.Status.Conditions[idx].Message; gotMsg != wantMsg {
+					t.Errorf("Workload %s has message %q, want %q", key, gotMsg, wantMsg)
+				}
+			}
 		})
 	}
 }
@@ -807,93 +1438,1053 @@ func TestEntryAssignFlavors(t *testing.T) {
 		"default": {
 			ObjectMeta: metav1.ObjectMeta{Name: "default"},
 		},
-		"one": {
-			ObjectMeta: metav1.ObjectMeta{Name: "one"},
-			Labels:     map[string]string{"type": "one"},
+		"one": {
+			ObjectMeta: metav1.ObjectMeta{Name: "one"},
+			Labels:     map[string]string{"type": "one"},
+		},
+		"two": {
+			ObjectMeta: metav1.ObjectMeta{Name: "two"},
+			Labels:     map[string]string{"type": "two"},
+		},
+		"tainted": {
+			ObjectMeta: metav1.ObjectMeta{Name: "tainted"},
+			Taints: []corev1.Taint{{
+				Key:    "instance",
+				Value:  "spot",
+				Effect: corev1.TaintEffectNoSchedule,
+			}},
+		},
+	}
+
+	cases := map[string]struct {
+		wlPods              []kueue.PodSet
+		sameFlavorResources []corev1.ResourceName
+		placementHints      []corev1.NodeSelectorTerm
+		wlPriority          *int32
+		clusterQueue        cache.ClusterQueue
+		wantFits            bool
+		// wantReasons is the reason for each podSet that doesn't fit.
+		wantReasons map[string]string
+		wantFlavors map[string]map[corev1.ResourceName]string
+		wantSplits  map[string][]workload.PodSetSplit
+		wantBorrows cache.Resources
+	}{
+		"single flavor, fits": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "1",
+						corev1.ResourceMemory: "1Mi",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU:    {{Name: "default", Nominal: 1000}},
+					corev1.ResourceMemory: {{Name: "default", Nominal: 2 * utiltesting.Mi}},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU:    "default",
+					corev1.ResourceMemory: "default",
+				},
+			},
+		},
+		"single flavor, fits tainted flavor": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+								},
+							},
+						},
+						Tolerations: []corev1.Toleration{{
+							Key:      "instance",
+							Operator: corev1.TolerationOpEqual,
+							Value:    "spot",
+							Effect:   corev1.TaintEffectNoSchedule,
+						}},
+					},
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "tainted", Nominal: 4000},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "tainted",
+				},
+			},
+		},
+		"single flavor, used resources, doesn't fit": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {{Name: "default", Nominal: 4000}},
+				},
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {
+						"default": 3_000,
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: insufficient cpu in flavor default (need 2, available 1)",
+			},
+		},
+		"multiple flavors, fits": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "3",
+						corev1.ResourceMemory: "10Mi",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
+					},
+					corev1.ResourceMemory: {
+						{Name: "one", Nominal: utiltesting.Gi},
+						{Name: "two", Nominal: 5 * utiltesting.Mi},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU:    "two",
+					corev1.ResourceMemory: "one",
+				},
+			},
+		},
+		"multiple flavors, doesn't fit": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "4.1",
+						corev1.ResourceMemory: "0.5Gi",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
+					},
+					corev1.ResourceMemory: {
+						{Name: "one", Nominal: utiltesting.Gi},
+						{Name: "two", Nominal: 5 * utiltesting.Mi},
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: insufficient cpu in flavor one (need 4100m, available 2), insufficient cpu in flavor two (need 4100m, available 4)",
+			},
+		},
+		"multiple flavors, fits while skipping tainted flavor": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "3",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "tainted", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "two",
+				},
+			},
+		},
+		"multiple flavors, skips missing ResourceFlavor": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "3",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "non-existent", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "two",
+				},
+			},
+		},
+		"multiple flavors, fits a node selector": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+								},
+							},
+						},
+						// ignored:foo should get ignored
+						NodeSelector: map[string]string{"type": "two", "ignored1": "foo"},
+						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												// this expression should get ignored
+												Key:      "ignored2",
+												Operator: corev1.NodeSelectorOpIn,
+												Values:   []string{"bar"},
+											},
+										},
+									},
+								},
+							}},
+						},
+					},
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+				LabelKeys: map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType")},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "two",
+				},
+			},
+		},
+		"multiple flavors, fits with node affinity": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{
+										corev1.ResourceCPU:    resource.MustParse("1"),
+										corev1.ResourceMemory: resource.MustParse("1Mi"),
+									},
+								},
+							},
+						},
+						NodeSelector: map[string]string{"ignored1": "foo"},
+						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												Key:      "type",
+												Operator: corev1.NodeSelectorOpIn,
+												Values:   []string{"two"},
+											},
+										},
+									},
+								},
+							}},
+						},
+					},
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+					corev1.ResourceMemory: {
+						{Name: "one", Nominal: utiltesting.Gi},
+						{Name: "two", Nominal: utiltesting.Gi},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU:    "two",
+					corev1.ResourceMemory: "two",
+				},
+			},
+		},
+		"multiple flavors, node affinity fits any flavor": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+								},
+							},
+						},
+						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												Key:      "ignored2",
+												Operator: corev1.NodeSelectorOpIn,
+												Values:   []string{"bar"},
+											},
+										},
+									},
+									{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												// although this terms selects two
+												// the first term practically matches
+												// any flavor; and since the terms
+												// are ORed, any flavor can be selected.
+												Key:      "cpuType",
+												Operator: corev1.NodeSelectorOpIn,
+												Values:   []string{"two"},
+											},
+										},
+									},
+								},
+							}},
+						},
+					},
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "one",
+				},
+			},
+		},
+		"multiple flavor, doesn't fit node affinity": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+								},
+							},
+						},
+						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+								NodeSelectorTerms: []corev1.NodeSelectorTerm{
+									{
+										MatchExpressions: []corev1.NodeSelectorRequirement{
+											{
+												Key:      "type",
+												Operator: corev1.NodeSelectorOpIn,
+												Values:   []string{"three"},
+											},
+										},
+									},
+								},
+							}},
+						},
+					},
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+				LabelKeys: map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType")},
+			},
+			wantFits: false,
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: flavor one doesn't match the node affinity, flavor two doesn't match the node affinity",
+			},
+		},
+		"multiple flavors, fits placement hints": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "1",
+					}),
+				},
+			},
+			placementHints: []corev1.NodeSelectorTerm{
+				{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{
+							Key:      "type",
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   []string{"two"},
+						},
+					},
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "two",
+				},
+			},
+		},
+		"multiple flavors, placement hints conflict with node selector": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+								},
+							},
+						},
+						NodeSelector: map[string]string{"type": "one"},
+					},
+				},
+			},
+			placementHints: []corev1.NodeSelectorTerm{
+				{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{
+							Key:      "type",
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   []string{"two"},
+						},
+					},
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 4000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+			},
+			wantFits: false,
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: flavor one doesn't match the node affinity, flavor two doesn't match the node affinity",
+			},
+		},
+		"multiple specs, fit different flavors": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "5",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "3",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 4000},
+						{Name: "two", Nominal: 10_000},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"driver": {
+					corev1.ResourceCPU: "two",
+				},
+				"worker": {
+					corev1.ResourceCPU: "one",
+				},
+			},
+		},
+		"multiple specs, fits borrowing": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "4",
+						corev1.ResourceMemory: "1Gi",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "6",
+						corev1.ResourceMemory: "4Gi",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{
+							Name:           "default",
+							Nominal:        2000,
+							BorrowingLimit: pointer.Int64(98_000),
+						},
+					},
+					corev1.ResourceMemory: {
+						{
+							Name:    "default",
+							Nominal: 2 * utiltesting.Gi,
+							// No borrowing limit.
+						},
+					},
+				},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {
+							"default": 200_000,
+						},
+						corev1.ResourceMemory: {
+							"default": 200 * utiltesting.Gi,
+						},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"driver": {
+					corev1.ResourceCPU:    "default",
+					corev1.ResourceMemory: "default",
+				},
+				"worker": {
+					corev1.ResourceCPU:    "default",
+					corev1.ResourceMemory: "default",
+				},
+			},
+			wantBorrows: cache.Resources{
+				corev1.ResourceCPU: {
+					"default": 8_000,
+				},
+				corev1.ResourceMemory: {
+					"default": 3 * utiltesting.Gi,
+				},
+			},
+		},
+		"not enough space to borrow": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{
+							Name:    "one",
+							Nominal: 1000,
+							// No borrowing limit.
+						},
+					},
+				},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 10_000},
+					},
+					UsedResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 9_000},
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: insufficient cpu in flavor one (need 2, available 1)",
+			},
+		},
+		"multiple podSets, split across flavors": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 10_000},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"driver": {
+					corev1.ResourceCPU: "one",
+				},
+				"worker": {
+					corev1.ResourceCPU: "two",
+				},
+			},
+		},
+		"multiple podSets, only some fit": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "4",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "launcher",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "1",
+						corev1.ResourceMemory: "1Gi",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 3000},
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"launcher": "resource memory unavailable in ClusterQueue",
+				"worker":   "no flavor fits the requests for cpu: insufficient cpu in flavor one (need 4, available 1), insufficient cpu in flavor two (need 4, available 3)",
+			},
+		},
+		"multiple podSets, same flavor": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "2",
+						corev1.ResourceMemory: "1Mi",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			sameFlavorResources: []corev1.ResourceName{corev1.ResourceCPU},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 10_000},
+					},
+					corev1.ResourceMemory: {
+						{Name: "default", Nominal: utiltesting.Mi},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"driver": {
+					corev1.ResourceCPU:    "two",
+					corev1.ResourceMemory: "default",
+				},
+				"worker": {
+					corev1.ResourceCPU: "two",
+				},
+			},
 		},
-		"two": {
-			ObjectMeta: metav1.ObjectMeta{Name: "two"},
-			Labels:     map[string]string{"type": "two"},
+		"multiple podSets, same flavor required by ClusterQueue": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 10_000},
+					},
+				},
+				SameFlavorResources: sets.NewString(string(corev1.ResourceCPU)),
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"driver": {
+					corev1.ResourceCPU: "two",
+				},
+				"worker": {
+					corev1.ResourceCPU: "two",
+				},
+			},
 		},
-		"tainted": {
-			ObjectMeta: metav1.ObjectMeta{Name: "tainted"},
-			Taints: []corev1.Taint{{
-				Key:    "instance",
-				Value:  "spot",
-				Effect: corev1.TaintEffectNoSchedule,
-			}},
+		"multiple podSets, same flavor, borrows": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			sameFlavorResources: []corev1.ResourceName{corev1.ResourceCPU},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+					},
+				},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 10_000},
+					},
+					UsedResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 0},
+					},
+				},
+			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"driver": {
+					corev1.ResourceCPU: "one",
+				},
+				"worker": {
+					corev1.ResourceCPU: "one",
+				},
+			},
+			wantBorrows: cache.Resources{
+				corev1.ResourceCPU: {"one": 1000},
+			},
 		},
-	}
-
-	cases := map[string]struct {
-		wlPods       []kueue.PodSet
-		clusterQueue cache.ClusterQueue
-		wantFits     bool
-		wantFlavors  map[string]map[corev1.ResourceName]string
-		wantBorrows  cache.Resources
-	}{
-		"single flavor, fits": {
+		"multiple podSets, same flavor, doesn't fit": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+				{
+					Count: 1,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			sameFlavorResources: []corev1.ResourceName{corev1.ResourceCPU},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 3000},
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"driver": "no flavor fits the requests of all the pod sets for cpu: insufficient cpu in flavor one (need 4, available 3), insufficient cpu in flavor two (need 4, available 3)",
+				"worker": "no flavor fits the requests of all the pod sets for cpu: insufficient cpu in flavor one (need 4, available 3), insufficient cpu in flavor two (need 4, available 3)",
+			},
+		},
+		"past borrowing limit": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
 					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{
+							Name:           "one",
+							Nominal:        1000,
+							BorrowingLimit: pointer.Int64(9_000),
+						},
+					},
+				},
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {"one": 9_000},
+				},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 100_000},
+					},
+					UsedResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 9_000},
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: insufficient cpu in flavor one (need 2, available 1)",
+			},
+		},
+		"split across flavors": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 5,
+					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 						corev1.ResourceCPU:    "1",
 						corev1.ResourceMemory: "1Mi",
 					}),
+					SplitAcrossFlavors: true,
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
-					corev1.ResourceCPU:    {{Name: "default", Min: 1000}},
-					corev1.ResourceMemory: {{Name: "default", Min: 2 * utiltesting.Mi}},
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 3000},
+					},
+					corev1.ResourceMemory: {
+						{Name: "default", Nominal: utiltesting.Mi},
+						{Name: "two", Nominal: 10 * utiltesting.Mi},
+					},
 				},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": nil,
+			},
+			wantSplits: map[string][]workload.PodSetSplit{
 				"main": {
-					corev1.ResourceCPU:    "default",
-					corev1.ResourceMemory: "default",
+					{
+						Count: 3,
+						Requests: workload.Requests{
+							corev1.ResourceCPU:    3000,
+							corev1.ResourceMemory: 3 * utiltesting.Mi,
+						},
+						Flavors: map[corev1.ResourceName]string{
+							corev1.ResourceCPU:    "one",
+							corev1.ResourceMemory: "two",
+						},
+					},
+					{
+						Count: 2,
+						Requests: workload.Requests{
+							corev1.ResourceCPU:    2000,
+							corev1.ResourceMemory: 2 * utiltesting.Mi,
+						},
+						Flavors: map[corev1.ResourceName]string{
+							corev1.ResourceCPU:    "two",
+							corev1.ResourceMemory: "two",
+						},
+					},
 				},
 			},
 		},
-		"single flavor, fits tainted flavor": {
+		"split across flavors, doesn't fit": {
 			wlPods: []kueue.PodSet{
 				{
-					Count: 1,
+					Count: 7,
 					Name:  "main",
-					Spec: corev1.PodSpec{
-						Containers: []corev1.Container{
-							{
-								Resources: corev1.ResourceRequirements{
-									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
-								},
-							},
-						},
-						Tolerations: []corev1.Toleration{{
-							Key:      "instance",
-							Operator: corev1.TolerationOpEqual,
-							Value:    "spot",
-							Effect:   corev1.TaintEffectNoSchedule,
-						}},
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "1",
+					}),
+					SplitAcrossFlavors: true,
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 3000},
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"main": "couldn't fit the last 1 of 7 pods in any flavor: no flavor fits the requests for cpu: insufficient cpu in flavor one (need 1, available 0), insufficient cpu in flavor two (need 1, available 0)",
+			},
+		},
+		"split not allowed, doesn't fit": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 5,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "1",
+					}),
+				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 3000},
+						{Name: "two", Nominal: 3000},
 					},
 				},
 			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: insufficient cpu in flavor one (need 5, available 3), insufficient cpu in flavor two (need 5, available 3)",
+			},
+		},
+		"priority below min borrowing priority, uses next flavor": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "2",
+					}),
+				},
+			},
+			wlPriority: pointer.Int32(10),
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "tainted", Min: 4000},
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
+					},
+				},
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {"one": 1000},
+				},
+				MinBorrowingPriority: pointer.Int32(100),
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 10_000, "two": 4000},
+					},
+					UsedResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 1000},
 					},
 				},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
 				"main": {
-					corev1.ResourceCPU: "tainted",
+					corev1.ResourceCPU: "two",
 				},
 			},
 		},
-		"single flavor, used resources, doesn't fit": {
+		"priority at min borrowing priority, borrows": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
@@ -903,89 +2494,135 @@ func TestEntryAssignFlavors(t *testing.T) {
 					}),
 				},
 			},
+			wlPriority: pointer.Int32(100),
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
-					corev1.ResourceCPU: {{Name: "default", Min: 4000}},
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
+					},
 				},
 				UsedResources: cache.Resources{
-					corev1.ResourceCPU: {
-						"default": 3_000,
+					corev1.ResourceCPU: {"one": 1000},
+				},
+				MinBorrowingPriority: pointer.Int32(100),
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 10_000, "two": 4000},
+					},
+					UsedResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 1000},
 					},
 				},
 			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "one",
+				},
+			},
+			wantBorrows: cache.Resources{
+				corev1.ResourceCPU: {"one": 1000},
+			},
 		},
-		"multiple flavors, fits": {
+		"whenCanBorrow TryNextFlavor, uses next flavor without borrowing": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
 					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-						corev1.ResourceCPU:    "3",
-						corev1.ResourceMemory: "10Mi",
+						corev1.ResourceCPU: "2",
 					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "one", Min: 2000},
-						{Name: "two", Min: 4000},
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
 					},
-					corev1.ResourceMemory: {
-						{Name: "one", Min: utiltesting.Gi},
-						{Name: "two", Min: 5 * utiltesting.Mi},
+				},
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {"one": 1000},
+				},
+				FlavorFungibility: kueue.FlavorFungibility{WhenCanBorrow: kueue.TryNextFlavor},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 10_000, "two": 4000},
+					},
+					UsedResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 1000},
 					},
 				},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
 				"main": {
-					corev1.ResourceCPU:    "two",
-					corev1.ResourceMemory: "one",
+					corev1.ResourceCPU: "two",
 				},
 			},
 		},
-		"multiple flavors, doesn't fit": {
+		"whenCanBorrow TryNextFlavor, borrows in first flavor when no flavor fits without borrowing": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
 					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-						corev1.ResourceCPU:    "4.1",
-						corev1.ResourceMemory: "0.5Gi",
+						corev1.ResourceCPU: "2",
 					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "one", Min: 2000},
-						{Name: "two", Min: 4000},
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 1000},
 					},
-					corev1.ResourceMemory: {
-						{Name: "one", Min: utiltesting.Gi},
-						{Name: "two", Min: 5 * utiltesting.Mi},
+				},
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {"one": 1000},
+				},
+				FlavorFungibility: kueue.FlavorFungibility{WhenCanBorrow: kueue.TryNextFlavor},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 10_000, "two": 10_000},
+					},
+					UsedResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 1000},
 					},
 				},
 			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "one",
+				},
+			},
+			wantBorrows: cache.Resources{
+				corev1.ResourceCPU: {"one": 1000},
+			},
 		},
-		"multiple flavors, fits while skipping tainted flavor": {
+		"whenCanPreempt TryNextFlavor, uses next flavor": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
 					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-						corev1.ResourceCPU: "3",
+						corev1.ResourceCPU: "2",
 					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "tainted", Min: 4000},
-						{Name: "two", Min: 4000},
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
 					},
 				},
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {"one": 1000},
+				},
+				Preemption: kueue.ClusterQueuePreemption{WithinClusterQueue: kueue.PreemptionPolicyLowerPriority},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
@@ -994,73 +2631,52 @@ func TestEntryAssignFlavors(t *testing.T) {
 				},
 			},
 		},
-		"multiple flavors, skips missing ResourceFlavor": {
+		"whenCanPreempt Preempt, doesn't fit to preempt in first flavor": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
 					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-						corev1.ResourceCPU: "3",
+						corev1.ResourceCPU: "2",
 					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "non-existent", Min: 4000},
-						{Name: "two", Min: 4000},
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
 					},
 				},
-			},
-			wantFits: true,
-			wantFlavors: map[string]map[corev1.ResourceName]string{
-				"main": {
-					corev1.ResourceCPU: "two",
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {"one": 1000},
 				},
+				Preemption:        kueue.ClusterQueuePreemption{WithinClusterQueue: kueue.PreemptionPolicyLowerPriority},
+				FlavorFungibility: kueue.FlavorFungibility{WhenCanPreempt: kueue.Preempt},
+			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: insufficient cpu in flavor one (need 2, available 1)",
 			},
 		},
-		"multiple flavors, fits a node selector": {
+		"whenCanPreempt Preempt, uses next flavor if it can't preempt": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
 					Name:  "main",
-					Spec: corev1.PodSpec{
-						Containers: []corev1.Container{
-							{
-								Resources: corev1.ResourceRequirements{
-									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
-								},
-							},
-						},
-						// ignored:foo should get ignored
-						NodeSelector: map[string]string{"type": "two", "ignored1": "foo"},
-						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
-							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
-								NodeSelectorTerms: []corev1.NodeSelectorTerm{
-									{
-										MatchExpressions: []corev1.NodeSelectorRequirement{
-											{
-												// this expression should get ignored
-												Key:      "ignored2",
-												Operator: corev1.NodeSelectorOpIn,
-												Values:   []string{"bar"},
-											},
-										},
-									},
-								},
-							}},
-						},
-					},
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "3",
+					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "one", Min: 4000},
-						{Name: "two", Min: 4000},
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
 					},
 				},
-				LabelKeys: map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType")},
+				Preemption:        kueue.ClusterQueuePreemption{WithinClusterQueue: kueue.PreemptionPolicyLowerPriority},
+				FlavorFungibility: kueue.FlavorFungibility{WhenCanPreempt: kueue.Preempt},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
@@ -1069,174 +2685,118 @@ func TestEntryAssignFlavors(t *testing.T) {
 				},
 			},
 		},
-		"multiple flavors, fits with node affinity": {
+		"ephemeral-storage and hugepages": {
 			wlPods: []kueue.PodSet{
 				{
-					Count: 1,
+					Count: 2,
 					Name:  "main",
-					Spec: corev1.PodSpec{
-						Containers: []corev1.Container{
-							{
-								Resources: corev1.ResourceRequirements{
-									Requests: corev1.ResourceList{
-										corev1.ResourceCPU:    resource.MustParse("1"),
-										corev1.ResourceMemory: resource.MustParse("1Mi"),
-									},
-								},
-							},
-						},
-						NodeSelector: map[string]string{"ignored1": "foo"},
-						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
-							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
-								NodeSelectorTerms: []corev1.NodeSelectorTerm{
-									{
-										MatchExpressions: []corev1.NodeSelectorRequirement{
-											{
-												Key:      "type",
-												Operator: corev1.NodeSelectorOpIn,
-												Values:   []string{"two"},
-											},
-										},
-									},
-								},
-							}},
-						},
-					},
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceEphemeralStorage: "3Gi",
+						"hugepages-2Mi":                 "64Mi",
+					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
-					corev1.ResourceCPU: {
-						{Name: "one", Min: 4000},
-						{Name: "two", Min: 4000},
+					corev1.ResourceEphemeralStorage: {
+						{Name: "one", Nominal: 5 * utiltesting.Gi},
+						{Name: "two", Nominal: 10 * utiltesting.Gi},
 					},
-					corev1.ResourceMemory: {
-						{Name: "one", Min: utiltesting.Gi},
-						{Name: "two", Min: utiltesting.Gi},
+					"hugepages-2Mi": {
+						{Name: "one", Nominal: 128 * utiltesting.Mi},
 					},
 				},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
 				"main": {
-					corev1.ResourceCPU:    "two",
-					corev1.ResourceMemory: "two",
+					corev1.ResourceEphemeralStorage: "two",
+					"hugepages-2Mi":                 "one",
 				},
 			},
 		},
-		"multiple flavors, node affinity fits any flavor": {
+		"pods quota": {
 			wlPods: []kueue.PodSet{
 				{
-					Count: 1,
-					Name:  "main",
-					Spec: corev1.PodSpec{
-						Containers: []corev1.Container{
-							{
-								Resources: corev1.ResourceRequirements{
-									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
-								},
-							},
-						},
-						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
-							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
-								NodeSelectorTerms: []corev1.NodeSelectorTerm{
-									{
-										MatchExpressions: []corev1.NodeSelectorRequirement{
-											{
-												Key:      "ignored2",
-												Operator: corev1.NodeSelectorOpIn,
-												Values:   []string{"bar"},
-											},
-										},
-									},
-									{
-										MatchExpressions: []corev1.NodeSelectorRequirement{
-											{
-												// although this terms selects two
-												// the first term practically matches
-												// any flavor; and since the terms
-												// are ORed, any flavor can be selected.
-												Key:      "cpuType",
-												Operator: corev1.NodeSelectorOpIn,
-												Values:   []string{"two"},
-											},
-										},
-									},
-								},
-							}},
-						},
-					},
+					Count: 2,
+					Name:  "driver",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "1",
+					}),
+				},
+				{
+					Count: 3,
+					Name:  "worker",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "1",
+					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "one", Min: 4000},
-						{Name: "two", Min: 4000},
+						{Name: "default", Nominal: 10_000},
+					},
+					corev1.ResourcePods: {
+						{Name: "one", Nominal: 4},
+						{Name: "two", Nominal: 4},
 					},
 				},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
-				"main": {
-					corev1.ResourceCPU: "one",
+				"driver": {
+					corev1.ResourceCPU:  "default",
+					corev1.ResourcePods: "one",
+				},
+				"worker": {
+					corev1.ResourceCPU:  "default",
+					corev1.ResourcePods: "two",
 				},
 			},
 		},
-		"multiple flavor, doesn't fit node affinity": {
+		"borrows from the root cohort": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
 					Name:  "main",
-					Spec: corev1.PodSpec{
-						Containers: []corev1.Container{
-							{
-								Resources: corev1.ResourceRequirements{
-									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
-								},
-							},
-						},
-						Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
-							RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
-								NodeSelectorTerms: []corev1.NodeSelectorTerm{
-									{
-										MatchExpressions: []corev1.NodeSelectorRequirement{
-											{
-												Key:      "type",
-												Operator: corev1.NodeSelectorOpIn,
-												Values:   []string{"three"},
-											},
-										},
-									},
-								},
-							}},
-						},
-					},
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "3",
+					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "one", Min: 4000},
-						{Name: "two", Min: 4000},
+						{Name: "default", Nominal: 1000},
+					},
+				},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"default": 1000},
+					},
+					Parent: &cache.Cohort{
+						RequestableResources: cache.Resources{
+							corev1.ResourceCPU: {"default": 10_000},
+						},
 					},
 				},
-				LabelKeys: map[corev1.ResourceName]sets.String{corev1.ResourceCPU: sets.NewString("cpuType")},
 			},
-			wantFits: false,
-		},
-		"multiple specs, fit different flavors": {
-			wlPods: []kueue.PodSet{
-				{
-					Count: 1,
-					Name:  "driver",
-					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-						corev1.ResourceCPU: "5",
-					}),
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "default",
 				},
+			},
+			wantBorrows: cache.Resources{
+				corev1.ResourceCPU: {"default": 2_000},
+			},
+		},
+		"past the limit of a parent cohort, uses the next flavor": {
+			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
-					Name:  "worker",
+					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
 						corev1.ResourceCPU: "3",
 					}),
@@ -1245,89 +2805,125 @@ func TestEntryAssignFlavors(t *testing.T) {
 			clusterQueue: cache.ClusterQueue{
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{Name: "one", Min: 4000},
-						{Name: "two", Min: 10_000},
+						{Name: "one", Nominal: 5000},
+						{Name: "two", Nominal: 5000},
+					},
+				},
+				Cohort: &cache.Cohort{
+					RequestableResources: cache.Resources{
+						corev1.ResourceCPU: {"one": 5000, "two": 5000},
+					},
+					Parent: &cache.Cohort{
+						RequestableResources: cache.Resources{
+							corev1.ResourceCPU: {"one": 20_000, "two": 20_000},
+						},
+						UsedResources: cache.Resources{
+							corev1.ResourceCPU: {"one": 6000},
+						},
+						Limits: cache.Resources{
+							corev1.ResourceCPU: {"one": 8000},
+						},
 					},
 				},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
-				"driver": {
+				"main": {
 					corev1.ResourceCPU: "two",
 				},
-				"worker": {
-					corev1.ResourceCPU: "one",
-				},
 			},
 		},
-		"multiple specs, fits borrowing": {
+		"pods quota, doesn't fit": {
 			wlPods: []kueue.PodSet{
 				{
-					Count: 1,
-					Name:  "driver",
+					Count: 5,
+					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-						corev1.ResourceCPU:    "4",
-						corev1.ResourceMemory: "1Gi",
+						corev1.ResourceCPU: "1",
 					}),
 				},
+			},
+			clusterQueue: cache.ClusterQueue{
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "default", Nominal: 10_000},
+					},
+					corev1.ResourcePods: {
+						{Name: "default", Nominal: 4},
+					},
+				},
+			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for pods: insufficient pods in flavor default (need 5, available 4)",
+			},
+		},
+		"resource group, one flavor for all the covered resources": {
+			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
-					Name:  "worker",
+					Name:  "main",
 					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
-						corev1.ResourceCPU:    "6",
-						corev1.ResourceMemory: "4Gi",
+						corev1.ResourceCPU:    "3",
+						corev1.ResourceMemory: "10Mi",
 					}),
 				},
 			},
 			clusterQueue: cache.ClusterQueue{
+				ResourceGroups: []cache.ResourceGroup{{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+					Flavors:          []string{"one", "two"},
+				}},
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
-						{
-							Name: "default",
-							Min:  2000,
-							Max:  pointer.Int64(100_000),
-						},
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
 					},
 					corev1.ResourceMemory: {
-						{
-							Name: "default",
-							Min:  2 * utiltesting.Gi,
-							// No max.
-						},
-					},
-				},
-				Cohort: &cache.Cohort{
-					RequestableResources: cache.Resources{
-						corev1.ResourceCPU: {
-							"default": 200_000,
-						},
-						corev1.ResourceMemory: {
-							"default": 200 * utiltesting.Gi,
-						},
+						{Name: "one", Nominal: utiltesting.Gi},
+						{Name: "two", Nominal: 15 * utiltesting.Mi},
 					},
 				},
 			},
 			wantFits: true,
 			wantFlavors: map[string]map[corev1.ResourceName]string{
-				"driver": {
-					corev1.ResourceCPU:    "default",
-					corev1.ResourceMemory: "default",
-				},
-				"worker": {
-					corev1.ResourceCPU:    "default",
-					corev1.ResourceMemory: "default",
+				"main": {
+					corev1.ResourceCPU:    "two",
+					corev1.ResourceMemory: "two",
 				},
 			},
-			wantBorrows: cache.Resources{
-				corev1.ResourceCPU: {
-					"default": 8_000,
+		},
+		"resource group, no flavor fits all the covered resources": {
+			wlPods: []kueue.PodSet{
+				{
+					Count: 1,
+					Name:  "main",
+					Spec: utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+						corev1.ResourceCPU:    "3",
+						corev1.ResourceMemory: "10Mi",
+					}),
 				},
-				corev1.ResourceMemory: {
-					"default": 3 * utiltesting.Gi,
+			},
+			clusterQueue: cache.ClusterQueue{
+				ResourceGroups: []cache.ResourceGroup{{
+					CoveredResources: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+					Flavors:          []string{"one", "two"},
+				}},
+				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+					corev1.ResourceCPU: {
+						{Name: "one", Nominal: 2000},
+						{Name: "two", Nominal: 4000},
+					},
+					corev1.ResourceMemory: {
+						{Name: "one", Nominal: utiltesting.Gi},
+						{Name: "two", Nominal: 5 * utiltesting.Mi},
+					},
 				},
 			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu, memory: insufficient cpu in flavor one (need 3, available 2), insufficient memory in flavor two (need 10Mi, available 5Mi)",
+			},
 		},
-		"not enough space to borrow": {
+		"usage within the guaranteed quota doesn't need the cohort": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
@@ -1341,23 +2937,32 @@ func TestEntryAssignFlavors(t *testing.T) {
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
 						{
-							Name: "one",
-							Min:  1000,
-							// No max.
+							Name:         "default",
+							Nominal:      4000,
+							LendingLimit: pointer.Int64(1000),
 						},
 					},
 				},
+				UsedResources: cache.Resources{
+					corev1.ResourceCPU: {"default": 1000},
+				},
 				Cohort: &cache.Cohort{
 					RequestableResources: cache.Resources{
-						corev1.ResourceCPU: {"one": 10_000},
+						corev1.ResourceCPU: {"default": 10_000},
 					},
 					UsedResources: cache.Resources{
-						corev1.ResourceCPU: {"one": 9_000},
+						corev1.ResourceCPU: {"default": 10_000},
 					},
 				},
 			},
+			wantFits: true,
+			wantFlavors: map[string]map[corev1.ResourceName]string{
+				"main": {
+					corev1.ResourceCPU: "default",
+				},
+			},
 		},
-		"past max": {
+		"usage past the guaranteed quota doesn't fit in the cohort": {
 			wlPods: []kueue.PodSet{
 				{
 					Count: 1,
@@ -1371,24 +2976,27 @@ func TestEntryAssignFlavors(t *testing.T) {
 				RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
 					corev1.ResourceCPU: {
 						{
-							Name: "one",
-							Min:  1000,
-							Max:  pointer.Int64(10_000),
+							Name:         "default",
+							Nominal:      4000,
+							LendingLimit: pointer.Int64(1000),
 						},
 					},
 				},
 				UsedResources: cache.Resources{
-					corev1.ResourceCPU: {"one": 9_000},
+					corev1.ResourceCPU: {"default": 2000},
 				},
 				Cohort: &cache.Cohort{
 					RequestableResources: cache.Resources{
-						corev1.ResourceCPU: {"one": 100_000},
+						corev1.ResourceCPU: {"default": 10_000},
 					},
 					UsedResources: cache.Resources{
-						corev1.ResourceCPU: {"one": 9_000},
+						corev1.ResourceCPU: {"default": 10_000},
 					},
 				},
 			},
+			wantReasons: map[string]string{
+				"main": "no flavor fits the requests for cpu: insufficient cpu in flavor default (need 2, available 1)",
+			},
 		},
 	}
 	for name, tc := range cases {
@@ -1399,25 +3007,53 @@ func TestEntryAssignFlavors(t *testing.T) {
 			e := entry{
 				Info: *workload.NewInfo(&kueue.Workload{
 					Spec: kueue.WorkloadSpec{
-						PodSets: tc.wlPods,
+						PodSets:             tc.wlPods,
+						SameFlavorResources: tc.sameFlavorResources,
+						PlacementHints:      tc.placementHints,
+						Priority:            tc.wlPriority,
 					},
 				}),
 			}
+			if tc.clusterQueue.ResourceGroups == nil {
+				// Each resource gets its flavors independently.
+				tc.clusterQueue.ResourceGroups = resourceGroupPerResource(tc.clusterQueue.RequestableResources)
+			}
 			tc.clusterQueue.UpdateLabelKeys(resourceFlavors)
-			fits := e.assignFlavors(log, resourceFlavors, &tc.clusterQueue)
+			err := e.assignFlavors(log, resourceFlavors, &tc.clusterQueue)
+			fits := err == nil
 			if fits != tc.wantFits {
-				t.Errorf("e.assignFlavors(_)=%t, want %t", fits, tc.wantFits)
+				t.Errorf("e.assignFlavors(_) fits=%t, want %t", fits, tc.wantFits)
+			}
+			var reasons map[string]string
+			if unfit, ok := err.(unfitError); ok {
+				reasons = make(map[string]string, len(unfit))
+				for _, r := range unfit {
+					reasons[r.podSet] = r.reason
+				}
+			}
+			if diff := cmp.Diff(tc.wantReasons, reasons); diff != "" {
+				t.Errorf("Unexpected reasons for the podSets that don't fit (-want,+got):\n%s", diff)
 			}
 			var flavors map[string]map[corev1.ResourceName]string
+			var splits map[string][]workload.PodSetSplit
 			if fits {
 				flavors = make(map[string]map[corev1.ResourceName]string)
 				for _, podSet := range e.TotalRequests {
 					flavors[podSet.Name] = podSet.Flavors
+					if len(podSet.Splits) > 0 {
+						if splits == nil {
+							splits = make(map[string][]workload.PodSetSplit)
+						}
+						splits[podSet.Name] = podSet.Splits
+					}
 				}
 			}
 			if diff := cmp.Diff(tc.wantFlavors, flavors); diff != "" {
 				t.Errorf("Assigned unexpected flavors (-want,+got):\n%s", diff)
 			}
+			if diff := cmp.Diff(tc.wantSplits, splits); diff != "" {
+				t.Errorf("Assigned unexpected splits (-want,+got):\n%s", diff)
+			}
 			if diff := cmp.Diff(tc.wantBorrows, e.borrows); diff != "" {
 				t.Errorf("Calculated unexpected borrowing (-want,+got):\n%s", diff)
 			}
@@ -1425,6 +3061,70 @@ func TestEntryAssignFlavors(t *testing.T) {
 	}
 }
 
+func resourceGroupPerResource(limits map[corev1.ResourceName][]cache.FlavorLimits) []cache.ResourceGroup {
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	groups := make([]cache.ResourceGroup, len(names))
+	for i, name := range names {
+		groups[i].CoveredResources = []corev1.ResourceName{corev1.ResourceName(name)}
+		for _, f := range limits[corev1.ResourceName(name)] {
+			groups[i].Flavors = append(groups[i].Flavors, f.Name)
+		}
+	}
+	return groups
+}
+
+func TestSplitPodSet(t *testing.T) {
+	resourceFlavors := map[string]*kueue.ResourceFlavor{
+		"one": {ObjectMeta: metav1.ObjectMeta{Name: "one"}},
+		"two": {ObjectMeta: metav1.ObjectMeta{Name: "two"}},
+	}
+	cq := cache.ClusterQueue{
+		RequestableResources: map[corev1.ResourceName][]cache.FlavorLimits{
+			corev1.ResourceCPU: {
+				{Name: "one", Nominal: 6},
+				{Name: "two", Nominal: 4},
+			},
+		},
+	}
+	cq.ResourceGroups = resourceGroupPerResource(cq.RequestableResources)
+	cq.UpdateLabelKeys(resourceFlavors)
+	podSet := &kueue.PodSet{
+		Count:              4,
+		Name:               "main",
+		Spec:               utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{corev1.ResourceCPU: "1"}),
+		SplitAcrossFlavors: true,
+	}
+	// The transformed requests aren't a multiple of the count, so the pods
+	// get 3, 3, 2 and 2 units.
+	requests := workload.Requests{corev1.ResourceCPU: 10}
+	log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+		Verbosity: 2,
+	})
+	splits, err := splitPodSet(log, podSet, requests, nil, cache.Resources{}, cache.Resources{}, resourceFlavors, &cq)
+	if err != nil {
+		t.Fatalf("Failed splitting the podSet: %v", err)
+	}
+	want := []workload.PodSetSplit{
+		{
+			Count:    2,
+			Requests: workload.Requests{corev1.ResourceCPU: 6},
+			Flavors:  map[corev1.ResourceName]string{corev1.ResourceCPU: "one"},
+		},
+		{
+			Count:    2,
+			Requests: workload.Requests{corev1.ResourceCPU: 4},
+			Flavors:  map[corev1.ResourceName]string{corev1.ResourceCPU: "two"},
+		},
+	}
+	if diff := cmp.Diff(want, splits); diff != "" {
+		t.Errorf("Unexpected splits (-want,+got):\n%s", diff)
+	}
+}
+
 func TestEntryOrdering(t *testing.T) {
 	now := time.Now()
 	input := []entry{
@@ -1532,7 +3232,7 @@ func TestRequeueAndUpdate(t *testing.T) {
 			name: "skipped",
 			e: entry{
 				status:             skipped,
-				inadmissibleReason: "cohort used in this cycle",
+				inadmissibleReason: "didn't fit after the admissions in the cohort in this cycle",
 			},
 			wantWorkloads: map[string]sets.String{
 				"cq": sets.NewString(w1.Name),
@@ -1590,3 +3290,370 @@ func TestRequeueAndUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestScheduleAdmissionUpdateFailure(t *testing.T) {
+	cq := utiltesting.MakeClusterQueue("cq").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+		Obj()
+	q := utiltesting.MakeQueue("main", "ns").ClusterQueue("cq").Obj()
+	wl := utiltesting.MakeWorkload("wl", "ns").Queue("main").Request(corev1.ResourceCPU, "1").Obj()
+
+	cases := map[string]struct {
+		err           error
+		wantWorkloads map[string]sets.String
+	}{
+		"conflict": {
+			err: utiltesting.ConflictError(wl.Name),
+			wantWorkloads: map[string]sets.String{
+				"cq": sets.NewString(wl.Name),
+			},
+		},
+		"timeout": {
+			err: utiltesting.TimeoutError(),
+			wantWorkloads: map[string]sets.String{
+				"cq": sets.NewString(wl.Name),
+			},
+		},
+		"workload deleted": {
+			err: utiltesting.NotFoundError(wl.Name),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+				Verbosity: 2,
+			})
+			ctx := ctrl.LoggerInto(context.Background(), log)
+			scheme := runtime.NewScheme()
+			if err := kueue.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			if err := corev1.AddToScheme(scheme); err != nil {
+				t.Fatalf("Failed adding kueue scheme: %v", err)
+			}
+			cl := utiltesting.NewFaultyClient(fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(wl.DeepCopy(), q, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}).
+				Build())
+			cl.Inject(utiltesting.Fault{
+				Op:    utiltesting.OpUpdate,
+				Match: utiltesting.MatchName(wl.Name),
+				Err:   tc.err,
+				Times: 1,
+			})
+			broadcaster := record.NewBroadcaster()
+			recorder := broadcaster.NewRecorder(scheme, corev1.EventSource{Component: constants.ManagerName})
+			qManager := queue.NewManager(cl)
+			cqCache := cache.New(cl)
+			if err := qManager.AddQueue(ctx, q); err != nil {
+				t.Fatalf("Inserting queue %s/%s in manager: %v", q.Namespace, q.Name, err)
+			}
+			if err := qManager.AddClusterQueue(ctx, cq); err != nil {
+				t.Fatalf("Inserting clusterQueue %s in manager: %v", cq.Name, err)
+			}
+			if err := cqCache.AddClusterQueue(ctx, cq); err != nil {
+				t.Fatalf("Inserting clusterQueue %s in cache: %v", cq.Name, err)
+			}
+			cqCache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("default").Obj())
+
+			scheduler := New(qManager, cqCache, cl, recorder)
+			wg := sync.WaitGroup{}
+			scheduler.setAdmissionRoutineWrapper(routine.NewWrapper(
+				func() { wg.Add(1) },
+				func() { wg.Done() },
+			))
+			ctx, cancel := context.WithTimeout(ctx, queueingTimeout)
+			defer cancel()
+			go qManager.CleanUpOnContext(ctx)
+			scheduler.schedule(ctx)
+			wg.Wait()
+
+			if calls := cl.Calls(utiltesting.OpUpdate); calls != 1 {
+				t.Errorf("Got %d failed updates, want 1", calls)
+			}
+			snapshot := cqCache.Snapshot()
+			if got := len(snapshot.ClusterQueues["cq"].Workloads); got != 0 {
+				t.Errorf("Found %d workloads in the cache, want the assumed workload to be forgotten", got)
+			}
+			if diff := cmp.Diff(tc.wantWorkloads, qManager.Dump()); diff != "" {
+				t.Errorf("Unexpected elements in the cluster queue (-want,+got):\n%s", diff)
+			}
+			var updatedWl kueue.Workload
+			if err := cl.Get(ctx, client.ObjectKeyFromObject(wl), &updatedWl); err != nil {
+				t.Fatalf("Failed obtaining updated object: %v", err)
+			}
+			if updatedWl.Spec.Admission != nil {
+				t.Errorf("Workload got admitted: %v", updatedWl.Spec.Admission)
+			}
+		})
+	}
+}
+
+func TestStartWaitsForAdmissions(t *testing.T) {
+	log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+		Verbosity: 2,
+	})
+	ctx := ctrl.LoggerInto(context.Background(), log)
+	cq := utiltesting.MakeClusterQueue("cq").
+		Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+			Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+		Obj()
+	q := utiltesting.MakeQueue("main", "ns").ClusterQueue("cq").Obj()
+	wl := utiltesting.MakeWorkload("wl", "ns").Queue("main").Request(corev1.ResourceCPU, "1").Obj()
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	cl := &blockingClient{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(wl.DeepCopy(), q, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}).
+			Build(),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	broadcaster := record.NewBroadcaster()
+	recorder := broadcaster.NewRecorder(scheme, corev1.EventSource{Component: constants.ManagerName})
+	qManager := queue.NewManager(cl)
+	cqCache := cache.New(cl)
+	if err := qManager.AddQueue(ctx, q); err != nil {
+		t.Fatalf("Inserting queue %s/%s in manager: %v", q.Namespace, q.Name, err)
+	}
+	if err := qManager.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Inserting clusterQueue %s in manager: %v", cq.Name, err)
+	}
+	if err := cqCache.AddClusterQueue(ctx, cq); err != nil {
+		t.Fatalf("Inserting clusterQueue %s in cache: %v", cq.Name, err)
+	}
+	cqCache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("default").Obj())
+	scheduler := New(qManager, cqCache, cl, recorder)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go qManager.CleanUpOnContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		if err := scheduler.Start(ctx); err != nil {
+			t.Errorf("Start returned error: %v", err)
+		}
+		close(done)
+	}()
+	select {
+	case <-cl.started:
+	case <-time.After(queueingTimeout):
+		t.Fatal("The workload wasn't admitted")
+	}
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Start returned while an admission was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(cl.release)
+	select {
+	case <-done:
+	case <-time.After(queueingTimeout):
+		t.Fatal("Start didn't return after the admission finished")
+	}
+
+	var updatedWl kueue.Workload
+	if err := cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &updatedWl); err != nil {
+		t.Fatalf("Failed obtaining updated object: %v", err)
+	}
+	if updatedWl.Spec.Admission == nil {
+		t.Error("The admission in flight wasn't completed")
+	}
+}
+
+// blockingClient blocks the updates until release is closed. The update
+// fails if its context is done by then.
+type blockingClient struct {
+	client.Client
+	startOnce sync.Once
+	started   chan struct{}
+	release   chan struct{}
+}
+
+func (c *blockingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.startOnce.Do(func() { close(c.started) })
+	<-c.release
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestAdmissionWorkers(t *testing.T) {
+	log := logrtesting.NewTestLoggerWithOptions(t, logrtesting.Options{
+		Verbosity: 2,
+	})
+	ctx := ctrl.LoggerInto(context.Background(), log)
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed adding kueue scheme: %v", err)
+	}
+	var clusterQueues []*kueue.ClusterQueue
+	var queues []*kueue.Queue
+	objs := []client.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}}
+	for _, name := range []string{"a", "b", "c"} {
+		clusterQueues = append(clusterQueues, utiltesting.MakeClusterQueue(name).
+			Resource(utiltesting.MakeResource(corev1.ResourceCPU).
+				Flavor(utiltesting.MakeFlavor("default", "10").Obj()).Obj()).
+			Obj())
+		q := utiltesting.MakeQueue(name, "ns").ClusterQueue(name).Obj()
+		queues = append(queues, q)
+		objs = append(objs, q, utiltesting.MakeWorkload(name, "ns").Queue(name).Request(corev1.ResourceCPU, "1").Obj())
+	}
+	cl := &concurrencyClient{
+		Client:  fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		release: make(chan struct{}),
+	}
+	broadcaster := record.NewBroadcaster()
+	recorder := broadcaster.NewRecorder(scheme, corev1.EventSource{Component: constants.ManagerName})
+	qManager := queue.NewManager(cl)
+	cqCache := cache.New(cl)
+	for _, q := range queues {
+		if err := qManager.AddQueue(ctx, q); err != nil {
+			t.Fatalf("Inserting queue %s/%s in manager: %v", q.Namespace, q.Name, err)
+		}
+	}
+	for _, cq := range clusterQueues {
+		if err := qManager.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Inserting clusterQueue %s in manager: %v", cq.Name, err)
+		}
+		if err := cqCache.AddClusterQueue(ctx, cq); err != nil {
+			t.Fatalf("Inserting clusterQueue %s in cache: %v", cq.Name, err)
+		}
+	}
+	cqCache.AddOrUpdateResourceFlavor(utiltesting.MakeResourceFlavor("default").Obj())
+
+	scheduler := New(qManager, cqCache, cl, recorder, WithAdmissionWorkers(1))
+	wg := sync.WaitGroup{}
+	scheduler.setAdmissionRoutineWrapper(routine.NewWrapper(
+		func() { wg.Add(1) },
+		func() { wg.Done() },
+	))
+	ctx, cancel := context.WithTimeout(ctx, queueingTimeout)
+	defer cancel()
+	go qManager.CleanUpOnContext(ctx)
+	scheduler.schedule(ctx)
+
+	// The cycle doesn't wait for the updates to assume all the workloads.
+	snapshot := cqCache.Snapshot()
+	for _, cq := range clusterQueues {
+		if got := len(snapshot.ClusterQueues[cq.Name].Workloads); got != 1 {
+			t.Errorf("Found %d workloads in the cache for clusterQueue %s, want 1", got, cq.Name)
+		}
+	}
+	close(cl.release)
+	wg.Wait()
+
+	if cl.maxInFlight != 1 {
+		t.Errorf("Got %d updates in flight at the same time, want 1", cl.maxInFlight)
+	}
+	for _, q := range queues {
+		var updatedWl kueue.Workload
+		if err := cl.Get(ctx, client.ObjectKey{Namespace: "ns", Name: q.Name}, &updatedWl); err != nil {
+			t.Fatalf("Failed obtaining updated object: %v", err)
+		}
+		if updatedWl.Spec.Admission == nil {
+			t.Errorf("Workload %s wasn't admitted", updatedWl.Name)
+		}
+	}
+}
+
+// concurrencyClient blocks the updates until release is closed and records
+// the maximum number of updates in flight at the same time.
+type concurrencyClient struct {
+	client.Client
+	release chan struct{}
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *concurrencyClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+	<-c.release
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestQuotaUsage(t *testing.T) {
+	podSets := []workload.PodSetResources{
+		{
+			Name:  "driver",
+			Count: 1,
+			Requests: workload.Requests{
+				corev1.ResourceCPU:    1000,
+				corev1.ResourceMemory: utiltesting.Gi,
+			},
+			Flavors: map[corev1.ResourceName]string{
+				corev1.ResourceCPU:    "default",
+				corev1.ResourceMemory: "default",
+			},
+		},
+		{
+			Name:  "workers",
+			Count: 4,
+			Splits: []workload.PodSetSplit{
+				{
+					Count:    3,
+					Requests: workload.Requests{corev1.ResourceCPU: 1500},
+					Flavors:  map[corev1.ResourceName]string{corev1.ResourceCPU: "default"},
+				},
+				{
+					Count:    1,
+					Requests: workload.Requests{corev1.ResourceCPU: 500},
+					Flavors:  map[corev1.ResourceName]string{corev1.ResourceCPU: "spot"},
+				},
+			},
+		},
+	}
+	want := "cpu: 2500m in default, cpu: 500m in spot, memory: 1Gi in default"
+	if got := quotaUsage(podSets); got != want {
+		t.Errorf("quotaUsage() = %q, want %q", got, want)
+	}
+}
+
+func TestCycleProfile(t *testing.T) {
+	// A disabled profile doesn't measure anything.
+	disabled := newCycleProfile(false)
+	disabled.track(phaseSnapshot)()
+	disabled.trackAsync(phaseAPIUpdates)()
+	disabled.report(logrtesting.NewTestLogger(t), nil)
+
+	prof := newCycleProfile(true)
+	for i := 0; i < 2; i++ {
+		done := prof.track(phaseFlavorAssignment)
+		time.Sleep(time.Millisecond)
+		done()
+	}
+	if got := prof.durations[phaseFlavorAssignment]; got < 2*time.Millisecond {
+		t.Errorf("Flavor assignment took %v, want at least 2ms", got)
+	}
+	if got := prof.durations[phaseSnapshot]; got != 0 {
+		t.Errorf("Snapshot took %v, want 0", got)
+	}
+	// The updates that outlive the cycle are recorded on their own.
+	prof.trackAsync(phaseAPIUpdates)()
+	if got := prof.durations[phaseAPIUpdates]; got != 0 {
+		t.Errorf("API updates of the cycle took %v after an async update, want 0", got)
+	}
+	prof.report(logrtesting.NewTestLogger(t), []entry{{status: assumed}, {status: preempting}})
+}
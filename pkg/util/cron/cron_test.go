@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("Failed parsing time %q: %v", s, err)
+	}
+	return v
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"too few fields":     "0 22 * *",
+		"too many fields":    "0 22 * * * *",
+		"value out of range": "60 * * * *",
+		"invalid value":      "0 night * * *",
+		"invalid range":      "0 5-3 * * *",
+		"invalid step":       "*/0 * * * *",
+		"day of month zero":  "0 0 0 * *",
+	}
+	for name, spec := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(spec); err == nil {
+				t.Errorf("Parse(%q) succeeded, want error", spec)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	cases := map[string]struct {
+		spec string
+		from string
+		want string
+	}{
+		"every minute": {
+			spec: "* * * * *",
+			from: "2022-03-01T10:00:30Z",
+			want: "2022-03-01T10:01:00Z",
+		},
+		"strictly after": {
+			spec: "0 22 * * *",
+			from: "2022-03-01T22:00:00Z",
+			want: "2022-03-02T22:00:00Z",
+		},
+		"list and step": {
+			spec: "0,30 */6 * * *",
+			from: "2022-03-01T06:10:00Z",
+			want: "2022-03-01T06:30:00Z",
+		},
+		"weekdays": {
+			spec: "0 8 * * 1-5",
+			from: "2022-03-04T09:00:00Z", // Friday.
+			want: "2022-03-07T08:00:00Z",
+		},
+		"sunday as 7": {
+			spec: "0 0 * * 7",
+			from: "2022-03-01T00:00:00Z",
+			want: "2022-03-06T00:00:00Z",
+		},
+		"day of month or day of week": {
+			spec: "0 0 15 * 0",
+			from: "2022-03-07T00:00:00Z",
+			want: "2022-03-13T00:00:00Z",
+		},
+		"next year": {
+			spec: "0 0 1 1 *",
+			from: "2022-03-01T00:00:00Z",
+			want: "2023-01-01T00:00:00Z",
+		},
+		"leap day": {
+			spec: "0 0 29 2 *",
+			from: "2022-03-01T00:00:00Z",
+			want: "2024-02-29T00:00:00Z",
+		},
+		"in UTC": {
+			spec: "0 22 * * *",
+			from: "2022-03-01T23:00:00+02:00",
+			want: "2022-03-01T22:00:00Z",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := Parse(tc.spec)
+			if err != nil {
+				t.Fatalf("Failed parsing %q: %v", tc.spec, err)
+			}
+			want := mustParseTime(t, tc.want)
+			if got := s.Next(mustParseTime(t, tc.from)); !got.Equal(want) {
+				t.Errorf("Next() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestNextNeverMatches(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("Failed parsing: %v", err)
+	}
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next() = %v, want zero time", got)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	cases := map[string]struct {
+		spec       string
+		duration   time.Duration
+		at         string
+		wantActive bool
+		wantNext   string
+	}{
+		"before the window": {
+			spec:     "0 22 * * *",
+			duration: 8 * time.Hour,
+			at:       "2022-03-01T21:00:00Z",
+			wantNext: "2022-03-01T22:00:00Z",
+		},
+		"start of the window": {
+			spec:       "0 22 * * *",
+			duration:   8 * time.Hour,
+			at:         "2022-03-01T22:00:00Z",
+			wantActive: true,
+			wantNext:   "2022-03-02T06:00:00Z",
+		},
+		"window across midnight": {
+			spec:       "0 22 * * *",
+			duration:   8 * time.Hour,
+			at:         "2022-03-02T05:59:00Z",
+			wantActive: true,
+			wantNext:   "2022-03-02T06:00:00Z",
+		},
+		"end of the window": {
+			spec:     "0 22 * * *",
+			duration: 8 * time.Hour,
+			at:       "2022-03-02T06:00:00Z",
+			wantNext: "2022-03-02T22:00:00Z",
+		},
+		"overlapping windows": {
+			spec:       "0 * * * *",
+			duration:   90 * time.Minute,
+			at:         "2022-03-01T10:45:00Z",
+			wantActive: true,
+			wantNext:   "2022-03-01T11:30:00Z",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s, err := Parse(tc.spec)
+			if err != nil {
+				t.Fatalf("Failed parsing %q: %v", tc.spec, err)
+			}
+			gotActive, gotNext := s.Window(mustParseTime(t, tc.at), tc.duration)
+			if gotActive != tc.wantActive {
+				t.Errorf("Window() active = %t, want %t", gotActive, tc.wantActive)
+			}
+			if want := mustParseTime(t, tc.wantNext); !gotNext.Equal(want) {
+				t.Errorf("Window() next = %v, want %v", gotNext, want)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ package heap
 
 import (
 	"container/heap"
+	"sort"
 )
 
 // lessFunc is a function that receives two items and returns true if the first
@@ -149,6 +150,15 @@ func (h *Heap) Pop() interface{} {
 	return heap.Pop(&h.data)
 }
 
+// Peek returns the head of the heap without removing it. It returns nil if
+// the heap is empty.
+func (h *Heap) Peek() interface{} {
+	if h.Len() == 0 {
+		return nil
+	}
+	return h.data.items[h.data.keys[0]].obj
+}
+
 // Get returns the requested item, exists, error.
 func (h *Heap) Get(obj interface{}) (item interface{}) {
 	key := h.data.keyFunc(obj)
@@ -178,6 +188,16 @@ func (h *Heap) List() []interface{} {
 	return list
 }
 
+// Sorted returns a list of all the items in the order in which they would be
+// popped.
+func (h *Heap) Sorted() []interface{} {
+	list := h.List()
+	sort.Slice(list, func(i, j int) bool {
+		return h.data.lessFunc(list[i], list[j])
+	})
+	return list
+}
+
 // New returns a Heap which can be used to queue up items to process.
 func New(keyFn keyFunc, lessFn lessFunc) Heap {
 	return Heap{
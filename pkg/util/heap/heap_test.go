@@ -20,6 +20,7 @@ limitations under the License.
 package heap
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -65,6 +66,26 @@ func TestHeapBasic(t *testing.T) {
 	}
 }
 
+func TestHeap_Sorted(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	h.PushOrUpdate(mkHeapObj("foo", 10))
+	h.PushOrUpdate(mkHeapObj("bar", 1))
+	h.PushOrUpdate(mkHeapObj("baz", 11))
+	h.PushOrUpdate(mkHeapObj("zab", 30))
+
+	var got []interface{}
+	for _, obj := range h.Sorted() {
+		got = append(got, obj.(testHeapObject).val)
+	}
+	want := []interface{}{1, 10, 11, 30}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sorted() = %v, want %v", got, want)
+	}
+	if h.Len() != 4 {
+		t.Errorf("Sorted() removed items, got %d items, want 4", h.Len())
+	}
+}
+
 // Tests Heap.PushOrUpdate and ensures that heap invariant is preserved after adding items.
 func TestHeap_Add(t *testing.T) {
 	h := New(testHeapObjectKeyFunc, compareInts)
@@ -253,6 +274,25 @@ func TestHeap_GetByKey(t *testing.T) {
 	}
 }
 
+// TestHeap_Peek tests Heap.Peek function.
+func TestHeap_Peek(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	if obj := h.Peek(); obj != nil {
+		t.Fatalf("didn't expect to get any object from an empty heap")
+	}
+	h.PushOrUpdate(mkHeapObj("foo", 10))
+	h.PushOrUpdate(mkHeapObj("bar", 1))
+	h.PushOrUpdate(mkHeapObj("baz", 11))
+
+	obj := h.Peek()
+	if obj == nil || obj.(testHeapObject).val != 1 {
+		t.Fatalf("unexpected head %v", obj)
+	}
+	if h.Len() != 3 {
+		t.Fatalf("expected Peek to keep the items, got %d items", h.Len())
+	}
+}
+
 // TestHeap_List tests Heap.List function.
 func TestHeap_List(t *testing.T) {
 	h := New(testHeapObjectKeyFunc, compareInts)
@@ -55,6 +55,17 @@ func GetPriorityFromPriorityClass(ctx context.Context, client client.Client,
 	return pc.Name, pc.Value, nil
 }
 
+// GetPriorityFromWorkloadPriorityClass returns the priority populated from
+// the WorkloadPriorityClass with the given name.
+func GetPriorityFromWorkloadPriorityClass(ctx context.Context, client client.Client,
+	workloadPriorityClass string) (string, int32, error) {
+	wpc := &kueue.WorkloadPriorityClass{}
+	if err := client.Get(ctx, types.NamespacedName{Name: workloadPriorityClass}, wpc); err != nil {
+		return "", 0, err
+	}
+	return wpc.Name, wpc.Value, nil
+}
+
 func getDefaultPriority(ctx context.Context, client client.Client) (string, int32, error) {
 	dpc, err := getDefaultPriorityClass(ctx, client)
 	if err != nil {
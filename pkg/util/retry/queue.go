@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	baseDelay = 100 * time.Millisecond
+	maxDelay  = 5 * time.Minute
+
+	// jitterFactor is the maximum fraction of the backoff that is added at
+	// random to each delay, so that operations that failed together, for
+	// example during an apiserver hiccup, don't retry together.
+	jitterFactor = 0.5
+)
+
+// Func is an operation to retry.
+type Func func(ctx context.Context) error
+
+// Queue runs operations in the background until they succeed, waiting a
+// jittered exponential backoff between attempts. It's meant for event
+// handlers, which can't return errors for the controller to retry.
+type Queue struct {
+	log   logr.Logger
+	queue workqueue.RateLimitingInterface
+
+	sync.Mutex
+	pending map[string]*operation
+}
+
+type operation struct {
+	fn Func
+}
+
+var _ manager.Runnable = &Queue{}
+
+// NewQueue creates a Queue. It doesn't run operations until started.
+func NewQueue(name string) *Queue {
+	return newQueue(name, baseDelay, maxDelay)
+}
+
+func newQueue(name string, base, max time.Duration) *Queue {
+	limiter := &jitteredRateLimiter{
+		RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(base, max),
+	}
+	return &Queue{
+		log:     ctrl.Log.WithName(name),
+		queue:   workqueue.NewNamedRateLimitingQueue(limiter, name),
+		pending: make(map[string]*operation),
+	}
+}
+
+// Add schedules fn to run after a backoff. It replaces the operation pending
+// for the same key, if any, so that only the latest operation for an object
+// is retried.
+func (q *Queue) Add(key string, fn Func) {
+	q.Lock()
+	q.pending[key] = &operation{fn: fn}
+	q.Unlock()
+	q.queue.AddRateLimited(key)
+}
+
+// Start runs the operations until the context is done.
+func (q *Queue) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		q.queue.ShutDown()
+	}()
+	for q.processNext(ctx) {
+	}
+	return nil
+}
+
+func (q *Queue) processNext(ctx context.Context) bool {
+	item, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(item)
+	key := item.(string)
+	q.Lock()
+	op := q.pending[key]
+	q.Unlock()
+	if op == nil {
+		q.queue.Forget(key)
+		return true
+	}
+	if err := op.fn(ctx); err != nil {
+		q.log.Error(err, "Operation failed, retrying", "key", key, "attempts", q.queue.NumRequeues(key))
+		q.queue.AddRateLimited(key)
+		return true
+	}
+	q.Lock()
+	defer q.Unlock()
+	// If the operation was replaced while it ran, the new one is already
+	// scheduled.
+	if q.pending[key] == op {
+		delete(q.pending, key)
+		q.queue.Forget(key)
+	}
+	return true
+}
+
+// jitteredRateLimiter adds jitter to the delays of a RateLimiter.
+type jitteredRateLimiter struct {
+	workqueue.RateLimiter
+}
+
+func (r *jitteredRateLimiter) When(item interface{}) time.Duration {
+	return wait.Jitter(r.RateLimiter.When(item), jitterFactor)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+const testTimeout = 5 * time.Second
+
+func TestQueueRetriesUntilSuccess(t *testing.T) {
+	q := newQueue("test", time.Millisecond, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = q.Start(ctx)
+	}()
+
+	var mu sync.Mutex
+	attempts := 0
+	done := make(chan struct{})
+	q.Add("key", func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			return errors.New("transient")
+		}
+		close(done)
+		return nil
+	})
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("The operation didn't succeed")
+	}
+	// Give the queue the chance to run the operation again, which it
+	// shouldn't do after a success.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 {
+		t.Errorf("Operation ran %d times, want 3", attempts)
+	}
+	q.Lock()
+	defer q.Unlock()
+	if len(q.pending) != 0 {
+		t.Errorf("Queue has %d pending operations after the success, want none", len(q.pending))
+	}
+}
+
+func TestQueueReplacesPendingOperation(t *testing.T) {
+	q := newQueue("test", time.Millisecond, 10*time.Millisecond)
+	var mu sync.Mutex
+	var ran []string
+	done := make(chan struct{})
+	q.Add("key", func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, "old")
+		return nil
+	})
+	q.Add("key", func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, "new")
+		close(done)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = q.Start(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("The operation didn't run")
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 1 || ran[0] != "new" {
+		t.Errorf("Ran operations %v, want only the new one", ran)
+	}
+}
+
+func TestJitteredRateLimiter(t *testing.T) {
+	delay := 100 * time.Millisecond
+	maxDelay := delay + time.Duration(jitterFactor*float64(delay))
+	r := &jitteredRateLimiter{
+		RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(delay, delay),
+	}
+	for i := 0; i < 100; i++ {
+		if d := r.When("key"); d < delay || d > maxDelay {
+			t.Fatalf("Got delay %v, want in [%v, %v]", d, delay, maxDelay)
+		}
+	}
+}
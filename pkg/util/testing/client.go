@@ -0,0 +1,218 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Operation identifies a client call.
+type Operation string
+
+const (
+	OpGet          Operation = "Get"
+	OpList         Operation = "List"
+	OpCreate       Operation = "Create"
+	OpUpdate       Operation = "Update"
+	OpPatch        Operation = "Patch"
+	OpDelete       Operation = "Delete"
+	OpDeleteAllOf  Operation = "DeleteAllOf"
+	OpStatusUpdate Operation = "StatusUpdate"
+	OpStatusPatch  Operation = "StatusPatch"
+)
+
+var errInjected = errors.New("injected by FaultyClient")
+
+// Fault is an error returned by a FaultyClient instead of calling the
+// wrapped client.
+type Fault struct {
+	// Op is the operation that fails.
+	Op Operation
+	// Match restricts the fault to some objects. For List and DeleteAllOf,
+	// the object is the list or the object type. nil matches all the objects.
+	Match func(runtime.Object) bool
+	// Err is the error returned.
+	Err error
+	// Times is the number of calls that fail before the fault is exhausted.
+	// Zero means that all the calls fail.
+	Times int
+
+	calls int
+}
+
+// FaultyClient is a client.Client that returns the errors of the injected
+// faults, so that tests can exercise the error handling of its users.
+type FaultyClient struct {
+	client.Client
+
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+var _ client.Client = (*FaultyClient)(nil)
+
+// NewFaultyClient wraps c. Calls are passed through until faults are injected.
+func NewFaultyClient(c client.Client) *FaultyClient {
+	return &FaultyClient{Client: c}
+}
+
+// Inject adds a fault. Faults are evaluated in the order they were injected.
+func (c *FaultyClient) Inject(f Fault) *FaultyClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &f)
+	return c
+}
+
+// Reset removes all the faults.
+func (c *FaultyClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// Calls returns how many calls failed because of faults for the operation.
+func (c *FaultyClient) Calls(op Operation) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := 0
+	for _, f := range c.faults {
+		if f.Op == op {
+			calls += f.calls
+		}
+	}
+	return calls
+}
+
+func (c *FaultyClient) fault(op Operation, obj runtime.Object) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.faults {
+		if f.Op != op || (f.Times > 0 && f.calls >= f.Times) {
+			continue
+		}
+		if f.Match != nil && !f.Match(obj) {
+			continue
+		}
+		f.calls++
+		return f.Err
+	}
+	return nil
+}
+
+func (c *FaultyClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := c.fault(OpGet, obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *FaultyClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.fault(OpList, list); err != nil {
+		return err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *FaultyClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.fault(OpCreate, obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *FaultyClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.fault(OpUpdate, obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *FaultyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.fault(OpPatch, obj); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *FaultyClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.fault(OpDelete, obj); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *FaultyClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.fault(OpDeleteAllOf, obj); err != nil {
+		return err
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *FaultyClient) Status() client.StatusWriter {
+	return &faultyStatusWriter{StatusWriter: c.Client.Status(), c: c}
+}
+
+type faultyStatusWriter struct {
+	client.StatusWriter
+	c *FaultyClient
+}
+
+func (w *faultyStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := w.c.fault(OpStatusUpdate, obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *faultyStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.c.fault(OpStatusPatch, obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// MatchName returns a Fault.Match function for objects with the given name.
+func MatchName(name string) func(runtime.Object) bool {
+	return func(obj runtime.Object) bool {
+		cObj, ok := obj.(client.Object)
+		return ok && cObj.GetName() == name
+	}
+}
+
+// ConflictError returns the error of an update with a stale resourceVersion.
+func ConflictError(name string) error {
+	return apierrors.NewConflict(schema.GroupResource{}, name, errInjected)
+}
+
+// NotFoundError returns the error for an object that doesn't exist.
+func NotFoundError(name string) error {
+	return apierrors.NewNotFound(schema.GroupResource{}, name)
+}
+
+// TimeoutError returns the error of a request that the API server couldn't
+// complete in time.
+func TimeoutError() error {
+	return apierrors.NewTimeoutError(errInjected.Error(), 1)
+}
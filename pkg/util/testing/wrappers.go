@@ -17,6 +17,7 @@ limitations under the License.
 package testing
 
 import (
+	"strconv"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -79,18 +80,51 @@ func (j *JobWrapper) Parallelism(p int32) *JobWrapper {
 	return j
 }
 
+// Completions updates job completions.
+func (j *JobWrapper) Completions(c int32) *JobWrapper {
+	j.Spec.Completions = pointer.Int32(c)
+	return j
+}
+
 // PriorityClass updates job priorityclass.
 func (j *JobWrapper) PriorityClass(pc string) *JobWrapper {
 	j.Spec.Template.Spec.PriorityClassName = pc
 	return j
 }
 
+// WorkloadPriorityClass sets the WorkloadPriorityClass label of the job.
+func (j *JobWrapper) WorkloadPriorityClass(wpc string) *JobWrapper {
+	if j.Labels == nil {
+		j.Labels = make(map[string]string, 1)
+	}
+	j.Labels[constants.WorkloadPriorityClassLabel] = wpc
+	return j
+}
+
 // Queue updates the queue name of the job
 func (j *JobWrapper) Queue(queue string) *JobWrapper {
 	j.Annotations[constants.QueueAnnotation] = queue
 	return j
 }
 
+// PlacementHints sets the placement hints annotation of the job.
+func (j *JobWrapper) PlacementHints(hints string) *JobWrapper {
+	j.Annotations[constants.PlacementHintsAnnotation] = hints
+	return j
+}
+
+// Annotation sets an annotation of the job.
+func (j *JobWrapper) Annotation(k, v string) *JobWrapper {
+	j.Annotations[k] = v
+	return j
+}
+
+// MinParallelism sets the min parallelism annotation of the job.
+func (j *JobWrapper) MinParallelism(p int32) *JobWrapper {
+	j.Annotations[constants.JobMinParallelismAnnotation] = strconv.Itoa(int(p))
+	return j
+}
+
 // Toleration adds a toleration to the job.
 func (j *JobWrapper) Toleration(t corev1.Toleration) *JobWrapper {
 	j.Spec.Template.Spec.Tolerations = append(j.Spec.Template.Spec.Tolerations, t)
@@ -109,6 +143,13 @@ func (j *JobWrapper) Request(r corev1.ResourceName, v string) *JobWrapper {
 	return j
 }
 
+// Image sets the image and args of the default container.
+func (j *JobWrapper) Image(image string, args []string) *JobWrapper {
+	j.Spec.Template.Spec.Containers[0].Image = image
+	j.Spec.Template.Spec.Containers[0].Args = args
+	return j
+}
+
 // PriorityClassWrapper wraps a PriorityClass.
 type PriorityClassWrapper struct {
 	schedulingv1.PriorityClass
@@ -134,6 +175,29 @@ func (p *PriorityClassWrapper) Obj() *schedulingv1.PriorityClass {
 	return &p.PriorityClass
 }
 
+// WorkloadPriorityClassWrapper wraps a WorkloadPriorityClass.
+type WorkloadPriorityClassWrapper struct {
+	kueue.WorkloadPriorityClass
+}
+
+// MakeWorkloadPriorityClass creates a wrapper for a WorkloadPriorityClass.
+func MakeWorkloadPriorityClass(name string) *WorkloadPriorityClassWrapper {
+	return &WorkloadPriorityClassWrapper{kueue.WorkloadPriorityClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}}
+}
+
+// PriorityValue updates the value of the WorkloadPriorityClass.
+func (p *WorkloadPriorityClassWrapper) PriorityValue(v int32) *WorkloadPriorityClassWrapper {
+	p.Value = v
+	return p
+}
+
+// Obj returns the inner WorkloadPriorityClass.
+func (p *WorkloadPriorityClassWrapper) Obj() *kueue.WorkloadPriorityClass {
+	return &p.WorkloadPriorityClass
+}
+
 type WorkloadWrapper struct{ kueue.Workload }
 
 // MakeWorkload creates a wrapper for a Workload with a single
@@ -191,6 +255,42 @@ func (w *WorkloadWrapper) PriorityClass(priorityClassName string) *WorkloadWrapp
 	return w
 }
 
+func (w *WorkloadWrapper) Priority(priority int32) *WorkloadWrapper {
+	w.Spec.Priority = &priority
+	return w
+}
+
+// Active sets .spec.active of the workload.
+func (w *WorkloadWrapper) Active(active bool) *WorkloadWrapper {
+	w.Spec.Active = &active
+	return w
+}
+
+// MaximumExecutionTimeSeconds sets .spec.maximumExecutionTimeSeconds of the
+// workload.
+func (w *WorkloadWrapper) MaximumExecutionTimeSeconds(s int32) *WorkloadWrapper {
+	w.Spec.MaximumExecutionTimeSeconds = &s
+	return w
+}
+
+// Annotation sets an annotation of the workload.
+func (w *WorkloadWrapper) Annotation(key, value string) *WorkloadWrapper {
+	if w.Annotations == nil {
+		w.Annotations = make(map[string]string)
+	}
+	w.Annotations[key] = value
+	return w
+}
+
+// Condition sets the status of the given condition of the workload.
+func (w *WorkloadWrapper) Condition(t kueue.WorkloadConditionType, status corev1.ConditionStatus) *WorkloadWrapper {
+	w.Status.Conditions = append(w.Status.Conditions, kueue.WorkloadCondition{
+		Type:   t,
+		Status: status,
+	})
+	return w
+}
+
 // AdmissionWrapper wraps an Admission
 type AdmissionWrapper struct{ kueue.Admission }
 
@@ -215,6 +315,11 @@ func (w *AdmissionWrapper) Flavor(r corev1.ResourceName, f string) *AdmissionWra
 	return w
 }
 
+func (w *AdmissionWrapper) AdmissionChecks(checks ...string) *AdmissionWrapper {
+	w.Admission.AdmissionChecks = checks
+	return w
+}
+
 // QueueWrapper wraps a Queue.
 type QueueWrapper struct{ kueue.Queue }
 
@@ -239,6 +344,19 @@ func (q *QueueWrapper) ClusterQueue(c string) *QueueWrapper {
 	return q
 }
 
+// Weight updates the weight of the queue.
+func (q *QueueWrapper) Weight(w int32) *QueueWrapper {
+	q.Spec.Weight = w
+	return q
+}
+
+// MaxAdmittedWorkloads updates the maximum number of admitted workloads of
+// the queue.
+func (q *QueueWrapper) MaxAdmittedWorkloads(n int32) *QueueWrapper {
+	q.Spec.MaxAdmittedWorkloads = &n
+	return q
+}
+
 // ClusterQueueWrapper wraps a ClusterQueue.
 type ClusterQueueWrapper struct{ kueue.ClusterQueue }
 
@@ -267,9 +385,9 @@ func (c *ClusterQueueWrapper) Cohort(cohort string) *ClusterQueueWrapper {
 	return c
 }
 
-// Resource adds a resource with flavors.
-func (c *ClusterQueueWrapper) Resource(r *kueue.Resource) *ClusterQueueWrapper {
-	c.Spec.Resources = append(c.Spec.Resources, *r)
+// Resource adds a resource group.
+func (c *ClusterQueueWrapper) Resource(rg *kueue.ResourceGroup) *ClusterQueueWrapper {
+	c.Spec.ResourceGroups = append(c.Spec.ResourceGroups, *rg)
 	return c
 }
 
@@ -279,54 +397,148 @@ func (c *ClusterQueueWrapper) QueueingStrategy(strategy kueue.QueueingStrategy)
 	return c
 }
 
+// FairQueueing sets the fair queueing policy in this ClusterQueue.
+func (c *ClusterQueueWrapper) FairQueueing(policy kueue.FairQueueingPolicy) *ClusterQueueWrapper {
+	c.Spec.FairQueueing = policy
+	return c
+}
+
+// PriorityAging sets the priority aging of the pending workloads.
+func (c *ClusterQueueWrapper) PriorityAging(a kueue.PriorityAging) *ClusterQueueWrapper {
+	c.Spec.PriorityAging = &a
+	return c
+}
+
+// MaxAdmittedWorkloads sets the maximum number of admitted workloads in this
+// ClusterQueue.
+func (c *ClusterQueueWrapper) MaxAdmittedWorkloads(n int32) *ClusterQueueWrapper {
+	c.Spec.MaxAdmittedWorkloads = &n
+	return c
+}
+
+// NamespaceQuota sets the limit of the resource for each namespace in this
+// ClusterQueue.
+func (c *ClusterQueueWrapper) NamespaceQuota(name corev1.ResourceName, limit string) *ClusterQueueWrapper {
+	c.Spec.NamespaceQuotas = append(c.Spec.NamespaceQuotas, kueue.NamespaceQuota{
+		Name:  name,
+		Limit: resource.MustParse(limit),
+	})
+	return c
+}
+
+// QuotaSchedule adds a quota schedule to this ClusterQueue, overriding the
+// nominal quotas of the [flavor, resource] combinations with the given
+// ScheduledQuotas.
+func (c *ClusterQueueWrapper) QuotaSchedule(name, schedule string, durationSeconds int32, quotas ...kueue.ScheduledQuota) *ClusterQueueWrapper {
+	c.Spec.QuotaSchedules = append(c.Spec.QuotaSchedules, kueue.QuotaSchedule{
+		Name:            name,
+		Schedule:        schedule,
+		DurationSeconds: durationSeconds,
+		Quotas:          quotas,
+	})
+	return c
+}
+
+// QuotaShrinkPolicy sets the quota shrink policy in this ClusterQueue.
+func (c *ClusterQueueWrapper) QuotaShrinkPolicy(policy kueue.QuotaShrinkPolicy) *ClusterQueueWrapper {
+	c.Spec.QuotaShrinkPolicy = policy
+	return c
+}
+
+// StopPolicy sets the stop policy in this ClusterQueue.
+func (c *ClusterQueueWrapper) StopPolicy(policy kueue.StopPolicy) *ClusterQueueWrapper {
+	c.Spec.StopPolicy = policy
+	return c
+}
+
+// Preemption sets the preemption policies.
+func (c *ClusterQueueWrapper) Preemption(p kueue.ClusterQueuePreemption) *ClusterQueueWrapper {
+	c.Spec.Preemption = &p
+	return c
+}
+
+// FairWeight sets the fair sharing weight.
+func (c *ClusterQueueWrapper) FairWeight(w int32) *ClusterQueueWrapper {
+	c.Spec.FairSharing = &kueue.FairSharing{Weight: w}
+	return c
+}
+
 // NamespaceSelector sets the namespace selector.
 func (c *ClusterQueueWrapper) NamespaceSelector(s *metav1.LabelSelector) *ClusterQueueWrapper {
 	c.Spec.NamespaceSelector = s
 	return c
 }
 
-// ResourceWrapper wraps a resource.
-type ResourceWrapper struct{ kueue.Resource }
+// ResourceWrapper wraps a resource group.
+type ResourceWrapper struct{ kueue.ResourceGroup }
 
-// MakeResource creates a wrapper for a resource.
-func MakeResource(name corev1.ResourceName) *ResourceWrapper {
-	return &ResourceWrapper{kueue.Resource{
-		Name: name,
+// MakeResource creates a wrapper for a resource group that covers the given
+// resources.
+func MakeResource(names ...corev1.ResourceName) *ResourceWrapper {
+	return &ResourceWrapper{kueue.ResourceGroup{
+		CoveredResources: names,
 	}}
 }
 
-// Obj returns the inner resource.
-func (r *ResourceWrapper) Obj() *kueue.Resource {
-	return &r.Resource
+// Obj returns the inner resource group.
+func (r *ResourceWrapper) Obj() *kueue.ResourceGroup {
+	return &r.ResourceGroup
 }
 
-// Flavor appends a flavor.
-func (r *ResourceWrapper) Flavor(f *kueue.Flavor) *ResourceWrapper {
-	r.Flavors = append(r.Flavors, *f)
+// Flavor appends a flavor. The quotas of the flavor are for the covered
+// resources, in order.
+func (r *ResourceWrapper) Flavor(f *kueue.FlavorQuotas) *ResourceWrapper {
+	flavor := kueue.FlavorQuotas{
+		Name:      f.Name,
+		Resources: make([]kueue.ResourceQuota, len(f.Resources)),
+	}
+	for i, q := range f.Resources {
+		if i < len(r.CoveredResources) {
+			q.Name = r.CoveredResources[i]
+		}
+		flavor.Resources[i] = q
+	}
+	r.Flavors = append(r.Flavors, flavor)
+	return r
+}
+
+// FlavorAssignment sets the flavor assignment policy of the resource group.
+func (r *ResourceWrapper) FlavorAssignment(p kueue.FlavorAssignmentPolicy) *ResourceWrapper {
+	r.ResourceGroup.FlavorAssignment = p
 	return r
 }
 
-// FlavorWrapper wraps a resource flavor.
-type FlavorWrapper struct{ kueue.Flavor }
+// FlavorWrapper wraps the quotas of a resource flavor.
+type FlavorWrapper struct{ kueue.FlavorQuotas }
 
-// MakeFlavor creates a wrapper for a resource flavor.
-func MakeFlavor(rf, min string) *FlavorWrapper {
-	return &FlavorWrapper{kueue.Flavor{
+// MakeFlavor creates a wrapper for the quotas of a resource flavor, with the
+// given nominal quotas for the resources of the group it's added to.
+func MakeFlavor(rf string, nominalQuotas ...string) *FlavorWrapper {
+	f := &FlavorWrapper{kueue.FlavorQuotas{
 		Name: kueue.ResourceFlavorReference(rf),
-		Quota: kueue.Quota{
-			Min: resource.MustParse(min),
-		},
 	}}
+	for _, q := range nominalQuotas {
+		f.Resources = append(f.Resources, kueue.ResourceQuota{
+			NominalQuota: resource.MustParse(q),
+		})
+	}
+	return f
 }
 
-// Obj returns the inner flavor.
-func (f *FlavorWrapper) Obj() *kueue.Flavor {
-	return &f.Flavor
+// Obj returns the inner flavor quotas.
+func (f *FlavorWrapper) Obj() *kueue.FlavorQuotas {
+	return &f.FlavorQuotas
 }
 
-// Max updates the flavor max.
-func (f *FlavorWrapper) Max(c string) *FlavorWrapper {
-	f.Quota.Max = pointer.Quantity(resource.MustParse(c))
+// BorrowingLimit updates the borrowing limit of the last resource.
+func (f *FlavorWrapper) BorrowingLimit(c string) *FlavorWrapper {
+	f.Resources[len(f.Resources)-1].BorrowingLimit = pointer.Quantity(resource.MustParse(c))
+	return f
+}
+
+// LendingLimit updates the lending limit of the last resource.
+func (f *FlavorWrapper) LendingLimit(c string) *FlavorWrapper {
+	f.Resources[len(f.Resources)-1].LendingLimit = pointer.Quantity(resource.MustParse(c))
 	return f
 }
 
@@ -359,3 +571,42 @@ func (rf *ResourceFlavorWrapper) Taint(t corev1.Taint) *ResourceFlavorWrapper {
 	rf.Taints = append(rf.Taints, t)
 	return rf
 }
+
+// CohortWrapper wraps a Cohort.
+type CohortWrapper struct{ kueue.Cohort }
+
+// MakeCohort creates a wrapper for a Cohort.
+func MakeCohort(name string) *CohortWrapper {
+	return &CohortWrapper{kueue.Cohort{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}}
+}
+
+// Obj returns the inner Cohort.
+func (c *CohortWrapper) Obj() *kueue.Cohort {
+	return &c.Cohort
+}
+
+// Parent sets the parent of the Cohort.
+func (c *CohortWrapper) Parent(p string) *CohortWrapper {
+	c.Spec.Parent = p
+	return c
+}
+
+// Limit adds a limit for a flavor of a resource to the Cohort.
+func (c *CohortWrapper) Limit(r corev1.ResourceName, flavor, limit string) *CohortWrapper {
+	f := kueue.CohortFlavor{
+		Name:  kueue.ResourceFlavorReference(flavor),
+		Limit: resource.MustParse(limit),
+	}
+	for i := range c.Spec.Resources {
+		if c.Spec.Resources[i].Name == r {
+			c.Spec.Resources[i].Flavors = append(c.Spec.Resources[i].Flavors, f)
+			return c
+		}
+	}
+	c.Spec.Resources = append(c.Spec.Resources, kueue.CohortResource{Name: r, Flavors: []kueue.CohortFlavor{f}})
+	return c
+}
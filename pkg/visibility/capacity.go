@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"sigs.k8s.io/kueue/pkg/cache"
+)
+
+// CapacityPath is the path where the capacity of the ClusterQueues is served.
+const CapacityPath = "/clusterqueues/capacity"
+
+// CapacityLister lists the capacity of the ClusterQueues. cache.Cache is the
+// implementation used in production.
+type CapacityLister interface {
+	ClusterQueuesCapacity() []cache.ClusterQueueCapacity
+}
+
+// NewCapacityHandler returns a handler that serves, as JSON, the nominal,
+// used, borrowed and lent quota of each flavor of the ClusterQueues, and the
+// demand of their pending workloads for each resource, sorted by the name of
+// the ClusterQueue.
+// It allows components that scale the cluster, such as autoscalers, to size
+// the node groups of the flavors from the demand of the workloads.
+func NewCapacityHandler(lister CapacityLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(lister.ClusterQueuesCapacity()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
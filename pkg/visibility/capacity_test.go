@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"sigs.k8s.io/kueue/pkg/cache"
+)
+
+type fakeCapacityLister []cache.ClusterQueueCapacity
+
+func (l fakeCapacityLister) ClusterQueuesCapacity() []cache.ClusterQueueCapacity {
+	return l
+}
+
+func TestCapacityHandler(t *testing.T) {
+	capacities := []cache.ClusterQueueCapacity{{
+		Name:             "a",
+		PendingWorkloads: 1,
+		Resources: []cache.ResourceCapacity{{
+			Name:          corev1.ResourceCPU,
+			PendingDemand: resource.MustParse("2"),
+			Flavors: []cache.FlavorCapacity{{
+				Name:         "default",
+				NominalQuota: resource.MustParse("10"),
+				Used:         resource.MustParse("12"),
+				Borrowed:     resource.MustParse("2"),
+				Lent:         resource.MustParse("0"),
+			}},
+		}},
+	}}
+	rec := httptest.NewRecorder()
+	NewCapacityHandler(fakeCapacityLister(capacities)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CapacityPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Got status %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got []cache.ClusterQueueCapacity
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed decoding response: %v", err)
+	}
+	if diff := cmp.Diff(capacities, got); diff != "" {
+		t.Errorf("Unexpected response (-want,+got):\n%s", diff)
+	}
+}
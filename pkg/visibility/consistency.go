@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"sigs.k8s.io/kueue/pkg/cache"
+)
+
+// ConsistencyPath is the path where the quota consistency report is served.
+const ConsistencyPath = "/debug/consistency"
+
+// ConsistencyChecker reports inconsistencies between the admissions, the
+// quotas and the cache. cache.Cache is the implementation used in
+// production.
+type ConsistencyChecker interface {
+	CheckConsistency(context.Context) ([]cache.Violation, error)
+}
+
+// NewConsistencyHandler returns a handler that serves, as JSON, the
+// violations found by comparing the admissions recorded in the Workloads
+// against the quotas of the ClusterQueues and the cache. An empty list means
+// that no violation was found.
+func NewConsistencyHandler(checker ConsistencyChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		violations, err := checker.CheckConsistency(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if violations == nil {
+			violations = []cache.Violation{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(violations); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kueue/pkg/cache"
+)
+
+type fakeChecker struct {
+	violations []cache.Violation
+	err        error
+}
+
+func (c *fakeChecker) CheckConsistency(context.Context) ([]cache.Violation, error) {
+	return c.violations, c.err
+}
+
+func TestConsistencyHandler(t *testing.T) {
+	cases := map[string]struct {
+		checker    fakeChecker
+		wantStatus int
+		wantBody   string
+	}{
+		"no violations": {
+			wantStatus: http.StatusOK,
+			wantBody:   "[]",
+		},
+		"violations": {
+			checker: fakeChecker{
+				violations: []cache.Violation{{Type: cache.LeakedWorkload, ClusterQueue: "cq", Workload: "ns/wl", Message: "leaked"}},
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"type":"LeakedWorkload","clusterQueue":"cq","workload":"ns/wl","message":"leaked"}]`,
+		},
+		"error": {
+			checker:    fakeChecker{err: errors.New("listing failed")},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "listing failed",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewConsistencyHandler(&tc.checker).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ConsistencyPath, nil))
+			if rec.Code != tc.wantStatus {
+				t.Errorf("Got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tc.wantBody {
+				t.Errorf("Got body %s, want %s", got, tc.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"sigs.k8s.io/kueue/pkg/queue"
+)
+
+// PendingWorkloadsPath is the path where the pending workloads are served.
+const PendingWorkloadsPath = "/pendingworkloads"
+
+// PendingWorkloadsLister lists the pending workloads of a ClusterQueue or a
+// Queue, in order. queue.Manager is the implementation used in production.
+type PendingWorkloadsLister interface {
+	ClusterQueuePendingWorkloads(name string) ([]queue.PendingWorkload, bool)
+	QueuePendingWorkloads(namespace, name string) ([]queue.PendingWorkload, bool)
+}
+
+// NewPendingWorkloadsHandler returns a handler that serves, as JSON, the
+// pending workloads of the ClusterQueue given by the clusterQueue query
+// parameter, or of the Queue given by the namespace and queue query
+// parameters, in the order in which they are considered for admission.
+// The list is taken from the queues in memory, so that clients can learn the
+// position of their workloads without watching all the Workloads.
+func NewPendingWorkloadsHandler(lister PendingWorkloadsLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		cqName := query.Get("clusterQueue")
+		namespace, qName := query.Get("namespace"), query.Get("queue")
+		var pending []queue.PendingWorkload
+		var found bool
+		switch {
+		case cqName != "" && namespace == "" && qName == "":
+			pending, found = lister.ClusterQueuePendingWorkloads(cqName)
+			if !found {
+				http.Error(w, fmt.Sprintf("ClusterQueue %q not found", cqName), http.StatusNotFound)
+				return
+			}
+		case cqName == "" && namespace != "" && qName != "":
+			pending, found = lister.QueuePendingWorkloads(namespace, qName)
+			if !found {
+				http.Error(w, fmt.Sprintf("Queue %q not found in namespace %q", qName, namespace), http.StatusNotFound)
+				return
+			}
+		default:
+			http.Error(w, "must specify either clusterQueue, or namespace and queue", http.StatusBadRequest)
+			return
+		}
+		if pending == nil {
+			pending = []queue.PendingWorkload{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pending); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kueue/pkg/queue"
+)
+
+type fakePendingLister struct{}
+
+func (fakePendingLister) ClusterQueuePendingWorkloads(name string) ([]queue.PendingWorkload, bool) {
+	switch name {
+	case "cq":
+		return []queue.PendingWorkload{{Namespace: "ns", Name: "a", Queue: "q"}}, true
+	case "empty":
+		return nil, true
+	}
+	return nil, false
+}
+
+func (fakePendingLister) QueuePendingWorkloads(namespace, name string) ([]queue.PendingWorkload, bool) {
+	if namespace != "ns" || name != "q" {
+		return nil, false
+	}
+	return []queue.PendingWorkload{{Namespace: "ns", Name: "a", Queue: "q", PositionInClusterQueue: 1}}, true
+}
+
+func TestPendingWorkloadsHandler(t *testing.T) {
+	cases := map[string]struct {
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		"ClusterQueue": {
+			query:      "?clusterQueue=cq",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"namespace":"ns","name":"a","queue":"q","priority":0,"positionInClusterQueue":0,"positionInQueue":0}]`,
+		},
+		"empty ClusterQueue": {
+			query:      "?clusterQueue=empty",
+			wantStatus: http.StatusOK,
+			wantBody:   "[]",
+		},
+		"ClusterQueue not found": {
+			query:      "?clusterQueue=other",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `ClusterQueue "other" not found`,
+		},
+		"Queue": {
+			query:      "?namespace=ns&queue=q",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"namespace":"ns","name":"a","queue":"q","priority":0,"positionInClusterQueue":1,"positionInQueue":0}]`,
+		},
+		"Queue not found": {
+			query:      "?namespace=other&queue=q",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `Queue "q" not found in namespace "other"`,
+		},
+		"no queue": {
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "must specify either clusterQueue, or namespace and queue",
+		},
+		"Queue without namespace": {
+			query:      "?queue=q",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "must specify either clusterQueue, or namespace and queue",
+		},
+		"both kinds of queues": {
+			query:      "?clusterQueue=cq&namespace=ns&queue=q",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "must specify either clusterQueue, or namespace and queue",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewPendingWorkloadsHandler(fakePendingLister{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PendingWorkloadsPath+tc.query, nil))
+			if rec.Code != tc.wantStatus {
+				t.Errorf("Got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tc.wantBody {
+				t.Errorf("Got body %s, want %s", got, tc.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"sigs.k8s.io/kueue/pkg/cache"
+)
+
+// TopPath is the path where the usage of the ClusterQueues is served.
+const TopPath = "/clusterqueues/top"
+
+// UsageLister lists the usage of the ClusterQueues. cache.Cache is the
+// implementation used in production.
+type UsageLister interface {
+	ClusterQueuesUsage(cache.UsageSortKey) []cache.ClusterQueueUsage
+}
+
+// NewTopHandler returns a handler that serves the usage of the ClusterQueues
+// as JSON, with a per-flavor breakdown. The ClusterQueues are sorted by
+// utilization, or by borrowing when the sortBy query parameter is
+// "borrowing".
+// The usage is computed from the cache, so that clients don't need to read
+// the status of every ClusterQueue.
+func NewTopHandler(lister UsageLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sortBy := cache.SortByUtilization
+		if v := r.URL.Query().Get("sortBy"); v != "" {
+			sortBy = cache.UsageSortKey(v)
+		}
+		if sortBy != cache.SortByUtilization && sortBy != cache.SortByBorrowing {
+			http.Error(w, fmt.Sprintf("unsupported sortBy %q, must be %q or %q", sortBy, cache.SortByUtilization, cache.SortByBorrowing), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(lister.ClusterQueuesUsage(sortBy)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package visibility
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/kueue/pkg/cache"
+)
+
+type fakeLister struct {
+	sortBy cache.UsageSortKey
+}
+
+func (l *fakeLister) ClusterQueuesUsage(sortBy cache.UsageSortKey) []cache.ClusterQueueUsage {
+	l.sortBy = sortBy
+	return []cache.ClusterQueueUsage{{Name: "a", UtilizationPercentage: 50}}
+}
+
+func TestTopHandler(t *testing.T) {
+	cases := map[string]struct {
+		query      string
+		wantStatus int
+		wantSortBy cache.UsageSortKey
+	}{
+		"default": {
+			wantStatus: http.StatusOK,
+			wantSortBy: cache.SortByUtilization,
+		},
+		"sort by borrowing": {
+			query:      "?sortBy=borrowing",
+			wantStatus: http.StatusOK,
+			wantSortBy: cache.SortByBorrowing,
+		},
+		"unsupported sort": {
+			query:      "?sortBy=name",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			lister := &fakeLister{}
+			rec := httptest.NewRecorder()
+			NewTopHandler(lister).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, TopPath+tc.query, nil))
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("Got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if lister.sortBy != tc.wantSortBy {
+				t.Errorf("Listed usage sorted by %q, want %q", lister.sortBy, tc.wantSortBy)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var got []cache.ClusterQueueUsage
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Failed decoding response: %v", err)
+			}
+			if diff := cmp.Diff([]cache.ClusterQueueUsage{{Name: "a", UtilizationPercentage: 50}}, got); diff != "" {
+				t.Errorf("Unexpected response (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -18,12 +18,19 @@ package workload
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"strconv"
 	"strings"
+	"time"
 
+	"gopkg.in/inf.v0"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
@@ -39,15 +46,118 @@ type Info struct {
 }
 
 type PodSetResources struct {
-	Name     string
+	Name string
+	// Count is the number of pods that the requests account for. It's lower
+	// than the count of the podSet when the workload is partially admitted.
+	Count    int32
 	Requests Requests
 	Flavors  map[corev1.ResourceName]string
+	// Splits hold the requests and flavors of subsets of the pods, when the
+	// podSet was split across flavors. Flavors is empty in that case.
+	Splits []PodSetSplit
 }
 
-func NewInfo(w *kueue.Workload) *Info {
+// PodSetSplit holds the requests and flavors of a subset of the pods of a
+// podSet.
+type PodSetSplit struct {
+	Count    int32
+	Requests Requests
+	Flavors  map[corev1.ResourceName]string
+}
+
+const (
+	// EvictedByPreemption is the reason of the Evicted condition of the
+	// workloads preempted to admit other workloads.
+	EvictedByPreemption = "Preempted"
+
+	// EvictedByPodsReadyTimeout is the reason of the Evicted condition of the
+	// workloads whose pods weren't ready within the configured timeout.
+	EvictedByPodsReadyTimeout = "PodsReadyTimeout"
+
+	// EvictedByAdmissionCheck is the reason of the Evicted condition of the
+	// workloads for which an AdmissionCheck requested a retry.
+	EvictedByAdmissionCheck = "AdmissionCheck"
+
+	// EvictedByAdmissionCheckRejected is the reason of the Evicted condition
+	// of the workloads that an AdmissionCheck rejected. They are deactivated.
+	EvictedByAdmissionCheckRejected = "AdmissionCheckRejected"
+
+	// EvictedByClusterQueueStopped is the reason of the Evicted condition of
+	// the workloads drained from a ClusterQueue with the HoldAndDrain
+	// stopPolicy.
+	EvictedByClusterQueueStopped = "ClusterQueueStopped"
+
+	// EvictedByQuotaShrink is the reason of the Evicted condition of the
+	// workloads evicted from a ClusterQueue with the Evict quotaShrinkPolicy
+	// because its quotas shrunk below its usage.
+	EvictedByQuotaShrink = "QuotaShrink"
+
+	// EvictedByDeactivation is the reason of the Evicted condition of the
+	// workloads whose .spec.active was set to false.
+	EvictedByDeactivation = "InactiveWorkload"
+
+	// EvictedByDeadlineExceeded is the reason of the Evicted condition of the
+	// workloads that stayed admitted for longer than their
+	// .spec.maximumExecutionTimeSeconds.
+	EvictedByDeadlineExceeded = "DeadlineExceeded"
+
+	// EvictedByRequeuingLimitExceeded is the reason of the Evicted condition
+	// of the workloads whose pods didn't become ready in time more times than
+	// the requeuing limit of their ClusterQueue. They are deactivated, and
+	// their Admitted condition keeps this reason until they are activated.
+	EvictedByRequeuingLimitExceeded = "RequeuingLimitExceeded"
+)
+
+// InfoOption configures how NewInfo calculates the requests of a workload.
+type InfoOption func(*infoOptions)
+
+type infoOptions struct {
+	excludedResourcePrefixes []string
+	transformations          map[corev1.ResourceName]ResourceTransformation
+}
+
+// ResourceTransformation converts the requests of the Input resource into
+// requests of the Outputs, before they are accounted against the quotas.
+type ResourceTransformation struct {
+	Input corev1.ResourceName
+	// Retain keeps the requests of the Input resource, in addition to the
+	// Outputs. Otherwise, the Input resource is replaced by the Outputs.
+	Retain bool
+	// Outputs are the quantities of each resource that one unit of the Input
+	// resource amounts to. They can be fractions.
+	Outputs corev1.ResourceList
+}
+
+// WithExcludedResourcePrefixes makes NewInfo ignore the resources whose name
+// starts with any of the prefixes, so that they don't need quota.
+func WithExcludedResourcePrefixes(prefixes []string) InfoOption {
+	return func(o *infoOptions) {
+		o.excludedResourcePrefixes = prefixes
+	}
+}
+
+// WithResourceTransformations makes NewInfo convert the requests of the
+// workloads with the transformations. For example, a transformation can
+// account a MIG partition as a fraction of a GPU. The transformations are
+// applied to the total requests of each podSet, rounding up, and before
+// excluding resources.
+func WithResourceTransformations(transformations []ResourceTransformation) InfoOption {
+	return func(o *infoOptions) {
+		o.transformations = make(map[corev1.ResourceName]ResourceTransformation, len(transformations))
+		for _, t := range transformations {
+			o.transformations[t.Input] = t
+		}
+	}
+}
+
+func NewInfo(w *kueue.Workload, opts ...InfoOption) *Info {
+	var options infoOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 	return &Info{
 		Obj:           w,
-		TotalRequests: totalRequests(&w.Spec),
+		TotalRequests: totalRequests(&w.Spec, &w.Status, &options),
 	}
 }
 
@@ -55,29 +165,70 @@ func Key(w *kueue.Workload) string {
 	return fmt.Sprintf("%s/%s", w.Namespace, w.Name)
 }
 
-func totalRequests(spec *kueue.WorkloadSpec) []PodSetResources {
+// IsActive returns whether the workload can be admitted, as set in
+// .spec.active.
+func IsActive(w *kueue.Workload) bool {
+	return w.Spec.Active == nil || *w.Spec.Active
+}
+
+func totalRequests(spec *kueue.WorkloadSpec, status *kueue.WorkloadStatus, options *infoOptions) []PodSetResources {
 	if len(spec.PodSets) == 0 {
 		return nil
 	}
 	res := make([]PodSetResources, 0, len(spec.PodSets))
-	var podSetFlavors map[string]map[corev1.ResourceName]string
+	var podSetFlavors map[string]*kueue.PodSetFlavors
 	if spec.Admission != nil {
-		podSetFlavors = make(map[string]map[corev1.ResourceName]string, len(spec.Admission.PodSetFlavors))
-		for _, ps := range spec.Admission.PodSetFlavors {
-			podSetFlavors[ps.Name] = ps.Flavors
+		podSetFlavors = make(map[string]*kueue.PodSetFlavors, len(spec.Admission.PodSetFlavors))
+		for i := range spec.Admission.PodSetFlavors {
+			ps := &spec.Admission.PodSetFlavors[i]
+			podSetFlavors[ps.Name] = ps
 		}
 	}
+	reclaimable := make(map[string]int32, len(status.ReclaimablePods))
+	for _, rp := range status.ReclaimablePods {
+		reclaimable[rp.Name] = rp.Count
+	}
 	for _, ps := range spec.PodSets {
 		setRes := PodSetResources{
-			Name: ps.Name,
-		}
-		setRes.Requests = podRequests(&ps.Spec)
-		setRes.Requests.scale(int64(ps.Count))
-		flavors := podSetFlavors[ps.Name]
-		if len(flavors) > 0 {
-			setRes.Flavors = make(map[corev1.ResourceName]string, len(flavors))
-			for r, t := range flavors {
-				setRes.Flavors[r] = t
+			Name:  ps.Name,
+			Count: ps.Count,
+		}
+		psFlavors := podSetFlavors[ps.Name]
+		if psFlavors != nil && psFlavors.Count != nil {
+			setRes.Count = *psFlavors.Count
+		}
+		if psFlavors != nil {
+			// The pods of an admitted workload that finished don't need
+			// quota anymore.
+			setRes.Count -= min32(reclaimable[ps.Name], setRes.Count)
+		}
+		podReqs := podRequests(&ps.Spec)
+		// Each pod counts towards the quota for pods, when the admission
+		// assigned a flavor for pods.
+		if psFlavors != nil && assignsPods(psFlavors) {
+			podReqs[corev1.ResourcePods] = 1
+		}
+		setRes.Requests = options.requests(podReqs, setRes.Count)
+		if psFlavors != nil {
+			setRes.Flavors = copyFlavors(psFlavors.Flavors)
+			for _, split := range psFlavors.Splits {
+				setRes.Splits = append(setRes.Splits, PodSetSplit{
+					Count:    split.Count,
+					Requests: options.requests(podReqs, split.Count),
+					Flavors:  copyFlavors(split.Flavors),
+				})
+			}
+			// The reclaimable pods are taken from the last splits.
+			excess := -setRes.Count
+			for _, split := range setRes.Splits {
+				excess += split.Count
+			}
+			for i := len(setRes.Splits) - 1; i >= 0 && excess > 0; i-- {
+				split := &setRes.Splits[i]
+				removed := min32(excess, split.Count)
+				split.Count -= removed
+				split.Requests = options.requests(podReqs, split.Count)
+				excess -= removed
 			}
 		}
 		res = append(res, setRes)
@@ -85,24 +236,92 @@ func totalRequests(spec *kueue.WorkloadSpec) []PodSetResources {
 	return res
 }
 
+// assignsPods returns whether the podSet, or any of its splits, got a flavor
+// for the pods resource, which happens when the clusterQueue has quota for it.
+func assignsPods(psFlavors *kueue.PodSetFlavors) bool {
+	if _, ok := psFlavors.Flavors[corev1.ResourcePods]; ok {
+		return true
+	}
+	for _, split := range psFlavors.Splits {
+		if _, ok := split.Flavors[corev1.ResourcePods]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPodsRequests returns a copy of the requests where each pod requests one
+// unit of the pods resource, for the clusterQueues that have quota for pods.
+func WithPodsRequests(requests []PodSetResources) []PodSetResources {
+	res := make([]PodSetResources, len(requests))
+	for i, ps := range requests {
+		res[i] = ps
+		res[i].Requests = make(Requests, len(ps.Requests)+1)
+		for name, v := range ps.Requests {
+			res[i].Requests[name] = v
+		}
+		res[i].Requests[corev1.ResourcePods] = int64(ps.Count)
+	}
+	return res
+}
+
+// requests returns the requests of count pods, transformed and without the
+// excluded resources.
+func (o *infoOptions) requests(podReqs Requests, count int32) Requests {
+	res := podReqs.Scaled(int64(count))
+	if len(o.transformations) > 0 {
+		res = res.transformed(o.transformations)
+	}
+	res.dropPrefixes(o.excludedResourcePrefixes)
+	return res
+}
+
+func copyFlavors(flavors map[corev1.ResourceName]string) map[corev1.ResourceName]string {
+	if len(flavors) == 0 {
+		return nil
+	}
+	res := make(map[corev1.ResourceName]string, len(flavors))
+	for r, t := range flavors {
+		res[r] = t
+	}
+	return res
+}
+
 // The following resources calculations are inspired on
 // https://github.com/kubernetes/kubernetes/blob/master/pkg/scheduler/framework/types.go
 
 // Requests maps ResourceName to flavor to value; for CPU it is tracked in MilliCPU.
 type Requests map[corev1.ResourceName]int64
 
+// podRequests returns the requests of a pod, the way kube-scheduler accounts
+// them: the sum of the requests of the containers, or the largest requests of
+// an init container if higher, plus the pod overhead.
 func podRequests(spec *corev1.PodSpec) Requests {
 	res := Requests{}
-	for _, c := range spec.Containers {
-		res.add(newRequests(c.Resources.Requests))
+	for i := range spec.Containers {
+		res.add(containerRequests(&spec.Containers[i]))
 	}
-	for _, c := range spec.InitContainers {
-		res.setMax(newRequests(c.Resources.Requests))
+	for i := range spec.InitContainers {
+		res.setMax(containerRequests(&spec.InitContainers[i]))
 	}
 	res.add(newRequests(spec.Overhead))
 	return res
 }
 
+// containerRequests returns the requests of the container. Like the
+// apiserver does when defaulting pods, limits are used for the resources
+// with no requests. This is common for hugepages, for which requests must
+// be equal to limits.
+func containerRequests(c *corev1.Container) Requests {
+	res := newRequests(c.Resources.Requests)
+	for name, quant := range c.Resources.Limits {
+		if _, ok := res[name]; !ok {
+			res[name] = ResourceValue(name, quant)
+		}
+	}
+	return res
+}
+
 func newRequests(rl corev1.ResourceList) Requests {
 	r := Requests{}
 	for name, quant := range rl {
@@ -146,10 +365,63 @@ func (r Requests) setMax(o Requests) {
 	}
 }
 
-func (r Requests) scale(f int64) {
+func (r Requests) dropPrefixes(prefixes []string) {
 	for name := range r {
-		r[name] *= f
+		for _, p := range prefixes {
+			if strings.HasPrefix(string(name), p) {
+				delete(r, name)
+				break
+			}
+		}
+	}
+}
+
+// transformed returns the requests with the inputs of the transformations
+// converted to their outputs. The outputs are rounded up to the units of
+// the Requests: milli-units for CPU and absolute units otherwise.
+func (r Requests) transformed(transformations map[corev1.ResourceName]ResourceTransformation) Requests {
+	res := make(Requests, len(r))
+	outputs := make(map[corev1.ResourceName]*inf.Dec)
+	for name, val := range r {
+		t, ok := transformations[name]
+		if !ok || t.Retain {
+			res[name] += val
+		}
+		if !ok {
+			continue
+		}
+		input := ResourceQuantity(name, val)
+		for outName, perUnit := range t.Outputs {
+			perUnit := perUnit.DeepCopy()
+			out := new(inf.Dec).Mul(input.AsDec(), perUnit.AsDec())
+			if outputs[outName] == nil {
+				outputs[outName] = new(inf.Dec)
+			}
+			outputs[outName].Add(outputs[outName], out)
+		}
+	}
+	// The outputs are added up before rounding, so that many small inputs
+	// don't amount to more than their sum.
+	for name, d := range outputs {
+		res[name] += ResourceValue(name, *resource.NewDecimalQuantity(*d, resource.DecimalSI))
+	}
+	return res
+}
+
+// Scaled returns a copy of the requests, multiplied by f.
+func (r Requests) Scaled(f int64) Requests {
+	res := make(Requests, len(r))
+	for name, val := range r {
+		res[name] = val * f
+	}
+	return res
+}
+
+func min32(v1, v2 int32) int32 {
+	if v1 < v2 {
+		return v1
 	}
+	return v2
 }
 
 func max(v1, v2 int64) int64 {
@@ -180,8 +452,30 @@ func UpdateStatus(ctx context.Context,
 	conditionType kueue.WorkloadConditionType,
 	conditionStatus corev1.ConditionStatus,
 	reason, message string) error {
-	conditionIndex := FindConditionIndex(&wl.Status, conditionType)
+	// Avoid modifying the object in the cache.
+	newWl := *wl
+	newWl.Status = *newWl.Status.DeepCopy()
+	setCondition(&newWl.Status, conditionType, conditionStatus, reason, message)
+	return c.Status().Update(ctx, &newWl)
+}
+
+// UpdatePendingStatus records that the workload couldn't be admitted, along
+// with the backoff before the next attempt, if any.
+func UpdatePendingStatus(ctx context.Context, c client.Client, wl *kueue.Workload, message string, backoff *kueue.AdmissionBackoff) error {
+	newWl := *wl
+	newWl.Status = *newWl.Status.DeepCopy()
+	newWl.Status.AdmissionBackoff = backoff
+	setCondition(&newWl.Status, kueue.WorkloadAdmitted, corev1.ConditionFalse, "Pending", message)
+	return c.Status().Update(ctx, &newWl)
+}
 
+// setCondition sets the condition in the status, replacing the existing
+// condition of the same type. Replacing a true Admitted condition adds the
+// time since it was set to the accumulated execution time of the workload.
+func setCondition(status *kueue.WorkloadStatus,
+	conditionType kueue.WorkloadConditionType,
+	conditionStatus corev1.ConditionStatus,
+	reason, message string) {
 	now := metav1.Now()
 	condition := kueue.WorkloadCondition{
 		Type:               conditionType,
@@ -191,17 +485,15 @@ func UpdateStatus(ctx context.Context,
 		Reason:             reason,
 		Message:            message,
 	}
-	// Avoid modifying the object in the cache.
-	newWl := *wl
-	newWl.Status = *newWl.Status.DeepCopy()
-
-	if conditionIndex == -1 {
-		newWl.Status.Conditions = append(newWl.Status.Conditions, condition)
+	if i := FindConditionIndex(status, conditionType); i != -1 {
+		if prev := status.Conditions[i]; prev.Type == kueue.WorkloadAdmitted && prev.Status == corev1.ConditionTrue {
+			executed := int32(now.Sub(prev.LastTransitionTime.Time) / time.Second)
+			status.AccumulatedPastExecutionTimeSeconds = pointer.Int32(pointer.Int32Deref(status.AccumulatedPastExecutionTimeSeconds, 0) + executed)
+		}
+		status.Conditions[i] = condition
 	} else {
-		newWl.Status.Conditions[conditionIndex] = condition
+		status.Conditions = append(status.Conditions, condition)
 	}
-
-	return c.Status().Update(ctx, &newWl)
 }
 
 func UpdateStatusIfChanged(ctx context.Context,
@@ -210,21 +502,201 @@ func UpdateStatusIfChanged(ctx context.Context,
 	conditionType kueue.WorkloadConditionType,
 	conditionStatus corev1.ConditionStatus,
 	reason, message string) error {
+	if ConditionMatches(wl, conditionType, conditionStatus, reason, message) {
+		// No need to update
+		return nil
+	}
+	return UpdateStatus(ctx, c, wl, conditionType, conditionStatus, reason, message)
+}
+
+// ConditionMatches returns whether the workload has the condition with the
+// given status, reason and message.
+func ConditionMatches(wl *kueue.Workload,
+	conditionType kueue.WorkloadConditionType,
+	conditionStatus corev1.ConditionStatus,
+	reason, message string) bool {
 	i := FindConditionIndex(&wl.Status, conditionType)
 	if i == -1 {
-		// We are adding new pod condition.
-		return UpdateStatus(ctx, c, wl, conditionType, conditionStatus, reason, message)
+		return false
 	}
-	if wl.Status.Conditions[i].Status == conditionStatus && wl.Status.Conditions[i].Type == conditionType &&
-		wl.Status.Conditions[i].Reason == reason && wl.Status.Conditions[i].Message == message {
-		// No need to update
+	c := &wl.Status.Conditions[i]
+	return c.Status == conditionStatus && c.Reason == reason && c.Message == message
+}
+
+// Evict marks the workload for eviction, with the given reason and message.
+// The workload controller clears the admission of the workload, which stops
+// its job, and requeues it.
+func Evict(ctx context.Context, c client.Client, wl *kueue.Workload, reason, message string) error {
+	return UpdateStatus(ctx, c, wl, kueue.WorkloadEvicted, corev1.ConditionTrue, reason, message)
+}
+
+// QueueOrderTimestamp returns the timestamp that orders the workload among
+// the pending workloads with the same priority. It's the time of the
+// eviction for a workload evicted because its pods didn't become ready, so
+// that it doesn't go back ahead of the workloads that waited behind it, and
+// the creation time otherwise.
+func QueueOrderTimestamp(w *kueue.Workload) *metav1.Time {
+	if i := FindConditionIndex(&w.Status, kueue.WorkloadEvicted); i != -1 && w.Status.Conditions[i].Reason == EvictedByPodsReadyTimeout {
+		return &w.Status.Conditions[i].LastTransitionTime
+	}
+	return &w.CreationTimestamp
+}
+
+// FinishEviction records that the workload is no longer admitted because of
+// the eviction, keeping the reason and message of the eviction.
+func FinishEviction(ctx context.Context, c client.Client, wl *kueue.Workload) error {
+	i := FindConditionIndex(&wl.Status, kueue.WorkloadEvicted)
+	if i == -1 {
 		return nil
 	}
-	// Updating an existing condition
-	return UpdateStatus(ctx, c, wl, conditionType, conditionStatus, reason, message)
+	evicted := wl.Status.Conditions[i]
+	newWl := *wl
+	newWl.Status = *newWl.Status.DeepCopy()
+	setCondition(&newWl.Status, kueue.WorkloadEvicted, corev1.ConditionFalse, evicted.Reason, evicted.Message)
+	setCondition(&newWl.Status, kueue.WorkloadAdmitted, corev1.ConditionFalse, "Evicted", evicted.Message)
+	return c.Status().Update(ctx, &newWl)
+}
+
+// UnsetQuotaReservation records that the workload no longer has a quota
+// reservation and clears the state of its admission checks.
+func UnsetQuotaReservation(ctx context.Context, c client.Client, wl *kueue.Workload, reason, message string) error {
+	newWl := *wl
+	newWl.Status = *newWl.Status.DeepCopy()
+	newWl.Status.AdmissionChecks = nil
+	setCondition(&newWl.Status, kueue.WorkloadQuotaReserved, corev1.ConditionFalse, reason, message)
+	return c.Status().Update(ctx, &newWl)
+}
+
+// RecordEvent records an event on the workload and, if the workload is owned
+// by a job, on the job too, so that the event shows up when describing any of
+// them.
+func RecordEvent(recorder record.EventRecorder, wl *kueue.Workload, eventtype, reason, message string) {
+	recorder.Event(wl, eventtype, reason, message)
+	owner := metav1.GetControllerOf(wl)
+	if owner == nil {
+		return
+	}
+	// The event only needs a reference to the owner, so it's not read.
+	recorder.Event(&metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: owner.APIVersion, Kind: owner.Kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: wl.Namespace,
+			Name:      owner.Name,
+			UID:       owner.UID,
+		},
+	}, eventtype, reason, message)
+}
+
+// FindAdmissionCheck returns the state of the AdmissionCheck with the given
+// name, or nil if it's not recorded.
+func FindAdmissionCheck(checks []kueue.AdmissionCheckState, name string) *kueue.AdmissionCheckState {
+	for i := range checks {
+		if checks[i].Name == name {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+// HasQuotaReservation returns whether the workload has a quota reservation
+// in a ClusterQueue, recorded in .spec.admission. The workload holds the quota
+// while its AdmissionChecks complete, before it's admitted.
+func HasQuotaReservation(w *kueue.Workload) bool {
+	return w.Spec.Admission != nil
+}
+
+// IsAdmitted returns whether the workload has a quota reservation and all
+// the AdmissionChecks of the reservation are Ready.
+func IsAdmitted(w *kueue.Workload) bool {
+	if !HasQuotaReservation(w) {
+		return false
+	}
+	for _, name := range w.Spec.Admission.AdmissionChecks {
+		check := FindAdmissionCheck(w.Status.AdmissionChecks, name)
+		if check == nil || check.State != kueue.CheckStateReady {
+			return false
+		}
+	}
+	return true
 }
 
 func InCondition(w *kueue.Workload, condition kueue.WorkloadConditionType) bool {
 	i := FindConditionIndex(&w.Status, condition)
 	return i != -1 && w.Status.Conditions[i].Status == corev1.ConditionTrue
 }
+
+// PodSetsHash returns a hash that identifies equivalent pod sets, so that a
+// workload can be recognized when it's recreated. Only the counts and
+// containers are considered, as the node selectors and affinities of a job
+// change when it's started.
+func PodSetsHash(podSets []kueue.PodSet) string {
+	type identity struct {
+		Count          int32              `json:"count"`
+		InitContainers []corev1.Container `json:"initContainers,omitempty"`
+		Containers     []corev1.Container `json:"containers"`
+	}
+	ids := make([]identity, len(podSets))
+	for i, ps := range podSets {
+		ids[i] = identity{
+			Count:          ps.Count,
+			InitContainers: ps.Spec.InitContainers,
+			Containers:     ps.Spec.Containers,
+		}
+	}
+	// Marshaling the API types can't fail.
+	data, _ := json.Marshal(ids)
+	h := fnv.New64a()
+	h.Write(data)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// InjectRequiredNodeSelectorTerms adds the terms to the required node
+// affinity of the pod spec. If the pod spec already has required terms,
+// each of them is combined with each of the new terms, so that both the
+// original and the new requirements are satisfied.
+func InjectRequiredNodeSelectorTerms(spec *corev1.PodSpec, terms []corev1.NodeSelectorTerm) {
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	if spec.Affinity.NodeAffinity == nil {
+		spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	nodeAffinity := spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = terms
+		return
+	}
+	combined := make([]corev1.NodeSelectorTerm, 0, len(required.NodeSelectorTerms)*len(terms))
+	for _, existing := range required.NodeSelectorTerms {
+		for _, t := range terms {
+			term := *existing.DeepCopy()
+			term.MatchExpressions = append(term.MatchExpressions, t.MatchExpressions...)
+			term.MatchFields = append(term.MatchFields, t.MatchFields...)
+			combined = append(combined, term)
+		}
+	}
+	required.NodeSelectorTerms = combined
+}
+
+// PodSetsWithPlacementHints returns the podSets of the workload with the
+// placement hints added to their required node affinity. The workload is not
+// modified.
+func PodSetsWithPlacementHints(w *kueue.Workload) []kueue.PodSet {
+	if len(w.Spec.PlacementHints) == 0 {
+		return w.Spec.PodSets
+	}
+	podSets := make([]kueue.PodSet, len(w.Spec.PodSets))
+	for i := range w.Spec.PodSets {
+		w.Spec.PodSets[i].DeepCopyInto(&podSets[i])
+		hints := make([]corev1.NodeSelectorTerm, len(w.Spec.PlacementHints))
+		for j := range w.Spec.PlacementHints {
+			w.Spec.PlacementHints[j].DeepCopyInto(&hints[j])
+		}
+		InjectRequiredNodeSelectorTerms(&podSets[i].Spec, hints)
+	}
+	return podSets
+}
@@ -18,13 +18,17 @@ package workload
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	utiltesting "sigs.k8s.io/kueue/pkg/util/testing"
@@ -93,6 +97,44 @@ func TestPodRequests(t *testing.T) {
 				"ex.com/ssd": 1,
 			},
 		},
+		"storage and hugepages": {
+			spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceEphemeralStorage: resource.MustParse("2Gi"),
+								"hugepages-2Mi":                 resource.MustParse("4Mi"),
+							},
+						},
+					},
+					{
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{
+								"hugepages-1Gi": resource.MustParse("1Gi"),
+							},
+						},
+					},
+				},
+				InitContainers: []corev1.Container{
+					{
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{
+								"hugepages-2Mi": resource.MustParse("8Mi"),
+							},
+						},
+					},
+				},
+			},
+			wantRequests: Requests{
+				corev1.ResourceEphemeralStorage: 1024 * 1024 * 1024,
+				"hugepages-2Mi":                 8 * 1024 * 1024,
+				"hugepages-1Gi":                 1024 * 1024 * 1024,
+			},
+		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -104,6 +146,45 @@ func TestPodRequests(t *testing.T) {
 	}
 }
 
+func TestResourceQuantity(t *testing.T) {
+	cases := map[corev1.ResourceName]struct {
+		value    int64
+		wantQuan string
+	}{
+		corev1.ResourceCPU: {
+			value:    1500,
+			wantQuan: "1500m",
+		},
+		corev1.ResourceMemory: {
+			value:    1024 * 1024,
+			wantQuan: "1Mi",
+		},
+		corev1.ResourceEphemeralStorage: {
+			value:    3 * 1024 * 1024 * 1024,
+			wantQuan: "3Gi",
+		},
+		"hugepages-2Mi": {
+			value:    4 * 1024 * 1024,
+			wantQuan: "4Mi",
+		},
+		"ex.com/gpu": {
+			value:    2,
+			wantQuan: "2",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(string(name), func(t *testing.T) {
+			q := ResourceQuantity(name, tc.value)
+			if got := q.String(); got != tc.wantQuan {
+				t.Errorf("ResourceQuantity(%s, %d)=%s, want %s", name, tc.value, got, tc.wantQuan)
+			}
+			if got := ResourceValue(name, q); got != tc.value {
+				t.Errorf("ResourceValue(%s, %s)=%d, want %d", name, q.String(), got, tc.value)
+			}
+		})
+	}
+}
+
 func TestNewInfo(t *testing.T) {
 	wl := &kueue.Workload{
 		Spec: kueue.WorkloadSpec{
@@ -137,7 +218,25 @@ func TestNewInfo(t *testing.T) {
 					{
 						Name: "driver",
 						Flavors: map[corev1.ResourceName]string{
-							corev1.ResourceCPU: "on-demand",
+							corev1.ResourceCPU:  "on-demand",
+							corev1.ResourcePods: "on-demand",
+						},
+					},
+					{
+						Name: "workers",
+						Splits: []kueue.PodSetSplit{
+							{
+								Count: 2,
+								Flavors: map[corev1.ResourceName]string{
+									"ex.com/gpu": "zone-a",
+								},
+							},
+							{
+								Count: 1,
+								Flavors: map[corev1.ResourceName]string{
+									"ex.com/gpu": "zone-b",
+								},
+							},
 						},
 					},
 				},
@@ -147,22 +246,50 @@ func TestNewInfo(t *testing.T) {
 	info := NewInfo(wl)
 	wantRequests := []PodSetResources{
 		{
-			Name: "driver",
+			Name:  "driver",
+			Count: 1,
 			Requests: Requests{
 				corev1.ResourceCPU:    10,
 				corev1.ResourceMemory: 512 * 1024,
+				corev1.ResourcePods:   1,
 			},
 			Flavors: map[corev1.ResourceName]string{
-				corev1.ResourceCPU: "on-demand",
+				corev1.ResourceCPU:  "on-demand",
+				corev1.ResourcePods: "on-demand",
 			},
 		},
 		{
-			Name: "workers",
+			Name:  "workers",
+			Count: 3,
 			Requests: Requests{
 				corev1.ResourceCPU:    15,
 				corev1.ResourceMemory: 3 * 1024 * 1024,
 				"ex.com/gpu":          3,
 			},
+			Splits: []PodSetSplit{
+				{
+					Count: 2,
+					Requests: Requests{
+						corev1.ResourceCPU:    10,
+						corev1.ResourceMemory: 2 * 1024 * 1024,
+						"ex.com/gpu":          2,
+					},
+					Flavors: map[corev1.ResourceName]string{
+						"ex.com/gpu": "zone-a",
+					},
+				},
+				{
+					Count: 1,
+					Requests: Requests{
+						corev1.ResourceCPU:    5,
+						corev1.ResourceMemory: 1024 * 1024,
+						"ex.com/gpu":          1,
+					},
+					Flavors: map[corev1.ResourceName]string{
+						"ex.com/gpu": "zone-b",
+					},
+				},
+			},
 		},
 	}
 	if diff := cmp.Diff(info.TotalRequests, wantRequests); diff != "" {
@@ -170,6 +297,297 @@ func TestNewInfo(t *testing.T) {
 	}
 }
 
+func TestNewInfoWithExcludedResourcePrefixes(t *testing.T) {
+	wl := &kueue.Workload{
+		Spec: kueue.WorkloadSpec{
+			PodSets: []kueue.PodSet{
+				{
+					Name: "main",
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{
+										corev1.ResourceCPU: resource.MustParse("1"),
+									},
+									Limits: corev1.ResourceList{
+										corev1.ResourceCPU: resource.MustParse("2"),
+										"ex.com/gpu":       resource.MustParse("1"),
+										"ex.com/nic":       resource.MustParse("2"),
+										"other.com/fpga":   resource.MustParse("1"),
+									},
+								},
+							},
+						},
+					},
+					Count: 2,
+				},
+			},
+		},
+	}
+	info := NewInfo(wl, WithExcludedResourcePrefixes([]string{"ex.com/"}))
+	wantRequests := []PodSetResources{
+		{
+			Name:  "main",
+			Count: 2,
+			Requests: Requests{
+				corev1.ResourceCPU: 2000,
+				"other.com/fpga":   2,
+			},
+		},
+	}
+	if diff := cmp.Diff(wantRequests, info.TotalRequests); diff != "" {
+		t.Errorf("NewInfo returned unexpected total requests (-want,+got):\n%s", diff)
+	}
+}
+
+func TestNewInfoWithResourceTransformations(t *testing.T) {
+	mig := []ResourceTransformation{{
+		Input:   "nvidia.com/mig-1g.5gb",
+		Retain:  true,
+		Outputs: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("142857u")},
+	}}
+	cases := map[string]struct {
+		requests        corev1.ResourceList
+		count           int32
+		transformations []ResourceTransformation
+		wantRequests    Requests
+	}{
+		"partitions add up to a whole gpu": {
+			requests:        corev1.ResourceList{"nvidia.com/mig-1g.5gb": resource.MustParse("1")},
+			count:           7,
+			transformations: mig,
+			wantRequests: Requests{
+				"nvidia.com/mig-1g.5gb": 7,
+				"nvidia.com/gpu":        1,
+			},
+		},
+		"partial gpu is rounded up": {
+			requests:        corev1.ResourceList{"nvidia.com/mig-1g.5gb": resource.MustParse("1")},
+			count:           8,
+			transformations: mig,
+			wantRequests: Requests{
+				"nvidia.com/mig-1g.5gb": 8,
+				"nvidia.com/gpu":        2,
+			},
+		},
+		"replace": {
+			requests: corev1.ResourceList{"nvidia.com/mig-1g.5gb": resource.MustParse("2")},
+			count:    3,
+			transformations: []ResourceTransformation{{
+				Input:   "nvidia.com/mig-1g.5gb",
+				Outputs: mig[0].Outputs,
+			}},
+			wantRequests: Requests{
+				"nvidia.com/gpu": 1,
+			},
+		},
+		"outputs add up with the requests": {
+			requests: corev1.ResourceList{
+				corev1.ResourceCPU:      resource.MustParse("1"),
+				"nvidia.com/mig-1g.5gb": resource.MustParse("1"),
+				"nvidia.com/gpu":        resource.MustParse("1"),
+			},
+			count: 2,
+			transformations: []ResourceTransformation{{
+				Input:  "nvidia.com/mig-1g.5gb",
+				Retain: true,
+				Outputs: corev1.ResourceList{
+					"nvidia.com/gpu":   resource.MustParse("142857u"),
+					corev1.ResourceCPU: resource.MustParse("333m"),
+				},
+			}},
+			wantRequests: Requests{
+				corev1.ResourceCPU:      2666,
+				"nvidia.com/mig-1g.5gb": 2,
+				"nvidia.com/gpu":        3,
+			},
+		},
+		"cpu output is rounded up to milli-cpus": {
+			requests: corev1.ResourceList{"example.com/slot": resource.MustParse("1")},
+			count:    3,
+			transformations: []ResourceTransformation{{
+				Input:   "example.com/slot",
+				Retain:  true,
+				Outputs: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("333300u")},
+			}},
+			wantRequests: Requests{
+				corev1.ResourceCPU: 1000,
+				"example.com/slot": 3,
+			},
+		},
+		"cpu input": {
+			requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m")},
+			count:    1,
+			transformations: []ResourceTransformation{{
+				Input:   corev1.ResourceCPU,
+				Outputs: corev1.ResourceList{"example.com/credits": resource.MustParse("2")},
+			}},
+			wantRequests: Requests{
+				"example.com/credits": 3,
+			},
+		},
+		"replace without outputs excludes the resource": {
+			requests: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("1"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+			},
+			count: 2,
+			transformations: []ResourceTransformation{{
+				Input: corev1.ResourceEphemeralStorage,
+			}},
+			wantRequests: Requests{
+				corev1.ResourceCPU: 2000,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			wl := utiltesting.MakeWorkload("wl", "ns").Obj()
+			wl.Spec.PodSets[0].Count = tc.count
+			wl.Spec.PodSets[0].Spec.Containers[0].Resources.Requests = tc.requests
+			info := NewInfo(wl, WithResourceTransformations(tc.transformations))
+			if diff := cmp.Diff(tc.wantRequests, info.TotalRequests[0].Requests); diff != "" {
+				t.Errorf("NewInfo returned unexpected requests (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewInfoPartiallyAdmitted(t *testing.T) {
+	wl := &kueue.Workload{
+		Spec: kueue.WorkloadSpec{
+			PodSets: []kueue.PodSet{
+				{
+					Name: "workers",
+					Spec: corev1.PodSpec{
+						Containers: containersForRequests(
+							map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "1",
+							}),
+					},
+					Count:    5,
+					MinCount: pointer.Int32(2),
+				},
+			},
+			Admission: &kueue.Admission{
+				PodSetFlavors: []kueue.PodSetFlavors{
+					{
+						Name: "workers",
+						Flavors: map[corev1.ResourceName]string{
+							corev1.ResourceCPU: "on-demand",
+						},
+						Count: pointer.Int32(3),
+					},
+				},
+			},
+		},
+	}
+	info := NewInfo(wl)
+	wantRequests := []PodSetResources{
+		{
+			Name:  "workers",
+			Count: 3,
+			Requests: Requests{
+				corev1.ResourceCPU: 3000,
+			},
+			Flavors: map[corev1.ResourceName]string{
+				corev1.ResourceCPU: "on-demand",
+			},
+		},
+	}
+	if diff := cmp.Diff(wantRequests, info.TotalRequests); diff != "" {
+		t.Errorf("NewInfo returned unexpected total requests (-want,+got):\n%s", diff)
+	}
+}
+
+func TestNewInfoWithReclaimablePods(t *testing.T) {
+	wl := &kueue.Workload{
+		Spec: kueue.WorkloadSpec{
+			PodSets: []kueue.PodSet{
+				{
+					Name: "workers",
+					Spec: corev1.PodSpec{
+						Containers: containersForRequests(
+							map[corev1.ResourceName]string{
+								corev1.ResourceCPU: "1",
+							}),
+					},
+					Count: 5,
+				},
+			},
+			Admission: &kueue.Admission{
+				PodSetFlavors: []kueue.PodSetFlavors{
+					{
+						Name: "workers",
+						Flavors: map[corev1.ResourceName]string{
+							corev1.ResourceCPU: "on-demand",
+						},
+						Splits: []kueue.PodSetSplit{
+							{
+								Count: 3,
+								Flavors: map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "on-demand",
+								},
+							},
+							{
+								Count: 2,
+								Flavors: map[corev1.ResourceName]string{
+									corev1.ResourceCPU: "spot",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Status: kueue.WorkloadStatus{
+			ReclaimablePods: []kueue.ReclaimablePod{
+				{
+					Name:  "workers",
+					Count: 3,
+				},
+			},
+		},
+	}
+	info := NewInfo(wl)
+	wantRequests := []PodSetResources{
+		{
+			Name:  "workers",
+			Count: 2,
+			Requests: Requests{
+				corev1.ResourceCPU: 2000,
+			},
+			Flavors: map[corev1.ResourceName]string{
+				corev1.ResourceCPU: "on-demand",
+			},
+			Splits: []PodSetSplit{
+				{
+					Count: 2,
+					Requests: Requests{
+						corev1.ResourceCPU: 2000,
+					},
+					Flavors: map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "on-demand",
+					},
+				},
+				{
+					Count: 0,
+					Requests: Requests{
+						corev1.ResourceCPU: 0,
+					},
+					Flavors: map[corev1.ResourceName]string{
+						corev1.ResourceCPU: "spot",
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantRequests, info.TotalRequests); diff != "" {
+		t.Errorf("NewInfo returned unexpected total requests (-want,+got):\n%s", diff)
+	}
+}
+
 var ignoreConditionTimestamps = cmpopts.IgnoreFields(kueue.WorkloadCondition{}, "LastProbeTime", "LastTransitionTime")
 
 func TestUpdateWorkloadStatus(t *testing.T) {
@@ -271,6 +689,218 @@ func TestUpdateWorkloadStatus(t *testing.T) {
 	}
 }
 
+func TestFinishEviction(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kueue.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add kueue scheme: %v", err)
+	}
+	workload := utiltesting.MakeWorkload("foo", "bar").Obj()
+	workload.Status = kueue.WorkloadStatus{
+		Conditions: []kueue.WorkloadCondition{
+			{
+				Type:               kueue.WorkloadAdmitted,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+			},
+		},
+		AccumulatedPastExecutionTimeSeconds: pointer.Int32(30),
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(workload).Build()
+	ctx := context.Background()
+	if err := Evict(ctx, cl, workload, EvictedByPreemption, "preempted by foo"); err != nil {
+		t.Fatalf("Failed marking the workload for eviction: %v", err)
+	}
+	var updatedWl kueue.Workload
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(workload), &updatedWl); err != nil {
+		t.Fatalf("Failed obtaining updated object: %v", err)
+	}
+	if !InCondition(&updatedWl, kueue.WorkloadEvicted) {
+		t.Fatal("Workload wasn't marked for eviction")
+	}
+	if err := FinishEviction(ctx, cl, &updatedWl); err != nil {
+		t.Fatalf("Failed finishing the eviction: %v", err)
+	}
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(workload), &updatedWl); err != nil {
+		t.Fatalf("Failed obtaining updated object: %v", err)
+	}
+	wantStatus := kueue.WorkloadStatus{
+		Conditions: []kueue.WorkloadCondition{
+			{
+				Type:    kueue.WorkloadAdmitted,
+				Status:  corev1.ConditionFalse,
+				Reason:  "Evicted",
+				Message: "preempted by foo",
+			},
+			{
+				Type:    kueue.WorkloadEvicted,
+				Status:  corev1.ConditionFalse,
+				Reason:  EvictedByPreemption,
+				Message: "preempted by foo",
+			},
+		},
+		// The time of this admission is added to the previous ones.
+		AccumulatedPastExecutionTimeSeconds: pointer.Int32(90),
+	}
+	if diff := cmp.Diff(wantStatus, updatedWl.Status, ignoreConditionTimestamps); diff != "" {
+		t.Errorf("Unexpected status after the eviction (-want,+got):\n%s", diff)
+	}
+}
+
+func TestQueueOrderTimestamp(t *testing.T) {
+	creation := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	eviction := metav1.NewTime(time.Now().Truncate(time.Second))
+	cases := map[string]struct {
+		reason string
+		want   metav1.Time
+	}{
+		"not evicted": {
+			want: creation,
+		},
+		"evicted by preemption": {
+			reason: EvictedByPreemption,
+			want:   creation,
+		},
+		"evicted by PodsReady timeout": {
+			reason: EvictedByPodsReadyTimeout,
+			want:   eviction,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			wl := utiltesting.MakeWorkload("foo", "bar").Obj()
+			wl.CreationTimestamp = creation
+			if tc.reason != "" {
+				wl.Status.Conditions = []kueue.WorkloadCondition{
+					{
+						Type:               kueue.WorkloadEvicted,
+						Status:             corev1.ConditionTrue,
+						LastTransitionTime: eviction,
+						Reason:             tc.reason,
+					},
+				}
+			}
+			if got := QueueOrderTimestamp(wl); !got.Equal(&tc.want) {
+				t.Errorf("QueueOrderTimestamp() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsAdmitted(t *testing.T) {
+	cases := map[string]struct {
+		workload *kueue.Workload
+		want     bool
+	}{
+		"no admission": {
+			workload: utiltesting.MakeWorkload("foo", "bar").Obj(),
+		},
+		"admission without checks": {
+			workload: utiltesting.MakeWorkload("foo", "bar").
+				Admit(utiltesting.MakeAdmission("cq").Obj()).
+				Obj(),
+			want: true,
+		},
+		"checks not recorded": {
+			workload: utiltesting.MakeWorkload("foo", "bar").
+				Admit(utiltesting.MakeAdmission("cq").AdmissionChecks("budget").Obj()).
+				Obj(),
+		},
+		"check pending": {
+			workload: func() *kueue.Workload {
+				wl := utiltesting.MakeWorkload("foo", "bar").
+					Admit(utiltesting.MakeAdmission("cq").AdmissionChecks("budget", "provisioning").Obj()).
+					Obj()
+				wl.Status.AdmissionChecks = []kueue.AdmissionCheckState{
+					{Name: "budget", State: kueue.CheckStateReady},
+					{Name: "provisioning", State: kueue.CheckStatePending},
+				}
+				return wl
+			}(),
+		},
+		"all checks ready": {
+			workload: func() *kueue.Workload {
+				wl := utiltesting.MakeWorkload("foo", "bar").
+					Admit(utiltesting.MakeAdmission("cq").AdmissionChecks("budget", "provisioning").Obj()).
+					Obj()
+				wl.Status.AdmissionChecks = []kueue.AdmissionCheckState{
+					{Name: "provisioning", State: kueue.CheckStateReady},
+					{Name: "budget", State: kueue.CheckStateReady},
+				}
+				return wl
+			}(),
+			want: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsAdmitted(tc.workload); got != tc.want {
+				t.Errorf("IsAdmitted() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+// objectRecorder records the kind and name of the objects of the events.
+type objectRecorder struct {
+	events []string
+}
+
+func (r *objectRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	kind := object.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		kind = "Workload"
+	}
+	r.events = append(r.events, fmt.Sprintf("%s %s: %s %s %s", kind, object.(metav1.Object).GetName(), eventtype, reason, message))
+}
+
+func (r *objectRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *objectRecorder) AnnotatedEventf(object runtime.Object, _ map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+func TestRecordEvent(t *testing.T) {
+	cases := map[string]struct {
+		workload   *kueue.Workload
+		wantEvents []string
+	}{
+		"without owner": {
+			workload: utiltesting.MakeWorkload("foo", "bar").Obj(),
+			wantEvents: []string{
+				"Workload foo: Normal Admitted Admitted by ClusterQueue cq",
+			},
+		},
+		"owned by a job": {
+			workload: func() *kueue.Workload {
+				wl := utiltesting.MakeWorkload("foo", "bar").Obj()
+				wl.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+					Name:       "job",
+					UID:        "job-uid",
+					Controller: pointer.Bool(true),
+				}}
+				return wl
+			}(),
+			wantEvents: []string{
+				"Workload foo: Normal Admitted Admitted by ClusterQueue cq",
+				"Job job: Normal Admitted Admitted by ClusterQueue cq",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			recorder := &objectRecorder{}
+			RecordEvent(recorder, tc.workload, corev1.EventTypeNormal, "Admitted", "Admitted by ClusterQueue cq")
+			if diff := cmp.Diff(tc.wantEvents, recorder.events); diff != "" {
+				t.Errorf("Unexpected events (-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func containersForRequests(requests ...map[corev1.ResourceName]string) []corev1.Container {
 	containers := make([]corev1.Container, len(requests))
 	for i, r := range requests {
@@ -284,3 +914,41 @@ func containersForRequests(requests ...map[corev1.ResourceName]string) []corev1.
 	}
 	return containers
 }
+
+func TestPodSetsHash(t *testing.T) {
+	podSets := func(count int32, cpu string, nodeSelector map[string]string) []kueue.PodSet {
+		spec := utiltesting.PodSpecForRequest(map[corev1.ResourceName]string{
+			corev1.ResourceCPU: cpu,
+		})
+		spec.NodeSelector = nodeSelector
+		return []kueue.PodSet{{Name: "main", Count: count, Spec: spec}}
+	}
+	base := PodSetsHash(podSets(3, "1", nil))
+	cases := map[string]struct {
+		podSets  []kueue.PodSet
+		wantSame bool
+	}{
+		"equal": {
+			podSets:  podSets(3, "1", nil),
+			wantSame: true,
+		},
+		"different node selector": {
+			podSets:  podSets(3, "1", map[string]string{"instance": "spot"}),
+			wantSame: true,
+		},
+		"different count": {
+			podSets: podSets(4, "1", nil),
+		},
+		"different requests": {
+			podSets: podSets(3, "2", nil),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := PodSetsHash(tc.podSets)
+			if (got == base) != tc.wantSame {
+				t.Errorf("PodSetsHash() = %s, base hash %s, want same %t", got, base, tc.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/testing"
+	"sigs.k8s.io/kueue/pkg/workload"
+	"sigs.k8s.io/kueue/test/e2e/framework"
+	integration "sigs.k8s.io/kueue/test/integration/framework"
+)
+
+const sleepImage = "gcr.io/k8s-staging-perf-tests/sleep:v0.0.3"
+
+// +kubebuilder:docs-gen:collapse=Imports
+
+var _ = ginkgo.Describe("Kueue", func() {
+	var (
+		ns           *corev1.Namespace
+		flavor       *kueue.ResourceFlavor
+		clusterQueue *kueue.ClusterQueue
+		queue        *kueue.Queue
+	)
+
+	ginkgo.BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "e2e-",
+			},
+		}
+		gomega.Expect(k8sClient.Create(ctx, ns)).To(gomega.Succeed())
+
+		flavor = testing.MakeResourceFlavor("default").Obj()
+		gomega.Expect(k8sClient.Create(ctx, flavor)).To(gomega.Succeed())
+
+		clusterQueue = testing.MakeClusterQueue("cluster-queue").
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor(flavor.Name, "1").Obj()).Obj()).
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, clusterQueue)).To(gomega.Succeed())
+
+		queue = testing.MakeQueue("main", ns.Name).ClusterQueue(clusterQueue.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, queue)).To(gomega.Succeed())
+	})
+
+	ginkgo.AfterEach(func() {
+		gomega.Expect(integration.DeleteNamespace(ctx, k8sClient, ns)).To(gomega.Succeed())
+		gomega.Expect(integration.DeleteClusterQueue(ctx, k8sClient, clusterQueue)).To(gomega.Succeed())
+		gomega.Expect(integration.DeleteResourceFlavor(ctx, k8sClient, flavor)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("Should unsuspend a job and let it run to completion", func() {
+		job := testing.MakeJob("test-job", ns.Name).
+			Queue(queue.Name).
+			Image(sleepImage, []string{"1ms"}).
+			Request(corev1.ResourceCPU, "200m").
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, job)).To(gomega.Succeed())
+
+		ginkgo.By("checking the job gets unsuspended and completes")
+		gomega.Eventually(func() bool {
+			var updatedJob batchv1.Job
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(job), &updatedJob); err != nil {
+				return false
+			}
+			return !pointer.BoolDeref(updatedJob.Spec.Suspend, false) && updatedJob.Status.Succeeded == 1
+		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+
+		ginkgo.By("checking the workload is finished")
+		gomega.Eventually(func() bool {
+			var wl kueue.Workload
+			key := client.ObjectKey{Namespace: ns.Name, Name: job.Name}
+			if err := k8sClient.Get(ctx, key, &wl); err != nil {
+				return false
+			}
+			return workload.InCondition(&wl, kueue.WorkloadFinished)
+		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+	})
+
+	ginkgo.It("Should keep a job suspended while it doesn't fit in the quota", func() {
+		job := testing.MakeJob("big-job", ns.Name).
+			Queue(queue.Name).
+			Image(sleepImage, []string{"1ms"}).
+			Request(corev1.ResourceCPU, "2").
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, job)).To(gomega.Succeed())
+
+		ginkgo.By("checking the job stays suspended and creates no pods")
+		gomega.Consistently(func() bool {
+			var updatedJob batchv1.Job
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(job), &updatedJob)).To(gomega.Succeed())
+			return pointer.BoolDeref(updatedJob.Spec.Suspend, false) && updatedJob.Status.Active == 0
+		}, integration.ConsistentDuration, framework.Interval).Should(gomega.BeTrue())
+	})
+})
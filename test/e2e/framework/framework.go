@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+)
+
+const (
+	// Timeout is longer than the one used in integration tests, because pods
+	// have to be scheduled and run in the nodes.
+	Timeout  = time.Minute
+	Interval = time.Second
+
+	// ClusterNameEnv holds the name of the kind cluster the tests run against.
+	// It is set by hack/e2e-test.sh after deploying kueue in the cluster.
+	ClusterNameEnv = "E2E_KIND_CLUSTER_NAME"
+
+	kueueNamespace  = "kueue-system"
+	kueueDeployment = "kueue-controller-manager"
+)
+
+// ClusterName returns the name of the kind cluster to run the tests against,
+// or an empty string if no cluster was set up.
+func ClusterName() string {
+	return os.Getenv(ClusterNameEnv)
+}
+
+// CreateClientUsingCluster returns a client for the cluster in the current
+// kubeconfig, with the kueue types registered.
+func CreateClientUsingCluster() client.Client {
+	cfg, err := ctrl.GetConfig()
+	gomega.ExpectWithOffset(1, err).NotTo(gomega.HaveOccurred())
+	gomega.ExpectWithOffset(1, cfg).NotTo(gomega.BeNil())
+
+	err = kueue.AddToScheme(scheme.Scheme)
+	gomega.ExpectWithOffset(1, err).NotTo(gomega.HaveOccurred())
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	gomega.ExpectWithOffset(1, err).NotTo(gomega.HaveOccurred())
+	return c
+}
+
+// KueueReadyForTesting waits for the kueue manager to have all its replicas
+// available, which implies that the webhooks are serving.
+func KueueReadyForTesting(ctx context.Context, c client.Client) {
+	key := types.NamespacedName{Namespace: kueueNamespace, Name: kueueDeployment}
+	gomega.EventuallyWithOffset(1, func() bool {
+		var deployment appsv1.Deployment
+		if err := c.Get(ctx, key, &deployment); err != nil {
+			return false
+		}
+		return deployment.Status.AvailableReplicas > 0 &&
+			deployment.Status.AvailableReplicas == deployment.Status.Replicas
+	}, 3*Timeout, Interval).Should(gomega.BeTrue(), "kueue manager is not available")
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/kueue/test/e2e/framework"
+)
+
+var (
+	k8sClient client.Client
+	ctx       context.Context
+)
+
+func TestAPIs(t *testing.T) {
+	if framework.ClusterName() == "" {
+		t.Skipf("%s is not set, run the e2e tests with hack/e2e-test.sh", framework.ClusterNameEnv)
+	}
+	gomega.RegisterFailHandler(ginkgo.Fail)
+
+	ginkgo.RunSpecs(t,
+		"End To End Suite",
+	)
+}
+
+var _ = ginkgo.BeforeSuite(func() {
+	ctx = context.Background()
+	k8sClient = framework.CreateClientUsingCluster()
+	framework.KueueReadyForTesting(ctx, k8sClient)
+})
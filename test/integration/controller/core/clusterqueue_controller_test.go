@@ -17,6 +17,7 @@ limitations under the License.
 package core
 
 import (
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
@@ -56,6 +57,9 @@ var _ = ginkgo.Describe("ClusterQueue controller", func() {
 				flavorModelB: {Total: pointer.Quantity(resource.MustParse("0"))},
 			},
 		}
+		ignoreConditions             = cmpopts.IgnoreFields(kueue.ClusterQueueStatus{}, "Conditions")
+		ignorePendingWorkloadsStatus = cmpopts.IgnoreFields(kueue.ClusterQueueStatus{}, "PendingWorkloadsStatus")
+		ignoreConditionTimestamps    = cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime", "ObservedGeneration")
 	)
 
 	ginkgo.BeforeEach(func() {
@@ -74,11 +78,11 @@ var _ = ginkgo.Describe("ClusterQueue controller", func() {
 	ginkgo.BeforeEach(func() {
 		clusterQueue = testing.MakeClusterQueue("cluster-queue").
 			Resource(testing.MakeResource(corev1.ResourceCPU).
-				Flavor(testing.MakeFlavor(flavorOnDemand, "5").Max("10").Obj()).
-				Flavor(testing.MakeFlavor(flavorSpot, "5").Max("10").Obj()).Obj()).
+				Flavor(testing.MakeFlavor(flavorOnDemand, "5").BorrowingLimit("5").Obj()).
+				Flavor(testing.MakeFlavor(flavorSpot, "5").BorrowingLimit("5").Obj()).Obj()).
 			Resource(testing.MakeResource(resourceGPU).
-				Flavor(testing.MakeFlavor(flavorModelA, "5").Max("10").Obj()).
-				Flavor(testing.MakeFlavor(flavorModelB, "5").Max("10").Obj()).Obj()).Obj()
+				Flavor(testing.MakeFlavor(flavorModelA, "5").BorrowingLimit("5").Obj()).
+				Flavor(testing.MakeFlavor(flavorModelB, "5").BorrowingLimit("5").Obj()).Obj()).Obj()
 		gomega.Expect(k8sClient.Create(ctx, clusterQueue)).To(gomega.Succeed())
 		queue = testing.MakeQueue("queue", ns.Name).ClusterQueue(clusterQueue.Name).Obj()
 		gomega.Expect(k8sClient.Create(ctx, queue)).To(gomega.Succeed())
@@ -115,7 +119,7 @@ var _ = ginkgo.Describe("ClusterQueue controller", func() {
 		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.ClusterQueueStatus{
 			PendingWorkloads: 5,
 			UsedResources:    emptyUsedResources,
-		}))
+		}, ignoreConditions, ignorePendingWorkloadsStatus))
 
 		ginkgo.By("Admitting workloads")
 		admissions := []*kueue.Admission{
@@ -144,8 +148,9 @@ var _ = ginkgo.Describe("ClusterQueue controller", func() {
 			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueue), &updatedCQ)).To(gomega.Succeed())
 			return updatedCQ.Status
 		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.ClusterQueueStatus{
-			PendingWorkloads:  1,
-			AdmittedWorkloads: 4,
+			PendingWorkloads:   1,
+			ReservingWorkloads: 4,
+			AdmittedWorkloads:  4,
 			UsedResources: kueue.UsedResources{
 				corev1.ResourceCPU: {
 					flavorOnDemand: {
@@ -165,7 +170,7 @@ var _ = ginkgo.Describe("ClusterQueue controller", func() {
 					},
 				},
 			},
-		}))
+		}, ignoreConditions, ignorePendingWorkloadsStatus))
 
 		ginkgo.By("Finishing workloads")
 		for _, w := range workloads {
@@ -185,6 +190,100 @@ var _ = ginkgo.Describe("ClusterQueue controller", func() {
 			return updatedCq.Status
 		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.ClusterQueueStatus{
 			UsedResources: emptyUsedResources,
+		}, ignoreConditions, ignorePendingWorkloadsStatus))
+	})
+
+	ginkgo.It("Should be active only while all its flavors exist", func() {
+		ginkgo.By("Checking the ClusterQueue is inactive without the flavors")
+		gomega.Eventually(func() []metav1.Condition {
+			var updatedCq kueue.ClusterQueue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueue), &updatedCq)).To(gomega.Succeed())
+			return updatedCq.Status.Conditions
+		}, framework.Timeout, framework.Interval).Should(testing.Equal([]metav1.Condition{
+			{
+				Type:    kueue.ClusterQueueActive,
+				Status:  metav1.ConditionFalse,
+				Reason:  "FlavorNotFound",
+				Message: "Can't admit new workloads: ResourceFlavors on-demand, spot, model-a, model-b not found",
+			},
+		}, ignoreConditionTimestamps))
+
+		ginkgo.By("Creating the flavors")
+		flavors := []*kueue.ResourceFlavor{
+			testing.MakeResourceFlavor(flavorOnDemand).Obj(),
+			testing.MakeResourceFlavor(flavorSpot).Obj(),
+			testing.MakeResourceFlavor(flavorModelA).Obj(),
+			testing.MakeResourceFlavor(flavorModelB).Obj(),
+		}
+		for _, rf := range flavors {
+			gomega.Expect(k8sClient.Create(ctx, rf)).To(gomega.Succeed())
+		}
+		defer func() {
+			for _, rf := range flavors {
+				gomega.Expect(framework.DeleteResourceFlavor(ctx, k8sClient, rf)).To(gomega.Succeed())
+			}
+		}()
+		gomega.Eventually(func() []metav1.Condition {
+			var updatedCq kueue.ClusterQueue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueue), &updatedCq)).To(gomega.Succeed())
+			return updatedCq.Status.Conditions
+		}, framework.Timeout, framework.Interval).Should(testing.Equal([]metav1.Condition{
+			{
+				Type:    kueue.ClusterQueueActive,
+				Status:  metav1.ConditionTrue,
+				Reason:  "Ready",
+				Message: "Can admit new workloads",
+			},
+		}, ignoreConditionTimestamps))
+	})
+
+	ginkgo.It("Should list the first pending workloads in the status", func() {
+		workloads := []*kueue.Workload{
+			testing.MakeWorkload("low", ns.Name).Queue(queue.Name).Priority(1).
+				Request(corev1.ResourceCPU, "1").Obj(),
+			testing.MakeWorkload("high", ns.Name).Queue(queue.Name).Priority(3).
+				Request(corev1.ResourceCPU, "1").Obj(),
+			testing.MakeWorkload("mid", ns.Name).Queue(queue.Name).Priority(2).
+				Request(corev1.ResourceCPU, "1").Obj(),
+		}
+
+		ginkgo.By("Creating workloads")
+		for _, w := range workloads {
+			gomega.Expect(k8sClient.Create(ctx, w)).To(gomega.Succeed())
+		}
+		wantHead := []kueue.PendingWorkload{
+			{Name: "high", Namespace: ns.Name, Position: 0, Priority: 3},
+			{Name: "mid", Namespace: ns.Name, Position: 1, Priority: 2},
+		}
+		gomega.Eventually(func() []kueue.PendingWorkload {
+			var updatedCq kueue.ClusterQueue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueue), &updatedCq)).To(gomega.Succeed())
+			if updatedCq.Status.PendingWorkloadsStatus == nil {
+				return nil
+			}
+			return updatedCq.Status.PendingWorkloadsStatus.Head
+		}, framework.Timeout, framework.Interval).Should(testing.Equal(wantHead))
+		gomega.Eventually(func() []kueue.PendingWorkload {
+			var updatedQueue kueue.Queue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(queue), &updatedQueue)).To(gomega.Succeed())
+			if updatedQueue.Status.PendingWorkloadsStatus == nil {
+				return nil
+			}
+			return updatedQueue.Status.PendingWorkloadsStatus.Head
+		}, framework.Timeout, framework.Interval).Should(testing.Equal(wantHead))
+
+		ginkgo.By("Deleting the workload at the head")
+		gomega.Expect(k8sClient.Delete(ctx, workloads[1])).To(gomega.Succeed())
+		gomega.Eventually(func() []kueue.PendingWorkload {
+			var updatedCq kueue.ClusterQueue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueue), &updatedCq)).To(gomega.Succeed())
+			if updatedCq.Status.PendingWorkloadsStatus == nil {
+				return nil
+			}
+			return updatedCq.Status.PendingWorkloadsStatus.Head
+		}, framework.Timeout, framework.Interval).Should(testing.Equal([]kueue.PendingWorkload{
+			{Name: "mid", Namespace: ns.Name, Position: 0, Priority: 2},
+			{Name: "low", Namespace: ns.Name, Position: 1, Priority: 1},
 		}))
 	})
 })
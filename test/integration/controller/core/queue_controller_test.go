@@ -17,13 +17,17 @@ limitations under the License.
 package core
 
 import (
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/util/pointer"
 	"sigs.k8s.io/kueue/pkg/util/testing"
 	"sigs.k8s.io/kueue/test/integration/framework"
 )
@@ -32,9 +36,10 @@ import (
 
 var _ = ginkgo.Describe("Queue controller", func() {
 	var (
-		ns           *corev1.Namespace
-		queue        *kueue.Queue
-		clusterQueue *kueue.ClusterQueue
+		ns                           *corev1.Namespace
+		queue                        *kueue.Queue
+		clusterQueue                 *kueue.ClusterQueue
+		ignorePendingWorkloadsStatus = cmpopts.IgnoreFields(kueue.QueueStatus{}, "PendingWorkloadsStatus")
 	)
 
 	ginkgo.BeforeEach(func() {
@@ -49,8 +54,8 @@ var _ = ginkgo.Describe("Queue controller", func() {
 	ginkgo.BeforeEach(func() {
 		clusterQueue = testing.MakeClusterQueue("clusterQueue_queue-controller").
 			Resource(testing.MakeResource(resourceGPU).
-				Flavor(testing.MakeFlavor(flavorModelA, "5").Max("10").Obj()).
-				Flavor(testing.MakeFlavor(flavorModelB, "5").Max("10").Obj()).Obj()).Obj()
+				Flavor(testing.MakeFlavor(flavorModelA, "5").BorrowingLimit("5").Obj()).
+				Flavor(testing.MakeFlavor(flavorModelB, "5").BorrowingLimit("5").Obj()).Obj()).Obj()
 		queue = testing.MakeQueue("queue", ns.Name).ClusterQueue(clusterQueue.Name).Obj()
 		gomega.Expect(k8sClient.Create(ctx, queue)).To(gomega.Succeed())
 	})
@@ -81,7 +86,7 @@ var _ = ginkgo.Describe("Queue controller", func() {
 			var updatedQueue kueue.Queue
 			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(queue), &updatedQueue)).To(gomega.Succeed())
 			return updatedQueue.Status
-		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{PendingWorkloads: 3}))
+		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{PendingWorkloads: 3}, ignorePendingWorkloadsStatus))
 
 		ginkgo.By("Admitting workloads")
 		for _, w := range workloads {
@@ -97,7 +102,7 @@ var _ = ginkgo.Describe("Queue controller", func() {
 			var updatedQueue kueue.Queue
 			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(queue), &updatedQueue)).To(gomega.Succeed())
 			return updatedQueue.Status
-		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{PendingWorkloads: 0}))
+		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{PendingWorkloads: 0}, ignorePendingWorkloadsStatus))
 
 		ginkgo.By("Finishing workloads")
 		for _, w := range workloads {
@@ -115,6 +120,90 @@ var _ = ginkgo.Describe("Queue controller", func() {
 			var updatedQueue kueue.Queue
 			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(queue), &updatedQueue)).To(gomega.Succeed())
 			return updatedQueue.Status
-		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{}))
+		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{}, ignorePendingWorkloadsStatus))
+	})
+
+	ginkgo.It("Should requeue admitted workloads when requested", func() {
+		wl := testing.MakeWorkload("one", ns.Name).
+			Queue(queue.Name).
+			Request(corev1.ResourceCPU, "2").Obj()
+		gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+
+		ginkgo.By("Admitting the workload")
+		gomega.Eventually(func() error {
+			var newWL kueue.Workload
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &newWL)).To(gomega.Succeed())
+			newWL.Spec.Admission = testing.MakeAdmission(clusterQueue.Name).
+				Flavor(corev1.ResourceCPU, flavorOnDemand).Obj()
+			return k8sClient.Update(ctx, &newWL)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+
+		ginkgo.By("Requesting the requeue of the admitted workloads")
+		gomega.Eventually(func() error {
+			var updatedQueue kueue.Queue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(queue), &updatedQueue)).To(gomega.Succeed())
+			if updatedQueue.Annotations == nil {
+				updatedQueue.Annotations = map[string]string{}
+			}
+			updatedQueue.Annotations[constants.RequeueAdmittedWorkloadsAnnotation] = "true"
+			return k8sClient.Update(ctx, &updatedQueue)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+
+		gomega.Eventually(func() *kueue.Admission {
+			var newWL kueue.Workload
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &newWL)).To(gomega.Succeed())
+			return newWL.Spec.Admission
+		}, framework.Timeout, framework.Interval).Should(gomega.BeNil())
+		gomega.Eventually(func() map[string]string {
+			var updatedQueue kueue.Queue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(queue), &updatedQueue)).To(gomega.Succeed())
+			return updatedQueue.Annotations
+		}, framework.Timeout, framework.Interval).ShouldNot(gomega.HaveKey(constants.RequeueAdmittedWorkloadsAnnotation))
+		gomega.Eventually(func() kueue.QueueStatus {
+			var updatedQueue kueue.Queue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(queue), &updatedQueue)).To(gomega.Succeed())
+			return updatedQueue.Status
+		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{PendingWorkloads: 1}, ignorePendingWorkloadsStatus))
+	})
+
+	ginkgo.It("Should report the usage of the admitted workloads and the usable flavors", func() {
+		onDemandFlavor := testing.MakeResourceFlavor(flavorOnDemand).Obj()
+		gomega.Expect(k8sClient.Create(ctx, onDemandFlavor)).To(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteResourceFlavor(ctx, k8sClient, onDemandFlavor)).To(gomega.Succeed())
+		}()
+		cpuClusterQueue := testing.MakeClusterQueue("cpu-cluster-queue").
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor(flavorOnDemand, "5").Obj()).Obj()).Obj()
+		gomega.Expect(k8sClient.Create(ctx, cpuClusterQueue)).To(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, cpuClusterQueue)).To(gomega.Succeed())
+		}()
+		cpuQueue := testing.MakeQueue("cpu-queue", ns.Name).ClusterQueue(cpuClusterQueue.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, cpuQueue)).To(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteQueue(ctx, k8sClient, cpuQueue)).To(gomega.Succeed())
+		}()
+
+		ginkgo.By("Admitting a workload")
+		wl := testing.MakeWorkload("one", ns.Name).
+			Queue(cpuQueue.Name).
+			Request(corev1.ResourceCPU, "2").
+			Admit(testing.MakeAdmission(cpuClusterQueue.Name).Flavor(corev1.ResourceCPU, flavorOnDemand).Obj()).Obj()
+		gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+		gomega.Eventually(func() kueue.QueueStatus {
+			var updatedQueue kueue.Queue
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cpuQueue), &updatedQueue)).To(gomega.Succeed())
+			return updatedQueue.Status
+		}, framework.Timeout, framework.Interval).Should(testing.Equal(kueue.QueueStatus{
+			ReservingWorkloads: 1,
+			AdmittedWorkloads:  1,
+			UsedResources: kueue.UsedResources{
+				corev1.ResourceCPU: {
+					flavorOnDemand: kueue.Usage{Total: pointer.Quantity(resource.MustParse("2"))},
+				},
+			},
+			Flavors: []string{flavorOnDemand},
+		}, ignorePendingWorkloadsStatus))
 	})
 })
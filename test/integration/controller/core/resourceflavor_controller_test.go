@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/constants"
+	"sigs.k8s.io/kueue/pkg/util/testing"
+	"sigs.k8s.io/kueue/test/integration/framework"
+)
+
+// +kubebuilder:docs-gen:collapse=Imports
+
+var _ = ginkgo.Describe("ResourceFlavor controller", func() {
+	var (
+		flavor        *kueue.ResourceFlavor
+		clusterQueues []*kueue.ClusterQueue
+	)
+
+	ginkgo.BeforeEach(func() {
+		flavor = testing.MakeResourceFlavor("in-use").Obj()
+		gomega.Expect(k8sClient.Create(ctx, flavor)).To(gomega.Succeed())
+		clusterQueues = nil
+		for _, name := range []string{"cq-a", "cq-b"} {
+			cq := testing.MakeClusterQueue(name).
+				Resource(testing.MakeResource(corev1.ResourceCPU).
+					Flavor(testing.MakeFlavor(flavor.Name, "5").Obj()).Obj()).Obj()
+			gomega.Expect(k8sClient.Create(ctx, cq)).To(gomega.Succeed())
+			clusterQueues = append(clusterQueues, cq)
+		}
+	})
+
+	ginkgo.AfterEach(func() {
+		for _, cq := range clusterQueues {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, cq)).To(gomega.Succeed())
+		}
+		framework.ExpectResourceFlavorToBeDeleted(ctx, k8sClient, flavor)
+	})
+
+	ginkgo.It("Should only remove a ResourceFlavor once no ClusterQueue references it", func() {
+		ginkgo.By("Checking the finalizer is added")
+		gomega.Eventually(func() []string {
+			var updated kueue.ResourceFlavor
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(flavor), &updated)).To(gomega.Succeed())
+			return updated.Finalizers
+		}, framework.Timeout, framework.Interval).Should(gomega.ContainElement(constants.ResourceInUseFinalizerName))
+
+		ginkgo.By("Deleting the ResourceFlavor")
+		gomega.Expect(k8sClient.Delete(ctx, flavor)).To(gomega.Succeed())
+		gomega.Consistently(func() error {
+			var updated kueue.ResourceFlavor
+			return k8sClient.Get(ctx, client.ObjectKeyFromObject(flavor), &updated)
+		}, framework.ConsistentDuration, framework.Interval).Should(gomega.Succeed())
+
+		ginkgo.By("Removing the ResourceFlavor from a ClusterQueue")
+		gomega.Eventually(func() error {
+			var updated kueue.ClusterQueue
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueues[0]), &updated); err != nil {
+				return err
+			}
+			updated.Spec.ResourceGroups[0].Flavors[0].Name = "other"
+			return k8sClient.Update(ctx, &updated)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		gomega.Consistently(func() error {
+			var updated kueue.ResourceFlavor
+			return k8sClient.Get(ctx, client.ObjectKeyFromObject(flavor), &updated)
+		}, framework.ConsistentDuration, framework.Interval).Should(gomega.Succeed())
+
+		ginkgo.By("Deleting the last ClusterQueue that references the ResourceFlavor")
+		gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, clusterQueues[1])).To(gomega.Succeed())
+		gomega.Eventually(func() bool {
+			var updated kueue.ResourceFlavor
+			return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(flavor), &updated))
+		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+	})
+})
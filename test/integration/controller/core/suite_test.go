@@ -20,6 +20,7 @@ import (
 	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
@@ -39,6 +40,8 @@ var (
 	k8sClient client.Client
 	ctx       context.Context
 	fwk       *framework.Framework
+	queues    *queue.Manager
+	cCache    *cache.Cache
 )
 
 func TestAPIs(t *testing.T) {
@@ -57,6 +60,10 @@ var _ = ginkgo.BeforeSuite(func() {
 	ctx, cfg, k8sClient = fwk.Setup()
 })
 
+var _ = ginkgo.JustAfterEach(func() {
+	framework.DumpStateOnFailure(ctx, k8sClient, queues, cCache)
+})
+
 var _ = ginkgo.AfterSuite(func() {
 	fwk.Teardown()
 })
@@ -68,9 +75,11 @@ func managerSetup(mgr manager.Manager, ctx context.Context) {
 	err = cache.SetupIndexes(mgr.GetFieldIndexer())
 	gomega.Expect(err).NotTo(gomega.HaveOccurred())
 
-	queues := queue.NewManager(mgr.GetClient())
-	cCache := cache.New(mgr.GetClient())
+	queues = queue.NewManager(mgr.GetClient())
+	cCache = cache.New(mgr.GetClient())
 
-	failedCtrl, err := core.SetupControllers(mgr, queues, cCache)
+	finishedTTL := time.Hour
+	failedCtrl, err := core.SetupControllers(mgr, queues, cCache, core.WithQueueVisibility(2, 0),
+		core.WithFinishedRetention(core.FinishedRetention{TTL: &finishedTTL, DeleteOwners: true}))
 	gomega.Expect(err).ToNot(gomega.HaveOccurred(), "controller", failedCtrl)
 }
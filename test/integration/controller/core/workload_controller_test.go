@@ -18,12 +18,15 @@ package core
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/util/testing"
@@ -112,7 +115,7 @@ var _ = ginkgo.Describe("Workload controller", func() {
 		ginkgo.BeforeEach(func() {
 			clusterQueue = testing.MakeClusterQueue("cluster-queue").
 				Resource(testing.MakeResource(resourceGPU).
-					Flavor(testing.MakeFlavor(flavorOnDemand, "5").Max("10").Obj()).Obj()).
+					Flavor(testing.MakeFlavor(flavorOnDemand, "5").BorrowingLimit("5").Obj()).Obj()).
 				Obj()
 			gomega.Expect(k8sClient.Create(ctx, clusterQueue)).To(gomega.Succeed())
 
@@ -141,5 +144,187 @@ var _ = ginkgo.Describe("Workload controller", func() {
 				return workload.InCondition(&updatedQueueWorkload, kueue.WorkloadAdmitted)
 			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
 		})
+
+		ginkgo.It("Should clear the admission when the workload is evicted", func() {
+			ginkgo.By("Create and admit workload")
+			wl = testing.MakeWorkload("one", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "1").Obj()
+			wl.Spec.Admission = testing.MakeAdmission(clusterQueue.Name).
+				Flavor(corev1.ResourceCPU, flavorOnDemand).Obj()
+			gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+			gomega.Eventually(func() bool {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return workload.InCondition(&updatedQueueWorkload, kueue.WorkloadAdmitted)
+			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+
+			ginkgo.By("Evict workload")
+			gomega.Expect(workload.Evict(ctx, k8sClient, &updatedQueueWorkload, "ByTest", "evicted by test")).To(gomega.Succeed())
+			gomega.Eventually(func() *kueue.Admission {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return updatedQueueWorkload.Spec.Admission
+			}, framework.Timeout, framework.Interval).Should(gomega.BeNil())
+			gomega.Eventually(func() bool {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return workload.InCondition(&updatedQueueWorkload, kueue.WorkloadEvicted)
+			}, framework.Timeout, framework.Interval).Should(gomega.BeFalse())
+			i := workload.FindConditionIndex(&updatedQueueWorkload.Status, kueue.WorkloadAdmitted)
+			gomega.Expect(updatedQueueWorkload.Status.Conditions[i].Reason).To(gomega.Equal("Evicted"))
+			gomega.Expect(updatedQueueWorkload.Status.Conditions[i].Message).To(gomega.Equal("evicted by test"))
+		})
+
+		ginkgo.It("Should evict the workload when it's deactivated", func() {
+			ginkgo.By("Create and admit workload")
+			wl = testing.MakeWorkload("one", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "1").Obj()
+			wl.Spec.Admission = testing.MakeAdmission(clusterQueue.Name).
+				Flavor(corev1.ResourceCPU, flavorOnDemand).Obj()
+			gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+			gomega.Eventually(func() bool {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return workload.InCondition(&updatedQueueWorkload, kueue.WorkloadAdmitted)
+			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+
+			ginkgo.By("Deactivate workload")
+			updatedQueueWorkload.Spec.Active = pointer.Bool(false)
+			gomega.Expect(k8sClient.Update(ctx, &updatedQueueWorkload)).To(gomega.Succeed())
+			gomega.Eventually(func() *kueue.Admission {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return updatedQueueWorkload.Spec.Admission
+			}, framework.Timeout, framework.Interval).Should(gomega.BeNil())
+			i := workload.FindConditionIndex(&updatedQueueWorkload.Status, kueue.WorkloadEvicted)
+			gomega.Expect(i).NotTo(gomega.Equal(-1))
+			gomega.Expect(updatedQueueWorkload.Status.Conditions[i].Reason).To(gomega.Equal(workload.EvictedByDeactivation))
+			gomega.Eventually(func() string {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				i := workload.FindConditionIndex(&updatedQueueWorkload.Status, kueue.WorkloadAdmitted)
+				return updatedQueueWorkload.Status.Conditions[i].Reason
+			}, framework.Timeout, framework.Interval).Should(gomega.Equal("Inactive"))
+		})
+
+		ginkgo.It("Should evict and deactivate the workload when it exceeds its maximum execution time", func() {
+			ginkgo.By("Create and admit workload")
+			wl = testing.MakeWorkload("one", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "1").
+				MaximumExecutionTimeSeconds(1).Obj()
+			wl.Spec.Admission = testing.MakeAdmission(clusterQueue.Name).
+				Flavor(corev1.ResourceCPU, flavorOnDemand).Obj()
+			gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+
+			ginkgo.By("Wait for the eviction")
+			gomega.Eventually(func() *kueue.Admission {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return updatedQueueWorkload.Spec.Admission
+			}, framework.Timeout, framework.Interval).Should(gomega.BeNil())
+			gomega.Expect(workload.IsActive(&updatedQueueWorkload)).To(gomega.BeFalse())
+			i := workload.FindConditionIndex(&updatedQueueWorkload.Status, kueue.WorkloadEvicted)
+			gomega.Expect(i).NotTo(gomega.Equal(-1))
+			gomega.Expect(updatedQueueWorkload.Status.Conditions[i].Reason).To(gomega.Equal(workload.EvictedByDeadlineExceeded))
+			gomega.Eventually(func() string {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				i := workload.FindConditionIndex(&updatedQueueWorkload.Status, kueue.WorkloadAdmitted)
+				return updatedQueueWorkload.Status.Conditions[i].Reason
+			}, framework.Timeout, framework.Interval).Should(gomega.Equal("Inactive"))
+		})
+
+		ginkgo.It("Should delete the admitted workload when its owner no longer exists", func() {
+			ginkgo.By("Create and delete a job; without a garbage collector its dependents are orphaned")
+			job := testing.MakeJob("job", ns.Name).Queue(queue.Name).Obj()
+			gomega.Expect(k8sClient.Create(ctx, job)).To(gomega.Succeed())
+			gomega.Expect(k8sClient.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))).To(gomega.Succeed())
+
+			ginkgo.By("Create an admitted workload owned by the deleted job")
+			wl = testing.MakeWorkload("one", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "1").Obj()
+			gomega.Expect(ctrl.SetControllerReference(job, wl, k8sClient.Scheme())).To(gomega.Succeed())
+			wl.Spec.Admission = testing.MakeAdmission(clusterQueue.Name).
+				Flavor(corev1.ResourceCPU, flavorOnDemand).Obj()
+			gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+			gomega.Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload))
+			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+		})
+
+		ginkgo.It("Should wait for the admission checks before admitting the workload", func() {
+			ginkgo.By("Create workload with a quota reservation")
+			wl = testing.MakeWorkload("one", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "1").Obj()
+			wl.Spec.Admission = testing.MakeAdmission(clusterQueue.Name).
+				Flavor(corev1.ResourceCPU, flavorOnDemand).
+				AdmissionChecks("budget").Obj()
+			gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+			gomega.Eventually(func() []kueue.AdmissionCheckState {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return updatedQueueWorkload.Status.AdmissionChecks
+			}, framework.Timeout, framework.Interval).Should(gomega.HaveLen(1))
+			gomega.Expect(updatedQueueWorkload.Status.AdmissionChecks[0].State).To(gomega.Equal(kueue.CheckStatePending))
+			gomega.Expect(workload.InCondition(&updatedQueueWorkload, kueue.WorkloadQuotaReserved)).To(gomega.BeTrue())
+			gomega.Consistently(func() bool {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return workload.InCondition(&updatedQueueWorkload, kueue.WorkloadAdmitted)
+			}, framework.ConsistentDuration, framework.Interval).Should(gomega.BeFalse())
+			gomega.Eventually(func() []int32 {
+				var updatedCq kueue.ClusterQueue
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueue), &updatedCq)).To(gomega.Succeed())
+				return []int32{updatedCq.Status.ReservingWorkloads, updatedCq.Status.AdmittedWorkloads}
+			}, framework.Timeout, framework.Interval).Should(gomega.Equal([]int32{1, 0}))
+
+			ginkgo.By("Mark the check as ready")
+			updatedQueueWorkload.Status.AdmissionChecks[0].State = kueue.CheckStateReady
+			gomega.Expect(k8sClient.Status().Update(ctx, &updatedQueueWorkload)).To(gomega.Succeed())
+			gomega.Eventually(func() bool {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return workload.InCondition(&updatedQueueWorkload, kueue.WorkloadAdmitted)
+			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+			gomega.Eventually(func() []int32 {
+				var updatedCq kueue.ClusterQueue
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(clusterQueue), &updatedCq)).To(gomega.Succeed())
+				return []int32{updatedCq.Status.ReservingWorkloads, updatedCq.Status.AdmittedWorkloads}
+			}, framework.Timeout, framework.Interval).Should(gomega.Equal([]int32{1, 1}))
+		})
+
+		ginkgo.It("Should deactivate the workload when an admission check rejects it", func() {
+			ginkgo.By("Create workload with a quota reservation")
+			wl = testing.MakeWorkload("one", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "1").Obj()
+			wl.Spec.Admission = testing.MakeAdmission(clusterQueue.Name).
+				Flavor(corev1.ResourceCPU, flavorOnDemand).
+				AdmissionChecks("budget").Obj()
+			gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+			gomega.Eventually(func() bool {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return workload.ConditionMatches(&updatedQueueWorkload, kueue.WorkloadAdmissionChecksReady, corev1.ConditionFalse,
+					"Pending", "AdmissionChecks not Ready: budget is Pending: AdmissionCheck not found")
+			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+
+			ginkgo.By("Reject the workload")
+			updatedQueueWorkload.Status.AdmissionChecks[0].State = kueue.CheckStateRejected
+			updatedQueueWorkload.Status.AdmissionChecks[0].Message = "Over budget"
+			gomega.Expect(k8sClient.Status().Update(ctx, &updatedQueueWorkload)).To(gomega.Succeed())
+			gomega.Eventually(func() bool {
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+				return workload.IsActive(&updatedQueueWorkload) || workload.HasQuotaReservation(&updatedQueueWorkload)
+			}, framework.Timeout, framework.Interval).Should(gomega.BeFalse())
+			gomega.Expect(workload.ConditionMatches(&updatedQueueWorkload, kueue.WorkloadAdmissionChecksReady, corev1.ConditionFalse,
+				"Rejected", "AdmissionChecks not Ready: budget is Rejected: Over budget")).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("Should delete the finished workload and its owner once the TTL expires", func() {
+			ginkgo.By("Create a job and a workload owned by it")
+			job := testing.MakeJob("job", ns.Name).Queue(queue.Name).Obj()
+			gomega.Expect(k8sClient.Create(ctx, job)).To(gomega.Succeed())
+			wl = testing.MakeWorkload("one", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "1").Obj()
+			gomega.Expect(ctrl.SetControllerReference(job, wl, k8sClient.Scheme())).To(gomega.Succeed())
+			gomega.Expect(k8sClient.Create(ctx, wl)).To(gomega.Succeed())
+
+			ginkgo.By("Mark the workload finished longer than the TTL ago")
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload)).To(gomega.Succeed())
+			updatedQueueWorkload.Status.Conditions = append(updatedQueueWorkload.Status.Conditions, kueue.WorkloadCondition{
+				Type:               kueue.WorkloadFinished,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+				Reason:             "ByTest",
+			})
+			gomega.Expect(k8sClient.Status().Update(ctx, &updatedQueueWorkload)).To(gomega.Succeed())
+			gomega.Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updatedQueueWorkload))
+			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+			gomega.Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(job), job))
+			}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+		})
 	})
 })
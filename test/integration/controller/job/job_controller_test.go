@@ -30,12 +30,13 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
 	"sigs.k8s.io/kueue/pkg/constants"
-	"sigs.k8s.io/kueue/pkg/controller/workload/job"
 	workloadjob "sigs.k8s.io/kueue/pkg/controller/workload/job"
+	"sigs.k8s.io/kueue/pkg/controller/workload/jobframework"
 	"sigs.k8s.io/kueue/pkg/util/testing"
 	"sigs.k8s.io/kueue/pkg/workload"
 	"sigs.k8s.io/kueue/test/integration/framework"
@@ -64,7 +65,7 @@ var (
 var _ = ginkgo.Describe("Job controller", func() {
 	ginkgo.BeforeEach(func() {
 		fwk = &framework.Framework{
-			ManagerSetup: managerSetup(job.WithManageJobsWithoutQueueName(true)),
+			ManagerSetup: managerSetup(jobframework.WithManageJobsWithoutQueueName(true)),
 			CRDPath:      crdPath,
 		}
 		ctx, cfg, k8sClient = fwk.Setup()
@@ -224,6 +225,25 @@ var _ = ginkgo.Describe("Job controller", func() {
 			return len(createdWorkload.Status.Conditions) == 0
 		}, framework.ConsistentDuration, framework.Interval).Should(gomega.BeTrue())
 
+		ginkgo.By("checking the workload is recreated with its admission when deleted while the job runs")
+		gomega.Expect(createdJob.Annotations[constants.PodSetsHashAnnotation]).Should(gomega.Equal(createdWorkload.Annotations[constants.PodSetsHashAnnotation]))
+		oldUID := createdWorkload.UID
+		wantAdmission := createdWorkload.Spec.Admission
+		gomega.Expect(k8sClient.Delete(ctx, createdWorkload)).Should(gomega.Succeed())
+		gomega.Eventually(func() bool {
+			if err := k8sClient.Get(ctx, lookupKey, createdWorkload); err != nil {
+				return false
+			}
+			return createdWorkload.UID != oldUID
+		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+		gomega.Expect(createdWorkload.Spec.Admission).Should(gomega.Equal(wantAdmission))
+		gomega.Consistently(func() bool {
+			if err := k8sClient.Get(ctx, lookupKey, createdJob); err != nil {
+				return false
+			}
+			return !*createdJob.Spec.Suspend
+		}, framework.ConsistentDuration, framework.Interval).Should(gomega.BeTrue())
+
 		ginkgo.By("checking the workload is finished when job is completed")
 		createdJob.Status.Conditions = append(createdJob.Status.Conditions,
 			batchv1.JobCondition{
@@ -243,6 +263,161 @@ var _ = ginkgo.Describe("Job controller", func() {
 				createdWorkload.Status.Conditions[0].Status == corev1.ConditionTrue
 		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
 	})
+
+	ginkgo.It("Should take the priority of the workload from the WorkloadPriorityClass", func() {
+		priorityClass := testing.MakePriorityClass(priorityClassName).
+			PriorityValue(int32(priorityValue)).Obj()
+		gomega.Expect(k8sClient.Create(ctx, priorityClass)).Should(gomega.Succeed())
+		wpc := testing.MakeWorkloadPriorityClass("workload-priority").PriorityValue(200).Obj()
+		gomega.Expect(k8sClient.Create(ctx, wpc)).Should(gomega.Succeed())
+		job := testing.MakeJob(jobName, jobNamespace).PriorityClass(priorityClassName).
+			WorkloadPriorityClass(wpc.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, job)).Should(gomega.Succeed())
+
+		ginkgo.By("checking the workload is created with the priority of the WorkloadPriorityClass")
+		lookupKey := types.NamespacedName{Name: jobName, Namespace: jobNamespace}
+		createdWorkload := &kueue.Workload{}
+		gomega.Eventually(func() error {
+			return k8sClient.Get(ctx, lookupKey, createdWorkload)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		gomega.Expect(createdWorkload.Spec.PriorityClassName).Should(gomega.Equal(wpc.Name))
+		gomega.Expect(createdWorkload.Spec.PriorityClassSource).Should(gomega.Equal(kueue.WorkloadPriorityClassSource))
+		gomega.Expect(*createdWorkload.Spec.Priority).Should(gomega.Equal(int32(200)))
+
+		ginkgo.By("checking the pods keep their priority class")
+		createdJob := &batchv1.Job{}
+		gomega.Expect(k8sClient.Get(ctx, lookupKey, createdJob)).Should(gomega.Succeed())
+		gomega.Expect(createdJob.Spec.Template.Spec.PriorityClassName).Should(gomega.Equal(priorityClassName))
+	})
+
+	ginkgo.It("Should pass the placement hints of the job to the workload and the pods", func() {
+		ginkgo.By("checking the workload is created with the placement hints")
+		job := testing.MakeJob(jobName, jobNamespace).Queue("test-queue").
+			PlacementHints("zone in (a,b)").Obj()
+		gomega.Expect(k8sClient.Create(ctx, job)).Should(gomega.Succeed())
+		lookupKey := types.NamespacedName{Name: jobName, Namespace: jobNamespace}
+		wantHints := []corev1.NodeSelectorTerm{{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{
+				Key:      "zone",
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{"a", "b"},
+			}},
+		}}
+		createdWorkload := &kueue.Workload{}
+		gomega.Eventually(func() error {
+			return k8sClient.Get(ctx, lookupKey, createdWorkload)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		gomega.Expect(createdWorkload.Spec.PlacementHints).Should(gomega.Equal(wantHints))
+
+		ginkgo.By("checking the placement hints are added to the node affinity when the job starts")
+		flavor := testing.MakeResourceFlavor("on-demand").Label(labelKey, "on-demand").Obj()
+		gomega.Expect(k8sClient.Create(ctx, flavor)).Should(gomega.Succeed())
+		createdWorkload.Spec.Admission = &kueue.Admission{
+			ClusterQueue: "cluster-queue",
+			PodSetFlavors: []kueue.PodSetFlavors{{
+				Flavors: map[corev1.ResourceName]string{
+					corev1.ResourceCPU: flavor.Name,
+				},
+			}},
+		}
+		gomega.Expect(k8sClient.Update(ctx, createdWorkload)).Should(gomega.Succeed())
+		createdJob := &batchv1.Job{}
+		gomega.Eventually(func() bool {
+			if err := k8sClient.Get(ctx, lookupKey, createdJob); err != nil {
+				return false
+			}
+			return !*createdJob.Spec.Suspend
+		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+		gomega.Expect(createdJob.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms).
+			Should(gomega.Equal(wantHints))
+	})
+
+	ginkgo.It("Should run the job with the admitted parallelism when partially admitted", func() {
+		ginkgo.By("checking the workload is created with the min count")
+		job := testing.MakeJob(jobName, jobNamespace).Queue("test-queue").
+			Parallelism(parallelism).MinParallelism(2).Obj()
+		gomega.Expect(k8sClient.Create(ctx, job)).Should(gomega.Succeed())
+		lookupKey := types.NamespacedName{Name: jobName, Namespace: jobNamespace}
+		createdWorkload := &kueue.Workload{}
+		gomega.Eventually(func() error {
+			return k8sClient.Get(ctx, lookupKey, createdWorkload)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		gomega.Expect(createdWorkload.Spec.PodSets[0].MinCount).Should(gomega.Equal(pointer.Int32(2)))
+
+		ginkgo.By("checking the job runs with the admitted count")
+		flavor := testing.MakeResourceFlavor("on-demand").Label(labelKey, "on-demand").Obj()
+		gomega.Expect(k8sClient.Create(ctx, flavor)).Should(gomega.Succeed())
+		createdWorkload.Spec.Admission = &kueue.Admission{
+			ClusterQueue: "cluster-queue",
+			PodSetFlavors: []kueue.PodSetFlavors{{
+				Flavors: map[corev1.ResourceName]string{
+					corev1.ResourceCPU: flavor.Name,
+				},
+				Count: pointer.Int32(3),
+			}},
+		}
+		gomega.Expect(k8sClient.Update(ctx, createdWorkload)).Should(gomega.Succeed())
+		createdJob := &batchv1.Job{}
+		gomega.Eventually(func() bool {
+			if err := k8sClient.Get(ctx, lookupKey, createdJob); err != nil {
+				return false
+			}
+			return !*createdJob.Spec.Suspend
+		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+		gomega.Expect(*createdJob.Spec.Parallelism).Should(gomega.Equal(int32(3)))
+
+		ginkgo.By("checking the job keeps its workload while running")
+		gomega.Consistently(func() error {
+			return k8sClient.Get(ctx, lookupKey, createdWorkload)
+		}, framework.ConsistentDuration, framework.Interval).Should(gomega.Succeed())
+
+		ginkgo.By("checking the parallelism is restored when the admission is cleared")
+		createdWorkload.Spec.Admission = nil
+		gomega.Expect(k8sClient.Update(ctx, createdWorkload)).Should(gomega.Succeed())
+		gomega.Eventually(func() int32 {
+			if err := k8sClient.Get(ctx, lookupKey, createdJob); err != nil {
+				return 0
+			}
+			return *createdJob.Spec.Parallelism
+		}, framework.Timeout, framework.Interval).Should(gomega.Equal(int32(parallelism)))
+	})
+
+	ginkgo.It("Should release the quota of the pods that are no longer needed", func() {
+		ginkgo.By("admitting the workload")
+		job := testing.MakeJob(jobName, jobNamespace).Queue("test-queue").
+			Parallelism(parallelism).Completions(parallelism + 2).Obj()
+		gomega.Expect(k8sClient.Create(ctx, job)).Should(gomega.Succeed())
+		lookupKey := types.NamespacedName{Name: jobName, Namespace: jobNamespace}
+		createdWorkload := &kueue.Workload{}
+		gomega.Eventually(func() error {
+			return k8sClient.Get(ctx, lookupKey, createdWorkload)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		flavor := testing.MakeResourceFlavor("on-demand").Label(labelKey, "on-demand").Obj()
+		gomega.Expect(k8sClient.Create(ctx, flavor)).Should(gomega.Succeed())
+		createdWorkload.Spec.Admission = testing.MakeAdmission("cluster-queue").
+			Flavor(corev1.ResourceCPU, flavor.Name).Obj()
+		gomega.Expect(k8sClient.Update(ctx, createdWorkload)).Should(gomega.Succeed())
+		createdJob := &batchv1.Job{}
+		gomega.Eventually(func() bool {
+			if err := k8sClient.Get(ctx, lookupKey, createdJob); err != nil {
+				return false
+			}
+			return !*createdJob.Spec.Suspend
+		}, framework.Timeout, framework.Interval).Should(gomega.BeTrue())
+
+		ginkgo.By("checking the pods beyond the remaining completions are reclaimable")
+		createdJob.Status.Succeeded = 3
+		gomega.Expect(k8sClient.Status().Update(ctx, createdJob)).Should(gomega.Succeed())
+		gomega.Eventually(func() []kueue.ReclaimablePod {
+			if err := k8sClient.Get(ctx, lookupKey, createdWorkload); err != nil {
+				return nil
+			}
+			return createdWorkload.Status.ReclaimablePods
+		}, framework.Timeout, framework.Interval).Should(gomega.Equal([]kueue.ReclaimablePod{{
+			Name:  kueue.DefaultPodSetName,
+			Count: 1,
+		}}))
+	})
 })
 
 var _ = ginkgo.Describe("Job controller for workloads with no queue set", func() {
@@ -0,0 +1,329 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/testing"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// Check verifies an invariant of the cluster, returning an error describing
+// the violation, if any.
+type Check func(context.Context, client.Client) error
+
+// Churn creates, updates and deletes Queues, ResourceFlavors and Workloads at
+// random, so that the controllers receive events in unusual orders.
+// ClusterQueues are not modified; they have to exist before running.
+type Churn struct {
+	// Namespace where the Queues and Workloads are created.
+	Namespace string
+	// ClusterQueues are the ClusterQueues the Queues point to.
+	ClusterQueues []string
+	// Flavors are the names of the ResourceFlavors to create and delete.
+	Flavors []string
+	// Queues is the number of distinct Queue names to use.
+	Queues int
+	// MaxWorkloads caps the number of Workloads that exist at the same time.
+	MaxWorkloads int
+	// Requests are the CPU requests of the Workloads, picked at random.
+	Requests []string
+	// Interval is the time between operations.
+	Interval time.Duration
+	// Seed of the random operations. A zero seed is replaced by the current
+	// time, and the seed in use is logged so that failures can be reproduced.
+	Seed int64
+
+	rnd *rand.Rand
+}
+
+// Run applies random operations for the given duration, running the checks
+// after each of them. It fails the test on unexpected API errors or when a
+// check fails.
+func (c *Churn) Run(ctx context.Context, k8sClient client.Client, duration time.Duration, checks ...Check) {
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	fmt.Fprintf(ginkgo.GinkgoWriter, "Churn seed: %d\n", c.Seed)
+	c.rnd = rand.New(rand.NewSource(c.Seed))
+	ops := []func(context.Context, client.Client) error{
+		c.createQueue,
+		c.deleteQueue,
+		c.createFlavor,
+		c.deleteFlavor,
+		c.createWorkload,
+		c.createWorkload,
+		c.moveWorkload,
+		c.finishWorkload,
+		c.deleteWorkload,
+	}
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		op := ops[c.rnd.Intn(len(ops))]
+		err := op(ctx, k8sClient)
+		// Objects can be concurrently modified by the controllers.
+		if apierrors.IsNotFound(err) || apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+			err = nil
+		}
+		gomega.ExpectWithOffset(1, err).NotTo(gomega.HaveOccurred(), "Churn seed %d", c.Seed)
+		for _, check := range checks {
+			gomega.ExpectWithOffset(1, check(ctx, k8sClient)).To(gomega.Succeed(), "Churn seed %d", c.Seed)
+		}
+		time.Sleep(c.Interval)
+	}
+}
+
+func (c *Churn) queueName() string {
+	return fmt.Sprintf("churn-queue-%d", c.rnd.Intn(c.Queues))
+}
+
+func (c *Churn) createQueue(ctx context.Context, k8sClient client.Client) error {
+	cq := c.ClusterQueues[c.rnd.Intn(len(c.ClusterQueues))]
+	return k8sClient.Create(ctx, testing.MakeQueue(c.queueName(), c.Namespace).ClusterQueue(cq).Obj())
+}
+
+func (c *Churn) deleteQueue(ctx context.Context, k8sClient client.Client) error {
+	return k8sClient.Delete(ctx, testing.MakeQueue(c.queueName(), c.Namespace).Obj())
+}
+
+func (c *Churn) createFlavor(ctx context.Context, k8sClient client.Client) error {
+	return k8sClient.Create(ctx, testing.MakeResourceFlavor(c.Flavors[c.rnd.Intn(len(c.Flavors))]).Obj())
+}
+
+func (c *Churn) deleteFlavor(ctx context.Context, k8sClient client.Client) error {
+	return k8sClient.Delete(ctx, testing.MakeResourceFlavor(c.Flavors[c.rnd.Intn(len(c.Flavors))]).Obj())
+}
+
+func (c *Churn) createWorkload(ctx context.Context, k8sClient client.Client) error {
+	var wls kueue.WorkloadList
+	if err := k8sClient.List(ctx, &wls, client.InNamespace(c.Namespace)); err != nil {
+		return err
+	}
+	if len(wls.Items) >= c.MaxWorkloads {
+		return nil
+	}
+	wl := testing.MakeWorkload("", c.Namespace).
+		Queue(c.queueName()).
+		Request(corev1.ResourceCPU, c.Requests[c.rnd.Intn(len(c.Requests))]).
+		Obj()
+	wl.GenerateName = "churn-"
+	return k8sClient.Create(ctx, wl)
+}
+
+// randomWorkload returns a random Workload in the namespace that matches the
+// filter, or nil if there are none.
+func (c *Churn) randomWorkload(ctx context.Context, k8sClient client.Client, filter func(*kueue.Workload) bool) (*kueue.Workload, error) {
+	var wls kueue.WorkloadList
+	if err := k8sClient.List(ctx, &wls, client.InNamespace(c.Namespace)); err != nil {
+		return nil, err
+	}
+	var candidates []*kueue.Workload
+	for i := range wls.Items {
+		if filter(&wls.Items[i]) {
+			candidates = append(candidates, &wls.Items[i])
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	return candidates[c.rnd.Intn(len(candidates))], nil
+}
+
+func (c *Churn) moveWorkload(ctx context.Context, k8sClient client.Client) error {
+	wl, err := c.randomWorkload(ctx, k8sClient, func(w *kueue.Workload) bool {
+		return w.Spec.Admission == nil
+	})
+	if wl == nil || err != nil {
+		return err
+	}
+	wl.Spec.QueueName = c.queueName()
+	return k8sClient.Update(ctx, wl)
+}
+
+func (c *Churn) finishWorkload(ctx context.Context, k8sClient client.Client) error {
+	wl, err := c.randomWorkload(ctx, k8sClient, func(w *kueue.Workload) bool {
+		return w.Spec.Admission != nil && !workload.InCondition(w, kueue.WorkloadFinished)
+	})
+	if wl == nil || err != nil {
+		return err
+	}
+	wl.Status.Conditions = append(wl.Status.Conditions, kueue.WorkloadCondition{
+		Type:               kueue.WorkloadFinished,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Churn",
+	})
+	return k8sClient.Status().Update(ctx, wl)
+}
+
+func (c *Churn) deleteWorkload(ctx context.Context, k8sClient client.Client) error {
+	wl, err := c.randomWorkload(ctx, k8sClient, func(*kueue.Workload) bool { return true })
+	if wl == nil || err != nil {
+		return err
+	}
+	return k8sClient.Delete(ctx, wl)
+}
+
+// CheckNoOverAdmission verifies that the usage of the admitted Workloads
+// doesn't exceed the nominal quota plus the borrowing limit of any
+// ClusterQueue, nor the nominal quota of ClusterQueues without a cohort, nor
+// the sum of the nominal quotas that the ClusterQueues of any cohort lend.
+func CheckNoOverAdmission(ctx context.Context, k8sClient client.Client) error {
+	var cqs kueue.ClusterQueueList
+	if err := k8sClient.List(ctx, &cqs); err != nil {
+		return err
+	}
+	var wls kueue.WorkloadList
+	if err := k8sClient.List(ctx, &wls); err != nil {
+		return err
+	}
+	type flavorKey struct {
+		resource corev1.ResourceName
+		flavor   string
+	}
+	usage := make(map[string]map[flavorKey]int64)
+	for i := range wls.Items {
+		wl := &wls.Items[i]
+		if wl.Spec.Admission == nil || workload.InCondition(wl, kueue.WorkloadFinished) {
+			continue
+		}
+		cq := string(wl.Spec.Admission.ClusterQueue)
+		if usage[cq] == nil {
+			usage[cq] = make(map[flavorKey]int64)
+		}
+		for _, ps := range workload.NewInfo(wl).TotalRequests {
+			for r, v := range ps.Requests {
+				usage[cq][flavorKey{resource: r, flavor: ps.Flavors[r]}] += v
+			}
+		}
+	}
+	cohortQuota := make(map[string]map[flavorKey]int64)
+	cohortUsage := make(map[string]map[flavorKey]int64)
+	for _, cq := range cqs.Items {
+		cqUsage := usage[cq.Name]
+		delete(usage, cq.Name)
+		quotas := make(map[flavorKey]kueue.ResourceQuota)
+		for _, rg := range cq.Spec.ResourceGroups {
+			for _, f := range rg.Flavors {
+				for _, q := range f.Resources {
+					quotas[flavorKey{resource: q.Name, flavor: string(f.Name)}] = q
+				}
+			}
+		}
+		if cq.Spec.Cohort != "" && cohortQuota[cq.Spec.Cohort] == nil {
+			cohortQuota[cq.Spec.Cohort] = make(map[flavorKey]int64)
+			cohortUsage[cq.Spec.Cohort] = make(map[flavorKey]int64)
+		}
+		guaranteed := make(map[flavorKey]int64)
+		for k, q := range quotas {
+			nominal := workload.ResourceValue(k.resource, q.NominalQuota)
+			if q.LendingLimit != nil {
+				if lendable := workload.ResourceValue(k.resource, *q.LendingLimit); lendable < nominal {
+					guaranteed[k] = nominal - lendable
+				}
+			}
+			if cq.Spec.Cohort != "" {
+				cohortQuota[cq.Spec.Cohort][k] += nominal - guaranteed[k]
+			}
+		}
+		for k, used := range cqUsage {
+			q, ok := quotas[k]
+			if !ok {
+				return fmt.Errorf("ClusterQueue %s admitted %d of %s in flavor %q, which it doesn't define", cq.Name, used, k.resource, k.flavor)
+			}
+			nominal := workload.ResourceValue(k.resource, q.NominalQuota)
+			if q.BorrowingLimit != nil && used > nominal+workload.ResourceValue(k.resource, *q.BorrowingLimit) {
+				return fmt.Errorf("ClusterQueue %s uses %d of %s in flavor %s, over its borrowing limit %s", cq.Name, used, k.resource, k.flavor, q.BorrowingLimit.String())
+			}
+			if cq.Spec.Cohort == "" {
+				if used > nominal {
+					return fmt.Errorf("ClusterQueue %s uses %d of %s in flavor %s, over its nominal quota %s without a cohort", cq.Name, used, k.resource, k.flavor, q.NominalQuota.String())
+				}
+			} else if lent := used - guaranteed[k]; lent > 0 {
+				cohortUsage[cq.Spec.Cohort][k] += lent
+			}
+		}
+	}
+	for cq := range usage {
+		return fmt.Errorf("workloads are admitted to ClusterQueue %s, which doesn't exist", cq)
+	}
+	for cohort, cUsage := range cohortUsage {
+		for k, used := range cUsage {
+			if quota := cohortQuota[cohort][k]; used > quota {
+				return fmt.Errorf("cohort %s uses %d of %s in flavor %s, over its quota %d", cohort, used, k.resource, k.flavor, quota)
+			}
+		}
+	}
+	return nil
+}
+
+// ExpectCountersConsistent waits until the number of admitted workloads in
+// the status of the ClusterQueues and the number of pending workloads in the
+// status of the Queues match the Workloads in the API server.
+func ExpectCountersConsistent(ctx context.Context, k8sClient client.Client) {
+	gomega.EventuallyWithOffset(1, func() error {
+		var wls kueue.WorkloadList
+		if err := k8sClient.List(ctx, &wls); err != nil {
+			return err
+		}
+		admitted := make(map[string]int32)
+		pending := make(map[string]int32)
+		for i := range wls.Items {
+			wl := &wls.Items[i]
+			if workload.InCondition(wl, kueue.WorkloadFinished) {
+				continue
+			}
+			if wl.Spec.Admission != nil {
+				admitted[string(wl.Spec.Admission.ClusterQueue)]++
+			} else {
+				pending[wl.Namespace+"/"+wl.Spec.QueueName]++
+			}
+		}
+		var cqs kueue.ClusterQueueList
+		if err := k8sClient.List(ctx, &cqs); err != nil {
+			return err
+		}
+		for _, cq := range cqs.Items {
+			if cq.Status.AdmittedWorkloads != admitted[cq.Name] {
+				return fmt.Errorf("ClusterQueue %s reports %d admitted workloads, want %d", cq.Name, cq.Status.AdmittedWorkloads, admitted[cq.Name])
+			}
+		}
+		var queues kueue.QueueList
+		if err := k8sClient.List(ctx, &queues); err != nil {
+			return err
+		}
+		for _, q := range queues.Items {
+			key := q.Namespace + "/" + q.Name
+			if q.Status.PendingWorkloads != pending[key] {
+				return fmt.Errorf("Queue %s reports %d pending workloads, want %d", key, q.Status.PendingWorkloads, pending[key])
+			}
+		}
+		return nil
+	}, Timeout, Interval).Should(gomega.Succeed())
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/onsi/ginkgo/v2"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/queue"
+)
+
+// ArtifactsEnv is the environment variable pointing to the directory where
+// CI collects artifacts.
+const ArtifactsEnv = "ARTIFACTS"
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+type stateDump struct {
+	PendingWorkloads      map[string][]string    `json:"pendingWorkloads,omitempty"`
+	InadmissibleWorkloads map[string][]string    `json:"inadmissibleWorkloads,omitempty"`
+	AdmittedWorkloads     map[string][]string    `json:"admittedWorkloads,omitempty"`
+	AssumedWorkloads      map[string]string      `json:"assumedWorkloads,omitempty"`
+	ClusterQueues         []kueue.ClusterQueue   `json:"clusterQueues,omitempty"`
+	Queues                []kueue.Queue          `json:"queues,omitempty"`
+	ResourceFlavors       []kueue.ResourceFlavor `json:"resourceFlavors,omitempty"`
+	Workloads             []kueue.Workload       `json:"workloads,omitempty"`
+	Errors                []string               `json:"errors,omitempty"`
+}
+
+// DumpState writes the state of the queue manager and the cache, along with
+// the kueue objects in the API server, to the GinkgoWriter. If the ARTIFACTS
+// environment variable is set, the dump is also written to a file in that
+// directory named after the current spec.
+func DumpState(ctx context.Context, k8sClient client.Client, queues *queue.Manager, cCache *cache.Cache) {
+	var dump stateDump
+	if queues != nil {
+		dump.PendingWorkloads = setsToLists(queues.Dump())
+		dump.InadmissibleWorkloads = setsToLists(queues.DumpInadmissible())
+	}
+	if cCache != nil {
+		dump.AdmittedWorkloads = setsToLists(cCache.Dump())
+		dump.AssumedWorkloads = cCache.DumpAssumed()
+	}
+
+	var cqs kueue.ClusterQueueList
+	if err := k8sClient.List(ctx, &cqs); err != nil {
+		dump.Errors = append(dump.Errors, fmt.Sprintf("listing ClusterQueues: %v", err))
+	}
+	dump.ClusterQueues = cqs.Items
+	var qs kueue.QueueList
+	if err := k8sClient.List(ctx, &qs); err != nil {
+		dump.Errors = append(dump.Errors, fmt.Sprintf("listing Queues: %v", err))
+	}
+	dump.Queues = qs.Items
+	var rfs kueue.ResourceFlavorList
+	if err := k8sClient.List(ctx, &rfs); err != nil {
+		dump.Errors = append(dump.Errors, fmt.Sprintf("listing ResourceFlavors: %v", err))
+	}
+	dump.ResourceFlavors = rfs.Items
+	var wls kueue.WorkloadList
+	if err := k8sClient.List(ctx, &wls); err != nil {
+		dump.Errors = append(dump.Errors, fmt.Sprintf("listing Workloads: %v", err))
+	}
+	dump.Workloads = wls.Items
+
+	data, err := yaml.Marshal(&dump)
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "Failed to marshal the state dump: %v\n", err)
+		return
+	}
+	report := ginkgo.CurrentSpecReport()
+	fmt.Fprintf(ginkgo.GinkgoWriter, "State dump for %q:\n%s\n", report.FullText(), data)
+
+	dir := os.Getenv(ArtifactsEnv)
+	if dir == "" {
+		return
+	}
+	name := unsafeFileChars.ReplaceAllString(report.FullText(), "_")
+	path := filepath.Join(dir, fmt.Sprintf("state-dump-%s.yaml", name))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "Failed to write the state dump to %s: %v\n", path, err)
+	}
+}
+
+// DumpStateOnFailure calls DumpState if the current spec failed. It's meant
+// to be called from a ginkgo.JustAfterEach, so that the state is captured
+// before the AfterEach nodes clean up the objects.
+func DumpStateOnFailure(ctx context.Context, k8sClient client.Client, queues *queue.Manager, cCache *cache.Cache) {
+	if ginkgo.CurrentSpecReport().Failed() {
+		DumpState(ctx, k8sClient, queues, cCache)
+	}
+}
+
+func setsToLists(m map[string]sets.String) map[string][]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(m))
+	for k, s := range m {
+		out[k] = s.List()
+	}
+	return out
+}
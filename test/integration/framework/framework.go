@@ -39,6 +39,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/cache"
+	"sigs.k8s.io/kueue/pkg/queue"
 	"sigs.k8s.io/kueue/pkg/workload"
 	// +kubebuilder:scaffold:imports
 )
@@ -142,6 +144,19 @@ func DeleteResourceFlavor(ctx context.Context, c client.Client, rf *kueue.Resour
 	return nil
 }
 
+// ExpectResourceFlavorToBeDeleted deletes the ResourceFlavor and waits for it
+// to be removed, which only happens once no ClusterQueue references it.
+func ExpectResourceFlavorToBeDeleted(ctx context.Context, k8sClient client.Client, rf *kueue.ResourceFlavor) {
+	if rf == nil {
+		return
+	}
+	gomega.ExpectWithOffset(1, DeleteResourceFlavor(ctx, k8sClient, rf)).To(gomega.Succeed())
+	gomega.EventuallyWithOffset(1, func() bool {
+		var updated kueue.ResourceFlavor
+		return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(rf), &updated))
+	}, Timeout, Interval).Should(gomega.BeTrue(), "The ResourceFlavor wasn't removed")
+}
+
 func DeleteQueue(ctx context.Context, c client.Client, q *kueue.Queue) error {
 	if q != nil {
 		if err := c.Delete(ctx, q); err != nil && !apierrors.IsNotFound(err) {
@@ -213,3 +228,29 @@ func UpdateWorkloadStatus(ctx context.Context, k8sClient client.Client, wl *kueu
 		return k8sClient.Status().Update(ctx, &updatedWl)
 	}, Timeout, Interval).Should(gomega.Succeed())
 }
+
+// ExpectInSync waits until the queue manager and the cache processed the
+// events for the objects that exist in the API server. Use it instead of
+// sleeping after creating or deleting objects.
+func ExpectInSync(ctx context.Context, k8sClient client.Client, queues *queue.Manager, cCache *cache.Cache) {
+	gomega.EventuallyWithOffset(1, func() (bool, error) {
+		var cqs kueue.ClusterQueueList
+		if err := k8sClient.List(ctx, &cqs); err != nil {
+			return false, err
+		}
+		var qs kueue.QueueList
+		if err := k8sClient.List(ctx, &qs); err != nil {
+			return false, err
+		}
+		var flavors kueue.ResourceFlavorList
+		if err := k8sClient.List(ctx, &flavors); err != nil {
+			return false, err
+		}
+		var wls kueue.WorkloadList
+		if err := k8sClient.List(ctx, &wls); err != nil {
+			return false, err
+		}
+		return queues.InSync(qs.Items, cqs.Items, wls.Items) &&
+			cCache.InSync(cqs.Items, flavors.Items, wls.Items), nil
+	}, Timeout, Interval).Should(gomega.BeTrue(), "The queue manager and cache didn't catch up with the API server")
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/testing"
+	"sigs.k8s.io/kueue/test/integration/framework"
+)
+
+var _ = ginkgo.Describe("Scheduler under churn", func() {
+	const churnDuration = 10 * time.Second
+
+	var (
+		ns       *corev1.Namespace
+		cqs      []*kueue.ClusterQueue
+		flavors  = []string{"churn-on-demand", "churn-spot"}
+		cqNames  = []string{"churn-alpha", "churn-beta", "churn-solo"}
+		cohortOf = map[string]string{"churn-alpha": "churn", "churn-beta": "churn"}
+	)
+
+	ginkgo.BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "churn-",
+			},
+		}
+		gomega.Expect(k8sClient.Create(ctx, ns)).To(gomega.Succeed())
+
+		cqs = nil
+		for _, name := range cqNames {
+			cq := testing.MakeClusterQueue(name).
+				Cohort(cohortOf[name]).
+				QueueingStrategy(kueue.BestEffortFIFO).
+				Resource(testing.MakeResource(corev1.ResourceCPU).
+					Flavor(testing.MakeFlavor(flavors[0], "4").BorrowingLimit("2").Obj()).
+					Flavor(testing.MakeFlavor(flavors[1], "2").Obj()).
+					Obj()).
+				Obj()
+			gomega.Expect(k8sClient.Create(ctx, cq)).To(gomega.Succeed())
+			cqs = append(cqs, cq)
+		}
+	})
+
+	ginkgo.AfterEach(func() {
+		gomega.Expect(framework.DeleteNamespace(ctx, k8sClient, ns)).To(gomega.Succeed())
+		for _, cq := range cqs {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, cq)).To(gomega.Succeed())
+		}
+		for _, name := range flavors {
+			framework.ExpectResourceFlavorToBeDeleted(ctx, k8sClient, testing.MakeResourceFlavor(name).Obj())
+		}
+		framework.ExpectInSync(ctx, k8sClient, queues, cCache)
+	})
+
+	ginkgo.It("Should not over-admit and should keep counters consistent", func() {
+		churn := framework.Churn{
+			Namespace:     ns.Name,
+			ClusterQueues: cqNames,
+			Flavors:       flavors,
+			Queues:        4,
+			MaxWorkloads:  30,
+			Requests:      []string{"500m", "1", "2", "3"},
+			Interval:      20 * time.Millisecond,
+		}
+		churn.Run(ctx, k8sClient, churnDuration, framework.CheckNoOverAdmission)
+
+		framework.ExpectInSync(ctx, k8sClient, queues, cCache)
+		framework.ExpectCountersConsistent(ctx, k8sClient)
+		gomega.Expect(framework.CheckNoOverAdmission(ctx, k8sClient)).To(gomega.Succeed())
+	})
+})
@@ -21,6 +21,7 @@ import (
 	"github.com/onsi/gomega"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -86,7 +87,7 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		prodClusterQ = testing.MakeClusterQueue("prod-cq").
 			Cohort("prod").
 			Resource(testing.MakeResource(corev1.ResourceCPU).
-				Flavor(testing.MakeFlavor(spotTaintedFlavor.Name, "5").Max("5").Obj()).
+				Flavor(testing.MakeFlavor(spotTaintedFlavor.Name, "5").BorrowingLimit("0").Obj()).
 				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "5").Obj()).
 				Obj()).
 			Obj()
@@ -103,7 +104,7 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		prodBEClusterQ = testing.MakeClusterQueue("prod-be-cq").
 			Cohort("be").QueueingStrategy(kueue.BestEffortFIFO).
 			Resource(testing.MakeResource(corev1.ResourceCPU).
-				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "5").Max("15").Obj()).
+				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "5").BorrowingLimit("10").Obj()).
 				Obj()).
 			Obj()
 		gomega.Expect(k8sClient.Create(ctx, prodBEClusterQ)).Should(gomega.Succeed())
@@ -111,7 +112,7 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		devBEClusterQ = testing.MakeClusterQueue("dev-be-cq").
 			Cohort("be").QueueingStrategy(kueue.BestEffortFIFO).
 			Resource(testing.MakeResource(corev1.ResourceCPU).
-				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "5").Max("15").Obj()).
+				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "5").BorrowingLimit("10").Obj()).
 				Obj()).
 			Obj()
 		gomega.Expect(k8sClient.Create(ctx, devBEClusterQ)).Should(gomega.Succeed())
@@ -127,6 +128,7 @@ var _ = ginkgo.Describe("Scheduler", func() {
 
 		devBEQueue = testing.MakeQueue("dev-be-queue", ns.Name).ClusterQueue(devBEClusterQ.Name).Obj()
 		gomega.Expect(k8sClient.Create(ctx, devBEQueue)).Should(gomega.Succeed())
+		framework.ExpectInSync(ctx, k8sClient, queues, cCache)
 	})
 
 	ginkgo.AfterEach(func() {
@@ -135,9 +137,10 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, devClusterQ)).To(gomega.Succeed())
 		gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, prodBEClusterQ)).To(gomega.Succeed())
 		gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, devBEClusterQ)).To(gomega.Succeed())
-		gomega.Expect(framework.DeleteResourceFlavor(ctx, k8sClient, onDemandFlavor)).To(gomega.Succeed())
-		gomega.Expect(framework.DeleteResourceFlavor(ctx, k8sClient, spotTaintedFlavor)).To(gomega.Succeed())
-		gomega.Expect(framework.DeleteResourceFlavor(ctx, k8sClient, spotUntaintedFlavor)).To(gomega.Succeed())
+		framework.ExpectResourceFlavorToBeDeleted(ctx, k8sClient, onDemandFlavor)
+		framework.ExpectResourceFlavorToBeDeleted(ctx, k8sClient, spotTaintedFlavor)
+		framework.ExpectResourceFlavorToBeDeleted(ctx, k8sClient, spotUntaintedFlavor)
+		framework.ExpectInSync(ctx, k8sClient, queues, cCache)
 		ns = nil
 	})
 
@@ -241,7 +244,7 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		fallbackClusterQueue := testing.MakeClusterQueue("fallback-cq").
 			Cohort(prodClusterQ.Spec.Cohort).
 			Resource(testing.MakeResource(corev1.ResourceCPU).
-				Flavor(testing.MakeFlavor(spotTaintedFlavor.Name, "5").Obj()). // prod-cq can't borrow this flavor due to its max quota.
+				Flavor(testing.MakeFlavor(spotTaintedFlavor.Name, "5").Obj()). // prod-cq can't borrow this flavor due to its borrowing limit.
 				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "5").Obj()).
 				Obj()).
 			Obj()
@@ -505,8 +508,8 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		devCq := &kueue.ClusterQueue{}
 		gomega.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: devBEClusterQ.Name}, devCq)).Should(gomega.Succeed())
 
-		updatedResource := testing.MakeResource(corev1.ResourceCPU).Flavor(testing.MakeFlavor(onDemandFlavor.Name, "13").Max("13").Obj()).Obj()
-		devCq.Spec.Resources = []kueue.Resource{*updatedResource}
+		updatedResource := testing.MakeResource(corev1.ResourceCPU).Flavor(testing.MakeFlavor(onDemandFlavor.Name, "13").BorrowingLimit("0").Obj()).Obj()
+		devCq.Spec.ResourceGroups = []kueue.ResourceGroup{*updatedResource}
 		gomega.Expect(k8sClient.Update(ctx, devCq)).Should(gomega.Succeed())
 
 		gomega.Eventually(func() *bool {
@@ -543,6 +546,21 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, prodClusterQ.Name, smallWl1, smallWl2)
 	})
 
+	ginkgo.It("Should admit the pending workloads of a Queue moved to another ClusterQueue", func() {
+		wl := testing.MakeWorkload("wl", ns.Name).Queue(prodQueue.Name).Request(corev1.ResourceCPU, "8").Obj()
+		ginkgo.By("Creating a workload that doesn't fit in the ClusterQueue")
+		gomega.Expect(k8sClient.Create(ctx, wl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBePending(ctx, k8sClient, wl)
+
+		ginkgo.By("Pointing the Queue to a ClusterQueue with enough quota")
+		var updatedQueue kueue.Queue
+		gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(prodQueue), &updatedQueue)).Should(gomega.Succeed())
+		updatedQueue.Spec.ClusterQueue = kueue.ClusterQueueReference(prodBEClusterQ.Name)
+		gomega.Expect(k8sClient.Update(ctx, &updatedQueue)).Should(gomega.Succeed())
+
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, prodBEClusterQ.Name, wl)
+	})
+
 	ginkgo.It("Should schedule workloads borrowing quota from ClusterQueues in the same Cohort", func() {
 		wl1 := testing.MakeWorkload("wl-1", ns.Name).Queue(prodBEQueue.Name).Request(corev1.ResourceCPU, "11").Obj()
 		wl2 := testing.MakeWorkload("wl-2", ns.Name).Queue(devBEQueue.Name).Request(corev1.ResourceCPU, "11").Obj()
@@ -557,7 +575,7 @@ var _ = ginkgo.Describe("Scheduler", func() {
 			Cohort("be").QueueingStrategy(kueue.BestEffortFIFO).
 			Resource(testing.MakeResource(corev1.ResourceCPU).
 				Flavor(testing.MakeFlavor(onDemandFlavor.Name,
-					"15").Max("15").Obj()).
+					"15").BorrowingLimit("0").Obj()).
 				Obj()).
 			Obj()
 		gomega.Expect(k8sClient.Create(ctx, testBEClusterQ)).Should(gomega.Succeed())
@@ -568,4 +586,194 @@ var _ = ginkgo.Describe("Scheduler", func() {
 		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, prodBEClusterQ.Name, wl1)
 		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, devBEClusterQ.Name, wl2)
 	})
+
+	ginkgo.It("Should borrow from the root Cohort within the limits of the nested Cohort", func() {
+		teamCohort := testing.MakeCohort("team").Parent("be").Limit(corev1.ResourceCPU, onDemandFlavor.Name, "4").Obj()
+		gomega.Expect(k8sClient.Create(ctx, teamCohort)).Should(gomega.Succeed())
+		defer func() {
+			gomega.Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, teamCohort))).Should(gomega.Succeed())
+		}()
+		teamClusterQ := testing.MakeClusterQueue("team-cq").
+			Cohort(teamCohort.Name).QueueingStrategy(kueue.BestEffortFIFO).
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "2").Obj()).
+				Obj()).
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, teamClusterQ)).Should(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, teamClusterQ)).Should(gomega.Succeed())
+		}()
+		teamQueue := testing.MakeQueue("team-queue", ns.Name).ClusterQueue(teamClusterQ.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, teamQueue)).Should(gomega.Succeed())
+
+		ginkgo.By("Creating a workload that fits in the root Cohort but not in the limit of the nested one")
+		wl := testing.MakeWorkload("wl", ns.Name).Queue(teamQueue.Name).Request(corev1.ResourceCPU, "6").Obj()
+		gomega.Expect(k8sClient.Create(ctx, wl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBePending(ctx, k8sClient, wl)
+
+		ginkgo.By("Raising the limit of the nested Cohort")
+		gomega.Eventually(func() error {
+			var updated kueue.Cohort
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(teamCohort), &updated); err != nil {
+				return err
+			}
+			updated.Spec.Resources[0].Flavors[0].Limit = resource.MustParse("6")
+			return k8sClient.Update(ctx, &updated)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, teamClusterQ.Name, wl)
+	})
+
+	ginkgo.It("Should only lend the quota of a ClusterQueue up to its lending limit", func() {
+		lenderClusterQ := testing.MakeClusterQueue("lender-cq").
+			Cohort("lending").QueueingStrategy(kueue.BestEffortFIFO).
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "10").LendingLimit("4").Obj()).
+				Obj()).
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, lenderClusterQ)).Should(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, lenderClusterQ)).Should(gomega.Succeed())
+		}()
+		borrowerClusterQ := testing.MakeClusterQueue("borrower-cq").
+			Cohort("lending").QueueingStrategy(kueue.BestEffortFIFO).
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "0").Obj()).
+				Obj()).
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, borrowerClusterQ)).Should(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, borrowerClusterQ)).Should(gomega.Succeed())
+		}()
+		lenderQueue := testing.MakeQueue("lender-queue", ns.Name).ClusterQueue(lenderClusterQ.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, lenderQueue)).Should(gomega.Succeed())
+		borrowerQueue := testing.MakeQueue("borrower-queue", ns.Name).ClusterQueue(borrowerClusterQ.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, borrowerQueue)).Should(gomega.Succeed())
+
+		ginkgo.By("Creating a workload that needs more than the lent quota")
+		bigWl := testing.MakeWorkload("big", ns.Name).Queue(borrowerQueue.Name).Request(corev1.ResourceCPU, "5").Obj()
+		gomega.Expect(k8sClient.Create(ctx, bigWl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBePending(ctx, k8sClient, bigWl)
+
+		ginkgo.By("Creating a workload that fits in the lent quota")
+		smallWl := testing.MakeWorkload("small", ns.Name).Queue(borrowerQueue.Name).Request(corev1.ResourceCPU, "4").Obj()
+		gomega.Expect(k8sClient.Create(ctx, smallWl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, borrowerClusterQ.Name, smallWl)
+
+		ginkgo.By("Creating a workload that uses the quota that the lender keeps")
+		lenderWl := testing.MakeWorkload("lender", ns.Name).Queue(lenderQueue.Name).Request(corev1.ResourceCPU, "6").Obj()
+		gomega.Expect(k8sClient.Create(ctx, lenderWl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, lenderClusterQ.Name, lenderWl)
+		framework.ExpectWorkloadsToBePending(ctx, k8sClient, bigWl)
+	})
+
+	ginkgo.It("Should hold the admission of a stopped ClusterQueue and drain it", func() {
+		admittedWl := testing.MakeWorkload("admitted-wl", ns.Name).Queue(prodQueue.Name).Request(corev1.ResourceCPU, "2").Obj()
+		gomega.Expect(k8sClient.Create(ctx, admittedWl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, prodClusterQ.Name, admittedWl)
+
+		setStopPolicy := func(policy kueue.StopPolicy) {
+			gomega.Eventually(func() error {
+				var updated kueue.ClusterQueue
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(prodClusterQ), &updated); err != nil {
+					return err
+				}
+				updated.Spec.StopPolicy = policy
+				return k8sClient.Update(ctx, &updated)
+			}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		}
+
+		ginkgo.By("Holding the ClusterQueue")
+		setStopPolicy(kueue.StopPolicyHold)
+		pendingWl := testing.MakeWorkload("pending-wl", ns.Name).Queue(prodQueue.Name).Request(corev1.ResourceCPU, "2").Obj()
+		gomega.Expect(k8sClient.Create(ctx, pendingWl)).Should(gomega.Succeed())
+		gomega.Consistently(func() *kueue.Admission {
+			var updated kueue.Workload
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(pendingWl), &updated)).Should(gomega.Succeed())
+			return updated.Spec.Admission
+		}, framework.ConsistentDuration, framework.Interval).Should(gomega.BeNil())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, prodClusterQ.Name, admittedWl)
+
+		ginkgo.By("Draining the ClusterQueue")
+		setStopPolicy(kueue.StopPolicyHoldAndDrain)
+		gomega.Eventually(func() *kueue.Admission {
+			var updated kueue.Workload
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(admittedWl), &updated)).Should(gomega.Succeed())
+			return updated.Spec.Admission
+		}, framework.Timeout, framework.Interval).Should(gomega.BeNil())
+
+		ginkgo.By("Resuming the ClusterQueue")
+		setStopPolicy(kueue.StopPolicyNone)
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, prodClusterQ.Name, admittedWl, pendingWl)
+	})
+
+	ginkgo.It("Should apply the quota schedules and evict the workloads above the quotas when they shrink", func() {
+		// A window of one minute that starts every minute is always active.
+		cq := testing.MakeClusterQueue("scheduled-cq").
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor(onDemandFlavor.Name, "2").Obj()).
+				Obj()).
+			QuotaSchedule("always", "* * * * *", 60,
+				kueue.ScheduledQuota{Flavor: kueue.ResourceFlavorReference(onDemandFlavor.Name), Resource: corev1.ResourceCPU, NominalQuota: resource.MustParse("4")}).
+			QuotaShrinkPolicy(kueue.QuotaShrinkPolicyEvict).
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, cq)).Should(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, cq)).Should(gomega.Succeed())
+		}()
+		queue := testing.MakeQueue("scheduled-queue", ns.Name).ClusterQueue(cq.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, queue)).Should(gomega.Succeed())
+
+		ginkgo.By("Creating workloads that only fit the scheduled quota")
+		oldWl := testing.MakeWorkload("old", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "2").Obj()
+		gomega.Expect(k8sClient.Create(ctx, oldWl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, cq.Name, oldWl)
+		newWl := testing.MakeWorkload("new", ns.Name).Queue(queue.Name).Request(corev1.ResourceCPU, "2").Obj()
+		gomega.Expect(k8sClient.Create(ctx, newWl)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, cq.Name, newWl)
+
+		ginkgo.By("Removing the quota schedule")
+		gomega.Eventually(func() error {
+			var updated kueue.ClusterQueue
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(cq), &updated); err != nil {
+				return err
+			}
+			updated.Spec.QuotaSchedules = nil
+			return k8sClient.Update(ctx, &updated)
+		}, framework.Timeout, framework.Interval).Should(gomega.Succeed())
+		gomega.Eventually(func() *kueue.Admission {
+			var updated kueue.Workload
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(newWl), &updated)).Should(gomega.Succeed())
+			return updated.Spec.Admission
+		}, framework.Timeout, framework.Interval).Should(gomega.BeNil())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, cq.Name, oldWl)
+	})
+
+	ginkgo.It("Should hold the admission of a ClusterQueue until its flavors exist", func() {
+		modelFlavor := testing.MakeResourceFlavor("model").Obj()
+		cq := testing.MakeClusterQueue("model-cq").
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor(modelFlavor.Name, "5").Obj()).
+				Obj()).
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, cq)).Should(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, cq)).To(gomega.Succeed())
+			framework.ExpectResourceFlavorToBeDeleted(ctx, k8sClient, modelFlavor)
+		}()
+		modelQueue := testing.MakeQueue("model-queue", ns.Name).ClusterQueue(cq.Name).Obj()
+		gomega.Expect(k8sClient.Create(ctx, modelQueue)).Should(gomega.Succeed())
+
+		ginkgo.By("Creating a workload before the flavor")
+		wl := testing.MakeWorkload("wl", ns.Name).Queue(modelQueue.Name).Request(corev1.ResourceCPU, "2").Obj()
+		gomega.Expect(k8sClient.Create(ctx, wl)).Should(gomega.Succeed())
+		gomega.Consistently(func() *kueue.Admission {
+			var updated kueue.Workload
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), &updated)).Should(gomega.Succeed())
+			return updated.Spec.Admission
+		}, framework.ConsistentDuration, framework.Interval).Should(gomega.BeNil())
+
+		ginkgo.By("Creating the flavor")
+		gomega.Expect(k8sClient.Create(ctx, modelFlavor)).Should(gomega.Succeed())
+		framework.ExpectWorkloadsToBeAdmitted(ctx, k8sClient, cq.Name, wl)
+	})
 })
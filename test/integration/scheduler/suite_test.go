@@ -42,6 +42,8 @@ var (
 	k8sClient client.Client
 	ctx       context.Context
 	fwk       *framework.Framework
+	queues    *queue.Manager
+	cCache    *cache.Cache
 )
 
 func TestScheduler(t *testing.T) {
@@ -60,6 +62,10 @@ var _ = ginkgo.BeforeSuite(func() {
 	ctx, cfg, k8sClient = fwk.Setup()
 })
 
+var _ = ginkgo.JustAfterEach(func() {
+	framework.DumpStateOnFailure(ctx, k8sClient, queues, cCache)
+})
+
 var _ = ginkgo.AfterSuite(func() {
 	fwk.Teardown()
 })
@@ -71,8 +77,8 @@ func managerAndSchedulerSetup(mgr manager.Manager, ctx context.Context) {
 	err = cache.SetupIndexes(mgr.GetFieldIndexer())
 	gomega.Expect(err).NotTo(gomega.HaveOccurred())
 
-	queues := queue.NewManager(mgr.GetClient())
-	cCache := cache.New(mgr.GetClient())
+	cCache = cache.New(mgr.GetClient())
+	queues = queue.NewManager(mgr.GetClient(), queue.WithStatusChecker(cCache))
 
 	failedCtrl, err := core.SetupControllers(mgr, queues, cCache)
 	gomega.Expect(err).ToNot(gomega.HaveOccurred(), "controller", failedCtrl)
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/testing"
+	"sigs.k8s.io/kueue/test/integration/framework"
+)
+
+var _ = ginkgo.Describe("ClusterQueue defaulting webhook", func() {
+	ginkgo.It("Should round up cpu quotas to millicores", func() {
+		cq := testing.MakeClusterQueue("cluster-queue").
+			Resource(testing.MakeResource(corev1.ResourceCPU).
+				Flavor(testing.MakeFlavor("default", "1.0005").Obj()).Obj()).
+			Obj()
+		gomega.Expect(k8sClient.Create(ctx, cq)).Should(gomega.Succeed())
+		defer func() {
+			gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, cq)).To(gomega.Succeed())
+		}()
+
+		created := &v1alpha1.ClusterQueue{}
+		gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cq), created)).Should(gomega.Succeed())
+		gomega.Expect(created.Spec.ResourceGroups[0].Flavors[0].Resources[0].NominalQuota).Should(gomega.Equal(resource.MustParse("1001m")))
+	})
+})
+
+var _ = ginkgo.Describe("ClusterQueue validating webhook", func() {
+	ginkgo.DescribeTable("Validating quotas",
+		func(resourceName corev1.ResourceName, nominal, borrowingLimit, lendingLimit string, succeed bool) {
+			flavor := testing.MakeFlavor("default", nominal)
+			if borrowingLimit != "" {
+				flavor.BorrowingLimit(borrowingLimit)
+			}
+			if lendingLimit != "" {
+				flavor.LendingLimit(lendingLimit)
+			}
+			cq := testing.MakeClusterQueue("cluster-queue").
+				Resource(testing.MakeResource(resourceName).Flavor(flavor.Obj()).Obj()).
+				Obj()
+			err := k8sClient.Create(ctx, cq)
+			if succeed {
+				gomega.Expect(err).Should(gomega.Succeed())
+				gomega.Expect(framework.DeleteClusterQueue(ctx, k8sClient, cq)).To(gomega.Succeed())
+			} else {
+				gomega.Expect(err).Should(gomega.HaveOccurred())
+			}
+		},
+		ginkgo.Entry("valid quotas", corev1.ResourceMemory, "2Gi", "1Gi", "1Gi", true),
+		ginkgo.Entry("negative nominal quota", corev1.ResourceMemory, "-1Gi", "", "", false),
+		ginkgo.Entry("negative borrowing limit", corev1.ResourceMemory, "2Gi", "-1Gi", "", false),
+		ginkgo.Entry("lending limit above nominal quota", corev1.ResourceMemory, "1Gi", "", "2Gi", false),
+		ginkgo.Entry("fractional quota", "example.com/gpu", "0.5", "", "", false),
+		ginkgo.Entry("too big quota", corev1.ResourceCPU, "1e17", "", "", false),
+	)
+})
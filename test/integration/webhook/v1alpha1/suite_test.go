@@ -32,11 +32,16 @@ import (
 	// +kubebuilder:scaffold:imports
 )
 
+// admitter is the user allowed to change the admission of Workloads.
+const admitter = "kueue-admitter"
+
 var (
 	cfg       *rest.Config
 	k8sClient client.Client
-	fwk       *framework.Framework
-	ctx       context.Context
+	// admitterClient impersonates the admitter.
+	admitterClient client.Client
+	fwk            *framework.Framework
+	ctx            context.Context
 )
 
 func TestAPIs(t *testing.T) {
@@ -51,11 +56,22 @@ var _ = ginkgo.BeforeSuite(func() {
 		CRDPath:     filepath.Join("..", "..", "..", "..", "config", "crd", "bases"),
 		WebhookPath: filepath.Join("..", "..", "..", "..", "config", "webhook"),
 		ManagerSetup: func(mgr manager.Manager, ctx context.Context) {
-			err := (&kueuev1alpha1.Workload{}).SetupWebhookWithManager(mgr)
+			err := (&kueuev1alpha1.Workload{}).SetupWebhookWithManager(mgr, admitter)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			err = (&kueuev1alpha1.ClusterQueue{}).SetupWebhookWithManager(mgr)
 			gomega.Expect(err).NotTo(gomega.HaveOccurred())
 		},
 	}
 	ctx, cfg, k8sClient = fwk.Setup()
+
+	admitterCfg := rest.CopyConfig(cfg)
+	admitterCfg.Impersonate = rest.ImpersonationConfig{
+		UserName: admitter,
+		Groups:   []string{"system:masters"},
+	}
+	var err error
+	admitterClient, err = client.New(admitterCfg, client.Options{Scheme: k8sClient.Scheme()})
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
 })
 
 var _ = ginkgo.AfterSuite(func() {
@@ -17,10 +17,13 @@ import (
 	"github.com/onsi/ginkgo/v2"
 	"github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"sigs.k8s.io/kueue/apis/kueue/v1alpha1"
+	"sigs.k8s.io/kueue/pkg/util/pointer"
 	"sigs.k8s.io/kueue/pkg/util/testing"
 	"sigs.k8s.io/kueue/test/integration/framework"
 )
@@ -53,6 +56,11 @@ var _ = ginkgo.Describe("Workload defaulting webhook", func() {
 							{
 								Name:  "test",
 								Image: "fake-image",
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{
+										corev1.ResourceCPU: resource.MustParse("1"),
+									},
+								},
 							},
 						},
 					},
@@ -68,5 +76,119 @@ var _ = ginkgo.Describe("Workload defaulting webhook", func() {
 
 			gomega.Expect(created.Spec.PodSets[0].Name).Should(gomega.Equal(v1alpha1.DefaultPodSetName))
 		})
+
+		ginkgo.It("Should set the default queue of the namespace", func() {
+			ginkgo.By("Annotating the namespace")
+			ns.Annotations = map[string]string{v1alpha1.DefaultQueueAnnotation: "default-queue"}
+			gomega.Expect(k8sClient.Update(ctx, ns)).Should(gomega.Succeed())
+
+			ginkgo.By("Creating Workloads without a queue name")
+			created := &v1alpha1.Workload{}
+			gomega.Eventually(func() string {
+				workload := testing.MakeWorkload("", ns.Name).Request(corev1.ResourceCPU, "1").Obj()
+				workload.GenerateName = "workload-"
+				gomega.Expect(k8sClient.Create(ctx, workload)).Should(gomega.Succeed())
+				gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workload), created)).Should(gomega.Succeed())
+				return created.Spec.QueueName
+			}, framework.Timeout, framework.Interval).Should(gomega.Equal("default-queue"))
+		})
+
+		ginkgo.It("Should resolve the priority from the WorkloadPriorityClass", func() {
+			wpc := testing.MakeWorkloadPriorityClass("high").PriorityValue(100).Obj()
+			gomega.Expect(k8sClient.Create(ctx, wpc)).Should(gomega.Succeed())
+			defer func() {
+				gomega.Expect(k8sClient.Delete(ctx, wpc)).Should(gomega.Succeed())
+			}()
+
+			workload := testing.MakeWorkload("workload1", ns.Name).Request(corev1.ResourceCPU, "1").Obj()
+			workload.Spec.PriorityClassName = wpc.Name
+			workload.Spec.PriorityClassSource = v1alpha1.WorkloadPriorityClassSource
+			gomega.Expect(k8sClient.Create(ctx, workload)).Should(gomega.Succeed())
+
+			created := &v1alpha1.Workload{}
+			gomega.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(workload), created)).Should(gomega.Succeed())
+			gomega.Expect(created.Spec.Priority).Should(gomega.Equal(pointer.Int32(100)))
+		})
+	})
+})
+
+var _ = ginkgo.Describe("Workload validating webhook", func() {
+	ginkgo.Context("When creating a Workload", func() {
+		ginkgo.It("Should forbid podSets without pods or requests", func() {
+			ginkgo.By("Creating a Workload without requests")
+			workload := testing.MakeWorkload("workload1", ns.Name).Queue("queue").Obj()
+			gomega.Expect(k8sClient.Create(ctx, workload)).ShouldNot(gomega.Succeed())
+
+			ginkgo.By("Creating a Workload without pods")
+			workload = testing.MakeWorkload("workload1", ns.Name).Queue("queue").Request(corev1.ResourceCPU, "1").Obj()
+			workload.Spec.PodSets[0].Count = 0
+			gomega.Expect(k8sClient.Create(ctx, workload)).ShouldNot(gomega.Succeed())
+		})
+	})
+
+	ginkgo.Context("When updating an admitted Workload", func() {
+		ginkgo.It("Should forbid changing the queue", func() {
+			ginkgo.By("Creating an admitted Workload")
+			workload := testing.MakeWorkload("workload1", ns.Name).Queue("queue").Request(corev1.ResourceCPU, "1").Obj()
+			gomega.Expect(k8sClient.Create(ctx, workload)).Should(gomega.Succeed())
+			workload.Spec.Admission = testing.MakeAdmission("cq").Obj()
+			gomega.Expect(admitterClient.Update(ctx, workload)).Should(gomega.Succeed())
+
+			ginkgo.By("Changing the queue")
+			workload.Spec.QueueName = "other"
+			gomega.Expect(admitterClient.Update(ctx, workload)).ShouldNot(gomega.Succeed())
+
+			ginkgo.By("Changing the queue along with clearing the admission")
+			workload.Spec.Admission = nil
+			gomega.Expect(admitterClient.Update(ctx, workload)).Should(gomega.Succeed())
+		})
+
+		ginkgo.It("Should forbid changing the podSets and the priority", func() {
+			ginkgo.By("Creating an admitted Workload")
+			workload := testing.MakeWorkload("workload1", ns.Name).Queue("queue").Request(corev1.ResourceCPU, "1").Obj()
+			gomega.Expect(k8sClient.Create(ctx, workload)).Should(gomega.Succeed())
+			workload.Spec.Admission = testing.MakeAdmission("cq").Flavor(corev1.ResourceCPU, "default").Obj()
+			gomega.Expect(admitterClient.Update(ctx, workload)).Should(gomega.Succeed())
+
+			ginkgo.By("Changing the podSets")
+			updated := workload.DeepCopy()
+			updated.Spec.PodSets[0].Count = 2
+			gomega.Expect(admitterClient.Update(ctx, updated)).ShouldNot(gomega.Succeed())
+
+			ginkgo.By("Changing the priority")
+			updated = workload.DeepCopy()
+			updated.Spec.Priority = pointer.Int32(100)
+			gomega.Expect(admitterClient.Update(ctx, updated)).ShouldNot(gomega.Succeed())
+		})
+
+		ginkgo.It("Should forbid an admission that doesn't match the podSets", func() {
+			workload := testing.MakeWorkload("workload1", ns.Name).Queue("queue").Request(corev1.ResourceCPU, "1").Obj()
+			gomega.Expect(k8sClient.Create(ctx, workload)).Should(gomega.Succeed())
+			workload.Spec.Admission = testing.MakeAdmission("cq").Obj()
+			workload.Spec.Admission.PodSetFlavors[0].Name = "other"
+			gomega.Expect(admitterClient.Update(ctx, workload)).ShouldNot(gomega.Succeed())
+		})
+	})
+})
+
+var _ = ginkgo.Describe("Workload admission webhook", func() {
+	ginkgo.It("Should only allow the admitter to change the admission", func() {
+		ginkgo.By("Creating an admitted Workload as a user")
+		workload := testing.MakeWorkload("workload1", ns.Name).Queue("queue").Request(corev1.ResourceCPU, "1").
+			Admit(testing.MakeAdmission("cq").Obj()).Obj()
+		gomega.Expect(k8sClient.Create(ctx, workload)).ShouldNot(gomega.Succeed())
+
+		ginkgo.By("Admitting the Workload as a user")
+		workload.Spec.Admission = nil
+		gomega.Expect(k8sClient.Create(ctx, workload)).Should(gomega.Succeed())
+		workload.Spec.Admission = testing.MakeAdmission("cq").Obj()
+		gomega.Expect(k8sClient.Update(ctx, workload)).ShouldNot(gomega.Succeed())
+
+		ginkgo.By("Admitting the Workload as the admitter")
+		gomega.Expect(admitterClient.Update(ctx, workload)).Should(gomega.Succeed())
+
+		ginkgo.By("Clearing the admission as a user")
+		workload.Spec.Admission = nil
+		gomega.Expect(k8sClient.Update(ctx, workload)).ShouldNot(gomega.Succeed())
 	})
 })